@@ -0,0 +1,118 @@
+package authn
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/user-api/model"
+)
+
+var (
+	// ErrInvalidCredentials ...
+	ErrInvalidCredentials = errors.New("Invalid username or password")
+	// ErrPasswordNotSet ...
+	ErrPasswordNotSet = errors.New("User password not set")
+	// ErrUnavailable ...
+	ErrUnavailable = errors.New("Authentication backend unavailable")
+)
+
+// Identity is what a backend knows of the user it authenticated, empty
+// fields are unknown
+type Identity struct {
+	Username  string
+	FullName  string
+	FirstName string
+	LastName  string
+	Country   string
+}
+
+// Backend checks the passwords of users
+type Backend interface {
+	// Name identifies the backend in logs
+	Name() string
+	// Authenticate checks the password of a user, user is nil for users
+	// unknown here
+	Authenticate(ctx context.Context, username, password string, user *model.User) (*Identity, error)
+}
+
+// Route tells which users a backend authenticates and whether users
+// unknown here are created on their first successful login
+type Route struct {
+	Backend Backend
+	Domains []string
+	// Provision creates users on their first login
+	Provision bool
+	// Realm is the slug of the realm provisioned users join, the default
+	// realm if empty
+	Realm string
+}
+
+// Backends selects the backend authenticating a user from the domain of
+// its email address, users of other domains use their local password
+type Backends struct {
+	mu     sync.RWMutex
+	local  *Route
+	routes map[string]*Route
+}
+
+// NewBackends returns backends authenticating every user locally
+func NewBackends() *Backends {
+	return &Backends{
+		local:  &Route{Backend: new(Local)},
+		routes: map[string]*Route{},
+	}
+}
+
+// New returns the backends of the config
+func New(backends []config.AuthBackendConfig) *Backends {
+	b := NewBackends()
+
+	for _, cnf := range backends {
+		route := &Route{
+			Domains:   cnf.Domains,
+			Provision: cnf.Provision,
+			Realm:     cnf.Realm,
+		}
+
+		switch cnf.Type {
+		case "local":
+			route.Backend = new(Local)
+		case "ldap":
+			route.Backend = NewLDAP(cnf.Name, cnf.LDAP)
+		default:
+			continue // refused by config validation
+		}
+
+		b.Register(route)
+	}
+
+	return b
+}
+
+// Register routes the users of the domains of route to its backend,
+// replacing any backend previously registered for them
+func (b *Backends) Register(route *Route) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, domain := range route.Domains {
+		b.routes[strings.ToLower(domain)] = route
+	}
+}
+
+// ForUsername returns the route of a user given its email address
+func (b *Backends) ForUsername(username string) *Route {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if i := strings.LastIndex(username, "@"); i >= 0 {
+		if route, ok := b.routes[strings.ToLower(username[i+1:])]; ok {
+			return route
+		}
+	}
+
+	return b.local
+}
@@ -0,0 +1,80 @@
+package authn_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/resonatecoop/id/authn"
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForUsername(t *testing.T) {
+	backends := authn.New([]config.AuthBackendConfig{
+		{
+			Name:      "corp",
+			Type:      "ldap",
+			Domains:   []string{"Corp.example.com"},
+			Provision: true,
+			LDAP:      config.LDAPConfig{URL: "ldap://127.0.0.1", BaseDN: "dc=example,dc=com"},
+		},
+		{
+			Name:    "contractors",
+			Type:    "local",
+			Domains: []string{"contractors.example.com"},
+		},
+	})
+
+	// Domains are matched case insensitively
+	route := backends.ForUsername("Alice@CORP.example.com")
+	assert.Equal(t, "corp", route.Backend.Name())
+	assert.True(t, route.Provision)
+
+	route = backends.ForUsername("bob@contractors.example.com")
+	assert.Equal(t, "local", route.Backend.Name())
+	assert.False(t, route.Provision)
+
+	// Users of other domains, subdomains included, use their local password
+	for _, username := range []string{"carol@example.com", "dave@sub.corp.example.com", "corp.example.com"} {
+		route = backends.ForUsername(username)
+		assert.Equal(t, "local", route.Backend.Name(), username)
+		assert.False(t, route.Provision, username)
+	}
+
+	// Registered backends replace configured ones
+	backends.Register(&authn.Route{Backend: new(authn.Local), Domains: []string{"corp.example.com"}})
+	assert.Equal(t, "local", backends.ForUsername("alice@corp.example.com").Backend.Name())
+}
+
+func TestLocal(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		backend = new(authn.Local)
+	)
+
+	user := &model.User{
+		Username: "test@user.com",
+		Password: sql.NullString{
+			String: "$2a$10$4J4t9xuWhOKhfjN0bOKNReS9sL3BVSN9zxIr2.VaWWQfRBWh1dQIS",
+			Valid:  true,
+		},
+	}
+
+	identity, err := backend.Authenticate(ctx, "test@user.com", "test_password", user)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "test@user.com", identity.Username)
+	}
+
+	_, err = backend.Authenticate(ctx, "test@user.com", "bogus", user)
+	assert.Equal(t, authn.ErrInvalidCredentials, err)
+
+	// Local users must exist
+	_, err = backend.Authenticate(ctx, "test@user.com", "test_password", nil)
+	assert.Equal(t, authn.ErrInvalidCredentials, err)
+
+	user.Password.Valid = false
+	_, err = backend.Authenticate(ctx, "test@user.com", "test_password", user)
+	assert.Equal(t, authn.ErrPasswordNotSet, err)
+}
@@ -0,0 +1,164 @@
+package authn
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/log"
+	"github.com/resonatecoop/user-api/model"
+)
+
+const (
+	// defaultLDAPTimeout is the timeout of directories without one, in seconds
+	defaultLDAPTimeout = 10
+	// defaultUserFilter finds users by their email address
+	defaultUserFilter = "(mail=%s)"
+)
+
+// LDAP authenticates users by binding to a directory as them. Users are
+// first searched by email address, with a service account or anonymously,
+// then the password is checked by binding with the DN found.
+type LDAP struct {
+	name string
+	cnf  config.LDAPConfig
+}
+
+// NewLDAP returns a backend binding to the directory of the config
+func NewLDAP(name string, cnf config.LDAPConfig) *LDAP {
+	if cnf.Timeout == 0 {
+		cnf.Timeout = defaultLDAPTimeout
+	}
+	if cnf.UserFilter == "" {
+		cnf.UserFilter = defaultUserFilter
+	}
+	if cnf.Attributes.FullName == "" {
+		cnf.Attributes.FullName = "cn"
+	}
+	if cnf.Attributes.FirstName == "" {
+		cnf.Attributes.FirstName = "givenName"
+	}
+	if cnf.Attributes.LastName == "" {
+		cnf.Attributes.LastName = "sn"
+	}
+	if cnf.Attributes.Country == "" {
+		cnf.Attributes.Country = "c"
+	}
+
+	return &LDAP{name: name, cnf: cnf}
+}
+
+// Name identifies the backend in logs
+func (l *LDAP) Name() string {
+	return l.name
+}
+
+// Authenticate binds to the directory as the user, the identity returned
+// holds the attributes of its entry
+func (l *LDAP) Authenticate(ctx context.Context, username, password string, user *model.User) (*Identity, error) {
+	// Directories accept binds without a password as anonymous binds
+	if password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	logger := log.FromContext(ctx).With("backend", l.name)
+
+	conn, err := l.dial()
+	if err != nil {
+		logger.ERROR.Printf("Connecting to the directory failed: %v", err)
+		return nil, ErrUnavailable
+	}
+	defer conn.Close()
+
+	if l.cnf.BindDN != "" {
+		if err = conn.Bind(l.cnf.BindDN, l.cnf.BindPassword); err != nil {
+			logger.ERROR.Printf("Binding with the service account failed: %v", err)
+			return nil, ErrUnavailable
+		}
+	}
+
+	attributes := l.cnf.Attributes
+
+	res, err := conn.Search(ldap.NewSearchRequest(
+		l.cnf.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		2, // more than one entry is ambiguous
+		l.cnf.Timeout,
+		false,
+		fmt.Sprintf(l.cnf.UserFilter, ldap.EscapeFilter(username)),
+		[]string{attributes.FullName, attributes.FirstName, attributes.LastName, attributes.Country},
+		nil,
+	))
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		logger.WARNING.Printf("Several entries match %s", username)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		logger.ERROR.Printf("Searching the directory failed: %v", err)
+		return nil, ErrUnavailable
+	}
+	if len(res.Entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+
+	entry := res.Entries[0]
+
+	if err = conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		logger.ERROR.Printf("Binding as %s failed: %v", entry.DN, err)
+		return nil, ErrUnavailable
+	}
+
+	return &Identity{
+		Username:  strings.ToLower(username),
+		FullName:  entry.GetAttributeValue(attributes.FullName),
+		FirstName: entry.GetAttributeValue(attributes.FirstName),
+		LastName:  entry.GetAttributeValue(attributes.LastName),
+		Country:   entry.GetAttributeValue(attributes.Country),
+	}, nil
+}
+
+// dial connects to the directory, upgrading the connection to TLS if
+// configured to
+func (l *LDAP) dial() (*ldap.Conn, error) {
+	u, err := url.Parse(l.cnf.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := time.Duration(l.cnf.Timeout) * time.Second
+
+	tlsConfig := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: l.cnf.InsecureSkipVerify,
+	}
+
+	conn, err := ldap.DialURL(
+		l.cnf.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+		ldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetTimeout(timeout)
+
+	if l.cnf.StartTLS {
+		if err = conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
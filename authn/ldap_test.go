@@ -0,0 +1,373 @@
+package authn_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/resonatecoop/id/authn"
+	"github.com/resonatecoop/id/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBaseDN       = "dc=example,dc=com"
+	testBindDN       = "cn=admin,dc=example,dc=com"
+	testBindPassword = "admin"
+)
+
+// testEntry is an entry of the test directory
+type testEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// testDirectory is an in-process LDAP server implementing just enough of
+// the protocol for the bind backend: simple binds, searches with equality,
+// presence, and, or and not filters, and unbinds. Searches require binding
+// with the service account.
+type testDirectory struct {
+	listener net.Listener
+	entries  []*testEntry
+	wg       sync.WaitGroup
+}
+
+func newTestDirectory(t *testing.T, entries ...*testEntry) *testDirectory {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	d := &testDirectory{listener: listener, entries: entries}
+
+	d.wg.Add(1)
+	go d.serve()
+
+	t.Cleanup(d.Close)
+
+	return d
+}
+
+// URL returns the URL of the directory
+func (d *testDirectory) URL() string {
+	return "ldap://" + d.listener.Addr().String()
+}
+
+// Close stops the directory
+func (d *testDirectory) Close() {
+	d.listener.Close()
+	d.wg.Wait()
+}
+
+func (d *testDirectory) serve() {
+	defer d.wg.Done()
+
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			return
+		}
+		d.wg.Add(1)
+		go d.handle(conn)
+	}
+}
+
+func (d *testDirectory) handle(conn net.Conn) {
+	defer d.wg.Done()
+	defer conn.Close()
+
+	bound := ""
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		messageID := packet.Children[0].Value.(int64)
+		request := packet.Children[1]
+
+		switch request.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := request.Children[1].Data.String()
+			password := request.Children[2].Data.String()
+
+			bound = ""
+			code := uint64(ldap.LDAPResultInvalidCredentials)
+			if d.checkPassword(dn, password) {
+				bound = dn
+				code = ldap.LDAPResultSuccess
+			}
+
+			writeResult(conn, messageID, ldap.ApplicationBindResponse, code)
+		case ldap.ApplicationSearchRequest:
+			if bound != testBindDN {
+				writeResult(conn, messageID, ldap.ApplicationSearchResultDone, ldap.LDAPResultInsufficientAccessRights)
+				continue
+			}
+
+			baseDN := strings.ToLower(request.Children[0].Data.String())
+			filter := request.Children[6]
+
+			attributes := []string{}
+			for _, attribute := range request.Children[7].Children {
+				attributes = append(attributes, attribute.Data.String())
+			}
+
+			for _, entry := range d.entries {
+				if strings.HasSuffix(strings.ToLower(entry.dn), baseDN) && entry.matches(filter) {
+					conn.Write(entry.encode(messageID, attributes).Bytes())
+				}
+			}
+
+			writeResult(conn, messageID, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess)
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func (d *testDirectory) checkPassword(dn, password string) bool {
+	if strings.EqualFold(dn, testBindDN) {
+		return password == testBindPassword
+	}
+
+	for _, entry := range d.entries {
+		if strings.EqualFold(dn, entry.dn) {
+			return password == entry.password
+		}
+	}
+
+	return false
+}
+
+// matches evaluates a search filter against the entry
+func (e *testEntry) matches(filter *ber.Packet) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !e.matches(child) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if e.matches(child) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !e.matches(filter.Children[0])
+	case ldap.FilterEqualityMatch:
+		for _, value := range e.values(filter.Children[0].Data.String()) {
+			if strings.EqualFold(value, filter.Children[1].Data.String()) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterPresent:
+		return len(e.values(filter.Data.String())) > 0
+	}
+	return false
+}
+
+func (e *testEntry) values(attribute string) []string {
+	for name, values := range e.attributes {
+		if strings.EqualFold(name, attribute) {
+			return values
+		}
+	}
+	return nil
+}
+
+// encode returns the search result entry of the entry, with the attributes
+// requested only
+func (e *testEntry) encode(messageID int64, attributes []string) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "DN"))
+
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for _, name := range attributes {
+		values := e.values(name)
+		if len(values) == 0 {
+			continue
+		}
+
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+
+		list.AppendChild(attribute)
+	}
+	entry.AppendChild(list)
+
+	packet.AppendChild(entry)
+
+	return packet
+}
+
+// writeResult writes a response carrying a result code only
+func writeResult(conn net.Conn, messageID int64, tag ber.Tag, code uint64) {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	packet.AppendChild(result)
+
+	conn.Write(packet.Bytes())
+}
+
+func newTestLDAP(url string) *authn.LDAP {
+	return authn.NewLDAP("corp", config.LDAPConfig{
+		URL:          url,
+		Timeout:      2,
+		BindDN:       testBindDN,
+		BindPassword: testBindPassword,
+		BaseDN:       testBaseDN,
+		UserFilter:   "(&(objectClass=person)(mail=%s))",
+	})
+}
+
+func TestLDAP(t *testing.T) {
+	ctx := context.Background()
+
+	directory := newTestDirectory(t,
+		&testEntry{
+			dn:       "uid=alice,ou=people,dc=example,dc=com",
+			password: "alice-secret",
+			attributes: map[string][]string{
+				"objectClass": {"person"},
+				"mail":        {"alice@corp.example.com"},
+				"cn":          {"Alice Liddell"},
+				"givenName":   {"Alice"},
+				"sn":          {"Liddell"},
+				"c":           {"GB"},
+			},
+		},
+		&testEntry{
+			dn:       "uid=bob,ou=people,dc=example,dc=com",
+			password: "bob-secret",
+			attributes: map[string][]string{
+				"objectClass": {"person"},
+				"mail":        {"bob@corp.example.com"},
+			},
+		},
+		// entries outside the base DN are not users
+		&testEntry{
+			dn:       "uid=eve,dc=other,dc=com",
+			password: "eve-secret",
+			attributes: map[string][]string{
+				"objectClass": {"person"},
+				"mail":        {"eve@corp.example.com"},
+			},
+		},
+	)
+
+	backend := newTestLDAP(directory.URL())
+	assert.Equal(t, "corp", backend.Name())
+
+	// Attributes are mapped onto the identity
+	identity, err := backend.Authenticate(ctx, "Alice@corp.example.com", "alice-secret", nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, &authn.Identity{
+			Username:  "alice@corp.example.com",
+			FullName:  "Alice Liddell",
+			FirstName: "Alice",
+			LastName:  "Liddell",
+			Country:   "GB",
+		}, identity)
+	}
+
+	// Attributes missing from the entry are unknown
+	identity, err = backend.Authenticate(ctx, "bob@corp.example.com", "bob-secret", nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, &authn.Identity{Username: "bob@corp.example.com"}, identity)
+	}
+
+	// Wrong password
+	_, err = backend.Authenticate(ctx, "alice@corp.example.com", "bob-secret", nil)
+	assert.Equal(t, authn.ErrInvalidCredentials, err)
+
+	// Empty passwords would be anonymous binds
+	_, err = backend.Authenticate(ctx, "alice@corp.example.com", "", nil)
+	assert.Equal(t, authn.ErrInvalidCredentials, err)
+
+	// Unknown user
+	_, err = backend.Authenticate(ctx, "carol@corp.example.com", "alice-secret", nil)
+	assert.Equal(t, authn.ErrInvalidCredentials, err)
+
+	// Outside the base DN
+	_, err = backend.Authenticate(ctx, "eve@corp.example.com", "eve-secret", nil)
+	assert.Equal(t, authn.ErrInvalidCredentials, err)
+
+	// Usernames are escaped, wildcards match no entry
+	_, err = backend.Authenticate(ctx, "*", "alice-secret", nil)
+	assert.Equal(t, authn.ErrInvalidCredentials, err)
+
+	_, err = backend.Authenticate(ctx, "*)(mail=*", "alice-secret", nil)
+	assert.Equal(t, authn.ErrInvalidCredentials, err)
+}
+
+func TestLDAPAmbiguous(t *testing.T) {
+	directory := newTestDirectory(t,
+		&testEntry{
+			dn:         "uid=alice,ou=people,dc=example,dc=com",
+			password:   "alice-secret",
+			attributes: map[string][]string{"objectClass": {"person"}, "mail": {"alice@corp.example.com"}},
+		},
+		&testEntry{
+			dn:         "uid=alice2,ou=people,dc=example,dc=com",
+			password:   "alice-secret",
+			attributes: map[string][]string{"objectClass": {"person"}, "mail": {"alice@corp.example.com"}},
+		},
+	)
+
+	_, err := newTestLDAP(directory.URL()).Authenticate(context.Background(), "alice@corp.example.com", "alice-secret", nil)
+	assert.Equal(t, authn.ErrInvalidCredentials, err)
+}
+
+func TestLDAPUnavailable(t *testing.T) {
+	ctx := context.Background()
+
+	directory := newTestDirectory(t)
+
+	// Wrong service account
+	backend := authn.NewLDAP("corp", config.LDAPConfig{
+		URL:          directory.URL(),
+		Timeout:      2,
+		BindDN:       testBindDN,
+		BindPassword: "bogus",
+		BaseDN:       testBaseDN,
+	})
+	_, err := backend.Authenticate(ctx, "alice@corp.example.com", "alice-secret", nil)
+	assert.Equal(t, authn.ErrUnavailable, err)
+
+	// Searches refused
+	backend = authn.NewLDAP("corp", config.LDAPConfig{URL: directory.URL(), Timeout: 2, BaseDN: testBaseDN})
+	_, err = backend.Authenticate(ctx, "alice@corp.example.com", "alice-secret", nil)
+	assert.Equal(t, authn.ErrUnavailable, err)
+
+	// Directory down
+	url := directory.URL()
+	directory.Close()
+
+	_, err = newTestLDAP(url).Authenticate(ctx, "alice@corp.example.com", "alice-secret", nil)
+	assert.Equal(t, authn.ErrUnavailable, err)
+}
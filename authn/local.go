@@ -0,0 +1,34 @@
+package authn
+
+import (
+	"context"
+
+	pass "github.com/resonatecoop/id/util/password"
+	"github.com/resonatecoop/user-api/model"
+)
+
+// Local checks passwords against the bcrypt, or legacy phpass, hash stored
+// on users
+type Local struct{}
+
+// Name identifies the backend in logs
+func (l *Local) Name() string {
+	return "local"
+}
+
+// Authenticate checks the password of a user against its hash
+func (l *Local) Authenticate(ctx context.Context, username, password string, user *model.User) (*Identity, error) {
+	if user == nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	if !user.Password.Valid {
+		return nil, ErrPasswordNotSet
+	}
+
+	if pass.VerifyPassword(user.Password.String, password) != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Identity{Username: user.Username}, nil
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/oauth"
+)
+
+var (
+	// ErrPasswordRequired ...
+	ErrPasswordRequired = errors.New("A password is required")
+
+	// stdout and stdin are replaced in tests
+	stdout io.Writer = os.Stdout
+	stdin  io.Reader = os.Stdin
+)
+
+// withOauthService loads the config, connects to the database and calls fn
+// with an oauth service, for commands run once from the command line
+func withOauthService(configBackend string, fn func(cnf *config.Config, s oauth.ServiceInterface) error) error {
+	cnf, db, err := initConfigDB(true, false, configBackend)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	service := oauth.NewService(cnf, db)
+	defer service.Close()
+
+	return fn(cnf, service)
+}
+
+// newTabWriter returns a writer aligning tab separated columns
+func newTabWriter() *tabwriter.Writer {
+	return tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+}
+
+// readPassword returns password, or the first line read from stdin
+// when it is empty
+func readPassword(password string) (string, error) {
+	if password != "" {
+		return password, nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	password = strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", ErrPasswordRequired
+	}
+
+	return password, nil
+}
@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/util"
+)
+
+// CreateClient creates an oauth client, a secret is generated if none is
+// given. The secret is printed once as only its hash gets stored.
+func CreateClient(configBackend, clientID, secret, redirectURI, applicationName, applicationHostname, applicationURL string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		var err error
+
+		if secret == "" {
+			if secret, err = util.GenerateSecret(); err != nil {
+				return err
+			}
+		}
+
+		client, err := s.CreateClient(clientID, secret, redirectURI, applicationName, applicationHostname, applicationURL)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Created client %s (%s)\n", client.Key, client.ID)
+		fmt.Fprintf(stdout, "Secret: %s\n", secret)
+
+		return nil
+	})
+}
+
+// ListClients prints every oauth client
+func ListClients(configBackend string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		clients, err := s.ListClients()
+		if err != nil {
+			return err
+		}
+
+		w := newTabWriter()
+		fmt.Fprintln(w, "ID\tCLIENT ID\tNAME\tREDIRECT URI\tCREATED")
+		for _, client := range clients {
+			fmt.Fprintf(
+				w,
+				"%s\t%s\t%s\t%s\t%s\n",
+				client.ID,
+				client.Key,
+				client.ApplicationName.String,
+				client.RedirectURI.String,
+				client.CreatedAt.Format("2006-01-02"),
+			)
+		}
+
+		return w.Flush()
+	})
+}
+
+// RotateClientSecret generates a new secret for a client and prints it
+func RotateClientSecret(configBackend, clientID string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		client, err := s.FindClientByClientID(clientID)
+		if err != nil {
+			return err
+		}
+
+		secret, err := util.GenerateSecret()
+		if err != nil {
+			return err
+		}
+
+		if err := s.RotateClientSecret(client, secret); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Rotated secret of client %s\n", client.Key)
+		fmt.Fprintf(stdout, "Secret: %s\n", secret)
+
+		return nil
+	})
+}
+
+// DeleteClient deletes a client and revokes the tokens issued to it
+func DeleteClient(configBackend, clientID string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		client, err := s.FindClientByClientID(clientID)
+		if err != nil {
+			return err
+		}
+
+		if err := s.DeleteClient(client); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Deleted client %s\n", client.Key)
+
+		return nil
+	})
+}
+
+// SetClientScopes replaces the scopes a client may request, no scopes limit
+// the client to the default scope
+func SetClientScopes(configBackend, clientID string, scopes []string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		client, err := s.FindClientByClientID(clientID)
+		if err != nil {
+			return err
+		}
+
+		if err := s.SetClientScopes(client, scopes); err != nil {
+			return err
+		}
+
+		if len(scopes) == 0 {
+			fmt.Fprintf(stdout, "Client %s may request the default scope\n", client.Key)
+			return nil
+		}
+
+		fmt.Fprintf(stdout, "Client %s may request %s\n", client.Key, strings.Join(scopes, " "))
+
+		return nil
+	})
+}
+
+// SetClientLogout replaces the logout URIs of a client, so first party
+// clients which were not registered dynamically take part in single logout
+func SetClientLogout(configBackend, clientID string, postLogoutRedirectURIs []string, backchannelURI, frontchannelURI string, sessionRequired bool) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		client, err := s.FindClientByClientID(clientID)
+		if err != nil {
+			return err
+		}
+
+		logout := &oauth.ClientLogout{
+			PostLogoutRedirectURIs:            postLogoutRedirectURIs,
+			BackchannelLogoutURI:              backchannelURI,
+			BackchannelLogoutSessionRequired:  sessionRequired && backchannelURI != "",
+			FrontchannelLogoutURI:             frontchannelURI,
+			FrontchannelLogoutSessionRequired: sessionRequired && frontchannelURI != "",
+		}
+
+		if err := s.SetClientLogout(client, logout); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Updated the logout URIs of client %s\n", client.Key)
+
+		return nil
+	})
+}
+
+// SetClientAuthorization replaces the redirect URIs of a client and how it
+// secures its authorization requests, so first party clients which were not
+// registered dynamically may use several redirect URIs, pushed
+// authorization requests and request objects
+func SetClientAuthorization(configBackend, clientID string, redirectURIs []string, jwksURI string, requirePushed bool, requestObjectSigningAlg string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		client, err := s.FindClientByClientID(clientID)
+		if err != nil {
+			return err
+		}
+
+		authorization := &oauth.ClientAuthorization{
+			JWKSURI:                            jwksURI,
+			RequirePushedAuthorizationRequests: requirePushed,
+			RequestObjectSigningAlg:            requestObjectSigningAlg,
+		}
+
+		if err := s.SetClientAuthorization(client, redirectURIs, authorization); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Updated the authorization settings of client %s\n", client.Key)
+
+		return nil
+	})
+}
+
+// SetTokenExchangePolicy lets a client exchange the access tokens of users
+// issued to the subject clients for tokens restricted to resources
+func SetTokenExchangePolicy(configBackend, clientID string, subjectClients, resources, scopes []string, allowImpersonation bool) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		client, err := s.FindClientByClientID(clientID)
+		if err != nil {
+			return err
+		}
+
+		policy := &oauth.TokenExchangePolicy{
+			SubjectClients:     subjectClients,
+			Resources:          resources,
+			Scopes:             scopes,
+			AllowImpersonation: allowImpersonation,
+		}
+
+		if err := s.SetTokenExchangePolicy(client, policy); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Updated the token exchange policy of client %s\n", client.Key)
+
+		return nil
+	})
+}
+
+// DeleteTokenExchangePolicy stops a client from exchanging tokens
+func DeleteTokenExchangePolicy(configBackend, clientID string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		client, err := s.FindClientByClientID(clientID)
+		if err != nil {
+			return err
+		}
+
+		if err := s.DeleteTokenExchangePolicy(client); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Deleted the token exchange policy of client %s\n", client.Key)
+
+		return nil
+	})
+}
+
+// VerifyClient marks a client as verified by the co-op, users see it on the
+// consent screen
+func VerifyClient(configBackend, clientID string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		client, err := s.FindClientByClientID(clientID)
+		if err != nil {
+			return err
+		}
+
+		if err := s.VerifyClient(client); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Verified client %s\n", client.Key)
+
+		return nil
+	})
+}
+
+// UnverifyClient withdraws the verification of a client
+func UnverifyClient(configBackend, clientID string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		client, err := s.FindClientByClientID(clientID)
+		if err != nil {
+			return err
+		}
+
+		if err := s.UnverifyClient(client); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Withdrew the verification of client %s\n", client.Key)
+
+		return nil
+	})
+}
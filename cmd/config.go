@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/resonatecoop/id/config"
+)
+
+// DumpConfig prints the loaded configuration as JSON with secrets redacted
+func DumpConfig(configBackend string) error {
+	cnf := config.NewConfig(true, false, configBackend)
+
+	data, err := json.MarshalIndent(cnf.Redacted(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, string(data))
+
+	return nil
+}
+
+// CheckConfig loads and validates the configuration, resolves its secrets
+// and makes sure the database and the config backend can be reached.
+// An invalid config makes the command exit with a non zero status.
+func CheckConfig(configBackend string) error {
+	cnf, db, err := initConfigDB(true, false, configBackend)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cnf.Health.Timeout)*time.Second)
+	defer cancel()
+
+	if err := config.CheckBackendHealth(ctx); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, "Config is valid")
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/resonatecoop/id/database/fixtures"
+	"github.com/resonatecoop/id/database/migrations"
+)
+
+// MigrateUp applies pending migrations
+func MigrateUp(configBackend string) error {
+	_, db, err := initConfigDB(true, false, configBackend)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	group, err := migrations.Up(context.Background(), db)
+	if err != nil {
+		return err
+	}
+
+	if group.IsZero() {
+		fmt.Fprintln(stdout, "No pending migrations")
+		return nil
+	}
+
+	fmt.Fprintf(stdout, "Migrated to %s\n", group)
+
+	return nil
+}
+
+// MigrateDown rolls back the last group of migrations
+func MigrateDown(configBackend string) error {
+	_, db, err := initConfigDB(true, false, configBackend)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	group, err := migrations.Down(context.Background(), db)
+	if err != nil {
+		return err
+	}
+
+	if group.IsZero() {
+		fmt.Fprintln(stdout, "No migrations to roll back")
+		return nil
+	}
+
+	fmt.Fprintf(stdout, "Rolled back %s\n", group)
+
+	return nil
+}
+
+// MigrationStatus prints every migration and whether it is applied
+func MigrationStatus(configBackend string) error {
+	_, db, err := initConfigDB(true, false, configBackend)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ms, err := migrations.Status(context.Background(), db)
+	if err != nil {
+		return err
+	}
+
+	w := newTabWriter()
+	fmt.Fprintln(w, "MIGRATION\tGROUP\tMIGRATED AT")
+	for _, m := range ms {
+		if !m.IsApplied() {
+			fmt.Fprintf(w, "%s\t-\tpending\n", m.Name)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", m.Name, m.GroupID, m.MigratedAt.Format("2006-01-02 15:04:05"))
+	}
+
+	return w.Flush()
+}
+
+// LoadFixtures inserts the default roles, scopes and configured clients,
+// the secrets of created clients are printed once
+func LoadFixtures(configBackend string) error {
+	cnf, db, err := initConfigDB(true, false, configBackend)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	created, err := fixtures.Load(context.Background(), db, cnf.Clients)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, "Loaded roles and scopes")
+
+	for _, c := range created {
+		fmt.Fprintf(stdout, "Created client %s, secret: %s\n", c.Client.Key, c.Secret)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/oauth"
+)
+
+// CreateInitialAccessToken issues a token for dynamic client registration
+// in the realm with the given slug and prints it, the token cannot be
+// retrieved later
+func CreateInitialAccessToken(configBackend, realmSlug, description string, expiresIn time.Duration) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		rlm, err := s.GetRealmService().FindBySlug(context.Background(), realmSlug)
+		if err != nil {
+			return err
+		}
+
+		token, initialAccessToken, err := s.CreateInitialAccessToken(rlm.ID, description, expiresIn)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "ID: %s\n", initialAccessToken.ID)
+		fmt.Fprintf(stdout, "Realm: %s\n", rlm.Slug)
+		fmt.Fprintf(stdout, "Token: %s\n", token)
+		if initialAccessToken.ExpiresAt.Valid {
+			fmt.Fprintf(stdout, "Expires: %s\n", initialAccessToken.ExpiresAt.Time.Format(time.RFC3339))
+		}
+
+		return nil
+	})
+}
+
+// ListInitialAccessTokens prints every initial access token
+func ListInitialAccessTokens(configBackend string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		tokens, err := s.ListInitialAccessTokens()
+		if err != nil {
+			return err
+		}
+
+		w := newTabWriter()
+		fmt.Fprintln(w, "ID\tREALM\tDESCRIPTION\tEXPIRES\tCREATED")
+		for _, token := range tokens {
+			rlm, err := s.GetRealmService().FindByID(context.Background(), token.RealmID)
+			if err != nil {
+				return err
+			}
+			expires := "never"
+			if token.ExpiresAt.Valid {
+				expires = token.ExpiresAt.Time.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", token.ID, rlm.Slug, token.Description, expires, token.CreatedAt.Format("2006-01-02"))
+		}
+
+		return w.Flush()
+	})
+}
+
+// RevokeInitialAccessToken deletes an initial access token
+func RevokeInitialAccessToken(configBackend, id string) error {
+	tokenID, err := uuid.Parse(id)
+	if err != nil {
+		return fmt.Errorf("invalid token id %q", id)
+	}
+
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		if err := s.DeleteInitialAccessToken(tokenID); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Revoked initial access token %s\n", tokenID)
+
+		return nil
+	})
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/oauth"
+)
+
+// ListResources prints every resource tokens can be restricted to
+func ListResources(configBackend string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		resources, err := s.ListResources()
+		if err != nil {
+			return err
+		}
+
+		w := newTabWriter()
+		fmt.Fprintln(w, "URI\tNAMESPACE\tDESCRIPTION")
+		for _, resource := range resources {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", resource.URI, resource.Namespace, resource.Description)
+		}
+
+		return w.Flush()
+	})
+}
+
+// AddResource registers a resource
+func AddResource(configBackend, uri, namespace, description string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		resource, err := s.CreateResource(uri, namespace, description)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Added resource %s (%s:*)\n", resource.URI, resource.Namespace)
+
+		return nil
+	})
+}
+
+// DeleteResource removes a resource
+func DeleteResource(configBackend, uri string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		if err := s.DeleteResource(uri); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Deleted resource %s\n", uri)
+
+		return nil
+	})
+}
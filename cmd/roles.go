@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/oauth"
+)
+
+// ListRoles prints every role with the permissions it grants
+func ListRoles(configBackend string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		roles, err := s.GetRBACService().ListRoles(context.Background())
+		if err != nil {
+			return err
+		}
+
+		w := newTabWriter()
+		fmt.Fprintln(w, "ID\tNAME\tBUILTIN\tPERMISSIONS")
+		for _, role := range roles {
+			fmt.Fprintf(w, "%d\t%s\t%t\t%s\n", role.ID, role.Name, role.Builtin, strings.Join(role.Permissions, " "))
+		}
+
+		return w.Flush()
+	})
+}
+
+// CreateRole adds a custom role granting permissions
+func CreateRole(configBackend, name, description string, permissions []string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		role, err := s.GetRBACService().CreateRole(context.Background(), name, description, permissions)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Created role %s (%d)\n", role.Name, role.ID)
+
+		return nil
+	})
+}
+
+// GrantRole grants a role to a user
+func GrantRole(configBackend, username, roleName string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		ctx := context.Background()
+
+		user, err := s.FindUserByUsername(username)
+		if err != nil {
+			return err
+		}
+
+		role, err := s.GetRBACService().FindRoleByName(ctx, roleName)
+		if err != nil {
+			return err
+		}
+
+		if err := s.GetRBACService().AssignRole(ctx, user, role, uuid.Nil); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Granted %s to %s\n", role.Name, user.Username)
+
+		return nil
+	})
+}
+
+// RevokeRole revokes a role from a user
+func RevokeRole(configBackend, username, roleName string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		ctx := context.Background()
+
+		user, err := s.FindUserByUsername(username)
+		if err != nil {
+			return err
+		}
+
+		role, err := s.GetRBACService().FindRoleByName(ctx, roleName)
+		if err != nil {
+			return err
+		}
+
+		if err := s.GetRBACService().RevokeRole(ctx, user, role, uuid.Nil); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Revoked %s from %s\n", role.Name, user.Username)
+
+		return nil
+	})
+}
@@ -1,14 +1,20 @@
 package cmd
 
 import (
+	"context"
 	"net/http"
 	"time"
 
 	"github.com/gorilla/csrf"
 	"github.com/gorilla/mux"
 	"github.com/phyber/negroni-gzip/gzip"
+	"github.com/resonatecoop/id/i18n"
 	"github.com/resonatecoop/id/log"
+	"github.com/resonatecoop/id/metrics"
+	"github.com/resonatecoop/id/realm"
 	"github.com/resonatecoop/id/services"
+	"github.com/resonatecoop/id/tracing"
+	"github.com/resonatecoop/id/util/response"
 	"github.com/unrolled/secure"
 	"github.com/urfave/negroni"
 	"gopkg.in/tylerb/graceful.v1"
@@ -22,12 +28,51 @@ func RunServer(configBackend string) error {
 	}
 	defer db.Close()
 
+	// set up tracing before any outbound client gets created
+	shutdownTracing, err := tracing.Init(cnf)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.ERROR.Print(err)
+		}
+	}()
+
+	// export database connection pool stats
+	if err := metrics.RegisterDBStats(db, "id"); err != nil {
+		return err
+	}
+
 	// start the services
 	if err := services.Init(cnf, db); err != nil {
 		return err
 	}
 	defer services.Close()
 
+	// Prometheus metrics are served apart from the app, on an internal port
+	if cnf.Metrics.Port != "" {
+		metricsRouter := mux.NewRouter()
+		metricsRouter.Methods("GET").
+			Path("/metrics").
+			Name("metrics").
+			Handler(metrics.Handler())
+
+		metricsServer := &http.Server{Addr: cnf.Metrics.Port, Handler: metricsRouter}
+		go func() {
+			log.INFO.Printf("Serving metrics on localhost%v", cnf.Metrics.Port)
+			if err := metricsServer.ListenAndServe(); err != http.ErrServerClosed {
+				log.ERROR.Print(err)
+			}
+		}()
+		defer metricsServer.Close()
+	}
+
+	// start background jobs, only one replica runs each of them
+	if cnf.Scheduler.Enabled {
+		services.SchedulerService.Start()
+	}
+
 	secureMiddleware := secure.New(secure.Options{
 		FrameDeny:          false, // already set in web/render.go
 		ContentTypeNosniff: true,
@@ -36,8 +81,13 @@ func RunServer(configBackend string) error {
 	})
 	// Start a classic negroni app
 	app := negroni.New()
-	app.Use(negroni.NewRecovery())
-	app.Use(negroni.NewLogger())
+	recovery := negroni.NewRecovery()
+	recovery.Logger = log.ERROR
+	app.Use(recovery)
+	app.Use(log.NewRequestID())
+	app.Use(tracing.NewMiddleware(cnf.Tracing.ServiceName))
+	app.Use(response.NewURLLogger())
+	app.Use(realm.NewMiddleware(services.RealmService))
 	app.Use(gzip.Gzip(gzip.DefaultCompression))
 	app.Use(negroni.HandlerFunc(secureMiddleware.HandlerFuncWithNext))
 	app.Use(negroni.NewStatic(http.Dir("public")))
@@ -48,7 +98,11 @@ func RunServer(configBackend string) error {
 	// Add routes
 	services.HealthService.RegisterRoutes(router, "/v1")
 	services.OauthService.RegisterRoutes(router, "/v1/oauth")
+	services.RealmService.RegisterRoutes(router, "/v1/realms")
+	services.RBACService.RegisterRoutes(router, "/v1/rbac")
+	services.EventsService.RegisterRoutes(router, "/v1/webhooks")
 	services.WebHookService.RegisterRoutes(router, "/webhook")
+	services.SCIMService.RegisterRoutes(router, "/scim/v2")
 
 	webRoutes := mux.NewRouter()
 	services.WebService.RegisterRoutes(webRoutes, "/web")
@@ -61,6 +115,7 @@ func RunServer(configBackend string) error {
 	)
 
 	router.PathPrefix("").Handler(negroni.New(
+		i18n.NewMiddleware(cnf),
 		negroni.Wrap(CSRF(webRoutes)),
 	))
 
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/oauth"
+)
+
+// ListScopes prints every scope
+func ListScopes(configBackend string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		scopes, err := s.ListScopes()
+		if err != nil {
+			return err
+		}
+
+		w := newTabWriter()
+		fmt.Fprintln(w, "NAME\tDEFAULT\tDESCRIPTION")
+		for _, scope := range scopes {
+			fmt.Fprintf(w, "%s\t%t\t%s\n", scope.Name, scope.IsDefault, scope.Description)
+		}
+
+		return w.Flush()
+	})
+}
+
+// AddScope creates a scope
+func AddScope(configBackend, name, description string, isDefault bool) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		scope, err := s.CreateScope(name, description, isDefault)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Added scope %s\n", scope.Name)
+
+		return nil
+	})
+}
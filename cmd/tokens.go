@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/oauth"
+)
+
+// PurgeExpiredTokens deletes expired tokens, authorization codes, email
+// tokens, stale sessions and pushed authorization requests, like the
+// scheduler cleanup jobs do
+func PurgeExpiredTokens(configBackend string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		batchSize := cnf.Scheduler.BatchSize
+
+		for _, purge := range []struct {
+			name string
+			run  func(batchSize int) (int, error)
+		}{
+			{"access tokens", s.PurgeExpiredAccessTokens},
+			{"refresh tokens", s.PurgeExpiredRefreshTokens},
+			{"authorization codes", s.PurgeExpiredAuthorizationCodes},
+			{"email tokens", s.PurgeExpiredEmailTokens},
+			{"sessions", s.PurgeStaleSessions},
+			{"pushed authorization requests", s.PurgeExpiredPushedAuthorizationRequests},
+		} {
+			removed, err := purge.run(batchSize)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(stdout, "Purged %d expired %s\n", removed, purge.name)
+		}
+
+		return nil
+	})
+}
+
+// RevokeUserTokens revokes every token issued to a user
+func RevokeUserTokens(configBackend, username string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		user, err := s.FindUserByUsername(username)
+		if err != nil {
+			return err
+		}
+
+		if err := s.RevokeUserTokens(user); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Revoked tokens of %s\n", user.Username)
+
+		return nil
+	})
+}
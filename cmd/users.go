@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/oauth"
+	pass "github.com/resonatecoop/id/util/password"
+)
+
+// FindUser prints the account details of a user
+func FindUser(configBackend, username string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		user, err := s.FindUserByUsername(username)
+		if err != nil {
+			return err
+		}
+
+		w := newTabWriter()
+		fmt.Fprintf(w, "ID\t%s\n", user.ID)
+		fmt.Fprintf(w, "Username\t%s\n", user.Username)
+		fmt.Fprintf(w, "Full name\t%s\n", user.FullName)
+		fmt.Fprintf(w, "Role\t%d\n", user.RoleID)
+		fmt.Fprintf(w, "Email confirmed\t%t\n", user.EmailConfirmed)
+		fmt.Fprintf(w, "Member\t%t\n", user.Member)
+		fmt.Fprintf(w, "Locked\t%t\n", !user.Password.Valid)
+		fmt.Fprintf(w, "Created\t%s\n", user.CreatedAt.Format("2006-01-02 15:04:05"))
+		fmt.Fprintf(w, "Last login\t%s\n", user.LastLogin.Format("2006-01-02 15:04:05"))
+
+		return w.Flush()
+	})
+}
+
+// ConfirmUserEmail marks the email address of a user as confirmed
+func ConfirmUserEmail(configBackend, username string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		if err := s.ConfirmUserEmail(username); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Confirmed email of %s\n", username)
+
+		return nil
+	})
+}
+
+// SetUserPassword sets the password of a user, it is read from stdin
+// when empty so it does not end up in the shell history
+func SetUserPassword(configBackend, username, password string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		user, err := s.FindUserByUsername(username)
+		if err != nil {
+			return err
+		}
+
+		password, err := readPassword(password)
+		if err != nil {
+			return err
+		}
+
+		if err := pass.ValidatePassword(password); err != nil {
+			return err
+		}
+
+		if err := s.SetPassword(user, password); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Set password of %s\n", user.Username)
+
+		return nil
+	})
+}
+
+// SetUserRole changes the role of a user
+func SetUserRole(configBackend, username string, roleID int32) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		user, err := s.FindUserByUsername(username)
+		if err != nil {
+			return err
+		}
+
+		if err := s.SetUserRole(user, roleID, uuid.Nil); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Set role of %s to %d\n", user.Username, roleID)
+
+		return nil
+	})
+}
+
+// LockUser prevents a user from logging in until its password is reset
+func LockUser(configBackend, username string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		user, err := s.FindUserByUsername(username)
+		if err != nil {
+			return err
+		}
+
+		if err := s.LockUser(user); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Locked %s\n", user.Username)
+
+		return nil
+	})
+}
+
+// ListImpersonations prints the impersonations of a user, or by a user, with
+// their audit trail
+func ListImpersonations(configBackend, username string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		user, err := s.FindUserByUsername(username)
+		if err != nil {
+			return err
+		}
+
+		impersonations, err := s.ListImpersonations(user)
+		if err != nil {
+			return err
+		}
+
+		w := newTabWriter()
+		for _, impersonation := range impersonations {
+			fmt.Fprintf(w, "%s\t%s acted as %s\t%s\n",
+				impersonation.StartedAt.Format("2006-01-02 15:04:05"),
+				impersonation.ActorUsername,
+				impersonation.Username,
+				impersonation.Reason,
+			)
+
+			events, err := s.ListImpersonationEvents(impersonation)
+			if err != nil {
+				return err
+			}
+
+			for _, event := range events {
+				fmt.Fprintf(w, "  %s\t%s\t%s\n",
+					event.CreatedAt.Format("2006-01-02 15:04:05"),
+					event.Action,
+					event.Detail,
+				)
+			}
+		}
+
+		return w.Flush()
+	})
+}
+
+// SyncUserAPI delivers the changes due to the user api right away and
+// prints what is left
+func SyncUserAPI(configBackend string) error {
+	return withOauthService(configBackend, func(cnf *config.Config, s oauth.ServiceInterface) error {
+		delivered, err := s.SyncUserAPI(cnf.Scheduler.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		pending, failed, err := s.CountPendingUserAPIChanges()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Delivered %d changes, %d pending, %d given up on\n", delivered, pending, failed)
+
+		return nil
+	})
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"github.com/resonatecoop/id/config"
 	"github.com/resonatecoop/id/database"
+	"github.com/resonatecoop/id/log"
 
 	//	"github.com/resonatecoop/id/database"
 	"github.com/uptrace/bun"
@@ -13,6 +14,25 @@ func initConfigDB(mustLoadOnce, keepReloading bool, configBackend string) (*conf
 	// Config
 	cnf := config.NewConfig(mustLoadOnce, keepReloading, configBackend)
 
+	// Logging
+	if err := configureLogging(cnf); err != nil {
+		return nil, nil, err
+	}
+
+	// change the log level and format without restarting
+	if keepReloading {
+		_, err := config.Subscribe("Log", func(cnf *config.Config) {
+			if err := configureLogging(cnf); err != nil {
+				log.ERROR.Print(err)
+				return
+			}
+			log.INFO.Printf("Log level set to %s", log.GetLevel())
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
 	// Database
 	db, err := database.NewDatabase(cnf)
 	if err != nil {
@@ -21,3 +41,17 @@ func initConfigDB(mustLoadOnce, keepReloading bool, configBackend string) (*conf
 
 	return cnf, db, nil
 }
+
+// configureLogging applies the log settings, lines are written as
+// JSON unless running in development mode
+func configureLogging(cnf *config.Config) error {
+	format := cnf.Log.Format
+	if format == "" {
+		format = log.FormatJSON
+		if cnf.IsDevelopment {
+			format = log.FormatConsole
+		}
+	}
+
+	return log.Configure(cnf.Log.Level, format)
+}
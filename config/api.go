@@ -1,33 +1,58 @@
 package config
 
 import (
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"time"
 
 	httptransport "github.com/go-openapi/runtime/client"
 	"github.com/go-openapi/strfmt"
 	apiclient "github.com/resonatecoop/user-api-client/client"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 var (
-	insecureSkipVerify = true // TODO keep this only for development
-	basepath           = ""
-	schemes            = []string{}
+	basepath = ""
+	schemes  = []string{}
+
+	// ErrInvalidCACertificate ...
+	ErrInvalidCACertificate = errors.New("UserAPI.CACertificate is not a PEM encoded certificate")
 )
 
-// NewAPIClient
-func NewAPIClient(address, port string) *apiclient.ResonateServiceDocumentationUser {
-	httpClient, err := httptransport.TLSClient(httptransport.TLSClientOptions{
-		InsecureSkipVerify: insecureSkipVerify,
-	})
+// NewAPIClient returns a client of the user api. The certificate of the
+// user api is verified against the system roots and UserAPI.CACertificate,
+// and every call is bounded by UserAPI.Timeout.
+func NewAPIClient(cnf *Config) (*apiclient.ResonateServiceDocumentationUser, error) {
+	options := httptransport.TLSClientOptions{
+		InsecureSkipVerify: cnf.UserAPI.InsecureSkipVerify,
+	}
+
+	if cnf.UserAPI.CACertificate != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(cnf.UserAPI.CACertificate)) {
+			return nil, ErrInvalidCACertificate
+		}
+		options.LoadedCAPool = pool
+	}
 
+	httpClient, err := httptransport.TLSClient(options)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	hostname := fmt.Sprintf("%s%s", address, port)
+	httpClient.Timeout = time.Duration(cnf.UserAPI.Timeout) * time.Second
+
+	// Trace calls and propagate the trace context to the user api
+	httpClient.Transport = otelhttp.NewTransport(httpClient.Transport)
+
+	hostname := fmt.Sprintf("%s%s", cnf.UserAPIHostname, cnf.UserAPIPort)
 	transport := httptransport.NewWithClient(hostname, basepath, schemes, httpClient)
 
 	client := apiclient.New(transport, strfmt.Default)
 
-	return client
+	return client, nil
 }
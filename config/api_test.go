@@ -0,0 +1,78 @@
+package config_test
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/user-api-client/client/users"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAPIClient(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+
+	cnf := *config.Cnf
+	cnf.UserAPIHostname = u.Hostname()
+	cnf.UserAPIPort = ":" + u.Port()
+
+	deleteUser := func(cnf *config.Config) error {
+		client, err := config.NewAPIClient(cnf)
+		if err != nil {
+			return err
+		}
+		params := users.NewResonateUserDeleteUserParams().WithID("42")
+		_, err = client.Users.ResonateUserDeleteUser(params, nil)
+		return err
+	}
+
+	// The test server certificate is not trusted by default
+	assert.Error(t, deleteUser(&cnf))
+
+	cnf.UserAPI.CACertificate = string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	}))
+	assert.NoError(t, deleteUser(&cnf))
+
+	cnf.UserAPI.CACertificate = "not a certificate"
+	_, err = config.NewAPIClient(&cnf)
+	assert.Equal(t, config.ErrInvalidCACertificate, err)
+}
+
+func TestNewAPIClientTimeout(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Second)
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+
+	cnf := *config.Cnf
+	cnf.UserAPIHostname = u.Hostname()
+	cnf.UserAPIPort = ":" + u.Port()
+	cnf.UserAPI.InsecureSkipVerify = true
+	cnf.UserAPI.Timeout = 1
+
+	client, err := config.NewAPIClient(&cnf)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	start := time.Now()
+	_, err = client.Users.ResonateUserDeleteUser(users.NewResonateUserDeleteUserParams().WithID("42"), nil)
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 2*time.Second)
+}
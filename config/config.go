@@ -1,25 +1,41 @@
 package config
 
+import (
+	"regexp"
+	"strings"
+)
+
+// nonAlphanumeric is replaced when deriving client IDs from names
+var nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+// ClientConfig is a first party client, the fixtures create an oauth client
+// for each of them and users are not asked for their consent
 type ClientConfig struct {
 	ConnectUrl  string `json:"connectUrl"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
 }
 
+// ClientID derives the client ID of a configured client from its name,
+// e.g. "Upload Tool" becomes "upload_tool"
+func (c ClientConfig) ClientID() string {
+	return strings.Trim(nonAlphanumeric.ReplaceAllString(strings.ToLower(c.Name), "_"), "_")
+}
+
 type CSRFConfig struct {
-	Key     string
+	Key     string `secret:"true"`
 	Origins string
 }
 
 type MailgunConfig struct {
 	Sender string
-	Key    string
+	Key    string `secret:"true"`
 	Domain string
 }
 
 // DatabaseConfig stores database connection options
 type DatabaseConfig struct {
-	PSN          string
+	PSN          string `secret:"url"`
 	MaxIdleConns int
 	MaxOpenConns int
 }
@@ -29,11 +45,17 @@ type OauthConfig struct {
 	AccessTokenLifetime  int
 	RefreshTokenLifetime int
 	AuthCodeLifetime     int
+	// ImpersonationLifetime is the number of seconds an admin may act as
+	// a user for, impersonated sessions cannot be refreshed
+	ImpersonationLifetime int
+	// SigningKey is the PEM encoded RSA private key logout tokens are
+	// signed with, development mode generates one when it is empty
+	SigningKey string `secret:"true"`
 }
 
 // SessionConfig stores session configuration for the web app
 type SessionConfig struct {
-	Secret string
+	Secret string `secret:"true"`
 	Domain string
 	Path   string
 	// MaxAge=0 means no 'Max-Age' attribute specified.
@@ -48,6 +70,162 @@ type SessionConfig struct {
 	SameSite bool
 }
 
+// AccountDeletionConfig stores account deletion options
+type AccountDeletionConfig struct {
+	// GracePeriod is the number of days a member can cancel
+	// the deletion of their account before it gets purged
+	GracePeriod int
+	// Anonymize purged accounts instead of deleting them
+	Anonymize bool
+}
+
+// SchedulerConfig stores background jobs options
+type SchedulerConfig struct {
+	// Enabled turns the background jobs on
+	Enabled bool
+	// CleanupInterval is the number of seconds between two runs
+	// of the expired tokens cleanup jobs
+	CleanupInterval int
+	// PurgeInterval is the number of seconds between two runs
+	// of the deleted accounts purge job
+	PurgeInterval int
+	// BatchSize is the maximum number of rows deleted per query
+	BatchSize int
+}
+
+// HealthConfig stores readiness checks options
+type HealthConfig struct {
+	// Timeout is the number of seconds each component check may take
+	Timeout int
+	// CacheTTL is the number of seconds the result of checks against
+	// external services (config backend, user api) is reused
+	CacheTTL int
+	// CredentialsCacheTTL is the number of seconds the result of
+	// Mailgun and Stripe credentials checks is reused
+	CredentialsCacheTTL int
+}
+
+// UserAPIConfig stores options of the user api client
+type UserAPIConfig struct {
+	// Timeout is the number of seconds a call to the user api may take
+	Timeout int
+	// CACertificate is a PEM encoded certificate the certificate of the
+	// user api may be signed with, on top of the system roots
+	CACertificate string
+	// InsecureSkipVerify disables the verification of the certificate of
+	// the user api, it is refused outside development mode
+	InsecureSkipVerify bool
+	// SyncInterval is the number of seconds between two runs of the job
+	// sending account changes to the user api
+	SyncInterval int
+	// SyncMaxAttempts is the number of times an account change is sent
+	// before it is given up on
+	SyncMaxAttempts int
+}
+
+// WebhooksConfig stores options of the outbound webhooks notifying other
+// services of changes to users
+type WebhooksConfig struct {
+	// Timeout is the number of seconds a subscriber may take to answer
+	Timeout int
+	// DeliveryInterval is the number of seconds between two runs of the job
+	// delivering events
+	DeliveryInterval int
+	// MaxAttempts is the number of times an event is sent to a subscriber
+	// before it is given up on
+	MaxAttempts int
+}
+
+// SCIMConfig stores options of the SCIM provisioning API
+type SCIMConfig struct {
+	// MaxResults is the largest page of users or groups returned at once
+	MaxResults int
+	// BulkMaxOperations is the largest number of operations of a bulk request
+	BulkMaxOperations int
+	// BulkMaxPayloadSize is the largest bulk request body in bytes
+	BulkMaxPayloadSize int
+}
+
+// AuthBackendConfig selects how the users of some email domains
+// authenticate, users of other domains use their local password
+type AuthBackendConfig struct {
+	// Name identifies the backend in logs
+	Name string
+	// Type is "local", the password stored here, or "ldap"
+	Type string
+	// Domains lists the email domains of the users the backend authenticates
+	Domains []string
+	// Provision creates users unknown here on their first successful login
+	Provision bool
+	// Realm is the slug of the realm provisioned users join, the default
+	// realm if empty
+	Realm string
+	LDAP  LDAPConfig
+}
+
+// LDAPConfig stores options of a directory users bind to
+type LDAPConfig struct {
+	// URL of the directory, e.g. ldaps://ldap.example.com
+	URL string
+	// StartTLS upgrades ldap:// connections to TLS
+	StartTLS bool
+	// InsecureSkipVerify disables certificate verification, development only
+	InsecureSkipVerify bool
+	// Timeout of connections and requests in seconds
+	Timeout int
+	// BindDN and BindPassword are the account users are searched with,
+	// searches are anonymous if empty
+	BindDN       string
+	BindPassword string `secret:"true"`
+	// BaseDN is where users are searched
+	BaseDN string
+	// UserFilter finds a user by email address, %s is replaced by the
+	// escaped address. Defaults to (mail=%s).
+	UserFilter string
+	// Attributes maps directory attributes onto users
+	Attributes LDAPAttributesConfig
+}
+
+// LDAPAttributesConfig names the directory attributes users are filled
+// from, empty names use the defaults of inetOrgPerson
+type LDAPAttributesConfig struct {
+	FullName  string // cn
+	FirstName string // givenName
+	LastName  string // sn
+	Country   string // c
+}
+
+// TracingConfig stores OpenTelemetry tracing options
+type TracingConfig struct {
+	// Exporter is one of "none", "stdout" or "otlp"
+	Exporter string
+	// Endpoint is the host:port of the OTLP/HTTP collector
+	Endpoint string
+	// Insecure disables TLS when talking to the collector
+	Insecure bool
+	// ServiceName is reported as the service.name resource attribute
+	ServiceName string
+	// SampleRatio is the fraction of new traces which get sampled
+	SampleRatio float64
+}
+
+// MetricsConfig stores Prometheus metrics options
+type MetricsConfig struct {
+	// Port is the address metrics are served on, apart from the app so
+	// they are only reachable internally, e.g. ":9090". Metrics are not
+	// served when empty.
+	Port string
+}
+
+// LogConfig stores logging options, both can be changed on reload
+type LogConfig struct {
+	// Level is one of "debug", "info", "warning" or "error"
+	Level string
+	// Format is "json" or "console", it defaults to console
+	// in development mode and to json otherwise
+	Format string
+}
+
 type Product struct {
 	ID          string
 	PriceID     string
@@ -59,8 +237,8 @@ type Product struct {
 type StripeConfig struct {
 	Domain               string
 	Token                string
-	Secret               string
-	WebHookSecret        string
+	Secret               string `secret:"true"`
+	WebHookSecret        string `secret:"true"`
 	ListenerSubscription Product
 	SupporterShares      Product
 	ArtistMembership     Product
@@ -79,14 +257,24 @@ type Config struct {
 	Database            DatabaseConfig
 	Oauth               OauthConfig
 	Session             SessionConfig
+	AccountDeletion     AccountDeletionConfig
+	Scheduler           SchedulerConfig
+	Health              HealthConfig
+	Tracing             TracingConfig
+	Metrics             MetricsConfig
+	Log                 LogConfig
 	IsDevelopment       bool
 	Clients             []ClientConfig
 	Port                string
 	ApplicationURL      string
 	Origins             []string
-	EmailTokenSecretKey string
+	EmailTokenSecretKey string `secret:"true"`
 	UserAPIHostname     string
 	UserAPIPort         string
+	UserAPI             UserAPIConfig
+	Webhooks            WebhooksConfig
+	SCIM                SCIMConfig
+	AuthBackends        []AuthBackendConfig
 	StaticURL           string
 	AppURL              string
 	Stripe              StripeConfig
@@ -1,10 +1,12 @@
 package config
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"sync"
 
 	"github.com/hashicorp/consul/api"
 	"github.com/resonatecoop/id/log"
@@ -16,7 +18,11 @@ var (
 	consulConfigPath                            = "/config/go_oauth2_server.json"
 )
 
-type consulBackend struct{}
+// consulBackend keeps its client, loads and health checks share it
+type consulBackend struct {
+	mu  sync.Mutex
+	cli *api.Client
+}
 
 func (b *consulBackend) InitConfigBackend() {
 	// Overwrite default values with environment variables if they are set
@@ -40,7 +46,7 @@ func (b *consulBackend) InitConfigBackend() {
 //LoadConfig gets the JSON from Consul and unmarshals it to the config object
 func (b *consulBackend) LoadConfig() (*Config, error) {
 
-	cli, err := newConsulClient(consulEndpoint, consulCertFile, consulKeyFile, consulCaFile)
+	cli, err := b.client()
 	if err != nil {
 		return nil, err
 	}
@@ -57,8 +63,8 @@ func (b *consulBackend) LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("key not found: %s", consulConfigPath)
 	}
 
-	// Unmarshal the config JSON into the cnf object
-	newCnf := new(Config)
+	// Unmarshal the config JSON on top of the defaults
+	newCnf := defaultConfig()
 
 	if err := json.Unmarshal(resp.Value, newCnf); err != nil {
 		return nil, err
@@ -72,6 +78,41 @@ func (b *consulBackend) RefreshConfig(newCnf *Config) {
 	*Cnf = *newCnf
 }
 
+// CheckHealth makes sure the consul agent is reachable and has a leader
+func (b *consulBackend) CheckHealth(ctx context.Context) error {
+	cli, err := b.client()
+	if err != nil {
+		return err
+	}
+
+	leader, err := cli.Status().LeaderWithQueryOptions((&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	if leader == "" {
+		return fmt.Errorf("consul cluster has no leader")
+	}
+
+	return nil
+}
+
+// client returns the client of the backend, created on first use
+func (b *consulBackend) client() (*api.Client, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.cli == nil {
+		cli, err := newConsulClient(consulEndpoint, consulCertFile, consulKeyFile, consulCaFile)
+		if err != nil {
+			return nil, err
+		}
+		b.cli = cli
+	}
+
+	return b.cli, nil
+}
+
 func newConsulClient(theEndpoint, certFile, keyFile, caFile string) (*api.Client, error) {
 	// Log the consul endpoint for debugging purposes
 	log.INFO.Printf("CONSUL Endpoint: %s", theEndpoint)
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// OverlayEnv overwrites fields of cnf with environment variables named after
+// the upper cased field path, e.g. ID_DATABASE_PSN for cnf.Database.PSN or
+// ID_OAUTH_ACCESSTOKENLIFETIME for cnf.Oauth.AccessTokenLifetime. Lists of
+// strings are comma separated, other lists are given as JSON.
+func OverlayEnv(prefix string, cnf *Config) error {
+	return overlayEnvValue(prefix, reflect.ValueOf(cnf).Elem())
+}
+
+func overlayEnvValue(prefix string, v reflect.Value) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+
+		name := prefix + "_" + strings.ToUpper(field.Name)
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Struct {
+			if err := overlayEnvValue(name, fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		if err := setFromString(fv, value); err != nil {
+			return fmt.Errorf("invalid value for %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+func setFromString(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(value), "[") {
+			parts := strings.Split(value, ",")
+			for i := range parts {
+				parts[i] = strings.TrimSpace(parts[i])
+			}
+			v.Set(reflect.ValueOf(parts))
+			return nil
+		}
+		return json.Unmarshal([]byte(value), v.Addr().Interface())
+	default:
+		return json.Unmarshal([]byte(value), v.Addr().Interface())
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package config_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverlayEnv(t *testing.T) {
+	env := map[string]string{
+		"TEST_HOSTNAME":                       "id.example.com",
+		"TEST_ISDEVELOPMENT":                  "true",
+		"TEST_DATABASE_PSN":                   "postgres://localhost/id",
+		"TEST_OAUTH_ACCESSTOKENLIFETIME":      "7200",
+		"TEST_TRACING_SAMPLERATIO":            "0.5",
+		"TEST_ORIGINS":                        "a.example.com, b.example.com",
+		"TEST_CLIENTS":                        `[{"name": "Player", "connectUrl": "https://example.com"}]`,
+		"TEST_STRIPE_LABELMEMBERSHIP_PRICEID": "price_label",
+	}
+
+	for key, value := range env {
+		os.Setenv(key, value)
+		defer os.Unsetenv(key)
+	}
+
+	cnf := &config.Config{Hostname: "localhost", Port: ":8080"}
+
+	err := config.OverlayEnv("TEST", cnf)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "id.example.com", cnf.Hostname)
+	assert.Equal(t, ":8080", cnf.Port)
+	assert.True(t, cnf.IsDevelopment)
+	assert.Equal(t, "postgres://localhost/id", cnf.Database.PSN)
+	assert.Equal(t, 7200, cnf.Oauth.AccessTokenLifetime)
+	assert.Equal(t, 0.5, cnf.Tracing.SampleRatio)
+	assert.Equal(t, []string{"a.example.com", "b.example.com"}, cnf.Origins)
+	assert.Equal(t, []config.ClientConfig{{Name: "Player", ConnectUrl: "https://example.com"}}, cnf.Clients)
+	assert.Equal(t, "price_label", cnf.Stripe.LabelMembership.PriceID)
+}
+
+func TestOverlayEnvInvalidValue(t *testing.T) {
+	os.Setenv("TEST_OAUTH_AUTHCODELIFETIME", "soon")
+	defer os.Unsetenv("TEST_OAUTH_AUTHCODELIFETIME")
+
+	err := config.OverlayEnv("TEST", new(config.Config))
+	assert.EqualError(t, err, `invalid value for TEST_OAUTH_AUTHCODELIFETIME: strconv.ParseInt: parsing "soon": invalid syntax`)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 
 	//"github.com/etcd-io/etcdetcd/etcdserver/api/v3rpc/rpctypes"
 	//"github.com/etcd-io/etcdetcd/pkg/transport"
@@ -22,7 +23,11 @@ var (
 	etcdConfigPath                        = "/config/go_oauth2_server.json"
 )
 
-type etcdBackend struct{}
+// etcdBackend keeps its client, loads and health checks share it
+type etcdBackend struct {
+	mu  sync.Mutex
+	cli *clientv3.Client
+}
 
 func (b *etcdBackend) InitConfigBackend() {
 	// Overwrite default values with environment variables if they are set
@@ -46,11 +51,10 @@ func (b *etcdBackend) InitConfigBackend() {
 // LoadConfig gets the JSON from ETCD and unmarshals it to the config object
 func (b *etcdBackend) LoadConfig() (*Config, error) {
 
-	cli, err := newEtcdClient(etcdEndpoints, etcdCertFile, etcdKeyFile, etcdCaFile)
+	cli, err := b.client()
 	if err != nil {
 		return nil, err
 	}
-	defer cli.Close()
 
 	// Read from remote config the first time
 	ctx, cancel := context.WithTimeout(context.Background(), contextTimeout)
@@ -73,8 +77,8 @@ func (b *etcdBackend) LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("key not found: %s", etcdConfigPath)
 	}
 
-	// Unmarshal the config JSON into the cnf object
-	newCnf := new(Config)
+	// Unmarshal the config JSON on top of the defaults
+	newCnf := defaultConfig()
 
 	if err := json.Unmarshal([]byte(resp.Kvs[0].Value), newCnf); err != nil {
 		return nil, err
@@ -88,6 +92,41 @@ func (b *etcdBackend) RefreshConfig(newCnf *Config) {
 	*Cnf = *newCnf
 }
 
+// CheckHealth makes sure the etcd cluster is reachable and serves the config key
+func (b *etcdBackend) CheckHealth(ctx context.Context) error {
+	cli, err := b.client()
+	if err != nil {
+		return err
+	}
+
+	resp, err := cli.Get(ctx, etcdConfigPath, clientv3.WithCountOnly())
+	if err != nil {
+		return err
+	}
+
+	if resp.Count == 0 {
+		return fmt.Errorf("key not found: %s", etcdConfigPath)
+	}
+
+	return nil
+}
+
+// client returns the client of the backend, connecting on first use
+func (b *etcdBackend) client() (*clientv3.Client, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.cli == nil {
+		cli, err := newEtcdClient(etcdEndpoints, etcdCertFile, etcdKeyFile, etcdCaFile)
+		if err != nil {
+			return nil, err
+		}
+		b.cli = cli
+	}
+
+	return b.cli, nil
+}
+
 func newEtcdClient(theEndpoints, certFile, keyFile, caFile string) (*clientv3.Client, error) {
 	// Log the etcd endpoint for debugging purposes
 	log.INFO.Printf("ETCD Endpoints: %s", theEndpoints)
@@ -0,0 +1,4 @@
+package config
+
+// DefaultConfig exposes the base remote configs are unmarshalled onto
+var DefaultConfig = defaultConfig
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"context"
+	"errors"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/resonatecoop/id/log"
@@ -9,9 +12,13 @@ import (
 
 var (
 	configLoaded   bool
+	currentBackend Backend
 	dialTimeout    = 5 * time.Second
 	contextTimeout = 5 * time.Second
 	reloadDelay    = time.Second * 10
+
+	// ErrBackendNotInitialized ...
+	ErrBackendNotInitialized = errors.New("Config backend is not initialized")
 )
 
 // Cnf ...
@@ -33,9 +40,10 @@ var Cnf = &Config{
 		MaxOpenConns: 5,
 	},
 	Oauth: OauthConfig{
-		AccessTokenLifetime:  3600,    // 1 hour
-		RefreshTokenLifetime: 1209600, // 14 days
-		AuthCodeLifetime:     3600,    // 1 hour
+		AccessTokenLifetime:   3600,    // 1 hour
+		RefreshTokenLifetime:  1209600, // 14 days
+		AuthCodeLifetime:      3600,    // 1 hour
+		ImpersonationLifetime: 1800,    // 30 minutes
 	},
 	Session: SessionConfig{
 		Secret:   "test_secret",
@@ -43,6 +51,49 @@ var Cnf = &Config{
 		MaxAge:   86400 * 7, // 7 days
 		HTTPOnly: true,
 	},
+	AccountDeletion: AccountDeletionConfig{
+		GracePeriod: 30, // 30 days
+		Anonymize:   false,
+	},
+	Scheduler: SchedulerConfig{
+		Enabled:         true,
+		CleanupInterval: 600,  // 10 minutes
+		PurgeInterval:   3600, // 1 hour
+		BatchSize:       1000,
+	},
+	Health: HealthConfig{
+		Timeout:             2,
+		CacheTTL:            10,  // 10 seconds
+		CredentialsCacheTTL: 300, // 5 minutes
+	},
+	UserAPI: UserAPIConfig{
+		Timeout:         10,
+		SyncInterval:    30, // 30 seconds
+		SyncMaxAttempts: 20,
+	},
+	Webhooks: WebhooksConfig{
+		Timeout:          10,
+		DeliveryInterval: 15, // 15 seconds
+		MaxAttempts:      12,
+	},
+	SCIM: SCIMConfig{
+		MaxResults:         100,
+		BulkMaxOperations:  100,
+		BulkMaxPayloadSize: 1048576, // 1 MiB
+	},
+	Tracing: TracingConfig{
+		Exporter:    "none",
+		Endpoint:    "localhost:4318",
+		Insecure:    true,
+		ServiceName: "id",
+		SampleRatio: 1,
+	},
+	Metrics: MetricsConfig{
+		Port: ":9090",
+	},
+	Log: LogConfig{
+		Level: "info",
+	},
 	Clients: []ClientConfig{
 		{
 			ConnectUrl:  "https://upload.resonate.is/api/user/connect/resonate",
@@ -103,6 +154,23 @@ var Cnf = &Config{
 	},
 }
 
+var (
+	// defaultCnf keeps the built-in defaults, Cnf gets overwritten on load
+	defaultCnf = *Cnf
+)
+
+// defaultConfig returns a copy of the built-in defaults, backends unmarshal
+// on top of it so fields missing from the remote config keep their default.
+// Development mode must be asked for, loaded configs are production ones
+// unless they set IsDevelopment.
+func defaultConfig() *Config {
+	cnf := defaultCnf
+	cnf.Clients = append([]ClientConfig(nil), defaultCnf.Clients...)
+	cnf.Origins = append([]string(nil), defaultCnf.Origins...)
+	cnf.IsDevelopment = false
+	return &cnf
+}
+
 // NewConfig loads configuration from etcd and returns *Config struct
 // It also starts a goroutine in the background to keep config up-to-date
 func NewConfig(mustLoadOnce bool, keepReloading bool, backendType string) *Config {
@@ -117,6 +185,8 @@ func NewConfig(mustLoadOnce bool, keepReloading bool, backendType string) *Confi
 		backend = new(etcdBackend)
 	case "consul":
 		backend = new(consulBackend)
+	case "file":
+		backend = new(fileBackend)
 	default:
 		log.FATAL.Printf("%s is not a valid backend", backendType)
 		os.Exit(1)
@@ -124,6 +194,8 @@ func NewConfig(mustLoadOnce bool, keepReloading bool, backendType string) *Confi
 
 	backend.InitConfigBackend()
 
+	currentBackend = backend
+
 	// If the config must be loaded once successfully
 	if mustLoadOnce && !configLoaded {
 		// Read from remote config the first time
@@ -134,6 +206,18 @@ func NewConfig(mustLoadOnce bool, keepReloading bool, backendType string) *Confi
 			os.Exit(1)
 		}
 
+		// Replace secret references with the actual secrets
+		if err := resolveSecrets(newCnf); err != nil {
+			log.FATAL.Print(err)
+			os.Exit(1)
+		}
+
+		// Refuse to start with an invalid config
+		if err := newCnf.Validate(); err != nil {
+			log.FATAL.Print(err)
+			os.Exit(1)
+		}
+
 		// Refresh the config
 		backend.RefreshConfig(newCnf)
 
@@ -143,28 +227,86 @@ func NewConfig(mustLoadOnce bool, keepReloading bool, backendType string) *Confi
 	}
 
 	if keepReloading {
+		// the watcher and the polling loop may reload concurrently
+		var reloadMu sync.Mutex
+
+		reload := func() {
+			reloadMu.Lock()
+			defer reloadMu.Unlock()
+
+			// Attempt to reload the config
+			newCnf, err := backend.LoadConfig()
+			if err != nil {
+				log.ERROR.Print(err)
+				return
+			}
+
+			// Secrets are resolved again so rotated secrets are picked up
+			if err := resolveSecrets(newCnf); err != nil {
+				log.ERROR.Print(err)
+				return
+			}
+
+			// Keep the current config if the new one is invalid
+			if err := newCnf.Validate(); err != nil {
+				log.ERROR.Print(err)
+				return
+			}
+
+			oldCnf := *Cnf
+
+			// Refresh the config
+			backend.RefreshConfig(newCnf)
+
+			// Let services react to the fields they depend on
+			notifySubscribers(&oldCnf, Cnf)
+
+			// Set configLoaded to true
+			configLoaded = true
+			log.INFO.Print("Successfully reloaded config")
+		}
+
+		// Backends notifying about changes are reloaded right away, they
+		// are still polled below so secret references get refreshed
+		if watcher, ok := backend.(Watcher); ok {
+			if err := watcher.Watch(reload); err != nil {
+				log.ERROR.Printf("Failed to watch config: %v", err)
+			}
+		}
+
 		// Open a goroutine to watch remote changes forever
 		go func() {
 			for {
 				// Delay after each request
 				<-time.After(reloadDelay)
 
-				// Attempt to reload the config
-				newCnf, err := backend.LoadConfig()
-				if err != nil {
-					log.ERROR.Print(err)
-					continue
-				}
-
-				// Refresh the config
-				backend.RefreshConfig(newCnf)
-
-				// Set configLoaded to true
-				configLoaded = true
-				log.INFO.Print("Successfully reloaded config")
+				reload()
 			}
 		}()
 	}
 
 	return Cnf
 }
+
+// CheckBackendHealth checks connectivity of the config backend in use,
+// backends not implementing HealthChecker are assumed to be healthy
+func CheckBackendHealth(ctx context.Context) error {
+	if currentBackend == nil {
+		return ErrBackendNotInitialized
+	}
+
+	checker, ok := currentBackend.(HealthChecker)
+	if !ok {
+		return nil
+	}
+
+	return checker.CheckHealth(ctx)
+}
+
+// resolveSecrets resolves secret references with a timeout
+func resolveSecrets(cnf *Config) error {
+	ctx, cancel := context.WithTimeout(context.Background(), contextTimeout)
+	defer cancel()
+
+	return ResolveSecrets(ctx, cnf)
+}
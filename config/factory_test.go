@@ -1,6 +1,7 @@
 package config_test
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/resonatecoop/id/config"
@@ -31,3 +32,25 @@ func TestConfigReloading(t *testing.T) {
 	foo.RefreshConfig(newCnf)
 	assert.Equal(t, 9999, foo.cnf.Oauth.AuthCodeLifetime)
 }
+
+func TestRemoteConfigDefaultsToProduction(t *testing.T) {
+	// Built-in defaults are development ones
+	assert.True(t, config.Cnf.IsDevelopment)
+
+	cnf := config.DefaultConfig()
+	err := json.Unmarshal([]byte(`{"Hostname": "id.example.com"}`), cnf)
+	assert.NoError(t, err)
+
+	// Configs leaving the key out are production ones
+	assert.False(t, cnf.IsDevelopment)
+
+	err = cnf.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Session.Secret: must not be the default value outside development mode")
+		assert.Contains(t, err.Error(), "EmailTokenSecretKey: must not be the default value outside development mode")
+	}
+
+	err = json.Unmarshal([]byte(`{"IsDevelopment": true}`), cnf)
+	assert.NoError(t, err)
+	assert.NoError(t, cnf.Validate())
+}
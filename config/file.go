@@ -0,0 +1,146 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/fsnotify/fsnotify"
+	"github.com/ghodss/yaml"
+	"github.com/resonatecoop/id/log"
+)
+
+var (
+	configFile      = "config.json"
+	configEnvPrefix = "ID"
+	// reloading waits for writes to settle before reading the file again
+	fileReloadDebounce = 100 * time.Millisecond
+)
+
+type fileBackend struct{}
+
+func (b *fileBackend) InitConfigBackend() {
+	// Overwrite default values with environment variables if they are set
+	if os.Getenv("CONFIG_FILE") != "" {
+		configFile = os.Getenv("CONFIG_FILE")
+	}
+	if os.Getenv("CONFIG_ENV_PREFIX") != "" {
+		configEnvPrefix = os.Getenv("CONFIG_ENV_PREFIX")
+	}
+}
+
+// LoadConfig reads the JSON, YAML or TOML config file, then overlays
+// environment variables such as ID_DATABASE_PSN on top of it
+func (b *fileBackend) LoadConfig() (*Config, error) {
+	data, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = toJSON(configFile, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the config JSON on top of the defaults
+	newCnf := defaultConfig()
+
+	if err := json.Unmarshal(data, newCnf); err != nil {
+		return nil, err
+	}
+
+	if err := OverlayEnv(configEnvPrefix, newCnf); err != nil {
+		return nil, err
+	}
+
+	return newCnf, nil
+}
+
+// RefreshConfig sets config through the pointer so config actually gets refreshed
+func (b *fileBackend) RefreshConfig(newCnf *Config) {
+	*Cnf = *newCnf
+}
+
+// CheckHealth makes sure the config file is still readable
+func (b *fileBackend) CheckHealth(ctx context.Context) error {
+	_, err := os.Stat(configFile)
+	return err
+}
+
+// Watch reloads the config whenever the file changes. The parent directory
+// is watched so editors and orchestrators replacing the file are noticed.
+func (b *fileBackend) Watch(reload func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	path, err := filepath.Abs(configFile)
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var timer *time.Timer
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				// kubernetes swaps the ..data symlink when a configmap changes
+				name := filepath.Base(event.Name)
+				if filepath.Clean(event.Name) != path && !strings.HasPrefix(name, "..") {
+					continue
+				}
+
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(fileReloadDebounce, reload)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.ERROR.Print(err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// toJSON converts YAML and TOML documents to JSON so every format
+// maps to the same field names as config.sample.json
+func toJSON(filename string, data []byte) ([]byte, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return data, nil
+	case ".yaml", ".yml":
+		return yaml.YAMLToJSON(data)
+	case ".toml":
+		var doc map[string]interface{}
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		return json.Marshal(doc)
+	default:
+		return nil, fmt.Errorf("unsupported config file format: %s", filename)
+	}
+}
@@ -0,0 +1,75 @@
+package config_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "id-config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.toml")
+
+	err = ioutil.WriteFile(path, []byte(`
+Hostname = "id.example.com"
+IsDevelopment = true
+
+[Oauth]
+AccessTokenLifetime = 3600
+`), 0600)
+	assert.NoError(t, err)
+
+	os.Setenv("CONFIG_FILE", path)
+	os.Setenv("ID_DATABASE_PSN", "postgres://localhost/id")
+	defer os.Unsetenv("CONFIG_FILE")
+	defer os.Unsetenv("ID_DATABASE_PSN")
+
+	cnf := config.NewConfig(true, true, "file")
+
+	assert.Equal(t, "id.example.com", cnf.Hostname)
+	assert.Equal(t, 3600, cnf.Oauth.AccessTokenLifetime)
+	assert.Equal(t, "postgres://localhost/id", cnf.Database.PSN)
+	assert.NoError(t, config.CheckBackendHealth(context.Background()))
+
+	hostnames := make(chan string, 1)
+	unsubscribe, err := config.Subscribe("Hostname", func(cnf *config.Config) {
+		hostnames <- cnf.Hostname
+	})
+	assert.NoError(t, err)
+	defer unsubscribe()
+
+	_, err = config.Subscribe("Session", func(cnf *config.Config) {
+		t.Error("Session did not change")
+	})
+	assert.NoError(t, err)
+
+	// Changes to the file are picked up without restarting
+	err = ioutil.WriteFile(path, []byte(`
+Hostname = "id.example.org"
+IsDevelopment = true
+
+[Oauth]
+AccessTokenLifetime = 60
+`), 0600)
+	assert.NoError(t, err)
+
+	select {
+	case hostname := <-hostnames:
+		assert.Equal(t, "id.example.org", hostname)
+	case <-time.After(5 * time.Second):
+		t.Fatal("config was not reloaded")
+	}
+
+	assert.Equal(t, "id.example.org", cnf.Hostname)
+	assert.Equal(t, 60, cnf.Oauth.AccessTokenLifetime)
+	assert.Equal(t, "postgres://localhost/id", cnf.Database.PSN)
+}
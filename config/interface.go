@@ -1,5 +1,9 @@
 package config
 
+import (
+	"context"
+)
+
 // Backend defines a configuration backend, implement this interface
 // to support additional backends
 type Backend interface {
@@ -7,3 +11,15 @@ type Backend interface {
 	RefreshConfig(newCnf *Config)
 	InitConfigBackend()
 }
+
+// HealthChecker is implemented by backends which can report
+// whether their remote store is reachable
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// Watcher is implemented by backends which notify about changes,
+// they call reload on change instead of being polled
+type Watcher interface {
+	Watch(reload func()) error
+}
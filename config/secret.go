@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+)
+
+const (
+	// redacted replaces secrets in config dumps
+	redacted = "REDACTED"
+
+	secretTag = "secret"
+	// secretTagURL marks URLs where only the password is secret
+	secretTagURL = "url"
+)
+
+// secretField is a string field tagged with `secret`
+type secretField struct {
+	path  string
+	kind  string
+	value reflect.Value
+}
+
+// secretFields returns every field tagged as secret, including the fields of
+// list items such as AuthBackends[0].LDAP.BindPassword
+func secretFields(cnf *Config) []secretField {
+	var fields []secretField
+
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue // unexported
+			}
+
+			path := field.Name
+			if prefix != "" {
+				path = prefix + "." + field.Name
+			}
+
+			fv := v.Field(i)
+
+			if fv.Kind() == reflect.Struct {
+				walk(path, fv)
+				continue
+			}
+
+			if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Struct {
+				for j := 0; j < fv.Len(); j++ {
+					walk(fmt.Sprintf("%s[%d]", path, j), fv.Index(j))
+				}
+				continue
+			}
+
+			if kind, ok := field.Tag.Lookup(secretTag); ok && fv.Kind() == reflect.String {
+				fields = append(fields, secretField{path: path, kind: kind, value: fv})
+			}
+		}
+	}
+
+	walk("", reflect.ValueOf(cnf).Elem())
+
+	return fields
+}
+
+// Redacted returns a copy of the config with secrets replaced,
+// safe to print or log
+func (c *Config) Redacted() *Config {
+	copied := *c
+
+	// List items are shared with c, they are copied so the original config
+	// is left untouched
+	if len(c.Clients) > 0 {
+		copied.Clients = append([]ClientConfig(nil), c.Clients...)
+	}
+	if len(c.AuthBackends) > 0 {
+		copied.AuthBackends = append([]AuthBackendConfig(nil), c.AuthBackends...)
+	}
+
+	for _, field := range secretFields(&copied) {
+		value := field.value.String()
+		if value == "" {
+			continue
+		}
+
+		if field.kind == secretTagURL {
+			field.value.SetString(redactURL(value))
+			continue
+		}
+
+		field.value.SetString(redacted)
+	}
+
+	return &copied
+}
+
+// redactURL hides the password of a URL, or the whole value if it
+// cannot be parsed
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return redacted
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+
+	return u.String()
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// SecretResolver resolves secret references such as "vault:secret/id#stripe"
+// or "file:/run/secrets/stripe" found in secret config fields
+type SecretResolver interface {
+	// Resolve returns the secret designated by ref, the part of
+	// the reference following the scheme
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+var (
+	// ErrEmptySecret ...
+	ErrEmptySecret = errors.New("Resolved secret is empty")
+
+	secretResolversMu sync.RWMutex
+	secretResolvers   = map[string]SecretResolver{
+		"file":  new(fileResolver),
+		"vault": NewVaultResolver("", ""),
+	}
+)
+
+// RegisterSecretResolver sets the resolver used for references with the
+// given scheme, replacing any existing one
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	secretResolversMu.Lock()
+	defer secretResolversMu.Unlock()
+
+	secretResolvers[scheme] = resolver
+}
+
+// ResolveSecrets replaces secret references in fields tagged as secret with
+// the value they point to. Values without a known scheme are left untouched,
+// so plain secrets and URLs such as Database.PSN keep working.
+func ResolveSecrets(ctx context.Context, cnf *Config) error {
+	for _, field := range secretFields(cnf) {
+		value := field.value.String()
+
+		resolver, ref, ok := lookupSecretResolver(value)
+		if !ok {
+			continue
+		}
+
+		secret, err := resolver.Resolve(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %v", field.path, err)
+		}
+
+		if secret == "" {
+			return fmt.Errorf("failed to resolve %s: %v", field.path, ErrEmptySecret)
+		}
+
+		field.value.SetString(secret)
+	}
+
+	return nil
+}
+
+// lookupSecretResolver splits a reference into the resolver and the
+// reference passed to it
+func lookupSecretResolver(value string) (SecretResolver, string, bool) {
+	i := strings.Index(value, ":")
+	if i <= 0 {
+		return nil, "", false
+	}
+
+	secretResolversMu.RLock()
+	defer secretResolversMu.RUnlock()
+
+	resolver, ok := secretResolvers[value[:i]]
+	if !ok {
+		return nil, "", false
+	}
+
+	return resolver, value[i+1:], true
+}
+
+// fileResolver reads secrets from mounted files, e.g. docker or
+// kubernetes secrets, ignoring the trailing newline
+type fileResolver struct{}
+
+// Resolve as per the SecretResolver interface
+func (r *fileResolver) Resolve(ctx context.Context, path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,135 @@
+package config_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeResolver resolves references from a map
+type fakeResolver map[string]string
+
+func (r fakeResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	secret, ok := r[ref]
+	if !ok {
+		return "", errors.New("secret not found")
+	}
+	return secret, nil
+}
+
+func TestResolveSecrets(t *testing.T) {
+	config.RegisterSecretResolver("fake", fakeResolver{
+		"stripe":  "sk_live_xxx",
+		"session": "session secret",
+	})
+
+	cnf := &config.Config{
+		Hostname: "fake:stripe", // not a secret field
+		Session:  config.SessionConfig{Secret: "fake:session"},
+		Stripe:   config.StripeConfig{Secret: "fake:stripe", WebHookSecret: "whsec_plain"},
+		Database: config.DatabaseConfig{PSN: "postgres://id:password@db:5432/id"},
+	}
+
+	err := config.ResolveSecrets(context.Background(), cnf)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "fake:stripe", cnf.Hostname)
+	assert.Equal(t, "session secret", cnf.Session.Secret)
+	assert.Equal(t, "sk_live_xxx", cnf.Stripe.Secret)
+	assert.Equal(t, "whsec_plain", cnf.Stripe.WebHookSecret)
+	assert.Equal(t, "postgres://id:password@db:5432/id", cnf.Database.PSN)
+
+	// Items of lists are resolved as well
+	cnf.AuthBackends = []config.AuthBackendConfig{
+		{Name: "label", Type: "ldap", LDAP: config.LDAPConfig{BindDN: "cn=id", BindPassword: "fake:ldap"}},
+	}
+
+	err = config.ResolveSecrets(context.Background(), cnf)
+	assert.EqualError(t, err, "failed to resolve AuthBackends[0].LDAP.BindPassword: secret not found")
+
+	config.RegisterSecretResolver("fake", fakeResolver{
+		"stripe":  "sk_live_xxx",
+		"session": "session secret",
+		"ldap":    "bind secret",
+	})
+
+	err = config.ResolveSecrets(context.Background(), cnf)
+	assert.NoError(t, err)
+	assert.Equal(t, "bind secret", cnf.AuthBackends[0].LDAP.BindPassword)
+
+	cnf.Mailgun.Key = "fake:mailgun"
+
+	err = config.ResolveSecrets(context.Background(), cnf)
+	assert.EqualError(t, err, "failed to resolve Mailgun.Key: secret not found")
+}
+
+func TestResolveFileSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "id-secrets")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "csrf_key")
+	err = ioutil.WriteFile(path, []byte("0123456789abcdef0123456789abcdef\n"), 0600)
+	assert.NoError(t, err)
+
+	cnf := &config.Config{CSRF: config.CSRFConfig{Key: "file:" + path}}
+
+	err = config.ResolveSecrets(context.Background(), cnf)
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef", cnf.CSRF.Key)
+}
+
+func TestResolveVaultSecrets(t *testing.T) {
+	// Fake vault server with a KV version 1 and a KV version 2 mount
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors": ["permission denied"]}`))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/kv/id":
+			w.Write([]byte(`{"data": {"mailgun": "key-live"}}`))
+		case "/v1/secret/data/id":
+			w.Write([]byte(`{"data": {"data": {"stripe": "sk_live_xxx"}, "metadata": {"version": 3}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors": []}`))
+		}
+	}))
+	defer vault.Close()
+
+	config.RegisterSecretResolver("vault", config.NewVaultResolver(vault.URL, "root"))
+	defer config.RegisterSecretResolver("vault", config.NewVaultResolver("", ""))
+
+	cnf := &config.Config{
+		Mailgun: config.MailgunConfig{Key: "vault:kv/id#mailgun"},
+		Stripe:  config.StripeConfig{Secret: "vault:secret/data/id#stripe"},
+	}
+
+	err := config.ResolveSecrets(context.Background(), cnf)
+	assert.NoError(t, err)
+	assert.Equal(t, "key-live", cnf.Mailgun.Key)
+	assert.Equal(t, "sk_live_xxx", cnf.Stripe.Secret)
+
+	cnf.Session.Secret = "vault:secret/data/id#session"
+	err = config.ResolveSecrets(context.Background(), cnf)
+	assert.EqualError(t, err, "failed to resolve Session.Secret: key session not found in vault secret secret/data/id")
+
+	cnf.Session.Secret = "vault:secret/data/missing#session"
+	err = config.ResolveSecrets(context.Background(), cnf)
+	assert.EqualError(t, err, "failed to resolve Session.Secret: vault returned status 404 for secret/data/missing: ")
+
+	cnf.Session.Secret = "vault:secret/data/id"
+	err = config.ResolveSecrets(context.Background(), cnf)
+	assert.EqualError(t, err, "failed to resolve Session.Secret: "+config.ErrVaultInvalidReference.Error())
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// subscription calls fn when the value at path changes on reload
+type subscription struct {
+	id   int
+	path string
+	fn   func(cnf *Config)
+}
+
+var (
+	subscriptionsMu sync.Mutex
+	subscriptions   []*subscription
+	subscriptionID  int
+)
+
+// Subscribe registers fn to be called with the new config whenever the
+// field at path changes on reload, e.g. "Session" or "Stripe.Secret".
+// It returns a function removing the subscription.
+func Subscribe(path string, fn func(cnf *Config)) (func(), error) {
+	if _, err := lookupField(Cnf, path); err != nil {
+		return nil, err
+	}
+
+	subscriptionsMu.Lock()
+	defer subscriptionsMu.Unlock()
+
+	subscriptionID++
+	sub := &subscription{id: subscriptionID, path: path, fn: fn}
+	subscriptions = append(subscriptions, sub)
+
+	unsubscribe := func() {
+		subscriptionsMu.Lock()
+		defer subscriptionsMu.Unlock()
+
+		for i, s := range subscriptions {
+			if s.id == sub.id {
+				subscriptions = append(subscriptions[:i], subscriptions[i+1:]...)
+				return
+			}
+		}
+	}
+
+	return unsubscribe, nil
+}
+
+// notifySubscribers calls subscribers whose field differs between configs
+func notifySubscribers(oldCnf, newCnf *Config) {
+	subscriptionsMu.Lock()
+	subs := make([]*subscription, len(subscriptions))
+	copy(subs, subscriptions)
+	subscriptionsMu.Unlock()
+
+	for _, sub := range subs {
+		oldValue, err := lookupField(oldCnf, sub.path)
+		if err != nil {
+			continue
+		}
+
+		newValue, err := lookupField(newCnf, sub.path)
+		if err != nil {
+			continue
+		}
+
+		if !reflect.DeepEqual(oldValue.Interface(), newValue.Interface()) {
+			sub.fn(newCnf)
+		}
+	}
+}
+
+// lookupField resolves a dotted field path such as "Stripe.Secret"
+func lookupField(cnf *Config, path string) (reflect.Value, error) {
+	v := reflect.ValueOf(cnf).Elem()
+
+	for _, name := range strings.Split(path, ".") {
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("unknown config field: %s", path)
+		}
+
+		v = v.FieldByName(name)
+		if !v.IsValid() {
+			return reflect.Value{}, fmt.Errorf("unknown config field: %s", path)
+		}
+	}
+
+	return v, nil
+}
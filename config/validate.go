@@ -0,0 +1,181 @@
+package config
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/resonatecoop/id/util"
+)
+
+// ValidationError describes an invalid config field
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors lists every invalid field of a config
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return "invalid config: " + strings.Join(messages, "; ")
+}
+
+// Validate checks the config is usable. Outside of development mode secrets
+// must be set and must differ from the built-in defaults.
+func (c *Config) Validate() error {
+	var errs ValidationErrors
+
+	check := func(ok bool, field, message string) {
+		if !ok {
+			errs = append(errs, ValidationError{Field: field, Message: message})
+		}
+	}
+
+	check(c.Hostname != "", "Hostname", "must not be empty")
+	check(strings.HasPrefix(c.Port, ":"), "Port", `must look like ":8080"`)
+	check(c.Database.PSN != "", "Database.PSN", "must not be empty")
+	check(c.Oauth.AccessTokenLifetime > 0, "Oauth.AccessTokenLifetime", "must be positive")
+	check(c.Oauth.RefreshTokenLifetime > 0, "Oauth.RefreshTokenLifetime", "must be positive")
+	check(c.Oauth.AuthCodeLifetime > 0, "Oauth.AuthCodeLifetime", "must be positive")
+	check(c.Oauth.ImpersonationLifetime > 0, "Oauth.ImpersonationLifetime", "must be positive")
+	if c.Oauth.SigningKey != "" {
+		_, err := util.ParseRSAPrivateKey(c.Oauth.SigningKey)
+		check(err == nil, "Oauth.SigningKey", "must be a PEM encoded RSA private key")
+	}
+	check(c.Session.Path != "", "Session.Path", "must not be empty")
+	check(c.AccountDeletion.GracePeriod > 0, "AccountDeletion.GracePeriod", "must be positive")
+
+	if c.Scheduler.Enabled {
+		check(c.Scheduler.CleanupInterval > 0, "Scheduler.CleanupInterval", "must be positive")
+		check(c.Scheduler.PurgeInterval > 0, "Scheduler.PurgeInterval", "must be positive")
+	}
+	// the purge commands batch their deletes too
+	check(c.Scheduler.BatchSize > 0, "Scheduler.BatchSize", "must be positive")
+
+	check(c.Health.Timeout > 0, "Health.Timeout", "must be positive")
+	check(c.Health.CacheTTL >= 0, "Health.CacheTTL", "must not be negative")
+	check(c.Health.CredentialsCacheTTL >= 0, "Health.CredentialsCacheTTL", "must not be negative")
+
+	check(c.UserAPI.Timeout > 0, "UserAPI.Timeout", "must be positive")
+	if c.UserAPI.CACertificate != "" {
+		check(x509.NewCertPool().AppendCertsFromPEM([]byte(c.UserAPI.CACertificate)), "UserAPI.CACertificate", "must be a PEM encoded certificate")
+	}
+	check(c.Webhooks.Timeout > 0, "Webhooks.Timeout", "must be positive")
+	check(c.SCIM.MaxResults > 0, "SCIM.MaxResults", "must be positive")
+	check(c.SCIM.BulkMaxOperations > 0, "SCIM.BulkMaxOperations", "must be positive")
+	check(c.SCIM.BulkMaxPayloadSize > 0, "SCIM.BulkMaxPayloadSize", "must be positive")
+	if c.Scheduler.Enabled {
+		check(c.UserAPI.SyncInterval > 0, "UserAPI.SyncInterval", "must be positive")
+		check(c.UserAPI.SyncMaxAttempts > 0, "UserAPI.SyncMaxAttempts", "must be positive")
+		check(c.Webhooks.DeliveryInterval > 0, "Webhooks.DeliveryInterval", "must be positive")
+		check(c.Webhooks.MaxAttempts > 0, "Webhooks.MaxAttempts", "must be positive")
+	}
+
+	domains := map[string]bool{}
+	for i, backend := range c.AuthBackends {
+		field := fmt.Sprintf("AuthBackends[%d]", i)
+
+		check(backend.Name != "", field+".Name", "must not be empty")
+		check(len(backend.Domains) > 0, field+".Domains", "must not be empty")
+		for _, domain := range backend.Domains {
+			domain = strings.ToLower(domain)
+			check(!domains[domain], field+".Domains", domain+" is handled by another backend")
+			domains[domain] = true
+		}
+
+		switch backend.Type {
+		case "local":
+			check(!backend.Provision, field+".Provision", "must be false with the local backend")
+		case "ldap":
+			u, err := url.Parse(backend.LDAP.URL)
+			check(err == nil && (u.Scheme == "ldap" || u.Scheme == "ldaps") && u.Host != "", field+".LDAP.URL", "must be an ldap:// or ldaps:// URL")
+			check(backend.LDAP.BaseDN != "", field+".LDAP.BaseDN", "must not be empty")
+			check(backend.LDAP.UserFilter == "" || strings.Count(backend.LDAP.UserFilter, "%s") == 1, field+".LDAP.UserFilter", `must contain "%s" once`)
+			check(backend.LDAP.Timeout >= 0, field+".LDAP.Timeout", "must not be negative")
+			check(c.IsDevelopment || !backend.LDAP.InsecureSkipVerify, field+".LDAP.InsecureSkipVerify", "must be false outside development mode")
+			// passwords are sent to the directory in the clear otherwise
+			check(c.IsDevelopment || err != nil || u.Scheme == "ldaps" || backend.LDAP.StartTLS, field+".LDAP.StartTLS", "must be true with ldap:// URLs outside development mode")
+		default:
+			check(false, field+".Type", `must be "local" or "ldap"`)
+		}
+	}
+
+	switch c.Tracing.Exporter {
+	case "", "none", "stdout":
+	case "otlp":
+		check(c.Tracing.Endpoint != "", "Tracing.Endpoint", "must not be empty with the otlp exporter")
+	default:
+		check(false, "Tracing.Exporter", `must be one of "none", "stdout" or "otlp"`)
+	}
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "Tracing.SampleRatio", "must be between 0 and 1")
+
+	if c.Metrics.Port != "" {
+		_, _, err := net.SplitHostPort(c.Metrics.Port)
+		check(err == nil, "Metrics.Port", `must look like ":9090"`)
+		check(c.Metrics.Port != c.Port, "Metrics.Port", "must differ from Port")
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "", "debug", "info", "warn", "warning", "error":
+	default:
+		check(false, "Log.Level", `must be one of "debug", "info", "warning" or "error"`)
+	}
+	switch c.Log.Format {
+	case "", "json", "console":
+	default:
+		check(false, "Log.Format", `must be "json" or "console"`)
+	}
+
+	for _, field := range []struct {
+		name  string
+		value string
+	}{
+		{"ApplicationURL", c.ApplicationURL},
+		{"StaticURL", c.StaticURL},
+		{"AppURL", c.AppURL},
+	} {
+		if field.value == "" {
+			continue
+		}
+		u, err := url.Parse(field.value)
+		check(err == nil && u.Scheme != "" && u.Host != "", field.name, "must be an absolute URL")
+	}
+
+	if !c.IsDevelopment {
+		defaults := make(map[string]string)
+		for _, field := range secretFields(&defaultCnf) {
+			defaults[field.path] = field.value.String()
+		}
+
+		for _, field := range secretFields(c) {
+			value := field.value.String()
+
+			// secrets of list items, e.g. bind passwords of directories
+			// searched anonymously, are optional
+			if !strings.Contains(field.path, "[") {
+				check(value != "", field.path, "must be set outside development mode")
+			}
+			check(value == "" || value != defaults[field.path], field.path, "must not be the default value outside development mode")
+		}
+
+		check(c.CSRF.Key == "" || len(c.CSRF.Key) == 32, "CSRF.Key", "must be 32 bytes long")
+		check(!c.UserAPI.InsecureSkipVerify, "UserAPI.InsecureSkipVerify", "must be false outside development mode")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
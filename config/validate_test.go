@@ -0,0 +1,222 @@
+package config_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDefaults(t *testing.T) {
+	cnf := *config.Cnf
+	cnf.IsDevelopment = true
+
+	assert.NoError(t, cnf.Validate())
+}
+
+func TestValidateRefusesDefaultSecrets(t *testing.T) {
+	cnf := *config.Cnf
+	cnf.IsDevelopment = false
+
+	err := cnf.Validate()
+	assert.Error(t, err)
+
+	errs, ok := err.(config.ValidationErrors)
+	assert.True(t, ok)
+
+	fields := make([]string, 0, len(errs))
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+
+	assert.Contains(t, fields, "Session.Secret")
+	assert.Contains(t, fields, "EmailTokenSecretKey")
+	assert.Contains(t, fields, "CSRF.Key")
+
+	cnf.CSRF.Key = "0123456789abcdef0123456789abcdef"
+	cnf.Mailgun.Key = "key-live"
+	cnf.Database.PSN = "postgres://id:s3cr3t@db:5432/id"
+	cnf.Session.Secret = "another secret"
+	cnf.EmailTokenSecretKey = "yet another secret"
+	cnf.Stripe.Secret = "sk_live_xxx"
+	cnf.Stripe.WebHookSecret = "whsec_xxx"
+	cnf.Oauth.SigningKey = "not a key"
+
+	err = cnf.Validate()
+	assert.EqualError(t, err, "invalid config: Oauth.SigningKey: must be a PEM encoded RSA private key")
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	cnf.Oauth.SigningKey = string(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}))
+
+	assert.NoError(t, cnf.Validate())
+}
+
+func TestValidateFields(t *testing.T) {
+	cnf := *config.Cnf
+	cnf.IsDevelopment = true
+	cnf.Port = "8080"
+	cnf.Oauth.AccessTokenLifetime = 0
+	cnf.AccountDeletion.GracePeriod = 0
+	cnf.Scheduler.Enabled = false
+	cnf.Scheduler.BatchSize = 0
+	cnf.Tracing.Exporter = "jaeger"
+	cnf.Metrics.Port = "9090"
+	cnf.Log.Level = "trace"
+	cnf.AppURL = "stream.resonate.coop"
+
+	err := cnf.Validate()
+	assert.EqualError(t, err, `invalid config: Port: must look like ":8080"; `+
+		`Oauth.AccessTokenLifetime: must be positive; `+
+		`AccountDeletion.GracePeriod: must be positive; `+
+		`Scheduler.BatchSize: must be positive; `+
+		`Tracing.Exporter: must be one of "none", "stdout" or "otlp"; `+
+		`Metrics.Port: must look like ":9090"; `+
+		`Log.Level: must be one of "debug", "info", "warning" or "error"; `+
+		`AppURL: must be an absolute URL`)
+}
+
+func TestValidateUserAPI(t *testing.T) {
+	cnf := *config.Cnf
+	cnf.IsDevelopment = true
+	cnf.UserAPI.InsecureSkipVerify = true
+
+	assert.NoError(t, cnf.Validate())
+
+	cnf.UserAPI.Timeout = 0
+	cnf.UserAPI.CACertificate = "not a certificate"
+	cnf.Scheduler.Enabled = true
+	cnf.UserAPI.SyncMaxAttempts = 0
+
+	err := cnf.Validate()
+	assert.EqualError(t, err, `invalid config: UserAPI.Timeout: must be positive; `+
+		`UserAPI.CACertificate: must be a PEM encoded certificate; `+
+		`UserAPI.SyncMaxAttempts: must be positive`)
+
+	// Certificates are always verified in production
+	cnf = *config.Cnf
+	cnf.IsDevelopment = false
+	cnf.UserAPI.InsecureSkipVerify = true
+
+	err = cnf.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "UserAPI.InsecureSkipVerify: must be false outside development mode")
+	}
+}
+
+func TestValidateAuthBackends(t *testing.T) {
+	cnf := *config.Cnf
+	cnf.IsDevelopment = true
+	cnf.AuthBackends = []config.AuthBackendConfig{
+		{
+			Name:      "label",
+			Type:      "ldap",
+			Domains:   []string{"label.example"},
+			Provision: true,
+			LDAP: config.LDAPConfig{
+				URL:    "ldaps://ldap.label.example",
+				BaseDN: "ou=people,dc=label,dc=example",
+			},
+		},
+		{
+			Name:    "staff",
+			Type:    "local",
+			Domains: []string{"resonate.coop"},
+		},
+	}
+
+	assert.NoError(t, cnf.Validate())
+
+	cnf.AuthBackends = []config.AuthBackendConfig{
+		{
+			Name:    "label",
+			Type:    "ldap",
+			Domains: []string{"label.example"},
+			LDAP: config.LDAPConfig{
+				URL:        "https://ldap.label.example",
+				UserFilter: "(mail=*)",
+			},
+		},
+		{
+			Type:      "local",
+			Domains:   []string{"Label.example"},
+			Provision: true,
+		},
+		{
+			Name: "kerberos",
+			Type: "kerberos",
+		},
+	}
+
+	err := cnf.Validate()
+	assert.EqualError(t, err, `invalid config: AuthBackends[0].LDAP.URL: must be an ldap:// or ldaps:// URL; `+
+		`AuthBackends[0].LDAP.BaseDN: must not be empty; `+
+		`AuthBackends[0].LDAP.UserFilter: must contain "%s" once; `+
+		`AuthBackends[1].Name: must not be empty; `+
+		`AuthBackends[1].Domains: label.example is handled by another backend; `+
+		`AuthBackends[1].Provision: must be false with the local backend; `+
+		`AuthBackends[2].Domains: must not be empty; `+
+		`AuthBackends[2].Type: must be "local" or "ldap"`)
+
+	// Outside development mode passwords only go to directories over TLS
+	cnf.IsDevelopment = false
+	cnf.AuthBackends = []config.AuthBackendConfig{
+		{
+			Name:    "label",
+			Type:    "ldap",
+			Domains: []string{"label.example"},
+			LDAP: config.LDAPConfig{
+				URL:    "ldap://ldap.label.example",
+				BaseDN: "ou=people,dc=label,dc=example",
+			},
+		},
+	}
+
+	err = cnf.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "AuthBackends[0].LDAP.StartTLS: must be true with ldap:// URLs outside development mode")
+	}
+
+	cnf.AuthBackends[0].LDAP.StartTLS = true
+	err = cnf.Validate()
+	if assert.Error(t, err) {
+		assert.NotContains(t, err.Error(), "AuthBackends[0].LDAP")
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cnf := *config.Cnf
+	cnf.Database.PSN = "postgres://id:s3cr3t@db:5432/id?sslmode=disable"
+	cnf.Mailgun.Key = ""
+	cnf.AuthBackends = []config.AuthBackendConfig{
+		{Name: "label", Type: "ldap", LDAP: config.LDAPConfig{BindDN: "cn=id", BindPassword: "s3cr3t"}},
+	}
+
+	redacted := cnf.Redacted()
+
+	assert.Equal(t, "REDACTED", redacted.Session.Secret)
+	assert.Equal(t, "REDACTED", redacted.EmailTokenSecretKey)
+	assert.Equal(t, "REDACTED", redacted.Stripe.Secret)
+	assert.Equal(t, "", redacted.Mailgun.Key)
+	assert.Equal(t, "postgres://id:REDACTED@db:5432/id?sslmode=disable", redacted.Database.PSN)
+	assert.Equal(t, cnf.Stripe.Token, redacted.Stripe.Token)
+	assert.Equal(t, "REDACTED", redacted.AuthBackends[0].LDAP.BindPassword)
+	assert.Equal(t, "cn=id", redacted.AuthBackends[0].LDAP.BindDN)
+
+	// The original config is left untouched
+	assert.Equal(t, config.Cnf.Session.Secret, cnf.Session.Secret)
+	assert.Equal(t, "postgres://id:s3cr3t@db:5432/id?sslmode=disable", cnf.Database.PSN)
+	assert.Equal(t, "s3cr3t", cnf.AuthBackends[0].LDAP.BindPassword)
+}
+
+func TestSubscribeUnknownField(t *testing.T) {
+	_, err := config.Subscribe("Session.Cookie", func(cnf *config.Config) {})
+	assert.EqualError(t, err, "unknown config field: Session.Cookie")
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrVaultNotConfigured ...
+	ErrVaultNotConfigured = errors.New("VAULT_ADDR and VAULT_TOKEN must be set to resolve vault secrets")
+	// ErrVaultInvalidReference ...
+	ErrVaultInvalidReference = errors.New("Vault references must look like vault:<path>#<key>")
+
+	vaultTimeout = 5 * time.Second
+)
+
+// vaultResolver reads secrets from a HashiCorp Vault KV engine,
+// both version 1 and version 2 mounts are supported
+type vaultResolver struct {
+	address string
+	token   string
+	client  *http.Client
+}
+
+// NewVaultResolver returns a resolver for references such as
+// "vault:secret/id#stripe". The address and token default to the
+// VAULT_ADDR and VAULT_TOKEN environment variables when empty.
+func NewVaultResolver(address, token string) SecretResolver {
+	return &vaultResolver{
+		address: address,
+		token:   token,
+		client:  &http.Client{Timeout: vaultTimeout},
+	}
+}
+
+// vaultResponse is the subset of a KV read response we use
+type vaultResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []string               `json:"errors"`
+}
+
+// Resolve as per the SecretResolver interface
+func (r *vaultResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	address, token := r.address, r.token
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+
+	if address == "" || token == "" {
+		return "", ErrVaultNotConfigured
+	}
+
+	i := strings.LastIndex(ref, "#")
+	if i <= 0 || i == len(ref)-1 {
+		return "", ErrVaultInvalidReference
+	}
+
+	path, key := strings.Trim(ref[:i], "/"), ref[i+1:]
+
+	data, err := r.read(ctx, address, token, path)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found in vault secret %s", key, path)
+	}
+
+	secret, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("key %s of vault secret %s is not a string", key, path)
+	}
+
+	return secret, nil
+}
+
+// read fetches the secret data at path, unwrapping KV version 2 responses
+func (r *vaultResolver) read(ctx context.Context, address, token, path string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("%s/v1/%s", strings.TrimRight(address, "/"), path)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("X-Vault-Token", token)
+
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body := new(vaultResponse)
+
+	if err := json.NewDecoder(resp.Body).Decode(body); err != nil {
+		return nil, fmt.Errorf("invalid vault response (status %d): %v", resp.StatusCode, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned status %d for %s: %s", resp.StatusCode, path, strings.Join(body.Errors, ", "))
+	}
+
+	// KV version 2 nests the secret data along with its metadata
+	if nested, ok := body.Data["data"].(map[string]interface{}); ok {
+		if _, ok := body.Data["metadata"]; ok {
+			return nested, nil
+		}
+	}
+
+	return body.Data, nil
+}
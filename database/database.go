@@ -4,6 +4,7 @@ import (
 	"github.com/jackc/pgx/v4"
 	stdlib "github.com/jackc/pgx/v4/stdlib"
 	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/tracing"
 	bun "github.com/uptrace/bun"
 
 	"github.com/uptrace/bun/dialect/pgdialect"
@@ -35,6 +36,8 @@ func NewDatabase(cnf *config.Config) (*bun.DB, error) {
 
 	db := bun.NewDB(sqldb, pgdialect.New())
 
+	db.AddQueryHook(tracing.NewQueryHook())
+
 	if cnf.IsDevelopment {
 		db.AddQueryHook(bundebug.NewQueryHook(bundebug.WithVerbose(true)))
 	}
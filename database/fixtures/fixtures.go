@@ -0,0 +1,227 @@
+// Package fixtures seeds a database with the roles, scopes and
+// clients the server expects to find
+package fixtures
+
+import (
+	"context"
+	_ "embed" // default fixtures
+	"net/url"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/rbac"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/id/util/password"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+//go:embed default.yml
+var defaultFixtures []byte
+
+// Fixtures lists the rows to insert, RolePermissions lists the permissions
+// granted to each role by role name
+type Fixtures struct {
+	Roles           []model.Role        `json:"roles"`
+	Scopes          []model.Scope       `json:"scopes"`
+	Permissions     []rbac.Permission   `json:"permissions"`
+	RolePermissions map[string][]string `json:"rolePermissions"`
+}
+
+// Grants returns the role permissions to insert
+func (f *Fixtures) Grants() []rbac.RolePermission {
+	var grants []rbac.RolePermission
+
+	for _, role := range f.Roles {
+		for _, permission := range f.RolePermissions[role.Name] {
+			grants = append(grants, rbac.RolePermission{RoleID: role.ID, Permission: permission})
+		}
+	}
+
+	return grants
+}
+
+// CreatedClient is a client inserted by Load along with its secret,
+// which cannot be recovered afterwards
+type CreatedClient struct {
+	Client *model.Client
+	Secret string
+}
+
+// Default returns the default roles, scopes and permissions
+func Default() (*Fixtures, error) {
+	fixtures := new(Fixtures)
+
+	if err := yaml.Unmarshal(defaultFixtures, fixtures); err != nil {
+		return nil, err
+	}
+
+	return fixtures, nil
+}
+
+// Load inserts the default roles, scopes and permissions along with an oauth client for
+// each of the configured clients. Rows which already exist are left
+// untouched so it can be run on every deploy.
+func Load(ctx context.Context, db *bun.DB, clients []config.ClientConfig) ([]*CreatedClient, error) {
+	fixtures, err := Default()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fixtures.Roles) > 0 {
+		_, err = tx.NewInsert().
+			Model(&fixtures.Roles).
+			On("CONFLICT DO NOTHING").
+			Exec(ctx)
+
+		if err != nil {
+			tx.Rollback() // rollback the transaction
+			return nil, err
+		}
+	}
+
+	if len(fixtures.Scopes) > 0 {
+		_, err = tx.NewInsert().
+			Model(&fixtures.Scopes).
+			On("CONFLICT DO NOTHING").
+			Exec(ctx)
+
+		if err != nil {
+			tx.Rollback() // rollback the transaction
+			return nil, err
+		}
+	}
+
+	if len(fixtures.Permissions) > 0 {
+		_, err = tx.NewInsert().
+			Model(&fixtures.Permissions).
+			On("CONFLICT DO NOTHING").
+			Exec(ctx)
+
+		if err != nil {
+			tx.Rollback() // rollback the transaction
+			return nil, err
+		}
+	}
+
+	// Permissions removed from a default role on purpose stay removed as
+	// long as one of the role's permissions is left
+	if grants := fixtures.Grants(); len(grants) > 0 {
+		var configured []int32
+
+		err = tx.NewSelect().
+			Model((*rbac.RolePermission)(nil)).
+			ColumnExpr("DISTINCT role_id").
+			Scan(ctx, &configured)
+
+		if err != nil {
+			tx.Rollback() // rollback the transaction
+			return nil, err
+		}
+
+		var missing []rbac.RolePermission
+		for _, grant := range grants {
+			if !containsRole(configured, grant.RoleID) {
+				missing = append(missing, grant)
+			}
+		}
+
+		if len(missing) > 0 {
+			_, err = tx.NewInsert().
+				Model(&missing).
+				On("CONFLICT DO NOTHING").
+				Exec(ctx)
+
+			if err != nil {
+				tx.Rollback() // rollback the transaction
+				return nil, err
+			}
+		}
+	}
+
+	created := []*CreatedClient{}
+
+	for _, clientConfig := range clients {
+		createdClient, err := loadClient(ctx, tx, clientConfig)
+		if err != nil {
+			tx.Rollback() // rollback the transaction
+			return nil, err
+		}
+
+		if createdClient != nil {
+			created = append(created, createdClient)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// ClientID derives the client ID of a configured client from its name,
+// e.g. "Upload Tool" becomes "upload_tool"
+func ClientID(clientConfig config.ClientConfig) string {
+	return clientConfig.ClientID()
+}
+
+// loadClient inserts a configured client unless it already exists
+func loadClient(ctx context.Context, tx bun.Tx, clientConfig config.ClientConfig) (*CreatedClient, error) {
+	clientID := ClientID(clientConfig)
+
+	exists, err := tx.NewSelect().
+		Model((*model.Client)(nil)).
+		Where("key = ?", clientID).
+		Exists(ctx)
+
+	if err != nil || exists {
+		return nil, err
+	}
+
+	secret, err := util.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	secretHash, err := password.HashPassword(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, applicationURL := "", ""
+	if u, err := url.Parse(clientConfig.ConnectUrl); err == nil && u.Host != "" {
+		hostname = u.Hostname()
+		applicationURL = u.Scheme + "://" + u.Host
+	}
+
+	client := &model.Client{
+		Key:                 clientID,
+		Secret:              string(secretHash),
+		RedirectURI:         util.StringOrNull(clientConfig.ConnectUrl),
+		ApplicationName:     util.StringOrNull(clientConfig.Name),
+		ApplicationHostname: util.StringOrNull(strings.ToLower(hostname)),
+		ApplicationURL:      util.StringOrNull(strings.ToLower(applicationURL)),
+	}
+
+	if _, err = tx.NewInsert().Model(client).Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	return &CreatedClient{Client: client, Secret: secret}, nil
+}
+
+func containsRole(roleIDs []int32, roleID int32) bool {
+	for _, id := range roleIDs {
+		if id == roleID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package fixtures_test
+
+import (
+	"testing"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/database/fixtures"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefault(t *testing.T) {
+	defaults, err := fixtures.Default()
+	assert.NoError(t, err)
+
+	assert.Len(t, defaults.Roles, 6)
+	assert.Equal(t, "superadmin", defaults.Roles[0].Name)
+	assert.True(t, defaults.Roles[5].IsDefault)
+
+	var defaultScopes []string
+	for _, scope := range defaults.Scopes {
+		if scope.IsDefault {
+			defaultScopes = append(defaultScopes, scope.Name)
+		}
+	}
+	assert.Equal(t, []string{"user", "read"}, defaultScopes)
+
+	// provisioning is only granted on request
+	assert.Contains(t, scopeNames(defaults.Scopes), "scim")
+	assert.Contains(t, scopeNames(defaults.Scopes), "admin_api")
+
+	// every role grants permissions which exist
+	var permissions []string
+	for _, permission := range defaults.Permissions {
+		permissions = append(permissions, permission.Name)
+	}
+	for _, role := range defaults.Roles {
+		assert.NotEmpty(t, defaults.RolePermissions[role.Name], role.Name)
+		for _, permission := range defaults.RolePermissions[role.Name] {
+			assert.Contains(t, permissions, permission)
+		}
+	}
+
+	// only admins manage roles
+	assert.Contains(t, defaults.RolePermissions["admin"], "roles:assign")
+	assert.NotContains(t, defaults.RolePermissions["tenantadmin"], "roles:assign")
+
+	// only admins act on behalf of users
+	assert.Contains(t, defaults.RolePermissions["admin"], "users:impersonate")
+	assert.NotContains(t, defaults.RolePermissions["tenantadmin"], "users:impersonate")
+
+	// only admins manage webhooks
+	assert.Contains(t, defaults.RolePermissions["admin"], "webhooks:manage")
+	assert.NotContains(t, defaults.RolePermissions["tenantadmin"], "webhooks:manage")
+}
+
+func TestGrants(t *testing.T) {
+	defaults, err := fixtures.Default()
+	assert.NoError(t, err)
+
+	grants := defaults.Grants()
+	assert.NotEmpty(t, grants)
+	for _, grant := range grants {
+		if grant.RoleID == int32(model.UserRole) {
+			assert.Equal(t, "account:write", grant.Permission)
+		}
+	}
+}
+
+func TestClientID(t *testing.T) {
+	assert.Equal(t, "upload_tool", fixtures.ClientID(config.ClientConfig{Name: "Upload Tool"}))
+	assert.Equal(t, "player", fixtures.ClientID(config.ClientConfig{Name: " Player! "}))
+}
+
+func scopeNames(scopes []model.Scope) []string {
+	var names []string
+	for _, scope := range scopes {
+		names = append(names, scope.Name)
+	}
+	return names
+}
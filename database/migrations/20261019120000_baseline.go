@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+// baselineModels are the tables the oauth service relies on, they are
+// usually created by the user api migrations
+var baselineModels = []interface{}{
+	(*model.Role)(nil),
+	(*model.Scope)(nil),
+	(*model.User)(nil),
+	(*model.Client)(nil),
+	(*model.EmailToken)(nil),
+	(*model.AccessToken)(nil),
+	(*model.RefreshToken)(nil),
+	(*model.AuthorizationCode)(nil),
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, `CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`); err != nil {
+			return err
+		}
+
+		for _, m := range baselineModels {
+			_, err := db.NewCreateTable().
+				Model(m).
+				IfNotExists().
+				Exec(ctx)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		// The tables may belong to the user api, they are never dropped
+		return nil
+	})
+}
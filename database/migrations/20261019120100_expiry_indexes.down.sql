@@ -0,0 +1,13 @@
+DROP INDEX CONCURRENTLY IF EXISTS access_tokens_expires_at_idx;
+
+--bun:split
+
+DROP INDEX CONCURRENTLY IF EXISTS refresh_tokens_expires_at_idx;
+
+--bun:split
+
+DROP INDEX CONCURRENTLY IF EXISTS authorization_codes_expires_at_idx;
+
+--bun:split
+
+DROP INDEX CONCURRENTLY IF EXISTS email_tokens_expires_at_idx;
@@ -0,0 +1,13 @@
+CREATE INDEX CONCURRENTLY IF NOT EXISTS access_tokens_expires_at_idx ON access_tokens (expires_at);
+
+--bun:split
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS refresh_tokens_expires_at_idx ON refresh_tokens (expires_at);
+
+--bun:split
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS authorization_codes_expires_at_idx ON authorization_codes (expires_at);
+
+--bun:split
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS email_tokens_expires_at_idx ON email_tokens (expires_at);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS realm_users;
+
+--bun:split
+
+DROP TABLE IF EXISTS realm_clients;
+
+--bun:split
+
+DROP TABLE IF EXISTS realms;
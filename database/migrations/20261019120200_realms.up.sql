@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS realms (
+  id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  slug varchar NOT NULL UNIQUE,
+  name varchar NOT NULL,
+  hostnames varchar[] NOT NULL DEFAULT '{}',
+  active boolean NOT NULL DEFAULT true,
+  default_role_id integer NOT NULL DEFAULT 0,
+  scopes varchar[] NOT NULL DEFAULT '{}',
+  default_scope varchar NOT NULL DEFAULT '',
+  mail jsonb NOT NULL DEFAULT '{}',
+  payment jsonb NOT NULL DEFAULT '{}',
+  branding jsonb NOT NULL DEFAULT '{}',
+  created_at timestamptz NOT NULL DEFAULT current_timestamp,
+  updated_at timestamptz NOT NULL DEFAULT current_timestamp
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS realms_hostnames_idx ON realms USING GIN (hostnames);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS realm_clients (
+  client_id uuid PRIMARY KEY REFERENCES clients (id) ON DELETE CASCADE,
+  realm_id uuid NOT NULL REFERENCES realms (id) ON DELETE CASCADE
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS realm_clients_realm_id_idx ON realm_clients (realm_id);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS realm_users (
+  user_id uuid PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+  realm_id uuid NOT NULL REFERENCES realms (id) ON DELETE CASCADE
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS realm_users_realm_id_idx ON realm_users (realm_id);
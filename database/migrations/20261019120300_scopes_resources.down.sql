@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS authorization_code_resources;
+
+--bun:split
+
+DROP TABLE IF EXISTS access_token_resources;
+
+--bun:split
+
+DROP TABLE IF EXISTS resources;
+
+--bun:split
+
+DROP TABLE IF EXISTS client_scopes;
@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS client_scopes (
+  client_id uuid NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+  scope varchar(50) NOT NULL REFERENCES scopes (name) ON DELETE CASCADE,
+  PRIMARY KEY (client_id, scope)
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS resources (
+  uri varchar(200) PRIMARY KEY,
+  namespace varchar(50) NOT NULL,
+  description varchar(200) NOT NULL DEFAULT '',
+  created_at timestamptz NOT NULL DEFAULT current_timestamp
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS resources_namespace_idx ON resources (namespace);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS access_token_resources (
+  access_token_id uuid NOT NULL REFERENCES access_tokens (id) ON DELETE CASCADE,
+  resource varchar(200) NOT NULL REFERENCES resources (uri) ON DELETE CASCADE,
+  PRIMARY KEY (access_token_id, resource)
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS authorization_code_resources (
+  authorization_code_id uuid NOT NULL REFERENCES authorization_codes (id) ON DELETE CASCADE,
+  resource varchar(200) NOT NULL REFERENCES resources (uri) ON DELETE CASCADE,
+  PRIMARY KEY (authorization_code_id, resource)
+);
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS role_assignments;
+
+--bun:split
+
+DROP TABLE IF EXISTS user_roles;
+
+--bun:split
+
+DROP TABLE IF EXISTS role_permissions;
+
+--bun:split
+
+DROP TABLE IF EXISTS permissions;
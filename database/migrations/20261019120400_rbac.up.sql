@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS permissions (
+  name varchar(50) PRIMARY KEY,
+  description varchar(200) NOT NULL DEFAULT '',
+  created_at timestamptz NOT NULL DEFAULT current_timestamp
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+  role_id integer NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+  permission varchar(50) NOT NULL REFERENCES permissions (name) ON DELETE CASCADE,
+  PRIMARY KEY (role_id, permission)
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS user_roles (
+  user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  role_id integer NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+  created_at timestamptz NOT NULL DEFAULT current_timestamp,
+  PRIMARY KEY (user_id, role_id)
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS user_roles_role_id_idx ON user_roles (role_id);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS role_assignments (
+  id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  role_id integer NOT NULL,
+  role_name varchar(50) NOT NULL,
+  action varchar(10) NOT NULL,
+  actor_id uuid,
+  created_at timestamptz NOT NULL DEFAULT current_timestamp
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS role_assignments_user_id_idx ON role_assignments (user_id, created_at);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS initial_access_tokens;
+
+--bun:split
+
+DROP TABLE IF EXISTS client_metadata;
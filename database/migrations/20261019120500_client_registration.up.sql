@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS client_metadata (
+  client_id uuid PRIMARY KEY REFERENCES clients (id) ON DELETE CASCADE,
+  redirect_uris text[] NOT NULL DEFAULT '{}',
+  grant_types text[] NOT NULL DEFAULT '{}',
+  response_types text[] NOT NULL DEFAULT '{}',
+  token_endpoint_auth_method varchar(50) NOT NULL DEFAULT 'client_secret_basic',
+  client_uri varchar(200) NOT NULL DEFAULT '',
+  logo_uri varchar(200) NOT NULL DEFAULT '',
+  policy_uri varchar(200) NOT NULL DEFAULT '',
+  tos_uri varchar(200) NOT NULL DEFAULT '',
+  contacts text[] NOT NULL DEFAULT '{}',
+  software_id varchar(100) NOT NULL DEFAULT '',
+  software_version varchar(100) NOT NULL DEFAULT '',
+  registration_token_hash varchar(64) UNIQUE,
+  created_at timestamptz NOT NULL DEFAULT current_timestamp,
+  updated_at timestamptz NOT NULL DEFAULT current_timestamp
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS initial_access_tokens (
+  id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  token_hash varchar(64) NOT NULL UNIQUE,
+  description varchar(200) NOT NULL DEFAULT '',
+  expires_at timestamptz,
+  created_at timestamptz NOT NULL DEFAULT current_timestamp
+);
@@ -0,0 +1,22 @@
+ALTER TABLE client_metadata
+  DROP COLUMN IF EXISTS post_logout_redirect_uris,
+  DROP COLUMN IF EXISTS backchannel_logout_uri,
+  DROP COLUMN IF EXISTS backchannel_logout_session_required,
+  DROP COLUMN IF EXISTS frontchannel_logout_uri,
+  DROP COLUMN IF EXISTS frontchannel_logout_session_required;
+
+--bun:split
+
+DROP TABLE IF EXISTS authorization_code_sessions;
+
+--bun:split
+
+DROP TABLE IF EXISTS refresh_token_sessions;
+
+--bun:split
+
+DROP TABLE IF EXISTS access_token_sessions;
+
+--bun:split
+
+DROP TABLE IF EXISTS user_sessions;
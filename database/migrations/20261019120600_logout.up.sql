@@ -0,0 +1,50 @@
+CREATE TABLE IF NOT EXISTS user_sessions (
+  id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  created_at timestamptz NOT NULL DEFAULT current_timestamp,
+  ended_at timestamptz
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS user_sessions_user_id_idx ON user_sessions (user_id);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS access_token_sessions (
+  access_token_id uuid NOT NULL REFERENCES access_tokens (id) ON DELETE CASCADE,
+  session_id uuid NOT NULL REFERENCES user_sessions (id) ON DELETE CASCADE,
+  PRIMARY KEY (access_token_id, session_id)
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS access_token_sessions_session_id_idx ON access_token_sessions (session_id);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS refresh_token_sessions (
+  refresh_token_id uuid NOT NULL REFERENCES refresh_tokens (id) ON DELETE CASCADE,
+  session_id uuid NOT NULL REFERENCES user_sessions (id) ON DELETE CASCADE,
+  PRIMARY KEY (refresh_token_id, session_id)
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS refresh_token_sessions_session_id_idx ON refresh_token_sessions (session_id);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS authorization_code_sessions (
+  authorization_code_id uuid PRIMARY KEY REFERENCES authorization_codes (id) ON DELETE CASCADE,
+  session_id uuid NOT NULL REFERENCES user_sessions (id) ON DELETE CASCADE
+);
+
+--bun:split
+
+ALTER TABLE client_metadata
+  ADD COLUMN IF NOT EXISTS post_logout_redirect_uris text[] NOT NULL DEFAULT '{}',
+  ADD COLUMN IF NOT EXISTS backchannel_logout_uri varchar(200) NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS backchannel_logout_session_required boolean NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS frontchannel_logout_uri varchar(200) NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS frontchannel_logout_session_required boolean NOT NULL DEFAULT false;
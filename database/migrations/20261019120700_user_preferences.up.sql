@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS user_preferences (
+  user_id uuid PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+  locale text NOT NULL,
+  updated_at timestamptz NOT NULL DEFAULT current_timestamp
+);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS pushed_authorization_requests;
+
+--bun:split
+
+ALTER TABLE client_metadata
+  DROP COLUMN IF EXISTS jwks_uri,
+  DROP COLUMN IF EXISTS jwks,
+  DROP COLUMN IF EXISTS require_pushed_authorization_requests,
+  DROP COLUMN IF EXISTS request_object_signing_alg;
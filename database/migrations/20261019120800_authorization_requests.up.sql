@@ -0,0 +1,20 @@
+ALTER TABLE client_metadata
+  ADD COLUMN IF NOT EXISTS jwks_uri varchar(200) NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS jwks jsonb,
+  ADD COLUMN IF NOT EXISTS require_pushed_authorization_requests boolean NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS request_object_signing_alg varchar(10) NOT NULL DEFAULT '';
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS pushed_authorization_requests (
+  id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  request_uri_hash varchar(64) NOT NULL UNIQUE,
+  client_id uuid NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+  parameters text NOT NULL,
+  expires_at timestamptz NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT current_timestamp
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS pushed_authorization_requests_expires_at_idx ON pushed_authorization_requests (expires_at);
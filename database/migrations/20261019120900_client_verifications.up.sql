@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS client_verifications (
+  client_id uuid PRIMARY KEY REFERENCES clients (id) ON DELETE CASCADE,
+  verified_at timestamptz NOT NULL DEFAULT current_timestamp
+);
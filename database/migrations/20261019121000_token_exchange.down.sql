@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS access_token_actors;
+
+--bun:split
+
+DROP TABLE IF EXISTS token_exchange_policies;
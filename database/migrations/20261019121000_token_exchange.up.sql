@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS token_exchange_policies (
+  client_id uuid PRIMARY KEY REFERENCES clients (id) ON DELETE CASCADE,
+  subject_clients text[] NOT NULL DEFAULT '{}',
+  resources text[] NOT NULL DEFAULT '{}',
+  scopes text[] NOT NULL DEFAULT '{}',
+  allow_impersonation boolean NOT NULL DEFAULT false,
+  created_at timestamptz NOT NULL DEFAULT current_timestamp,
+  updated_at timestamptz NOT NULL DEFAULT current_timestamp
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS access_token_actors (
+  access_token_id uuid PRIMARY KEY REFERENCES access_tokens (id) ON DELETE CASCADE,
+  act jsonb NOT NULL
+);
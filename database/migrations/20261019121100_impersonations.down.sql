@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS impersonation_events;
+
+--bun:split
+
+DROP TABLE IF EXISTS impersonations;
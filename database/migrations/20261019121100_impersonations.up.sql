@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS impersonations (
+  id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  actor_id uuid NOT NULL,
+  actor_username varchar(254) NOT NULL,
+  user_id uuid NOT NULL,
+  username varchar(254) NOT NULL,
+  reason text NOT NULL,
+  session_id uuid NOT NULL,
+  started_at timestamptz NOT NULL DEFAULT current_timestamp,
+  expires_at timestamptz NOT NULL,
+  ended_at timestamptz
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS impersonations_actor_id_idx ON impersonations (actor_id);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS impersonations_user_id_idx ON impersonations (user_id);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS impersonation_events (
+  id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  impersonation_id uuid NOT NULL REFERENCES impersonations (id) ON DELETE CASCADE,
+  action varchar(20) NOT NULL,
+  detail text NOT NULL DEFAULT '',
+  created_at timestamptz NOT NULL DEFAULT current_timestamp
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS impersonation_events_impersonation_id_idx ON impersonation_events (impersonation_id, created_at);
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS user_api_changes (
+  id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id uuid NOT NULL,
+  action varchar(20) NOT NULL,
+  attempts integer NOT NULL DEFAULT 0,
+  last_error text NOT NULL DEFAULT '',
+  next_attempt_at timestamptz NOT NULL DEFAULT current_timestamp,
+  created_at timestamptz NOT NULL DEFAULT current_timestamp
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS user_api_changes_next_attempt_at_idx ON user_api_changes (next_attempt_at);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS credits (
+  id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  created_at timestamptz NOT NULL DEFAULT current_timestamp,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  user_id uuid NOT NULL,
+  total bigint NOT NULL DEFAULT 128
+);
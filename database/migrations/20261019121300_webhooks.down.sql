@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS webhook_delivery_attempts;
+
+--bun:split
+
+DROP TABLE IF EXISTS webhook_deliveries;
+
+--bun:split
+
+DROP TABLE IF EXISTS webhook_events;
+
+--bun:split
+
+DROP TABLE IF EXISTS webhook_subscriptions;
@@ -0,0 +1,60 @@
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+  id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  url text NOT NULL,
+  secret varchar(100) NOT NULL,
+  events text[] NOT NULL DEFAULT '{}',
+  description text NOT NULL DEFAULT '',
+  active boolean NOT NULL DEFAULT true,
+  created_at timestamptz NOT NULL DEFAULT current_timestamp,
+  updated_at timestamptz NOT NULL DEFAULT current_timestamp
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS webhook_events (
+  id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  type varchar(50) NOT NULL,
+  user_id uuid,
+  data jsonb NOT NULL DEFAULT '{}',
+  created_at timestamptz NOT NULL DEFAULT current_timestamp
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS webhook_events_user_id_idx ON webhook_events (user_id);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  subscription_id uuid NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+  event_id uuid NOT NULL REFERENCES webhook_events (id) ON DELETE CASCADE,
+  status varchar(20) NOT NULL DEFAULT 'pending',
+  attempts integer NOT NULL DEFAULT 0,
+  next_attempt_at timestamptz NOT NULL DEFAULT current_timestamp,
+  delivered_at timestamptz,
+  created_at timestamptz NOT NULL DEFAULT current_timestamp
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_status_idx ON webhook_deliveries (status, next_attempt_at);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_id_idx ON webhook_deliveries (subscription_id, created_at);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+  id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  delivery_id uuid NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE,
+  status_code integer NOT NULL DEFAULT 0,
+  error text NOT NULL DEFAULT '',
+  duration_ms integer NOT NULL DEFAULT 0,
+  created_at timestamptz NOT NULL DEFAULT current_timestamp
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_attempts_delivery_id_idx ON webhook_delivery_attempts (delivery_id, created_at);
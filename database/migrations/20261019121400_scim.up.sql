@@ -0,0 +1,50 @@
+CREATE TABLE IF NOT EXISTS group_types (
+  id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  created_at timestamptz NOT NULL DEFAULT current_timestamp,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  name varchar NOT NULL,
+  description varchar
+);
+
+--bun:split
+
+INSERT INTO group_types (name, description)
+SELECT t.name, t.description
+FROM (VALUES ('persona', 'Persona'), ('band', 'Band'), ('label', 'Label'), ('distributor', 'Distributor')) AS t (name, description)
+WHERE NOT EXISTS (SELECT 1 FROM group_types WHERE group_types.name = t.name);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS user_groups (
+  id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  created_at timestamptz NOT NULL DEFAULT current_timestamp,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  display_name varchar NOT NULL UNIQUE,
+  description varchar,
+  short_bio varchar,
+  group_email varchar,
+  address_id uuid NOT NULL,
+  type_id uuid NOT NULL,
+  owner_id uuid NOT NULL,
+  links uuid[],
+  avatar uuid,
+  banner uuid,
+  tags uuid[]
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS user_groups_owner_id_idx ON user_groups (owner_id);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS scim_external_ids (
+  resource_type varchar(20) NOT NULL,
+  resource_id uuid NOT NULL,
+  external_id varchar NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT current_timestamp,
+  PRIMARY KEY (resource_type, resource_id),
+  UNIQUE (resource_type, external_id)
+);
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS refresh_token_resources (
+  refresh_token_id uuid NOT NULL REFERENCES refresh_tokens (id) ON DELETE CASCADE,
+  resource varchar(200) NOT NULL REFERENCES resources (uri) ON DELETE CASCADE,
+  PRIMARY KEY (refresh_token_id, resource)
+);
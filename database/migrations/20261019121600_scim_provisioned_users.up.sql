@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS scim_provisioned_users (
+  user_id uuid PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+  created_at timestamptz NOT NULL DEFAULT current_timestamp
+);
+
+--bun:split
+
+INSERT INTO scim_provisioned_users (user_id, created_at)
+SELECT e.resource_id, e.created_at
+FROM scim_external_ids AS e
+JOIN users ON users.id = e.resource_id
+WHERE e.resource_type = 'User'
+ON CONFLICT DO NOTHING;
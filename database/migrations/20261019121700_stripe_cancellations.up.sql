@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS stripe_cancellations (
+  id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  email varchar(255) NOT NULL,
+  stripe_account varchar(255) NOT NULL DEFAULT '',
+  attempts integer NOT NULL DEFAULT 0,
+  last_error text NOT NULL DEFAULT '',
+  next_attempt_at timestamptz NOT NULL DEFAULT current_timestamp,
+  created_at timestamptz NOT NULL DEFAULT current_timestamp
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS stripe_cancellations_next_attempt_at_idx ON stripe_cancellations (next_attempt_at);
@@ -0,0 +1,2 @@
+ALTER TABLE initial_access_tokens
+  DROP COLUMN IF EXISTS realm_id;
@@ -0,0 +1,2 @@
+ALTER TABLE initial_access_tokens
+  ADD COLUMN IF NOT EXISTS realm_id uuid REFERENCES realms (id) ON DELETE CASCADE;
@@ -0,0 +1,6 @@
+DELETE FROM impersonations WHERE session_id IS NULL;
+
+--bun:split
+
+ALTER TABLE impersonations
+  ALTER COLUMN session_id SET NOT NULL;
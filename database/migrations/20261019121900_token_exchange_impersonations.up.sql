@@ -0,0 +1,2 @@
+ALTER TABLE impersonations
+  ALTER COLUMN session_id DROP NOT NULL;
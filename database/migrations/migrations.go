@@ -0,0 +1,79 @@
+// Package migrations manages the database schema of the id server.
+//
+// The tables shared with the user api are created by the baseline migration
+// only when they do not exist yet, tables owned by this server are created
+// by SQL migrations embedded in the binary.
+package migrations
+
+import (
+	"context"
+	"embed"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+)
+
+const (
+	// tableName keeps track of applied migrations, it differs from the
+	// default so it does not collide with the user api migrations
+	tableName = "id_migrations"
+	// locksTableName prevents concurrent migrations
+	locksTableName = "id_migration_locks"
+)
+
+var (
+	// Migrations lists the SQL and Go migrations
+	Migrations = migrate.NewMigrations()
+
+	//go:embed *.sql
+	sqlMigrations embed.FS
+)
+
+func init() {
+	if err := Migrations.Discover(sqlMigrations); err != nil {
+		panic(err)
+	}
+}
+
+// NewMigrator returns a migrator using the id migrations tables
+func NewMigrator(db *bun.DB) *migrate.Migrator {
+	return migrate.NewMigrator(
+		db,
+		Migrations,
+		migrate.WithTableName(tableName),
+		migrate.WithLocksTableName(locksTableName),
+	)
+}
+
+// Up applies every pending migration as a new group
+func Up(ctx context.Context, db *bun.DB) (*migrate.MigrationGroup, error) {
+	migrator := NewMigrator(db)
+
+	if err := migrator.Init(ctx); err != nil {
+		return nil, err
+	}
+
+	return migrator.Migrate(ctx)
+}
+
+// Down rolls back the last group of applied migrations
+func Down(ctx context.Context, db *bun.DB) (*migrate.MigrationGroup, error) {
+	migrator := NewMigrator(db)
+
+	if err := migrator.Init(ctx); err != nil {
+		return nil, err
+	}
+
+	return migrator.Rollback(ctx)
+}
+
+// Status returns every migration, applied ones have a non zero ID
+func Status(ctx context.Context, db *bun.DB) (migrate.MigrationSlice, error) {
+	migrator := NewMigrator(db)
+
+	if err := migrator.Init(ctx); err != nil {
+		return nil, err
+	}
+
+	return migrator.MigrationsWithStatus(ctx)
+}
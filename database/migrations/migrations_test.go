@@ -0,0 +1,41 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/resonatecoop/id/database/migrations"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrationsAreRegistered(t *testing.T) {
+	sorted := migrations.Migrations.Sorted()
+
+	if assert.True(t, len(sorted) >= 20) {
+		assert.Equal(t, "20261019120000", sorted[0].Name)
+		assert.Equal(t, "20261019120100", sorted[1].Name)
+		assert.Equal(t, "20261019120200", sorted[2].Name)
+		assert.Equal(t, "20261019120300", sorted[3].Name)
+		assert.Equal(t, "20261019120400", sorted[4].Name)
+		assert.Equal(t, "20261019120500", sorted[5].Name)
+		assert.Equal(t, "20261019120600", sorted[6].Name)
+		assert.Equal(t, "20261019120700", sorted[7].Name)
+		assert.Equal(t, "20261019120800", sorted[8].Name)
+		assert.Equal(t, "20261019120900", sorted[9].Name)
+		assert.Equal(t, "20261019121000", sorted[10].Name)
+		assert.Equal(t, "20261019121100", sorted[11].Name)
+		assert.Equal(t, "20261019121200", sorted[12].Name)
+		assert.Equal(t, "20261019121300", sorted[13].Name)
+		assert.Equal(t, "20261019121400", sorted[14].Name)
+		assert.Equal(t, "20261019121500", sorted[15].Name)
+		assert.Equal(t, "20261019121600", sorted[16].Name)
+		assert.Equal(t, "20261019121700", sorted[17].Name)
+		assert.Equal(t, "20261019121800", sorted[18].Name)
+		assert.Equal(t, "20261019121900", sorted[19].Name)
+	}
+
+	for _, migration := range sorted {
+		assert.NotNil(t, migration.Up, migration.Name)
+		assert.NotNil(t, migration.Down, migration.Name)
+		assert.False(t, migration.IsApplied(), migration.Name)
+	}
+}
@@ -0,0 +1,231 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/log"
+	"github.com/uptrace/bun"
+)
+
+// maxBackoff bounds the delay between two attempts to deliver an event
+const maxBackoff = 6 * time.Hour
+
+// payload is the body POSTed to subscribers
+type payload struct {
+	ID        uuid.UUID              `json:"id"`
+	Type      string                 `json:"type"`
+	UserID    uuid.UUID              `json:"user_id,omitempty"`
+	Data      map[string]interface{} `json:"data"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// DeliverPending sends up to batchSize deliveries due to their active
+// subscriptions, oldest first. Deliveries answered with anything but a 2xx
+// status are retried with an exponential backoff until
+// cnf.Webhooks.MaxAttempts is reached. It returns the number of delivered
+// events.
+func (s *Service) DeliverPending(batchSize int) (int, error) {
+	ctx := context.Background()
+
+	deliveries := []*Delivery{}
+	err := s.db.NewSelect().
+		Model(&deliveries).
+		Where("status = ?", DeliveryPending).
+		Where("next_attempt_at <= ?", time.Now().UTC()).
+		Where("subscription_id IN (?)", s.db.NewSelect().
+			Model((*Subscription)(nil)).
+			Column("id").
+			Where("active")).
+		Order("created_at ASC").
+		Limit(batchSize).
+		Scan(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(deliveries) == 0 {
+		return 0, nil
+	}
+
+	subscriptions := map[uuid.UUID]*Subscription{}
+	events := map[uuid.UUID]*Event{}
+
+	for _, delivery := range deliveries {
+		subscriptions[delivery.SubscriptionID] = nil
+		events[delivery.EventID] = nil
+	}
+
+	if err = s.load(ctx, subscriptions, events); err != nil {
+		return 0, err
+	}
+
+	client := &http.Client{Timeout: time.Duration(s.cnf.Webhooks.Timeout) * time.Second}
+	delivered := 0
+
+	for _, delivery := range deliveries {
+		attempt := s.send(ctx, client, subscriptions[delivery.SubscriptionID], events[delivery.EventID], delivery)
+
+		if err = s.recordAttempt(ctx, delivery, attempt); err != nil {
+			return delivered, err
+		}
+
+		if delivery.Status == DeliveryDelivered {
+			delivered++
+		}
+	}
+
+	return delivered, nil
+}
+
+// load fetches the subscriptions and events deliveries refer to
+func (s *Service) load(ctx context.Context, subscriptions map[uuid.UUID]*Subscription, events map[uuid.UUID]*Event) error {
+	subscriptionIDs := make([]uuid.UUID, 0, len(subscriptions))
+	for id := range subscriptions {
+		subscriptionIDs = append(subscriptionIDs, id)
+	}
+
+	eventIDs := make([]uuid.UUID, 0, len(events))
+	for id := range events {
+		eventIDs = append(eventIDs, id)
+	}
+
+	foundSubscriptions := []*Subscription{}
+	err := s.db.NewSelect().
+		Model(&foundSubscriptions).
+		Where("id IN (?)", bun.In(subscriptionIDs)).
+		Scan(ctx)
+	if err != nil {
+		return err
+	}
+	for _, subscription := range foundSubscriptions {
+		subscriptions[subscription.ID] = subscription
+	}
+
+	foundEvents := []*Event{}
+	err = s.db.NewSelect().
+		Model(&foundEvents).
+		Where("id IN (?)", bun.In(eventIDs)).
+		Scan(ctx)
+	if err != nil {
+		return err
+	}
+	for _, event := range foundEvents {
+		events[event.ID] = event
+	}
+
+	return nil
+}
+
+// send POSTs the signed event to the subscription and returns how it went
+func (s *Service) send(ctx context.Context, client *http.Client, subscription *Subscription, event *Event, delivery *Delivery) *Attempt {
+	attempt := &Attempt{DeliveryID: delivery.ID}
+
+	if subscription == nil || event == nil {
+		attempt.Error = "subscription or event is gone"
+		return attempt
+	}
+
+	body, err := json.Marshal(&payload{
+		ID:        event.ID,
+		Type:      event.Type,
+		UserID:    event.UserID,
+		Data:      event.Data,
+		CreatedAt: event.CreatedAt,
+	})
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Resonate-Event", event.Type)
+	req.Header.Set("Resonate-Delivery", delivery.ID.String())
+	req.Header.Set(SignatureHeader, Sign(subscription.Secret, time.Now(), body))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	attempt.DurationMs = time.Since(start).Milliseconds()
+
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	attempt.StatusCode = resp.StatusCode
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// keep the start of the answer around for debugging
+		snippet, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		attempt.Error = fmt.Sprintf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(snippet))
+	}
+
+	return attempt
+}
+
+// recordAttempt logs an attempt and updates its delivery, failed deliveries
+// are scheduled again unless they ran out of attempts
+func (s *Service) recordAttempt(ctx context.Context, delivery *Delivery, attempt *Attempt) error {
+	now := time.Now().UTC()
+
+	delivery.Attempts++
+
+	switch {
+	case attempt.Error == "":
+		delivery.Status = DeliveryDelivered
+		delivery.DeliveredAt = now
+	case delivery.Attempts >= s.cnf.Webhooks.MaxAttempts:
+		delivery.Status = DeliveryFailed
+		log.ERROR.Printf("Giving up on webhook delivery %s: %s", delivery.ID, attempt.Error)
+	default:
+		delivery.NextAttemptAt = now.Add(s.backoff(delivery.Attempts))
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.NewInsert().Model(attempt).Exec(ctx); err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	_, err = tx.NewUpdate().
+		Model(delivery).
+		Column("status", "attempts", "next_attempt_at", "delivered_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// backoff returns how long to wait after the given number of attempts
+func (s *Service) backoff(attempts int) time.Duration {
+	backoff := time.Duration(s.cnf.Webhooks.DeliveryInterval) * time.Second
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
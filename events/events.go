@@ -0,0 +1,195 @@
+package events
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// Events sent to subscribers, the data of each is documented in docs/webhooks.md
+const (
+	// UserRegistered is sent when a user signs up
+	UserRegistered = "user.registered"
+	// UserEmailConfirmed is sent when a user confirms their email address
+	UserEmailConfirmed = "user.email_confirmed"
+	// UserEmailChanged is sent when a user changes their email address
+	UserEmailChanged = "user.email_changed"
+	// UserMembershipGranted is sent when a user becomes a member
+	UserMembershipGranted = "user.membership_granted"
+	// UserMembershipRevoked is sent when the membership of a user ends
+	UserMembershipRevoked = "user.membership_revoked"
+	// UserCreditsPurchased is sent when a user buys stream credits
+	UserCreditsPurchased = "user.credits_purchased"
+	// UserDeleted is sent when a user deletes their account, it is purged
+	// once the grace period is over
+	UserDeleted = "user.deleted"
+	// UserRestored is sent when a user cancels the deletion of their account
+	UserRestored = "user.restored"
+)
+
+// Types lists every event subscribers may subscribe to
+var Types = []string{
+	UserRegistered,
+	UserEmailConfirmed,
+	UserEmailChanged,
+	UserMembershipGranted,
+	UserMembershipRevoked,
+	UserCreditsPurchased,
+	UserDeleted,
+	UserRestored,
+}
+
+// Status of a delivery
+const (
+	// DeliveryPending deliveries are sent by the next run of DeliverPending
+	DeliveryPending = "pending"
+	// DeliveryDelivered deliveries were acknowledged by the subscriber
+	DeliveryDelivered = "delivered"
+	// DeliveryFailed deliveries were given up on after cnf.Webhooks.MaxAttempts
+	DeliveryFailed = "failed"
+)
+
+// SignatureHeader is the header holding the signature of a payload
+const SignatureHeader = "Resonate-Signature"
+
+var (
+	// ErrSignatureInvalid ...
+	ErrSignatureInvalid = errors.New("Invalid webhook signature")
+	// ErrSignatureExpired ...
+	ErrSignatureExpired = errors.New("Webhook signature expired")
+)
+
+// Subscription is a service notified of the events it subscribed to, every
+// event when Events is empty
+type Subscription struct {
+	bun.BaseModel `bun:"table:webhook_subscriptions"`
+
+	ID          uuid.UUID `bun:",pk,type:uuid,nullzero,default:uuid_generate_v4()" json:"id"`
+	URL         string    `bun:",notnull" json:"url"`
+	Secret      string    `bun:",notnull" json:"-"`
+	Events      []string  `bun:",array,notnull" json:"events"`
+	Description string    `bun:",notnull" json:"description"`
+	Active      bool      `bun:",notnull" json:"active"`
+	CreatedAt   time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt   time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// Event is something which happened to a user, it is delivered to every
+// subscription interested at the time
+type Event struct {
+	bun.BaseModel `bun:"table:webhook_events"`
+
+	ID        uuid.UUID              `bun:",pk,type:uuid,nullzero,default:uuid_generate_v4()" json:"id"`
+	Type      string                 `bun:",notnull" json:"type"`
+	UserID    uuid.UUID              `bun:"type:uuid,nullzero" json:"user_id,omitempty"`
+	Data      map[string]interface{} `bun:"type:jsonb,notnull" json:"data"`
+	CreatedAt time.Time              `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// Delivery is an event being sent to a subscription
+type Delivery struct {
+	bun.BaseModel `bun:"table:webhook_deliveries"`
+
+	ID             uuid.UUID `bun:",pk,type:uuid,nullzero,default:uuid_generate_v4()" json:"id"`
+	SubscriptionID uuid.UUID `bun:"type:uuid,notnull" json:"subscription_id"`
+	EventID        uuid.UUID `bun:"type:uuid,notnull" json:"event_id"`
+	Status         string    `bun:",notnull,default:'pending'" json:"status"`
+	Attempts       int       `bun:",notnull" json:"attempts"`
+	NextAttemptAt  time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"next_attempt_at"`
+	DeliveredAt    time.Time `bun:",nullzero" json:"delivered_at,omitempty"`
+	CreatedAt      time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// Attempt logs a single try to deliver an event
+type Attempt struct {
+	bun.BaseModel `bun:"table:webhook_delivery_attempts"`
+
+	ID         uuid.UUID `bun:",pk,type:uuid,nullzero,default:uuid_generate_v4()" json:"id"`
+	DeliveryID uuid.UUID `bun:"type:uuid,notnull" json:"delivery_id"`
+	StatusCode int       `bun:",notnull" json:"status_code"`
+	Error      string    `bun:",notnull" json:"error,omitempty"`
+	DurationMs int64     `bun:",notnull" json:"duration_ms"`
+	CreatedAt  time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// IsType returns true for the events subscribers may subscribe to
+func IsType(eventType string) bool {
+	for _, t := range Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Wants returns true if the subscription is interested in the event type
+func (s *Subscription) Wants(eventType string) bool {
+	if !s.Active {
+		return false
+	}
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the value of the Resonate-Signature header of a payload sent
+// at the given time, the hex encoded HMAC-SHA256 of "timestamp.payload"
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", t, computeSignature(secret, t, payload))
+}
+
+// Verify checks the Resonate-Signature header of a payload, signatures
+// older than tolerance are refused to prevent replays
+func Verify(secret, header string, payload []byte, tolerance time.Duration) error {
+	var t, signature string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return ErrSignatureInvalid
+		}
+		switch kv[0] {
+		case "t":
+			t = kv[1]
+		case "v1":
+			signature = kv[1]
+		}
+	}
+
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || signature == "" {
+		return ErrSignatureInvalid
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(computeSignature(secret, t, payload))) {
+		return ErrSignatureInvalid
+	}
+
+	if tolerance > 0 && time.Since(time.Unix(unix, 0)) > tolerance {
+		return ErrSignatureExpired
+	}
+
+	return nil
+}
+
+func computeSignature(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,51 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/resonatecoop/id/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsType(t *testing.T) {
+	for _, eventType := range events.Types {
+		assert.True(t, events.IsType(eventType))
+	}
+	assert.False(t, events.IsType("user.unknown"))
+	assert.False(t, events.IsType(""))
+}
+
+func TestWants(t *testing.T) {
+	// subscriptions without events want every event
+	subscription := &events.Subscription{Active: true}
+	assert.True(t, subscription.Wants(events.UserDeleted))
+
+	subscription.Events = []string{events.UserRegistered}
+	assert.True(t, subscription.Wants(events.UserRegistered))
+	assert.False(t, subscription.Wants(events.UserDeleted))
+
+	// inactive subscriptions want nothing
+	subscription.Active = false
+	assert.False(t, subscription.Wants(events.UserRegistered))
+}
+
+func TestSignAndVerify(t *testing.T) {
+	payload := []byte(`{"type":"user.registered"}`)
+
+	header := events.Sign("secret", time.Now(), payload)
+	assert.Nil(t, events.Verify("secret", header, payload, time.Minute))
+
+	// payloads and secrets must match
+	assert.Equal(t, events.ErrSignatureInvalid, events.Verify("other", header, payload, time.Minute))
+	assert.Equal(t, events.ErrSignatureInvalid, events.Verify("secret", header, []byte(`{}`), time.Minute))
+
+	// malformed headers are refused
+	assert.Equal(t, events.ErrSignatureInvalid, events.Verify("secret", "", payload, time.Minute))
+	assert.Equal(t, events.ErrSignatureInvalid, events.Verify("secret", "t=1", payload, time.Minute))
+
+	// old signatures are refused unless tolerance is zero
+	header = events.Sign("secret", time.Now().Add(-time.Hour), payload)
+	assert.Equal(t, events.ErrSignatureExpired, events.Verify("secret", header, payload, time.Minute))
+	assert.Nil(t, events.Verify("secret", header, payload, 0))
+}
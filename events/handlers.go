@@ -0,0 +1,270 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/resonatecoop/id/rbac"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/id/util/response"
+)
+
+var (
+	// ErrForbidden ...
+	ErrForbidden = errors.New("Not allowed to manage webhooks")
+	// ErrUnauthorized ...
+	ErrUnauthorized = errors.New("Invalid or missing access token")
+)
+
+// defaultDeliveriesLimit is the number of deliveries listed by default
+const defaultDeliveriesLimit = 50
+
+// subscriptionRequest is the body of subscription creation and update requests
+type subscriptionRequest struct {
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	Description string   `json:"description"`
+	Active      *bool    `json:"active"`
+}
+
+// subscriptionCreatedResponse is the only time the secret is shown
+type subscriptionCreatedResponse struct {
+	*Subscription
+	Secret string `json:"secret"`
+}
+
+// deliveryResponse describes a delivery along with its event and attempts
+type deliveryResponse struct {
+	*Delivery
+	Event    *Event     `json:"event"`
+	Attempts []*Attempt `json:"attempt_log"`
+}
+
+// Lists subscriptions (GET /v1/webhooks/subscriptions)
+func (s *Service) listSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	subscriptions, err := s.ListSubscriptions(r.Context())
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, map[string]interface{}{"subscriptions": subscriptions}, http.StatusOK)
+}
+
+// Creates a subscription (POST /v1/webhooks/subscriptions), the response
+// holds the secret payloads are signed with
+func (s *Service) createSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	req := new(subscriptionRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	subscription, err := s.CreateSubscription(r.Context(), req.URL, req.Events, req.Description)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, &subscriptionCreatedResponse{
+		Subscription: subscription,
+		Secret:       subscription.Secret,
+	}, http.StatusCreated)
+}
+
+// Returns a subscription (GET /v1/webhooks/subscriptions/{id})
+func (s *Service) getSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	subscription, err := s.FindSubscription(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, subscription, http.StatusOK)
+}
+
+// Updates a subscription (PUT /v1/webhooks/subscriptions/{id}), subscriptions
+// stay active unless told otherwise
+func (s *Service) updateSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	subscription, err := s.FindSubscription(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	req := new(subscriptionRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	active := req.Active == nil || *req.Active
+
+	err = s.UpdateSubscription(r.Context(), subscription, req.URL, req.Events, req.Description, active)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, subscription, http.StatusOK)
+}
+
+// Deletes a subscription and its deliveries (DELETE /v1/webhooks/subscriptions/{id})
+func (s *Service) deleteSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	subscription, err := s.FindSubscription(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	if err := s.DeleteSubscription(r.Context(), subscription); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// Lists the most recent deliveries of a subscription
+// (GET /v1/webhooks/subscriptions/{id}/deliveries?status=failed&limit=50)
+func (s *Service) listDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	subscription, err := s.FindSubscription(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	limit := defaultDeliveriesLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > 500 {
+			response.Error(w, "limit must be between 1 and 500", http.StatusBadRequest)
+			return
+		}
+	}
+
+	deliveries, err := s.ListDeliveries(r.Context(), subscription, r.URL.Query().Get("status"), limit)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, map[string]interface{}{"deliveries": deliveries}, http.StatusOK)
+}
+
+// Returns a delivery with its event and the log of its attempts
+// (GET /v1/webhooks/deliveries/{id})
+func (s *Service) getDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	delivery, event, attempts, err := s.FindDelivery(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, &deliveryResponse{
+		Delivery: delivery,
+		Event:    event,
+		Attempts: attempts,
+	}, http.StatusOK)
+}
+
+// Sends the event of a delivery again (POST /v1/webhooks/deliveries/{id}/replay)
+func (s *Service) replayDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	delivery, _, _, err := s.FindDelivery(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	replay, err := s.Replay(r.Context(), delivery)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, replay, http.StatusAccepted)
+}
+
+// authorize authenticates the bearer token of the request as an admin
+// token, one of the roles of its user must grant the webhooks:manage
+// permission
+func (s *Service) authorize(r *http.Request) error {
+	token, err := util.ParseBearerToken(r)
+	if err != nil {
+		return ErrUnauthorized
+	}
+
+	user, err := s.backend.AuthenticateAdmin(string(token))
+	if err != nil {
+		return ErrUnauthorized
+	}
+
+	allowed, err := s.backend.GetRBACService().HasPermission(r.Context(), user, rbac.PermissionWebhooksManage)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// writeError maps events errors to status codes
+func (s *Service) writeError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrSubscriptionNotFound, ErrDeliveryNotFound:
+		response.Error(w, err.Error(), http.StatusNotFound)
+	case ErrForbidden:
+		response.Error(w, err.Error(), http.StatusForbidden)
+	case ErrInvalidURL, ErrUnknownEvent:
+		response.Error(w, err.Error(), http.StatusBadRequest)
+	case ErrUnauthorized:
+		response.UnauthorizedError(w, err.Error())
+	default:
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,66 @@
+package events
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/resonatecoop/id/util/routes"
+)
+
+// RegisterRoutes registers route handlers for the events service
+func (s *Service) RegisterRoutes(router *mux.Router, prefix string) {
+	subRouter := router.PathPrefix(prefix).Subrouter()
+	routes.AddRoutes(s.GetRoutes(), subRouter)
+}
+
+// GetRoutes returns []routes.Route slice for the events service
+func (s *Service) GetRoutes() []routes.Route {
+	return []routes.Route{
+		{
+			Name:        "webhooks_subscriptions_list",
+			Method:      "GET",
+			Pattern:     "/subscriptions",
+			HandlerFunc: s.listSubscriptionsHandler,
+		},
+		{
+			Name:        "webhooks_subscriptions_create",
+			Method:      "POST",
+			Pattern:     "/subscriptions",
+			HandlerFunc: s.createSubscriptionHandler,
+		},
+		{
+			Name:        "webhooks_subscriptions_get",
+			Method:      "GET",
+			Pattern:     "/subscriptions/{id}",
+			HandlerFunc: s.getSubscriptionHandler,
+		},
+		{
+			Name:        "webhooks_subscriptions_update",
+			Method:      "PUT",
+			Pattern:     "/subscriptions/{id}",
+			HandlerFunc: s.updateSubscriptionHandler,
+		},
+		{
+			Name:        "webhooks_subscriptions_delete",
+			Method:      "DELETE",
+			Pattern:     "/subscriptions/{id}",
+			HandlerFunc: s.deleteSubscriptionHandler,
+		},
+		{
+			Name:        "webhooks_deliveries_list",
+			Method:      "GET",
+			Pattern:     "/subscriptions/{id}/deliveries",
+			HandlerFunc: s.listDeliveriesHandler,
+		},
+		{
+			Name:        "webhooks_deliveries_get",
+			Method:      "GET",
+			Pattern:     "/deliveries/{id}",
+			HandlerFunc: s.getDeliveryHandler,
+		},
+		{
+			Name:        "webhooks_deliveries_replay",
+			Method:      "POST",
+			Pattern:     "/deliveries/{id}/replay",
+			HandlerFunc: s.replayDeliveryHandler,
+		},
+	}
+}
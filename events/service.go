@@ -0,0 +1,327 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/util"
+	"github.com/uptrace/bun"
+)
+
+var (
+	// ErrSubscriptionNotFound ...
+	ErrSubscriptionNotFound = errors.New("Subscription not found")
+	// ErrDeliveryNotFound ...
+	ErrDeliveryNotFound = errors.New("Delivery not found")
+	// ErrInvalidURL ...
+	ErrInvalidURL = errors.New("Subscription URL must be an absolute https URL")
+	// ErrUnknownEvent ...
+	ErrUnknownEvent = errors.New("Unknown event")
+)
+
+// Service struct keeps config and db objects to avoid passing them around
+type Service struct {
+	cnf     *config.Config
+	db      *bun.DB
+	backend Backend
+}
+
+// NewService returns a new Service instance
+func NewService(cnf *config.Config, db *bun.DB) *Service {
+	return &Service{cnf: cnf, db: db}
+}
+
+// GetConfig returns config.Config instance
+func (s *Service) GetConfig() *config.Config {
+	return s.cnf
+}
+
+// UseBackend sets the oauth backend the admin API relies on
+func (s *Service) UseBackend(b Backend) {
+	s.backend = b
+}
+
+// Close stops any running services
+func (s *Service) Close() {}
+
+// Emit records an event along with a delivery for every subscription
+// interested. db is usually the transaction making the change, so events
+// are only sent for changes which were committed.
+func (s *Service) Emit(ctx context.Context, db bun.IDB, eventType string, userID uuid.UUID, data map[string]interface{}) (*Event, error) {
+	if !IsType(eventType) {
+		return nil, ErrUnknownEvent
+	}
+
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	event := &Event{
+		ID:        uuid.New(),
+		Type:      eventType,
+		UserID:    userID,
+		Data:      data,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	_, err := db.NewInsert().
+		Model(event).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptions := []*Subscription{}
+	err = db.NewSelect().
+		Model(&subscriptions).
+		Where("active").
+		Where("cardinality(events) = 0 OR ? = ANY(events)", eventType).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(subscriptions) == 0 {
+		return event, nil
+	}
+
+	deliveries := make([]*Delivery, len(subscriptions))
+	for i, subscription := range subscriptions {
+		deliveries[i] = &Delivery{
+			SubscriptionID: subscription.ID,
+			EventID:        event.ID,
+			Status:         DeliveryPending,
+		}
+	}
+
+	_, err = db.NewInsert().
+		Model(&deliveries).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// ListSubscriptions returns every subscription, oldest first
+func (s *Service) ListSubscriptions(ctx context.Context) ([]*Subscription, error) {
+	subscriptions := []*Subscription{}
+
+	err := s.db.NewSelect().
+		Model(&subscriptions).
+		Order("created_at ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+// FindSubscription looks up a subscription by ID
+func (s *Service) FindSubscription(ctx context.Context, id string) (*Subscription, error) {
+	subscriptionID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrSubscriptionNotFound
+	}
+
+	subscription := new(Subscription)
+	err = s.db.NewSelect().
+		Model(subscription).
+		Where("id = ?", subscriptionID).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+// CreateSubscription subscribes a URL to events, every event when eventTypes
+// is empty. The secret payloads are signed with is generated.
+func (s *Service) CreateSubscription(ctx context.Context, rawURL string, eventTypes []string, description string) (*Subscription, error) {
+	if err := s.validate(rawURL, eventTypes); err != nil {
+		return nil, err
+	}
+
+	secret, err := util.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+
+	subscription := &Subscription{
+		URL:         rawURL,
+		Secret:      secret,
+		Events:      eventTypes,
+		Description: description,
+		Active:      true,
+	}
+
+	_, err = s.db.NewInsert().
+		Model(subscription).
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+// UpdateSubscription changes the URL, events, description and state of a
+// subscription. Pending deliveries of inactive subscriptions wait until it
+// is active again.
+func (s *Service) UpdateSubscription(ctx context.Context, subscription *Subscription, rawURL string, eventTypes []string, description string, active bool) error {
+	if err := s.validate(rawURL, eventTypes); err != nil {
+		return err
+	}
+
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+
+	subscription.URL = rawURL
+	subscription.Events = eventTypes
+	subscription.Description = description
+	subscription.Active = active
+	subscription.UpdatedAt = time.Now().UTC()
+
+	_, err := s.db.NewUpdate().
+		Model(subscription).
+		Column("url", "events", "description", "active", "updated_at").
+		WherePK().
+		Exec(ctx)
+
+	return err
+}
+
+// DeleteSubscription removes a subscription along with its deliveries
+func (s *Service) DeleteSubscription(ctx context.Context, subscription *Subscription) error {
+	_, err := s.db.NewDelete().
+		Model(subscription).
+		WherePK().
+		Exec(ctx)
+
+	return err
+}
+
+// ListDeliveries returns the most recent deliveries of a subscription,
+// status filters them when not empty
+func (s *Service) ListDeliveries(ctx context.Context, subscription *Subscription, status string, limit int) ([]*Delivery, error) {
+	deliveries := []*Delivery{}
+
+	query := s.db.NewSelect().
+		Model(&deliveries).
+		Where("subscription_id = ?", subscription.ID).
+		Order("created_at DESC").
+		Limit(limit)
+
+	if status != "" {
+		query.Where("status = ?", status)
+	}
+
+	if err := query.Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// FindDelivery looks up a delivery by ID along with its event and the log
+// of its attempts
+func (s *Service) FindDelivery(ctx context.Context, id string) (*Delivery, *Event, []*Attempt, error) {
+	deliveryID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, nil, nil, ErrDeliveryNotFound
+	}
+
+	delivery := new(Delivery)
+	err = s.db.NewSelect().
+		Model(delivery).
+		Where("id = ?", deliveryID).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	event := new(Event)
+	err = s.db.NewSelect().
+		Model(event).
+		Where("id = ?", delivery.EventID).
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	attempts := []*Attempt{}
+	err = s.db.NewSelect().
+		Model(&attempts).
+		Where("delivery_id = ?", delivery.ID).
+		Order("created_at ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return delivery, event, attempts, nil
+}
+
+// Replay sends the event of a delivery to its subscription again, e.g. once
+// a subscriber fixed a bug. A new delivery is created so the log of the
+// original one is kept.
+func (s *Service) Replay(ctx context.Context, delivery *Delivery) (*Delivery, error) {
+	replay := &Delivery{
+		SubscriptionID: delivery.SubscriptionID,
+		EventID:        delivery.EventID,
+		Status:         DeliveryPending,
+	}
+
+	_, err := s.db.NewInsert().
+		Model(replay).
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return replay, nil
+}
+
+// validate checks the URL and events of a subscription, plain http is only
+// allowed in development mode
+func (s *Service) validate(rawURL string, eventTypes []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return ErrInvalidURL
+	}
+	if u.Scheme != "https" && !(s.cnf.IsDevelopment && u.Scheme == "http") {
+		return ErrInvalidURL
+	}
+
+	for _, eventType := range eventTypes {
+		if !IsType(eventType) {
+			return ErrUnknownEvent
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package events
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/rbac"
+	"github.com/resonatecoop/id/util/routes"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+// Backend is the part of the oauth service the webhook admin API relies on
+type Backend interface {
+	AuthenticateAdmin(token string) (*model.User, error)
+	GetRBACService() rbac.ServiceInterface
+}
+
+// ServiceInterface defines exported methods
+type ServiceInterface interface {
+	// Exported methods
+	GetConfig() *config.Config
+	UseBackend(b Backend)
+	Emit(ctx context.Context, db bun.IDB, eventType string, userID uuid.UUID, data map[string]interface{}) (*Event, error)
+	ListSubscriptions(ctx context.Context) ([]*Subscription, error)
+	FindSubscription(ctx context.Context, id string) (*Subscription, error)
+	CreateSubscription(ctx context.Context, rawURL string, eventTypes []string, description string) (*Subscription, error)
+	UpdateSubscription(ctx context.Context, subscription *Subscription, rawURL string, eventTypes []string, description string, active bool) error
+	DeleteSubscription(ctx context.Context, subscription *Subscription) error
+	ListDeliveries(ctx context.Context, subscription *Subscription, status string, limit int) ([]*Delivery, error)
+	FindDelivery(ctx context.Context, id string) (*Delivery, *Event, []*Attempt, error)
+	Replay(ctx context.Context, delivery *Delivery) (*Delivery, error)
+	DeliverPending(batchSize int) (int, error)
+	GetRoutes() []routes.Route
+	RegisterRoutes(router *mux.Router, prefix string)
+	Close()
+}
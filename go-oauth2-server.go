@@ -1,10 +1,12 @@
 package main
 
 import (
-	"log"
+	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/resonatecoop/id/cmd"
+	"github.com/resonatecoop/id/log"
 	"github.com/urfave/cli"
 )
 
@@ -25,6 +27,7 @@ func init() {
 		cli.StringFlag{
 			Name:        "configBackend",
 			Value:       "etcd",
+			Usage:       "config backend: etcd, consul or file",
 			Destination: &configBackend,
 		},
 	}
@@ -40,10 +43,516 @@ func main() {
 				return cmd.RunServer(configBackend)
 			},
 		},
+		{
+			Name:  "config",
+			Usage: "inspect the configuration",
+			Subcommands: []cli.Command{
+				{
+					Name:  "dump",
+					Usage: "print the loaded configuration with secrets redacted",
+					Action: func(c *cli.Context) error {
+						return cmd.DumpConfig(configBackend)
+					},
+				},
+				{
+					Name:  "check",
+					Usage: "validate the configuration and check the database can be reached",
+					Action: func(c *cli.Context) error {
+						return cmd.CheckConfig(configBackend)
+					},
+				},
+			},
+		},
+		{
+			Name:  "migrate",
+			Usage: "manage the database schema",
+			Subcommands: []cli.Command{
+				{
+					Name:  "up",
+					Usage: "apply pending migrations",
+					Action: func(c *cli.Context) error {
+						return cmd.MigrateUp(configBackend)
+					},
+				},
+				{
+					Name:  "down",
+					Usage: "roll back the last group of migrations",
+					Action: func(c *cli.Context) error {
+						return cmd.MigrateDown(configBackend)
+					},
+				},
+				{
+					Name:  "status",
+					Usage: "list applied and pending migrations",
+					Action: func(c *cli.Context) error {
+						return cmd.MigrationStatus(configBackend)
+					},
+				},
+			},
+		},
+		{
+			Name:  "fixtures",
+			Usage: "seed the database",
+			Subcommands: []cli.Command{
+				{
+					Name:  "load",
+					Usage: "insert the default roles, scopes and configured clients",
+					Action: func(c *cli.Context) error {
+						return cmd.LoadFixtures(configBackend)
+					},
+				},
+			},
+		},
+		{
+			Name:  "clients",
+			Usage: "manage oauth clients",
+			Subcommands: []cli.Command{
+				{
+					Name:      "create",
+					Usage:     "create a client, a secret is generated unless --secret is set",
+					ArgsUsage: "<client id>",
+					Flags: []cli.Flag{
+						cli.StringFlag{Name: "secret", Usage: "client secret"},
+						cli.StringFlag{Name: "redirect-uri", Usage: "redirect URI"},
+						cli.StringFlag{Name: "name", Usage: "application name"},
+						cli.StringFlag{Name: "hostname", Usage: "application hostname"},
+						cli.StringFlag{Name: "url", Usage: "application URL"},
+					},
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 1); err != nil {
+							return err
+						}
+						return cmd.CreateClient(
+							configBackend,
+							c.Args().First(),
+							c.String("secret"),
+							c.String("redirect-uri"),
+							c.String("name"),
+							c.String("hostname"),
+							c.String("url"),
+						)
+					},
+				},
+				{
+					Name:  "list",
+					Usage: "list clients",
+					Action: func(c *cli.Context) error {
+						return cmd.ListClients(configBackend)
+					},
+				},
+				{
+					Name:      "rotate-secret",
+					Usage:     "generate a new client secret",
+					ArgsUsage: "<client id>",
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 1); err != nil {
+							return err
+						}
+						return cmd.RotateClientSecret(configBackend, c.Args().First())
+					},
+				},
+				{
+					Name:      "delete",
+					Usage:     "delete a client and the tokens issued to it",
+					ArgsUsage: "<client id>",
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 1); err != nil {
+							return err
+						}
+						return cmd.DeleteClient(configBackend, c.Args().First())
+					},
+				},
+				{
+					Name:      "set-scopes",
+					Usage:     "restrict the scopes a client may request, none limits it to the default scope",
+					ArgsUsage: "<client id> [scope...]",
+					Action: func(c *cli.Context) error {
+						if c.NArg() < 1 {
+							return fmt.Errorf("usage: %s %s %s", c.App.Name, c.Command.Name, c.Command.ArgsUsage)
+						}
+						return cmd.SetClientScopes(configBackend, c.Args().First(), c.Args().Tail())
+					},
+				},
+				{
+					Name:      "set-logout",
+					Usage:     "set the URIs a client is sent to and notified at on logout, omitted ones are removed",
+					ArgsUsage: "<client id>",
+					Flags: []cli.Flag{
+						cli.StringSliceFlag{Name: "post-logout-redirect-uri", Usage: "URI users may be sent to once logged out, may be repeated"},
+						cli.StringFlag{Name: "backchannel-uri", Usage: "URI logout tokens are POSTed to"},
+						cli.StringFlag{Name: "frontchannel-uri", Usage: "URI loaded in an iframe on logout"},
+						cli.BoolFlag{Name: "session-required", Usage: "send the issuer and session ID to the front-channel URI and require the sid claim on the back-channel"},
+					},
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 1); err != nil {
+							return err
+						}
+						return cmd.SetClientLogout(
+							configBackend,
+							c.Args().First(),
+							c.StringSlice("post-logout-redirect-uri"),
+							c.String("backchannel-uri"),
+							c.String("frontchannel-uri"),
+							c.Bool("session-required"),
+						)
+					},
+				},
+				{
+					Name:      "set-authorization",
+					Usage:     "set the redirect URIs of a client and how it secures authorization requests, omitted settings are removed",
+					ArgsUsage: "<client id>",
+					Flags: []cli.Flag{
+						cli.StringSliceFlag{Name: "redirect-uri", Usage: "URI users may be sent back to, may be repeated, the redirect URI of the client when omitted"},
+						cli.StringFlag{Name: "jwks-uri", Usage: "https URI of the keys request objects are signed with"},
+						cli.BoolFlag{Name: "require-par", Usage: "only accept pushed authorization requests"},
+						cli.StringFlag{Name: "request-object-signing-alg", Usage: "only accept request objects signed with RS256, PS256 or ES256"},
+					},
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 1); err != nil {
+							return err
+						}
+						return cmd.SetClientAuthorization(
+							configBackend,
+							c.Args().First(),
+							c.StringSlice("redirect-uri"),
+							c.String("jwks-uri"),
+							c.Bool("require-par"),
+							c.String("request-object-signing-alg"),
+						)
+					},
+				},
+				{
+					Name:      "set-token-exchange",
+					Usage:     "let a client exchange the access tokens of users for tokens restricted to resources, omitted settings are removed",
+					ArgsUsage: "<client id>",
+					Flags: []cli.Flag{
+						cli.StringSliceFlag{Name: "subject-client", Usage: "client whose tokens may be exchanged, may be repeated"},
+						cli.StringSliceFlag{Name: "resource", Usage: "registered resource tokens may be exchanged for, may be repeated"},
+						cli.StringSliceFlag{Name: "scope", Usage: "scope exchanged tokens may have, may be repeated"},
+						cli.BoolFlag{Name: "allow-impersonation", Usage: "accept actor tokens of staff allowed to impersonate users"},
+					},
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 1); err != nil {
+							return err
+						}
+						return cmd.SetTokenExchangePolicy(
+							configBackend,
+							c.Args().First(),
+							c.StringSlice("subject-client"),
+							c.StringSlice("resource"),
+							c.StringSlice("scope"),
+							c.Bool("allow-impersonation"),
+						)
+					},
+				},
+				{
+					Name:      "delete-token-exchange",
+					Usage:     "stop a client from exchanging tokens",
+					ArgsUsage: "<client id>",
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 1); err != nil {
+							return err
+						}
+						return cmd.DeleteTokenExchangePolicy(configBackend, c.Args().First())
+					},
+				},
+				{
+					Name:      "verify",
+					Usage:     "mark a client as verified by the co-op, updating its registration withdraws the verification",
+					ArgsUsage: "<client id>",
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 1); err != nil {
+							return err
+						}
+						return cmd.VerifyClient(configBackend, c.Args().First())
+					},
+				},
+				{
+					Name:      "unverify",
+					Usage:     "withdraw the verification of a client",
+					ArgsUsage: "<client id>",
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 1); err != nil {
+							return err
+						}
+						return cmd.UnverifyClient(configBackend, c.Args().First())
+					},
+				},
+			},
+		},
+		{
+			Name:  "registration",
+			Usage: "manage initial access tokens for dynamic client registration",
+			Subcommands: []cli.Command{
+				{
+					Name:  "create-token",
+					Usage: "issue an initial access token",
+					Flags: []cli.Flag{
+						cli.StringFlag{Name: "realm", Value: "default", Usage: "slug of the realm clients are registered in"},
+						cli.StringFlag{Name: "description", Usage: "who the token is for"},
+						cli.DurationFlag{Name: "expires-in", Usage: "token lifetime, e.g. 720h, never expires when omitted"},
+					},
+					Action: func(c *cli.Context) error {
+						return cmd.CreateInitialAccessToken(configBackend, c.String("realm"), c.String("description"), c.Duration("expires-in"))
+					},
+				},
+				{
+					Name:  "list-tokens",
+					Usage: "list initial access tokens",
+					Action: func(c *cli.Context) error {
+						return cmd.ListInitialAccessTokens(configBackend)
+					},
+				},
+				{
+					Name:      "revoke-token",
+					Usage:     "revoke an initial access token",
+					ArgsUsage: "<id>",
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 1); err != nil {
+							return err
+						}
+						return cmd.RevokeInitialAccessToken(configBackend, c.Args().First())
+					},
+				},
+			},
+		},
+		{
+			Name:  "users",
+			Usage: "manage user accounts",
+			Subcommands: []cli.Command{
+				{
+					Name:      "find",
+					Usage:     "print account details",
+					ArgsUsage: "<email>",
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 1); err != nil {
+							return err
+						}
+						return cmd.FindUser(configBackend, c.Args().First())
+					},
+				},
+				{
+					Name:      "confirm-email",
+					Usage:     "mark the email address as confirmed",
+					ArgsUsage: "<email>",
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 1); err != nil {
+							return err
+						}
+						return cmd.ConfirmUserEmail(configBackend, c.Args().First())
+					},
+				},
+				{
+					Name:      "set-password",
+					Usage:     "set the password, read from stdin unless --password is set",
+					ArgsUsage: "<email>",
+					Flags: []cli.Flag{
+						cli.StringFlag{Name: "password", Usage: "new password"},
+					},
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 1); err != nil {
+							return err
+						}
+						return cmd.SetUserPassword(configBackend, c.Args().First(), c.String("password"))
+					},
+				},
+				{
+					Name:      "set-role",
+					Usage:     "replace the roles of a user with a single role",
+					ArgsUsage: "<email> <role id>",
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 2); err != nil {
+							return err
+						}
+						roleID, err := strconv.ParseInt(c.Args().Get(1), 10, 32)
+						if err != nil {
+							return fmt.Errorf("invalid role id %q", c.Args().Get(1))
+						}
+						return cmd.SetUserRole(configBackend, c.Args().First(), int32(roleID))
+					},
+				},
+				{
+					Name:      "lock",
+					Usage:     "prevent logging in until the password is reset",
+					ArgsUsage: "<email>",
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 1); err != nil {
+							return err
+						}
+						return cmd.LockUser(configBackend, c.Args().First())
+					},
+				},
+				{
+					Name:      "impersonations",
+					Usage:     "print who acted as the user, or as whom the user acted, and what was done",
+					ArgsUsage: "<email>",
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 1); err != nil {
+							return err
+						}
+						return cmd.ListImpersonations(configBackend, c.Args().First())
+					},
+				},
+				{
+					Name:  "sync",
+					Usage: "deliver the changes made to users to the user api without waiting for the scheduler",
+					Action: func(c *cli.Context) error {
+						return cmd.SyncUserAPI(configBackend)
+					},
+				},
+			},
+		},
+		{
+			Name:  "tokens",
+			Usage: "manage tokens",
+			Subcommands: []cli.Command{
+				{
+					Name:  "purge-expired",
+					Usage: "delete expired tokens, authorization codes and email tokens",
+					Action: func(c *cli.Context) error {
+						return cmd.PurgeExpiredTokens(configBackend)
+					},
+				},
+				{
+					Name:      "revoke-user",
+					Usage:     "revoke every token issued to a user",
+					ArgsUsage: "<email>",
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 1); err != nil {
+							return err
+						}
+						return cmd.RevokeUserTokens(configBackend, c.Args().First())
+					},
+				},
+			},
+		},
+		{
+			Name:  "scopes",
+			Usage: "manage oauth scopes",
+			Subcommands: []cli.Command{
+				{
+					Name:  "list",
+					Usage: "list scopes",
+					Action: func(c *cli.Context) error {
+						return cmd.ListScopes(configBackend)
+					},
+				},
+				{
+					Name:      "add",
+					Usage:     "add a scope",
+					ArgsUsage: "<name>",
+					Flags: []cli.Flag{
+						cli.StringFlag{Name: "description", Usage: "scope description"},
+						cli.BoolFlag{Name: "default", Usage: "grant the scope when none is requested"},
+					},
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 1); err != nil {
+							return err
+						}
+						return cmd.AddScope(configBackend, c.Args().First(), c.String("description"), c.Bool("default"))
+					},
+				},
+			},
+		},
+		{
+			Name:  "roles",
+			Usage: "manage roles and the roles of users",
+			Subcommands: []cli.Command{
+				{
+					Name:  "list",
+					Usage: "list roles and their permissions",
+					Action: func(c *cli.Context) error {
+						return cmd.ListRoles(configBackend)
+					},
+				},
+				{
+					Name:      "create",
+					Usage:     "create a role",
+					ArgsUsage: "<name> [permission...]",
+					Flags: []cli.Flag{
+						cli.StringFlag{Name: "description", Usage: "role description"},
+					},
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 1); err != nil {
+							return err
+						}
+						return cmd.CreateRole(configBackend, c.Args().First(), c.String("description"), c.Args().Tail())
+					},
+				},
+				{
+					Name:      "grant",
+					Usage:     "grant a role to a user",
+					ArgsUsage: "<email> <role>",
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 2); err != nil {
+							return err
+						}
+						return cmd.GrantRole(configBackend, c.Args().First(), c.Args().Get(1))
+					},
+				},
+				{
+					Name:      "revoke",
+					Usage:     "revoke a role from a user",
+					ArgsUsage: "<email> <role>",
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 2); err != nil {
+							return err
+						}
+						return cmd.RevokeRole(configBackend, c.Args().First(), c.Args().Get(1))
+					},
+				},
+			},
+		},
+		{
+			Name:  "resources",
+			Usage: "manage resources tokens can be restricted to",
+			Subcommands: []cli.Command{
+				{
+					Name:  "list",
+					Usage: "list resources",
+					Action: func(c *cli.Context) error {
+						return cmd.ListResources(configBackend)
+					},
+				},
+				{
+					Name:      "add",
+					Usage:     "register a resource, its scopes are prefixed with the namespace",
+					ArgsUsage: "<uri> <namespace>",
+					Flags: []cli.Flag{
+						cli.StringFlag{Name: "description", Usage: "resource description"},
+					},
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 2); err != nil {
+							return err
+						}
+						return cmd.AddResource(configBackend, c.Args().First(), c.Args().Get(1), c.String("description"))
+					},
+				},
+				{
+					Name:      "delete",
+					Usage:     "remove a resource",
+					ArgsUsage: "<uri>",
+					Action: func(c *cli.Context) error {
+						if err := requireArgs(c, 1); err != nil {
+							return err
+						}
+						return cmd.DeleteResource(configBackend, c.Args().First())
+					},
+				},
+			},
+		},
 	}
 
 	// Run the CLI app
 	if err := cliApp.Run(os.Args); err != nil {
-		log.Fatal(err)
+		log.FATAL.Fatal(err)
+	}
+}
+
+// requireArgs makes sure a command got n arguments
+func requireArgs(c *cli.Context, n int) error {
+	if c.NArg() != n {
+		return fmt.Errorf("usage: %s %s %s", c.App.Name, c.Command.Name, c.Command.ArgsUsage)
 	}
+	return nil
 }
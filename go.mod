@@ -3,6 +3,7 @@ module github.com/resonatecoop/id
 go 1.16
 
 require (
+	github.com/BurntSushi/toml v0.4.1
 	github.com/RichardKnop/jsonhal v0.0.0-20181101035658-9ef775cfa6bf
 	github.com/RichardKnop/logging v0.0.0-20190827224416-1a693bdd4fae
 	github.com/apokalyptik/phpass v0.0.0-20140806224508-cd4a744fe20c
@@ -10,6 +11,11 @@ require (
 	github.com/didip/tollbooth_negroni v0.0.0-20170928042109-a4e3efc33255
 	github.com/dlclark/regexp2 v1.4.0 // indirect
 	github.com/form3tech-oss/jwt-go v3.2.3+incompatible
+	github.com/fsnotify/fsnotify v1.5.1
+	github.com/ghodss/yaml v1.0.0
+	github.com/go-asn1-ber/asn1-ber v1.5.1
+	github.com/go-ldap/ldap/v3 v3.4.1
+	github.com/go-logr/stdr v1.2.2 // indirect
 	github.com/go-openapi/runtime v0.19.29
 	github.com/go-openapi/strfmt v0.20.1
 	github.com/google/uuid v1.3.0
@@ -20,7 +26,6 @@ require (
 	github.com/grpc-ecosystem/grpc-gateway/v2 v2.5.0 // indirect
 	github.com/hashicorp/consul/api v1.8.1
 	github.com/jackc/pgx/v4 v4.12.0
-	github.com/json-iterator/go v1.1.11 // indirect
 	github.com/lib/pq v1.10.2
 	github.com/mailgun/mailgun-go/v4 v4.5.1
 	github.com/mitchellh/mapstructure v1.4.1 // indirect
@@ -28,6 +33,7 @@ require (
 	github.com/pariz/gountries v0.0.0-20200430155801-1c6a393df9c7
 	github.com/patrickmn/go-cache v2.1.0+incompatible // indirect
 	github.com/phyber/negroni-gzip v1.0.0
+	github.com/prometheus/client_golang v1.11.1
 	github.com/resonatecoop/user-api v1.0.0-10
 	github.com/resonatecoop/user-api-client v0.0.0-20220414135746-4287bec2bff3
 	github.com/rs/xid v1.3.0
@@ -36,7 +42,6 @@ require (
 	github.com/stretchr/testify v1.7.0
 	github.com/stripe/stripe-go/v72 v72.77.0
 	github.com/test-go/testify v1.1.4 // indirect
-	github.com/thanhpk/randstr v1.0.4
 	github.com/trustelem/zxcvbn v1.0.1
 	github.com/unrolled/secure v1.0.9
 	github.com/uptrace/bun v1.0.22
@@ -48,6 +53,12 @@ require (
 	go.etcd.io/etcd/api/v3 v3.5.0
 	go.etcd.io/etcd/client/pkg/v3 v3.5.0 // indirect
 	go.etcd.io/etcd/client/v3 v3.5.0-rc.0
+	go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.28.0
+	go.opentelemetry.io/otel v1.3.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.3.0
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.3.0
+	go.opentelemetry.io/otel/sdk v1.3.0
+	go.opentelemetry.io/otel/trace v1.3.0
 	go.uber.org/atomic v1.8.0 // indirect
 	go.uber.org/multierr v1.7.0 // indirect
 	go.uber.org/zap v1.18.1 // indirect
@@ -55,6 +66,5 @@ require (
 	golang.org/x/net v0.0.0-20210614182718-04defd469f4e
 	golang.org/x/time v0.0.0-20210220033141-f8bda1e9f3ba // indirect
 	google.golang.org/genproto v0.0.0-20210714021259-044028024a4f // indirect
-	google.golang.org/grpc v1.40.0-dev.0.20210623211556-d9eb12feed7a // indirect
 	gopkg.in/tylerb/graceful.v1 v1.2.15
 )
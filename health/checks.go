@@ -0,0 +1,74 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/tracing"
+	"github.com/stripe/stripe-go/v72"
+	"github.com/stripe/stripe-go/v72/client"
+)
+
+var (
+	// ErrMailgunNotConfigured ...
+	ErrMailgunNotConfigured = errors.New("Mailgun domain or key is not configured")
+	// ErrStripeNotConfigured ...
+	ErrStripeNotConfigured = errors.New("Stripe secret key is not configured")
+)
+
+// checkDatabase makes sure the database accepts queries
+func (s *Service) checkDatabase(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "SELECT 1=1")
+	return err
+}
+
+// checkConfigBackend makes sure the etcd or consul backend is reachable
+func (s *Service) checkConfigBackend(ctx context.Context) error {
+	return config.CheckBackendHealth(ctx)
+}
+
+// checkUserAPI makes sure the user api gateway accepts connections
+func (s *Service) checkUserAPI(ctx context.Context) error {
+	address := fmt.Sprintf("%s%s", s.cnf.UserAPIHostname, s.cnf.UserAPIPort)
+
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+// checkMailgun makes sure the Mailgun key is valid for the configured domain
+func (s *Service) checkMailgun(ctx context.Context) error {
+	if s.cnf.Mailgun.Domain == "" || s.cnf.Mailgun.Key == "" {
+		return ErrMailgunNotConfigured
+	}
+
+	mg := tracing.NewMailgun(s.cnf.Mailgun.Domain, s.cnf.Mailgun.Key)
+
+	_, err := mg.GetDomain(ctx, s.cnf.Mailgun.Domain)
+
+	return err
+}
+
+// checkStripe makes sure the Stripe secret key is valid
+func (s *Service) checkStripe(ctx context.Context) error {
+	if s.cnf.Stripe.Secret == "" {
+		return ErrStripeNotConfigured
+	}
+
+	sc := client.New(s.cnf.Stripe.Secret, nil)
+
+	params := &stripe.BalanceParams{}
+	params.Context = ctx
+
+	_, err := sc.Balance.Get(params)
+
+	return err
+}
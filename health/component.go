@@ -0,0 +1,69 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	statusUp   = "up"
+	statusDown = "down"
+)
+
+// componentStatus is the outcome of a component check
+type componentStatus struct {
+	Status    string    `json:"status"`
+	Critical  bool      `json:"critical"`
+	Latency   float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// component is a dependency checked by the readiness endpoint. Only critical
+// components make the service unready when they are down, the others are
+// reported for information.
+type component struct {
+	name     string
+	critical bool
+	// ttl returns for how long the last result is reused, checks are not
+	// cached when nil. Concurrent probes wait for the running check so
+	// external services are hit at most once per ttl.
+	ttl   func() time.Duration
+	check func(ctx context.Context) error
+
+	mu   sync.Mutex
+	last *componentStatus
+}
+
+// run checks the component, or returns the cached result if still fresh
+func (c *component) run(ctx context.Context, timeout time.Duration) componentStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.last != nil && c.ttl != nil && time.Since(c.last.CheckedAt) < c.ttl() {
+		return *c.last
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.check(ctx)
+
+	status := &componentStatus{
+		Status:    statusUp,
+		Critical:  c.critical,
+		Latency:   float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: start,
+	}
+
+	if err != nil {
+		status.Status = statusDown
+		status.Error = err.Error()
+	}
+
+	c.last = status
+
+	return *status
+}
@@ -1,11 +1,18 @@
 package health
 
 import (
+	"context"
 	"net/http"
+	"sync"
 
 	"github.com/resonatecoop/id/util/response"
 )
 
+const (
+	statusReady   = "ready"
+	statusUnready = "unready"
+)
+
 // Handles health check requests (GET /v1/health)
 func (s *Service) healthcheck(w http.ResponseWriter, r *http.Request) {
 	_, err := s.db.Exec("SELECT 1=1")
@@ -16,7 +23,63 @@ func (s *Service) healthcheck(w http.ResponseWriter, r *http.Request) {
 		healthy = true
 	}
 
+	code := http.StatusOK
+	if !healthy {
+		code = http.StatusServiceUnavailable
+	}
+
 	response.WriteJSON(w, map[string]interface{}{
 		"healthy": healthy,
-	}, 200)
+	}, code)
+}
+
+// Handles liveness probes (GET /v1/health/live)
+// The process is alive as long as it can serve requests,
+// dependencies are checked by the readiness probe only
+func (s *Service) liveness(w http.ResponseWriter, r *http.Request) {
+	response.WriteJSON(w, map[string]interface{}{
+		"status": statusUp,
+	}, http.StatusOK)
+}
+
+// Handles readiness probes (GET /v1/health/ready)
+func (s *Service) readiness(w http.ResponseWriter, r *http.Request) {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		statuses = make(map[string]componentStatus, len(s.components))
+		ready    = true
+	)
+
+	for _, c := range s.components {
+		wg.Add(1)
+
+		go func(c *component) {
+			defer wg.Done()
+
+			// results may be cached, do not tie them to this request
+			status := c.run(context.Background(), s.timeout())
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			statuses[c.name] = status
+
+			if c.critical && status.Status != statusUp {
+				ready = false
+			}
+		}(c)
+	}
+
+	wg.Wait()
+
+	status, code := statusReady, http.StatusOK
+	if !ready {
+		status, code = statusUnready, http.StatusServiceUnavailable
+	}
+
+	response.WriteJSON(w, map[string]interface{}{
+		"status":     status,
+		"components": statuses,
+	}, code)
 }
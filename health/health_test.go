@@ -0,0 +1,127 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type readinessResponse struct {
+	Status     string `json:"status"`
+	Components map[string]struct {
+		Status   string `json:"status"`
+		Critical bool   `json:"critical"`
+		Error    string `json:"error"`
+	} `json:"components"`
+}
+
+func newTestConfig() *config.Config {
+	cnf := *config.Cnf
+	cnf.Health.Timeout = 1
+	return &cnf
+}
+
+func ready(t *testing.T, s *health.Service) (int, *readinessResponse) {
+	router := mux.NewRouter()
+	s.RegisterRoutes(router, "/v1")
+
+	r, err := http.NewRequest("GET", "http://1.2.3.4/v1/health/ready", nil)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	res := new(readinessResponse)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), res))
+
+	return w.Code, res
+}
+
+func up(ctx context.Context) error {
+	return nil
+}
+
+func down(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestReadiness(t *testing.T) {
+	s := health.NewTestService(newTestConfig(),
+		health.Check{Name: "database", Critical: true, Check: up},
+		health.Check{Name: "stripe", Check: down},
+	)
+
+	// Non critical components do not make the service unready
+	code, res := ready(t, s)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", res.Status)
+	assert.Equal(t, "up", res.Components["database"].Status)
+	assert.Equal(t, "down", res.Components["stripe"].Status)
+	assert.Equal(t, "connection refused", res.Components["stripe"].Error)
+
+	s = health.NewTestService(newTestConfig(),
+		health.Check{Name: "database", Critical: true, Check: down},
+		health.Check{Name: "stripe", Check: up},
+	)
+
+	code, res = ready(t, s)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unready", res.Status)
+	assert.True(t, res.Components["database"].Critical)
+	assert.Equal(t, "down", res.Components["database"].Status)
+}
+
+func TestReadinessTimeout(t *testing.T) {
+	s := health.NewTestService(newTestConfig(),
+		health.Check{Name: "config_backend", Critical: true, Check: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	)
+
+	start := time.Now()
+	code, res := ready(t, s)
+
+	assert.True(t, time.Since(start) < 5*time.Second)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "down", res.Components["config_backend"].Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), res.Components["config_backend"].Error)
+}
+
+func TestReadinessCache(t *testing.T) {
+	var cached, uncached int32
+
+	s := health.NewTestService(newTestConfig(),
+		health.Check{Name: "mailgun", TTL: time.Minute, Check: func(ctx context.Context) error {
+			if atomic.AddInt32(&cached, 1) > 1 {
+				return nil
+			}
+			return errors.New("invalid key")
+		}},
+		health.Check{Name: "database", Critical: true, Check: func(ctx context.Context) error {
+			atomic.AddInt32(&uncached, 1)
+			return nil
+		}},
+	)
+
+	for i := 0; i < 3; i++ {
+		_, res := ready(t, s)
+		// the first result is reused until it expires
+		assert.Equal(t, "down", res.Components["mailgun"].Status)
+		assert.Equal(t, "invalid key", res.Components["mailgun"].Error)
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&cached))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&uncached))
+}
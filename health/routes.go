@@ -20,5 +20,17 @@ func (s *Service) GetRoutes() []routes.Route {
 			Pattern:     "/health",
 			HandlerFunc: s.healthcheck,
 		},
+		{
+			Name:        "health_liveness",
+			Method:      "GET",
+			Pattern:     "/health/live",
+			HandlerFunc: s.liveness,
+		},
+		{
+			Name:        "health_readiness",
+			Method:      "GET",
+			Pattern:     "/health/ready",
+			HandlerFunc: s.readiness,
+		},
 	}
 }
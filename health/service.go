@@ -1,18 +1,68 @@
 package health
 
 import (
+	"time"
+
+	"github.com/resonatecoop/id/config"
 	"github.com/uptrace/bun"
 )
 
-// Service struct keeps db object to avoid passing it around
+// Service struct keeps config and db objects to avoid passing them around
 type Service struct {
-	db *bun.DB
+	cnf        *config.Config
+	db         *bun.DB
+	components []*component
 }
 
 // NewService returns a new Service instance
-func NewService(db *bun.DB) *Service {
-	return &Service{db: db}
+func NewService(cnf *config.Config, db *bun.DB) *Service {
+	s := &Service{cnf: cnf, db: db}
+
+	s.components = []*component{
+		{
+			name:     "database",
+			critical: true,
+			check:    s.checkDatabase,
+		},
+		{
+			name:     "config_backend",
+			critical: true,
+			ttl:      s.cacheTTL,
+			check:    s.checkConfigBackend,
+		},
+		{
+			// changes reach the user api through the outbox, it being
+			// down delays them without failing requests
+			name:  "user_api",
+			ttl:   s.cacheTTL,
+			check: s.checkUserAPI,
+		},
+		{
+			name:  "mailgun",
+			ttl:   s.credentialsCacheTTL,
+			check: s.checkMailgun,
+		},
+		{
+			name:  "stripe",
+			ttl:   s.credentialsCacheTTL,
+			check: s.checkStripe,
+		},
+	}
+
+	return s
 }
 
 // Close stops any running services
 func (s *Service) Close() {}
+
+func (s *Service) timeout() time.Duration {
+	return time.Duration(s.cnf.Health.Timeout) * time.Second
+}
+
+func (s *Service) cacheTTL() time.Duration {
+	return time.Duration(s.cnf.Health.CacheTTL) * time.Second
+}
+
+func (s *Service) credentialsCacheTTL() time.Duration {
+	return time.Duration(s.cnf.Health.CredentialsCacheTTL) * time.Second
+}
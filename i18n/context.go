@@ -0,0 +1,21 @@
+package i18n
+
+import "context"
+
+type contextKey int
+
+const localeKey contextKey = 0
+
+// NewContext returns a copy of ctx carrying the locale
+func NewContext(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey, locale)
+}
+
+// FromContext returns the locale of a request, requests which did not go
+// through the middleware use DefaultLocale
+func FromContext(ctx context.Context) string {
+	if locale, ok := ctx.Value(localeKey).(string); ok && locale != "" {
+		return locale
+	}
+	return DefaultLocale
+}
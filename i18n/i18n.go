@@ -0,0 +1,154 @@
+// Package i18n translates the web app. Messages are identified by their
+// English text, the catalogs in locales/ map them to their translation in
+// every other supported language.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLocale is the language messages are written in
+const DefaultLocale = "en"
+
+// Locale is a supported language
+type Locale struct {
+	Code string
+	Name string // in the language itself
+}
+
+// Locales lists the supported languages
+var Locales = []Locale{
+	{Code: "en", Name: "English"},
+	{Code: "fr", Name: "Français"},
+	{Code: "de", Name: "Deutsch"},
+}
+
+//go:embed locales/*.json
+var catalogFS embed.FS
+
+// catalogs maps locales to their messages, English needs none
+var catalogs = mustLoadCatalogs()
+
+func mustLoadCatalogs() map[string]map[string]string {
+	result := map[string]map[string]string{}
+
+	for _, locale := range Locales {
+		if locale.Code == DefaultLocale {
+			continue
+		}
+
+		data, err := catalogFS.ReadFile("locales/" + locale.Code + ".json")
+		if err != nil {
+			panic(err)
+		}
+
+		messages := map[string]string{}
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("locales/%s.json: %v", locale.Code, err))
+		}
+
+		result[locale.Code] = messages
+	}
+
+	return result
+}
+
+// IsSupported tells whether a locale is one of Locales
+func IsSupported(locale string) bool {
+	for _, l := range Locales {
+		if l.Code == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the supported locale of a language tag such as fr-CH
+func Match(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if !IsSupported(tag) {
+		return "", false
+	}
+	return tag, true
+}
+
+// Lookup returns the translation of a message, English messages are their
+// own translation
+func Lookup(locale, msgid string) (string, bool) {
+	if locale == DefaultLocale {
+		return msgid, true
+	}
+	msgstr, ok := catalogs[locale][msgid]
+	return msgstr, ok && msgstr != ""
+}
+
+// T translates a message, messages missing from the catalog of the locale
+// are left in English. Arguments are formatted as per fmt.Sprintf.
+func T(locale, msgid string, args ...interface{}) string {
+	msgstr, ok := Lookup(locale, msgid)
+	if !ok {
+		msgstr = msgid
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msgstr, args...)
+	}
+	return msgstr
+}
+
+// Negotiate picks the supported locale the user agent prefers from an
+// Accept-Language header, DefaultLocale when none is acceptable
+func Negotiate(acceptLanguage string) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		if q <= 0 {
+			continue
+		}
+
+		tags = append(tags, weighted{tag: tag, q: q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	for _, t := range tags {
+		if t.tag == "*" {
+			return DefaultLocale
+		}
+		if locale, ok := Match(t.tag); ok {
+			return locale
+		}
+	}
+
+	return DefaultLocale
+}
@@ -0,0 +1,155 @@
+package i18n_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/i18n"
+	"github.com/resonatecoop/id/oauth"
+	pass "github.com/resonatecoop/id/util/password"
+	"github.com/resonatecoop/id/web"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	for acceptLanguage, expected := range map[string]string{
+		"":                               "en",
+		"fr":                             "fr",
+		"de-CH":                          "de",
+		"fr-CH, fr;q=0.9, en;q=0.8":      "fr",
+		"es, de;q=0.5, fr;q=0.7":         "fr",
+		"en;q=0.2, de;q=0.9":             "de",
+		"fr;q=0, de;q=0.1":               "de",
+		"es, it":                         "en",
+		"es, *;q=0.5, de;q=0.1":          "en",
+		"pt_BR, DE":                      "de",
+		"de;q=invalid, fr;q=0.5":         "de",
+		" , ;q=1, fr":                    "fr",
+		"en-GB,en;q=0.9,fr;q=0.8,de;q=0": "en",
+	} {
+		assert.Equal(t, expected, i18n.Negotiate(acceptLanguage), acceptLanguage)
+	}
+}
+
+func TestT(t *testing.T) {
+	assert.Equal(t, "Mot de passe", i18n.T("fr", "Password"))
+	assert.Equal(t, "Passwort", i18n.T("de", "Password"))
+	assert.Equal(t, "Password", i18n.T("en", "Password"))
+	assert.Equal(t, "Continuer vers Resonate", i18n.T("fr", "Continue to %s", "Resonate"))
+
+	// Messages missing from a catalog are left in English
+	assert.Equal(t, "Unknown message", i18n.T("fr", "Unknown message"))
+	assert.Equal(t, "Unknown message", i18n.T("es", "Unknown message"))
+}
+
+func TestMiddleware(t *testing.T) {
+	cnf := &config.Config{}
+	middleware := i18n.NewMiddleware(cnf)
+
+	serve := func(r *http.Request) (*httptest.ResponseRecorder, string) {
+		var locale string
+		w := httptest.NewRecorder()
+		middleware.ServeHTTP(w, r, func(w http.ResponseWriter, r *http.Request) {
+			locale = i18n.FromContext(r.Context())
+		})
+		return w, locale
+	}
+
+	r := httptest.NewRequest("GET", "/web/login", nil)
+	r.Header.Set("Accept-Language", "de-DE,de;q=0.9")
+	w, locale := serve(r)
+	assert.Equal(t, "de", locale)
+	assert.Equal(t, "de", w.Header().Get("Content-Language"))
+	assert.Empty(t, w.Result().Cookies())
+
+	// The language picked is remembered
+	r = httptest.NewRequest("GET", "/web/login?lang=fr", nil)
+	r.Header.Set("Accept-Language", "de-DE,de;q=0.9")
+	w, locale = serve(r)
+	assert.Equal(t, "fr", locale)
+	cookies := w.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, i18n.CookieName, cookies[0].Name)
+		assert.Equal(t, "fr", cookies[0].Value)
+	}
+
+	r = httptest.NewRequest("GET", "/web/login", nil)
+	r.Header.Set("Accept-Language", "de-DE,de;q=0.9")
+	r.AddCookie(cookies[0])
+	_, locale = serve(r)
+	assert.Equal(t, "fr", locale)
+
+	// Unsupported languages are ignored
+	r = httptest.NewRequest("GET", "/web/login?lang=xx", nil)
+	r.AddCookie(&http.Cookie{Name: i18n.CookieName, Value: "yy"})
+	w, locale = serve(r)
+	assert.Equal(t, "en", locale)
+	assert.Empty(t, w.Result().Cookies())
+}
+
+// TestCatalogs checks every message of the templates and the errors users
+// get to see is translated, with the same format verbs
+func TestCatalogs(t *testing.T) {
+	msgids := map[string]bool{}
+
+	files, err := filepath.Glob("../web/*/*.html")
+	if !assert.Nil(t, err) || !assert.NotEmpty(t, files) {
+		return
+	}
+
+	re := regexp.MustCompile(`\bt "([^"]+)"`)
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if !assert.Nil(t, err) {
+			return
+		}
+		for _, match := range re.FindAllStringSubmatch(string(data), -1) {
+			msgids[match[1]] = true
+		}
+	}
+
+	for _, err := range []error{
+		oauth.ErrEmailNotFound,
+		oauth.ErrInvalidUserPassword,
+		oauth.ErrUsernameTaken,
+		oauth.ErrUserNotFound,
+		oauth.ErrEmailInvalid,
+		oauth.ErrEmailNotConfirmed,
+		oauth.ErrCountryNotFound,
+		oauth.ErrLoginTooShort,
+		oauth.ErrLoginTooLong,
+		oauth.ErrAccountPendingDeletion,
+		oauth.ErrEmailTokenInvalid,
+		oauth.ErrLocaleNotSupported,
+		oauth.ErrInvalidRedirectURI,
+		oauth.ErrInvalidRequestURI,
+		oauth.ErrInvalidRequestObject,
+		oauth.ErrPushedAuthorizationRequired,
+		oauth.ErrImpersonationNotAllowed,
+		oauth.ErrImpersonationReasonRequired,
+		oauth.ErrImpersonating,
+		pass.ErrPasswordTooShort,
+		pass.ErrPasswordTooLong,
+		pass.ErrPasswordTooWeak,
+		web.ErrPasswordMismatch,
+		web.ErrInvalidPassword,
+	} {
+		msgids[err.Error()] = true
+	}
+
+	for msgid := range msgids {
+		for _, locale := range i18n.Locales {
+			msgstr, ok := i18n.Lookup(locale.Code, msgid)
+			if assert.True(t, ok, "%q is not translated to %s", msgid, locale.Code) {
+				assert.Equal(t, strings.Count(msgid, "%"), strings.Count(msgstr, "%"), msgid)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package i18n
+
+import (
+	"net/http"
+
+	"github.com/resonatecoop/id/config"
+)
+
+const (
+	// QueryParam switches the language of a page, e.g. /web/login?lang=fr
+	QueryParam = "lang"
+	// CookieName is the cookie remembering the language a user picked
+	CookieName = "lang"
+	// cookieMaxAge is the number of seconds the language is remembered for
+	cookieMaxAge = 365 * 24 * 60 * 60
+)
+
+// Middleware is a middleware handler adding the locale of a request to its
+// context. The language picked with the lang query parameter, which is
+// remembered in a cookie, wins over the Accept-Language header.
+type Middleware struct {
+	cnf *config.Config
+}
+
+// NewMiddleware returns a new Middleware instance
+func NewMiddleware(cnf *config.Config) *Middleware {
+	return &Middleware{cnf: cnf}
+}
+
+func (m *Middleware) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	locale, ok := Match(r.URL.Query().Get(QueryParam))
+	if ok {
+		SetCookie(rw, m.cnf, locale)
+	} else if cookie, err := r.Cookie(CookieName); err == nil {
+		locale, ok = Match(cookie.Value)
+	}
+	if !ok {
+		locale = Negotiate(r.Header.Get("Accept-Language"))
+	}
+
+	rw.Header().Add("Vary", "Accept-Language")
+	rw.Header().Set("Content-Language", locale)
+
+	next(rw, r.WithContext(NewContext(r.Context(), locale)))
+}
+
+// SetCookie remembers the language of a user in their browser
+func SetCookie(rw http.ResponseWriter, cnf *config.Config, locale string) {
+	http.SetCookie(rw, &http.Cookie{
+		Name:     CookieName,
+		Value:    locale,
+		Path:     "/",
+		Domain:   cnf.Session.Domain,
+		MaxAge:   cookieMaxAge,
+		Secure:   cnf.Session.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
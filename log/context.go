@@ -0,0 +1,85 @@
+package log
+
+import (
+	"context"
+
+	"github.com/RichardKnop/logging"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type contextKey int
+
+const requestIDKey contextKey = 0
+
+// Entry carries fields added to every line it logs, it exposes
+// the same level loggers as the package
+type Entry struct {
+	fields Fields
+
+	DEBUG   logging.LoggerInterface
+	INFO    logging.LoggerInterface
+	WARNING logging.LoggerInterface
+	ERROR   logging.LoggerInterface
+	FATAL   logging.LoggerInterface
+}
+
+// WithFields returns an entry logging fields on every line
+func WithFields(fields Fields) *Entry {
+	if custom != nil {
+		return &Entry{
+			fields:  fields,
+			DEBUG:   custom,
+			INFO:    custom,
+			WARNING: custom,
+			ERROR:   custom,
+			FATAL:   custom,
+		}
+	}
+
+	return &Entry{
+		fields:  fields,
+		DEBUG:   &levelLogger{lvl: DebugLevel, fields: fields},
+		INFO:    &levelLogger{lvl: InfoLevel, fields: fields},
+		WARNING: &levelLogger{lvl: WarningLevel, fields: fields},
+		ERROR:   &levelLogger{lvl: ErrorLevel, fields: fields},
+		FATAL:   &levelLogger{lvl: FatalLevel, fields: fields},
+	}
+}
+
+// With returns a copy of the entry with an additional field
+func (e *Entry) With(key string, value interface{}) *Entry {
+	fields := make(Fields, len(e.fields)+1)
+	for k, v := range e.fields {
+		fields[k] = v
+	}
+	fields[key] = value
+
+	return WithFields(fields)
+}
+
+// FromContext returns an entry carrying the request ID and the trace ID
+// found in ctx, so lines logged while serving a request can be correlated
+func FromContext(ctx context.Context) *Entry {
+	fields := Fields{}
+
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		fields["request_id"] = requestID
+	}
+
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		fields["trace_id"] = spanContext.TraceID().String()
+	}
+
+	return WithFields(fields)
+}
+
+// ContextWithRequestID returns a copy of ctx carrying requestID
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, if any
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
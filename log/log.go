@@ -1,26 +1,73 @@
 package log
 
 import (
+	"io"
+	"os"
+
 	"github.com/RichardKnop/logging"
 )
 
 var (
-	logger = logging.New(nil, nil, new(logging.ColouredFormatter))
+	std = newLogger(os.Stdout)
+
+	// custom is the logger set with Set, if any
+	custom logging.LoggerInterface
 
+	// DEBUG ...
+	DEBUG logging.LoggerInterface = &levelLogger{lvl: DebugLevel}
 	// INFO ...
-	INFO = logger[logging.INFO]
+	INFO logging.LoggerInterface = &levelLogger{lvl: InfoLevel}
 	// WARNING ...
-	WARNING = logger[logging.WARNING]
+	WARNING logging.LoggerInterface = &levelLogger{lvl: WarningLevel}
 	// ERROR ...
-	ERROR = logger[logging.ERROR]
+	ERROR logging.LoggerInterface = &levelLogger{lvl: ErrorLevel}
 	// FATAL ...
-	FATAL = logger[logging.FATAL]
+	FATAL logging.LoggerInterface = &levelLogger{lvl: FatalLevel}
 )
 
 // Set sets a custom logger
 func Set(l logging.LoggerInterface) {
+	custom = l
+	DEBUG = l
 	INFO = l
 	WARNING = l
 	ERROR = l
 	FATAL = l
 }
+
+// SetLevel sets the minimum level of logged messages, it can be called
+// at any time to change the verbosity of a running server
+func SetLevel(lvl Level) {
+	std.setLevel(lvl)
+}
+
+// GetLevel returns the minimum level of logged messages
+func GetLevel() Level {
+	return std.getLevel()
+}
+
+// SetFormat sets the output format, FormatJSON or FormatConsole
+func SetFormat(format string) error {
+	return std.setFormat(format)
+}
+
+// SetOutput sets the writer log lines are written to
+func SetOutput(w io.Writer) {
+	std.setOutput(w)
+}
+
+// Configure sets both the level and the format from their names
+func Configure(level, format string) error {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	if err := SetFormat(format); err != nil {
+		return err
+	}
+
+	SetLevel(lvl)
+
+	return nil
+}
@@ -1,9 +1,15 @@
 package log_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/resonatecoop/id/log"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestDefaultLogger(t *testing.T) {
@@ -12,3 +18,56 @@ func TestDefaultLogger(t *testing.T) {
 	log.ERROR.Print("should not panic")
 	log.FATAL.Print("should not panic")
 }
+
+func TestJSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stdout)
+
+	assert.NoError(t, log.SetFormat(log.FormatJSON))
+	defer log.SetFormat(log.FormatConsole)
+
+	ctx := log.ContextWithRequestID(context.Background(), "req-1")
+	log.FromContext(ctx).With("user_id", 42).ERROR.Printf("login failed for %s", "john@example.com")
+
+	line := make(map[string]interface{})
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+
+	assert.Equal(t, "error", line["level"])
+	assert.Equal(t, "login failed for j***@example.com", line["msg"])
+	assert.Equal(t, "req-1", line["request_id"])
+	assert.Equal(t, float64(42), line["user_id"])
+	assert.True(t, strings.HasPrefix(line["caller"].(string), "log_test.go:"))
+}
+
+func TestSetLevel(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stdout)
+
+	defer log.SetLevel(log.GetLevel())
+
+	log.DEBUG.Print("hidden")
+	assert.Empty(t, buf.String())
+
+	log.SetLevel(log.DebugLevel)
+	log.DEBUG.Print("shown")
+	assert.Contains(t, buf.String(), "DEBUG")
+	assert.Contains(t, buf.String(), "shown")
+
+	buf.Reset()
+	log.SetLevel(log.ErrorLevel)
+	log.WARNING.Print("hidden")
+	assert.Empty(t, buf.String())
+}
+
+func TestConfigure(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+	defer log.SetFormat(log.FormatConsole)
+
+	assert.NoError(t, log.Configure("warn", log.FormatJSON))
+	assert.Equal(t, log.WarningLevel, log.GetLevel())
+
+	assert.EqualError(t, log.Configure("verbose", log.FormatJSON), `unknown log level "verbose"`)
+	assert.EqualError(t, log.Configure("debug", "xml"), `unknown log format "xml"`)
+}
@@ -0,0 +1,290 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Level is the severity of a log message
+type Level int32
+
+const (
+	// DebugLevel ...
+	DebugLevel Level = iota
+	// InfoLevel ...
+	InfoLevel
+	// WarningLevel ...
+	WarningLevel
+	// ErrorLevel ...
+	ErrorLevel
+	// FatalLevel ...
+	FatalLevel
+)
+
+const (
+	// FormatJSON writes one JSON object per line
+	FormatJSON = "json"
+	// FormatConsole writes coloured human readable lines
+	FormatConsole = "console"
+
+	// callerDepth is the number of frames between a levelLogger
+	// method and the caller of that method
+	callerDepth = 2
+
+	// For colouring
+	resetSeq  = "\033[0m"
+	colourSeq = "\033[0;%dm"
+)
+
+var (
+	levelNames = map[Level]string{
+		DebugLevel:   "debug",
+		InfoLevel:    "info",
+		WarningLevel: "warning",
+		ErrorLevel:   "error",
+		FatalLevel:   "fatal",
+	}
+
+	// Colour map
+	colour = map[Level]string{
+		DebugLevel:   fmt.Sprintf(colourSeq, 90), // grey
+		InfoLevel:    fmt.Sprintf(colourSeq, 94), // blue
+		WarningLevel: fmt.Sprintf(colourSeq, 95), // pink
+		ErrorLevel:   fmt.Sprintf(colourSeq, 91), // red
+		FatalLevel:   fmt.Sprintf(colourSeq, 91), // red
+	}
+
+	// exit is replaced in tests
+	exit = os.Exit
+)
+
+// String returns the lower case name of the level
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", l)
+}
+
+// ParseLevel returns the level named s, an empty name means info
+func ParseLevel(s string) (Level, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	if s == "" {
+		return InfoLevel, nil
+	}
+
+	if s == "warn" {
+		return WarningLevel, nil
+	}
+
+	for lvl, name := range levelNames {
+		if name == s {
+			return lvl, nil
+		}
+	}
+
+	return InfoLevel, fmt.Errorf("unknown log level %q", s)
+}
+
+// Fields are key value pairs attached to a log line
+type Fields map[string]interface{}
+
+// logger writes log lines at or above its level
+type logger struct {
+	level int32 // accessed atomically
+
+	mu     sync.Mutex
+	out    io.Writer
+	format string
+}
+
+func newLogger(out io.Writer) *logger {
+	return &logger{
+		level:  int32(InfoLevel),
+		out:    out,
+		format: FormatConsole,
+	}
+}
+
+func (l *logger) setLevel(lvl Level) {
+	atomic.StoreInt32(&l.level, int32(lvl))
+}
+
+func (l *logger) getLevel() Level {
+	return Level(atomic.LoadInt32(&l.level))
+}
+
+func (l *logger) setFormat(format string) error {
+	switch format {
+	case FormatJSON, FormatConsole:
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+
+	l.mu.Lock()
+	l.format = format
+	l.mu.Unlock()
+
+	return nil
+}
+
+func (l *logger) setOutput(w io.Writer) {
+	l.mu.Lock()
+	l.out = w
+	l.mu.Unlock()
+}
+
+// log writes msg along with fields if lvl is enabled. Every string
+// is scrubbed of tokens, passwords and email addresses beforehand.
+func (l *logger) log(lvl Level, fields Fields, msg string) {
+	if lvl < l.getLevel() {
+		return
+	}
+
+	caller := ""
+	if _, file, line, ok := runtime.Caller(callerDepth); ok {
+		caller = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	}
+
+	now := time.Now().UTC()
+	msg = Scrub(strings.TrimSuffix(msg, "\n"))
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var buf bytes.Buffer
+
+	if l.format == FormatJSON {
+		buf.WriteString(`{"time":`)
+		writeJSON(&buf, now.Format(time.RFC3339Nano))
+		buf.WriteString(`,"level":`)
+		writeJSON(&buf, lvl.String())
+		buf.WriteString(`,"caller":`)
+		writeJSON(&buf, caller)
+		buf.WriteString(`,"msg":`)
+		writeJSON(&buf, msg)
+		for _, key := range keys {
+			buf.WriteByte(',')
+			writeJSON(&buf, key)
+			buf.WriteByte(':')
+			writeJSON(&buf, scrubValue(fields[key]))
+		}
+		buf.WriteString("}\n")
+	} else {
+		fmt.Fprintf(
+			&buf,
+			"%s%s %s: %s %s",
+			colour[lvl],
+			now.Local().Format("2006/01/02 15:04:05"),
+			strings.ToUpper(lvl.String()),
+			caller,
+			msg,
+		)
+		for _, key := range keys {
+			fmt.Fprintf(&buf, " %s=%v", key, scrubValue(fields[key]))
+		}
+		buf.WriteString(resetSeq + "\n")
+	}
+
+	l.out.Write(buf.Bytes())
+}
+
+// writeJSON encodes v, falling back to its string representation
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	buf.Write(data)
+}
+
+// scrubValue scrubs field values which end up as strings
+func scrubValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case string:
+		return Scrub(value)
+	case error:
+		return Scrub(value.Error())
+	case fmt.Stringer:
+		return Scrub(value.String())
+	default:
+		return v
+	}
+}
+
+// levelLogger implements logging.LoggerInterface for a single level
+type levelLogger struct {
+	lvl    Level
+	fields Fields
+}
+
+// Print ...
+func (l *levelLogger) Print(v ...interface{}) {
+	std.log(l.lvl, l.fields, fmt.Sprint(v...))
+}
+
+// Printf ...
+func (l *levelLogger) Printf(format string, v ...interface{}) {
+	std.log(l.lvl, l.fields, fmt.Sprintf(format, v...))
+}
+
+// Println ...
+func (l *levelLogger) Println(v ...interface{}) {
+	std.log(l.lvl, l.fields, fmt.Sprintln(v...))
+}
+
+// Fatal ...
+func (l *levelLogger) Fatal(v ...interface{}) {
+	std.log(FatalLevel, l.fields, fmt.Sprint(v...))
+	exit(1)
+}
+
+// Fatalf ...
+func (l *levelLogger) Fatalf(format string, v ...interface{}) {
+	std.log(FatalLevel, l.fields, fmt.Sprintf(format, v...))
+	exit(1)
+}
+
+// Fatalln ...
+func (l *levelLogger) Fatalln(v ...interface{}) {
+	std.log(FatalLevel, l.fields, fmt.Sprintln(v...))
+	exit(1)
+}
+
+// Panic ...
+func (l *levelLogger) Panic(v ...interface{}) {
+	msg := fmt.Sprint(v...)
+	std.log(l.lvl, l.fields, msg)
+	panic(msg)
+}
+
+// Panicf ...
+func (l *levelLogger) Panicf(format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	std.log(l.lvl, l.fields, msg)
+	panic(msg)
+}
+
+// Panicln ...
+func (l *levelLogger) Panicln(v ...interface{}) {
+	msg := fmt.Sprintln(v...)
+	std.log(l.lvl, l.fields, msg)
+	panic(msg)
+}
@@ -0,0 +1,36 @@
+package log
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is read from incoming requests and set on responses
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limits request IDs forwarded by proxies to something
+// which cannot be used to forge log lines
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID is a middleware handler adding a request ID to the request
+// context. The ID set by a proxy in the X-Request-ID header is reused,
+// otherwise a new one is generated. It is echoed in the response headers.
+type RequestID struct{}
+
+// NewRequestID returns a new RequestID instance
+func NewRequestID() *RequestID {
+	return &RequestID{}
+}
+
+func (m *RequestID) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	requestID := r.Header.Get(RequestIDHeader)
+	if !validRequestID.MatchString(requestID) {
+		requestID = uuid.New().String()
+	}
+
+	rw.Header().Set(RequestIDHeader, requestID)
+
+	next(rw, r.WithContext(ContextWithRequestID(r.Context(), requestID)))
+}
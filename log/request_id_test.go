@@ -0,0 +1,38 @@
+package log_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/resonatecoop/id/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	var requestID string
+
+	next := func(w http.ResponseWriter, r *http.Request) {
+		requestID = log.RequestIDFromContext(r.Context())
+	}
+
+	middleware := log.NewRequestID()
+
+	// A request ID set by a proxy is kept
+	r := httptest.NewRequest("GET", "/web/login", nil)
+	r.Header.Set(log.RequestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+	middleware.ServeHTTP(w, r, next)
+
+	assert.Equal(t, "abc-123", requestID)
+	assert.Equal(t, "abc-123", w.Header().Get(log.RequestIDHeader))
+
+	// Missing or malformed request IDs are replaced
+	r = httptest.NewRequest("GET", "/web/login", nil)
+	r.Header.Set(log.RequestIDHeader, "forged\nline")
+	w = httptest.NewRecorder()
+	middleware.ServeHTTP(w, r, next)
+
+	assert.Len(t, requestID, 36)
+	assert.Equal(t, requestID, w.Header().Get(log.RequestIDHeader))
+}
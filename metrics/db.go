@@ -0,0 +1,12 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/uptrace/bun"
+)
+
+// RegisterDBStats exports connection pool stats of the database
+func RegisterDBStats(db *bun.DB, dbName string) error {
+	return prometheus.Register(collectors.NewDBStatsCollector(db.DB, dbName))
+}
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// RequestDuration tracks request latency by route name
+	RequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "id",
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of HTTP requests by route name, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"route", "method", "code"},
+	)
+
+	// GrantsIssued counts access tokens issued by grant type
+	GrantsIssued = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "id",
+			Subsystem: "oauth",
+			Name:      "grants_issued_total",
+			Help:      "Number of grants issued by grant type.",
+		},
+		[]string{"grant_type"},
+	)
+
+	// FailedLogins counts failed authentication attempts by source
+	FailedLogins = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "id",
+			Subsystem: "oauth",
+			Name:      "failed_logins_total",
+			Help:      "Number of failed logins by source (web, password_grant).",
+		},
+		[]string{"source"},
+	)
+
+	// IntrospectionResults counts token introspections by result
+	IntrospectionResults = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "id",
+			Subsystem: "oauth",
+			Name:      "introspections_total",
+			Help:      "Number of token introspections by token type hint and result (active, inactive, error).",
+		},
+		[]string{"token_type_hint", "result"},
+	)
+
+	// StripeWebhookEvents counts stripe webhook events by type and outcome
+	StripeWebhookEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "id",
+			Subsystem: "stripe",
+			Name:      "webhook_events_total",
+			Help:      "Number of Stripe webhook events by type and outcome (processed, rejected, failed).",
+		},
+		[]string{"type", "outcome"},
+	)
+
+	// EmailsSent counts emails handed over to mailgun by template and outcome
+	EmailsSent = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "id",
+			Subsystem: "mailgun",
+			Name:      "emails_total",
+			Help:      "Number of emails by template and outcome (sent, failed).",
+		},
+		[]string{"template", "outcome"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		RequestDuration,
+		GrantsIssued,
+		FailedLogins,
+		IntrospectionResults,
+		StripeWebhookEvents,
+		EmailsSent,
+	)
+}
+
+// ObserveEmail records the outcome of sending an email
+func ObserveEmail(template string, err error) {
+	outcome := "sent"
+	if err != nil {
+		outcome = "failed"
+	}
+	EmailsSent.WithLabelValues(template, outcome).Inc()
+}
+
+// ObserveStripeWebhookEvent records a stripe webhook event given the
+// status code returned to stripe
+func ObserveStripeWebhookEvent(eventType string, status int) {
+	outcome := "processed"
+	switch {
+	case status >= http.StatusInternalServerError:
+		outcome = "failed"
+	case status >= http.StatusBadRequest:
+		outcome = "rejected"
+	}
+	StripeWebhookEvents.WithLabelValues(eventType, outcome).Inc()
+}
+
+// statusCode returns the status code label, 0 means nothing was written
+func statusCode(status int) string {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return strconv.Itoa(status)
+}
@@ -0,0 +1,62 @@
+package metrics_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/resonatecoop/id/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrumentHandler(t *testing.T) {
+	handler := metrics.InstrumentHandler("test_route", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	r, err := http.NewRequest("GET", "http://1.2.3.4/foo", nil)
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusTeapot, w.Code)
+
+	count := testutil.CollectAndCount(metrics.RequestDuration, "id_http_request_duration_seconds")
+	assert.Equal(t, 1, count)
+}
+
+func TestObserveStripeWebhookEvent(t *testing.T) {
+	metrics.ObserveStripeWebhookEvent("customer.created", 0)
+	metrics.ObserveStripeWebhookEvent("customer.created", http.StatusOK)
+	metrics.ObserveStripeWebhookEvent("customer.created", http.StatusBadRequest)
+	metrics.ObserveStripeWebhookEvent("customer.created", http.StatusInternalServerError)
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.StripeWebhookEvents.WithLabelValues("customer.created", "processed")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.StripeWebhookEvents.WithLabelValues("customer.created", "rejected")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.StripeWebhookEvents.WithLabelValues("customer.created", "failed")))
+}
+
+func TestObserveEmail(t *testing.T) {
+	metrics.ObserveEmail("signup", nil)
+	metrics.ObserveEmail("signup", errors.New("mailgun is down"))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.EmailsSent.WithLabelValues("signup", "sent")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.EmailsSent.WithLabelValues("signup", "failed")))
+}
+
+func TestHandler(t *testing.T) {
+	metrics.GrantsIssued.WithLabelValues("password").Inc()
+
+	r, err := http.NewRequest("GET", "http://1.2.3.4/metrics", nil)
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Contains(w.Body.String(), `id_oauth_grants_issued_total{grant_type="password"} 1`))
+}
@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/urfave/negroni"
+)
+
+// InstrumentHandler wraps a route handler to record its latency
+// labelled with the route name
+func InstrumentHandler(name string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := negroni.NewResponseWriter(w)
+
+		handler.ServeHTTP(rw, r)
+
+		RequestDuration.
+			WithLabelValues(name, r.Method, statusCode(rw.Status())).
+			Observe(time.Since(start).Seconds())
+	})
+}
@@ -6,22 +6,41 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
 )
 
-// GrantAccessToken deletes old tokens and grants a new access token
-func (s *Service) GrantAccessToken(client *model.Client, user *model.User, expiresIn int, scope string) (*model.AccessToken, error) {
-	// Begin a transaction
-	tx, err := s.db.Begin()
+// GrantAccessToken deletes old tokens and grants a new access token, the
+// token is restricted to the audience of resources when any are given
+func (s *Service) GrantAccessToken(client *model.Client, user *model.User, expiresIn int, scope string, resources ...string) (*model.AccessToken, error) {
 	ctx := context.Background()
 
-	//var result Sql.result
+	// Begin a transaction
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
 
+	accessToken, err := grantAccessToken(ctx, tx, client, user, expiresIn, scope, resources...)
 	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
 
+	return accessToken, nil
+}
+
+// grantAccessToken works like GrantAccessToken within a transaction, for
+// tokens which must not exist without rows written along with them
+func grantAccessToken(ctx context.Context, tx bun.Tx, client *model.Client, user *model.User, expiresIn int, scope string, resources ...string) (*model.AccessToken, error) {
 	accessToken := new(model.AccessToken)
 
+	var err error
+
 	// Delete expired access tokens
 	if user != nil && user.ID != uuid.Nil {
 		_, err = tx.NewDelete().
@@ -40,7 +59,6 @@ func (s *Service) GrantAccessToken(client *model.Client, user *model.User, expir
 	}
 
 	if err != nil {
-		tx.Rollback() // rollback the transaction
 		return nil, err
 	}
 
@@ -52,9 +70,24 @@ func (s *Service) GrantAccessToken(client *model.Client, user *model.User, expir
 		Exec(ctx)
 
 	if err != nil {
-		tx.Rollback() // rollback the transaction
 		return nil, err
 	}
+
+	if len(resources) > 0 {
+		rows := make([]*AccessTokenResource, len(resources))
+		for i, resource := range resources {
+			rows[i] = &AccessTokenResource{AccessTokenID: accessToken.ID, Resource: resource}
+		}
+
+		_, err = tx.NewInsert().
+			Model(&rows).
+			Exec(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	accessToken.ClientID = client.ID
 
 	if user == nil {
@@ -63,12 +96,5 @@ func (s *Service) GrantAccessToken(client *model.Client, user *model.User, expir
 		accessToken.UserID = user.ID
 	}
 
-	// Commit the transaction
-	err = tx.Commit()
-	if err != nil {
-		tx.Rollback() // rollback the transaction
-		return nil, err
-	}
-
 	return accessToken, nil
 }
@@ -0,0 +1,462 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/events"
+	"github.com/resonatecoop/id/log"
+	"github.com/resonatecoop/id/realm"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+
+	"github.com/stripe/stripe-go/v72"
+	cus "github.com/stripe/stripe-go/v72/customer"
+	"github.com/stripe/stripe-go/v72/sub"
+)
+
+var (
+	// ErrAccountPendingDeletion ...
+	ErrAccountPendingDeletion = errors.New("This account is scheduled for deletion, check your email to cancel it")
+	// ErrAccountDeletionNotFound ...
+	ErrAccountDeletionNotFound = errors.New("No pending account deletion found")
+	// ErrAccountDeletionExpired ...
+	ErrAccountDeletionExpired = errors.New("This account deletion can no longer be cancelled")
+
+	// anonymizedUsernameDomain is used for usernames of purged accounts
+	// which are kept around when anonymizing is enabled
+	anonymizedUsernameDomain = "deleted.invalid"
+)
+
+// maxStripeCancellationBackoff bounds the delay between two attempts to
+// cancel the subscriptions of a purged account
+const maxStripeCancellationBackoff = 6 * time.Hour
+
+// StripeCancellation is a purged account whose stripe subscriptions are
+// waiting to be cancelled. It is stored in the transaction purging the
+// account, so stripe being down never keeps an account around and an
+// account failing to be purged keeps its subscriptions.
+type StripeCancellation struct {
+	bun.BaseModel `bun:"table:stripe_cancellations"`
+
+	ID            uuid.UUID `bun:",pk,type:uuid,nullzero,default:uuid_generate_v4()"`
+	Email         string    `bun:",notnull"`
+	StripeAccount string    `bun:",notnull"`
+	Attempts      int       `bun:",notnull"`
+	LastError     string    `bun:",notnull"`
+	NextAttemptAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// CancelUserDeletion restores an account pending deletion using
+// the token sent by email when the deletion was requested
+func (s *Service) CancelUserDeletion(ctx context.Context, token string) (*model.User, error) {
+	emailToken, claims, err := s.getValidEmailTokenClaims(token)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.findPendingDeletionUser(claims.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens sent before the deletion was requested cannot cancel it
+	if emailToken.CreatedAt.Before(user.DeletedAt) {
+		return nil, ErrEmailTokenInvalid
+	}
+
+	if time.Now().UTC().After(s.deletionDeadline(user)) {
+		return nil, ErrAccountDeletionExpired
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.NewUpdate().
+		Model(user).
+		Set("deleted_at = NULL").
+		Set("updated_at = ?", time.Now().UTC()).
+		WhereDeleted().
+		WherePK().
+		Exec(ctx)
+
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	_, err = s.events.Emit(ctx, tx, events.UserRestored, user.ID, map[string]interface{}{
+		"username": user.Username,
+	})
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	user.DeletedAt = time.Time{}
+
+	if err = s.DeleteEmailToken(emailToken, true); err != nil {
+		log.FromContext(ctx).ERROR.Print(err)
+	}
+
+	return user, nil
+}
+
+// ScheduleUserDeletion deletes a user without asking for its password, e.g.
+// when an identity provider deprovisions the account. It is purged once
+// the grace period is over.
+func (s *Service) ScheduleUserDeletion(user *model.User) error {
+	return s.scheduleUserDeletion(s.db, user)
+}
+
+// RevokeUserTokens deletes all access tokens, refresh tokens
+// and authorization codes issued to a user, for every client
+func (s *Service) RevokeUserTokens(user *model.User) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = s.revokeUserTokensCommon(tx, user); err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PurgeDeletedUsers permanently removes accounts whose deletion grace period
+// is over. The cancellation of their stripe subscriptions and the
+// notification of the user API are queued along with the account getting
+// deleted or anonymized. It returns the number of purged accounts.
+func (s *Service) PurgeDeletedUsers() (int, error) {
+	ctx := context.Background()
+
+	cutoff := time.Now().UTC().AddDate(0, 0, -s.cnf.AccountDeletion.GracePeriod)
+
+	var pending []*model.User
+
+	err := s.db.NewSelect().
+		Model(&pending).
+		WhereDeleted().
+		Where("deleted_at <= ?", cutoff).
+		Where("username NOT LIKE ?", "%@"+anonymizedUsernameDomain).
+		Scan(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+
+	for _, user := range pending {
+		if err := s.purgeUser(user); err != nil {
+			log.ERROR.Printf("Failed to purge user %s: %v", user.ID, err)
+			continue
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// CancelStripeSubscriptions cancels the stripe subscriptions of up to
+// batchSize purged accounts, oldest first. Failed cancellations are retried
+// with an exponential backoff until they succeed, subscriptions cancelled
+// by a previous attempt are not listed again. It returns the number of
+// accounts whose subscriptions are all cancelled.
+func (s *Service) CancelStripeSubscriptions(batchSize int) (int, error) {
+	if batchSize <= 0 {
+		return 0, ErrInvalidBatchSize
+	}
+
+	ctx := context.Background()
+
+	cancellations := []*StripeCancellation{}
+	err := s.db.NewSelect().
+		Model(&cancellations).
+		Where("next_attempt_at <= ?", time.Now().UTC()).
+		Order("created_at ASC").
+		Limit(batchSize).
+		Scan(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	cancelled := 0
+
+	for _, cancellation := range cancellations {
+		if err = cancelStripeSubscriptions(cancellation.Email, cancellation.StripeAccount); err != nil {
+			if err = s.retryStripeCancellation(ctx, cancellation, err); err != nil {
+				return cancelled, err
+			}
+			continue
+		}
+
+		_, err = s.db.NewDelete().
+			Model(cancellation).
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return cancelled, err
+		}
+
+		cancelled++
+	}
+
+	return cancelled, nil
+}
+
+// findPendingDeletionUser looks up a soft deleted user by username
+func (s *Service) findPendingDeletionUser(username string) (*model.User, error) {
+	ctx := context.Background()
+	user := new(model.User)
+
+	err := s.db.NewSelect().
+		Model(user).
+		WhereDeleted().
+		Where("username = LOWER(?)", username).
+		Limit(1).
+		Scan(ctx)
+
+	if err != nil {
+		return nil, ErrAccountDeletionNotFound
+	}
+
+	return user, nil
+}
+
+// isPendingDeletion returns true if username and password match
+// an account which is scheduled for deletion
+func (s *Service) isPendingDeletion(username, password string) bool {
+	user, err := s.findPendingDeletionUser(username)
+	if err != nil {
+		return false
+	}
+
+	return s.checkPassword(context.Background(), user, password) == nil
+}
+
+// deletionDeadline returns the time after which an account deletion
+// can no longer be cancelled
+func (s *Service) deletionDeadline(user *model.User) time.Time {
+	return user.DeletedAt.AddDate(0, 0, s.cnf.AccountDeletion.GracePeriod)
+}
+
+// scheduleUserDeletion soft deletes the user and revokes all of its tokens
+func (s *Service) scheduleUserDeletion(db *bun.DB, user *model.User) error {
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err = s.revokeUserTokensCommon(tx, user); err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	// will set deleted_at to current time using soft delete
+	_, err = tx.NewDelete().
+		Model(user).
+		WherePK().
+		Exec(ctx)
+
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	_, err = s.events.Emit(ctx, tx, events.UserDeleted, user.ID, map[string]interface{}{
+		"username": user.Username,
+		"purge_at": time.Now().UTC().AddDate(0, 0, s.cnf.AccountDeletion.GracePeriod),
+	})
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Service) revokeUserTokensCommon(tx bun.Tx, user *model.User) error {
+	ctx := context.Background()
+
+	_, err := tx.NewDelete().
+		Model(new(model.AccessToken)).
+		Where("user_id = ?", user.ID).
+		ForceDelete().
+		Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NewDelete().
+		Model(new(model.RefreshToken)).
+		Where("user_id = ?", user.ID).
+		ForceDelete().
+		Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NewDelete().
+		Model(new(model.AuthorizationCode)).
+		Where("user_id = ?", user.ID).
+		ForceDelete().
+		Exec(ctx)
+
+	return err
+}
+
+// purgeUser removes a single account past its grace period
+func (s *Service) purgeUser(user *model.User) error {
+	ctx := context.Background()
+
+	// subscriptions live in the connected account of the realm, which
+	// forgets the user once it is deleted
+	rlm, err := s.userRealm(user)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = s.revokeUserTokensCommon(tx, user); err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	if s.cnf.AccountDeletion.Anonymize {
+		_, err = tx.NewUpdate().
+			Model(user).
+			Set("username = ?", fmt.Sprintf("%s@%s", user.ID, anonymizedUsernameDomain)).
+			Set("full_name = ''").
+			Set("first_name = ''").
+			Set("last_name = ''").
+			Set("country = ''").
+			Set("password = NULL").
+			Set("token = ''").
+			Set("newsletter_notification = ?", false).
+			Set("updated_at = ?", time.Now().UTC()).
+			WhereDeleted().
+			WherePK().
+			Exec(ctx)
+	} else {
+		_, err = tx.NewDelete().
+			Model(user).
+			WherePK().
+			ForceDelete().
+			Exec(ctx)
+	}
+
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	_, err = tx.NewInsert().
+		Model(&StripeCancellation{Email: user.Username, StripeAccount: rlm.Payment.Account}).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	// the user api removes its own records once told
+	if err = s.enqueueUserAPIChange(ctx, tx, user.ID, UserAPIDelete); err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// retryStripeCancellation records a failed cancellation and schedules the
+// next one
+func (s *Service) retryStripeCancellation(ctx context.Context, cancellation *StripeCancellation, cause error) error {
+	cancellation.Attempts++
+
+	log.ERROR.Printf("Failed to cancel the stripe subscriptions of %s, attempt %d: %v", cancellation.ID, cancellation.Attempts, cause)
+
+	backoff := time.Duration(s.cnf.Scheduler.CleanupInterval) * time.Second
+	for i := 1; i < cancellation.Attempts && backoff < maxStripeCancellationBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxStripeCancellationBackoff {
+		backoff = maxStripeCancellationBackoff
+	}
+
+	_, err := s.db.NewUpdate().
+		Model(cancellation).
+		Set("attempts = ?", cancellation.Attempts).
+		Set("last_error = ?", cause.Error()).
+		Set("next_attempt_at = ?", time.Now().UTC().Add(backoff)).
+		WherePK().
+		Exec(ctx)
+
+	return err
+}
+
+// cancelStripeSubscriptions cancels every subscription of the stripe
+// customers registered with the given email address, in the connected
+// account if any. Cancelled subscriptions are not listed, so it may be
+// called again after failing halfway.
+func cancelStripeSubscriptions(email, account string) error {
+	customerListParams := &stripe.CustomerListParams{
+		Email: stripe.String(email),
+	}
+	useStripeAccount(customerListParams, account)
+
+	customers := cus.List(customerListParams)
+
+	for customers.Next() {
+		customer := customers.Customer()
+
+		subscriptionListParams := &stripe.SubscriptionListParams{}
+		subscriptionListParams.Filters.AddFilter("customer", "", customer.ID)
+		useStripeAccount(subscriptionListParams, account)
+
+		subscriptions := sub.List(subscriptionListParams)
+
+		for subscriptions.Next() {
+			cancelParams := &stripe.SubscriptionCancelParams{}
+			useStripeAccount(cancelParams, account)
+
+			_, err := sub.Cancel(subscriptions.Subscription().ID, cancelParams)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := subscriptions.Err(); err != nil {
+			return err
+		}
+	}
+
+	return customers.Err()
+}
+
+// useStripeAccount makes a stripe request on behalf of a connected account
+// like realm.UseStripeAccount, the platform account is used without one
+func useStripeAccount(params realm.StripeParams, account string) {
+	if account != "" {
+		params.SetStripeAccount(account)
+	}
+}
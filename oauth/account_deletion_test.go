@@ -0,0 +1,205 @@
+package oauth_test
+
+import (
+	"context"
+	"time"
+
+	jwt "github.com/form3tech-oss/jwt-go"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/realm"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// deletionToken returns the token of the link cancelling the deletion of
+// the account of username, like the one it gets by email
+func (suite *OauthTestSuite) deletionToken(username string) string {
+	emailToken, err := suite.service.CreateEmailToken(username)
+	if !assert.NoError(suite.T(), err) {
+		return ""
+	}
+
+	token, err := jwt.NewWithClaims(
+		jwt.SigningMethodHS256,
+		model.NewOauthEmailTokenClaims(username, emailToken),
+	).SignedString([]byte(suite.cnf.EmailTokenSecretKey))
+	assert.NoError(suite.T(), err)
+
+	return token
+}
+
+func (suite *OauthTestSuite) TestDeleteUserSchedulesDeletion() {
+	ctx := context.Background()
+
+	user, err := suite.service.FindUserByUsername("test@user.com")
+	assert.NoError(suite.T(), err)
+
+	accessToken, err := suite.service.GrantAccessToken(suite.clients[0], user, 3600, "read_write")
+	assert.NoError(suite.T(), err)
+
+	// Wrong password does not delete the account
+	err = suite.service.DeleteUser(context.Background(), user, "bogus")
+	assert.Equal(suite.T(), oauth.ErrInvalidUserPassword, err)
+
+	err = suite.service.DeleteUser(context.Background(), user, "test_password")
+	assert.NoError(suite.T(), err)
+
+	// The user can no longer log in
+	_, err = suite.service.AuthUser(context.Background(), "test@user.com", "test_password")
+	assert.Equal(suite.T(), oauth.ErrAccountPendingDeletion, err)
+
+	// Wrong password does not reveal the pending deletion
+	_, err = suite.service.AuthUser(context.Background(), "test@user.com", "bogus")
+	assert.Equal(suite.T(), oauth.ErrUserNotFound, err)
+
+	// Tokens have been revoked
+	_, err = suite.service.Authenticate(accessToken.Token)
+	assert.Equal(suite.T(), oauth.ErrAccessTokenNotFound, err)
+
+	// Accounts within the grace period are not purged
+	purged, err := suite.service.PurgeDeletedUsers()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, purged)
+
+	// Restore the user for other tests
+	_, err = suite.db.NewUpdate().
+		Model(user).
+		Set("deleted_at = NULL").
+		WhereDeleted().
+		WherePK().
+		Exec(ctx)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *OauthTestSuite) TestCancelUserDeletionBogusToken() {
+	_, err := suite.service.CancelUserDeletion(context.Background(), "bogus")
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *OauthTestSuite) TestCancelUserDeletion() {
+	user, err := suite.service.FindUserByUsername("test@user2.com")
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+
+	err = suite.service.DeleteUser(context.Background(), user, "test_password")
+	assert.NoError(suite.T(), err)
+
+	token := suite.deletionToken(user.Username)
+
+	restored, err := suite.service.CancelUserDeletion(context.Background(), token)
+	if assert.NoError(suite.T(), err) {
+		assert.Equal(suite.T(), user.ID, restored.ID)
+		assert.True(suite.T(), restored.DeletedAt.IsZero())
+	}
+
+	// The user can log in again
+	_, err = suite.service.AuthUser(context.Background(), "test@user2.com", "test_password")
+	assert.NoError(suite.T(), err)
+
+	// The link cannot be used twice
+	_, err = suite.service.CancelUserDeletion(context.Background(), token)
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *OauthTestSuite) TestPurgeDeletedUsers() {
+	ctx := context.Background()
+
+	user, err := suite.service.CreateUser(realm.Default(suite.cnf), "test@purged.com", "C0mpl3xPa$$w0rdAr3U5", "", nil)
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+
+	err = suite.service.DeleteUser(ctx, user, "C0mpl3xPa$$w0rdAr3U5")
+	assert.NoError(suite.T(), err)
+
+	// The grace period is over
+	_, err = suite.db.NewUpdate().
+		Model(user).
+		Set("deleted_at = ?", time.Now().UTC().AddDate(0, 0, -suite.cnf.AccountDeletion.GracePeriod-1)).
+		WhereDeleted().
+		WherePK().
+		Exec(ctx)
+	assert.NoError(suite.T(), err)
+
+	// Links sent before are useless
+	_, err = suite.service.CancelUserDeletion(ctx, suite.deletionToken(user.Username))
+	assert.Equal(suite.T(), oauth.ErrAccountDeletionExpired, err)
+
+	purged, err := suite.service.PurgeDeletedUsers()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, purged)
+
+	// The account is gone, or anonymized
+	count, err := suite.db.NewSelect().
+		Model(new(model.User)).
+		WhereDeleted().
+		Where("username = ?", "test@purged.com").
+		Count(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+
+	// Its subscriptions are cancelled and the user api told once committed
+	count, err = suite.db.NewSelect().
+		Model(new(oauth.StripeCancellation)).
+		Where("email = ?", "test@purged.com").
+		Where("stripe_account = ''").
+		Count(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, count)
+
+	count, err = suite.db.NewSelect().
+		Model(new(oauth.UserAPIChange)).
+		Where("user_id = ?", user.ID).
+		Where("action = ?", oauth.UserAPIDelete).
+		Count(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, count)
+
+	// Nothing is left to purge
+	purged, err = suite.service.PurgeDeletedUsers()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, purged)
+}
+
+func (suite *OauthTestSuite) TestRevokeUserTokens() {
+	ctx := context.Background()
+
+	user := suite.users[0]
+
+	for _, client := range suite.clients {
+		_, err := suite.service.GrantAccessToken(client, user, 3600, "read_write")
+		assert.NoError(suite.T(), err)
+
+		_, err = suite.service.GetOrCreateRefreshToken(client, user, 3600, "read_write")
+		assert.NoError(suite.T(), err)
+	}
+
+	err := suite.service.RevokeUserTokens(user)
+	assert.NoError(suite.T(), err)
+
+	count, err := suite.db.NewSelect().
+		Model(new(model.AccessToken)).
+		Where("user_id = ?", user.ID).
+		Where("expires_at > ?", time.Now().UTC()).
+		Count(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+
+	count, err = suite.db.NewSelect().
+		Model(new(model.RefreshToken)).
+		Where("user_id = ?", user.ID).
+		Count(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+}
+
+func (suite *OauthTestSuite) TestCancelStripeSubscriptionsBatchSize() {
+	_, err := suite.service.CancelStripeSubscriptions(0)
+	assert.Equal(suite.T(), oauth.ErrInvalidBatchSize, err)
+
+	// Nothing is due
+	cancelled, err := suite.service.CancelStripeSubscriptions(10)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, cancelled)
+}
@@ -0,0 +1,73 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/user-api/model"
+)
+
+// AdminScope is the scope access tokens need to use the admin APIs of
+// roles, realms and webhooks
+const AdminScope = "admin_api"
+
+var (
+	// ErrAdminScopeRequired ...
+	ErrAdminScopeRequired = errors.New("Access token lacks the admin scope")
+	// ErrAdminAudience ...
+	ErrAdminAudience = errors.New("Access token is restricted to another resource")
+	// ErrAdminActor ...
+	ErrAdminActor = errors.New("Access tokens obtained by an actor cannot use the admin APIs")
+)
+
+// AuthenticateAdmin authenticates the bearer token of an admin API request
+// and returns its user. The token must be granted the admin scope, must not
+// be restricted to a resource, since the admin APIs are none, and must not
+// have been obtained by an actor through token exchange or impersonation.
+// Whether the user may do what is asked is left to the caller.
+func (s *Service) AuthenticateAdmin(token string) (*model.User, error) {
+	ctx := context.Background()
+
+	accessToken, err := s.Authenticate(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if accessToken.UserID == uuid.Nil {
+		return nil, ErrUserNotFound
+	}
+
+	if !util.StringInSlice(AdminScope, splitScope(accessToken.Scope)) {
+		return nil, ErrAdminScopeRequired
+	}
+
+	resources, err := s.GetAccessTokenResources(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if len(resources) > 0 {
+		return nil, ErrAdminAudience
+	}
+
+	actor, err := s.GetAccessTokenActor(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if actor != nil {
+		return nil, ErrAdminActor
+	}
+
+	user := new(model.User)
+	err = s.db.NewSelect().
+		Model(user).
+		Where("id = ?", accessToken.UserID).
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	return user, nil
+}
@@ -0,0 +1,61 @@
+package oauth_test
+
+import (
+	"context"
+
+	"github.com/resonatecoop/id/oauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestAuthenticateAdmin() {
+	ctx := context.Background()
+
+	// Tokens without the admin scope are refused
+	accessToken, err := suite.service.GrantAccessToken(suite.clients[0], suite.users[0], 3600, "read_write user")
+	if assert.Nil(suite.T(), err) {
+		_, err = suite.service.AuthenticateAdmin(accessToken.Token)
+		assert.Equal(suite.T(), oauth.ErrAdminScopeRequired, err)
+	}
+
+	// Tokens of clients are refused
+	accessToken, err = suite.service.GrantAccessToken(suite.clients[0], nil, 3600, oauth.AdminScope)
+	if assert.Nil(suite.T(), err) {
+		_, err = suite.service.AuthenticateAdmin(accessToken.Token)
+		assert.Equal(suite.T(), oauth.ErrUserNotFound, err)
+	}
+
+	accessToken, err = suite.service.GrantAccessToken(suite.clients[0], suite.users[0], 3600, "admin_api user")
+	if assert.Nil(suite.T(), err) {
+		user, err := suite.service.AuthenticateAdmin(accessToken.Token)
+		if assert.Nil(suite.T(), err) {
+			assert.Equal(suite.T(), suite.users[0].ID, user.ID)
+		}
+	}
+
+	// Tokens restricted to a resource are refused
+	tracks, err := suite.service.CreateResource("https://api.resonate.test/tracks", "tracks", "")
+	if assert.Nil(suite.T(), err) {
+		defer suite.service.DeleteResource(tracks.URI)
+	}
+
+	accessToken, err = suite.service.GrantAccessToken(suite.clients[0], suite.users[0], 3600, "admin_api user", tracks.URI)
+	if assert.Nil(suite.T(), err) {
+		_, err = suite.service.AuthenticateAdmin(accessToken.Token)
+		assert.Equal(suite.T(), oauth.ErrAdminAudience, err)
+	}
+
+	// Tokens obtained by an actor are refused
+	accessToken, err = suite.service.GrantAccessToken(suite.clients[0], suite.users[0], 3600, "admin_api user")
+	if assert.Nil(suite.T(), err) {
+		_, err = suite.db.NewInsert().
+			Model(&oauth.AccessTokenActor{
+				AccessTokenID: accessToken.ID,
+				Act:           &oauth.Actor{Subject: suite.users[1].ID.String()},
+			}).
+			Exec(ctx)
+		assert.Nil(suite.T(), err)
+
+		_, err = suite.service.AuthenticateAdmin(accessToken.Token)
+		assert.Equal(suite.T(), oauth.ErrAdminActor, err)
+	}
+}
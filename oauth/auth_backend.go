@@ -0,0 +1,114 @@
+package oauth
+
+import (
+	"context"
+
+	"github.com/resonatecoop/id/authn"
+	"github.com/resonatecoop/id/log"
+	"github.com/resonatecoop/id/realm"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+// checkPassword verifies the password of a user with the backend of its
+// domain, users without a password are locked whatever their backend
+func (s *Service) checkPassword(ctx context.Context, user *model.User, password string) error {
+	if !user.Password.Valid {
+		return ErrUserPasswordNotSet
+	}
+
+	route := s.authBackends.ForUsername(user.Username)
+
+	if _, err := route.Backend.Authenticate(ctx, user.Username, password, user); err != nil {
+		return authError(err)
+	}
+
+	return nil
+}
+
+// authError maps the errors of authentication backends to ours
+func authError(err error) error {
+	switch err {
+	case authn.ErrInvalidCredentials:
+		return ErrInvalidUserPassword
+	case authn.ErrPasswordNotSet:
+		return ErrUserPasswordNotSet
+	}
+	return err
+}
+
+// provisionUser creates a user authenticated by a backend for the first
+// time. Its local password is random, it may only log in through the
+// backend until it resets it, and its address is trusted.
+func (s *Service) provisionUser(ctx context.Context, route *authn.Route, identity *authn.Identity) (*model.User, error) {
+	slug := route.Realm
+	if slug == "" {
+		slug = realm.DefaultSlug
+	}
+
+	rlm, err := s.realms.FindBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := util.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	// the user is left out unless its attributes are synced as well
+	user, err := s.CreateConfirmedUser(ctx, rlm, identity.Username, secret, "", nil, func(ctx context.Context, tx bun.Tx, user *model.User) error {
+		return s.syncIdentity(ctx, tx, user, identity)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.FromContext(ctx).INFO.Printf("Provisioned %s from the %s backend", user.Username, route.Backend.Name())
+
+	return user, nil
+}
+
+// syncIdentity copies the attributes a backend knows of a user to it,
+// attributes the backend doesn't know are left alone
+func (s *Service) syncIdentity(ctx context.Context, db bun.IDB, user *model.User, identity *authn.Identity) error {
+	update := db.NewUpdate().Model(user)
+	changed := false
+
+	if identity.FullName != "" && identity.FullName != user.FullName {
+		update.Set("full_name = ?", identity.FullName)
+		user.FullName = identity.FullName
+		changed = true
+	}
+
+	if identity.FirstName != "" && identity.FirstName != user.FirstName {
+		update.Set("first_name = ?", identity.FirstName)
+		user.FirstName = identity.FirstName
+		changed = true
+	}
+
+	if identity.LastName != "" && identity.LastName != user.LastName {
+		update.Set("last_name = ?", identity.LastName)
+		user.LastName = identity.LastName
+		changed = true
+	}
+
+	if identity.Country != "" {
+		// directories may hold anything, unknown countries are ignored
+		country, err := findCountryCode(identity.Country)
+		if err == nil && country != user.Country {
+			update.Set("country = ?", country)
+			user.Country = country
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	_, err := update.Where("id = ?", user.ID).Exec(ctx)
+
+	return err
+}
@@ -0,0 +1,66 @@
+package oauth_test
+
+import (
+	"context"
+
+	"github.com/resonatecoop/id/authn"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// partnerBackend authenticates the users of a directory holding a single
+// password
+type partnerBackend struct{}
+
+func (b *partnerBackend) Name() string {
+	return "partner"
+}
+
+func (b *partnerBackend) Authenticate(ctx context.Context, username, password string, user *model.User) (*authn.Identity, error) {
+	if password != "partner_password" {
+		return nil, authn.ErrInvalidCredentials
+	}
+	return &authn.Identity{
+		Username:  username,
+		FullName:  "Partner User",
+		FirstName: "Partner",
+		LastName:  "User",
+	}, nil
+}
+
+func (suite *OauthTestSuite) TestAuthUserProvision() {
+	suite.service.RegisterAuthBackend(&authn.Route{
+		Backend:   new(partnerBackend),
+		Domains:   []string{"partner.example"},
+		Provision: true,
+	})
+
+	// Wrong passwords provision nobody
+	user, err := suite.service.AuthUser(context.Background(), "test@partner.example", "bogus")
+	assert.Nil(suite.T(), user)
+	assert.Equal(suite.T(), oauth.ErrInvalidUserPassword, err)
+	assert.False(suite.T(), suite.service.UserExists("test@partner.example"))
+
+	// Unknown users are created on their first login
+	user, err = suite.service.AuthUser(context.Background(), "test@partner.example", "partner_password")
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	assert.True(suite.T(), user.EmailConfirmed)
+	assert.Equal(suite.T(), "Partner User", user.FullName)
+
+	user, err = suite.service.FindUserByUsername("test@partner.example")
+	if assert.Nil(suite.T(), err) {
+		assert.True(suite.T(), user.EmailConfirmed)
+		assert.Equal(suite.T(), "Partner User", user.FullName)
+		assert.Equal(suite.T(), "Partner", user.FirstName)
+		assert.Equal(suite.T(), "User", user.LastName)
+	}
+
+	// and log in as known users afterwards
+	user, err = suite.service.AuthUser(context.Background(), "test@partner.example", "partner_password")
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), "test@partner.example", user.Username)
+	}
+}
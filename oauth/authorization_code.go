@@ -15,17 +15,48 @@ var (
 	ErrAuthorizationCodeExpired = errors.New("Authorization code expired")
 )
 
-// GrantAuthorizationCode grants a new authorization code
-func (s *Service) GrantAuthorizationCode(client *model.Client, user *model.User, expiresIn int, redirectURI, scope string) (*model.AuthorizationCode, error) {
+// GrantAuthorizationCode grants a new authorization code, the tokens issued
+// for it may be restricted to the given resources
+func (s *Service) GrantAuthorizationCode(client *model.Client, user *model.User, expiresIn int, redirectURI, scope string, resources ...string) (*model.AuthorizationCode, error) {
 	// Create a new authorization code
 	authorizationCode := model.NewOauthAuthorizationCode(client, user, expiresIn, redirectURI, scope)
 
 	ctx := context.Background()
 
-	_, err := s.db.NewInsert().Model(authorizationCode).Exec(ctx)
+	// Begin a transaction
+	tx, err := s.db.Begin()
 	if err != nil {
 		return nil, err
 	}
+
+	_, err = tx.NewInsert().Model(authorizationCode).Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	if len(resources) > 0 {
+		rows := make([]*AuthorizationCodeResource, len(resources))
+		for i, resource := range resources {
+			rows[i] = &AuthorizationCodeResource{AuthorizationCodeID: authorizationCode.ID, Resource: resource}
+		}
+
+		_, err = tx.NewInsert().
+			Model(&rows).
+			Exec(ctx)
+
+		if err != nil {
+			tx.Rollback() // rollback the transaction
+			return nil, err
+		}
+	}
+
+	// Commit the transaction
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
 	authorizationCode.Client = client
 	authorizationCode.User = user
 
@@ -0,0 +1,380 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	jwt "github.com/form3tech-oss/jwt-go"
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// RequestURIPrefix starts the request URIs of pushed authorization
+	// requests (RFC 9126 section 2.2)
+	RequestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+	// pushedRequestLifetime is the number of seconds a pushed authorization
+	// request may be used for, users may have to log in first
+	pushedRequestLifetime = 300
+	// maxJWKSSize bounds the key sets fetched from the jwks_uri of clients
+	maxJWKSSize = 64 << 10
+)
+
+var (
+	// ErrInvalidRequestURI ...
+	ErrInvalidRequestURI = errors.New("Invalid request URI")
+	// ErrInvalidRequestObject ...
+	ErrInvalidRequestObject = errors.New("Invalid request object")
+	// ErrPushedAuthorizationRequired ...
+	ErrPushedAuthorizationRequired = errors.New("Authorization requests of this client must be pushed")
+)
+
+var (
+	// requestObjectSigningAlgs lists the algorithms request objects may be
+	// signed with, unsigned request objects are rejected
+	requestObjectSigningAlgs = []string{"RS256", "PS256", "ES256"}
+	// requestObjectClaims are the claims of request objects which are not
+	// authorization request parameters
+	requestObjectClaims = []string{"iss", "aud", "exp", "iat", "nbf", "jti"}
+)
+
+// PushedAuthorizationRequest holds the parameters of an authorization
+// request a client pushed, only a hash of its request URI is stored
+type PushedAuthorizationRequest struct {
+	bun.BaseModel `bun:"table:pushed_authorization_requests"`
+
+	ID             uuid.UUID `bun:",pk,type:uuid,nullzero,default:uuid_generate_v4()"`
+	RequestURIHash string    `bun:"request_uri_hash,notnull"`
+	ClientID       uuid.UUID `bun:"type:uuid,notnull"`
+	Parameters     string    `bun:",notnull"`
+	ExpiresAt      time.Time `bun:",notnull"`
+	CreatedAt      time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// PushAuthorizationRequest validates the parameters of an authorization
+// request and stores them (RFC 9126). It returns the request URI the client
+// sends the user to the authorization endpoint with and its lifetime in
+// seconds. Request objects are verified against issuer.
+func (s *Service) PushAuthorizationRequest(client *model.Client, params url.Values, issuer string) (string, int, error) {
+	ctx := context.Background()
+
+	var err error
+
+	// the parameters of a request object replace the others
+	if request := params.Get("request"); request != "" {
+		params, err = s.ParseRequestObject(client, request, issuer)
+		if err != nil {
+			return "", 0, err
+		}
+	} else {
+		params = copyValues(params)
+		params.Del("client_secret")
+	}
+
+	if params.Get("request_uri") != "" {
+		return "", 0, ErrInvalidRequestURI
+	}
+
+	if clientID := params.Get("client_id"); clientID != "" && clientID != client.Key {
+		return "", 0, ErrInvalidClientIDOrSecret
+	}
+	params.Set("client_id", client.Key)
+
+	if err = s.validateAuthorizationRequest(client, params); err != nil {
+		return "", 0, err
+	}
+
+	secret, err := util.GenerateSecret()
+	if err != nil {
+		return "", 0, err
+	}
+
+	requestURI := RequestURIPrefix + secret
+
+	_, err = s.db.NewInsert().
+		Model(&PushedAuthorizationRequest{
+			RequestURIHash: hashToken(requestURI),
+			ClientID:       client.ID,
+			Parameters:     params.Encode(),
+			ExpiresAt:      time.Now().UTC().Add(pushedRequestLifetime * time.Second),
+		}).
+		Exec(ctx)
+
+	if err != nil {
+		return "", 0, err
+	}
+
+	return requestURI, pushedRequestLifetime, nil
+}
+
+// GetAuthorizationRequest returns the parameters of an authorization request
+// of a client: the pushed ones when form has a request_uri, those of the
+// request object when it has a request, form itself otherwise
+func (s *Service) GetAuthorizationRequest(client *model.Client, form url.Values, issuer string) (url.Values, error) {
+	if requestURI := form.Get("request_uri"); requestURI != "" {
+		return s.getPushedAuthorizationRequest(client, requestURI)
+	}
+
+	metadata, err := s.GetClientMetadata(client)
+	if err != nil {
+		return nil, err
+	}
+
+	if metadata.RequirePushedAuthorizationRequests {
+		return nil, ErrPushedAuthorizationRequired
+	}
+
+	if request := form.Get("request"); request != "" {
+		return s.ParseRequestObject(client, request, issuer)
+	}
+
+	return form, nil
+}
+
+// ConsumePushedAuthorizationRequest deletes a pushed authorization request
+// once it was used, so it cannot be replayed
+func (s *Service) ConsumePushedAuthorizationRequest(client *model.Client, requestURI string) error {
+	ctx := context.Background()
+
+	res, err := s.db.NewDelete().
+		Model((*PushedAuthorizationRequest)(nil)).
+		Where("request_uri_hash = ?", hashToken(requestURI)).
+		Where("client_id = ?", client.ID).
+		Where("expires_at > ?", time.Now().UTC()).
+		Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrInvalidRequestURI
+	}
+
+	return nil
+}
+
+// ParseRequestObject verifies a request object signed by a client and
+// returns the authorization request parameters it holds (RFC 9101). Its
+// audience must be issuer, the URL of the realm the request was sent to.
+func (s *Service) ParseRequestObject(client *model.Client, requestObject, issuer string) (url.Values, error) {
+	metadata, err := s.GetClientMetadata(client)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+
+	_, err = new(jwt.Parser).ParseWithClaims(requestObject, claims, func(token *jwt.Token) (interface{}, error) {
+		alg := token.Method.Alg()
+		if !util.StringInSlice(alg, requestObjectSigningAlgs) {
+			return nil, ErrInvalidRequestObject
+		}
+		if metadata.RequestObjectSigningAlg != "" && alg != metadata.RequestObjectSigningAlg {
+			return nil, ErrInvalidRequestObject
+		}
+		kid, _ := token.Header["kid"].(string)
+		return s.getClientKey(metadata, alg, kid)
+	})
+	if err != nil {
+		return nil, ErrInvalidRequestObject
+	}
+
+	// request objects expire and are meant for this server only
+	if _, ok := claims["exp"]; !ok {
+		return nil, ErrInvalidRequestObject
+	}
+	if !claims.VerifyIssuer(client.Key, true) || !claims.VerifyAudience(issuer, true) {
+		return nil, ErrInvalidRequestObject
+	}
+
+	params := url.Values{}
+
+	for name, value := range claims {
+		if util.StringInSlice(name, requestObjectClaims) {
+			continue
+		}
+
+		switch v := value.(type) {
+		case string:
+			params.Set(name, v)
+		case float64:
+			params.Set(name, strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
+			params.Set(name, strconv.FormatBool(v))
+		case []interface{}:
+			// e.g. several resource parameters
+			for _, item := range v {
+				str, ok := item.(string)
+				if !ok {
+					return nil, ErrInvalidRequestObject
+				}
+				params.Add(name, str)
+			}
+		default:
+			// e.g. the claims parameter of OpenID Connect
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, ErrInvalidRequestObject
+			}
+			params.Set(name, string(data))
+		}
+	}
+
+	// request objects cannot be nested and are issued by the client
+	if params.Get("request") != "" || params.Get("request_uri") != "" {
+		return nil, ErrInvalidRequestObject
+	}
+	if clientID := params.Get("client_id"); clientID != "" && clientID != client.Key {
+		return nil, ErrInvalidRequestObject
+	}
+	params.Set("client_id", client.Key)
+
+	return params, nil
+}
+
+// PurgeExpiredPushedAuthorizationRequests permanently deletes expired
+// pushed authorization requests, batchSize rows at a time. It returns the
+// number of deleted rows.
+func (s *Service) PurgeExpiredPushedAuthorizationRequests(batchSize int) (int, error) {
+	return s.purgeExpiredCommon((*PushedAuthorizationRequest)(nil), batchSize)
+}
+
+// getPushedAuthorizationRequest returns the parameters a client pushed,
+// requests of other clients and expired requests are invalid
+func (s *Service) getPushedAuthorizationRequest(client *model.Client, requestURI string) (url.Values, error) {
+	ctx := context.Background()
+
+	if !strings.HasPrefix(requestURI, RequestURIPrefix) {
+		return nil, ErrInvalidRequestURI
+	}
+
+	pushed := new(PushedAuthorizationRequest)
+
+	err := s.db.NewSelect().
+		Model(pushed).
+		Where("request_uri_hash = ?", hashToken(requestURI)).
+		Where("client_id = ?", client.ID).
+		Where("expires_at > ?", time.Now().UTC()).
+		Limit(1).
+		Scan(ctx)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidRequestURI
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return url.ParseQuery(pushed.Parameters)
+}
+
+// validateAuthorizationRequest checks the parameters of a pushed request
+// the way the authorization endpoint does, before the user sees them
+func (s *Service) validateAuthorizationRequest(client *model.Client, params url.Values) error {
+	grantType := "authorization_code"
+	switch params.Get("response_type") {
+	case "", "code":
+	case "token":
+		grantType = "implicit"
+	default:
+		return ErrUnsupportedResponseType
+	}
+
+	if err := s.CheckGrantType(client, grantType); err != nil {
+		return err
+	}
+
+	// clients with several redirect URIs must say which one to use
+	if redirectURI := params.Get("redirect_uri"); redirectURI != "" {
+		if !s.IsValidRedirectURI(client, redirectURI) {
+			return ErrInvalidRedirectURI
+		}
+	} else {
+		redirectURIs, err := s.GetClientRedirectURIs(client)
+		if err != nil {
+			return err
+		}
+		if len(redirectURIs) != 1 {
+			return ErrInvalidRedirectURI
+		}
+	}
+
+	_, err := s.GetClientScope(client, params.Get("scope"))
+
+	return err
+}
+
+// getClientKey returns the key of a client matching a signature algorithm
+// and, when the header names one, a key ID
+func (s *Service) getClientKey(metadata *ClientMetadata, alg, kid string) (interface{}, error) {
+	jwks := metadata.JWKS
+	if metadata.JWKSURI != "" {
+		var err error
+		jwks, err = s.fetchJSONWebKeySet(metadata.JWKSURI)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if jwks == nil {
+		return nil, ErrInvalidRequestObject
+	}
+
+	kty := "RSA"
+	if alg == "ES256" {
+		kty = "EC"
+	}
+
+	for i := range jwks.Keys {
+		key := &jwks.Keys[i]
+		if key.Kty != kty || (kid != "" && key.Kid != kid) {
+			continue
+		}
+		if (key.Use != "" && key.Use != "sig") || (key.Alg != "" && key.Alg != alg) {
+			continue
+		}
+		return key.publicKey()
+	}
+
+	return nil, ErrInvalidRequestObject
+}
+
+// fetchJSONWebKeySet downloads the keys a client published
+func (s *Service) fetchJSONWebKeySet(uri string) (*JSONWebKeySet, error) {
+	resp, err := s.jwksClient.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks_uri responded with status %d", resp.StatusCode)
+	}
+
+	jwks := new(JSONWebKeySet)
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSSize)).Decode(jwks); err != nil {
+		return nil, err
+	}
+
+	return jwks, nil
+}
+
+func copyValues(values url.Values) url.Values {
+	result := url.Values{}
+	for name, value := range values {
+		result[name] = append([]string(nil), value...)
+	}
+	return result
+}
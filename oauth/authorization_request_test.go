@@ -0,0 +1,274 @@
+package oauth_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	jwt "github.com/form3tech-oss/jwt-go"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/realm"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestRedirectURIMatching() {
+	client := suite.clients[1]
+
+	assert.Equal(suite.T(), oauth.ErrInvalidRedirectURI, suite.service.SetClientAuthorization(client, []string{"/callback"}, &oauth.ClientAuthorization{}))
+	assert.Equal(suite.T(), oauth.ErrInvalidClientMetadata, suite.service.SetClientAuthorization(client, nil, &oauth.ClientAuthorization{JWKSURI: "http://app.example.com/jwks"}))
+	assert.Equal(suite.T(), oauth.ErrInvalidClientMetadata, suite.service.SetClientAuthorization(client, nil, &oauth.ClientAuthorization{RequestObjectSigningAlg: "none"}))
+
+	err := suite.service.SetClientAuthorization(client, []string{
+		"https://app.example.com/callback",
+		"http://127.0.0.1/callback",
+		"http://[::1]:8080/callback",
+		"http://localhost:3000/callback",
+	}, &oauth.ClientAuthorization{})
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	defer suite.db.NewDelete().
+		Model((*oauth.ClientMetadata)(nil)).
+		Where("client_id = ?", client.ID).
+		Exec(context.Background())
+
+	for redirectURI, valid := range map[string]bool{
+		"https://app.example.com/callback":       true,
+		"https://app.example.com/callback/":      false,
+		"https://app.example.com/callback?x=1":   false,
+		"https://app.example.com:8443/callback":  false,
+		"http://app.example.com/callback":        false,
+		"http://127.0.0.1/callback":              true,
+		"http://127.0.0.1:51004/callback":        true,
+		"http://127.0.0.1:51004/other":           false,
+		"http://127.0.0.1:51004/callback#x":      false,
+		"https://127.0.0.1:51004/callback":       false,
+		"http://[::1]:51004/callback":            true,
+		"http://localhost:3000/callback":         true,
+		"http://localhost:3001/callback":         false,
+		"http://user@127.0.0.1:51004/callback":   false,
+		client.RedirectURI.String + "/elsewhere": false,
+	} {
+		assert.Equal(suite.T(), valid, suite.service.IsValidRedirectURI(client, redirectURI), redirectURI)
+	}
+}
+
+func (suite *OauthTestSuite) TestPushedAuthorizationRequests() {
+	client := suite.clients[0]
+	issuer := realm.Default(suite.cnf).URL(suite.cnf, "")
+
+	push := func(form url.Values) *httptest.ResponseRecorder {
+		r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/par", strings.NewReader(form.Encode()))
+		assert.NoError(suite.T(), err, "Request setup should not get an error")
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("test_client_1", "test_secret")
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+
+	// Invalid requests are rejected before the user sees them
+	for form, code := range map[string]string{
+		"response_type=code&redirect_uri=https%3A%2F%2Fevil.example.com":                            "invalid_request",
+		"response_type=code&request_uri=urn%3Aietf%3Aparams%3Aoauth%3Arequest_uri%3Ax":              "invalid_request",
+		"response_type=id_token&redirect_uri=" + url.QueryEscape(client.RedirectURI.String):         "unsupported_response_type",
+		"response_type=code&scope=bogus&redirect_uri=" + url.QueryEscape(client.RedirectURI.String): "invalid_scope",
+		"request=not.a.jwt": "invalid_request_object",
+	} {
+		values, _ := url.ParseQuery(form)
+		w := push(values)
+		assert.Equal(suite.T(), http.StatusBadRequest, w.Code, form)
+		assert.Contains(suite.T(), w.Body.String(), `"error":"`+code+`"`, form)
+	}
+
+	w := push(url.Values{
+		"response_type": {"code"},
+		"redirect_uri":  {client.RedirectURI.String},
+		"state":         {"somestate"},
+		"scope":         {"read"},
+	})
+	if !assert.Equal(suite.T(), http.StatusCreated, w.Code) {
+		return
+	}
+
+	resp := new(struct {
+		RequestURI string `json:"request_uri"`
+		ExpiresIn  int    `json:"expires_in"`
+	})
+	assert.Nil(suite.T(), json.NewDecoder(w.Body).Decode(resp))
+	assert.True(suite.T(), strings.HasPrefix(resp.RequestURI, oauth.RequestURIPrefix))
+	assert.True(suite.T(), resp.ExpiresIn > 0)
+
+	// The authorization endpoint only uses the pushed parameters
+	params, err := suite.service.GetAuthorizationRequest(client, url.Values{
+		"client_id":   {client.Key},
+		"request_uri": {resp.RequestURI},
+		"state":       {"other"},
+	}, issuer)
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), "somestate", params.Get("state"))
+		assert.Equal(suite.T(), client.Key, params.Get("client_id"))
+		assert.Empty(suite.T(), params.Get("client_secret"))
+	}
+
+	// Request URIs belong to the client which pushed them
+	_, err = suite.service.GetAuthorizationRequest(suite.clients[1], url.Values{"request_uri": {resp.RequestURI}}, issuer)
+	assert.Equal(suite.T(), oauth.ErrInvalidRequestURI, err)
+
+	// and are used once
+	assert.Nil(suite.T(), suite.service.ConsumePushedAuthorizationRequest(client, resp.RequestURI))
+	assert.Equal(suite.T(), oauth.ErrInvalidRequestURI, suite.service.ConsumePushedAuthorizationRequest(client, resp.RequestURI))
+	_, err = suite.service.GetAuthorizationRequest(client, url.Values{"request_uri": {resp.RequestURI}}, issuer)
+	assert.Equal(suite.T(), oauth.ErrInvalidRequestURI, err)
+
+	_, err = suite.service.PurgeExpiredPushedAuthorizationRequests(100)
+	assert.Nil(suite.T(), err)
+
+	// Clients may require every request to be pushed
+	err = suite.service.SetClientAuthorization(client, nil, &oauth.ClientAuthorization{RequirePushedAuthorizationRequests: true})
+	if assert.Nil(suite.T(), err) {
+		defer suite.db.NewDelete().
+			Model((*oauth.ClientMetadata)(nil)).
+			Where("client_id = ?", client.ID).
+			Exec(context.Background())
+	}
+	_, err = suite.service.GetAuthorizationRequest(client, url.Values{"response_type": {"code"}}, issuer)
+	assert.Equal(suite.T(), oauth.ErrPushedAuthorizationRequired, err)
+}
+
+func (suite *OauthTestSuite) TestRequestObjects() {
+	client := suite.clients[1]
+	issuer := realm.Default(suite.cnf).URL(suite.cnf, "")
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+
+	encode := func(b []byte) string {
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	err = suite.service.SetClientAuthorization(client, nil, &oauth.ClientAuthorization{
+		JWKS: &oauth.JSONWebKeySet{Keys: []oauth.JSONWebKey{
+			{Kty: "RSA", Kid: "rsa", N: encode(rsaKey.N.Bytes()), E: encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{Kty: "EC", Kid: "ec", Crv: "P-256", X: encode(ecKey.X.Bytes()), Y: encode(ecKey.Y.Bytes())},
+		}},
+	})
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	defer suite.db.NewDelete().
+		Model((*oauth.ClientMetadata)(nil)).
+		Where("client_id = ?", client.ID).
+		Exec(context.Background())
+
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":           client.Key,
+			"aud":           issuer,
+			"exp":           time.Now().Add(time.Minute).Unix(),
+			"client_id":     client.Key,
+			"response_type": "code",
+			"redirect_uri":  client.RedirectURI.String,
+			"state":         "somestate",
+			"resource":      []string{"https://api.example.com/a", "https://api.example.com/b"},
+		}
+	}
+
+	sign := func(method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		assert.Nil(suite.T(), err)
+		return signed
+	}
+
+	for _, requestObject := range []string{
+		sign(jwt.SigningMethodRS256, "rsa", rsaKey, claims()),
+		sign(jwt.SigningMethodPS256, "rsa", rsaKey, claims()),
+		sign(jwt.SigningMethodES256, "ec", ecKey, claims()),
+	} {
+		params, err := suite.service.ParseRequestObject(client, requestObject, issuer)
+		if assert.Nil(suite.T(), err) {
+			assert.Equal(suite.T(), "somestate", params.Get("state"))
+			assert.Equal(suite.T(), []string{"https://api.example.com/a", "https://api.example.com/b"}, params["resource"])
+			assert.Empty(suite.T(), params.Get("iss"))
+		}
+	}
+
+	invalid := map[string]string{
+		"wrong key":   sign(jwt.SigningMethodRS256, "rsa", otherKey, claims()),
+		"unsigned":    sign(jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, claims()),
+		"symmetric":   sign(jwt.SigningMethodHS256, "rsa", []byte("secret"), claims()),
+		"not a token": "not.a.token",
+		"unknown kid": sign(jwt.SigningMethodRS256, "other", rsaKey, claims()),
+		"issuer":      "",
+		"audience":    "",
+		"expiry":      "",
+		"expired":     "",
+		"client id":   "",
+		"nested":      "",
+	}
+
+	for name, mutate := range map[string]func(jwt.MapClaims){
+		"issuer":    func(c jwt.MapClaims) { c["iss"] = "test_client_1" },
+		"audience":  func(c jwt.MapClaims) { c["aud"] = "https://evil.example.com" },
+		"expiry":    func(c jwt.MapClaims) { delete(c, "exp") },
+		"expired":   func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() },
+		"client id": func(c jwt.MapClaims) { c["client_id"] = "test_client_1" },
+		"nested":    func(c jwt.MapClaims) { c["request_uri"] = "https://app.example.com/request" },
+	} {
+		c := claims()
+		mutate(c)
+		invalid[name] = sign(jwt.SigningMethodRS256, "rsa", rsaKey, c)
+	}
+
+	for name, requestObject := range invalid {
+		_, err := suite.service.ParseRequestObject(client, requestObject, issuer)
+		assert.Equal(suite.T(), oauth.ErrInvalidRequestObject, err, name)
+	}
+
+	// The authorization endpoint ignores the query string of requests with
+	// a request object
+	params, err := suite.service.GetAuthorizationRequest(client, url.Values{
+		"client_id": {client.Key},
+		"request":   {sign(jwt.SigningMethodRS256, "rsa", rsaKey, claims())},
+		"state":     {"other"},
+	}, issuer)
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), "somestate", params.Get("state"))
+	}
+
+	// Clients may restrict the signing algorithm
+	err = suite.service.SetClientAuthorization(client, nil, &oauth.ClientAuthorization{
+		JWKS: &oauth.JSONWebKeySet{Keys: []oauth.JSONWebKey{
+			{Kty: "RSA", Kid: "rsa", N: encode(rsaKey.N.Bytes()), E: encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+		}},
+		RequestObjectSigningAlg: "PS256",
+	})
+	if assert.Nil(suite.T(), err) {
+		_, err = suite.service.ParseRequestObject(client, sign(jwt.SigningMethodRS256, "rsa", rsaKey, claims()), issuer)
+		assert.Equal(suite.T(), oauth.ErrInvalidRequestObject, err)
+		_, err = suite.service.ParseRequestObject(client, sign(jwt.SigningMethodPS256, "rsa", rsaKey, claims()), issuer)
+		assert.Nil(suite.T(), err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/resonatecoop/id/util"
 	"github.com/resonatecoop/id/util/password"
@@ -120,3 +121,87 @@ func (s *Service) createClientCommon(db *bun.DB, clientID, secret, redirectURI,
 
 	return client, nil
 }
+
+// ListClients returns every client ordered by creation date
+func (s *Service) ListClients() ([]*model.Client, error) {
+	ctx := context.Background()
+	var clients []*model.Client
+
+	err := s.db.NewSelect().
+		Model(&clients).
+		Order("created_at ASC").
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+// RotateClientSecret replaces the secret of a client
+func (s *Service) RotateClientSecret(client *model.Client, secret string) error {
+	ctx := context.Background()
+
+	// Hash password
+	secretHash, err := password.HashPassword(secret)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.NewUpdate().
+		Model(client).
+		Set("secret = ?", string(secretHash)).
+		Set("updated_at = ?", time.Now().UTC()).
+		WherePK().
+		Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	client.Secret = string(secretHash)
+
+	return nil
+}
+
+// DeleteClient deletes a client along with the tokens
+// and authorization codes issued to it
+func (s *Service) DeleteClient(client *model.Client) error {
+	ctx := context.Background()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range []interface{}{
+		(*model.AccessToken)(nil),
+		(*model.RefreshToken)(nil),
+		(*model.AuthorizationCode)(nil),
+	} {
+		_, err = tx.NewDelete().
+			Model(m).
+			Where("client_id = ?", client.ID).
+			ForceDelete().
+			Exec(ctx)
+
+		if err != nil {
+			tx.Rollback() // rollback the transaction
+			return err
+		}
+	}
+
+	_, err = tx.NewDelete().
+		Model(client).
+		WherePK().
+		ForceDelete().
+		Exec(ctx)
+
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	return tx.Commit()
+}
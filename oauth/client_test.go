@@ -117,3 +117,45 @@ func (suite *OauthTestSuite) TestAuthClient() {
 		assert.Equal(suite.T(), "test_client_1", client.Key)
 	}
 }
+
+func (suite *OauthTestSuite) TestListClients() {
+	clients, err := suite.service.ListClients()
+	assert.Nil(suite.T(), err)
+
+	if assert.Len(suite.T(), clients, len(suite.clients)) {
+		for i, client := range clients {
+			assert.Equal(suite.T(), suite.clients[i].Key, client.Key)
+		}
+	}
+}
+
+func (suite *OauthTestSuite) TestRotateClientSecret() {
+	client, err := suite.service.CreateClient("test_client_rotate", "old_secret", "", "", "", "")
+	assert.Nil(suite.T(), err)
+
+	err = suite.service.RotateClientSecret(client, "new_secret")
+	assert.Nil(suite.T(), err)
+
+	_, err = suite.service.AuthClient("test_client_rotate", "old_secret")
+	assert.Equal(suite.T(), oauth.ErrInvalidClientSecret, err)
+
+	_, err = suite.service.AuthClient("test_client_rotate", "new_secret")
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *OauthTestSuite) TestDeleteClient() {
+	client, err := suite.service.CreateClient("test_client_delete", "test_secret", "", "", "", "")
+	assert.Nil(suite.T(), err)
+
+	accessToken, err := suite.service.GrantAccessToken(client, suite.users[0], 3600, "read_write")
+	assert.Nil(suite.T(), err)
+
+	err = suite.service.DeleteClient(client)
+	assert.Nil(suite.T(), err)
+
+	assert.False(suite.T(), suite.service.ClientExists("test_client_delete"))
+
+	// Tokens issued to the client have been revoked
+	_, err = suite.service.Authenticate(accessToken.Token)
+	assert.Equal(suite.T(), oauth.ErrAccessTokenNotFound, err)
+}
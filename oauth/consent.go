@@ -0,0 +1,94 @@
+package oauth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+// ClientVerification records that the co-op checked who runs a client and
+// that its registered metadata is accurate
+type ClientVerification struct {
+	bun.BaseModel `bun:"table:client_verifications"`
+
+	ClientID   uuid.UUID `bun:"type:uuid,pk"`
+	VerifiedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// VerifyClient marks a client as verified by the co-op
+func (s *Service) VerifyClient(client *model.Client) error {
+	ctx := context.Background()
+
+	_, err := s.db.NewInsert().
+		Model(&ClientVerification{
+			ClientID:   client.ID,
+			VerifiedAt: time.Now().UTC(),
+		}).
+		On("CONFLICT (client_id) DO UPDATE").
+		Set("verified_at = EXCLUDED.verified_at").
+		Exec(ctx)
+
+	return err
+}
+
+// UnverifyClient withdraws the verification of a client
+func (s *Service) UnverifyClient(client *model.Client) error {
+	ctx := context.Background()
+
+	_, err := s.db.NewDelete().
+		Model((*ClientVerification)(nil)).
+		Where("client_id = ?", client.ID).
+		Exec(ctx)
+
+	return err
+}
+
+// IsClientVerified returns whether the co-op verified a client
+func (s *Service) IsClientVerified(client *model.Client) (bool, error) {
+	ctx := context.Background()
+
+	return s.db.NewSelect().
+		Model((*ClientVerification)(nil)).
+		Where("client_id = ?", client.ID).
+		Exists(ctx)
+}
+
+// IsFirstPartyClient returns whether a client is one of the co-op's own
+// applications listed in the configuration, users are not asked for their
+// consent when they use them
+func (s *Service) IsFirstPartyClient(client *model.Client) bool {
+	for _, clientConfig := range s.cnf.Clients {
+		if clientConfig.ClientID() == client.Key {
+			return true
+		}
+	}
+	return false
+}
+
+// GetConsentedScope narrows a scope to what the user consented to: its
+// default scopes, which cannot be declined, and the optional ones they
+// ticked. It returns ErrInvalidScope when nothing is left.
+func (s *Service) GetConsentedScope(scope string, granted []string) (string, error) {
+	scopes, err := s.FindScopes(scope)
+	if err != nil {
+		return "", err
+	}
+
+	var consented []string
+	for _, sc := range scopes {
+		if sc.IsDefault || util.StringInSlice(sc.Name, granted) {
+			consented = append(consented, sc.Name)
+		}
+	}
+
+	if len(consented) == 0 {
+		return "", ErrInvalidScope
+	}
+
+	return strings.Join(consented, " "), nil
+}
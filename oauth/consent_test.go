@@ -0,0 +1,82 @@
+package oauth_test
+
+import (
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestClientVerification() {
+	registration := &oauth.ClientRegistration{
+		RedirectURIs: []string{"https://app.example.com/callback"},
+		ClientName:   "Example App",
+		ClientURI:    "https://app.example.com",
+	}
+
+	info, err := suite.service.RegisterClient(uuid.Nil, registration)
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+
+	client, err := suite.service.FindClientByClientID(info.ClientID)
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	defer suite.service.DeleteClient(client)
+
+	// Registered clients are not verified
+	verified, err := suite.service.IsClientVerified(client)
+	assert.Nil(suite.T(), err)
+	assert.False(suite.T(), verified)
+
+	assert.Nil(suite.T(), suite.service.VerifyClient(client))
+	assert.Nil(suite.T(), suite.service.VerifyClient(client))
+
+	verified, err = suite.service.IsClientVerified(client)
+	assert.Nil(suite.T(), err)
+	assert.True(suite.T(), verified)
+
+	// Changing the metadata withdraws the verification
+	registration.ClientURI = "https://other.example.com"
+	_, err = suite.service.UpdateClientRegistration(client, registration)
+	assert.Nil(suite.T(), err)
+
+	verified, err = suite.service.IsClientVerified(client)
+	assert.Nil(suite.T(), err)
+	assert.False(suite.T(), verified)
+
+	assert.Nil(suite.T(), suite.service.VerifyClient(client))
+	assert.Nil(suite.T(), suite.service.UnverifyClient(client))
+
+	verified, err = suite.service.IsClientVerified(client)
+	assert.Nil(suite.T(), err)
+	assert.False(suite.T(), verified)
+}
+
+func (suite *OauthTestSuite) TestIsFirstPartyClient() {
+	clients := suite.cnf.Clients
+	defer func() { suite.cnf.Clients = clients }()
+
+	suite.cnf.Clients = []config.ClientConfig{{Name: suite.clients[0].Key}}
+	assert.True(suite.T(), suite.service.IsFirstPartyClient(suite.clients[0]))
+	assert.False(suite.T(), suite.service.IsFirstPartyClient(suite.clients[1]))
+
+	suite.cnf.Clients = nil
+	assert.False(suite.T(), suite.service.IsFirstPartyClient(suite.clients[0]))
+}
+
+func (suite *OauthTestSuite) TestGetConsentedScope() {
+	// Default scopes cannot be declined
+	scope, err := suite.service.GetConsentedScope("read read_write", nil)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "read", scope)
+
+	scope, err = suite.service.GetConsentedScope("read read_write", []string{"read_write", "bogus"})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "read read_write", scope)
+
+	// Declining everything leaves nothing to grant
+	_, err = suite.service.GetConsentedScope("read_write", []string{})
+	assert.Equal(suite.T(), oauth.ErrInvalidScope, err)
+}
@@ -9,7 +9,8 @@ import (
 
 	jwt "github.com/form3tech-oss/jwt-go"
 	uuid "github.com/google/uuid"
-	"github.com/mailgun/mailgun-go/v4"
+	"github.com/resonatecoop/id/metrics"
+	"github.com/resonatecoop/id/tracing"
 	"github.com/resonatecoop/id/util"
 	"github.com/resonatecoop/user-api/model"
 	"github.com/uptrace/bun"
@@ -19,10 +20,30 @@ var (
 	ErrEmailTokenNotFound    = errors.New("this token was not found")
 	ErrEmailTokenInvalid     = errors.New("this token is invalid or has expired")
 	ErrInvalidEmailTokenLink = errors.New("email token link is invalid")
+
+	defaultEmailTokenLifetime = 30 * time.Minute // 30 minutes
 )
 
 // GetValidEmailToken ...
 func (s *Service) GetValidEmailToken(token string) (*model.EmailToken, *model.User, error) {
+	emailToken, claims, err := s.getValidEmailTokenClaims(token)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.FindUserByUsername(claims.Username)
+
+	if err != nil {
+		return nil, nil, ErrEmailTokenNotFound
+	}
+
+	return emailToken, user, nil
+}
+
+// getValidEmailTokenClaims verifies a signed email token and returns
+// the matching email token record along with the token claims
+func (s *Service) getValidEmailTokenClaims(token string) (*model.EmailToken, *model.EmailTokenClaims, error) {
 	ctx := context.Background()
 	claims := &model.EmailTokenClaims{}
 
@@ -53,13 +74,7 @@ func (s *Service) GetValidEmailToken(token string) (*model.EmailToken, *model.Us
 		return nil, nil, ErrEmailTokenNotFound
 	}
 
-	user, err := s.FindUserByUsername(claims.Username)
-
-	if err != nil {
-		return nil, nil, ErrEmailTokenNotFound
-	}
-
-	return emailToken, user, nil
+	return emailToken, claims, nil
 }
 
 // SendEmailToken ...
@@ -92,8 +107,10 @@ func (s *Service) SendEmailTokenTx(
 
 // CreateEmailToken ...
 func (s *Service) CreateEmailToken(email string) (*model.EmailToken, error) {
-	expiresIn := 30 * time.Minute // 30 minutes
+	return s.createEmailTokenCommon(s.db, defaultEmailTokenLifetime)
+}
 
+func (s *Service) createEmailTokenCommon(db *bun.DB, expiresIn time.Duration) (*model.EmailToken, error) {
 	emailToken := model.NewOauthEmailToken(&expiresIn)
 
 	emailToken.EmailSentAt = &time.Time{}
@@ -101,7 +118,7 @@ func (s *Service) CreateEmailToken(email string) (*model.EmailToken, error) {
 
 	ctx := context.Background()
 
-	_, err := s.db.NewInsert().Column(
+	_, err := db.NewInsert().Column(
 		"id",
 		"reference",
 		"email_sent_at",
@@ -143,6 +160,19 @@ func (s *Service) sendEmailTokenCommon(
 ) (
 	*model.EmailToken,
 	error,
+) {
+	return s.sendEmailTokenWithLifetime(db, email, link, defaultEmailTokenLifetime)
+}
+
+// sendEmailTokenWithLifetime sends an email token link valid for expiresIn
+func (s *Service) sendEmailTokenWithLifetime(
+	db *bun.DB,
+	email *model.Email,
+	link string,
+	expiresIn time.Duration,
+) (
+	*model.EmailToken,
+	error,
 ) {
 	// Check if email token link is valid
 	_, err := url.ParseRequestURI(link)
@@ -153,7 +183,7 @@ func (s *Service) sendEmailTokenCommon(
 
 	recipient := email.Recipient
 
-	emailToken, err := s.CreateEmailToken(recipient)
+	emailToken, err := s.createEmailTokenCommon(db, expiresIn)
 
 	if err != nil {
 		return nil, err
@@ -174,12 +204,18 @@ func (s *Service) sendEmailTokenCommon(
 		token,
 	)
 
-	mg := mailgun.NewMailgun(s.cnf.Mailgun.Domain, s.cnf.Mailgun.Key)
-	sender := s.cnf.Mailgun.Sender
+	// The realm of the recipient picks the sender and template
+	rlm, err := s.realms.ForUsername(context.Background(), recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	mg := tracing.NewMailgun(s.cnf.Mailgun.Domain, s.cnf.Mailgun.Key)
+	sender := rlm.Sender(s.cnf)
 	body := ""
 	subject := email.Subject
 	message := mg.NewMessage(sender, subject, body, recipient)
-	message.SetTemplate(email.Template) // set mailgun template
+	message.SetTemplate(rlm.Template(email.Template)) // set mailgun template
 	err = message.AddTemplateVariable("email", email.Recipient)
 	if err != nil {
 		return nil, err
@@ -194,6 +230,7 @@ func (s *Service) sendEmailTokenCommon(
 
 	// Send the message with a 10 second timeout
 	_, _, err = mg.Send(ctx, message)
+	metrics.ObserveEmail(email.Template, err)
 
 	if err != nil {
 		return nil, err
@@ -10,6 +10,7 @@ var (
 		ErrAuthorizationCodeExpired:      http.StatusBadRequest,
 		ErrInvalidRedirectURI:            http.StatusBadRequest,
 		ErrInvalidScope:                  http.StatusBadRequest,
+		ErrInvalidTarget:                 http.StatusBadRequest,
 		ErrInvalidUsernameOrPassword:     http.StatusBadRequest,
 		ErrRefreshTokenNotFound:          http.StatusNotFound,
 		ErrRefreshTokenExpired:           http.StatusBadRequest,
@@ -20,6 +21,11 @@ var (
 		ErrTokenMissing:                  http.StatusBadRequest,
 		ErrTokenHintInvalid:              http.StatusBadRequest,
 		ErrInvalidUsernameOrPassword:     http.StatusUnauthorized,
+		ErrUnauthorizedClient:            http.StatusBadRequest,
+		ErrInvalidSubjectToken:           http.StatusBadRequest,
+		ErrInvalidActorToken:             http.StatusBadRequest,
+		ErrUnsupportedTokenType:          http.StatusBadRequest,
+		ErrImpersonationNotAllowed:       http.StatusForbidden,
 	}
 )
 
@@ -0,0 +1,131 @@
+package oauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/resonatecoop/id/events"
+	"github.com/resonatecoop/id/rbac"
+	"github.com/resonatecoop/id/realm"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestWebhookDelivery() {
+	ctx := context.Background()
+	eventsService := suite.service.GetEventsService()
+
+	up := false
+	received := []map[string]interface{}{}
+	signatureErrors := []error{}
+
+	var secret string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		signatureErrors = append(signatureErrors, events.Verify(secret, r.Header.Get(events.SignatureHeader), body, time.Minute))
+		payload := map[string]interface{}{}
+		json.Unmarshal(body, &payload)
+		received = append(received, payload)
+	}))
+	defer server.Close()
+
+	isDevelopment := suite.cnf.IsDevelopment
+	defer func() { suite.cnf.IsDevelopment = isDevelopment }()
+
+	// Plain http is only allowed in development mode
+	_, err := eventsService.CreateSubscription(ctx, server.URL, nil, "stream")
+	assert.Equal(suite.T(), events.ErrInvalidURL, err)
+
+	suite.cnf.IsDevelopment = true
+
+	_, err = eventsService.CreateSubscription(ctx, server.URL, []string{"user.unknown"}, "stream")
+	assert.Equal(suite.T(), events.ErrUnknownEvent, err)
+
+	subscription, err := eventsService.CreateSubscription(ctx, server.URL, []string{events.UserEmailConfirmed}, "stream")
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	secret = subscription.Secret
+
+	user, err := suite.service.CreateUser(realm.Default(suite.cnf), "webhooks@user.com", "C0mpl3xPa$$w0rdAr3U5", "", nil)
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	defer suite.db.NewDelete().Model(user).WherePK().ForceDelete().Exec(ctx)
+	for _, m := range []interface{}{(*model.Credit)(nil), (*rbac.UserRole)(nil), (*rbac.Assignment)(nil)} {
+		defer suite.db.NewDelete().Model(m).Where("user_id = ?", user.ID).ForceDelete().Exec(ctx)
+	}
+
+	// Only the events subscribed to are delivered
+	deliveries, err := eventsService.ListDeliveries(ctx, subscription, "", 10)
+	assert.Nil(suite.T(), err)
+	assert.Len(suite.T(), deliveries, 0)
+
+	assert.Nil(suite.T(), suite.service.ConfirmUserEmail(user.Username))
+	assert.Nil(suite.T(), suite.service.ConfirmUserEmail(user.Username))
+
+	deliveries, err = eventsService.ListDeliveries(ctx, subscription, events.DeliveryPending, 10)
+	if !assert.Nil(suite.T(), err) || !assert.Len(suite.T(), deliveries, 1) {
+		return
+	}
+	delivery := deliveries[0]
+
+	// Failed deliveries are retried later
+	delivered, err := eventsService.DeliverPending(10)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 0, delivered)
+
+	delivery, _, attempts, err := eventsService.FindDelivery(ctx, delivery.ID.String())
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), events.DeliveryPending, delivery.Status)
+		assert.Equal(suite.T(), 1, delivery.Attempts)
+		assert.True(suite.T(), delivery.NextAttemptAt.After(time.Now()))
+		if assert.Len(suite.T(), attempts, 1) {
+			assert.Equal(suite.T(), http.StatusServiceUnavailable, attempts[0].StatusCode)
+		}
+	}
+
+	// Nothing is due until then
+	up = true
+	delivered, err = eventsService.DeliverPending(10)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 0, delivered)
+
+	_, err = suite.db.NewUpdate().
+		Model(delivery).
+		Set("next_attempt_at = ?", time.Now().UTC()).
+		WherePK().
+		Exec(ctx)
+	assert.Nil(suite.T(), err)
+
+	delivered, err = eventsService.DeliverPending(10)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, delivered)
+
+	if assert.Len(suite.T(), received, 1) {
+		assert.Nil(suite.T(), signatureErrors[0])
+		assert.Equal(suite.T(), events.UserEmailConfirmed, received[0]["type"])
+		assert.Equal(suite.T(), user.ID.String(), received[0]["user_id"])
+	}
+
+	// Replays are new deliveries of the same event
+	replay, err := eventsService.Replay(ctx, delivery)
+	if assert.Nil(suite.T(), err) {
+		assert.NotEqual(suite.T(), delivery.ID, replay.ID)
+		assert.Equal(suite.T(), delivery.EventID, replay.EventID)
+	}
+
+	delivered, err = eventsService.DeliverPending(10)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, delivered)
+	assert.Len(suite.T(), received, 2)
+}
@@ -0,0 +1,82 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/resonatecoop/user-api/model"
+)
+
+var (
+	// ErrInvalidBatchSize ...
+	ErrInvalidBatchSize = errors.New("Batch size must be positive")
+)
+
+// PurgeExpiredAccessTokens permanently deletes expired access tokens,
+// batchSize rows at a time. It returns the number of deleted rows.
+func (s *Service) PurgeExpiredAccessTokens(batchSize int) (int, error) {
+	return s.purgeExpiredCommon((*model.AccessToken)(nil), batchSize)
+}
+
+// PurgeExpiredRefreshTokens permanently deletes expired refresh tokens,
+// batchSize rows at a time. It returns the number of deleted rows.
+func (s *Service) PurgeExpiredRefreshTokens(batchSize int) (int, error) {
+	return s.purgeExpiredCommon((*model.RefreshToken)(nil), batchSize)
+}
+
+// PurgeExpiredAuthorizationCodes permanently deletes expired authorization
+// codes, batchSize rows at a time. It returns the number of deleted rows.
+func (s *Service) PurgeExpiredAuthorizationCodes(batchSize int) (int, error) {
+	return s.purgeExpiredCommon((*model.AuthorizationCode)(nil), batchSize)
+}
+
+// PurgeExpiredEmailTokens permanently deletes expired email tokens,
+// batchSize rows at a time. It returns the number of deleted rows.
+func (s *Service) PurgeExpiredEmailTokens(batchSize int) (int, error) {
+	return s.purgeExpiredCommon((*model.EmailToken)(nil), batchSize)
+}
+
+// purgeExpiredCommon deletes expired rows of the table backing m in batches
+// so a large backlog does not hold locks on the table for too long
+func (s *Service) purgeExpiredCommon(m interface{}, batchSize int) (int, error) {
+	ctx := context.Background()
+
+	// bun drops a zero limit, the loop would never end
+	if batchSize <= 0 {
+		return 0, ErrInvalidBatchSize
+	}
+
+	now := time.Now().UTC()
+	total := 0
+
+	for {
+		batch := s.db.NewSelect().
+			Model(m).
+			Column("id").
+			WhereAllWithDeleted().
+			Where("expires_at <= ?", now).
+			Limit(batchSize)
+
+		res, err := s.db.NewDelete().
+			Model(m).
+			Where("id IN (?)", batch).
+			ForceDelete().
+			Exec(ctx)
+
+		if err != nil {
+			return total, err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+
+		total += int(affected)
+
+		if int(affected) < batchSize {
+			return total, nil
+		}
+	}
+}
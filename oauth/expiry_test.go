@@ -0,0 +1,69 @@
+package oauth_test
+
+import (
+	"context"
+
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestPurgeExpiredAccessTokens() {
+	ctx := context.Background()
+
+	// Insert expired access tokens, GrantAccessToken would delete them
+	for i := 0; i < 3; i++ {
+		accessToken := model.NewOauthAccessToken(suite.clients[0], suite.users[0], -10, "read_write")
+		_, err := suite.db.NewInsert().Model(accessToken).Exec(ctx)
+		assert.NoError(suite.T(), err)
+	}
+
+	// Valid access tokens are kept
+	validToken, err := suite.service.GrantAccessToken(suite.clients[1], suite.users[0], 3600, "read_write")
+	assert.NoError(suite.T(), err)
+
+	// Small batches still remove every expired token
+	purged, err := suite.service.PurgeExpiredAccessTokens(2)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), purged >= 3)
+
+	count, err := suite.db.NewSelect().
+		Model(new(model.AccessToken)).
+		WhereAllWithDeleted().
+		Where("expires_at <= NOW()").
+		Count(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+
+	_, err = suite.service.Authenticate(validToken.Token)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *OauthTestSuite) TestPurgeExpiredAuthorizationCodes() {
+	ctx := context.Background()
+
+	authorizationCode := model.NewOauthAuthorizationCode(suite.clients[0], suite.users[0], -10, "https://www.example.com", "read_write")
+	_, err := suite.db.NewInsert().Model(authorizationCode).Exec(ctx)
+	assert.NoError(suite.T(), err)
+
+	purged, err := suite.service.PurgeExpiredAuthorizationCodes(100)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), purged >= 1)
+
+	exists, err := suite.db.NewSelect().
+		Model(new(model.AuthorizationCode)).
+		Where("code = ?", authorizationCode.Code).
+		Exists(ctx)
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), exists)
+}
+
+func (suite *OauthTestSuite) TestPurgeRefusesInvalidBatchSize() {
+	for _, batchSize := range []int{0, -1} {
+		_, err := suite.service.PurgeExpiredAccessTokens(batchSize)
+		assert.Equal(suite.T(), oauth.ErrInvalidBatchSize, err)
+
+		_, err = suite.service.PurgeStaleSessions(batchSize)
+		assert.Equal(suite.T(), oauth.ErrInvalidBatchSize, err)
+	}
+}
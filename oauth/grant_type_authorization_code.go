@@ -26,16 +26,32 @@ func (s *Service) authorizationCodeGrant(r *http.Request, client *model.Client)
 		return nil, err
 	}
 
+	// Get the resources the token is restricted to
+	resources, err := s.getCodeResources(authorizationCode, r.Form["resource"])
+	if err != nil {
+		return nil, err
+	}
+
 	// Log in the user
 	accessToken, refreshToken, err := s.Login(
 		authorizationCode.Client,
 		authorizationCode.User,
 		authorizationCode.Scope,
+		resources...,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	// The tokens belong to the session the code was granted in
+	sessionIDs, err := s.getAuthorizationCodeSessions(ctx, authorizationCode)
+	if err != nil {
+		return nil, err
+	}
+	if err = addSessionTokens(ctx, s.db, sessionIDs, accessToken, refreshToken); err != nil {
+		return nil, err
+	}
+
 	// Delete the authorization code
 
 	_, err = s.db.NewDelete().
@@ -9,7 +9,13 @@ import (
 
 func (s *Service) clientCredentialsGrant(r *http.Request, client *model.Client) (*AccessTokenResponse, error) {
 	// Get the scope string
-	scope, err := s.GetScope(r.Form.Get("scope"))
+	scope, err := s.getRealmScope(r, client, r.Form.Get("scope"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the resources the token is restricted to
+	resources, err := s.GetResources(client, scope, r.Form["resource"])
 	if err != nil {
 		return nil, err
 	}
@@ -20,6 +26,7 @@ func (s *Service) clientCredentialsGrant(r *http.Request, client *model.Client)
 		nil,                             // empty user
 		s.cnf.Oauth.AccessTokenLifetime, // expires in
 		scope,
+		resources...,
 	)
 	if err != nil {
 		return nil, err
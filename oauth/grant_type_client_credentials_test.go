@@ -14,6 +14,10 @@ import (
 )
 
 func (suite *OauthTestSuite) TestClientCredentialsGrant() {
+	// The client is allowed more than the default scope
+	assert.Nil(suite.T(), suite.service.SetClientScopes(suite.clients[0], []string{"read_write"}))
+	defer suite.service.SetClientScopes(suite.clients[0], nil)
+
 	// Prepare a request
 	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
 	assert.NoError(suite.T(), err, "Request setup should not get an error")
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 
+	"github.com/resonatecoop/id/metrics"
 	"github.com/resonatecoop/id/oauth/tokentypes"
 	"github.com/resonatecoop/user-api/model"
 )
@@ -15,20 +16,32 @@ var (
 
 func (s *Service) passwordGrant(r *http.Request, client *model.Client) (*AccessTokenResponse, error) {
 	// Get the scope string
-	scope, err := s.GetScope(r.Form.Get("scope"))
+	scope, err := s.getRealmScope(r, client, r.Form.Get("scope"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the resources the token is restricted to
+	resources, err := s.GetResources(client, scope, r.Form["resource"])
 	if err != nil {
 		return nil, err
 	}
 
 	// Authenticate the user
-	user, err := s.AuthUser(r.Form.Get("username"), r.Form.Get("password"))
+	user, err := s.AuthUser(r.Context(), r.Form.Get("username"), r.Form.Get("password"))
+	if err == nil {
+		// users of other realms are unknown here
+		err = s.realms.CheckUser(r.Context(), user)
+	}
 	if err != nil {
+		metrics.FailedLogins.WithLabelValues("password_grant").Inc()
+
 		// For security reasons, return a general error message
 		return nil, ErrInvalidUsernameOrPassword
 	}
 
 	// Log in the user
-	accessToken, refreshToken, err := s.Login(client, user, scope)
+	accessToken, refreshToken, err := s.Login(client, user, scope, resources...)
 	if err != nil {
 		return nil, err
 	}
@@ -15,6 +15,10 @@ import (
 )
 
 func (suite *OauthTestSuite) TestPasswordGrant() {
+	// The client is allowed more than the default scope
+	assert.Nil(suite.T(), suite.service.SetClientScopes(suite.clients[0], []string{"read_write", "artist"}))
+	defer suite.service.SetClientScopes(suite.clients[0], nil)
+
 	// Prepare a request
 	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
 	assert.NoError(suite.T(), err, "Request setup should not get an error")
@@ -64,7 +68,11 @@ func (suite *OauthTestSuite) TestPasswordGrant() {
 }
 
 func (suite *OauthTestSuite) TestPasswordGrantWithRoleRestriction() {
-	suite.service.RestrictToRoles(int32(model.SuperAdminRole))
+	// The client is allowed more than the default scope
+	assert.Nil(suite.T(), suite.service.SetClientScopes(suite.clients[0], []string{"read_write", "artist"}))
+	defer suite.service.SetClientScopes(suite.clients[0], nil)
+
+	suite.service.RestrictToRoles(model.SuperAdminRole)
 
 	// Prepare a request
 	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
@@ -89,5 +97,5 @@ func (suite *OauthTestSuite) TestPasswordGrantWithRoleRestriction() {
 		401,
 	)
 
-	suite.service.RestrictToRoles(int32(model.SuperAdminRole), int32(model.AdminRole), int32(model.TenantAdminRole), int32(model.LabelRole), int32(model.ArtistRole), int32(model.UserRole))
+	suite.service.RestrictToRoles(model.SuperAdminRole, model.AdminRole, model.TenantAdminRole, model.LabelRole, model.ArtistRole, model.UserRole)
 }
@@ -1,6 +1,7 @@
 package oauth
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/resonatecoop/id/oauth/tokentypes"
@@ -8,6 +9,7 @@ import (
 )
 
 func (s *Service) refreshTokenGrant(r *http.Request, client *model.Client) (*AccessTokenResponse, error) {
+	ctx := context.Background()
 	// Fetch the refresh token
 	theRefreshToken, err := s.GetValidRefreshToken(r.Form.Get("refresh_token"), client)
 	if err != nil {
@@ -20,16 +22,39 @@ func (s *Service) refreshTokenGrant(r *http.Request, client *model.Client) (*Acc
 		return nil, err
 	}
 
-	// Log in the user
-	accessToken, refreshToken, err := s.Login(
+	// Get the resources the token is restricted to, at most those of the
+	// refresh token
+	granted, err := s.getRefreshTokenResources(theRefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	resources, err := s.narrowResources(client, scope, granted, r.Form["resource"])
+	if err != nil {
+		return nil, err
+	}
+
+	// Log in the user, the refresh token keeps its resources
+	accessToken, refreshToken, err := s.login(
 		theRefreshToken.Client,
 		theRefreshToken.User,
 		scope,
+		resources,
+		granted,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	// The tokens belong to the sessions of the refresh token
+	sessionIDs, err := s.getRefreshTokenSessions(ctx, theRefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if err = addSessionTokens(ctx, s.db, sessionIDs, accessToken, refreshToken); err != nil {
+		return nil, err
+	}
+
 	// Create response
 	accessTokenResponse, err := NewAccessTokenResponse(
 		accessToken,
@@ -281,3 +281,84 @@ func (suite *OauthTestSuite) TestRefreshTokenGrant() {
 	}
 	testutil.TestResponseObject(suite.T(), w, expected, 200)
 }
+
+func (suite *OauthTestSuite) TestRefreshTokenGrantResources() {
+	ctx := context.Background()
+
+	tracks, err := suite.service.CreateResource("https://api.resonate.test/tracks", "tracks", "")
+	if assert.Nil(suite.T(), err) {
+		defer suite.service.DeleteResource(tracks.URI)
+	}
+	payouts, err := suite.service.CreateResource("https://api.resonate.test/payouts", "payouts", "")
+	if assert.Nil(suite.T(), err) {
+		defer suite.service.DeleteResource(payouts.URI)
+	}
+
+	_, refreshToken, err := suite.service.Login(suite.clients[0], suite.users[0], "read_write", tracks.URI)
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+
+	refresh := func(resources ...string) *httptest.ResponseRecorder {
+		r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+		assert.NoError(suite.T(), err, "Request setup should not get an error")
+		r.SetBasicAuth("test_client_1", "test_secret")
+		r.PostForm = url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {refreshToken.Token},
+			"resource":      resources,
+		}
+
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+		return w
+	}
+
+	// The resources of the refresh token cannot be widened
+	testutil.TestResponseForError(
+		suite.T(),
+		refresh(tracks.URI, payouts.URI),
+		oauth.ErrInvalidTarget.Error(),
+		400,
+	)
+
+	// Access tokens default to the resources of the refresh token
+	w := refresh()
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	accessToken := new(model.AccessToken)
+	err = suite.db.NewSelect().
+		Model(accessToken).
+		Order("created_at DESC").
+		Limit(1).
+		Scan(ctx)
+	if assert.Nil(suite.T(), err) {
+		resources, err := suite.service.GetAccessTokenResources(accessToken)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), []string{tracks.URI}, resources)
+	}
+
+	// Refresh tokens granted without resources may be narrowed down
+	_, refreshToken, err = suite.service.Login(suite.clients[1], suite.users[0], "read_write")
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.SetBasicAuth("test_client_2", "test_secret")
+	r.PostForm = url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken.Token},
+		"resource":      {payouts.URI},
+	}
+
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	// The refresh token keeps its resources
+	theRefreshToken, err := suite.service.GetValidRefreshToken(refreshToken.Token, suite.clients[1])
+	assert.Nil(suite.T(), err)
+	assert.NotNil(suite.T(), theRefreshToken)
+}
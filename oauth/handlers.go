@@ -4,6 +4,8 @@ import (
 	"errors"
 	"net/http"
 
+	"github.com/resonatecoop/id/metrics"
+	"github.com/resonatecoop/id/realm"
 	"github.com/resonatecoop/id/util/response"
 	"github.com/resonatecoop/user-api/model"
 )
@@ -15,6 +17,17 @@ var (
 	ErrInvalidClientIDOrSecret = errors.New("Invalid client ID or secret")
 )
 
+// authorizationRequestErrorCodes maps the errors of pushed authorization
+// requests to the error codes of RFC 6749 section 4.1.2.1 and RFC 9101
+var authorizationRequestErrorCodes = map[error]string{
+	ErrInvalidRedirectURI:      "invalid_request",
+	ErrInvalidRequestURI:       "invalid_request",
+	ErrInvalidRequestObject:    "invalid_request_object",
+	ErrUnsupportedResponseType: "unsupported_response_type",
+	ErrUnauthorizedClient:      "unauthorized_client",
+	ErrInvalidScope:            "invalid_scope",
+}
+
 // tokensHandler handles all OAuth 2.0 grant types
 // (POST /v1/oauth/tokens)
 func (s *Service) tokensHandler(w http.ResponseWriter, r *http.Request) {
@@ -26,26 +39,34 @@ func (s *Service) tokensHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Map of grant types against handler functions
 	grantTypes := map[string]func(r *http.Request, client *model.Client) (*AccessTokenResponse, error){
-		"authorization_code": s.authorizationCodeGrant,
-		"password":           s.passwordGrant,
-		"client_credentials": s.clientCredentialsGrant,
-		"refresh_token":      s.refreshTokenGrant,
+		"authorization_code":   s.authorizationCodeGrant,
+		"password":             s.passwordGrant,
+		"client_credentials":   s.clientCredentialsGrant,
+		"refresh_token":        s.refreshTokenGrant,
+		TokenExchangeGrantType: s.tokenExchangeGrant,
 	}
 
 	// Check the grant type
-	grantHandler, ok := grantTypes[r.Form.Get("grant_type")]
+	grantType := r.Form.Get("grant_type")
+	grantHandler, ok := grantTypes[grantType]
 	if !ok {
 		response.Error(w, ErrInvalidGrantType.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// Client auth
-	client, err := s.basicAuthClient(r)
+	client, err := s.authClient(r)
 	if err != nil {
 		response.UnauthorizedError(w, err.Error())
 		return
 	}
 
+	// Registered clients only use the grant types they registered
+	if err := s.CheckGrantType(client, grantType); err != nil {
+		response.Error(w, err.Error(), getErrStatusCode(err))
+		return
+	}
+
 	// Grant processing
 	resp, err := grantHandler(r, client)
 	if err != nil {
@@ -53,6 +74,8 @@ func (s *Service) tokensHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	metrics.GrantsIssued.WithLabelValues(grantType).Inc()
+
 	// Write response to json
 	response.WriteJSON(w, resp, 200)
 }
@@ -61,7 +84,7 @@ func (s *Service) tokensHandler(w http.ResponseWriter, r *http.Request) {
 // (POST /v1/oauth/introspect)
 func (s *Service) introspectHandler(w http.ResponseWriter, r *http.Request) {
 	// Client auth
-	client, err := s.basicAuthClient(r)
+	client, err := s.authClient(r)
 	if err != nil {
 		response.UnauthorizedError(w, err.Error())
 		return
@@ -69,25 +92,112 @@ func (s *Service) introspectHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Introspect the token
 	resp, err := s.introspectToken(r, client)
+
+	// Keep label values bounded
+	tokenTypeHint := r.Form.Get("token_type_hint")
+	switch tokenTypeHint {
+	case "":
+		tokenTypeHint = AccessTokenHint
+	case AccessTokenHint, RefreshTokenHint:
+	default:
+		tokenTypeHint = "invalid"
+	}
+
 	if err != nil {
-		response.Error(w, err.Error(), getErrStatusCode(err))
+		code := getErrStatusCode(err)
+		result := "inactive"
+		if code == http.StatusInternalServerError {
+			result = "error"
+		}
+		metrics.IntrospectionResults.WithLabelValues(tokenTypeHint, result).Inc()
+
+		response.Error(w, err.Error(), code)
 		return
 	}
 
+	metrics.IntrospectionResults.WithLabelValues(tokenTypeHint, "active").Inc()
+
 	// Write response to json
 	response.WriteJSON(w, resp, 200)
 }
 
-// Get client credentials from basic auth and try to authenticate client
-func (s *Service) basicAuthClient(r *http.Request) (*model.Client, error) {
+// parHandler stores the parameters of an authorization request, the client
+// then sends the user to the authorization endpoint with the returned
+// request_uri (RFC 9126)
+// (POST /v1/oauth/par)
+func (s *Service) parHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse the form so r.Form becomes available
+	if err := r.ParseForm(); err != nil {
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Client auth
+	client, err := s.authClient(r)
+	if err != nil {
+		response.UnauthorizedError(w, err.Error())
+		return
+	}
+
+	// request objects are meant for the realm of the request
+	rlm, ok := realm.FromContext(r.Context())
+	if !ok {
+		rlm = realm.Default(s.cnf)
+	}
+
+	requestURI, expiresIn, err := s.PushAuthorizationRequest(client, r.PostForm, rlm.URL(s.cnf, ""))
+	if err == ErrInvalidClientIDOrSecret {
+		response.UnauthorizedError(w, err.Error())
+		return
+	}
+	if err != nil {
+		code, ok := authorizationRequestErrorCodes[err]
+		if !ok {
+			response.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response.WriteJSON(w, map[string]string{
+			"error":             code,
+			"error_description": err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	response.WriteJSON(w, map[string]interface{}{
+		"request_uri": requestURI,
+		"expires_in":  expiresIn,
+	}, http.StatusCreated)
+}
+
+// Get client credentials from basic auth, or from the form for clients
+// registered with client_secret_post, and try to authenticate client
+func (s *Service) authClient(r *http.Request) (*model.Client, error) {
+	authMethod := AuthMethodClientSecretBasic
+
 	// Get client credentials from basic auth
 	clientID, secret, ok := r.BasicAuth()
 	if !ok {
-		return nil, ErrInvalidClientIDOrSecret
+		clientID, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+		if clientID == "" || secret == "" {
+			return nil, ErrInvalidClientIDOrSecret
+		}
+		authMethod = AuthMethodClientSecretPost
 	}
 
 	// Authenticate the client
 	client, err := s.AuthClient(clientID, secret)
+	if err == nil {
+		// clients of other realms are unknown here
+		err = s.realms.CheckClient(r.Context(), client)
+	}
+	if err == nil {
+		// clients authenticate the way they registered
+		var metadata *ClientMetadata
+		metadata, err = s.GetClientMetadata(client)
+		if err == nil && metadata.TokenEndpointAuthMethod != authMethod {
+			err = ErrInvalidClientIDOrSecret
+		}
+	}
 	if err != nil {
 		// For security reasons, return a general error message
 		return nil, ErrInvalidClientIDOrSecret
@@ -0,0 +1,402 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/rbac"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+// Actions recorded in the audit trail of impersonations
+const (
+	// ImpersonationStart is recorded when an admin starts acting as a user
+	ImpersonationStart = "start"
+	// ImpersonationRequest is recorded for every change made while
+	// impersonating, e.g. POST /web/account
+	ImpersonationRequest = "request"
+	// ImpersonationBlocked is recorded when an admin tries something
+	// impersonated sessions may not do, e.g. changing the password
+	ImpersonationBlocked = "blocked"
+	// ImpersonationEnd is recorded when the admin stops or the time is up
+	ImpersonationEnd = "end"
+)
+
+var (
+	// ErrImpersonationNotFound ...
+	ErrImpersonationNotFound = errors.New("Impersonation not found")
+	// ErrImpersonationReasonRequired ...
+	ErrImpersonationReasonRequired = errors.New("Please say why you need to act as this user")
+	// ErrImpersonationEnded ...
+	ErrImpersonationEnded = errors.New("Impersonation ended")
+	// ErrImpersonating ...
+	ErrImpersonating = errors.New("Not allowed while acting as another user")
+)
+
+// Impersonation is an admin acting as a user in the web app, e.g. to
+// reproduce a bug. The session it started ends when the admin stops or after
+// cnf.Oauth.ImpersonationLifetime seconds. Support staff exchanging their
+// token to act as a user are recorded too, without a session.
+type Impersonation struct {
+	bun.BaseModel `bun:"table:impersonations"`
+
+	ID            uuid.UUID `bun:",pk,type:uuid,nullzero,default:uuid_generate_v4()"`
+	ActorID       uuid.UUID `bun:"type:uuid,notnull"`
+	ActorUsername string    `bun:",notnull"`
+	UserID        uuid.UUID `bun:"type:uuid,notnull"`
+	Username      string    `bun:",notnull"`
+	Reason        string    `bun:",notnull"`
+	SessionID     uuid.UUID `bun:"type:uuid,nullzero"`
+	StartedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	ExpiresAt     time.Time `bun:",notnull"`
+	EndedAt       sql.NullTime
+}
+
+// ImpersonationEvent is an entry of the audit trail of an impersonation
+type ImpersonationEvent struct {
+	bun.BaseModel `bun:"table:impersonation_events"`
+
+	ID              uuid.UUID `bun:",pk,type:uuid,nullzero,default:uuid_generate_v4()"`
+	ImpersonationID uuid.UUID `bun:"type:uuid,notnull"`
+	Action          string    `bun:",notnull"`
+	Detail          string    `bun:",notnull"`
+	CreatedAt       time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// IsActive returns true until the impersonation ended or expired
+func (i *Impersonation) IsActive() bool {
+	return !i.EndedAt.Valid && time.Now().UTC().Before(i.ExpiresAt)
+}
+
+// StartImpersonation lets an admin act as a user on a client. The admin
+// needs the users:impersonate permission, other admins cannot be
+// impersonated and both roles must be allowed to use this service. The
+// access token has the admin as actor and no refresh token is issued, so
+// the session cannot outlive the impersonation.
+func (s *Service) StartImpersonation(client *model.Client, actor, user *model.User, reason string) (*Impersonation, *model.AccessToken, error) {
+	ctx := context.Background()
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, nil, ErrImpersonationReasonRequired
+	}
+
+	// Return error if either role is not allowed to use this service
+	if !s.IsRoleAllowed(model.AccessRole(actor.RoleID)) || !s.IsRoleAllowed(model.AccessRole(user.RoleID)) {
+		return nil, nil, ErrImpersonationNotAllowed
+	}
+
+	if err := s.checkImpersonation(ctx, actor, user); err != nil {
+		return nil, nil, err
+	}
+
+	scope, err := s.updateUserScopeWithRole(user, "read_write")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lifetime := s.cnf.Oauth.ImpersonationLifetime
+
+	// Begin a transaction, no token is left without its audit trail
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	accessToken, err := grantAccessToken(ctx, tx, client, user, lifetime, scope)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, nil, err
+	}
+
+	// Resource servers see who acts as the user
+	_, err = tx.NewInsert().
+		Model(&AccessTokenActor{
+			AccessTokenID: accessToken.ID,
+			Act: &Actor{
+				Subject:  actor.ID.String(),
+				Username: actor.Username,
+				ClientID: client.Key,
+			},
+		}).
+		Exec(ctx)
+
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, nil, err
+	}
+
+	// Logging out ends the impersonation and revokes the token
+	userSession, err := startSession(ctx, tx, user, accessToken, nil)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, nil, err
+	}
+
+	impersonation := &Impersonation{
+		ActorID:       actor.ID,
+		ActorUsername: actor.Username,
+		UserID:        user.ID,
+		Username:      user.Username,
+		Reason:        reason,
+		SessionID:     userSession.ID,
+		StartedAt:     accessToken.CreatedAt,
+		ExpiresAt:     accessToken.ExpiresAt,
+	}
+
+	if err = insertImpersonation(ctx, tx, impersonation); err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, nil, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	return impersonation, accessToken, nil
+}
+
+// AuthenticateOwner authenticates the access token of a request only the
+// user may make, i.e. changing their password or email or deleting their
+// account. Tokens obtained by an actor, through impersonation or token
+// exchange, are refused with ErrImpersonating.
+func (s *Service) AuthenticateOwner(token string) (*model.AccessToken, error) {
+	accessToken, err := s.Authenticate(token)
+	if err != nil {
+		return nil, err
+	}
+
+	actor, err := s.GetAccessTokenActor(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if actor != nil {
+		return nil, ErrImpersonating
+	}
+
+	return accessToken, nil
+}
+
+// insertImpersonation stores an impersonation and starts its audit trail
+// with its reason
+func insertImpersonation(ctx context.Context, tx bun.Tx, impersonation *Impersonation) error {
+	_, err := tx.NewInsert().
+		Model(impersonation).
+		Returning("*").
+		Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NewInsert().
+		Model(&ImpersonationEvent{
+			ImpersonationID: impersonation.ID,
+			Action:          ImpersonationStart,
+			Detail:          impersonation.Reason,
+		}).
+		Exec(ctx)
+
+	return err
+}
+
+// FindImpersonation returns an impersonation, whether it is active or not
+func (s *Service) FindImpersonation(id string) (*Impersonation, error) {
+	ctx := context.Background()
+
+	impersonationID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrImpersonationNotFound
+	}
+
+	impersonation := new(Impersonation)
+
+	err = s.db.NewSelect().
+		Model(impersonation).
+		Where("id = ?", impersonationID).
+		Limit(1).
+		Scan(ctx)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrImpersonationNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return impersonation, nil
+}
+
+// checkImpersonation returns ErrImpersonationNotAllowed unless actor may
+// act as user. Nobody acts as themselves, and admins, or anyone else who
+// may impersonate, cannot act as each other.
+func (s *Service) checkImpersonation(ctx context.Context, actor, user *model.User) error {
+	if actor.ID == user.ID {
+		return ErrImpersonationNotAllowed
+	}
+
+	allowed, err := s.rbac.HasPermission(ctx, actor, rbac.PermissionUsersImpersonate)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrImpersonationNotAllowed
+	}
+
+	roleIDs, err := s.rbac.UserRoleIDs(ctx, user)
+	if err != nil {
+		return err
+	}
+	if rbac.IsPrivileged(rbac.PrimaryRoleID(roleIDs)) {
+		return ErrImpersonationNotAllowed
+	}
+
+	allowed, err = s.rbac.HasPermission(ctx, user, rbac.PermissionUsersImpersonate)
+	if err != nil {
+		return err
+	}
+	if allowed {
+		return ErrImpersonationNotAllowed
+	}
+
+	return nil
+}
+
+// EndImpersonation ends an impersonation and its session, which revokes the
+// access token. Ending an impersonation twice does nothing.
+func (s *Service) EndImpersonation(impersonation *Impersonation) error {
+	ctx := context.Background()
+
+	if impersonation.EndedAt.Valid {
+		return nil
+	}
+
+	detail := "stopped"
+	if !impersonation.IsActive() {
+		detail = "expired"
+	}
+
+	// impersonations through token exchange have no session, their token
+	// expires along with them
+	if impersonation.SessionID != uuid.Nil {
+		if _, err := s.EndSession(impersonation.SessionID.String()); err != nil && err != ErrSessionNotFound {
+			return err
+		}
+	}
+
+	impersonation.EndedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	_, err := s.db.NewUpdate().
+		Model(impersonation).
+		Column("ended_at").
+		WherePK().
+		Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return s.RecordImpersonationEvent(impersonation, ImpersonationEnd, detail)
+}
+
+// EndExpiredImpersonations ends the impersonations whose time is up but
+// which the admin never stopped, batchSize at a time, and returns how many
+// were ended
+func (s *Service) EndExpiredImpersonations(batchSize int) (int, error) {
+	ctx := context.Background()
+
+	if batchSize <= 0 {
+		return 0, ErrInvalidBatchSize
+	}
+
+	total := 0
+
+	for {
+		var impersonations []*Impersonation
+
+		err := s.db.NewSelect().
+			Model(&impersonations).
+			Where("ended_at IS NULL").
+			Where("expires_at <= ?", time.Now().UTC()).
+			Order("expires_at ASC").
+			Limit(batchSize).
+			Scan(ctx)
+
+		if err != nil {
+			return total, err
+		}
+
+		for _, impersonation := range impersonations {
+			if err = s.EndImpersonation(impersonation); err != nil {
+				return total, err
+			}
+			total++
+		}
+
+		if len(impersonations) < batchSize {
+			return total, nil
+		}
+	}
+}
+
+// RecordImpersonationEvent adds an entry to the audit trail of an
+// impersonation
+func (s *Service) RecordImpersonationEvent(impersonation *Impersonation, action, detail string) error {
+	ctx := context.Background()
+
+	_, err := s.db.NewInsert().
+		Model(&ImpersonationEvent{
+			ImpersonationID: impersonation.ID,
+			Action:          action,
+			Detail:          detail,
+		}).
+		Exec(ctx)
+
+	return err
+}
+
+// ListImpersonations returns the impersonations of a user and those by a
+// user, most recent first
+func (s *Service) ListImpersonations(user *model.User) ([]*Impersonation, error) {
+	ctx := context.Background()
+	impersonations := []*Impersonation{}
+
+	err := s.db.NewSelect().
+		Model(&impersonations).
+		WhereOr("user_id = ?", user.ID).
+		WhereOr("actor_id = ?", user.ID).
+		Order("started_at DESC").
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return impersonations, nil
+}
+
+// ListImpersonationEvents returns the audit trail of an impersonation in
+// chronological order
+func (s *Service) ListImpersonationEvents(impersonation *Impersonation) ([]*ImpersonationEvent, error) {
+	ctx := context.Background()
+	events := []*ImpersonationEvent{}
+
+	err := s.db.NewSelect().
+		Model(&events).
+		Where("impersonation_id = ?", impersonation.ID).
+		Order("created_at ASC").
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,174 @@
+package oauth_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/rbac"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestImpersonation() {
+	ctx := context.Background()
+	actor, user := suite.users[1], suite.users[0]
+
+	// A reason is required and the actor must be allowed to impersonate
+	_, _, err := suite.service.StartImpersonation(suite.clients[0], actor, user, " ")
+	assert.Equal(suite.T(), oauth.ErrImpersonationReasonRequired, err)
+
+	rbacService := suite.service.GetRBACService()
+	allowed, err := rbacService.HasPermission(ctx, actor, rbac.PermissionUsersImpersonate)
+	if assert.Nil(suite.T(), err) && !allowed {
+		_, _, err = suite.service.StartImpersonation(suite.clients[0], actor, user, "Support ticket #42")
+		assert.Equal(suite.T(), oauth.ErrImpersonationNotAllowed, err)
+	}
+
+	rbacService.CreatePermission(ctx, rbac.PermissionUsersImpersonate, "")
+	support, err := rbacService.CreateRole(ctx, "support", "", []string{rbac.PermissionUsersImpersonate})
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	defer rbacService.DeleteRole(ctx, support)
+
+	assert.Nil(suite.T(), rbacService.AssignRole(ctx, actor, support, uuid.Nil))
+	defer rbacService.RevokeRole(ctx, actor, support, uuid.Nil)
+
+	// Nobody acts as themselves
+	_, _, err = suite.service.StartImpersonation(suite.clients[0], actor, actor, "Support ticket #42")
+	assert.Equal(suite.T(), oauth.ErrImpersonationNotAllowed, err)
+
+	impersonation, accessToken, err := suite.service.StartImpersonation(suite.clients[0], actor, user, "Support ticket #42")
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	assert.True(suite.T(), impersonation.IsActive())
+	assert.Equal(suite.T(), user.ID, accessToken.UserID)
+	assert.WithinDuration(
+		suite.T(),
+		time.Now().UTC().Add(time.Duration(suite.cnf.Oauth.ImpersonationLifetime)*time.Second),
+		impersonation.ExpiresAt,
+		time.Minute,
+	)
+
+	// Resource servers see who acts as the user
+	introspection, err := suite.service.NewIntrospectResponseFromAccessToken(accessToken)
+	if assert.Nil(suite.T(), err) && assert.NotNil(suite.T(), introspection.Act) {
+		assert.Equal(suite.T(), actor.ID.String(), introspection.Act.Subject)
+	}
+
+	// The session was started with the token
+	_, err = suite.service.FindSession(impersonation.SessionID.String())
+	assert.Nil(suite.T(), err)
+
+	// Only the user may change their password, email or delete their account
+	_, err = suite.service.AuthenticateOwner(accessToken.Token)
+	assert.Equal(suite.T(), oauth.ErrImpersonating, err)
+
+	ownToken, err := suite.service.GrantAccessToken(suite.clients[0], user, 3600, "read_write user")
+	if assert.Nil(suite.T(), err) {
+		_, err = suite.service.AuthenticateOwner(ownToken.Token)
+		assert.Nil(suite.T(), err)
+	}
+
+	assert.Nil(suite.T(), suite.service.RecordImpersonationEvent(impersonation, oauth.ImpersonationBlocked, "password change"))
+
+	// Ending the impersonation revokes the token
+	assert.Nil(suite.T(), suite.service.EndImpersonation(impersonation))
+	assert.Nil(suite.T(), suite.service.EndImpersonation(impersonation))
+
+	_, err = suite.service.Authenticate(accessToken.Token)
+	assert.NotNil(suite.T(), err)
+
+	impersonation, err = suite.service.FindImpersonation(impersonation.ID.String())
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	assert.False(suite.T(), impersonation.IsActive())
+
+	// Everything is audited
+	events, err := suite.service.ListImpersonationEvents(impersonation)
+	if assert.Nil(suite.T(), err) && assert.Len(suite.T(), events, 3) {
+		assert.Equal(suite.T(), oauth.ImpersonationStart, events[0].Action)
+		assert.Equal(suite.T(), "Support ticket #42", events[0].Detail)
+		assert.Equal(suite.T(), oauth.ImpersonationBlocked, events[1].Action)
+		assert.Equal(suite.T(), oauth.ImpersonationEnd, events[2].Action)
+	}
+
+	// Both the admin and the user can tell
+	for _, u := range []*model.User{actor, user} {
+		impersonations, err := suite.service.ListImpersonations(u)
+		if assert.Nil(suite.T(), err) && assert.NotEmpty(suite.T(), impersonations) {
+			assert.Equal(suite.T(), impersonation.ID, impersonations[0].ID)
+		}
+	}
+
+	// Users who may impersonate cannot be impersonated
+	assert.Nil(suite.T(), rbacService.AssignRole(ctx, user, support, uuid.Nil))
+	defer rbacService.RevokeRole(ctx, user, support, uuid.Nil)
+
+	_, _, err = suite.service.StartImpersonation(suite.clients[0], actor, user, "Support ticket #42")
+	assert.Equal(suite.T(), oauth.ErrImpersonationNotAllowed, err)
+
+	_, err = suite.service.FindImpersonation("bogus")
+	assert.Equal(suite.T(), oauth.ErrImpersonationNotFound, err)
+}
+
+func (suite *OauthTestSuite) TestEndExpiredImpersonations() {
+	ctx := context.Background()
+	actor, user := suite.users[1], suite.users[0]
+
+	_, err := suite.service.EndExpiredImpersonations(0)
+	assert.Equal(suite.T(), oauth.ErrInvalidBatchSize, err)
+
+	rbacService := suite.service.GetRBACService()
+	rbacService.CreatePermission(ctx, rbac.PermissionUsersImpersonate, "")
+	support, err := rbacService.CreateRole(ctx, "support", "", []string{rbac.PermissionUsersImpersonate})
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	defer rbacService.DeleteRole(ctx, support)
+
+	assert.Nil(suite.T(), rbacService.AssignRole(ctx, actor, support, uuid.Nil))
+	defer rbacService.RevokeRole(ctx, actor, support, uuid.Nil)
+
+	expired, expiredToken, err := suite.service.StartImpersonation(suite.clients[0], actor, user, "Support ticket #42")
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	active, _, err := suite.service.StartImpersonation(suite.clients[0], actor, user, "Support ticket #43")
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+
+	_, err = suite.db.NewUpdate().
+		Model(expired).
+		Set("expires_at = ?", time.Now().UTC().Add(-time.Minute)).
+		WherePK().
+		Exec(ctx)
+	assert.Nil(suite.T(), err)
+
+	// Only impersonations whose time is up are ended
+	ended, err := suite.service.EndExpiredImpersonations(1)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, ended)
+
+	expired, err = suite.service.FindImpersonation(expired.ID.String())
+	if assert.Nil(suite.T(), err) {
+		assert.True(suite.T(), expired.EndedAt.Valid)
+	}
+	_, err = suite.service.Authenticate(expiredToken.Token)
+	assert.NotNil(suite.T(), err)
+
+	active, err = suite.service.FindImpersonation(active.ID.String())
+	if assert.Nil(suite.T(), err) {
+		assert.True(suite.T(), active.IsActive())
+		assert.Nil(suite.T(), suite.service.EndImpersonation(active))
+	}
+
+	ended, err = suite.service.EndExpiredImpersonations(1)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 0, ended)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/resonatecoop/id/oauth/tokentypes"
+	"github.com/resonatecoop/id/realm"
 	"github.com/resonatecoop/id/util"
 	"github.com/resonatecoop/user-api/model"
 )
@@ -51,6 +52,13 @@ func (s *Service) introspectToken(r *http.Request, client *model.Client) (*Intro
 		if err != nil {
 			return nil, err
 		}
+		// tokens issued in another realm are unknown here
+		if err := s.checkTokenRealm(r.Context(), accessToken.ClientID, accessToken.UserID); err != nil {
+			if err == realm.ErrRealmMismatch {
+				return nil, ErrAccessTokenNotFound
+			}
+			return nil, err
+		}
 		return s.NewIntrospectResponseFromAccessToken(accessToken)
 	case RefreshTokenHint:
 		refreshToken, err := s.GetValidRefreshToken(token, client)
@@ -101,6 +109,26 @@ func (s *Service) NewIntrospectResponseFromAccessToken(accessToken *model.Access
 
 		introspectResponse.Username = user.Username
 		introspectResponse.UserID = accessToken.UserID.String()
+
+		// Resource servers authorize users by their effective permissions
+		if err := s.setUserRoles(ctx, introspectResponse, accessToken.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Tokens granted for resources are restricted to their audience
+	audience, err := s.GetAccessTokenResources(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if len(audience) > 0 {
+		introspectResponse.Audience = audience
+	}
+
+	// Tokens obtained on behalf of the user name who acted
+	introspectResponse.Act, err = s.GetAccessTokenActor(accessToken)
+	if err != nil {
+		return nil, err
 	}
 
 	return introspectResponse, nil
@@ -148,3 +176,36 @@ func (s *Service) NewIntrospectResponseFromRefreshToken(refreshToken *model.Refr
 
 	return introspectResponse, nil
 }
+
+// setUserRoles adds the names of the roles held by a user and the
+// permissions they grant to an introspection response
+func (s *Service) setUserRoles(ctx context.Context, introspectResponse *IntrospectResponse, userID uuid.UUID) error {
+	user := new(model.User)
+	err := s.db.NewSelect().
+		Model(user).
+		Column("id", "role_id").
+		Where("id = ?", userID).
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	roles, err := s.rbac.UserRoles(ctx, user)
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		introspectResponse.Roles = append(introspectResponse.Roles, role.Name)
+	}
+
+	permissions, err := s.rbac.UserPermissions(ctx, user)
+	if err != nil {
+		return err
+	}
+	if len(permissions) > 0 {
+		introspectResponse.Permissions = permissions
+	}
+
+	return nil
+}
@@ -34,6 +34,18 @@ func (suite *OauthTestSuite) TestNewIntrospectResponseFromAccessToken() {
 		Username:  suite.users[0].Username,
 	}
 
+	// The roles of the user and the permissions they grant are included
+	roles, err := suite.service.GetRBACService().UserRoles(context.Background(), suite.users[0])
+	assert.NoError(suite.T(), err)
+	for _, role := range roles {
+		expected.Roles = append(expected.Roles, role.Name)
+	}
+	permissions, err := suite.service.GetRBACService().UserPermissions(context.Background(), suite.users[0])
+	assert.NoError(suite.T(), err)
+	if len(permissions) > 0 {
+		expected.Permissions = permissions
+	}
+
 	actual, err := suite.service.NewIntrospectResponseFromAccessToken(accessToken)
 	assert.NoError(suite.T(), err)
 	assert.Equal(suite.T(), expected, actual)
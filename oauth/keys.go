@@ -0,0 +1,182 @@
+package oauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net/http"
+
+	jwt "github.com/form3tech-oss/jwt-go"
+	"github.com/resonatecoop/id/log"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/id/util/response"
+)
+
+var (
+	// ErrNoSigningKey ...
+	ErrNoSigningKey = errors.New("No signing key configured")
+	// ErrInvalidKey ...
+	ErrInvalidKey = errors.New("Invalid or unsupported key")
+)
+
+// signingKey is the RSA key tokens sent to clients are signed with
+type signingKey struct {
+	source string
+	key    *rsa.PrivateKey
+	kid    string
+}
+
+// JSONWebKey is the public part of a signing key (RFC 7517), ours are RSA
+// keys while clients may sign request objects with EC keys
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet lists the keys clients verify our signatures with
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// getSigningKey returns the configured signing key, development mode
+// generates one which lasts until the process exits
+func (s *Service) getSigningKey() (*signingKey, error) {
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+
+	source := s.cnf.Oauth.SigningKey
+
+	// the config may be reloaded
+	if s.key != nil && s.key.source == source {
+		return s.key, nil
+	}
+
+	var (
+		key *rsa.PrivateKey
+		err error
+	)
+	if source != "" {
+		key, err = util.ParseRSAPrivateKey(source)
+	} else if s.cnf.IsDevelopment {
+		log.WARNING.Print("Oauth.SigningKey is not set, generating a temporary signing key")
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	} else {
+		err = ErrNoSigningKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+
+	s.key = &signingKey{
+		source: source,
+		key:    key,
+		kid:    base64.RawURLEncoding.EncodeToString(sum[:12]),
+	}
+
+	return s.key, nil
+}
+
+// signToken signs claims with RS256, typ is the media type of the token
+func (s *Service) signToken(claims jwt.MapClaims, typ string) (string, error) {
+	key, err := s.getSigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.kid
+	if typ != "" {
+		token.Header["typ"] = typ
+	}
+
+	return token.SignedString(key.key)
+}
+
+// GetJSONWebKeySet returns the public signing keys
+func (s *Service) GetJSONWebKeySet() (*JSONWebKeySet, error) {
+	key, err := s.getSigningKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return &JSONWebKeySet{
+		Keys: []JSONWebKey{{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: "RS256",
+			Kid: key.kid,
+			N:   base64.RawURLEncoding.EncodeToString(key.key.PublicKey.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.key.PublicKey.E)).Bytes()),
+		}},
+	}, nil
+}
+
+// publicKey decodes an RSA or P-256 key (RFC 7518 section 6)
+func (k *JSONWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if len(n) < 256 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, ErrInvalidKey
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, ErrInvalidKey
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, ErrInvalidKey
+		}
+		return key, nil
+	default:
+		return nil, ErrInvalidKey
+	}
+}
+
+// jwksHandler publishes the public signing keys
+// (GET /v1/oauth/jwks)
+func (s *Service) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	jwks, err := s.GetJSONWebKeySet()
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response.WriteJSON(w, jwks, http.StatusOK)
+}
@@ -5,18 +5,26 @@ import (
 	"errors"
 	"strings"
 
+	"github.com/resonatecoop/id/util"
 	"github.com/resonatecoop/user-api/model"
 )
 
-// Login creates an access token and refresh token for a user (logs him/her in)
-func (s *Service) Login(client *model.Client, user *model.User, scope string) (*model.AccessToken, *model.RefreshToken, error) {
+// Login creates an access token and refresh token for a user (logs him/her in),
+// the tokens are restricted to the audience of resources when any are given
+func (s *Service) Login(client *model.Client, user *model.User, scope string, resources ...string) (*model.AccessToken, *model.RefreshToken, error) {
+	return s.login(client, user, scope, resources, resources)
+}
+
+// login creates the tokens of Login, the refresh token may be granted for
+// more resources than the access token
+func (s *Service) login(client *model.Client, user *model.User, scope string, resources, refreshResources []string) (*model.AccessToken, *model.RefreshToken, error) {
 
 	if user == nil {
 		return nil, nil, errors.New("valid user must be supplied")
 	}
 
 	// Return error if user's role is not allowed to use this service
-	if !s.IsRoleAllowed(user.RoleID) {
+	if !s.IsRoleAllowed(model.AccessRole(user.RoleID)) {
 		// For security reasons, return a general error message
 		return nil, nil, ErrInvalidUsernameOrPassword
 	}
@@ -33,6 +41,7 @@ func (s *Service) Login(client *model.Client, user *model.User, scope string) (*
 		user,
 		s.cnf.Oauth.AccessTokenLifetime, // expires in
 		scope,
+		resources...,
 	)
 	if err != nil {
 		return nil, nil, err
@@ -44,6 +53,7 @@ func (s *Service) Login(client *model.Client, user *model.User, scope string) (*
 		user,
 		s.cnf.Oauth.RefreshTokenLifetime, // expires in
 		scope,
+		refreshResources...,
 	)
 	if err != nil {
 		return nil, nil, err
@@ -52,28 +62,47 @@ func (s *Service) Login(client *model.Client, user *model.User, scope string) (*
 	return accessToken, refreshToken, nil
 }
 
+// updateUserScopeWithRole replaces any role in the scope with the role of
+// the user, so clients cannot ask for a role the user does not have
 func (s *Service) updateUserScopeWithRole(user *model.User, scope string) (string, error) {
 
 	ctx := context.Background()
 
-	scopes := strings.Split(scope, " ")
-
-	if scopes[0] != "read" && scopes[0] != "read_write" {
-		return "", errors.New("invalid scope format")
-	}
-
-	scopeRole := new(model.Role)
+	var roles []*model.Role
 
 	err := s.db.NewSelect().
-		Model(scopeRole).
-		Where("id = ?", user.RoleID).
+		Model(&roles).
 		Scan(ctx)
 
 	if err != nil {
 		return "", errors.New("problem determining role from user record")
 	}
 
-	scope = scopes[0] + " " + scopeRole.Name
+	var userRole *model.Role
+	roleNames := make([]string, len(roles))
+	for i, role := range roles {
+		roleNames[i] = role.Name
+		if role.ID == user.RoleID {
+			userRole = role
+		}
+	}
+
+	if userRole == nil {
+		return "", errors.New("problem determining role from user record")
+	}
+
+	var scopes []string
+	for _, name := range splitScope(scope) {
+		if !util.StringInSlice(name, roleNames) {
+			scopes = append(scopes, name)
+		}
+	}
+
+	if len(scopes) == 0 {
+		return "", ErrInvalidScope
+	}
+
+	scopes = append(scopes, userRole.Name)
 
-	return scope, nil
+	return strings.Join(scopes, " "), nil
 }
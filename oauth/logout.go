@@ -0,0 +1,138 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/form3tech-oss/jwt-go"
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/log"
+)
+
+const (
+	// backchannelLogoutEvent identifies logout tokens (OpenID Connect
+	// Back-Channel Logout section 2.4)
+	backchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+	// logoutTokenType is the media type of logout tokens
+	logoutTokenType = "logout+jwt"
+	// logoutTokenLifetime is the number of seconds clients may accept a
+	// logout token for
+	logoutTokenLifetime = 120
+)
+
+// NewLogoutToken returns a signed logout token telling a client the session
+// of a user ended, issuer is the URL of the realm the session belongs to
+func (s *Service) NewLogoutToken(issuer string, logout *Logout, client *LogoutClient) (string, error) {
+	now := time.Now().UTC()
+
+	return s.signToken(jwt.MapClaims{
+		"iss": issuer,
+		"aud": client.Client.Key,
+		"iat": now.Unix(),
+		"exp": now.Add(logoutTokenLifetime * time.Second).Unix(),
+		"jti": uuid.New().String(),
+		"sub": logout.Session.UserID.String(),
+		"sid": logout.Session.ID.String(),
+		"events": map[string]interface{}{
+			backchannelLogoutEvent: map[string]interface{}{},
+		},
+	}, logoutTokenType)
+}
+
+// NotifyBackchannelLogout POSTs a logout token to the back-channel logout
+// URI of every client of a logout. Clients are notified concurrently, the
+// first failure is returned once every request completed.
+func (s *Service) NotifyBackchannelLogout(ctx context.Context, issuer string, logout *Logout) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	for _, client := range logout.Clients {
+		if client.Metadata.BackchannelLogoutURI == "" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(client *LogoutClient) {
+			defer wg.Done()
+
+			if err := s.sendLogoutToken(issuer, logout, client); err != nil {
+				log.FromContext(ctx).ERROR.Printf("Back-channel logout of client %s failed: %v", client.Client.Key, err)
+
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(client)
+	}
+
+	wg.Wait()
+
+	return firstErr
+}
+
+// FrontchannelLogoutURIs returns the front-channel logout URIs of the
+// clients of a logout, the user agent loads each of them in an iframe
+func (s *Service) FrontchannelLogoutURIs(issuer string, logout *Logout) []string {
+	uris := []string{}
+
+	for _, client := range logout.Clients {
+		uri := client.Metadata.FrontchannelLogoutURI
+		if uri == "" {
+			continue
+		}
+
+		if client.Metadata.FrontchannelLogoutSessionRequired {
+			u, err := url.Parse(uri)
+			if err != nil {
+				continue
+			}
+			query := u.Query()
+			query.Set("iss", issuer)
+			query.Set("sid", logout.Session.ID.String())
+			u.RawQuery = query.Encode()
+			uri = u.String()
+		}
+
+		uris = append(uris, uri)
+	}
+
+	return uris
+}
+
+// sendLogoutToken delivers a logout token to a single client
+func (s *Service) sendLogoutToken(issuer string, logout *Logout, client *LogoutClient) error {
+	logoutToken, err := s.NewLogoutToken(issuer, logout, client)
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{"logout_token": {logoutToken}}
+
+	req, err := http.NewRequest("POST", client.Metadata.BackchannelLogoutURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.logoutClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("back-channel logout URI responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
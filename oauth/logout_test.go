@@ -0,0 +1,172 @@
+package oauth_test
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	jwt "github.com/form3tech-oss/jwt-go"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestEndSession() {
+	accessToken, refreshToken, err := suite.service.Login(suite.clients[0], suite.users[0], "read_write")
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+
+	userSession, err := suite.service.StartSession(suite.users[0], accessToken, refreshToken)
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+
+	// The tokens of a code granted in the session are tied to it
+	authorizationCode, err := suite.service.GrantAuthorizationCode(
+		suite.clients[0],
+		suite.users[0],
+		3600,
+		"https://www.example.com",
+		"read_write",
+	)
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), suite.service.AddSessionAuthorizationCode(userSession.ID.String(), authorizationCode))
+
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.SetBasicAuth("test_client_1", "test_secret")
+	r.PostForm = url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {authorizationCode.Code},
+		"redirect_uri": {"https://www.example.com"},
+	}
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	exchanged := new(oauth.AccessTokenResponse)
+	assert.Nil(suite.T(), json.NewDecoder(w.Body).Decode(exchanged))
+
+	// Tokens of other logins are left alone
+	otherAccessToken, _, err := suite.service.Login(suite.clients[1], suite.users[0], "read_write")
+	assert.Nil(suite.T(), err)
+
+	logout, err := suite.service.EndSession(userSession.ID.String())
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+
+	assert.True(suite.T(), logout.Session.EndedAt.Valid)
+	if assert.Len(suite.T(), logout.Clients, 1) {
+		assert.Equal(suite.T(), suite.clients[0].ID, logout.Clients[0].Client.ID)
+	}
+
+	_, err = suite.service.Authenticate(accessToken.Token)
+	assert.Equal(suite.T(), oauth.ErrAccessTokenNotFound, err)
+	_, err = suite.service.Authenticate(exchanged.AccessToken)
+	assert.Equal(suite.T(), oauth.ErrAccessTokenNotFound, err)
+	_, err = suite.service.GetValidRefreshToken(refreshToken.Token, suite.clients[0])
+	assert.Equal(suite.T(), oauth.ErrRefreshTokenNotFound, err)
+
+	_, err = suite.service.Authenticate(otherAccessToken.Token)
+	assert.Nil(suite.T(), err)
+
+	// Sessions end once
+	_, err = suite.service.EndSession(userSession.ID.String())
+	assert.Equal(suite.T(), oauth.ErrSessionNotFound, err)
+	_, err = suite.service.EndSession("bogus")
+	assert.Equal(suite.T(), oauth.ErrSessionNotFound, err)
+
+	purged, err := suite.service.PurgeStaleSessions(100)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, purged)
+}
+
+func (suite *OauthTestSuite) TestLogoutNotifications() {
+	logoutTokens := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logoutTokens <- r.PostFormValue("logout_token")
+	}))
+	defer server.Close()
+
+	client := suite.clients[1]
+
+	for _, logout := range []*oauth.ClientLogout{
+		{PostLogoutRedirectURIs: []string{"http://app.example.com/"}},
+		{BackchannelLogoutURI: "coop.resonate.app:/logout"},
+		{FrontchannelLogoutSessionRequired: true},
+	} {
+		assert.NotNil(suite.T(), suite.service.SetClientLogout(client, logout), logout)
+	}
+
+	err := suite.service.SetClientLogout(client, &oauth.ClientLogout{
+		PostLogoutRedirectURIs:            []string{"https://app.example.com/"},
+		BackchannelLogoutURI:              server.URL + "/logout",
+		FrontchannelLogoutURI:             "https://app.example.com/logout",
+		FrontchannelLogoutSessionRequired: true,
+	})
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	defer suite.db.NewDelete().
+		Model((*oauth.ClientMetadata)(nil)).
+		Where("client_id = ?", client.ID).
+		Exec(context.Background())
+
+	assert.True(suite.T(), suite.service.IsValidPostLogoutRedirectURI(client, "https://app.example.com/"))
+	assert.False(suite.T(), suite.service.IsValidPostLogoutRedirectURI(client, "https://evil.example.com/"))
+
+	// Setting up logout keeps the defaults of clients created before
+	// dynamic registration
+	assert.True(suite.T(), suite.service.IsValidRedirectURI(client, client.RedirectURI.String))
+	assert.Nil(suite.T(), suite.service.CheckGrantType(client, "password"))
+
+	accessToken, refreshToken, err := suite.service.Login(client, suite.users[0], "read_write")
+	assert.Nil(suite.T(), err)
+	userSession, err := suite.service.StartSession(suite.users[0], accessToken, refreshToken)
+	assert.Nil(suite.T(), err)
+	logout, err := suite.service.EndSession(userSession.ID.String())
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+
+	issuer := "https://id.resonate.coop"
+
+	assert.Nil(suite.T(), suite.service.NotifyBackchannelLogout(context.Background(), issuer, logout))
+
+	jwks, err := suite.service.GetJSONWebKeySet()
+	if !assert.Nil(suite.T(), err) || !assert.Len(suite.T(), jwks.Keys, 1) {
+		return
+	}
+
+	n, _ := base64.RawURLEncoding.DecodeString(jwks.Keys[0].N)
+	e, _ := base64.RawURLEncoding.DecodeString(jwks.Keys[0].E)
+	publicKey := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(<-logoutTokens, claims, func(token *jwt.Token) (interface{}, error) {
+		return publicKey, nil
+	})
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+
+	assert.Equal(suite.T(), "logout+jwt", token.Header["typ"])
+	assert.Equal(suite.T(), jwks.Keys[0].Kid, token.Header["kid"])
+	assert.Equal(suite.T(), issuer, claims["iss"])
+	assert.Equal(suite.T(), client.Key, claims["aud"])
+	assert.Equal(suite.T(), suite.users[0].ID.String(), claims["sub"])
+	assert.Equal(suite.T(), userSession.ID.String(), claims["sid"])
+	assert.Contains(suite.T(), claims["events"], "http://schemas.openid.net/event/backchannel-logout")
+
+	// Front-channel logout URIs get the issuer and session when required
+	uris := suite.service.FrontchannelLogoutURIs(issuer, logout)
+	if assert.Len(suite.T(), uris, 1) {
+		assert.Equal(suite.T(), "https://app.example.com/logout?iss="+url.QueryEscape(issuer)+"&sid="+userSession.ID.String(), uris[0])
+	}
+}
@@ -459,6 +459,48 @@ func (_m *ServiceInterface) Authenticate(token string) (*model.AccessToken, erro
 
 	return r0, r1
 }
+func (_m *ServiceInterface) AuthenticateAdmin(token string) (*model.User, error) {
+	ret := _m.Called(token)
+
+	var r0 *model.User
+	if rf, ok := ret.Get(0).(func(string) *model.User); ok {
+		r0 = rf(token)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+func (_m *ServiceInterface) AuthenticateOwner(token string) (*model.AccessToken, error) {
+	ret := _m.Called(token)
+
+	var r0 *model.AccessToken
+	if rf, ok := ret.Get(0).(func(string) *model.AccessToken); ok {
+		r0 = rf(token)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.AccessToken)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
 func (_m *ServiceInterface) NewIntrospectResponseFromAccessToken(accessToken *model.AccessToken) (*oauth.IntrospectResponse, error) {
 	ret := _m.Called(accessToken)
 
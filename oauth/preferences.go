@@ -0,0 +1,74 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/i18n"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+var (
+	// ErrLocaleNotSupported ...
+	ErrLocaleNotSupported = errors.New("Language not supported")
+)
+
+// UserPreference holds the settings of a user which the shared user model
+// has no room for
+type UserPreference struct {
+	bun.BaseModel `bun:"table:user_preferences"`
+
+	UserID    uuid.UUID `bun:"type:uuid,pk"`
+	Locale    string    `bun:",notnull"`
+	UpdatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// GetUserLocale returns the language a user picked for the web app, an
+// empty string when they never did
+func (s *Service) GetUserLocale(user *model.User) (string, error) {
+	ctx := context.Background()
+
+	preference := new(UserPreference)
+
+	err := s.db.NewSelect().
+		Model(preference).
+		Where("user_id = ?", user.ID).
+		Limit(1).
+		Scan(ctx)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return preference.Locale, nil
+}
+
+// SetUserLocale saves the language a user picked for the web app
+func (s *Service) SetUserLocale(user *model.User, locale string) error {
+	ctx := context.Background()
+
+	if !i18n.IsSupported(locale) {
+		return ErrLocaleNotSupported
+	}
+
+	_, err := s.db.NewInsert().
+		Model(&UserPreference{
+			UserID:    user.ID,
+			Locale:    locale,
+			UpdatedAt: time.Now().UTC(),
+		}).
+		On("CONFLICT (user_id) DO UPDATE").
+		Set("locale = EXCLUDED.locale").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+
+	return err
+}
@@ -0,0 +1,35 @@
+package oauth_test
+
+import (
+	"context"
+
+	"github.com/resonatecoop/id/oauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestUserLocale() {
+	user := suite.users[0]
+
+	defer suite.db.NewDelete().
+		Model((*oauth.UserPreference)(nil)).
+		Where("user_id = ?", user.ID).
+		Exec(context.Background())
+
+	// Users who never picked a language have none
+	locale, err := suite.service.GetUserLocale(user)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "", locale)
+
+	assert.Equal(suite.T(), oauth.ErrLocaleNotSupported, suite.service.SetUserLocale(user, "xx"))
+
+	assert.Nil(suite.T(), suite.service.SetUserLocale(user, "fr"))
+	assert.Nil(suite.T(), suite.service.SetUserLocale(user, "de"))
+
+	locale, err = suite.service.GetUserLocale(user)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "de", locale)
+
+	locale, err = suite.service.GetUserLocale(suite.users[1])
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "", locale)
+}
@@ -0,0 +1,72 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/realm"
+	"github.com/resonatecoop/user-api/model"
+)
+
+// getRealmScope validates the requested scope against the client and the
+// realm the request was routed to, an empty scope falls back to the default
+// scope of the realm
+func (s *Service) getRealmScope(r *http.Request, client *model.Client, requestedScope string) (string, error) {
+	rlm, ok := realm.FromContext(r.Context())
+	if !ok {
+		return s.GetClientScope(client, requestedScope)
+	}
+
+	if requestedScope == "" {
+		requestedScope = rlm.DefaultScope
+	}
+
+	scope, err := s.GetClientScope(client, requestedScope)
+	if err != nil {
+		return "", err
+	}
+
+	if !rlm.AllowsScope(scope) {
+		return "", ErrInvalidScope
+	}
+
+	return scope, nil
+}
+
+// checkTokenRealm returns realm.ErrRealmMismatch unless the client, or the
+// user for tokens without a client, belongs to the realm of the request
+func (s *Service) checkTokenRealm(ctx context.Context, clientID, userID uuid.UUID) error {
+	var (
+		realmID uuid.UUID
+		err     error
+	)
+
+	switch {
+	case clientID != uuid.Nil:
+		realmID, err = s.realms.ClientRealmID(ctx, clientID)
+	case userID != uuid.Nil:
+		realmID, err = s.realms.UserRealmID(ctx, userID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if realmID != realm.IDFromContext(ctx) {
+		return realm.ErrRealmMismatch
+	}
+
+	return nil
+}
+
+// userRealm returns the realm a user belongs to
+func (s *Service) userRealm(user *model.User) (*realm.Realm, error) {
+	ctx := context.Background()
+
+	realmID, err := s.realms.UserRealmID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.realms.FindByID(ctx, realmID)
+}
@@ -20,8 +20,9 @@ var (
 )
 
 // GetOrCreateRefreshToken retrieves an existing refresh token, if expired,
-// the token gets deleted and new refresh token is created
-func (s *Service) GetOrCreateRefreshToken(client *model.Client, user *model.User, expiresIn int, scope string) (*model.RefreshToken, error) {
+// the token gets deleted and new refresh token is created. Tokens are only
+// reused for the resources they were granted for.
+func (s *Service) GetOrCreateRefreshToken(client *model.Client, user *model.User, expiresIn int, scope string, resources ...string) (*model.RefreshToken, error) {
 	ctx := context.Background()
 	// Try to fetch an existing refresh token first
 	refreshToken := new(model.RefreshToken)
@@ -51,6 +52,15 @@ func (s *Service) GetOrCreateRefreshToken(client *model.Client, user *model.User
 		expired = time.Now().UTC().After(refreshToken.ExpiresAt)
 	}
 
+	// A token granted for other resources is replaced too
+	if err == nil && !expired {
+		granted, err := s.getRefreshTokenResources(refreshToken)
+		if err != nil {
+			return nil, err
+		}
+		expired = !sameResources(granted, resources)
+	}
+
 	var dberr error
 	// If the refresh token has expired, delete it
 	if expired {
@@ -68,18 +78,56 @@ func (s *Service) GetOrCreateRefreshToken(client *model.Client, user *model.User
 
 	// Create a new refresh token if it expired or was not found
 	if expired || (err != nil) {
-		refreshToken = model.NewOauthRefreshToken(client, user, expiresIn, scope)
+		refreshToken, err = s.createRefreshToken(ctx, client, user, expiresIn, scope, resources)
+		if err != nil {
+			return nil, err
+		}
 
-		_, err = s.db.NewInsert().
-			Model(refreshToken).
+		refreshToken.Client = client
+		refreshToken.User = user
+	}
+
+	return refreshToken, nil
+}
+
+// createRefreshToken saves a new refresh token along with the resources
+// it is granted for
+func (s *Service) createRefreshToken(ctx context.Context, client *model.Client, user *model.User, expiresIn int, scope string, resources []string) (*model.RefreshToken, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken := model.NewOauthRefreshToken(client, user, expiresIn, scope)
+
+	_, err = tx.NewInsert().
+		Model(refreshToken).
+		Exec(ctx)
+
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	if len(resources) > 0 {
+		rows := make([]*RefreshTokenResource, len(resources))
+		for i, resource := range resources {
+			rows[i] = &RefreshTokenResource{RefreshTokenID: refreshToken.ID, Resource: resource}
+		}
+
+		_, err = tx.NewInsert().
+			Model(&rows).
 			Exec(ctx)
 
 		if err != nil {
+			tx.Rollback() // rollback the transaction
 			return nil, err
 		}
+	}
 
-		refreshToken.Client = client
-		refreshToken.User = user
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
 	}
 
 	return refreshToken, nil
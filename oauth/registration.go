@@ -0,0 +1,975 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/realm"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/id/util/password"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/rs/xid"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// AuthMethodClientSecretBasic authenticates clients with HTTP basic auth
+	AuthMethodClientSecretBasic = "client_secret_basic"
+	// AuthMethodClientSecretPost authenticates clients with form parameters
+	AuthMethodClientSecretPost = "client_secret_post"
+)
+
+var (
+	// ErrInvalidClientMetadata ...
+	ErrInvalidClientMetadata = errors.New("Invalid client metadata")
+	// ErrUnsupportedGrantType ...
+	ErrUnsupportedGrantType = errors.New("Unsupported grant type")
+	// ErrUnsupportedResponseType ...
+	ErrUnsupportedResponseType = errors.New("Unsupported response type")
+	// ErrUnsupportedAuthMethod ...
+	ErrUnsupportedAuthMethod = errors.New("Unsupported token endpoint auth method")
+	// ErrInvalidInitialAccessToken ...
+	ErrInvalidInitialAccessToken = errors.New("Invalid initial access token")
+	// ErrInvalidRegistrationToken ...
+	ErrInvalidRegistrationToken = errors.New("Invalid registration access token")
+	// ErrInitialAccessTokenNotFound ...
+	ErrInitialAccessTokenNotFound = errors.New("Initial access token not found")
+	// ErrUnauthorizedClient ...
+	ErrUnauthorizedClient = errors.New("Grant type not allowed for client")
+)
+
+var (
+	// registrableGrantTypes lists the grant types registered clients may use,
+	// the password grant is kept for first party clients
+	registrableGrantTypes = []string{"authorization_code", "implicit", "refresh_token", "client_credentials", TokenExchangeGrantType}
+	// registrableAuthMethods lists the supported token endpoint auth methods
+	registrableAuthMethods = []string{AuthMethodClientSecretBasic, AuthMethodClientSecretPost}
+)
+
+// ClientMetadata holds the RFC 7591 metadata of a client. Clients created
+// before dynamic registration have none and may use every grant type.
+type ClientMetadata struct {
+	bun.BaseModel `bun:"table:client_metadata"`
+
+	ClientID                uuid.UUID      `bun:"type:uuid,pk"`
+	RedirectURIs            []string       `bun:"redirect_uris,array"`
+	GrantTypes              []string       `bun:"grant_types,array"`
+	ResponseTypes           []string       `bun:"response_types,array"`
+	TokenEndpointAuthMethod string         `bun:",notnull"`
+	ClientURI               string         `bun:",notnull"`
+	LogoURI                 string         `bun:",notnull"`
+	PolicyURI               string         `bun:",notnull"`
+	TosURI                  string         `bun:",notnull"`
+	Contacts                []string       `bun:"contacts,array"`
+	SoftwareID              string         `bun:",notnull"`
+	SoftwareVersion         string         `bun:",notnull"`
+	RegistrationTokenHash   sql.NullString `bun:"type:varchar(64)"`
+
+	PostLogoutRedirectURIs            []string `bun:"post_logout_redirect_uris,array"`
+	BackchannelLogoutURI              string   `bun:",notnull"`
+	BackchannelLogoutSessionRequired  bool     `bun:",notnull"`
+	FrontchannelLogoutURI             string   `bun:",notnull"`
+	FrontchannelLogoutSessionRequired bool     `bun:",notnull"`
+
+	JWKSURI                            string         `bun:"jwks_uri,notnull"`
+	JWKS                               *JSONWebKeySet `bun:"jwks,type:jsonb"`
+	RequirePushedAuthorizationRequests bool           `bun:",notnull"`
+	RequestObjectSigningAlg            string         `bun:",notnull"`
+
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	UpdatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// InitialAccessToken authorizes dynamic client registration requests in a
+// realm, uuid.Nil being the default realm. Only a hash of the token is stored.
+type InitialAccessToken struct {
+	bun.BaseModel `bun:"table:initial_access_tokens"`
+
+	ID          uuid.UUID `bun:",pk,type:uuid,nullzero,default:uuid_generate_v4()"`
+	RealmID     uuid.UUID `bun:"type:uuid,nullzero"`
+	TokenHash   string    `bun:",notnull"`
+	Description string    `bun:",notnull"`
+	ExpiresAt   sql.NullTime
+	CreatedAt   time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// ClientRegistration is the client metadata of a registration or client
+// configuration request (RFC 7591 section 2)
+type ClientRegistration struct {
+	RedirectURIs            []string `json:"redirect_uris"`
+	GrantTypes              []string `json:"grant_types"`
+	ResponseTypes           []string `json:"response_types"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	ClientName              string   `json:"client_name,omitempty"`
+	ClientURI               string   `json:"client_uri,omitempty"`
+	LogoURI                 string   `json:"logo_uri,omitempty"`
+	PolicyURI               string   `json:"policy_uri,omitempty"`
+	TosURI                  string   `json:"tos_uri,omitempty"`
+	Contacts                []string `json:"contacts,omitempty"`
+	SoftwareID              string   `json:"software_id,omitempty"`
+	SoftwareVersion         string   `json:"software_version,omitempty"`
+	Scope                   string   `json:"scope,omitempty"`
+	ClientLogout
+	ClientAuthorization
+}
+
+// ClientLogout is the logout metadata of a client (OpenID Connect
+// RP-Initiated, Front-Channel and Back-Channel Logout)
+type ClientLogout struct {
+	PostLogoutRedirectURIs            []string `json:"post_logout_redirect_uris,omitempty"`
+	BackchannelLogoutURI              string   `json:"backchannel_logout_uri,omitempty"`
+	BackchannelLogoutSessionRequired  bool     `json:"backchannel_logout_session_required,omitempty"`
+	FrontchannelLogoutURI             string   `json:"frontchannel_logout_uri,omitempty"`
+	FrontchannelLogoutSessionRequired bool     `json:"frontchannel_logout_session_required,omitempty"`
+}
+
+// ClientAuthorization is the metadata of a client securing its
+// authorization requests (RFC 9126 section 6, RFC 9101 section 10.5)
+type ClientAuthorization struct {
+	JWKSURI                            string         `json:"jwks_uri,omitempty"`
+	JWKS                               *JSONWebKeySet `json:"jwks,omitempty"`
+	RequirePushedAuthorizationRequests bool           `json:"require_pushed_authorization_requests,omitempty"`
+	RequestObjectSigningAlg            string         `json:"request_object_signing_alg,omitempty"`
+}
+
+// ClientInformation is the response to registration and client
+// configuration requests (RFC 7591 section 3.2.1, RFC 7592 section 3)
+type ClientInformation struct {
+	ClientID                string `json:"client_id"`
+	ClientSecret            string `json:"client_secret,omitempty"`
+	ClientIDIssuedAt        int64  `json:"client_id_issued_at"`
+	ClientSecretExpiresAt   int64  `json:"client_secret_expires_at"`
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri,omitempty"`
+	ClientRegistration
+}
+
+// CreateInitialAccessToken issues a token allowing clients to be registered
+// in a realm until it expires, expiresIn of 0 never expires
+func (s *Service) CreateInitialAccessToken(realmID uuid.UUID, description string, expiresIn time.Duration) (string, *InitialAccessToken, error) {
+	ctx := context.Background()
+
+	token, err := util.GenerateSecret()
+	if err != nil {
+		return "", nil, err
+	}
+
+	initialAccessToken := &InitialAccessToken{
+		RealmID:     realmID,
+		TokenHash:   hashToken(token),
+		Description: description,
+	}
+	if expiresIn > 0 {
+		initialAccessToken.ExpiresAt = sql.NullTime{Time: time.Now().UTC().Add(expiresIn), Valid: true}
+	}
+
+	_, err = s.db.NewInsert().
+		Model(initialAccessToken).
+		Returning("*").
+		Exec(ctx)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, initialAccessToken, nil
+}
+
+// ListInitialAccessTokens returns every initial access token ordered by
+// creation date
+func (s *Service) ListInitialAccessTokens() ([]*InitialAccessToken, error) {
+	ctx := context.Background()
+	var tokens []*InitialAccessToken
+
+	err := s.db.NewSelect().
+		Model(&tokens).
+		Order("created_at ASC").
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// DeleteInitialAccessToken revokes an initial access token
+func (s *Service) DeleteInitialAccessToken(id uuid.UUID) error {
+	ctx := context.Background()
+
+	res, err := s.db.NewDelete().
+		Model((*InitialAccessToken)(nil)).
+		Where("id = ?", id).
+		Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrInitialAccessTokenNotFound
+	}
+
+	return nil
+}
+
+// AuthInitialAccessToken returns ErrInvalidInitialAccessToken unless token
+// is a known initial access token of the realm which has not expired
+func (s *Service) AuthInitialAccessToken(realmID uuid.UUID, token string) error {
+	ctx := context.Background()
+
+	query := s.db.NewSelect().
+		Model((*InitialAccessToken)(nil)).
+		Where("token_hash = ?", hashToken(token)).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now().UTC())
+
+	if realmID == uuid.Nil {
+		query = query.Where("realm_id IS NULL")
+	} else {
+		query = query.Where("realm_id = ?", realmID)
+	}
+
+	exists, err := query.Exists(ctx)
+
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrInvalidInitialAccessToken
+	}
+
+	return nil
+}
+
+// RegisterClient validates the metadata of a client and registers it in a
+// realm, uuid.Nil being the default realm. The response holds the client
+// secret and the registration access token, neither can be retrieved later.
+func (s *Service) RegisterClient(realmID uuid.UUID, registration *ClientRegistration) (*ClientInformation, error) {
+	ctx := context.Background()
+
+	if err := s.validateRegistration(ctx, registration, nil); err != nil {
+		return nil, err
+	}
+
+	secret, err := util.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	registrationToken, err := util.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	// Hash password
+	secretHash, err := password.HashPassword(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &model.Client{
+		Key:    xid.New().String(),
+		Secret: string(secretHash),
+	}
+	setClientFields(client, registration)
+
+	metadata := newClientMetadata(registration)
+	metadata.RegistrationTokenHash = util.StringOrNull(hashToken(registrationToken))
+
+	// Begin a transaction
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.NewInsert().
+		Model(client).
+		Exec(ctx)
+
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	metadata.ClientID = client.ID
+
+	_, err = tx.NewInsert().
+		Model(metadata).
+		Exec(ctx)
+
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	if err = setClientScopes(ctx, tx, client, splitScope(registration.Scope)); err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	if realmID != uuid.Nil {
+		_, err = tx.NewInsert().
+			Model(&realm.Client{ClientID: client.ID, RealmID: realmID}).
+			Exec(ctx)
+		if err != nil {
+			tx.Rollback() // rollback the transaction
+			return nil, err
+		}
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	info := newClientInformation(client, metadata, registration.Scope)
+	info.ClientSecret = secret
+	info.RegistrationAccessToken = registrationToken
+
+	return info, nil
+}
+
+// AuthRegistrationToken returns the client a registration access token was
+// issued for, clientID must be the key of that client
+func (s *Service) AuthRegistrationToken(clientID, token string) (*model.Client, error) {
+	ctx := context.Background()
+
+	client, err := s.FindClientByClientID(clientID)
+	if err != nil {
+		return nil, ErrInvalidRegistrationToken
+	}
+
+	exists, err := s.db.NewSelect().
+		Model((*ClientMetadata)(nil)).
+		Where("client_id = ?", client.ID).
+		Where("registration_token_hash = ?", hashToken(token)).
+		Exists(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrInvalidRegistrationToken
+	}
+
+	return client, nil
+}
+
+// GetClientInformation returns the current registration of a client
+func (s *Service) GetClientInformation(client *model.Client) (*ClientInformation, error) {
+	metadata, err := s.GetClientMetadata(client)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes, err := s.GetClientScopes(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return newClientInformation(client, metadata, strings.Join(scopes, " ")), nil
+}
+
+// UpdateClientRegistration replaces the metadata of a registered client,
+// omitted fields are reset to their defaults (RFC 7592 section 2.2)
+func (s *Service) UpdateClientRegistration(client *model.Client, registration *ClientRegistration) (*ClientInformation, error) {
+	ctx := context.Background()
+
+	// privileged scopes an admin allowed are kept
+	allowed, err := s.GetClientScopes(client)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.validateRegistration(ctx, registration, allowed); err != nil {
+		return nil, err
+	}
+
+	setClientFields(client, registration)
+	client.UpdatedAt = time.Now().UTC()
+
+	metadata := newClientMetadata(registration)
+	metadata.ClientID = client.ID
+	metadata.UpdatedAt = client.UpdatedAt
+
+	// Begin a transaction
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.NewUpdate().
+		Model(client).
+		Column("redirect_uri", "application_name", "application_hostname", "application_url", "updated_at").
+		WherePK().
+		Exec(ctx)
+
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	// the registration access token stays valid
+	_, err = tx.NewInsert().
+		Model(metadata).
+		ExcludeColumn("registration_token_hash", "created_at").
+		On("CONFLICT (client_id) DO UPDATE").
+		Set("redirect_uris = EXCLUDED.redirect_uris").
+		Set("grant_types = EXCLUDED.grant_types").
+		Set("response_types = EXCLUDED.response_types").
+		Set("token_endpoint_auth_method = EXCLUDED.token_endpoint_auth_method").
+		Set("client_uri = EXCLUDED.client_uri").
+		Set("logo_uri = EXCLUDED.logo_uri").
+		Set("policy_uri = EXCLUDED.policy_uri").
+		Set("tos_uri = EXCLUDED.tos_uri").
+		Set("contacts = EXCLUDED.contacts").
+		Set("software_id = EXCLUDED.software_id").
+		Set("software_version = EXCLUDED.software_version").
+		Set("post_logout_redirect_uris = EXCLUDED.post_logout_redirect_uris").
+		Set("backchannel_logout_uri = EXCLUDED.backchannel_logout_uri").
+		Set("backchannel_logout_session_required = EXCLUDED.backchannel_logout_session_required").
+		Set("frontchannel_logout_uri = EXCLUDED.frontchannel_logout_uri").
+		Set("frontchannel_logout_session_required = EXCLUDED.frontchannel_logout_session_required").
+		Set("jwks_uri = EXCLUDED.jwks_uri").
+		Set("jwks = EXCLUDED.jwks").
+		Set("require_pushed_authorization_requests = EXCLUDED.require_pushed_authorization_requests").
+		Set("request_object_signing_alg = EXCLUDED.request_object_signing_alg").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	if err = setClientScopes(ctx, tx, client, splitScope(registration.Scope)); err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	// the co-op verified the metadata which was just replaced
+	_, err = tx.NewDelete().
+		Model((*ClientVerification)(nil)).
+		Where("client_id = ?", client.ID).
+		Exec(ctx)
+
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetClientInformation(client)
+}
+
+// GetClientMetadata returns the metadata of a client, clients which were not
+// registered dynamically get metadata derived from their redirect URI
+func (s *Service) GetClientMetadata(client *model.Client) (*ClientMetadata, error) {
+	ctx := context.Background()
+	metadata := new(ClientMetadata)
+
+	err := s.db.NewSelect().
+		Model(metadata).
+		Where("client_id = ?", client.ID).
+		Limit(1).
+		Scan(ctx)
+
+	if err == sql.ErrNoRows {
+		metadata = &ClientMetadata{
+			ClientID:                client.ID,
+			RedirectURIs:            []string{},
+			GrantTypes:              []string{},
+			ResponseTypes:           []string{},
+			TokenEndpointAuthMethod: AuthMethodClientSecretBasic,
+			ClientURI:               client.ApplicationURL.String,
+			Contacts:                []string{},
+			PostLogoutRedirectURIs:  []string{},
+			CreatedAt:               client.CreatedAt,
+		}
+		err = nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// clients which only set up logout keep their redirect URI on the client
+	if len(metadata.RedirectURIs) == 0 && client.RedirectURI.String != "" {
+		metadata.RedirectURIs = []string{client.RedirectURI.String}
+	}
+
+	return metadata, nil
+}
+
+// SetClientLogout replaces the logout metadata of a client, clients which
+// were not registered dynamically keep every other default
+func (s *Service) SetClientLogout(client *model.Client, logout *ClientLogout) error {
+	ctx := context.Background()
+
+	if err := validateClientLogout(logout); err != nil {
+		return err
+	}
+
+	metadata := &ClientMetadata{
+		ClientID:                client.ID,
+		RedirectURIs:            []string{},
+		GrantTypes:              []string{},
+		ResponseTypes:           []string{},
+		TokenEndpointAuthMethod: AuthMethodClientSecretBasic,
+		ClientURI:               client.ApplicationURL.String,
+		Contacts:                []string{},
+		UpdatedAt:               time.Now().UTC(),
+	}
+	setClientLogoutFields(metadata, logout)
+
+	_, err := s.db.NewInsert().
+		Model(metadata).
+		ExcludeColumn("registration_token_hash", "created_at").
+		On("CONFLICT (client_id) DO UPDATE").
+		Set("post_logout_redirect_uris = EXCLUDED.post_logout_redirect_uris").
+		Set("backchannel_logout_uri = EXCLUDED.backchannel_logout_uri").
+		Set("backchannel_logout_session_required = EXCLUDED.backchannel_logout_session_required").
+		Set("frontchannel_logout_uri = EXCLUDED.frontchannel_logout_uri").
+		Set("frontchannel_logout_session_required = EXCLUDED.frontchannel_logout_session_required").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+
+	return err
+}
+
+// SetClientAuthorization replaces the redirect URIs and the authorization
+// request metadata of a client, clients which were not registered
+// dynamically keep every other default. Without redirect URIs the one of
+// the client is used.
+func (s *Service) SetClientAuthorization(client *model.Client, redirectURIs []string, authorization *ClientAuthorization) error {
+	ctx := context.Background()
+
+	for _, redirectURI := range redirectURIs {
+		if !isValidRegisteredRedirectURI(redirectURI) {
+			return ErrInvalidRedirectURI
+		}
+	}
+
+	if err := validateClientAuthorization(authorization); err != nil {
+		return err
+	}
+
+	metadata := &ClientMetadata{
+		ClientID:                client.ID,
+		RedirectURIs:            nonNil(redirectURIs),
+		GrantTypes:              []string{},
+		ResponseTypes:           []string{},
+		TokenEndpointAuthMethod: AuthMethodClientSecretBasic,
+		ClientURI:               client.ApplicationURL.String,
+		Contacts:                []string{},
+		PostLogoutRedirectURIs:  []string{},
+		UpdatedAt:               time.Now().UTC(),
+	}
+	setClientAuthorizationFields(metadata, authorization)
+
+	_, err := s.db.NewInsert().
+		Model(metadata).
+		ExcludeColumn("registration_token_hash", "created_at").
+		On("CONFLICT (client_id) DO UPDATE").
+		Set("redirect_uris = EXCLUDED.redirect_uris").
+		Set("jwks_uri = EXCLUDED.jwks_uri").
+		Set("jwks = EXCLUDED.jwks").
+		Set("require_pushed_authorization_requests = EXCLUDED.require_pushed_authorization_requests").
+		Set("request_object_signing_alg = EXCLUDED.request_object_signing_alg").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+
+	return err
+}
+
+// IsValidPostLogoutRedirectURI returns true if redirectURI exactly matches
+// one of the post logout redirect URIs registered for a client
+func (s *Service) IsValidPostLogoutRedirectURI(client *model.Client, redirectURI string) bool {
+	metadata, err := s.GetClientMetadata(client)
+	if err != nil {
+		return false
+	}
+
+	return util.StringInSlice(redirectURI, metadata.PostLogoutRedirectURIs)
+}
+
+// GetClientRedirectURIs returns the redirect URIs registered for a client
+func (s *Service) GetClientRedirectURIs(client *model.Client) ([]string, error) {
+	metadata, err := s.GetClientMetadata(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return metadata.RedirectURIs, nil
+}
+
+// IsValidRedirectURI returns true if redirectURI exactly matches one of the
+// redirect URIs registered for a client. Native apps registering a loopback
+// IP address may use any port (RFC 8252 section 7.3).
+func (s *Service) IsValidRedirectURI(client *model.Client, redirectURI string) bool {
+	redirectURIs, err := s.GetClientRedirectURIs(client)
+	if err != nil {
+		return false
+	}
+
+	for _, registered := range redirectURIs {
+		if matchRedirectURI(registered, redirectURI) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckGrantType returns ErrUnauthorizedClient if a client registered grant
+// types other than grantType
+func (s *Service) CheckGrantType(client *model.Client, grantType string) error {
+	metadata, err := s.GetClientMetadata(client)
+	if err != nil {
+		return err
+	}
+
+	if len(metadata.GrantTypes) > 0 && !util.StringInSlice(grantType, metadata.GrantTypes) {
+		return ErrUnauthorizedClient
+	}
+
+	return nil
+}
+
+// validateRegistration fills in the defaults of RFC 7591 section 2 and
+// checks the metadata is consistent. Privileged scopes are refused unless
+// already allowed for the client.
+func (s *Service) validateRegistration(ctx context.Context, registration *ClientRegistration, allowed []string) error {
+	if len(registration.GrantTypes) == 0 {
+		registration.GrantTypes = []string{"authorization_code"}
+	}
+	if len(registration.ResponseTypes) == 0 && util.StringInSlice("authorization_code", registration.GrantTypes) {
+		registration.ResponseTypes = []string{"code"}
+	}
+	if registration.TokenEndpointAuthMethod == "" {
+		registration.TokenEndpointAuthMethod = AuthMethodClientSecretBasic
+	}
+
+	for _, grantType := range registration.GrantTypes {
+		if !util.StringInSlice(grantType, registrableGrantTypes) {
+			return ErrUnsupportedGrantType
+		}
+	}
+
+	for _, responseType := range registration.ResponseTypes {
+		switch responseType {
+		case "code":
+			if !util.StringInSlice("authorization_code", registration.GrantTypes) {
+				return ErrInvalidClientMetadata
+			}
+		case "token":
+			if !util.StringInSlice("implicit", registration.GrantTypes) {
+				return ErrInvalidClientMetadata
+			}
+		default:
+			return ErrUnsupportedResponseType
+		}
+	}
+
+	if !util.StringInSlice(registration.TokenEndpointAuthMethod, registrableAuthMethods) {
+		return ErrUnsupportedAuthMethod
+	}
+
+	// Redirection based grants need a redirect URI
+	if len(registration.RedirectURIs) == 0 && len(registration.ResponseTypes) > 0 {
+		return ErrInvalidRedirectURI
+	}
+	for _, redirectURI := range registration.RedirectURIs {
+		if !isValidRegisteredRedirectURI(redirectURI) {
+			return ErrInvalidRedirectURI
+		}
+	}
+
+	if len(registration.ClientName) > 200 {
+		return ErrInvalidClientMetadata
+	}
+	for _, uri := range []string{registration.ClientURI, registration.LogoURI, registration.PolicyURI, registration.TosURI} {
+		if uri != "" && !isValidWebURI(uri) {
+			return ErrInvalidClientMetadata
+		}
+	}
+
+	// clients registered without a scope are limited to the default scope
+	if registration.Scope == "" {
+		registration.Scope = s.GetDefaultScope()
+	}
+	if !s.ScopeExists(registration.Scope) {
+		return ErrInvalidScope
+	}
+	privileged, err := s.privilegedScopes(ctx)
+	if err != nil {
+		return err
+	}
+	for _, scope := range splitScope(registration.Scope) {
+		if util.StringInSlice(scope, privileged) && !util.StringInSlice(scope, allowed) {
+			return ErrInvalidScope
+		}
+	}
+
+	if err := validateClientLogout(&registration.ClientLogout); err != nil {
+		return err
+	}
+
+	return validateClientAuthorization(&registration.ClientAuthorization)
+}
+
+// privilegedScopes returns the scopes only an admin may allow clients with
+// SetClientScopes, client credentials tokens would hold them as is: the
+// scopes named after roles, SCIM provisioning and the admin APIs
+func (s *Service) privilegedScopes(ctx context.Context) ([]string, error) {
+	var scopes []string
+
+	err := s.db.NewSelect().
+		Model((*model.Role)(nil)).
+		Column("name").
+		Scan(ctx, &scopes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(scopes, SCIMScope, AdminScope), nil
+}
+
+// validateClientLogout checks the logout URIs, they are held to the same
+// rules as redirect URIs except notifications must go to web URIs
+func validateClientLogout(logout *ClientLogout) error {
+	for _, redirectURI := range logout.PostLogoutRedirectURIs {
+		if !isValidRegisteredRedirectURI(redirectURI) {
+			return ErrInvalidRedirectURI
+		}
+	}
+
+	for _, uri := range []string{logout.BackchannelLogoutURI, logout.FrontchannelLogoutURI} {
+		if uri != "" && (!isValidRegisteredRedirectURI(uri) || !isValidWebURI(uri)) {
+			return ErrInvalidClientMetadata
+		}
+	}
+
+	if logout.BackchannelLogoutURI == "" && logout.BackchannelLogoutSessionRequired {
+		return ErrInvalidClientMetadata
+	}
+	if logout.FrontchannelLogoutURI == "" && logout.FrontchannelLogoutSessionRequired {
+		return ErrInvalidClientMetadata
+	}
+
+	return nil
+}
+
+// validateClientAuthorization checks the keys request objects are verified
+// with, they are either registered or fetched from an https URI
+func validateClientAuthorization(authorization *ClientAuthorization) error {
+	if authorization.JWKSURI != "" && authorization.JWKS != nil {
+		return ErrInvalidClientMetadata
+	}
+
+	if authorization.JWKSURI != "" && (!isValidWebURI(authorization.JWKSURI) || !strings.HasPrefix(authorization.JWKSURI, "https:")) {
+		return ErrInvalidClientMetadata
+	}
+
+	if authorization.JWKS != nil {
+		if len(authorization.JWKS.Keys) == 0 {
+			return ErrInvalidClientMetadata
+		}
+		for i := range authorization.JWKS.Keys {
+			if _, err := authorization.JWKS.Keys[i].publicKey(); err != nil {
+				return ErrInvalidClientMetadata
+			}
+		}
+	}
+
+	alg := authorization.RequestObjectSigningAlg
+	if alg != "" && !util.StringInSlice(alg, requestObjectSigningAlgs) {
+		return ErrInvalidClientMetadata
+	}
+
+	return nil
+}
+
+// setClientFields copies the metadata the clients table has columns for,
+// the first redirect URI is used when authorization requests omit one
+func setClientFields(client *model.Client, registration *ClientRegistration) {
+	client.RedirectURI = sql.NullString{}
+	if len(registration.RedirectURIs) > 0 {
+		client.RedirectURI = util.StringOrNull(registration.RedirectURIs[0])
+	}
+	client.ApplicationName = util.StringOrNull(registration.ClientName)
+	client.ApplicationURL = util.StringOrNull(strings.ToLower(registration.ClientURI))
+	client.ApplicationHostname = sql.NullString{}
+	if u, err := url.Parse(registration.ClientURI); err == nil && registration.ClientURI != "" {
+		client.ApplicationHostname = util.StringOrNull(strings.ToLower(u.Host))
+	}
+}
+
+func newClientMetadata(registration *ClientRegistration) *ClientMetadata {
+	metadata := &ClientMetadata{
+		RedirectURIs:            nonNil(registration.RedirectURIs),
+		GrantTypes:              nonNil(registration.GrantTypes),
+		ResponseTypes:           nonNil(registration.ResponseTypes),
+		TokenEndpointAuthMethod: registration.TokenEndpointAuthMethod,
+		ClientURI:               registration.ClientURI,
+		LogoURI:                 registration.LogoURI,
+		PolicyURI:               registration.PolicyURI,
+		TosURI:                  registration.TosURI,
+		Contacts:                nonNil(registration.Contacts),
+		SoftwareID:              registration.SoftwareID,
+		SoftwareVersion:         registration.SoftwareVersion,
+	}
+	setClientLogoutFields(metadata, &registration.ClientLogout)
+	setClientAuthorizationFields(metadata, &registration.ClientAuthorization)
+
+	return metadata
+}
+
+func setClientLogoutFields(metadata *ClientMetadata, logout *ClientLogout) {
+	metadata.PostLogoutRedirectURIs = nonNil(logout.PostLogoutRedirectURIs)
+	metadata.BackchannelLogoutURI = logout.BackchannelLogoutURI
+	metadata.BackchannelLogoutSessionRequired = logout.BackchannelLogoutSessionRequired
+	metadata.FrontchannelLogoutURI = logout.FrontchannelLogoutURI
+	metadata.FrontchannelLogoutSessionRequired = logout.FrontchannelLogoutSessionRequired
+}
+
+func setClientAuthorizationFields(metadata *ClientMetadata, authorization *ClientAuthorization) {
+	metadata.JWKSURI = authorization.JWKSURI
+	metadata.JWKS = authorization.JWKS
+	metadata.RequirePushedAuthorizationRequests = authorization.RequirePushedAuthorizationRequests
+	metadata.RequestObjectSigningAlg = authorization.RequestObjectSigningAlg
+}
+
+func newClientInformation(client *model.Client, metadata *ClientMetadata, scope string) *ClientInformation {
+	issuedAt := metadata.CreatedAt
+	if issuedAt.IsZero() {
+		issuedAt = time.Now().UTC()
+	}
+
+	return &ClientInformation{
+		ClientID:         client.Key,
+		ClientIDIssuedAt: issuedAt.Unix(),
+		// client secrets do not expire
+		ClientSecretExpiresAt: 0,
+		ClientRegistration: ClientRegistration{
+			RedirectURIs:            metadata.RedirectURIs,
+			GrantTypes:              metadata.GrantTypes,
+			ResponseTypes:           metadata.ResponseTypes,
+			TokenEndpointAuthMethod: metadata.TokenEndpointAuthMethod,
+			ClientName:              client.ApplicationName.String,
+			ClientURI:               metadata.ClientURI,
+			LogoURI:                 metadata.LogoURI,
+			PolicyURI:               metadata.PolicyURI,
+			TosURI:                  metadata.TosURI,
+			Contacts:                metadata.Contacts,
+			SoftwareID:              metadata.SoftwareID,
+			SoftwareVersion:         metadata.SoftwareVersion,
+			Scope:                   scope,
+			ClientLogout: ClientLogout{
+				PostLogoutRedirectURIs:            metadata.PostLogoutRedirectURIs,
+				BackchannelLogoutURI:              metadata.BackchannelLogoutURI,
+				BackchannelLogoutSessionRequired:  metadata.BackchannelLogoutSessionRequired,
+				FrontchannelLogoutURI:             metadata.FrontchannelLogoutURI,
+				FrontchannelLogoutSessionRequired: metadata.FrontchannelLogoutSessionRequired,
+			},
+			ClientAuthorization: ClientAuthorization{
+				JWKSURI:                            metadata.JWKSURI,
+				JWKS:                               metadata.JWKS,
+				RequirePushedAuthorizationRequests: metadata.RequirePushedAuthorizationRequests,
+				RequestObjectSigningAlg:            metadata.RequestObjectSigningAlg,
+			},
+		},
+	}
+}
+
+// isValidRegisteredRedirectURI returns true for absolute URIs without a
+// fragment, plain http is only allowed for loopback addresses
+func isValidRegisteredRedirectURI(redirectURI string) bool {
+	u, err := url.Parse(redirectURI)
+	if err != nil || !u.IsAbs() || u.Fragment != "" || strings.Contains(redirectURI, "#") || len(redirectURI) > 200 {
+		return false
+	}
+
+	switch u.Scheme {
+	case "https":
+		return u.Host != ""
+	case "http":
+		return isLoopback(u.Hostname())
+	default:
+		// private-use schemes of native apps, e.g. coop.resonate.app:/callback
+		return strings.Contains(u.Scheme, ".")
+	}
+}
+
+// isValidWebURI returns true for absolute http(s) URIs
+func isValidWebURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || len(uri) > 200 {
+		return false
+	}
+	return (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+// matchRedirectURI compares redirect URIs as strings, except for the port
+// of http redirect URIs registered with a loopback IP address
+func matchRedirectURI(registered, redirectURI string) bool {
+	if registered == redirectURI {
+		return true
+	}
+
+	r, err := url.Parse(registered)
+	if err != nil || r.Scheme != "http" || !isLoopbackIP(r.Hostname()) {
+		return false
+	}
+
+	u, err := url.Parse(redirectURI)
+	if err != nil || u.Scheme != "http" || u.Hostname() != r.Hostname() {
+		return false
+	}
+
+	r.Host = strings.TrimSuffix(r.Host, ":"+r.Port())
+	u.Host = strings.TrimSuffix(u.Host, ":"+u.Port())
+
+	return r.String() == u.String()
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	return isLoopbackIP(host)
+}
+
+// isLoopbackIP returns true for loopback IP literals, native apps should
+// not rely on localhost resolving to them (RFC 8252 section 8.3)
+func isLoopbackIP(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// hashToken returns the hex encoded SHA-256 of a token, registration tokens
+// are random so they need no salt
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
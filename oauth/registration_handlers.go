@@ -0,0 +1,173 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/resonatecoop/id/realm"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/id/util/response"
+	"github.com/resonatecoop/user-api/model"
+)
+
+// registrationErrorCodes maps errors to the error codes of RFC 7591 section 3.2.2
+var registrationErrorCodes = map[error]string{
+	ErrInvalidRedirectURI:      "invalid_redirect_uri",
+	ErrInvalidClientMetadata:   "invalid_client_metadata",
+	ErrUnsupportedGrantType:    "invalid_client_metadata",
+	ErrUnsupportedResponseType: "invalid_client_metadata",
+	ErrUnsupportedAuthMethod:   "invalid_client_metadata",
+	ErrInvalidScope:            "invalid_client_metadata",
+}
+
+// registerHandler registers a client (RFC 7591)
+// (POST /v1/oauth/register)
+func (s *Service) registerHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := util.ParseBearerToken(r)
+	if err != nil {
+		response.UnauthorizedError(w, ErrInvalidInitialAccessToken.Error())
+		return
+	}
+
+	// initial access tokens only register clients in their own realm
+	realmID := realm.IDFromContext(r.Context())
+	if err := s.AuthInitialAccessToken(realmID, string(token)); err != nil {
+		if err == ErrInvalidInitialAccessToken {
+			response.UnauthorizedError(w, err.Error())
+			return
+		}
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	registration := new(ClientRegistration)
+	if err := json.NewDecoder(r.Body).Decode(registration); err != nil {
+		writeRegistrationError(w, ErrInvalidClientMetadata)
+		return
+	}
+
+	// clients belong to the realm they were registered in
+	info, err := s.RegisterClient(realmID, registration)
+	if err != nil {
+		writeRegistrationError(w, err)
+		return
+	}
+
+	info.RegistrationClientURI = s.registrationClientURI(r, info.ClientID)
+
+	response.WriteJSON(w, info, http.StatusCreated)
+}
+
+// clientConfigurationHandler reads the registration of a client (RFC 7592)
+// (GET /v1/oauth/register/{client_id})
+func (s *Service) clientConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	client, ok := s.registrationClient(w, r)
+	if !ok {
+		return
+	}
+
+	info, err := s.GetClientInformation(client)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	info.RegistrationClientURI = s.registrationClientURI(r, info.ClientID)
+
+	response.WriteJSON(w, info, http.StatusOK)
+}
+
+// updateClientConfigurationHandler replaces the registration of a client
+// (PUT /v1/oauth/register/{client_id})
+func (s *Service) updateClientConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	client, ok := s.registrationClient(w, r)
+	if !ok {
+		return
+	}
+
+	req := new(ClientInformation)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		writeRegistrationError(w, ErrInvalidClientMetadata)
+		return
+	}
+
+	// the client ID cannot be changed
+	if req.ClientID != client.Key {
+		writeRegistrationError(w, ErrInvalidClientMetadata)
+		return
+	}
+
+	info, err := s.UpdateClientRegistration(client, &req.ClientRegistration)
+	if err != nil {
+		writeRegistrationError(w, err)
+		return
+	}
+
+	info.RegistrationClientURI = s.registrationClientURI(r, info.ClientID)
+
+	response.WriteJSON(w, info, http.StatusOK)
+}
+
+// deleteClientConfigurationHandler deregisters a client
+// (DELETE /v1/oauth/register/{client_id})
+func (s *Service) deleteClientConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	client, ok := s.registrationClient(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.DeleteClient(client); err != nil {
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// registrationClient authenticates the registration access token of a client
+// configuration request, writing the error response when it fails
+func (s *Service) registrationClient(w http.ResponseWriter, r *http.Request) (*model.Client, bool) {
+	token, err := util.ParseBearerToken(r)
+	if err != nil {
+		response.UnauthorizedError(w, ErrInvalidRegistrationToken.Error())
+		return nil, false
+	}
+
+	client, err := s.AuthRegistrationToken(mux.Vars(r)["client_id"], string(token))
+	if err == nil {
+		// clients of other realms are unknown here
+		err = s.realms.CheckClient(r.Context(), client)
+	}
+	if err != nil {
+		// RFC 7592 section 2: unknown clients and invalid tokens look alike
+		response.UnauthorizedError(w, ErrInvalidRegistrationToken.Error())
+		return nil, false
+	}
+
+	return client, true
+}
+
+// registrationClientURI returns the client configuration endpoint of a client
+// in the realm of the request
+func (s *Service) registrationClientURI(r *http.Request, clientID string) string {
+	rlm, ok := realm.FromContext(r.Context())
+	if !ok {
+		rlm = realm.Default(s.cnf)
+	}
+	return rlm.URL(s.cnf, "/v1/oauth"+registerPath+"/"+clientID)
+}
+
+// writeRegistrationError writes an RFC 7591 error response
+func writeRegistrationError(w http.ResponseWriter, err error) {
+	code, ok := registrationErrorCodes[err]
+	if !ok {
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response.WriteJSON(w, map[string]string{
+		"error":             code,
+		"error_description": err.Error(),
+	}, http.StatusBadRequest)
+}
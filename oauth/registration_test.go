@@ -0,0 +1,289 @@
+package oauth_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/realm"
+	testutil "github.com/resonatecoop/id/test-util"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestInitialAccessTokens() {
+	ctx := context.Background()
+
+	token, initialAccessToken, err := suite.service.CreateInitialAccessToken(uuid.Nil, "Upload tool", time.Hour)
+	if assert.Nil(suite.T(), err) {
+		defer suite.service.DeleteInitialAccessToken(initialAccessToken.ID)
+	}
+	assert.True(suite.T(), initialAccessToken.ExpiresAt.Valid)
+
+	assert.Nil(suite.T(), suite.service.AuthInitialAccessToken(uuid.Nil, token))
+	assert.Equal(suite.T(), oauth.ErrInvalidInitialAccessToken, suite.service.AuthInitialAccessToken(uuid.Nil, "bogus"))
+
+	// Expired tokens are refused
+	expired, expiredToken, err := suite.service.CreateInitialAccessToken(uuid.Nil, "", time.Nanosecond)
+	if assert.Nil(suite.T(), err) {
+		defer suite.service.DeleteInitialAccessToken(expiredToken.ID)
+	}
+	time.Sleep(time.Millisecond)
+	assert.Equal(suite.T(), oauth.ErrInvalidInitialAccessToken, suite.service.AuthInitialAccessToken(uuid.Nil, expired))
+
+	// Tokens only register clients in their own realm
+	rlm := &realm.Realm{Slug: "registration", Name: "Registration"}
+	if assert.Nil(suite.T(), suite.service.GetRealmService().Create(ctx, rlm)) {
+		defer suite.service.GetRealmService().Delete(ctx, rlm)
+	}
+
+	tenantToken, tenantInitialAccessToken, err := suite.service.CreateInitialAccessToken(rlm.ID, "", 0)
+	if assert.Nil(suite.T(), err) {
+		defer suite.service.DeleteInitialAccessToken(tenantInitialAccessToken.ID)
+	}
+	assert.Nil(suite.T(), suite.service.AuthInitialAccessToken(rlm.ID, tenantToken))
+	assert.Equal(suite.T(), oauth.ErrInvalidInitialAccessToken, suite.service.AuthInitialAccessToken(uuid.Nil, tenantToken))
+	assert.Equal(suite.T(), oauth.ErrInvalidInitialAccessToken, suite.service.AuthInitialAccessToken(rlm.ID, token))
+
+	tokens, err := suite.service.ListInitialAccessTokens()
+	assert.Nil(suite.T(), err)
+	assert.Len(suite.T(), tokens, 3)
+
+	assert.Equal(suite.T(), oauth.ErrInitialAccessTokenNotFound, suite.service.DeleteInitialAccessToken(uuid.New()))
+}
+
+func (suite *OauthTestSuite) TestRegisterClient() {
+	ctx := context.Background()
+
+	for _, testCase := range []struct {
+		registration *oauth.ClientRegistration
+		err          error
+	}{
+		{&oauth.ClientRegistration{}, oauth.ErrInvalidRedirectURI},
+		{&oauth.ClientRegistration{RedirectURIs: []string{"/callback"}}, oauth.ErrInvalidRedirectURI},
+		{&oauth.ClientRegistration{RedirectURIs: []string{"http://app.example.com/callback"}}, oauth.ErrInvalidRedirectURI},
+		{&oauth.ClientRegistration{RedirectURIs: []string{"https://app.example.com/callback#x"}}, oauth.ErrInvalidRedirectURI},
+		{&oauth.ClientRegistration{RedirectURIs: []string{"https://app.example.com/callback"}, GrantTypes: []string{"password"}}, oauth.ErrUnsupportedGrantType},
+		{&oauth.ClientRegistration{RedirectURIs: []string{"https://app.example.com/callback"}, ResponseTypes: []string{"token"}}, oauth.ErrInvalidClientMetadata},
+		{&oauth.ClientRegistration{RedirectURIs: []string{"https://app.example.com/callback"}, TokenEndpointAuthMethod: "private_key_jwt"}, oauth.ErrUnsupportedAuthMethod},
+		{&oauth.ClientRegistration{RedirectURIs: []string{"https://app.example.com/callback"}, LogoURI: "logo.png"}, oauth.ErrInvalidClientMetadata},
+		{&oauth.ClientRegistration{RedirectURIs: []string{"https://app.example.com/callback"}, Scope: "bogus"}, oauth.ErrInvalidScope},
+		{&oauth.ClientRegistration{GrantTypes: []string{"client_credentials"}, Scope: "read scim"}, oauth.ErrInvalidScope},
+		{&oauth.ClientRegistration{GrantTypes: []string{"client_credentials"}, Scope: "admin"}, oauth.ErrInvalidScope},
+		{&oauth.ClientRegistration{GrantTypes: []string{"client_credentials"}, Scope: "read label"}, oauth.ErrInvalidScope},
+		{&oauth.ClientRegistration{GrantTypes: []string{"client_credentials"}, Scope: "artist"}, oauth.ErrInvalidScope},
+		{&oauth.ClientRegistration{GrantTypes: []string{"client_credentials"}, Scope: oauth.AdminScope}, oauth.ErrInvalidScope},
+		{&oauth.ClientRegistration{RedirectURIs: []string{"https://app.example.com/callback"}, ClientAuthorization: oauth.ClientAuthorization{JWKS: &oauth.JSONWebKeySet{}}}, oauth.ErrInvalidClientMetadata},
+	} {
+		_, err := suite.service.RegisterClient(uuid.Nil, testCase.registration)
+		assert.Equal(suite.T(), testCase.err, err, testCase.registration)
+	}
+
+	info, err := suite.service.RegisterClient(uuid.Nil, &oauth.ClientRegistration{
+		RedirectURIs: []string{"https://app.example.com/callback", "http://127.0.0.1:8080/callback"},
+		GrantTypes:   []string{"authorization_code", "refresh_token"},
+		ClientName:   "Example App",
+		ClientURI:    "https://app.example.com",
+		Scope:        "read",
+	})
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+
+	client, err := suite.service.FindClientByClientID(info.ClientID)
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	defer suite.service.DeleteClient(client)
+
+	// Defaults are filled in
+	assert.Equal(suite.T(), []string{"code"}, info.ResponseTypes)
+	assert.Equal(suite.T(), oauth.AuthMethodClientSecretBasic, info.TokenEndpointAuthMethod)
+	assert.NotEmpty(suite.T(), info.ClientSecret)
+	assert.NotEmpty(suite.T(), info.RegistrationAccessToken)
+
+	// The secret authenticates the client
+	_, err = suite.service.AuthClient(info.ClientID, info.ClientSecret)
+	assert.Nil(suite.T(), err)
+
+	// The first redirect URI is kept on the client record
+	assert.Equal(suite.T(), "https://app.example.com/callback", client.RedirectURI.String)
+	assert.Equal(suite.T(), "app.example.com", client.ApplicationHostname.String)
+	assert.True(suite.T(), suite.service.IsValidRedirectURI(client, "http://127.0.0.1:8080/callback"))
+	assert.True(suite.T(), suite.service.IsValidRedirectURI(client, "http://127.0.0.1:51004/callback"))
+	assert.False(suite.T(), suite.service.IsValidRedirectURI(client, "https://app.example.com/other"))
+
+	// Only the registered grant types may be used
+	assert.Nil(suite.T(), suite.service.CheckGrantType(client, "refresh_token"))
+	assert.Equal(suite.T(), oauth.ErrUnauthorizedClient, suite.service.CheckGrantType(client, "password"))
+
+	scopes, err := suite.service.GetClientScopes(client)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"read"}, scopes)
+
+	// Privileged scopes cannot be added by updating the registration
+	registration := &oauth.ClientRegistration{
+		RedirectURIs: []string{"https://app.example.com/callback"},
+		Scope:        "read scim",
+	}
+	_, err = suite.service.UpdateClientRegistration(client, registration)
+	assert.Equal(suite.T(), oauth.ErrInvalidScope, err)
+
+	// unless an admin allowed them
+	assert.Nil(suite.T(), suite.service.SetClientScopes(client, []string{"read", "scim"}))
+	_, err = suite.service.UpdateClientRegistration(client, registration)
+	assert.Nil(suite.T(), err)
+
+	// Clients registered without a scope are limited to the default scope
+	info, err = suite.service.RegisterClient(uuid.Nil, &oauth.ClientRegistration{
+		GrantTypes: []string{"client_credentials"},
+	})
+	if assert.Nil(suite.T(), err) {
+		other, err := suite.service.FindClientByClientID(info.ClientID)
+		if assert.Nil(suite.T(), err) {
+			defer suite.service.DeleteClient(other)
+		}
+		assert.Equal(suite.T(), suite.service.GetDefaultScope(), info.Scope)
+
+		_, err = suite.service.GetClientScope(other, "scim")
+		assert.Equal(suite.T(), oauth.ErrInvalidScope, err)
+	}
+
+	// Clients are assigned to the realm they are registered in
+	rlm := &realm.Realm{Slug: "registered", Name: "Registered"}
+	if assert.Nil(suite.T(), suite.service.GetRealmService().Create(ctx, rlm)) {
+		defer suite.service.GetRealmService().Delete(ctx, rlm)
+	}
+
+	info, err = suite.service.RegisterClient(rlm.ID, &oauth.ClientRegistration{
+		GrantTypes: []string{"client_credentials"},
+	})
+	if assert.Nil(suite.T(), err) {
+		other, err := suite.service.FindClientByClientID(info.ClientID)
+		if assert.Nil(suite.T(), err) {
+			defer suite.service.DeleteClient(other)
+
+			realmID, err := suite.service.GetRealmService().ClientRealmID(ctx, other.ID)
+			assert.Nil(suite.T(), err)
+			assert.Equal(suite.T(), rlm.ID, realmID)
+		}
+	}
+
+	// Clients created before dynamic registration may use every grant type
+	assert.Nil(suite.T(), suite.service.CheckGrantType(suite.clients[0], "password"))
+}
+
+func (suite *OauthTestSuite) TestClientRegistrationHandlers() {
+	token, initialAccessToken, err := suite.service.CreateInitialAccessToken(uuid.Nil, "", 0)
+	if assert.Nil(suite.T(), err) {
+		defer suite.service.DeleteInitialAccessToken(initialAccessToken.ID)
+	}
+
+	body := `{"redirect_uris":["https://app.example.com/callback"],"client_name":"Example App"}`
+
+	// Registration needs an initial access token
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/register", strings.NewReader(body))
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	testutil.TestResponseForError(suite.T(), w, oauth.ErrInvalidInitialAccessToken.Error(), 401)
+
+	// Invalid metadata responds with an RFC 7591 error
+	r, err = http.NewRequest("POST", "http://1.2.3.4/v1/oauth/register", strings.NewReader(`{"redirect_uris":["/callback"]}`))
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), `"error":"invalid_redirect_uri"`)
+
+	r, err = http.NewRequest("POST", "http://1.2.3.4/v1/oauth/register", strings.NewReader(body))
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	if !assert.Equal(suite.T(), http.StatusCreated, w.Code) {
+		return
+	}
+
+	info := new(oauth.ClientInformation)
+	assert.Nil(suite.T(), json.NewDecoder(w.Body).Decode(info))
+	assert.True(suite.T(), strings.HasSuffix(info.RegistrationClientURI, "/v1/oauth/register/"+info.ClientID))
+
+	configurationURL := "http://1.2.3.4/v1/oauth/register/" + info.ClientID
+
+	// The client configuration endpoint needs the registration access token
+	r, err = http.NewRequest("GET", configurationURL, nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	testutil.TestResponseForError(suite.T(), w, oauth.ErrInvalidRegistrationToken.Error(), 401)
+
+	r, err = http.NewRequest("GET", configurationURL, nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.Header.Set("Authorization", "Bearer "+info.RegistrationAccessToken)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	current := new(oauth.ClientInformation)
+	assert.Nil(suite.T(), json.NewDecoder(w.Body).Decode(current))
+	assert.Empty(suite.T(), current.ClientSecret)
+	assert.Equal(suite.T(), "Example App", current.ClientName)
+
+	// Updates replace the metadata
+	current.RedirectURIs = []string{"https://app.example.com/callback", "https://app.example.com/other"}
+	current.TokenEndpointAuthMethod = oauth.AuthMethodClientSecretPost
+	update, err := json.Marshal(current)
+	assert.Nil(suite.T(), err)
+
+	r, err = http.NewRequest("PUT", configurationURL, bytes.NewReader(update))
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.Header.Set("Authorization", "Bearer "+info.RegistrationAccessToken)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	client, err := suite.service.FindClientByClientID(info.ClientID)
+	if assert.Nil(suite.T(), err) {
+		assert.True(suite.T(), suite.service.IsValidRedirectURI(client, "https://app.example.com/other"))
+	}
+
+	// Clients registered with client_secret_post authenticate with the form
+	r, err = http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.SetBasicAuth(info.ClientID, info.ClientSecret)
+	r.PostForm = url.Values{"grant_type": {"client_credentials"}}
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	testutil.TestResponseForError(suite.T(), w, oauth.ErrInvalidClientIDOrSecret.Error(), 401)
+
+	// and only use the grant types they registered
+	r, err = http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.PostForm = url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {info.ClientID},
+		"client_secret": {info.ClientSecret},
+	}
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	testutil.TestResponseForError(suite.T(), w, oauth.ErrUnauthorizedClient.Error(), 400)
+
+	// Deleting deregisters the client
+	r, err = http.NewRequest("DELETE", configurationURL, nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.Header.Set("Authorization", "Bearer "+info.RegistrationAccessToken)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	assert.Equal(suite.T(), http.StatusNoContent, w.Code)
+
+	assert.False(suite.T(), suite.service.ClientExists(info.ClientID))
+}
@@ -0,0 +1,321 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+var (
+	// ErrInvalidTarget ...
+	ErrInvalidTarget = errors.New("Invalid resource")
+	// ErrResourceTaken ...
+	ErrResourceTaken = errors.New("Resource already registered")
+	// ErrResourceNotFound ...
+	ErrResourceNotFound = errors.New("Resource not found")
+)
+
+// Resource is a protected resource tokens can be restricted to (RFC 8707).
+// Scopes prefixed with the namespace of a resource, e.g. tracks:write, may
+// only be granted together with that resource once any resource is asked for.
+type Resource struct {
+	bun.BaseModel `bun:"table:resources"`
+
+	URI         string    `bun:",pk"`
+	Namespace   string    `bun:",notnull"`
+	Description string    `bun:",notnull"`
+	CreatedAt   time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// AccessTokenResource is an audience of an access token
+type AccessTokenResource struct {
+	bun.BaseModel `bun:"table:access_token_resources"`
+
+	AccessTokenID uuid.UUID `bun:"type:uuid,pk"`
+	Resource      string    `bun:",pk"`
+}
+
+// AuthorizationCodeResource is a resource an authorization code was granted for
+type AuthorizationCodeResource struct {
+	bun.BaseModel `bun:"table:authorization_code_resources"`
+
+	AuthorizationCodeID uuid.UUID `bun:"type:uuid,pk"`
+	Resource            string    `bun:",pk"`
+}
+
+// RefreshTokenResource is a resource a refresh token was granted for
+type RefreshTokenResource struct {
+	bun.BaseModel `bun:"table:refresh_token_resources"`
+
+	RefreshTokenID uuid.UUID `bun:"type:uuid,pk"`
+	Resource       string    `bun:",pk"`
+}
+
+// ListResources returns every registered resource ordered by URI
+func (s *Service) ListResources() ([]*Resource, error) {
+	ctx := context.Background()
+	var resources []*Resource
+
+	err := s.db.NewSelect().
+		Model(&resources).
+		Order("uri ASC").
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+// CreateResource registers a resource, uri must be an absolute URI without
+// a fragment and namespace the prefix of the scopes of the resource
+func (s *Service) CreateResource(uri, namespace, description string) (*Resource, error) {
+	ctx := context.Background()
+
+	if !isValidResource(uri) {
+		return nil, ErrInvalidTarget
+	}
+
+	if namespace == "" || strings.ContainsAny(namespace, ": \t\n") {
+		return nil, ErrInvalidScope
+	}
+
+	exists, err := s.db.NewSelect().
+		Model((*Resource)(nil)).
+		Where("uri = ?", uri).
+		Exists(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrResourceTaken
+	}
+
+	resource := &Resource{
+		URI:         uri,
+		Namespace:   namespace,
+		Description: description,
+	}
+
+	_, err = s.db.NewInsert().
+		Model(resource).
+		Returning("*").
+		Exec(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+// DeleteResource removes a resource, tokens restricted to it lose that audience
+func (s *Service) DeleteResource(uri string) error {
+	ctx := context.Background()
+
+	res, err := s.db.NewDelete().
+		Model((*Resource)(nil)).
+		Where("uri = ?", uri).
+		Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrResourceNotFound
+	}
+
+	return nil
+}
+
+// GetResources validates the resources requested alongside scope and returns
+// them sorted without duplicates. Resources must be registered and, for
+// clients with allowed scopes, the client must be allowed a scope of their
+// namespace. When resources are requested every namespaced scope must
+// belong to one of them.
+func (s *Service) GetResources(client *model.Client, scope string, requested []string) ([]string, error) {
+	ctx := context.Background()
+
+	var uris []string
+	for _, uri := range requested {
+		if !isValidResource(uri) {
+			return nil, ErrInvalidTarget
+		}
+		if !util.StringInSlice(uri, uris) {
+			uris = append(uris, uri)
+		}
+	}
+
+	if len(uris) == 0 {
+		return []string{}, nil
+	}
+
+	var resources []*Resource
+
+	err := s.db.NewSelect().
+		Model(&resources).
+		Where("uri IN (?)", bun.In(uris)).
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resources) != len(uris) {
+		return nil, ErrInvalidTarget
+	}
+
+	allowed, err := s.GetClientScopes(client)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := make([]string, len(resources))
+	for i, resource := range resources {
+		if len(allowed) > 0 && !hasNamespace(allowed, resource.Namespace) {
+			return nil, ErrInvalidTarget
+		}
+		namespaces[i] = resource.Namespace
+	}
+
+	for _, name := range splitScope(scope) {
+		namespace := ScopeNamespace(name)
+		if namespace != "" && !util.StringInSlice(namespace, namespaces) {
+			return nil, ErrInvalidScope
+		}
+	}
+
+	sort.Strings(uris)
+
+	return uris, nil
+}
+
+// GetAccessTokenResources returns the audience of an access token, tokens
+// granted without resources are not audience restricted
+func (s *Service) GetAccessTokenResources(accessToken *model.AccessToken) ([]string, error) {
+	ctx := context.Background()
+	resources := []string{}
+
+	err := s.db.NewSelect().
+		Model((*AccessTokenResource)(nil)).
+		Column("resource").
+		Where("access_token_id = ?", accessToken.ID).
+		Order("resource ASC").
+		Scan(ctx, &resources)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+// getAuthorizationCodeResources returns the resources an authorization code
+// was granted for
+func (s *Service) getAuthorizationCodeResources(authorizationCode *model.AuthorizationCode) ([]string, error) {
+	ctx := context.Background()
+	resources := []string{}
+
+	err := s.db.NewSelect().
+		Model((*AuthorizationCodeResource)(nil)).
+		Column("resource").
+		Where("authorization_code_id = ?", authorizationCode.ID).
+		Order("resource ASC").
+		Scan(ctx, &resources)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+// getCodeResources returns the resources of the tokens issued for an
+// authorization code, the request may only narrow down those of the code
+func (s *Service) getCodeResources(authorizationCode *model.AuthorizationCode, requested []string) ([]string, error) {
+	granted, err := s.getAuthorizationCodeResources(authorizationCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.narrowResources(authorizationCode.Client, authorizationCode.Scope, granted, requested)
+}
+
+// narrowResources validates the resources requested out of those granted,
+// all of them when none are requested
+func (s *Service) narrowResources(client *model.Client, scope string, granted, requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		requested = granted
+	} else if len(granted) > 0 {
+		for _, uri := range requested {
+			if !util.StringInSlice(uri, granted) {
+				return nil, ErrInvalidTarget
+			}
+		}
+	}
+
+	return s.GetResources(client, scope, requested)
+}
+
+// getRefreshTokenResources returns the resources a refresh token was
+// granted for
+func (s *Service) getRefreshTokenResources(refreshToken *model.RefreshToken) ([]string, error) {
+	ctx := context.Background()
+	resources := []string{}
+
+	err := s.db.NewSelect().
+		Model((*RefreshTokenResource)(nil)).
+		Column("resource").
+		Where("refresh_token_id = ?", refreshToken.ID).
+		Order("resource ASC").
+		Scan(ctx, &resources)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+// sameResources returns true if a and b hold the same resources
+func sameResources(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, uri := range b {
+		if !util.StringInSlice(uri, a) {
+			return false
+		}
+	}
+	return true
+}
+
+// isValidResource returns true for absolute URIs without a fragment
+func isValidResource(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+	return u.IsAbs() && u.Host != "" && u.Fragment == "" && !strings.Contains(uri, "#")
+}
+
+// hasNamespace returns true if one of scopes is namespaced by namespace
+func hasNamespace(scopes []string, namespace string) bool {
+	for _, scope := range scopes {
+		if ScopeNamespace(scope) == namespace {
+			return true
+		}
+	}
+	return false
+}
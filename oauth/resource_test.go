@@ -0,0 +1,124 @@
+package oauth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestCreateResource() {
+	_, err := suite.service.CreateResource("/tracks", "tracks", "")
+	assert.Equal(suite.T(), oauth.ErrInvalidTarget, err)
+
+	_, err = suite.service.CreateResource("https://api.resonate.test/tracks#x", "tracks", "")
+	assert.Equal(suite.T(), oauth.ErrInvalidTarget, err)
+
+	_, err = suite.service.CreateResource("https://api.resonate.test/tracks", "tracks:x", "")
+	assert.Equal(suite.T(), oauth.ErrInvalidScope, err)
+
+	resource, err := suite.service.CreateResource("https://api.resonate.test/tracks", "tracks", "Tracks API")
+	if assert.Nil(suite.T(), err) {
+		defer suite.service.DeleteResource(resource.URI)
+	}
+
+	_, err = suite.service.CreateResource("https://api.resonate.test/tracks", "tracks", "")
+	assert.Equal(suite.T(), oauth.ErrResourceTaken, err)
+
+	resources, err := suite.service.ListResources()
+	assert.Nil(suite.T(), err)
+	assert.Len(suite.T(), resources, 1)
+
+	assert.Equal(suite.T(), oauth.ErrResourceNotFound, suite.service.DeleteResource("https://api.resonate.test/bogus"))
+}
+
+func (suite *OauthTestSuite) TestGetResources() {
+	ctx := context.Background()
+	client := suite.clients[0]
+
+	scope, err := suite.service.CreateScope("tracks:write", "Upload tracks", false)
+	if assert.Nil(suite.T(), err) {
+		defer suite.db.NewDelete().Model(scope).WherePK().Exec(ctx)
+	}
+	tracks, err := suite.service.CreateResource("https://api.resonate.test/tracks", "tracks", "")
+	if assert.Nil(suite.T(), err) {
+		defer suite.service.DeleteResource(tracks.URI)
+	}
+	payouts, err := suite.service.CreateResource("https://api.resonate.test/payouts", "payouts", "")
+	if assert.Nil(suite.T(), err) {
+		defer suite.service.DeleteResource(payouts.URI)
+	}
+
+	// Tokens are not restricted unless resources are requested
+	resources, err := suite.service.GetResources(client, "read tracks:write", nil)
+	assert.Nil(suite.T(), err)
+	assert.Empty(suite.T(), resources)
+
+	resources, err = suite.service.GetResources(client, "read tracks:write", []string{tracks.URI, tracks.URI})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{tracks.URI}, resources)
+
+	// Unknown resources are rejected
+	_, err = suite.service.GetResources(client, "read", []string{"https://api.resonate.test/bogus"})
+	assert.Equal(suite.T(), oauth.ErrInvalidTarget, err)
+
+	// Namespaced scopes must belong to a requested resource
+	_, err = suite.service.GetResources(client, "read tracks:write", []string{payouts.URI})
+	assert.Equal(suite.T(), oauth.ErrInvalidScope, err)
+
+	// Clients with allowed scopes are limited to the resources of those scopes
+	assert.Nil(suite.T(), suite.service.SetClientScopes(client, []string{"read", "tracks:write"}))
+	defer suite.service.SetClientScopes(client, nil)
+
+	_, err = suite.service.GetResources(client, "read", []string{payouts.URI})
+	assert.Equal(suite.T(), oauth.ErrInvalidTarget, err)
+}
+
+func (suite *OauthTestSuite) TestClientCredentialsGrantWithResource() {
+	ctx := context.Background()
+
+	scope, err := suite.service.CreateScope("tracks:write", "Upload tracks", false)
+	if assert.Nil(suite.T(), err) {
+		defer suite.db.NewDelete().Model(scope).WherePK().Exec(ctx)
+	}
+	tracks, err := suite.service.CreateResource("https://api.resonate.test/tracks", "tracks", "")
+	if assert.Nil(suite.T(), err) {
+		defer suite.service.DeleteResource(tracks.URI)
+	}
+
+	// The client is allowed more than the default scope
+	assert.Nil(suite.T(), suite.service.SetClientScopes(suite.clients[0], []string{"tracks:write"}))
+	defer suite.service.SetClientScopes(suite.clients[0], nil)
+
+	// Prepare a request
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+	assert.NoError(suite.T(), err, "Request setup should not get an error")
+	r.SetBasicAuth("test_client_1", "test_secret")
+	r.PostForm = url.Values{
+		"grant_type": {"client_credentials"},
+		"scope":      {"tracks:write"},
+		"resource":   {tracks.URI},
+	}
+
+	// Serve the request
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, r)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	accessToken := new(model.AccessToken)
+	err = suite.db.NewSelect().
+		Model(accessToken).
+		Limit(1).
+		Scan(ctx)
+	assert.Nil(suite.T(), err)
+
+	// The token is restricted to the resource
+	introspectResponse, err := suite.service.NewIntrospectResponseFromAccessToken(accessToken)
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), []string{tracks.URI}, introspectResponse.Audience)
+	}
+}
@@ -14,17 +14,23 @@ type AccessTokenResponse struct {
 	TokenType    string `json:"token_type"`
 	Scope        string `json:"scope"`
 	RefreshToken string `json:"refresh_token,omitempty"`
+	// IssuedTokenType is set by token exchange only (RFC 8693)
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
 }
 
 // IntrospectResponse ...
 type IntrospectResponse struct {
-	UserID    string `json:"user_id,omitempty"`
-	Active    bool   `json:"active"`
-	Scope     string `json:"scope,omitempty"`
-	ClientID  string `json:"client_id,omitempty"`
-	Username  string `json:"username,omitempty"`
-	TokenType string `json:"token_type,omitempty"`
-	ExpiresAt int    `json:"exp,omitempty"`
+	UserID      string   `json:"user_id,omitempty"`
+	Active      bool     `json:"active"`
+	Scope       string   `json:"scope,omitempty"`
+	ClientID    string   `json:"client_id,omitempty"`
+	Username    string   `json:"username,omitempty"`
+	TokenType   string   `json:"token_type,omitempty"`
+	ExpiresAt   int      `json:"exp,omitempty"`
+	Audience    []string `json:"aud,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	Act         *Actor   `json:"act,omitempty"`
 }
 
 // NewAccessTokenResponse ...
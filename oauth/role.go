@@ -14,12 +14,15 @@ var (
 )
 
 // FindRoleByID looks up a role by ID and returns it
-func (s *Service) FindRoleByID(id int32) (*model.AccessRole, error) {
+func (s *Service) FindRoleByID(id int32) (*model.Role, error) {
 	role := new(model.Role)
 	err := s.db.NewSelect().Model(role).Where("id = ?", id).Scan(context.Background())
 
 	if err == sql.ErrNoRows {
 		return nil, ErrRoleNotFound
 	}
-	return (*model.AccessRole)(&role.ID), nil
+	if err != nil {
+		return nil, err
+	}
+	return role, nil
 }
@@ -8,7 +8,7 @@ import (
 
 func (suite *OauthTestSuite) TestFindRoleByID() {
 	var (
-		role *model.AccessRole
+		role *model.Role
 		err  error
 	)
 
@@ -31,6 +31,7 @@ func (suite *OauthTestSuite) TestFindRoleByID() {
 
 	// Correct role should be returned
 	if assert.NotNil(suite.T(), role) {
-		assert.Equal(suite.T(), model.UserRole, *role)
+		assert.Equal(suite.T(), int32(model.UserRole), role.ID)
+		assert.Equal(suite.T(), "user", role.Name)
 	}
 }
@@ -10,6 +10,12 @@ const (
 	tokensPath         = "/" + tokensResource
 	introspectResource = "introspect"
 	introspectPath     = "/" + introspectResource
+	registerResource   = "register"
+	registerPath       = "/" + registerResource
+	jwksResource       = "jwks"
+	jwksPath           = "/" + jwksResource
+	parResource        = "par"
+	parPath            = "/" + parResource
 )
 
 // RegisterRoutes registers route handlers for the oauth service
@@ -33,5 +39,41 @@ func (s *Service) GetRoutes() []routes.Route {
 			Pattern:     introspectPath,
 			HandlerFunc: s.introspectHandler,
 		},
+		{
+			Name:        "oauth_par",
+			Method:      "POST",
+			Pattern:     parPath,
+			HandlerFunc: s.parHandler,
+		},
+		{
+			Name:        "oauth_register",
+			Method:      "POST",
+			Pattern:     registerPath,
+			HandlerFunc: s.registerHandler,
+		},
+		{
+			Name:        "oauth_client_configuration",
+			Method:      "GET",
+			Pattern:     registerPath + "/{client_id}",
+			HandlerFunc: s.clientConfigurationHandler,
+		},
+		{
+			Name:        "oauth_update_client_configuration",
+			Method:      "PUT",
+			Pattern:     registerPath + "/{client_id}",
+			HandlerFunc: s.updateClientConfigurationHandler,
+		},
+		{
+			Name:        "oauth_delete_client_configuration",
+			Method:      "DELETE",
+			Pattern:     registerPath + "/{client_id}",
+			HandlerFunc: s.deleteClientConfigurationHandler,
+		},
+		{
+			Name:        "oauth_jwks",
+			Method:      "GET",
+			Pattern:     jwksPath,
+			HandlerFunc: s.jwksHandler,
+		},
 	}
 }
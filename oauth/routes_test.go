@@ -38,3 +38,54 @@ func (suite *OauthTestSuite) TestIntrospectRouteIsValid() {
 		assert.Equal(suite.T(), "oauth_introspect", match.Route.GetName(), "Expected route to be matched")
 	}
 }
+
+func (suite *OauthTestSuite) TestRegisterRoutesAreValid() {
+	for method, name := range map[string]string{
+		"GET":    "oauth_client_configuration",
+		"PUT":    "oauth_update_client_configuration",
+		"DELETE": "oauth_delete_client_configuration",
+	} {
+		r, err := http.NewRequest(method, "http://1.2.3.4/v1/oauth/register/test_client_1", nil)
+		assert.NoError(suite.T(), err, "New request should not cause an error")
+
+		// Check the routing
+		match := new(mux.RouteMatch)
+		suite.router.Match(r, match)
+		if assert.NotNil(suite.T(), match.Route, "Expected to find a route match") {
+			assert.Equal(suite.T(), name, match.Route.GetName(), "Expected route to be matched")
+		}
+	}
+
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/register", nil)
+	assert.NoError(suite.T(), err, "New request should not cause an error")
+
+	match := new(mux.RouteMatch)
+	suite.router.Match(r, match)
+	if assert.NotNil(suite.T(), match.Route, "Expected to find a route match") {
+		assert.Equal(suite.T(), "oauth_register", match.Route.GetName(), "Expected route to be matched")
+	}
+}
+
+func (suite *OauthTestSuite) TestJWKSRouteIsValid() {
+	r, err := http.NewRequest("GET", "http://1.2.3.4/v1/oauth/jwks", nil)
+	assert.NoError(suite.T(), err, "New request should not cause an error")
+
+	// Check the routing
+	match := new(mux.RouteMatch)
+	suite.router.Match(r, match)
+	if assert.NotNil(suite.T(), match.Route, "Expected to find a route match") {
+		assert.Equal(suite.T(), "oauth_jwks", match.Route.GetName(), "Expected route to be matched")
+	}
+}
+
+func (suite *OauthTestSuite) TestPARRouteIsValid() {
+	r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/par", nil)
+	assert.NoError(suite.T(), err, "New request should not cause an error")
+
+	// Check the routing
+	match := new(mux.RouteMatch)
+	suite.router.Match(r, match)
+	if assert.NotNil(suite.T(), match.Route, "Expected to find a route match") {
+		assert.Equal(suite.T(), "oauth_par", match.Route.GetName(), "Expected route to be matched")
+	}
+}
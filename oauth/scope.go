@@ -6,15 +6,30 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/util"
 	"github.com/resonatecoop/user-api/model"
 	"github.com/uptrace/bun"
 )
 
+// SCIMScope is the scope client credentials tokens need to use the SCIM API
+const SCIMScope = "scim"
+
 var (
 	// ErrInvalidScope ...
 	ErrInvalidScope = errors.New("Invalid scope")
+	// ErrScopeTaken ...
+	ErrScopeTaken = errors.New("Scope name taken")
 )
 
+// ClientScope allows a client to request a scope
+type ClientScope struct {
+	bun.BaseModel `bun:"table:client_scopes"`
+
+	ClientID uuid.UUID `bun:"type:uuid,pk"`
+	Scope    string    `bun:",pk"`
+}
+
 // GetScope takes a requested scope and, if it's empty, returns the default
 // scope, if not empty, it validates the requested scope
 func (s *Service) GetScope(requestedScope string) (string, error) {
@@ -73,7 +88,10 @@ func (s *Service) GetDefaultScope() string {
 func (s *Service) ScopeExists(requestedScope string) bool {
 	ctx := context.Background()
 	// Split the requested scope string
-	scopes := strings.Split(requestedScope, " ")
+	scopes := splitScope(requestedScope)
+	if len(scopes) == 0 {
+		return false
+	}
 
 	var available_scopes []model.Scope
 
@@ -86,3 +104,231 @@ func (s *Service) ScopeExists(requestedScope string) bool {
 	// Return true only if all requested scopes found
 	return count == len(scopes)
 }
+
+// ScopeNamespace returns the resource a scope is namespaced by, e.g. tracks
+// for tracks:write, and an empty string for scopes without a namespace
+func ScopeNamespace(scope string) string {
+	if i := strings.Index(scope, ":"); i > 0 {
+		return scope[:i]
+	}
+	return ""
+}
+
+// FindScopes returns the scopes of a space delimited scope string, in the
+// order they were requested, so their descriptions can be shown for consent
+func (s *Service) FindScopes(scope string) ([]*model.Scope, error) {
+	ctx := context.Background()
+	names := splitScope(scope)
+	if len(names) == 0 {
+		return []*model.Scope{}, nil
+	}
+
+	var found []*model.Scope
+
+	err := s.db.NewSelect().
+		Model(&found).
+		Where("name IN (?)", bun.In(names)).
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*model.Scope, len(found))
+	for _, sc := range found {
+		byName[sc.Name] = sc
+	}
+
+	scopes := make([]*model.Scope, 0, len(names))
+	for _, name := range names {
+		sc, ok := byName[name]
+		if !ok {
+			return nil, ErrInvalidScope
+		}
+		scopes = append(scopes, sc)
+	}
+
+	return scopes, nil
+}
+
+// GetClientScope works like GetScope and also checks the scope is allowed
+// for the client, clients without allowed scopes are limited to the default
+// scope. The default scope is narrowed down to the scopes allowed for the
+// client.
+func (s *Service) GetClientScope(client *model.Client, requestedScope string) (string, error) {
+	allowed, err := s.GetClientScopes(client)
+	if err != nil {
+		return "", err
+	}
+
+	scope, err := s.GetScope(requestedScope)
+	if err != nil {
+		return "", err
+	}
+
+	if len(allowed) == 0 {
+		allowed = splitScope(s.GetDefaultScope())
+	}
+
+	var granted []string
+	for _, name := range splitScope(scope) {
+		if !util.StringInSlice(name, allowed) {
+			if requestedScope != "" {
+				return "", ErrInvalidScope
+			}
+			continue
+		}
+		granted = append(granted, name)
+	}
+
+	if len(granted) == 0 {
+		return "", ErrInvalidScope
+	}
+
+	return strings.Join(granted, " "), nil
+}
+
+// GetClientScopes returns the scopes a client may request, sorted by name.
+// An empty list means the client may only request the default scope.
+func (s *Service) GetClientScopes(client *model.Client) ([]string, error) {
+	ctx := context.Background()
+	scopes := []string{}
+
+	err := s.db.NewSelect().
+		Model((*ClientScope)(nil)).
+		Column("scope").
+		Where("client_id = ?", client.ID).
+		Order("scope ASC").
+		Scan(ctx, &scopes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scopes, nil
+}
+
+// SetClientScopes replaces the scopes a client may request, an empty list
+// limits the client to the default scope
+func (s *Service) SetClientScopes(client *model.Client, scopes []string) error {
+	ctx := context.Background()
+	scopes = splitScope(strings.Join(scopes, " "))
+
+	if len(scopes) > 0 && !s.ScopeExists(strings.Join(scopes, " ")) {
+		return ErrInvalidScope
+	}
+
+	// Begin a transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = setClientScopes(ctx, tx, client, scopes); err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	// Commit the transaction
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	return nil
+}
+
+// setClientScopes replaces the allowed scopes of a client within tx
+func setClientScopes(ctx context.Context, tx bun.Tx, client *model.Client, scopes []string) error {
+	_, err := tx.NewDelete().
+		Model((*ClientScope)(nil)).
+		Where("client_id = ?", client.ID).
+		Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if len(scopes) == 0 {
+		return nil
+	}
+
+	rows := make([]*ClientScope, len(scopes))
+	for i, scope := range scopes {
+		rows[i] = &ClientScope{ClientID: client.ID, Scope: scope}
+	}
+
+	_, err = tx.NewInsert().
+		Model(&rows).
+		Exec(ctx)
+
+	return err
+}
+
+// splitScope splits a space delimited scope string, dropping duplicates
+func splitScope(scope string) []string {
+	var scopes []string
+	for _, name := range strings.Fields(scope) {
+		if !util.StringInSlice(name, scopes) {
+			scopes = append(scopes, name)
+		}
+	}
+	return scopes
+}
+
+// ListScopes returns every scope ordered by name
+func (s *Service) ListScopes() ([]*model.Scope, error) {
+	ctx := context.Background()
+	var scopes []*model.Scope
+
+	err := s.db.NewSelect().
+		Model(&scopes).
+		Order("name ASC").
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scopes, nil
+}
+
+// CreateScope saves a new scope to database
+func (s *Service) CreateScope(name, description string, isDefault bool) (*model.Scope, error) {
+	ctx := context.Background()
+
+	if name == "" || strings.ContainsAny(name, " \t\n") {
+		return nil, ErrInvalidScope
+	}
+
+	if s.ScopeExists(name) {
+		return nil, ErrScopeTaken
+	}
+
+	// scope ids are not generated by the database
+	var maxID int32
+
+	err := s.db.NewSelect().
+		Model((*model.Scope)(nil)).
+		ColumnExpr("COALESCE(MAX(id), 0)").
+		Scan(ctx, &maxID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scope := &model.Scope{
+		ID:          maxID + 1,
+		Name:        name,
+		Description: description,
+		IsDefault:   isDefault,
+	}
+
+	_, err = s.db.NewInsert().Model(scope).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return scope, nil
+}
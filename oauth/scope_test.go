@@ -1,6 +1,8 @@
 package oauth_test
 
 import (
+	"context"
+
 	"github.com/resonatecoop/id/oauth"
 	"github.com/stretchr/testify/assert"
 )
@@ -38,3 +40,95 @@ func (suite *OauthTestSuite) TestScopeExists() {
 
 	assert.False(suite.T(), suite.service.ScopeExists("read_write bogus"))
 }
+
+func (suite *OauthTestSuite) TestCreateScope() {
+	ctx := context.Background()
+
+	// Existing scopes cannot be added twice
+	_, err := suite.service.CreateScope("read", "", false)
+	assert.Equal(suite.T(), oauth.ErrScopeTaken, err)
+
+	_, err = suite.service.CreateScope("read write", "", false)
+	assert.Equal(suite.T(), oauth.ErrInvalidScope, err)
+
+	scope, err := suite.service.CreateScope("test_scope", "Test scope", false)
+	assert.Nil(suite.T(), err)
+	defer suite.db.NewDelete().Model(scope).WherePK().Exec(ctx)
+
+	assert.True(suite.T(), suite.service.ScopeExists("test_scope"))
+
+	scopes, err := suite.service.ListScopes()
+	assert.Nil(suite.T(), err)
+
+	names := make([]string, len(scopes))
+	for i, s := range scopes {
+		names[i] = s.Name
+	}
+	assert.Contains(suite.T(), names, "test_scope")
+
+	// Adding a scope does not change the default scope
+	assert.Equal(suite.T(), "read user", suite.service.GetDefaultScope())
+}
+
+func (suite *OauthTestSuite) TestScopeNamespace() {
+	assert.Equal(suite.T(), "tracks", oauth.ScopeNamespace("tracks:write"))
+	assert.Equal(suite.T(), "", oauth.ScopeNamespace("read_write"))
+	assert.Equal(suite.T(), "", oauth.ScopeNamespace(":write"))
+}
+
+func (suite *OauthTestSuite) TestFindScopes() {
+	scopes, err := suite.service.FindScopes("read_write read read")
+	if assert.Nil(suite.T(), err) && assert.Len(suite.T(), scopes, 2) {
+		assert.Equal(suite.T(), "read_write", scopes[0].Name)
+		assert.Equal(suite.T(), "read", scopes[1].Name)
+	}
+
+	_, err = suite.service.FindScopes("read bogus")
+	assert.Equal(suite.T(), oauth.ErrInvalidScope, err)
+}
+
+func (suite *OauthTestSuite) TestGetClientScope() {
+	client := suite.clients[0]
+	defer suite.service.SetClientScopes(client, nil)
+
+	// Clients without allowed scopes are limited to the default scope
+	scope, err := suite.service.GetClientScope(client, "")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), suite.service.GetDefaultScope(), scope)
+
+	scope, err = suite.service.GetClientScope(client, "read")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "read", scope)
+
+	_, err = suite.service.GetClientScope(client, "read_write")
+	assert.Equal(suite.T(), oauth.ErrInvalidScope, err)
+
+	_, err = suite.service.GetClientScope(client, "read admin")
+	assert.Equal(suite.T(), oauth.ErrInvalidScope, err)
+
+	err = suite.service.SetClientScopes(client, []string{"read", "read_write"})
+	assert.Nil(suite.T(), err)
+
+	scope, err = suite.service.GetClientScope(client, "read read_write")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "read read_write", scope)
+
+	// Unknown scopes cannot be allowed
+	err = suite.service.SetClientScopes(client, []string{"read", "bogus"})
+	assert.Equal(suite.T(), oauth.ErrInvalidScope, err)
+
+	err = suite.service.SetClientScopes(client, []string{"read", "read"})
+	assert.Nil(suite.T(), err)
+
+	scopes, err := suite.service.GetClientScopes(client)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"read"}, scopes)
+
+	_, err = suite.service.GetClientScope(client, "read read_write")
+	assert.Equal(suite.T(), oauth.ErrInvalidScope, err)
+
+	// The default scope is narrowed down to the allowed scopes
+	scope, err = suite.service.GetClientScope(client, "")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "read", scope)
+}
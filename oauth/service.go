@@ -1,7 +1,15 @@
 package oauth
 
 import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/resonatecoop/id/authn"
 	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/events"
+	"github.com/resonatecoop/id/rbac"
+	"github.com/resonatecoop/id/realm"
 	"github.com/uptrace/bun"
 
 	"github.com/resonatecoop/user-api/model"
@@ -11,16 +19,39 @@ import (
 type Service struct {
 	cnf          *config.Config
 	db           *bun.DB
-	allowedRoles []int32
+	realms       realm.ServiceInterface
+	rbac         rbac.ServiceInterface
+	events       events.ServiceInterface
+	authBackends *authn.Backends
+	allowedRoles []model.AccessRole
+	keyMu        sync.Mutex
+	key          *signingKey
+	logoutClient *http.Client
+	jwksClient   *http.Client
 }
 
 // NewService returns a new Service instance
 func NewService(cnf *config.Config, db *bun.DB) *Service {
-	return &Service{
+	s := &Service{
 		cnf:          cnf,
 		db:           db,
-		allowedRoles: []int32{int32(model.SuperAdminRole), int32(model.AdminRole), int32(model.TenantAdminRole), int32(model.LabelRole), int32(model.ArtistRole), int32(model.UserRole)},
+		realms:       realm.NewService(cnf, db),
+		rbac:         rbac.NewService(cnf, db),
+		events:       events.NewService(cnf, db),
+		authBackends: authn.New(cnf.AuthBackends),
+		allowedRoles: []model.AccessRole{model.SuperAdminRole, model.AdminRole, model.TenantAdminRole, model.LabelRole, model.ArtistRole, model.UserRole},
+		logoutClient: &http.Client{Timeout: 5 * time.Second},
+		jwksClient:   &http.Client{Timeout: 5 * time.Second},
 	}
+
+	// the realm admin API authenticates and manages clients through us
+	s.realms.UseBackend(s)
+	// so does the role admin API for users
+	s.rbac.UseBackend(s)
+	// and the webhook admin API
+	s.events.UseBackend(s)
+
+	return s
 }
 
 // GetConfig returns config.Config instance
@@ -28,16 +59,35 @@ func (s *Service) GetConfig() *config.Config {
 	return s.cnf
 }
 
+// GetRealmService returns the realm.Service clients and users belong to
+func (s *Service) GetRealmService() realm.ServiceInterface {
+	return s.realms
+}
+
+// GetRBACService returns the rbac.Service users get their roles from
+func (s *Service) GetRBACService() rbac.ServiceInterface {
+	return s.rbac
+}
+
+// GetEventsService returns the events.Service other services are notified
+// of changes to users through
+func (s *Service) GetEventsService() events.ServiceInterface {
+	return s.events
+}
+
+// RegisterAuthBackend routes the users of the domains of route to its
+// backend, e.g. for backends which are not configurable
+func (s *Service) RegisterAuthBackend(route *authn.Route) {
+	s.authBackends.Register(route)
+}
+
 // RestrictToRoles restricts this service to only specified roles
-func (s *Service) RestrictToRoles(allowedRoles ...int32) {
+func (s *Service) RestrictToRoles(allowedRoles ...model.AccessRole) {
 	s.allowedRoles = allowedRoles
-	// for i := range s.allowedRoles {
-	// 	s.allowedRoles[i] = model.AccessRole(allowedRoles[i])
-	// }
 }
 
 // IsRoleAllowed returns true if the role is allowed to use this service
-func (s *Service) IsRoleAllowed(role int32) bool {
+func (s *Service) IsRoleAllowed(role model.AccessRole) bool {
 	for _, allowedRole := range s.allowedRoles {
 		if role == allowedRole {
 			return true
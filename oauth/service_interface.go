@@ -1,8 +1,17 @@
 package oauth
 
 import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/resonatecoop/id/authn"
 	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/events"
+	"github.com/resonatecoop/id/rbac"
+	"github.com/resonatecoop/id/realm"
 	"github.com/resonatecoop/id/session"
 	"github.com/resonatecoop/id/util/routes"
 	"github.com/resonatecoop/user-api/model"
@@ -13,9 +22,13 @@ import (
 type ServiceInterface interface {
 	// Exported methods
 	GetConfig() *config.Config
-	RestrictToRoles(allowedRoles ...int32)
-	IsRoleAllowed(role int32) bool
-	FindRoleByID(id int32) (*model.AccessRole, error)
+	GetRealmService() realm.ServiceInterface
+	GetRBACService() rbac.ServiceInterface
+	GetEventsService() events.ServiceInterface
+	RegisterAuthBackend(route *authn.Route)
+	RestrictToRoles(allowedRoles ...model.AccessRole)
+	IsRoleAllowed(role model.AccessRole) bool
+	FindRoleByID(id int32) (*model.Role, error)
 	GetRoutes() []routes.Route
 	RegisterRoutes(router *mux.Router, prefix string)
 	ClientExists(clientID string) bool
@@ -24,36 +37,116 @@ type ServiceInterface interface {
 	CreateClient(clientID, secret, redirectURI, applicationName, applicationHostname, applicationURL string) (*model.Client, error)
 	CreateClientTx(tx *bun.DB, clientID, secret, redirectURI, applicationName, applicationHostname, applicationURL string) (*model.Client, error)
 	AuthClient(clientID, secret string) (*model.Client, error)
+	ListClients() ([]*model.Client, error)
+	RotateClientSecret(client *model.Client, secret string) error
+	DeleteClient(client *model.Client) error
+	CreateInitialAccessToken(realmID uuid.UUID, description string, expiresIn time.Duration) (string, *InitialAccessToken, error)
+	ListInitialAccessTokens() ([]*InitialAccessToken, error)
+	DeleteInitialAccessToken(id uuid.UUID) error
+	AuthInitialAccessToken(realmID uuid.UUID, token string) error
+	RegisterClient(realmID uuid.UUID, registration *ClientRegistration) (*ClientInformation, error)
+	AuthRegistrationToken(clientID, token string) (*model.Client, error)
+	GetClientInformation(client *model.Client) (*ClientInformation, error)
+	UpdateClientRegistration(client *model.Client, registration *ClientRegistration) (*ClientInformation, error)
+	GetClientMetadata(client *model.Client) (*ClientMetadata, error)
+	GetClientRedirectURIs(client *model.Client) ([]string, error)
+	IsValidRedirectURI(client *model.Client, redirectURI string) bool
+	CheckGrantType(client *model.Client, grantType string) error
+	SetClientLogout(client *model.Client, logout *ClientLogout) error
+	SetClientAuthorization(client *model.Client, redirectURIs []string, authorization *ClientAuthorization) error
+	PushAuthorizationRequest(client *model.Client, params url.Values, issuer string) (string, int, error)
+	GetAuthorizationRequest(client *model.Client, form url.Values, issuer string) (url.Values, error)
+	ConsumePushedAuthorizationRequest(client *model.Client, requestURI string) error
+	ParseRequestObject(client *model.Client, requestObject, issuer string) (url.Values, error)
+	PurgeExpiredPushedAuthorizationRequests(batchSize int) (int, error)
+	VerifyClient(client *model.Client) error
+	UnverifyClient(client *model.Client) error
+	IsClientVerified(client *model.Client) (bool, error)
+	IsFirstPartyClient(client *model.Client) bool
+	GetConsentedScope(scope string, granted []string) (string, error)
+	SetTokenExchangePolicy(client *model.Client, policy *TokenExchangePolicy) error
+	GetTokenExchangePolicy(client *model.Client) (*TokenExchangePolicy, error)
+	DeleteTokenExchangePolicy(client *model.Client) error
+	GetAccessTokenActor(accessToken *model.AccessToken) (*Actor, error)
+	IsValidPostLogoutRedirectURI(client *model.Client, redirectURI string) bool
+	GetJSONWebKeySet() (*JSONWebKeySet, error)
 	GetValidEmailToken(token string) (*model.EmailToken, *model.User, error)
 	ClearExpiredEmailTokens() error
+	PurgeExpiredAccessTokens(batchSize int) (int, error)
+	PurgeExpiredRefreshTokens(batchSize int) (int, error)
+	PurgeExpiredAuthorizationCodes(batchSize int) (int, error)
+	PurgeExpiredEmailTokens(batchSize int) (int, error)
 	DeleteEmailToken(*model.EmailToken, bool) error
 	SendEmailToken(email *model.Email, emailTokenLink string) (*model.EmailToken, error)
 	SendEmailTokenTx(db *bun.DB, email *model.Email, emailTokenLink string) (*model.EmailToken, error)
 	UserExists(username string) bool
+	CreateUser(rlm *realm.Realm, username, password, country string, roleIDs []int32) (*model.User, error)
+	CreateConfirmedUser(ctx context.Context, rlm *realm.Realm, username, password, country string, roleIDs []int32, setup func(ctx context.Context, tx bun.Tx, user *model.User) error) (*model.User, error)
 	FindUserByUsername(username string) (*model.User, error)
 	FindUserByEmail(email string) (*model.User, error)
-	DeleteUser(user *model.User, password string) error
-	DeleteUserTx(tx *bun.DB, user *model.User, password string) error
+	DeleteUser(ctx context.Context, user *model.User, password string) error
+	DeleteUserTx(ctx context.Context, tx *bun.DB, user *model.User, password string) error
+	ScheduleUserDeletion(user *model.User) error
+	CancelUserDeletion(ctx context.Context, token string) (*model.User, error)
+	PurgeDeletedUsers() (int, error)
+	CancelStripeSubscriptions(batchSize int) (int, error)
+	SyncUserAPI(batchSize int) (int, error)
+	CountPendingUserAPIChanges() (int, int, error)
+	RevokeUserTokens(user *model.User) error
+	SetUserRole(user *model.User, roleID int32, actorID uuid.UUID) error
+	LockUser(user *model.User) error
 	ConfirmUserEmail(email string) error
 	SetPassword(user *model.User, password string) error
 	SetPasswordTx(tx *bun.DB, user *model.User, password string) error
-	UpdateUsername(user *model.User, username, password string) error
-	UpdateUsernameTx(db *bun.DB, user *model.User, username, password string) error
+	UpdateUsername(ctx context.Context, user *model.User, username, password string) error
+	UpdateUsernameTx(ctx context.Context, db *bun.DB, user *model.User, username, password string) error
+	SetUsername(user *model.User, username string) error
 	UpdateUser(user *model.User, fullName, firstName, lastName, country string, newsletter bool) error
 	SetUserCountry(user *model.User, country string) error
 	SetUserCountryTx(db *bun.DB, user *model.User, country string) error
-	AuthUser(username, thePassword string) (*model.User, error)
+	GetUserLocale(user *model.User) (string, error)
+	SetUserLocale(user *model.User, locale string) error
+	AuthUser(ctx context.Context, username, thePassword string) (*model.User, error)
 	GetScope(requestedScope string) (string, error)
 	GetDefaultScope() string
 	ScopeExists(requestedScope string) bool
-	Login(client *model.Client, user *model.User, scope string) (*model.AccessToken, *model.RefreshToken, error)
-	GrantAuthorizationCode(client *model.Client, user *model.User, expiresIn int, redirectURI, scope string) (*model.AuthorizationCode, error)
-	GrantAccessToken(client *model.Client, user *model.User, expiresIn int, scope string) (*model.AccessToken, error)
-	GetOrCreateRefreshToken(client *model.Client, user *model.User, expiresIn int, scope string) (*model.RefreshToken, error)
+	ListScopes() ([]*model.Scope, error)
+	CreateScope(name, description string, isDefault bool) (*model.Scope, error)
+	FindScopes(scope string) ([]*model.Scope, error)
+	GetClientScope(client *model.Client, requestedScope string) (string, error)
+	GetClientScopes(client *model.Client) ([]string, error)
+	SetClientScopes(client *model.Client, scopes []string) error
+	ListResources() ([]*Resource, error)
+	CreateResource(uri, namespace, description string) (*Resource, error)
+	DeleteResource(uri string) error
+	GetResources(client *model.Client, scope string, requested []string) ([]string, error)
+	GetAccessTokenResources(accessToken *model.AccessToken) ([]string, error)
+	Login(client *model.Client, user *model.User, scope string, resources ...string) (*model.AccessToken, *model.RefreshToken, error)
+	GrantAuthorizationCode(client *model.Client, user *model.User, expiresIn int, redirectURI, scope string, resources ...string) (*model.AuthorizationCode, error)
+	GrantAccessToken(client *model.Client, user *model.User, expiresIn int, scope string, resources ...string) (*model.AccessToken, error)
+	GetOrCreateRefreshToken(client *model.Client, user *model.User, expiresIn int, scope string, resources ...string) (*model.RefreshToken, error)
 	GetValidRefreshToken(token string, client *model.Client) (*model.RefreshToken, error)
 	Authenticate(token string) (*model.AccessToken, error)
+	AuthenticateAdmin(token string) (*model.User, error)
+	AuthenticateOwner(token string) (*model.AccessToken, error)
 	NewIntrospectResponseFromAccessToken(accessToken *model.AccessToken) (*IntrospectResponse, error)
 	NewIntrospectResponseFromRefreshToken(refreshToken *model.RefreshToken) (*IntrospectResponse, error)
 	ClearUserTokens(userSession *session.UserSession)
+	StartSession(user *model.User, accessToken *model.AccessToken, refreshToken *model.RefreshToken) (*Session, error)
+	FindSession(sessionID string) (*Session, error)
+	AddSessionTokens(sessionID string, accessToken *model.AccessToken, refreshToken *model.RefreshToken) error
+	AddSessionAuthorizationCode(sessionID string, authorizationCode *model.AuthorizationCode) error
+	EndSession(sessionID string) (*Logout, error)
+	PurgeStaleSessions(batchSize int) (int, error)
+	StartImpersonation(client *model.Client, actor, user *model.User, reason string) (*Impersonation, *model.AccessToken, error)
+	FindImpersonation(id string) (*Impersonation, error)
+	EndImpersonation(impersonation *Impersonation) error
+	EndExpiredImpersonations(batchSize int) (int, error)
+	RecordImpersonationEvent(impersonation *Impersonation, action, detail string) error
+	ListImpersonations(user *model.User) ([]*Impersonation, error)
+	ListImpersonationEvents(impersonation *Impersonation) ([]*ImpersonationEvent, error)
+	NewLogoutToken(issuer string, logout *Logout, client *LogoutClient) (string, error)
+	NotifyBackchannelLogout(ctx context.Context, issuer string, logout *Logout) error
+	FrontchannelLogoutURIs(issuer string, logout *Logout) []string
 	Close()
 }
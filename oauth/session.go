@@ -0,0 +1,420 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+var (
+	// ErrSessionNotFound ...
+	ErrSessionNotFound = errors.New("Session not found")
+)
+
+// Session is a login of a user to the web app. The tokens and authorization
+// codes clients obtain while it lasts are tied to it and revoked when it ends.
+type Session struct {
+	bun.BaseModel `bun:"table:user_sessions"`
+
+	ID        uuid.UUID `bun:",pk,type:uuid,nullzero,default:uuid_generate_v4()"`
+	UserID    uuid.UUID `bun:"type:uuid,notnull"`
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	EndedAt   sql.NullTime
+}
+
+// AccessTokenSession ties an access token to a session
+type AccessTokenSession struct {
+	bun.BaseModel `bun:"table:access_token_sessions"`
+
+	AccessTokenID uuid.UUID `bun:"type:uuid,pk"`
+	SessionID     uuid.UUID `bun:"type:uuid,pk"`
+}
+
+// RefreshTokenSession ties a refresh token to a session, refresh tokens are
+// shared by the sessions of a user with the same client
+type RefreshTokenSession struct {
+	bun.BaseModel `bun:"table:refresh_token_sessions"`
+
+	RefreshTokenID uuid.UUID `bun:"type:uuid,pk"`
+	SessionID      uuid.UUID `bun:"type:uuid,pk"`
+}
+
+// AuthorizationCodeSession ties an authorization code to a session
+type AuthorizationCodeSession struct {
+	bun.BaseModel `bun:"table:authorization_code_sessions"`
+
+	AuthorizationCodeID uuid.UUID `bun:"type:uuid,pk"`
+	SessionID           uuid.UUID `bun:"type:uuid,notnull"`
+}
+
+// Logout is an ended session and the clients which took part in it
+type Logout struct {
+	Session *Session
+	Clients []*LogoutClient
+}
+
+// LogoutClient is a client to notify of a logout
+type LogoutClient struct {
+	Client   *model.Client
+	Metadata *ClientMetadata
+}
+
+// StartSession starts a session for a user who logged in to the web app,
+// the tokens they were issued are tied to it
+func (s *Service) StartSession(user *model.User, accessToken *model.AccessToken, refreshToken *model.RefreshToken) (*Session, error) {
+	return startSession(context.Background(), s.db, user, accessToken, refreshToken)
+}
+
+// startSession starts a session and ties the tokens to it, within the
+// transaction of the caller when db is one
+func startSession(ctx context.Context, db bun.IDB, user *model.User, accessToken *model.AccessToken, refreshToken *model.RefreshToken) (*Session, error) {
+	userSession := &Session{UserID: user.ID}
+
+	_, err := db.NewInsert().
+		Model(userSession).
+		Returning("*").
+		Exec(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = addSessionTokens(ctx, db, []uuid.UUID{userSession.ID}, accessToken, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return userSession, nil
+}
+
+// FindSession returns a session which has not ended
+func (s *Service) FindSession(sessionID string) (*Session, error) {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return nil, ErrSessionNotFound
+	}
+
+	userSession := new(Session)
+
+	err = s.db.NewSelect().
+		Model(userSession).
+		Where("id = ?", id).
+		Where("ended_at IS NULL").
+		Limit(1).
+		Scan(ctx)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrSessionNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return userSession, nil
+}
+
+// AddSessionTokens ties an access token and a refresh token to a session,
+// either may be nil
+func (s *Service) AddSessionTokens(sessionID string, accessToken *model.AccessToken, refreshToken *model.RefreshToken) error {
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return ErrSessionNotFound
+	}
+
+	return addSessionTokens(context.Background(), s.db, []uuid.UUID{id}, accessToken, refreshToken)
+}
+
+// AddSessionAuthorizationCode ties an authorization code to a session, the
+// tokens it is exchanged for are tied to the same session
+func (s *Service) AddSessionAuthorizationCode(sessionID string, authorizationCode *model.AuthorizationCode) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return ErrSessionNotFound
+	}
+
+	_, err = s.db.NewInsert().
+		Model(&AuthorizationCodeSession{
+			AuthorizationCodeID: authorizationCode.ID,
+			SessionID:           id,
+		}).
+		On("CONFLICT DO NOTHING").
+		Exec(ctx)
+
+	return err
+}
+
+// EndSession ends a session and revokes every token and authorization code
+// tied to it. The clients which were issued any are returned so they can be
+// notified.
+func (s *Service) EndSession(sessionID string) (*Logout, error) {
+	ctx := context.Background()
+
+	userSession, err := s.FindSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessTokenIDs := s.db.NewSelect().
+		Model((*AccessTokenSession)(nil)).
+		Column("access_token_id").
+		Where("session_id = ?", userSession.ID)
+
+	refreshTokenIDs := s.db.NewSelect().
+		Model((*RefreshTokenSession)(nil)).
+		Column("refresh_token_id").
+		Where("session_id = ?", userSession.ID)
+
+	authorizationCodeIDs := s.db.NewSelect().
+		Model((*AuthorizationCodeSession)(nil)).
+		Column("authorization_code_id").
+		Where("session_id = ?", userSession.ID)
+
+	sessionTokens := []struct {
+		m   interface{}
+		ids *bun.SelectQuery
+	}{
+		{(*model.AccessToken)(nil), accessTokenIDs},
+		{(*model.RefreshToken)(nil), refreshTokenIDs},
+		{(*model.AuthorizationCode)(nil), authorizationCodeIDs},
+	}
+
+	var clientIDs []uuid.UUID
+
+	for _, tokens := range sessionTokens {
+		var ids []uuid.UUID
+
+		err := s.db.NewSelect().
+			Model(tokens.m).
+			ColumnExpr("DISTINCT client_id").
+			Where("id IN (?)", tokens.ids).
+			Scan(ctx, &ids)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range ids {
+			if !containsUUID(clientIDs, id) {
+				clientIDs = append(clientIDs, id)
+			}
+		}
+	}
+
+	// Begin a transaction
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tokens := range sessionTokens {
+		_, err = tx.NewDelete().
+			Model(tokens.m).
+			Where("id IN (?)", tokens.ids).
+			ForceDelete().
+			Exec(ctx)
+
+		if err != nil {
+			tx.Rollback() // rollback the transaction
+			return nil, err
+		}
+	}
+
+	userSession.EndedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	_, err = tx.NewUpdate().
+		Model(userSession).
+		Column("ended_at").
+		WherePK().
+		Exec(ctx)
+
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	logout := &Logout{Session: userSession, Clients: []*LogoutClient{}}
+
+	if len(clientIDs) == 0 {
+		return logout, nil
+	}
+
+	var clients []*model.Client
+
+	err = s.db.NewSelect().
+		Model(&clients).
+		Where("id IN (?)", bun.In(clientIDs)).
+		Order("key ASC").
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, client := range clients {
+		metadata, err := s.GetClientMetadata(client)
+		if err != nil {
+			return nil, err
+		}
+		logout.Clients = append(logout.Clients, &LogoutClient{Client: client, Metadata: metadata})
+	}
+
+	return logout, nil
+}
+
+// PurgeStaleSessions permanently deletes sessions which ended or outlived
+// every token tied to them, batchSize rows at a time. It returns the number
+// of deleted rows.
+func (s *Service) PurgeStaleSessions(batchSize int) (int, error) {
+	ctx := context.Background()
+
+	if batchSize <= 0 {
+		return 0, ErrInvalidBatchSize
+	}
+
+	cutoff := time.Now().UTC().Add(-time.Duration(s.cnf.Oauth.RefreshTokenLifetime) * time.Second)
+	total := 0
+
+	for {
+		batch := s.db.NewSelect().
+			Model((*Session)(nil)).
+			Column("id").
+			Where("ended_at IS NOT NULL").
+			WhereOr("created_at <= ? AND NOT EXISTS (?) AND NOT EXISTS (?)",
+				cutoff,
+				s.db.NewSelect().Model((*AccessTokenSession)(nil)).ColumnExpr("1").Where("session_id = user_sessions.id"),
+				s.db.NewSelect().Model((*RefreshTokenSession)(nil)).ColumnExpr("1").Where("session_id = user_sessions.id"),
+			).
+			Limit(batchSize)
+
+		res, err := s.db.NewDelete().
+			Model((*Session)(nil)).
+			Where("id IN (?)", batch).
+			Exec(ctx)
+
+		if err != nil {
+			return total, err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+
+		total += int(affected)
+
+		if int(affected) < batchSize {
+			return total, nil
+		}
+	}
+}
+
+// addSessionTokens ties tokens to sessions, tokens already tied are skipped
+func addSessionTokens(ctx context.Context, db bun.IDB, sessionIDs []uuid.UUID, accessToken *model.AccessToken, refreshToken *model.RefreshToken) error {
+	for _, sessionID := range sessionIDs {
+		if accessToken != nil {
+			_, err := db.NewInsert().
+				Model(&AccessTokenSession{
+					AccessTokenID: accessToken.ID,
+					SessionID:     sessionID,
+				}).
+				On("CONFLICT DO NOTHING").
+				Exec(ctx)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if refreshToken != nil {
+			_, err := db.NewInsert().
+				Model(&RefreshTokenSession{
+					RefreshTokenID: refreshToken.ID,
+					SessionID:      sessionID,
+				}).
+				On("CONFLICT DO NOTHING").
+				Exec(ctx)
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// getAuthorizationCodeSessions returns the sessions an authorization code is
+// tied to
+func (s *Service) getAuthorizationCodeSessions(ctx context.Context, authorizationCode *model.AuthorizationCode) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+
+	err := s.db.NewSelect().
+		Model((*AuthorizationCodeSession)(nil)).
+		Column("session_id").
+		Where("authorization_code_id = ?", authorizationCode.ID).
+		Scan(ctx, &ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// getAccessTokenSessions returns the sessions an access token is tied to
+func (s *Service) getAccessTokenSessions(ctx context.Context, accessToken *model.AccessToken) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+
+	err := s.db.NewSelect().
+		Model((*AccessTokenSession)(nil)).
+		Column("session_id").
+		Where("access_token_id = ?", accessToken.ID).
+		Scan(ctx, &ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// getRefreshTokenSessions returns the sessions a refresh token is tied to
+func (s *Service) getRefreshTokenSessions(ctx context.Context, refreshToken *model.RefreshToken) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+
+	err := s.db.NewSelect().
+		Model((*RefreshTokenSession)(nil)).
+		Column("session_id").
+		Where("refresh_token_id = ?", refreshToken.ID).
+		Scan(ctx, &ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func containsUUID(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -2,33 +2,41 @@ package oauth_test
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"os"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/resonatecoop/id/config"
-	"github.com/resonatecoop/id/database"
+	"github.com/resonatecoop/id/events"
 	"github.com/resonatecoop/id/log"
 	"github.com/resonatecoop/id/oauth"
+	testutil "github.com/resonatecoop/id/test-util"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/id/util/password"
 	"github.com/resonatecoop/user-api/model"
 	"github.com/stretchr/testify/suite"
 	"github.com/uptrace/bun"
 )
 
 var (
-	testDbUser = "go_oauth2_server"
-	testDbName = "go_oauth2_server_oauth_test"
-
-	// testFixtures = []string{
-	// 	"./oauth/fixtures/scopes.yml",
-	// 	"./oauth/fixtures/roles.yml",
-	// 	"./oauth/fixtures/test_clients.yml",
-	// 	"./oauth/fixtures/test_users.yml",
-	// }
-
-// 	testMigrations = []func(*bun.DB) error{
-// 		model.MigrateAll,
-// 	}
+	// testClients and testUsers are seeded in the schema of the suite,
+	// tests find them in this order
+	testClients = []struct {
+		id, key, secret, redirectURI string
+	}{
+		{"3392e754-ba3e-424f-a687-add9a8ab39c9", "test_client_1", "test_secret", "https://www.example.com"},
+		{"295be195-898c-4f0c-b6a0-8c62105f42de", "test_client_2", "test_secret", "https://www.example.com"},
+	}
+
+	testUsers = []struct {
+		id, username, password string
+	}{
+		{"243b4178-6f98-4bf1-bbb1-46b57a901816", "test@user.com", "test_password"},
+		{"5253747c-2b8c-40e2-8a70-bab91348a9bd", "test@user2.com", "test_password"},
+	}
 )
 
 func init() {
@@ -46,6 +54,7 @@ type OauthTestSuite struct {
 	clients []*model.Client
 	users   []*model.User
 	router  *mux.Router
+	cleanup func() error
 }
 
 // The SetupSuite method will be run by testify once, at the very
@@ -54,59 +63,21 @@ func (suite *OauthTestSuite) SetupSuite() {
 	// Initialise the config
 	suite.cnf = config.NewConfig(false, false, "etcd")
 
-	var err error
-
-	suite.db, err = database.NewDatabase(suite.cnf)
-
-	if err != nil {
-		panic(err)
+	// Run the suite in a schema of its own
+	db, cleanup, err := testutil.CreateTestSchema(suite.cnf, "oauth")
+	if errors.Is(err, testutil.ErrDatabaseUnreachable) {
+		suite.T().Skip(err)
 	}
-
-	// ASSUME THAT TEST DATABASE HAS ALREADY BEEN CREATED
-	// Create the test database
-	// db, err := testutil.CreateTestDatabasePostgres(
-	// 	suite.cnf.Database.Host,
-	// 	testDbUser,
-	// 	testDbName,
-	// 	testMigrations,
-	// 	testFixtures,
-	// )
-	// if err != nil {
-	// 	t.Skip(err)
-	// }
-	// suite.db = db
-	// suite.db2 = nil // TODO setup test mysql db client
-
-	ctx := context.Background()
-	// Fetch test client
-	suite.clients = make([]*model.Client, 0)
-
-	rows, err := suite.db.QueryContext(ctx, "SELECT * from clients ORDER BY created_at")
-
 	if err != nil {
-		panic(err)
+		suite.T().Fatal(err)
 	}
+	suite.db = db
+	suite.cleanup = cleanup
 
-	err = suite.db.ScanRows(ctx, rows, &suite.clients)
-
-	if err != nil {
-		log.ERROR.Fatal(err)
+	if err = suite.loadFixtures(); err != nil {
+		suite.T().Fatal(err)
 	}
 
-	// Fetch test users
-	suite.users = make([]*model.User, 0)
-
-	rows, err = suite.db.QueryContext(ctx, "SELECT * from users ORDER BY created_at")
-
-	if err != nil {
-		panic(err)
-	}
-
-	err = suite.db.ScanRows(ctx, rows, &suite.users)
-
-	if err != nil {
-		log.ERROR.Fatal(err)
-	}
 	// Initialise the service
 	suite.service = oauth.NewService(suite.cnf, suite.db)
 
@@ -118,7 +89,9 @@ func (suite *OauthTestSuite) SetupSuite() {
 // The TearDownSuite method will be run by testify once, at the very
 // end of the testing suite, after all tests have been run.
 func (suite *OauthTestSuite) TearDownSuite() {
-	//
+	if suite.cleanup != nil {
+		suite.cleanup()
+	}
 }
 
 // The SetupTest method will be run before every test in the suite.
@@ -132,25 +105,55 @@ func (suite *OauthTestSuite) TearDownTest() {
 	// so there is no need to clear them after running a test
 	ctx := context.Background()
 
+	// the resources of the tokens are truncated along with them
 	suite.db.NewTruncateTable().
 		Model(new(model.AuthorizationCode)).
+		Cascade().
 		Exec(ctx)
 
+	// the tokens are untied from their sessions
 	suite.db.NewTruncateTable().
 		Model(new(model.RefreshToken)).
+		Cascade().
 		Exec(ctx)
 
+	// the resources of the tokens are truncated along with them
 	suite.db.NewTruncateTable().
 		Model(new(model.AccessToken)).
+		Cascade().
 		Exec(ctx)
 
-	ids := []string{
-		"243b4178-6f98-4bf1-bbb1-46b57a901816",
-		"5253747c-2b8c-40e2-8a70-bab91348a9bd",
-		"90b26113-37e0-456a-9f75-01db0eb550f8",
-		"f40cf437-eef2-4659-8eb3-7ee93f6dfcea",
-		"046e7a23-a9f0-4fb8-a60f-861b2df05d95",
-		"953c3537-3149-4164-9c73-ec785a3c17c4"}
+	// sessions outlive the tokens tied to them
+	suite.db.NewTruncateTable().
+		Model(new(oauth.Session)).
+		Cascade().
+		Exec(ctx)
+
+	// nothing is delivered to the user api from tests
+	suite.db.NewTruncateTable().
+		Model(new(oauth.UserAPIChange)).
+		Exec(ctx)
+
+	// nor cancelled in stripe
+	suite.db.NewTruncateTable().
+		Model(new(oauth.StripeCancellation)).
+		Exec(ctx)
+
+	// the deliveries and their attempts are truncated along with them
+	suite.db.NewTruncateTable().
+		Model(new(events.Subscription)).
+		Cascade().
+		Exec(ctx)
+
+	suite.db.NewTruncateTable().
+		Model(new(events.Event)).
+		Cascade().
+		Exec(ctx)
+
+	ids := make([]uuid.UUID, len(suite.users))
+	for i, user := range suite.users {
+		ids[i] = user.ID
+	}
 
 	suite.db.NewDelete().
 		Model(new(model.User)).
@@ -158,7 +161,10 @@ func (suite *OauthTestSuite) TearDownTest() {
 		ForceDelete().
 		Exec(ctx)
 
-	ids = []string{"3392e754-ba3e-424f-a687-add9a8ab39c9", "295be195-898c-4f0c-b6a0-8c62105f42de"}
+	ids = make([]uuid.UUID, len(suite.clients))
+	for i, client := range suite.clients {
+		ids[i] = client.ID
+	}
 
 	suite.db.NewDelete().
 		Model(new(model.Client)).
@@ -167,6 +173,49 @@ func (suite *OauthTestSuite) TearDownTest() {
 		Exec(ctx)
 }
 
+// loadFixtures inserts the clients and users the tests use
+func (suite *OauthTestSuite) loadFixtures() error {
+	ctx := context.Background()
+
+	suite.clients = make([]*model.Client, len(testClients))
+	for i, c := range testClients {
+		secretHash, err := password.HashPassword(c.secret)
+		if err != nil {
+			return err
+		}
+
+		suite.clients[i] = &model.Client{
+			IDRecord:    model.IDRecord{ID: uuid.MustParse(c.id)},
+			Key:         c.key,
+			Secret:      string(secretHash),
+			RedirectURI: util.StringOrNull(c.redirectURI),
+		}
+	}
+
+	if _, err := suite.db.NewInsert().Model(&suite.clients).Exec(ctx); err != nil {
+		return err
+	}
+
+	suite.users = make([]*model.User, len(testUsers))
+	for i, u := range testUsers {
+		passwordHash, err := password.HashPassword(u.password)
+		if err != nil {
+			return err
+		}
+
+		suite.users[i] = &model.User{
+			IDRecord: model.IDRecord{ID: uuid.MustParse(u.id)},
+			Username: u.username,
+			RoleID:   int32(model.UserRole),
+			Password: sql.NullString{String: string(passwordHash), Valid: true},
+		}
+	}
+
+	_, err := suite.db.NewInsert().Model(&suite.users).Exec(ctx)
+
+	return err
+}
+
 // TestOauthTestSuite ...
 // In order for 'go test' to run this suite, we need to create
 // a normal test function and pass our suite to suite.Run
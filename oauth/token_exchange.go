@@ -0,0 +1,509 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/oauth/tokentypes"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// TokenExchangeGrantType is the grant type of token exchange (RFC 8693)
+	TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	// AccessTokenType identifies access tokens in token exchange requests
+	// and responses
+	AccessTokenType = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+var (
+	// ErrInvalidSubjectToken ...
+	ErrInvalidSubjectToken = errors.New("Invalid subject token")
+	// ErrInvalidActorToken ...
+	ErrInvalidActorToken = errors.New("Invalid actor token")
+	// ErrUnsupportedTokenType ...
+	ErrUnsupportedTokenType = errors.New("Unsupported token type")
+	// ErrImpersonationNotAllowed ...
+	ErrImpersonationNotAllowed = errors.New("Impersonation not allowed")
+	// ErrTokenExchangePolicyNotFound ...
+	ErrTokenExchangePolicyNotFound = errors.New("Token exchange policy not found")
+)
+
+// TokenExchangePolicy lists what a client may exchange access tokens for:
+// tokens issued to the subject clients, for the resources and scopes listed.
+// Clients without a policy cannot exchange tokens.
+type TokenExchangePolicy struct {
+	bun.BaseModel `bun:"table:token_exchange_policies"`
+
+	ClientID           uuid.UUID `bun:"type:uuid,pk"`
+	SubjectClients     []string  `bun:"subject_clients,array"`
+	Resources          []string  `bun:"resources,array"`
+	Scopes             []string  `bun:"scopes,array"`
+	AllowImpersonation bool      `bun:",notnull"`
+	CreatedAt          time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	UpdatedAt          time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// Actor is the act claim of a token obtained with an actor token, the user
+// acting on behalf of the subject and the actors of the subject token
+// (RFC 8693 section 4.1)
+type Actor struct {
+	Subject  string `json:"sub"`
+	Username string `json:"username,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	Actor    *Actor `json:"act,omitempty"`
+}
+
+// AccessTokenActor holds the act claim of an access token
+type AccessTokenActor struct {
+	bun.BaseModel `bun:"table:access_token_actors"`
+
+	AccessTokenID uuid.UUID `bun:"type:uuid,pk"`
+	Act           *Actor    `bun:"act,type:jsonb,notnull"`
+}
+
+// SetTokenExchangePolicy replaces the token exchange policy of a client, it
+// must list at least one registered resource
+func (s *Service) SetTokenExchangePolicy(client *model.Client, policy *TokenExchangePolicy) error {
+	ctx := context.Background()
+
+	resources, err := s.GetResources(client, "", policy.Resources)
+	if err != nil {
+		return err
+	}
+	if len(resources) == 0 {
+		return ErrInvalidTarget
+	}
+
+	scopes := splitScope(strings.Join(policy.Scopes, " "))
+	if len(scopes) > 0 && !s.ScopeExists(strings.Join(scopes, " ")) {
+		return ErrInvalidScope
+	}
+
+	for _, clientID := range policy.SubjectClients {
+		if _, err := s.FindClientByClientID(clientID); err != nil {
+			return err
+		}
+	}
+
+	_, err = s.db.NewInsert().
+		Model(&TokenExchangePolicy{
+			ClientID:           client.ID,
+			SubjectClients:     nonNil(policy.SubjectClients),
+			Resources:          resources,
+			Scopes:             nonNil(scopes),
+			AllowImpersonation: policy.AllowImpersonation,
+			UpdatedAt:          time.Now().UTC(),
+		}).
+		ExcludeColumn("created_at").
+		On("CONFLICT (client_id) DO UPDATE").
+		Set("subject_clients = EXCLUDED.subject_clients").
+		Set("resources = EXCLUDED.resources").
+		Set("scopes = EXCLUDED.scopes").
+		Set("allow_impersonation = EXCLUDED.allow_impersonation").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+
+	return err
+}
+
+// GetTokenExchangePolicy returns the token exchange policy of a client
+func (s *Service) GetTokenExchangePolicy(client *model.Client) (*TokenExchangePolicy, error) {
+	ctx := context.Background()
+
+	policy := new(TokenExchangePolicy)
+
+	err := s.db.NewSelect().
+		Model(policy).
+		Where("client_id = ?", client.ID).
+		Limit(1).
+		Scan(ctx)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrTokenExchangePolicyNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// DeleteTokenExchangePolicy stops a client from exchanging tokens, the
+// tokens it obtained stay valid until they expire
+func (s *Service) DeleteTokenExchangePolicy(client *model.Client) error {
+	ctx := context.Background()
+
+	res, err := s.db.NewDelete().
+		Model((*TokenExchangePolicy)(nil)).
+		Where("client_id = ?", client.ID).
+		Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrTokenExchangePolicyNotFound
+	}
+
+	return nil
+}
+
+// GetAccessTokenActor returns the act claim of an access token, nil when
+// the token was not obtained by an actor
+func (s *Service) GetAccessTokenActor(accessToken *model.AccessToken) (*Actor, error) {
+	ctx := context.Background()
+
+	actor := new(AccessTokenActor)
+
+	err := s.db.NewSelect().
+		Model(actor).
+		Where("access_token_id = ?", accessToken.ID).
+		Limit(1).
+		Scan(ctx)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return actor.Act, nil
+}
+
+// tokenExchangeGrant exchanges the access token of a user for a token of the
+// client restricted to some of the resources and scopes of its policy, the
+// scope cannot be greater than the one of the subject token. Support staff
+// send their own token as actor token to act on behalf of the user.
+func (s *Service) tokenExchangeGrant(r *http.Request, client *model.Client) (*AccessTokenResponse, error) {
+	ctx := context.Background()
+
+	policy, err := s.GetTokenExchangePolicy(client)
+	if err == ErrTokenExchangePolicyNotFound {
+		return nil, ErrUnauthorizedClient
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Only access tokens are exchanged for access tokens
+	if r.Form.Get("subject_token_type") != AccessTokenType {
+		return nil, ErrUnsupportedTokenType
+	}
+	if tokenType := r.Form.Get("requested_token_type"); tokenType != "" && tokenType != AccessTokenType {
+		return nil, ErrUnsupportedTokenType
+	}
+
+	// Check the subject token
+	subjectToken, subjectClient, err := s.getExchangedToken(r, r.Form.Get("subject_token"))
+	if err != nil {
+		return nil, ErrInvalidSubjectToken
+	}
+	if subjectToken.UserID == uuid.Nil || !util.StringInSlice(subjectClient.Key, policy.SubjectClients) {
+		return nil, ErrInvalidSubjectToken
+	}
+
+	user := new(model.User)
+	err = s.db.NewSelect().
+		Model(user).
+		Where("id = ?", subjectToken.UserID).
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		return nil, ErrInvalidSubjectToken
+	}
+
+	// Tokens obtained by an actor keep their actors
+	act, err := s.GetAccessTokenActor(subjectToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// The token cannot outlive the subject token
+	lifetime := s.cnf.Oauth.AccessTokenLifetime
+	if remaining := int(time.Until(subjectToken.ExpiresAt).Seconds()); remaining < lifetime {
+		lifetime = remaining
+	}
+
+	// Check the actor token, nor can the token outlive it
+	var actorToken *model.AccessToken
+	if token := r.Form.Get("actor_token"); token != "" {
+		act, actorToken, err = s.getActor(r, policy, token, user, act)
+		if err != nil {
+			return nil, err
+		}
+
+		if remaining := int(time.Until(actorToken.ExpiresAt).Seconds()); remaining < lifetime {
+			lifetime = remaining
+		}
+	}
+
+	if lifetime <= 0 {
+		return nil, ErrInvalidSubjectToken
+	}
+
+	// Get the scope string
+	scope, err := s.getExchangeScope(r, client, policy, subjectToken.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the resources the token is restricted to
+	resources, err := s.getExchangeResources(ctx, client, policy, scope, r.Form["resource"], r.Form["audience"])
+	if err != nil {
+		return nil, err
+	}
+
+	// Logging out revokes the tokens obtained with the subject token
+	sessionIDs, err := s.getAccessTokenSessions(ctx, subjectToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Begin a transaction, the token must not be used without its actor
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a new access token
+	accessToken, err := grantAccessToken(
+		ctx,
+		tx,
+		client,
+		user,
+		lifetime, // expires in
+		scope,
+		resources...,
+	)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	if act != nil {
+		_, err = tx.NewInsert().
+			Model(&AccessTokenActor{
+				AccessTokenID: accessToken.ID,
+				Act:           act,
+			}).
+			Exec(ctx)
+
+		if err != nil {
+			tx.Rollback() // rollback the transaction
+			return nil, err
+		}
+	}
+
+	// Acting as the user leaves the same trail as impersonating them in
+	// the web app
+	if actorToken != nil {
+		if err = insertImpersonation(ctx, tx, newExchangeImpersonation(client, user, act, accessToken)); err != nil {
+			tx.Rollback() // rollback the transaction
+			return nil, err
+		}
+	}
+
+	if err = addSessionTokens(ctx, tx, sessionIDs, accessToken, nil); err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// Create response
+	accessTokenResponse, err := NewAccessTokenResponse(
+		accessToken,
+		nil, // refresh token
+		lifetime,
+		tokentypes.Bearer,
+	)
+	if err != nil {
+		return nil, err
+	}
+	accessTokenResponse.IssuedTokenType = AccessTokenType
+
+	return accessTokenResponse, nil
+}
+
+// getExchangedToken returns a valid access token sent to be exchanged and
+// the client it was issued to, tokens of other realms are invalid
+func (s *Service) getExchangedToken(r *http.Request, token string) (*model.AccessToken, *model.Client, error) {
+	ctx := context.Background()
+
+	if token == "" {
+		return nil, nil, ErrTokenMissing
+	}
+
+	accessToken, err := s.Authenticate(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.checkTokenRealm(r.Context(), accessToken.ClientID, accessToken.UserID); err != nil {
+		return nil, nil, err
+	}
+
+	client := new(model.Client)
+	err = s.db.NewSelect().
+		Model(client).
+		Where("id = ?", accessToken.ClientID).
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		return nil, nil, ErrClientNotFound
+	}
+
+	return accessToken, client, nil
+}
+
+// getActor returns the act claim of a token obtained with an actor token,
+// the actor must be allowed to impersonate the user, as for
+// StartImpersonation, and the client to accept actor tokens. Actor tokens
+// obtained by an actor themselves are invalid.
+func (s *Service) getActor(r *http.Request, policy *TokenExchangePolicy, token string, user *model.User, subjectAct *Actor) (*Actor, *model.AccessToken, error) {
+	ctx := context.Background()
+
+	if !policy.AllowImpersonation {
+		return nil, nil, ErrImpersonationNotAllowed
+	}
+
+	if r.Form.Get("actor_token_type") != AccessTokenType {
+		return nil, nil, ErrUnsupportedTokenType
+	}
+
+	actorToken, actorClient, err := s.getExchangedToken(r, token)
+	if err != nil || actorToken.UserID == uuid.Nil {
+		return nil, nil, ErrInvalidActorToken
+	}
+
+	if act, err := s.GetAccessTokenActor(actorToken); err != nil || act != nil {
+		return nil, nil, ErrInvalidActorToken
+	}
+
+	actor := new(model.User)
+	err = s.db.NewSelect().
+		Model(actor).
+		Where("id = ?", actorToken.UserID).
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		return nil, nil, ErrInvalidActorToken
+	}
+
+	if err = s.checkImpersonation(ctx, actor, user); err != nil {
+		return nil, nil, err
+	}
+
+	return &Actor{
+		Subject:  actor.ID.String(),
+		Username: actor.Username,
+		ClientID: actorClient.Key,
+		Actor:    subjectAct,
+	}, actorToken, nil
+}
+
+// newExchangeImpersonation returns the impersonation of a user by the actor
+// of a token obtained through token exchange, it lasts as long as the token
+func newExchangeImpersonation(client *model.Client, user *model.User, act *Actor, accessToken *model.AccessToken) *Impersonation {
+	// the subject of the actor is the ID of the user getActor checked
+	actorID, _ := uuid.Parse(act.Subject)
+
+	return &Impersonation{
+		ActorID:       actorID,
+		ActorUsername: act.Username,
+		UserID:        user.ID,
+		Username:      user.Username,
+		Reason:        fmt.Sprintf("Token exchange by %s", client.Key),
+		StartedAt:     accessToken.CreatedAt,
+		ExpiresAt:     accessToken.ExpiresAt,
+	}
+}
+
+// getExchangeScope returns the requested scope, the scopes of the subject
+// token the policy allows by default. It cannot be greater than the scope
+// of the subject token.
+func (s *Service) getExchangeScope(r *http.Request, client *model.Client, policy *TokenExchangePolicy, subjectScope string) (string, error) {
+	subjectScopes := splitScope(subjectScope)
+
+	var scopes []string
+	if requestedScope := r.Form.Get("scope"); requestedScope != "" {
+		for _, name := range splitScope(requestedScope) {
+			if !util.StringInSlice(name, subjectScopes) {
+				return "", ErrRequestedScopeCannotBeGreater
+			}
+			if !util.StringInSlice(name, policy.Scopes) {
+				return "", ErrInvalidScope
+			}
+			scopes = append(scopes, name)
+		}
+	} else {
+		for _, name := range subjectScopes {
+			if util.StringInSlice(name, policy.Scopes) {
+				scopes = append(scopes, name)
+			}
+		}
+	}
+
+	if len(scopes) == 0 {
+		return "", ErrInvalidScope
+	}
+
+	return s.getRealmScope(r, client, strings.Join(scopes, " "))
+}
+
+// getExchangeResources returns the resources a token is exchanged for, named
+// by their URI in resource parameters or by their namespace in audience
+// parameters. At least one is required and all of them must be allowed by
+// the policy.
+func (s *Service) getExchangeResources(ctx context.Context, client *model.Client, policy *TokenExchangePolicy, scope string, resources, audiences []string) ([]string, error) {
+	requested := append([]string(nil), resources...)
+
+	for _, audience := range audiences {
+		if isValidResource(audience) {
+			requested = append(requested, audience)
+			continue
+		}
+
+		var uris []string
+		err := s.db.NewSelect().
+			Model((*Resource)(nil)).
+			Column("uri").
+			Where("namespace = ?", audience).
+			Scan(ctx, &uris)
+		if err != nil {
+			return nil, err
+		}
+		if len(uris) == 0 {
+			return nil, ErrInvalidTarget
+		}
+		requested = append(requested, uris...)
+	}
+
+	if len(requested) == 0 {
+		return nil, ErrInvalidTarget
+	}
+
+	for _, uri := range requested {
+		if !util.StringInSlice(uri, policy.Resources) {
+			return nil, ErrInvalidTarget
+		}
+	}
+
+	return s.GetResources(client, scope, requested)
+}
@@ -0,0 +1,222 @@
+package oauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/rbac"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestTokenExchange() {
+	ctx := context.Background()
+
+	tracks, err := suite.service.CreateResource("https://api.resonate.test/tracks", "tracks", "")
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	defer suite.service.DeleteResource(tracks.URI)
+
+	payouts, err := suite.service.CreateResource("https://api.resonate.test/payouts", "payouts", "")
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	defer suite.service.DeleteResource(payouts.URI)
+
+	// The backend of the upload tool
+	info, err := suite.service.RegisterClient(uuid.Nil, &oauth.ClientRegistration{
+		RedirectURIs: []string{"https://upload.example.com/callback"},
+		GrantTypes:   []string{oauth.TokenExchangeGrantType},
+		ClientName:   "Upload Backend",
+	})
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	backend, err := suite.service.FindClientByClientID(info.ClientID)
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	defer suite.service.DeleteClient(backend)
+
+	subjectToken, err := suite.service.GrantAccessToken(suite.clients[0], suite.users[0], 600, "read read_write")
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+
+	exchange := func(form url.Values) (*httptest.ResponseRecorder, *oauth.AccessTokenResponse) {
+		form.Set("grant_type", oauth.TokenExchangeGrantType)
+		form.Set("subject_token_type", oauth.AccessTokenType)
+		if form.Get("subject_token") == "" {
+			form.Set("subject_token", subjectToken.Token)
+		}
+
+		r, err := http.NewRequest("POST", "http://1.2.3.4/v1/oauth/tokens", nil)
+		assert.NoError(suite.T(), err, "Request setup should not get an error")
+		r.SetBasicAuth(info.ClientID, info.ClientSecret)
+		r.PostForm = form
+
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, r)
+
+		resp := new(oauth.AccessTokenResponse)
+		if w.Code == http.StatusOK {
+			assert.Nil(suite.T(), json.Unmarshal(w.Body.Bytes(), resp))
+		}
+		return w, resp
+	}
+
+	// Clients without a policy cannot exchange tokens
+	w, _ := exchange(url.Values{"audience": {"tracks"}})
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+
+	assert.Equal(suite.T(), oauth.ErrInvalidTarget, suite.service.SetTokenExchangePolicy(backend, &oauth.TokenExchangePolicy{}))
+	assert.Nil(suite.T(), suite.service.SetTokenExchangePolicy(backend, &oauth.TokenExchangePolicy{
+		SubjectClients: []string{suite.clients[0].Key},
+		Resources:      []string{tracks.URI},
+		Scopes:         []string{"read"},
+	}))
+	defer suite.service.DeleteTokenExchangePolicy(backend)
+
+	// The token is restricted to the audience and scopes of the policy
+	w, resp := exchange(url.Values{"audience": {"tracks"}})
+	if assert.Equal(suite.T(), http.StatusOK, w.Code, w.Body.String()) {
+		assert.Equal(suite.T(), "read", resp.Scope)
+		assert.Equal(suite.T(), oauth.AccessTokenType, resp.IssuedTokenType)
+		assert.Empty(suite.T(), resp.RefreshToken)
+		assert.True(suite.T(), resp.ExpiresIn <= 600)
+
+		accessToken, err := suite.service.Authenticate(resp.AccessToken)
+		if assert.Nil(suite.T(), err) {
+			assert.Equal(suite.T(), backend.ID, accessToken.ClientID)
+			assert.Equal(suite.T(), suite.users[0].ID, accessToken.UserID)
+
+			audience, err := suite.service.GetAccessTokenResources(accessToken)
+			assert.Nil(suite.T(), err)
+			assert.Equal(suite.T(), []string{tracks.URI}, audience)
+		}
+	}
+
+	// Resources, scopes and subject tokens outside the policy are rejected
+	for _, form := range []url.Values{
+		{},
+		{"resource": {payouts.URI}},
+		{"audience": {"payouts"}},
+		{"audience": {"bogus"}},
+		{"resource": {tracks.URI}, "scope": {"read_write"}},
+		{"resource": {tracks.URI}, "subject_token": {"bogus"}},
+		{"resource": {tracks.URI}, "requested_token_type": {"urn:ietf:params:oauth:token-type:refresh_token"}},
+	} {
+		w, _ := exchange(form)
+		assert.Equal(suite.T(), http.StatusBadRequest, w.Code, form)
+	}
+
+	// Actor tokens are only accepted when the policy allows impersonation
+	actorToken, err := suite.service.GrantAccessToken(suite.clients[0], suite.users[1], 600, "read")
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	actorForm := url.Values{
+		"resource":         {tracks.URI},
+		"actor_token":      {actorToken.Token},
+		"actor_token_type": {oauth.AccessTokenType},
+	}
+
+	w, _ = exchange(actorForm)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+
+	assert.Nil(suite.T(), suite.service.SetTokenExchangePolicy(backend, &oauth.TokenExchangePolicy{
+		SubjectClients:     []string{suite.clients[0].Key},
+		Resources:          []string{tracks.URI},
+		Scopes:             []string{"read"},
+		AllowImpersonation: true,
+	}))
+
+	// The actor must be allowed to impersonate users
+	rbacService := suite.service.GetRBACService()
+	rbacService.CreatePermission(ctx, rbac.PermissionUsersImpersonate, "")
+	support, err := rbacService.CreateRole(ctx, "support", "", []string{rbac.PermissionUsersImpersonate})
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	defer rbacService.DeleteRole(ctx, support)
+
+	allowed, err := rbacService.HasPermission(ctx, suite.users[1], rbac.PermissionUsersImpersonate)
+	if assert.Nil(suite.T(), err) && !allowed {
+		w, _ = exchange(actorForm)
+		assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+	}
+
+	assert.Nil(suite.T(), rbacService.AssignRole(ctx, suite.users[1], support, uuid.Nil))
+	defer rbacService.RevokeRole(ctx, suite.users[1], support, uuid.Nil)
+
+	// Nobody acts as themselves
+	ownToken, err := suite.service.GrantAccessToken(suite.clients[0], suite.users[1], 600, "read")
+	if assert.Nil(suite.T(), err) {
+		form := url.Values{"subject_token": {ownToken.Token}}
+		for key, values := range actorForm {
+			form[key] = values
+		}
+		w, _ = exchange(form)
+		assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+	}
+
+	// Subjects who may impersonate, or are admins, cannot be acted as
+	assert.Nil(suite.T(), rbacService.AssignRole(ctx, suite.users[0], support, uuid.Nil))
+	w, _ = exchange(actorForm)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+	assert.Nil(suite.T(), rbacService.RevokeRole(ctx, suite.users[0], support, uuid.Nil))
+
+	admin, err := rbacService.FindRoleByID(ctx, int32(model.AdminRole))
+	if assert.Nil(suite.T(), err) {
+		assert.Nil(suite.T(), rbacService.AssignRole(ctx, suite.users[0], admin, uuid.Nil))
+		w, _ = exchange(actorForm)
+		assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+		assert.Nil(suite.T(), rbacService.RevokeRole(ctx, suite.users[0], admin, uuid.Nil))
+	}
+
+	w, resp = exchange(actorForm)
+	if assert.Equal(suite.T(), http.StatusOK, w.Code, w.Body.String()) {
+		accessToken, err := suite.service.Authenticate(resp.AccessToken)
+		if !assert.Nil(suite.T(), err) {
+			return
+		}
+		assert.Equal(suite.T(), suite.users[0].ID, accessToken.UserID)
+
+		introspection, err := suite.service.NewIntrospectResponseFromAccessToken(accessToken)
+		if assert.Nil(suite.T(), err) && assert.NotNil(suite.T(), introspection.Act) {
+			assert.Equal(suite.T(), suite.users[1].ID.String(), introspection.Act.Subject)
+			assert.Equal(suite.T(), suite.clients[0].Key, introspection.Act.ClientID)
+		}
+
+		// Acting as the user is recorded like an impersonation, without a
+		// session
+		impersonations, err := suite.service.ListImpersonations(suite.users[1])
+		assert.Nil(suite.T(), err)
+		var recorded *oauth.Impersonation
+		for _, impersonation := range impersonations {
+			if impersonation.SessionID == uuid.Nil {
+				recorded = impersonation
+			}
+		}
+		if assert.NotNil(suite.T(), recorded) {
+			assert.Equal(suite.T(), suite.users[0].ID, recorded.UserID)
+			assert.Equal(suite.T(), accessToken.ExpiresAt.Unix(), recorded.ExpiresAt.Unix())
+
+			events, err := suite.service.ListImpersonationEvents(recorded)
+			if assert.Nil(suite.T(), err) && assert.Len(suite.T(), events, 1) {
+				assert.Equal(suite.T(), oauth.ImpersonationStart, events[0].Action)
+			}
+		}
+
+		// Tokens obtained by an actor cannot act themselves
+		actorForm.Set("actor_token", accessToken.Token)
+		w, _ = exchange(actorForm)
+		assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	}
+}
@@ -2,14 +2,20 @@ package oauth
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
 
-	"github.com/mailgun/mailgun-go/v4"
+	"github.com/google/uuid"
 	"github.com/pariz/gountries"
+	"github.com/resonatecoop/id/events"
 	"github.com/resonatecoop/id/log"
+	"github.com/resonatecoop/id/metrics"
+	"github.com/resonatecoop/id/rbac"
+	"github.com/resonatecoop/id/realm"
+	"github.com/resonatecoop/id/tracing"
 	pass "github.com/resonatecoop/id/util/password"
 	"github.com/resonatecoop/user-api/model"
 	"github.com/uptrace/bun"
@@ -103,6 +109,181 @@ func (s *Service) FindUserByEmail(email string) (*model.User, error) {
 	return user, nil
 }
 
+// CreateUser signs up a user in a realm with the given roles, users picking
+// none get the role of the realm. The user, its credit wallet, its roles and
+// the change for the user api are stored in a single transaction.
+func (s *Service) CreateUser(rlm *realm.Realm, username, password, country string, roleIDs []int32) (*model.User, error) {
+	return s.createUserCommon(context.Background(), rlm, username, password, country, roleIDs, false, nil)
+}
+
+// CreateConfirmedUser works like CreateUser for users whose email address
+// was vouched for, e.g. by a directory or an identity provider. The address
+// is confirmed and setup, unless nil, runs in the same transaction, so a
+// failure leaves no half provisioned user behind.
+func (s *Service) CreateConfirmedUser(ctx context.Context, rlm *realm.Realm, username, password, country string, roleIDs []int32, setup func(ctx context.Context, tx bun.Tx, user *model.User) error) (*model.User, error) {
+	return s.createUserCommon(ctx, rlm, username, password, country, roleIDs, true, setup)
+}
+
+func (s *Service) createUserCommon(ctx context.Context, rlm *realm.Realm, username, password, country string, roleIDs []int32, confirmed bool, setup func(ctx context.Context, tx bun.Tx, user *model.User) error) (*model.User, error) {
+	if username == "" {
+		return nil, ErrUsernameRequired
+	}
+
+	// Check the email/username is available, accounts pending deletion
+	// keep theirs
+	taken, err := s.db.NewSelect().
+		Model((*model.User)(nil)).
+		WhereAllWithDeleted().
+		Where("username = LOWER(?)", username).
+		Exists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, ErrUsernameTaken
+	}
+
+	if country != "" {
+		countryCode, err := findCountryCode(country)
+		if err != nil {
+			return nil, err
+		}
+		country = countryCode
+	}
+
+	passwordHash, err := pass.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(roleIDs) == 0 {
+		roleIDs = []int32{rlm.RoleID()}
+	}
+
+	now := time.Now().UTC()
+
+	user := &model.User{
+		IDRecord: model.IDRecord{ID: uuid.New()},
+		Username: strings.ToLower(username),
+		Country:  country,
+		RoleID:   rbac.PrimaryRoleID(roleIDs),
+		Password: sql.NullString{
+			String: string(passwordHash),
+			Valid:  true,
+		},
+		LastPasswordChange: now,
+	}
+
+	if !containsRoleID(roleIDs, user.RoleID) {
+		roleIDs = append(roleIDs, user.RoleID)
+	}
+
+	roles := []*model.Role{}
+	err = s.db.NewSelect().
+		Model(&roles).
+		Where("id IN (?)", bun.In(roleIDs)).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) != len(roleIDs) {
+		return nil, rbac.ErrRoleNotFound
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// legacy_id is left to its sequence
+	_, err = tx.NewInsert().
+		Model(user).
+		Column("id", "username", "role_id", "tenant_id", "member", "country", "newsletter_notification", "password", "last_password_change").
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	_, err = tx.NewInsert().
+		Model(&model.Credit{UserID: user.ID, Total: 128}).
+		Column("user_id", "total").
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	userRoles := make([]*rbac.UserRole, len(roles))
+	assignments := make([]*rbac.Assignment, len(roles))
+	for i, role := range roles {
+		userRoles[i] = &rbac.UserRole{UserID: user.ID, RoleID: role.ID}
+		assignments[i] = &rbac.Assignment{
+			UserID:   user.ID,
+			RoleID:   role.ID,
+			RoleName: role.Name,
+			Action:   rbac.ActionGrant,
+		}
+	}
+
+	if _, err = tx.NewInsert().Model(&userRoles).Exec(ctx); err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	if _, err = tx.NewInsert().Model(&assignments).Exec(ctx); err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	if !rlm.IsDefault() {
+		_, err = tx.NewInsert().
+			Model(&realm.User{UserID: user.ID, RealmID: rlm.ID}).
+			Exec(ctx)
+		if err != nil {
+			tx.Rollback() // rollback the transaction
+			return nil, err
+		}
+	}
+
+	if err = s.enqueueUserAPIChange(ctx, tx, user.ID, UserAPICreate); err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	_, err = s.events.Emit(ctx, tx, events.UserRegistered, user.ID, map[string]interface{}{
+		"username": user.Username,
+		"country":  user.Country,
+		"role_ids": roleIDs,
+		"realm":    rlm.Slug,
+	})
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	if confirmed {
+		if err = s.confirmUserEmailCommon(ctx, tx, user); err != nil {
+			tx.Rollback() // rollback the transaction
+			return nil, err
+		}
+	}
+
+	if setup != nil {
+		if err = setup(ctx, tx, user); err != nil {
+			tx.Rollback() // rollback the transaction
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
 // SetPassword sets a user password
 func (s *Service) SetPassword(user *model.User, password string) error {
 	return s.setPasswordCommon(s.db, user, password)
@@ -114,34 +295,73 @@ func (s *Service) SetPasswordTx(tx *bun.DB, user *model.User, password string) e
 }
 
 // AuthUser authenticates user
-func (s *Service) AuthUser(username, password string) (*model.User, error) {
+func (s *Service) AuthUser(ctx context.Context, username, password string) (*model.User, error) {
+	route := s.authBackends.ForUsername(username)
+
 	// Fetch the user
 	user, err := s.FindUserByUsername(username)
 	if err != nil {
-		return nil, err
+		// Accounts pending deletion cannot log in
+		if s.isPendingDeletion(username, password) {
+			return nil, ErrAccountPendingDeletion
+		}
+		// Unless the backend provisions its users
+		if !route.Provision {
+			return nil, err
+		}
+		user = nil
 	}
 
-	// Check that the password is set
-	if !user.Password.Valid {
+	// Check that the password is set, users without one are locked
+	// whatever their backend
+	if user != nil && !user.Password.Valid {
 		return nil, ErrUserPasswordNotSet
 	}
 
 	// Verify the password
-	if pass.VerifyPassword(user.Password.String, password) != nil {
-		return nil, ErrInvalidUserPassword
+	identity, err := route.Backend.Authenticate(ctx, username, password, user)
+	if err != nil {
+		return nil, authError(err)
+	}
+
+	if user == nil {
+		return s.provisionUser(ctx, route, identity)
+	}
+
+	if err = s.syncIdentity(ctx, s.db, user, identity); err != nil {
+		return nil, err
 	}
 
 	return user, nil
 }
 
 // UpdateUsername ...
-func (s *Service) UpdateUsername(user *model.User, username, password string) error {
-	return s.updateUsernameCommon(s.db, user, username, password)
+func (s *Service) UpdateUsername(ctx context.Context, user *model.User, username, password string) error {
+	return s.updateUsernameCommon(ctx, s.db, user, username, password)
 }
 
 // UpdateUsernameTx ...
-func (s *Service) UpdateUsernameTx(tx *bun.DB, user *model.User, username, password string) error {
-	return s.updateUsernameCommon(tx, user, username, password)
+func (s *Service) UpdateUsernameTx(ctx context.Context, tx *bun.DB, user *model.User, username, password string) error {
+	return s.updateUsernameCommon(ctx, tx, user, username, password)
+}
+
+// SetUsername changes the username of a user without asking for its
+// password, e.g. when an identity provider provisions the account. The
+// address is trusted so its confirmation is kept.
+func (s *Service) SetUsername(user *model.User, username string) error {
+	if username == "" {
+		return ErrCannotSetEmptyUsername
+	}
+
+	if strings.ToLower(username) == user.Username {
+		return nil
+	}
+
+	if s.UserExists(username) {
+		return ErrUsernameTaken
+	}
+
+	return s.changeUsername(context.Background(), s.db, user, username, user.EmailConfirmed)
 }
 
 func (s *Service) ConfirmUserEmail(email string) error {
@@ -152,13 +372,100 @@ func (s *Service) ConfirmUserEmail(email string) error {
 		return err
 	}
 
-	_, err = s.db.NewUpdate().
+	// confirming twice is harmless, subscribers only hear of it once
+	if user.EmailConfirmed {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err = s.confirmUserEmailCommon(ctx, tx, user); err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// confirmUserEmailCommon marks the address of a user as confirmed within tx
+func (s *Service) confirmUserEmailCommon(ctx context.Context, tx bun.Tx, user *model.User) error {
+	_, err := tx.NewUpdate().
 		Model(user).
 		Set("email_confirmed = ?", true).
 		WherePK().
 		Exec(ctx)
+	if err != nil {
+		return err
+	}
 
-	return err
+	_, err = s.events.Emit(ctx, tx, events.UserEmailConfirmed, user.ID, map[string]interface{}{
+		"username": user.Username,
+	})
+	if err != nil {
+		return err
+	}
+
+	user.EmailConfirmed = true
+
+	return nil
+}
+
+// SetUserRole replaces the roles of a user with a single role, the change
+// is recorded in the role assignment history along with the actor making
+// it, uuid.Nil when there is none
+func (s *Service) SetUserRole(user *model.User, roleID int32, actorID uuid.UUID) error {
+	ctx := context.Background()
+
+	role, err := s.rbac.FindRoleByID(ctx, roleID)
+	if err == rbac.ErrRoleNotFound {
+		return ErrRoleNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.rbac.SetRole(ctx, user, role, actorID)
+}
+
+// LockUser prevents a user from logging in by clearing its password
+// and revoking all of its tokens. The account is unlocked once the
+// password gets reset.
+func (s *Service) LockUser(user *model.User) error {
+	ctx := context.Background()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = s.revokeUserTokensCommon(tx, user); err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	_, err = tx.NewUpdate().
+		Model(user).
+		Set("password = NULL").
+		Set("updated_at = ?", time.Now().UTC()).
+		WherePK().
+		Exec(ctx)
+
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	user.Password.Valid = false
+	user.Password.String = ""
+
+	return nil
 }
 
 // UpdateUser ...
@@ -176,66 +483,53 @@ func (s *Service) SetUserCountryTx(tx *bun.DB, user *model.User, country string)
 	return s.setUserCountryCommon(tx, user, country)
 }
 
-// Delete user will soft delete  user
-func (s *Service) DeleteUser(user *model.User, password string) error {
-	return s.deleteUserCommon(s.db, user, password)
+// DeleteUser schedules the user for deletion, the account can be
+// restored until the grace period is over
+func (s *Service) DeleteUser(ctx context.Context, user *model.User, password string) error {
+	return s.deleteUserCommon(ctx, s.db, user, password)
 }
 
 // DeleteUserTx deletes a user in a transaction
-func (s *Service) DeleteUserTx(tx *bun.DB, user *model.User, password string) error {
-	return s.deleteUserCommon(tx, user, password)
+func (s *Service) DeleteUserTx(ctx context.Context, tx *bun.DB, user *model.User, password string) error {
+	return s.deleteUserCommon(ctx, tx, user, password)
 }
 
-func (s *Service) deleteUserCommon(db *bun.DB, user *model.User, password string) error {
-	ctx := context.Background()
-
-	// Check that the password is set
-	if !user.Password.Valid {
-		return ErrUserPasswordNotSet
-	}
+func (s *Service) deleteUserCommon(ctx context.Context, db *bun.DB, user *model.User, password string) error {
+	logger := log.FromContext(ctx)
 
 	// Verify the password
-	if pass.VerifyPassword(user.Password.String, password) != nil {
-		return ErrInvalidUserPassword
+	if err := s.checkPassword(ctx, user, password); err != nil {
+		return err
 	}
 
-	// will set deleted_at to current time using soft delete
-	_, err := db.NewDelete().
-		Model(user).
-		WherePK().
-		Exec(ctx)
-
-	if err != nil {
+	if err := s.scheduleUserDeletion(db, user); err != nil {
+		logger.ERROR.Print(err)
 		return ErrAccountDeletionFailed
 	}
 
-	// Inform user account is scheduled for deletion
-	mg := mailgun.NewMailgun(s.cnf.Mailgun.Domain, s.cnf.Mailgun.Key)
-	sender := s.cnf.Mailgun.Sender
-	body := ""
+	// Inform user account is scheduled for deletion,
+	// the email contains a link to cancel the deletion
 	email := model.NewOauthEmail(
 		user.Username,
 		"Account deleted",
 		"account-deleted",
 	)
-	subject := email.Subject
-	recipient := email.Recipient
-	message := mg.NewMessage(sender, subject, body, recipient)
-	message.SetTemplate(email.Template) // set mailgun template
-	err = message.AddTemplateVariable("email", recipient)
 
+	rlm, err := s.userRealm(user)
 	if err != nil {
-		log.ERROR.Print(err)
+		logger.ERROR.Print(err)
+		return nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
-
-	// Send the message with a 10 second timeout
-	_, _, err = mg.Send(ctx, message)
+	_, err = s.sendEmailTokenWithLifetime(
+		db,
+		email,
+		rlm.URL(s.cnf, "/web/account-deletion/cancel"),
+		time.Duration(s.cnf.AccountDeletion.GracePeriod)*24*time.Hour,
+	)
 
 	if err != nil {
-		log.ERROR.Print(err)
+		logger.ERROR.Print(err)
 	}
 
 	return nil
@@ -317,20 +611,11 @@ func (s *Service) updateUserCommon(db *bun.DB, user *model.User, fullName, first
 func (s *Service) setUserCountryCommon(db *bun.DB, user *model.User, country string) error {
 	ctx := context.Background()
 
-	// validate country code
-	query := gountries.New()
-	gountry, err := query.FindCountryByAlpha(strings.ToLower(country))
-
+	countryCode, err := findCountryCode(country)
 	if err != nil {
-		// fallback to name
-		gountry, err = query.FindCountryByName(strings.ToLower(country))
-		if err != nil {
-			return ErrCountryNotFound
-		}
+		return err
 	}
 
-	countryCode := gountry.Codes.Alpha2
-
 	_, err = db.NewUpdate().
 		Model(user).
 		Set("country = ?", countryCode).
@@ -341,8 +626,8 @@ func (s *Service) setUserCountryCommon(db *bun.DB, user *model.User, country str
 }
 
 // updateUsernameCommon ...
-func (s *Service) updateUsernameCommon(db *bun.DB, user *model.User, username, password string) error {
-	ctx := context.Background()
+func (s *Service) updateUsernameCommon(ctx context.Context, db *bun.DB, user *model.User, username, password string) error {
+	logger := log.FromContext(ctx)
 
 	if username == "" {
 		return ErrCannotSetEmptyUsername
@@ -353,22 +638,16 @@ func (s *Service) updateUsernameCommon(db *bun.DB, user *model.User, username, p
 		return ErrUsernameTaken
 	}
 
-	// Check that the password is set
-	if !user.Password.Valid {
-		return ErrUserPasswordNotSet
-	}
-
 	// Verify the password
-	if pass.VerifyPassword(user.Password.String, password) != nil {
-		return ErrInvalidUserPassword
+	if err := s.checkPassword(ctx, user, password); err != nil {
+		return err
 	}
 
-	_, err := db.NewUpdate().
-		Model(user).
-		Set("username = ?", strings.ToLower(username)).
-		Set("email_confirmed = ?", false).
-		WherePK().
-		Exec(ctx)
+	previousUsername := user.Username
+
+	if err := s.changeUsername(ctx, db, user, username, false); err != nil {
+		return err
+	}
 
 	// sends email with token for verification
 	email := model.NewOauthEmail(
@@ -377,46 +656,115 @@ func (s *Service) updateUsernameCommon(db *bun.DB, user *model.User, username, p
 		"email-change-confirmation",
 	)
 
+	rlm, err := s.userRealm(user)
+	if err != nil {
+		return err
+	}
+
 	_, err = s.SendEmailToken(
 		email,
-		fmt.Sprintf(
-			"https://%s/email-confirmation",
-			s.cnf.Hostname,
-		),
+		rlm.URL(s.cnf, "/email-confirmation"),
 	)
 
 	if err != nil {
-		log.ERROR.Print(err)
+		logger.ERROR.Print(err)
 	}
 
 	// notify current email address
-	mg := mailgun.NewMailgun(s.cnf.Mailgun.Domain, s.cnf.Mailgun.Key)
-	sender := s.cnf.Mailgun.Sender
+	mg := tracing.NewMailgun(s.cnf.Mailgun.Domain, s.cnf.Mailgun.Key)
+	sender := rlm.Sender(s.cnf)
 	body := ""
 	email = model.NewOauthEmail(
-		user.Username,
+		previousUsername,
 		"Email change notification",
 		"email-change-notification",
 	)
 	subject := email.Subject
 	recipient := email.Recipient
 	message := mg.NewMessage(sender, subject, body, recipient)
-	message.SetTemplate(email.Template) // set mailgun template
+	message.SetTemplate(rlm.Template(email.Template)) // set mailgun template
 	err = message.AddTemplateVariable("email", recipient)
 
 	if err != nil {
-		log.ERROR.Print(err)
+		logger.ERROR.Print(err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
 
 	// Send the message with a 10 second timeout
 	_, _, err = mg.Send(ctx, message)
+	metrics.ObserveEmail(email.Template, err)
+
+	if err != nil {
+		logger.ERROR.Print(err)
+	}
+
+	return nil
+}
+
+// findCountryCode resolves the alpha2 code of a country from its alpha2 or
+// alpha3 code, falling back to its common name
+func findCountryCode(country string) (string, error) {
+	query := gountries.New()
+	gountry, err := query.FindCountryByAlpha(strings.ToLower(country))
+
+	if err != nil {
+		// fallback to name
+		gountry, err = query.FindCountryByName(strings.ToLower(country))
+		if err != nil {
+			return "", ErrCountryNotFound
+		}
+	}
+
+	return gountry.Codes.Alpha2, nil
+}
+
+func containsRoleID(roleIDs []int32, roleID int32) bool {
+	for _, id := range roleIDs {
+		if id == roleID {
+			return true
+		}
+	}
+	return false
+}
+
+// changeUsername updates the username of a user and lets subscribers know
+func (s *Service) changeUsername(ctx context.Context, db *bun.DB, user *model.User, username string, confirmed bool) error {
+	username = strings.ToLower(username)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
 
+	_, err = tx.NewUpdate().
+		Model(user).
+		Set("username = ?", username).
+		Set("email_confirmed = ?", confirmed).
+		Set("updated_at = ?", time.Now().UTC()).
+		WherePK().
+		Exec(ctx)
 	if err != nil {
-		log.ERROR.Print(err)
+		tx.Rollback() // rollback the transaction
+		return err
 	}
 
+	_, err = s.events.Emit(ctx, tx, events.UserEmailChanged, user.ID, map[string]interface{}{
+		"username":          username,
+		"previous_username": user.Username,
+	})
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	user.Username = username
+	user.EmailConfirmed = confirmed
+
 	return nil
 }
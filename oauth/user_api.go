@@ -0,0 +1,207 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/log"
+	"github.com/resonatecoop/user-api-client/client/users"
+	"github.com/resonatecoop/user-api-client/models"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+// Changes propagated to the user api
+const (
+	// UserAPICreate lets the user api know about a new user
+	UserAPICreate = "create"
+	// UserAPIDelete lets the user api know an account is gone
+	UserAPIDelete = "delete"
+)
+
+// maxUserAPIBackoff bounds the delay between two attempts to deliver a change
+const maxUserAPIBackoff = 6 * time.Hour
+
+// UserAPIChange is a change waiting to be delivered to the user api. Changes
+// are stored in the transaction making them and delivered by SyncUserAPI, so
+// the user api being down never fails a sign up or an account deletion.
+type UserAPIChange struct {
+	bun.BaseModel `bun:"table:user_api_changes"`
+
+	ID            uuid.UUID `bun:",pk,type:uuid,nullzero,default:uuid_generate_v4()"`
+	UserID        uuid.UUID `bun:"type:uuid,notnull"`
+	Action        string    `bun:",notnull"`
+	Attempts      int       `bun:",notnull"`
+	LastError     string    `bun:",notnull"`
+	NextAttemptAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// SyncUserAPI delivers up to batchSize changes due to the user api, oldest
+// first. Failed deliveries are retried with an exponential backoff until
+// cnf.UserAPI.SyncMaxAttempts is reached, the change is then kept for
+// inspection. It returns the number of delivered changes.
+func (s *Service) SyncUserAPI(batchSize int) (int, error) {
+	ctx := context.Background()
+
+	changes := []*UserAPIChange{}
+	err := s.db.NewSelect().
+		Model(&changes).
+		Where("next_attempt_at <= ?", time.Now().UTC()).
+		Where("attempts < ?", s.cnf.UserAPI.SyncMaxAttempts).
+		Order("created_at ASC").
+		Limit(batchSize).
+		Scan(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(changes) == 0 {
+		return 0, nil
+	}
+
+	client, err := config.NewAPIClient(s.cnf)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+
+	for _, change := range changes {
+		if err = s.deliverUserAPIChange(ctx, client.Users, change); err != nil {
+			if err = s.retryUserAPIChange(ctx, change, err); err != nil {
+				return delivered, err
+			}
+			continue
+		}
+
+		_, err = s.db.NewDelete().
+			Model(change).
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return delivered, err
+		}
+
+		delivered++
+	}
+
+	return delivered, nil
+}
+
+// CountPendingUserAPIChanges returns the number of changes not delivered to
+// the user api yet, along with the number of changes given up on
+func (s *Service) CountPendingUserAPIChanges() (int, int, error) {
+	ctx := context.Background()
+
+	pending, err := s.db.NewSelect().
+		Model((*UserAPIChange)(nil)).
+		Where("attempts < ?", s.cnf.UserAPI.SyncMaxAttempts).
+		Count(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	failed, err := s.db.NewSelect().
+		Model((*UserAPIChange)(nil)).
+		Where("attempts >= ?", s.cnf.UserAPI.SyncMaxAttempts).
+		Count(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return pending, failed, nil
+}
+
+// enqueueUserAPIChange stores a change for the user api, db is usually the
+// transaction making the change
+func (s *Service) enqueueUserAPIChange(ctx context.Context, db bun.IDB, userID uuid.UUID, action string) error {
+	_, err := db.NewInsert().
+		Model(&UserAPIChange{UserID: userID, Action: action}).
+		Exec(ctx)
+
+	return err
+}
+
+// retryUserAPIChange records a failed delivery and schedules the next one
+func (s *Service) retryUserAPIChange(ctx context.Context, change *UserAPIChange, cause error) error {
+	change.Attempts++
+
+	if change.Attempts >= s.cnf.UserAPI.SyncMaxAttempts {
+		log.ERROR.Printf("Giving up on user api %s change of user %s: %v", change.Action, change.UserID, cause)
+	}
+
+	backoff := time.Duration(s.cnf.UserAPI.SyncInterval) * time.Second
+	for i := 1; i < change.Attempts && backoff < maxUserAPIBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxUserAPIBackoff {
+		backoff = maxUserAPIBackoff
+	}
+
+	_, err := s.db.NewUpdate().
+		Model(change).
+		Set("attempts = ?", change.Attempts).
+		Set("last_error = ?", cause.Error()).
+		Set("next_attempt_at = ?", time.Now().UTC().Add(backoff)).
+		WherePK().
+		Exec(ctx)
+
+	return err
+}
+
+// deliverUserAPIChange sends a single change to the user api
+func (s *Service) deliverUserAPIChange(ctx context.Context, client users.ClientService, change *UserAPIChange) error {
+	switch change.Action {
+	case UserAPIDelete:
+		params := users.NewResonateUserDeleteUserParamsWithContext(ctx)
+		params.WithID(change.UserID.String())
+
+		_, err := client.ResonateUserDeleteUser(params, nil)
+		if casted, ok := err.(*users.ResonateUserDeleteUserDefault); ok && casted.Payload != nil {
+			return errors.New(casted.Payload.Message)
+		}
+		return err
+	case UserAPICreate:
+		user := new(model.User)
+		err := s.db.NewSelect().
+			Model(user).
+			Where("id = ?", change.UserID).
+			Scan(ctx)
+		if err == sql.ErrNoRows {
+			// the account is gone already, its deletion follows
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		params := users.NewResonateUserUpdateUserRestrictedParamsWithContext(ctx)
+		params.WithID(user.ID.String())
+		params.Body = &models.UserUserUpdateRestrictedRequest{
+			ID:                     user.ID.String(),
+			Username:               user.Username,
+			FullName:               user.FullName,
+			FirstName:              user.FirstName,
+			LastName:               user.LastName,
+			Member:                 user.Member,
+			NewsletterNotification: user.NewsletterNotification,
+			RoleID:                 user.RoleID,
+			TenantID:               user.TenantID,
+		}
+
+		_, err = client.ResonateUserUpdateUserRestricted(params, nil)
+		if casted, ok := err.(*users.ResonateUserUpdateUserRestrictedDefault); ok && casted.Payload != nil {
+			return errors.New(casted.Payload.Message)
+		}
+		return err
+	default:
+		// nothing to deliver, the change is dropped
+		log.ERROR.Printf("Unknown user api change %q", change.Action)
+		return nil
+	}
+}
@@ -0,0 +1,94 @@
+package oauth_test
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *OauthTestSuite) TestSyncUserAPI() {
+	ctx := context.Background()
+
+	up := false
+	deleted := []string{}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"code": 14, "message": "unavailable"}`))
+			return
+		}
+		if r.Method == http.MethodDelete {
+			deleted = append(deleted, r.URL.Path)
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+
+	userAPI, hostname, port := suite.cnf.UserAPI, suite.cnf.UserAPIHostname, suite.cnf.UserAPIPort
+	defer func() {
+		suite.cnf.UserAPI, suite.cnf.UserAPIHostname, suite.cnf.UserAPIPort = userAPI, hostname, port
+	}()
+
+	suite.cnf.UserAPIHostname = u.Hostname()
+	suite.cnf.UserAPIPort = ":" + u.Port()
+	suite.cnf.UserAPI.CACertificate = string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	}))
+
+	change := &oauth.UserAPIChange{UserID: uuid.New(), Action: oauth.UserAPIDelete}
+	_, err = suite.db.NewInsert().Model(change).Returning("*").Exec(ctx)
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	defer suite.db.NewDelete().Model(change).WherePK().Exec(ctx)
+
+	// Failed deliveries are retried later
+	delivered, err := suite.service.SyncUserAPI(10)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 0, delivered)
+
+	err = suite.db.NewSelect().Model(change).WherePK().Scan(ctx)
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), 1, change.Attempts)
+		assert.Equal(suite.T(), "unavailable", change.LastError)
+		assert.True(suite.T(), change.NextAttemptAt.After(time.Now()))
+	}
+
+	// Nothing is due until then
+	up = true
+	delivered, err = suite.service.SyncUserAPI(10)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 0, delivered)
+
+	_, err = suite.db.NewUpdate().
+		Model(change).
+		Set("next_attempt_at = ?", time.Now().UTC()).
+		WherePK().
+		Exec(ctx)
+	assert.Nil(suite.T(), err)
+
+	delivered, err = suite.service.SyncUserAPI(10)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, delivered)
+	assert.Equal(suite.T(), []string{"/api/v1/restricted/user/" + change.UserID.String()}, deleted)
+
+	// Delivered changes are gone
+	exists, err := suite.db.NewSelect().Model(change).WherePK().Exists(ctx)
+	assert.Nil(suite.T(), err)
+	assert.False(suite.T(), exists)
+}
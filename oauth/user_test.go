@@ -3,12 +3,16 @@ package oauth_test
 import (
 	"context"
 	"database/sql"
+	"errors"
 
 	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/rbac"
+	"github.com/resonatecoop/id/realm"
 	"github.com/resonatecoop/id/util"
 	pass "github.com/resonatecoop/id/util/password"
 	"github.com/resonatecoop/user-api/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
 )
 
 func (suite *OauthTestSuite) TestUserExistsFindsValidUser() {
@@ -145,18 +149,22 @@ func (suite *OauthTestSuite) TestFindUserByUsername() {
 	}
 }
 
-/*
 func (suite *OauthTestSuite) TestCreateUser() {
 	var (
 		user *model.User
 		err  error
 	)
 
+	ctx := context.Background()
+	rlm := realm.Default(suite.cnf)
+
 	// We try to insert a non unique user
 	user, err = suite.service.CreateUser(
-		int32(model.UserRole),  // role ID
+		rlm,
 		"test@user.com",        // username
 		"C0mpl3xPa$$w0rdAr3U5", // password
+		"",                     // country
+		nil,                    // role IDs
 	)
 
 	// User object should be nil
@@ -167,37 +175,83 @@ func (suite *OauthTestSuite) TestCreateUser() {
 		assert.Equal(suite.T(), oauth.ErrUsernameTaken.Error(), err.Error())
 	}
 
-	// We try to insert a unique user
+	// Countries must exist
+	_, err = suite.service.CreateUser(rlm, "test@newuser.com", "C0mpl3xPa$$w0rdAr3U5", "Atlantis", nil)
+	assert.Equal(suite.T(), oauth.ErrCountryNotFound, err)
+
+	// Test username case insensitivity, artists may also be labels
 	user, err = suite.service.CreateUser(
-		int32(model.UserRole),  // role ID
-		"test@newuser.com",     // username
+		rlm,
+		"TeStinG@hOtMaIl.com",  // username
 		"C0mpl3xPa$$w0rdAr3U5", // password
+		"Germany",              // country
+		[]int32{int32(model.ArtistRole), int32(model.LabelRole)}, // role IDs
 	)
 
 	// Error should be nil
-	assert.Nil(suite.T(), err)
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	defer suite.db.NewDelete().Model(user).WherePK().ForceDelete().Exec(ctx)
+	for _, m := range []interface{}{(*model.Credit)(nil), (*rbac.UserRole)(nil), (*rbac.Assignment)(nil), (*oauth.UserAPIChange)(nil)} {
+		defer suite.db.NewDelete().Model(m).Where("user_id = ?", user.ID).ForceDelete().Exec(ctx)
+	}
 
 	// Correct user object should be returned
-	if assert.NotNil(suite.T(), user) {
-		assert.Equal(suite.T(), "test@newuser.com", user.Username)
+	assert.Equal(suite.T(), "testing@hotmail.com", user.Username)
+	assert.Equal(suite.T(), "DE", user.Country)
+	assert.Equal(suite.T(), int32(model.LabelRole), user.RoleID)
+
+	// The user may log in right away
+	_, err = suite.service.AuthUser(context.Background(), "testing@hotmail.com", "C0mpl3xPa$$w0rdAr3U5")
+	assert.Nil(suite.T(), err)
+
+	roleIDs, err := suite.service.GetRBACService().UserRoleIDs(ctx, user)
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), []int32{int32(model.LabelRole), int32(model.ArtistRole)}, roleIDs)
 	}
 
-	// Test username case insensitivity
-	user, err = suite.service.CreateUser(
-		int32(model.UserRole),  // role ID
-		"TeStinG@hOtMaIl.com",  // username
-		"C0mpl3xPa$$w0rdAr3U5", // password
-	)
+	// Every new user gets a credit wallet
+	credit := new(model.Credit)
+	err = suite.db.NewSelect().Model(credit).Where("user_id = ?", user.ID).Scan(ctx)
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), int64(128), credit.Total)
+	}
 
-	// Error should be nil
-	assert.Nil(suite.T(), err)
+	// The user api hears about it later
+	change := new(oauth.UserAPIChange)
+	err = suite.db.NewSelect().Model(change).Where("user_id = ?", user.ID).Scan(ctx)
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), oauth.UserAPICreate, change.Action)
+	}
+}
 
-	// Correct user object should be returned
-	if assert.NotNil(suite.T(), user) {
-		assert.Equal(suite.T(), "testing@hotmail.com", user.Username)
+func (suite *OauthTestSuite) TestCreateConfirmedUser() {
+	ctx := context.Background()
+	rlm := realm.Default(suite.cnf)
+
+	// A failing setup leaves nothing behind
+	_, err := suite.service.CreateConfirmedUser(ctx, rlm, "test@confirmed.com", "C0mpl3xPa$$w0rdAr3U5", "", nil, func(ctx context.Context, tx bun.Tx, user *model.User) error {
+		return errors.New("directory unavailable")
+	})
+	assert.EqualError(suite.T(), err, "directory unavailable")
+	assert.False(suite.T(), suite.service.UserExists("test@confirmed.com"))
+
+	user, err := suite.service.CreateConfirmedUser(ctx, rlm, "test@confirmed.com", "C0mpl3xPa$$w0rdAr3U5", "", nil, func(ctx context.Context, tx bun.Tx, user *model.User) error {
+		_, err := tx.NewUpdate().Model(user).Set("full_name = ?", "Test User").WherePK().Exec(ctx)
+		return err
+	})
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	assert.True(suite.T(), user.EmailConfirmed)
+
+	user, err = suite.service.FindUserByUsername("test@confirmed.com")
+	if assert.Nil(suite.T(), err) {
+		assert.True(suite.T(), user.EmailConfirmed)
+		assert.Equal(suite.T(), "Test User", user.FullName)
 	}
 }
-*/
 
 func (suite *OauthTestSuite) TestSetPassword() {
 	var (
@@ -260,7 +314,7 @@ func (suite *OauthTestSuite) TestAuthUser() {
 	assert.Nil(suite.T(), err)
 
 	// When we try to authenticate a user without a password
-	user, err = suite.service.AuthUser("test@user_nopass", "bogus")
+	user, err = suite.service.AuthUser(context.Background(), "test@user_nopass", "bogus")
 
 	// User object should be nil
 	assert.Nil(suite.T(), user)
@@ -271,7 +325,7 @@ func (suite *OauthTestSuite) TestAuthUser() {
 	}
 
 	// When we try to authenticate with a bogus username
-	user, err = suite.service.AuthUser("bogus", "test_password")
+	user, err = suite.service.AuthUser(context.Background(), "bogus", "test_password")
 
 	// User object should be nil
 	assert.Nil(suite.T(), user)
@@ -297,7 +351,7 @@ func (suite *OauthTestSuite) TestAuthUser() {
 	assert.Nil(suite.T(), err)
 
 	// When we try to authenticate with an invalid password
-	user, err = suite.service.AuthUser("test@user", "bogus")
+	user, err = suite.service.AuthUser(context.Background(), "test@user", "bogus")
 
 	// User object should be nil
 	assert.Nil(suite.T(), user)
@@ -308,7 +362,7 @@ func (suite *OauthTestSuite) TestAuthUser() {
 	}
 
 	// When we try to authenticate with valid username and password
-	user, err = suite.service.AuthUser("test@user", "test_password")
+	user, err = suite.service.AuthUser(context.Background(), "test@user", "test_password")
 
 	// Error should be nil
 	assert.Nil(suite.T(), err)
@@ -319,7 +373,7 @@ func (suite *OauthTestSuite) TestAuthUser() {
 	}
 
 	// Test username case insensitivity
-	user, err = suite.service.AuthUser("TeSt@UsEr", "test_password")
+	user, err = suite.service.AuthUser(context.Background(), "TeSt@UsEr", "test_password")
 
 	// Error should be nil
 	assert.Nil(suite.T(), err)
@@ -398,7 +452,7 @@ func (suite *OauthTestSuite) TestDeleteUser() {
 	}
 
 	// Delete the user
-	err = suite.service.DeleteUser(user, "C0mpl3xPa$$w0rdAr3U5")
+	err = suite.service.DeleteUser(context.Background(), user, "C0mpl3xPa$$w0rdAr3U5")
 
 	// Error should be nil
 	assert.Nil(suite.T(), err)
@@ -410,3 +464,77 @@ func (suite *OauthTestSuite) TestDeleteUser() {
 	assert.False(suite.T(), exists)
 }
 */
+
+func (suite *OauthTestSuite) TestSetUserRole() {
+	ctx := context.Background()
+
+	user := &model.User{
+		RoleID:   int32(model.UserRole),
+		Username: "test@user_role.com",
+	}
+
+	_, err := suite.db.NewInsert().
+		Model(user).
+		Exec(ctx)
+	assert.Nil(suite.T(), err)
+
+	// Unknown roles are refused
+	err = suite.service.SetUserRole(user, 42, suite.users[0].ID)
+	assert.Equal(suite.T(), oauth.ErrRoleNotFound, err)
+
+	err = suite.service.SetUserRole(user, int32(model.ArtistRole), suite.users[0].ID)
+	assert.Nil(suite.T(), err)
+
+	user, err = suite.service.FindUserByUsername("test@user_role.com")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), int32(model.ArtistRole), user.RoleID)
+
+	// The change is recorded in the role assignment history
+	history, err := suite.service.GetRBACService().History(ctx, user)
+	assert.Nil(suite.T(), err)
+	actions := map[string]string{}
+	for _, assignment := range history {
+		actions[assignment.RoleName] = assignment.Action
+		assert.Equal(suite.T(), suite.users[0].ID, assignment.ActorID)
+	}
+	assert.Equal(suite.T(), map[string]string{"artist": "grant", "user": "revoke"}, actions)
+}
+
+func (suite *OauthTestSuite) TestLockUser() {
+	ctx := context.Background()
+
+	passwordHash, err := pass.HashPassword("C0mpl3xPa$$w0rdAr3U5")
+	assert.Nil(suite.T(), err)
+
+	user := &model.User{
+		RoleID:   int32(model.UserRole),
+		Username: "test@user_lock.com",
+		Password: util.StringOrNull(string(passwordHash)),
+	}
+
+	_, err = suite.db.NewInsert().
+		Model(user).
+		Exec(ctx)
+	assert.Nil(suite.T(), err)
+
+	accessToken, err := suite.service.GrantAccessToken(suite.clients[0], user, 3600, "read_write")
+	assert.Nil(suite.T(), err)
+
+	err = suite.service.LockUser(user)
+	assert.Nil(suite.T(), err)
+
+	// The user can no longer log in
+	_, err = suite.service.AuthUser(context.Background(), "test@user_lock.com", "C0mpl3xPa$$w0rdAr3U5")
+	assert.Equal(suite.T(), oauth.ErrUserPasswordNotSet, err)
+
+	// Tokens have been revoked
+	_, err = suite.service.Authenticate(accessToken.Token)
+	assert.Equal(suite.T(), oauth.ErrAccessTokenNotFound, err)
+
+	// Setting a new password unlocks the account
+	err = suite.service.SetPassword(user, "C0mpl3xPa$$w0rdAr3U6")
+	assert.Nil(suite.T(), err)
+
+	_, err = suite.service.AuthUser(context.Background(), "test@user_lock.com", "C0mpl3xPa$$w0rdAr3U6")
+	assert.Nil(suite.T(), err)
+}
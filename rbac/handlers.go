@@ -0,0 +1,373 @@
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/id/util/response"
+	"github.com/resonatecoop/user-api/model"
+)
+
+var (
+	// ErrForbidden ...
+	ErrForbidden = errors.New("Not allowed to manage roles")
+	// ErrUnauthorized ...
+	ErrUnauthorized = errors.New("Invalid or missing access token")
+	// ErrUserNotFound ...
+	ErrUserNotFound = errors.New("User not found")
+)
+
+// roleRequest is the body of role creation and update requests
+type roleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// permissionRequest is the body of a permission creation request
+type permissionRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// userRolesResponse describes the roles and effective permissions of a user
+type userRolesResponse struct {
+	UserID      string   `json:"user_id"`
+	Username    string   `json:"username"`
+	RoleID      int32    `json:"role_id"`
+	Roles       []*Role  `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// Lists roles (GET /v1/rbac/roles)
+func (s *Service) listRolesHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.authorize(r, PermissionRolesRead); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	roles, err := s.ListRoles(r.Context())
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, map[string]interface{}{"roles": roles}, http.StatusOK)
+}
+
+// Creates a role (POST /v1/rbac/roles)
+func (s *Service) createRoleHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.authorize(r, PermissionRolesWrite); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	req := new(roleRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	role, err := s.CreateRole(r.Context(), req.Name, req.Description, req.Permissions)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, role, http.StatusCreated)
+}
+
+// Returns a role (GET /v1/rbac/roles/{role})
+func (s *Service) getRoleHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.authorize(r, PermissionRolesRead); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	role, err := s.FindRoleByName(r.Context(), mux.Vars(r)["role"])
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, role, http.StatusOK)
+}
+
+// Updates the description and permissions of a role (PUT /v1/rbac/roles/{role}),
+// only super admins may change the privileged roles
+func (s *Service) updateRoleHandler(w http.ResponseWriter, r *http.Request) {
+	actor, err := s.authorize(r, PermissionRolesWrite)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	role, err := s.FindRoleByName(r.Context(), mux.Vars(r)["role"])
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	if err := s.checkPrivileged(r, actor, role); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	req := new(roleRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.UpdateRole(r.Context(), role, req.Description, req.Permissions); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, role, http.StatusOK)
+}
+
+// Deletes a custom role (DELETE /v1/rbac/roles/{role})
+func (s *Service) deleteRoleHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.authorize(r, PermissionRolesWrite); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	role, err := s.FindRoleByName(r.Context(), mux.Vars(r)["role"])
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	if err := s.DeleteRole(r.Context(), role); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// Lists permissions (GET /v1/rbac/permissions)
+func (s *Service) listPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.authorize(r, PermissionRolesRead); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	permissions, err := s.ListPermissions(r.Context())
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, map[string]interface{}{"permissions": permissions}, http.StatusOK)
+}
+
+// Creates a permission (POST /v1/rbac/permissions)
+func (s *Service) createPermissionHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.authorize(r, PermissionRolesWrite); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	req := new(permissionRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	permission, err := s.CreatePermission(r.Context(), req.Name, req.Description)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, permission, http.StatusCreated)
+}
+
+// Deletes a permission (DELETE /v1/rbac/permissions/{permission})
+func (s *Service) deletePermissionHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.authorize(r, PermissionRolesWrite); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	if err := s.DeletePermission(r.Context(), mux.Vars(r)["permission"]); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// Lists the roles and effective permissions of a user
+// (GET /v1/rbac/users/{username}/roles)
+func (s *Service) listUserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.authorize(r, PermissionRolesRead); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	user, err := s.backend.FindUserByUsername(mux.Vars(r)["username"])
+	if err != nil {
+		s.writeError(w, ErrUserNotFound)
+		return
+	}
+
+	s.writeUserRoles(w, r, user)
+}
+
+// Lists the role assignments of a user (GET /v1/rbac/users/{username}/roles/history)
+func (s *Service) userRoleHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.authorize(r, PermissionRolesRead); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	user, err := s.backend.FindUserByUsername(mux.Vars(r)["username"])
+	if err != nil {
+		s.writeError(w, ErrUserNotFound)
+		return
+	}
+
+	history, err := s.History(r.Context(), user)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, map[string]interface{}{"history": history}, http.StatusOK)
+}
+
+// Grants a role to a user (PUT /v1/rbac/users/{username}/roles/{role})
+func (s *Service) assignUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	s.changeUserRole(w, r, s.AssignRole)
+}
+
+// Revokes a role from a user (DELETE /v1/rbac/users/{username}/roles/{role})
+func (s *Service) revokeUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	s.changeUserRole(w, r, s.RevokeRole)
+}
+
+// changeUserRole authorizes a role change and responds with the roles of the
+// user once change is applied
+func (s *Service) changeUserRole(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, user *model.User, role *Role, actorID uuid.UUID) error) {
+	actor, err := s.authorize(r, PermissionRolesAssign)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	role, err := s.FindRoleByName(r.Context(), mux.Vars(r)["role"])
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	if err := s.checkPrivileged(r, actor, role); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	user, err := s.backend.FindUserByUsername(mux.Vars(r)["username"])
+	if err != nil {
+		s.writeError(w, ErrUserNotFound)
+		return
+	}
+
+	if err := change(r.Context(), user, role, actor.ID); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	s.writeUserRoles(w, r, user)
+}
+
+func (s *Service) writeUserRoles(w http.ResponseWriter, r *http.Request, user *model.User) {
+	roles, err := s.UserRoles(r.Context(), user)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	permissions, err := s.UserPermissions(r.Context(), user)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, &userRolesResponse{
+		UserID:      user.ID.String(),
+		Username:    user.Username,
+		RoleID:      user.RoleID,
+		Roles:       roles,
+		Permissions: permissions,
+	}, http.StatusOK)
+}
+
+// authorize authenticates the bearer token of the request as an admin token
+// and returns its user if one of the user's roles grants permission
+func (s *Service) authorize(r *http.Request, permission string) (*model.User, error) {
+	token, err := util.ParseBearerToken(r)
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+
+	user, err := s.backend.AuthenticateAdmin(string(token))
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+
+	allowed, err := s.HasPermission(r.Context(), user, permission)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrForbidden
+	}
+
+	return user, nil
+}
+
+// checkPrivileged makes sure only super admins grant, revoke or change the
+// privileged roles
+func (s *Service) checkPrivileged(r *http.Request, actor *model.User, role *Role) error {
+	if !IsPrivileged(role.ID) {
+		return nil
+	}
+
+	roleIDs, err := s.UserRoleIDs(r.Context(), actor)
+	if err != nil {
+		return err
+	}
+
+	if !containsRole(roleIDs, int32(model.SuperAdminRole)) {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// writeError maps rbac errors to status codes
+func (s *Service) writeError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrRoleNotFound, ErrPermissionNotFound, ErrUserNotFound:
+		response.Error(w, err.Error(), http.StatusNotFound)
+	case ErrForbidden:
+		response.Error(w, err.Error(), http.StatusForbidden)
+	case ErrRoleTaken, ErrPermissionTaken, ErrBuiltinRole:
+		response.Error(w, err.Error(), http.StatusConflict)
+	case ErrInvalidRoleName, ErrInvalidPermission, ErrRoleNotHeld:
+		response.Error(w, err.Error(), http.StatusBadRequest)
+	case ErrUnauthorized:
+		response.UnauthorizedError(w, err.Error())
+	default:
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
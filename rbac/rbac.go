@@ -0,0 +1,103 @@
+package rbac
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+// Permissions granted to the default roles, see database/fixtures/default.yml
+const (
+	// PermissionRolesRead allows listing roles, permissions and the roles of users
+	PermissionRolesRead = "roles:read"
+	// PermissionRolesWrite allows creating, changing and deleting roles and permissions
+	PermissionRolesWrite = "roles:write"
+	// PermissionRolesAssign allows granting and revoking the roles of users
+	PermissionRolesAssign = "roles:assign"
+	// PermissionUsersImpersonate allows acting on behalf of other users
+	PermissionUsersImpersonate = "users:impersonate"
+	// PermissionWebhooksManage allows managing the webhooks other services
+	// are notified of changes to users through
+	PermissionWebhooksManage = "webhooks:manage"
+)
+
+const (
+	// ActionGrant is recorded when a role is granted to a user
+	ActionGrant = "grant"
+	// ActionRevoke is recorded when a role is revoked from a user
+	ActionRevoke = "revoke"
+)
+
+// Permission is something a role allows its users to do, permissions are
+// namespaced like scopes, e.g. tracks:upload
+type Permission struct {
+	bun.BaseModel `bun:"table:permissions"`
+
+	Name        string    `bun:",pk" json:"name"`
+	Description string    `bun:",notnull" json:"description"`
+	CreatedAt   time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// RolePermission grants a permission to a role
+type RolePermission struct {
+	bun.BaseModel `bun:"table:role_permissions"`
+
+	RoleID     int32  `bun:",pk"`
+	Permission string `bun:",pk"`
+}
+
+// UserRole grants a role to a user, a user can hold several roles
+type UserRole struct {
+	bun.BaseModel `bun:"table:user_roles"`
+
+	UserID    uuid.UUID `bun:"type:uuid,pk"`
+	RoleID    int32     `bun:",pk"`
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// Assignment records a role being granted to or revoked from a user
+type Assignment struct {
+	bun.BaseModel `bun:"table:role_assignments"`
+
+	ID        uuid.UUID `bun:",pk,type:uuid,nullzero,default:uuid_generate_v4()" json:"id"`
+	UserID    uuid.UUID `bun:"type:uuid,notnull" json:"user_id"`
+	RoleID    int32     `bun:",notnull" json:"role_id"`
+	RoleName  string    `bun:",notnull" json:"role"`
+	Action    string    `bun:",notnull" json:"action"`
+	ActorID   uuid.UUID `bun:"type:uuid,nullzero" json:"actor_id,omitempty"`
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// Role is a role along with the permissions it grants
+type Role struct {
+	ID          int32    `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Builtin     bool     `json:"builtin"`
+	Permissions []string `json:"permissions"`
+}
+
+// IsBuiltin returns true for the roles user-api relies on, they cannot be
+// renamed or deleted
+func IsBuiltin(roleID int32) bool {
+	return roleID >= int32(model.SuperAdminRole) && roleID <= int32(model.UserRole)
+}
+
+// IsPrivileged returns true for the roles only super admins may grant
+func IsPrivileged(roleID int32) bool {
+	return roleID == int32(model.SuperAdminRole) || roleID == int32(model.AdminRole)
+}
+
+// PrimaryRoleID returns the role stored on the user record for user-api,
+// the most privileged built-in role held, users without one are users
+func PrimaryRoleID(roleIDs []int32) int32 {
+	primary := int32(model.UserRole)
+	for _, roleID := range roleIDs {
+		if IsBuiltin(roleID) && roleID < primary {
+			primary = roleID
+		}
+	}
+	return primary
+}
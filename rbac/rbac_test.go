@@ -0,0 +1,35 @@
+package rbac_test
+
+import (
+	"testing"
+
+	"github.com/resonatecoop/id/rbac"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsBuiltin(t *testing.T) {
+	assert.True(t, rbac.IsBuiltin(int32(model.SuperAdminRole)))
+	assert.True(t, rbac.IsBuiltin(int32(model.UserRole)))
+	assert.False(t, rbac.IsBuiltin(0))
+	assert.False(t, rbac.IsBuiltin(int32(model.UserRole)+1))
+}
+
+func TestIsPrivileged(t *testing.T) {
+	assert.True(t, rbac.IsPrivileged(int32(model.SuperAdminRole)))
+	assert.True(t, rbac.IsPrivileged(int32(model.AdminRole)))
+	assert.False(t, rbac.IsPrivileged(int32(model.TenantAdminRole)))
+	assert.False(t, rbac.IsPrivileged(int32(model.ArtistRole)))
+}
+
+func TestPrimaryRoleID(t *testing.T) {
+	// users without a role are users
+	assert.Equal(t, int32(model.UserRole), rbac.PrimaryRoleID(nil))
+
+	// artists who are labels too are labels to user-api
+	assert.Equal(t, int32(model.LabelRole), rbac.PrimaryRoleID([]int32{int32(model.ArtistRole), int32(model.LabelRole)}))
+
+	// custom roles are never stored on the user record
+	assert.Equal(t, int32(model.UserRole), rbac.PrimaryRoleID([]int32{7, 8}))
+	assert.Equal(t, int32(model.ArtistRole), rbac.PrimaryRoleID([]int32{7, int32(model.ArtistRole)}))
+}
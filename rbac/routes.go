@@ -0,0 +1,90 @@
+package rbac
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/resonatecoop/id/util/routes"
+)
+
+// RegisterRoutes registers route handlers for the rbac service
+func (s *Service) RegisterRoutes(router *mux.Router, prefix string) {
+	subRouter := router.PathPrefix(prefix).Subrouter()
+	routes.AddRoutes(s.GetRoutes(), subRouter)
+}
+
+// GetRoutes returns []routes.Route slice for the rbac service
+func (s *Service) GetRoutes() []routes.Route {
+	return []routes.Route{
+		{
+			Name:        "rbac_roles_list",
+			Method:      "GET",
+			Pattern:     "/roles",
+			HandlerFunc: s.listRolesHandler,
+		},
+		{
+			Name:        "rbac_roles_create",
+			Method:      "POST",
+			Pattern:     "/roles",
+			HandlerFunc: s.createRoleHandler,
+		},
+		{
+			Name:        "rbac_roles_get",
+			Method:      "GET",
+			Pattern:     "/roles/{role}",
+			HandlerFunc: s.getRoleHandler,
+		},
+		{
+			Name:        "rbac_roles_update",
+			Method:      "PUT",
+			Pattern:     "/roles/{role}",
+			HandlerFunc: s.updateRoleHandler,
+		},
+		{
+			Name:        "rbac_roles_delete",
+			Method:      "DELETE",
+			Pattern:     "/roles/{role}",
+			HandlerFunc: s.deleteRoleHandler,
+		},
+		{
+			Name:        "rbac_permissions_list",
+			Method:      "GET",
+			Pattern:     "/permissions",
+			HandlerFunc: s.listPermissionsHandler,
+		},
+		{
+			Name:        "rbac_permissions_create",
+			Method:      "POST",
+			Pattern:     "/permissions",
+			HandlerFunc: s.createPermissionHandler,
+		},
+		{
+			Name:        "rbac_permissions_delete",
+			Method:      "DELETE",
+			Pattern:     "/permissions/{permission}",
+			HandlerFunc: s.deletePermissionHandler,
+		},
+		{
+			Name:        "rbac_user_roles_list",
+			Method:      "GET",
+			Pattern:     "/users/{username}/roles",
+			HandlerFunc: s.listUserRolesHandler,
+		},
+		{
+			Name:        "rbac_user_roles_history",
+			Method:      "GET",
+			Pattern:     "/users/{username}/roles/history",
+			HandlerFunc: s.userRoleHistoryHandler,
+		},
+		{
+			Name:        "rbac_user_roles_assign",
+			Method:      "PUT",
+			Pattern:     "/users/{username}/roles/{role}",
+			HandlerFunc: s.assignUserRoleHandler,
+		},
+		{
+			Name:        "rbac_user_roles_revoke",
+			Method:      "DELETE",
+			Pattern:     "/users/{username}/roles/{role}",
+			HandlerFunc: s.revokeUserRoleHandler,
+		},
+	}
+}
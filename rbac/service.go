@@ -0,0 +1,381 @@
+package rbac
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"sort"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+var (
+	// ErrRoleNotFound ...
+	ErrRoleNotFound = errors.New("Role not found")
+	// ErrRoleTaken ...
+	ErrRoleTaken = errors.New("Role name taken")
+	// ErrInvalidRoleName ...
+	ErrInvalidRoleName = errors.New("Invalid role name")
+	// ErrBuiltinRole ...
+	ErrBuiltinRole = errors.New("Built-in roles cannot be deleted")
+	// ErrPermissionNotFound ...
+	ErrPermissionNotFound = errors.New("Permission not found")
+	// ErrPermissionTaken ...
+	ErrPermissionTaken = errors.New("Permission name taken")
+	// ErrInvalidPermission ...
+	ErrInvalidPermission = errors.New("Invalid permission name")
+)
+
+var (
+	// validRoleName matches role names, which end up in token scopes
+	validRoleName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+	// validPermission matches permission names, e.g. tracks:upload
+	validPermission = regexp.MustCompile(`^[a-z][a-z0-9_]*(:[a-z0-9_]+)*$`)
+)
+
+// Service struct keeps config and db objects to avoid passing them around
+type Service struct {
+	cnf     *config.Config
+	db      *bun.DB
+	backend Backend
+}
+
+// NewService returns a new Service instance
+func NewService(cnf *config.Config, db *bun.DB) *Service {
+	return &Service{cnf: cnf, db: db}
+}
+
+// GetConfig returns config.Config instance
+func (s *Service) GetConfig() *config.Config {
+	return s.cnf
+}
+
+// UseBackend sets the oauth backend the admin API relies on
+func (s *Service) UseBackend(b Backend) {
+	s.backend = b
+}
+
+// Close stops any running services
+func (s *Service) Close() {}
+
+// ListRoles returns every role with its permissions ordered by ID
+func (s *Service) ListRoles(ctx context.Context) ([]*Role, error) {
+	var roles []*model.Role
+
+	err := s.db.NewSelect().
+		Model(&roles).
+		Order("id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	grants, err := s.rolePermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Role, len(roles))
+	for i, role := range roles {
+		result[i] = newRole(role, grants[role.ID])
+	}
+
+	return result, nil
+}
+
+// FindRoleByID looks up a role by ID
+func (s *Service) FindRoleByID(ctx context.Context, id int32) (*Role, error) {
+	return s.findRole(ctx, "id = ?", id)
+}
+
+// FindRoleByName looks up a role by name
+func (s *Service) FindRoleByName(ctx context.Context, name string) (*Role, error) {
+	return s.findRole(ctx, "name = ?", name)
+}
+
+// CreateRole saves a new role granting permissions
+func (s *Service) CreateRole(ctx context.Context, name, description string, permissions []string) (*Role, error) {
+	if !validRoleName.MatchString(name) {
+		return nil, ErrInvalidRoleName
+	}
+
+	if _, err := s.FindRoleByName(ctx, name); err != ErrRoleNotFound {
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrRoleTaken
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// role ids are not generated by the database
+	var maxID int32
+
+	err = tx.NewSelect().
+		Model((*model.Role)(nil)).
+		ColumnExpr("COALESCE(MAX(id), 0)").
+		Scan(ctx, &maxID)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	if maxID < int32(model.UserRole) {
+		maxID = int32(model.UserRole)
+	}
+
+	role := &model.Role{
+		ID:          maxID + 1,
+		Name:        name,
+		Description: description,
+	}
+
+	if _, err = tx.NewInsert().Model(role).Exec(ctx); err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	if err = setPermissions(ctx, tx, role.ID, permissions); err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.FindRoleByID(ctx, role.ID)
+}
+
+// UpdateRole changes the description and the permissions of a role
+func (s *Service) UpdateRole(ctx context.Context, role *Role, description string, permissions []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NewUpdate().
+		Model((*model.Role)(nil)).
+		Set("description = ?", description).
+		Where("id = ?", role.ID).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	if err = setPermissions(ctx, tx, role.ID, permissions); err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	role.Description = description
+	role.Permissions = sortedUnique(permissions)
+
+	return nil
+}
+
+// DeleteRole removes a custom role, the users holding it lose it
+func (s *Service) DeleteRole(ctx context.Context, role *Role) error {
+	if IsBuiltin(role.ID) {
+		return ErrBuiltinRole
+	}
+
+	_, err := s.db.NewDelete().
+		Model((*model.Role)(nil)).
+		Where("id = ?", role.ID).
+		Exec(ctx)
+
+	return err
+}
+
+// ListPermissions returns every permission ordered by name
+func (s *Service) ListPermissions(ctx context.Context) ([]*Permission, error) {
+	var permissions []*Permission
+
+	err := s.db.NewSelect().
+		Model(&permissions).
+		Order("name ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// CreatePermission saves a new permission
+func (s *Service) CreatePermission(ctx context.Context, name, description string) (*Permission, error) {
+	if !validPermission.MatchString(name) || len(name) > 50 {
+		return nil, ErrInvalidPermission
+	}
+
+	exists, err := s.db.NewSelect().
+		Model((*Permission)(nil)).
+		Where("name = ?", name).
+		Exists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrPermissionTaken
+	}
+
+	permission := &Permission{Name: name, Description: description}
+
+	_, err = s.db.NewInsert().
+		Model(permission).
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return permission, nil
+}
+
+// DeletePermission removes a permission from every role granting it
+func (s *Service) DeletePermission(ctx context.Context, name string) error {
+	res, err := s.db.NewDelete().
+		Model((*Permission)(nil)).
+		Where("name = ?", name).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrPermissionNotFound
+	}
+
+	return nil
+}
+
+func (s *Service) findRole(ctx context.Context, query string, args ...interface{}) (*Role, error) {
+	role := new(model.Role)
+
+	err := s.db.NewSelect().
+		Model(role).
+		Where(query, args...).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, ErrRoleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var permissions []string
+
+	err = s.db.NewSelect().
+		Model((*RolePermission)(nil)).
+		Column("permission").
+		Where("role_id = ?", role.ID).
+		Order("permission ASC").
+		Scan(ctx, &permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRole(role, permissions), nil
+}
+
+// rolePermissions returns the permissions granted by each role
+func (s *Service) rolePermissions(ctx context.Context) (map[int32][]string, error) {
+	var grants []*RolePermission
+
+	err := s.db.NewSelect().
+		Model(&grants).
+		Order("permission ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byRole := make(map[int32][]string)
+	for _, grant := range grants {
+		byRole[grant.RoleID] = append(byRole[grant.RoleID], grant.Permission)
+	}
+
+	return byRole, nil
+}
+
+// setPermissions replaces the permissions granted by a role
+func setPermissions(ctx context.Context, tx bun.Tx, roleID int32, permissions []string) error {
+	permissions = sortedUnique(permissions)
+
+	if len(permissions) > 0 {
+		count, err := tx.NewSelect().
+			Model((*Permission)(nil)).
+			Where("name IN (?)", bun.In(permissions)).
+			Count(ctx)
+		if err != nil {
+			return err
+		}
+		if count != len(permissions) {
+			return ErrInvalidPermission
+		}
+	}
+
+	_, err := tx.NewDelete().
+		Model((*RolePermission)(nil)).
+		Where("role_id = ?", roleID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	rows := make([]*RolePermission, len(permissions))
+	for i, permission := range permissions {
+		rows[i] = &RolePermission{RoleID: roleID, Permission: permission}
+	}
+
+	_, err = tx.NewInsert().
+		Model(&rows).
+		Exec(ctx)
+
+	return err
+}
+
+func newRole(role *model.Role, permissions []string) *Role {
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	return &Role{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Builtin:     IsBuiltin(role.ID),
+		Permissions: permissions,
+	}
+}
+
+// sortedUnique returns values sorted without duplicates
+func sortedUnique(values []string) []string {
+	result := []string{}
+	seen := make(map[string]bool, len(values))
+	for _, value := range values {
+		if value != "" && !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
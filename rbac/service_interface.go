@@ -0,0 +1,44 @@
+package rbac
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/util/routes"
+	"github.com/resonatecoop/user-api/model"
+)
+
+// Backend is the part of the oauth service the RBAC admin API relies on
+type Backend interface {
+	AuthenticateAdmin(token string) (*model.User, error)
+	FindUserByUsername(username string) (*model.User, error)
+}
+
+// ServiceInterface defines exported methods
+type ServiceInterface interface {
+	// Exported methods
+	GetConfig() *config.Config
+	UseBackend(b Backend)
+	ListRoles(ctx context.Context) ([]*Role, error)
+	FindRoleByID(ctx context.Context, id int32) (*Role, error)
+	FindRoleByName(ctx context.Context, name string) (*Role, error)
+	CreateRole(ctx context.Context, name, description string, permissions []string) (*Role, error)
+	UpdateRole(ctx context.Context, role *Role, description string, permissions []string) error
+	DeleteRole(ctx context.Context, role *Role) error
+	ListPermissions(ctx context.Context) ([]*Permission, error)
+	CreatePermission(ctx context.Context, name, description string) (*Permission, error)
+	DeletePermission(ctx context.Context, name string) error
+	UserRoleIDs(ctx context.Context, user *model.User) ([]int32, error)
+	UserRoles(ctx context.Context, user *model.User) ([]*Role, error)
+	UserPermissions(ctx context.Context, user *model.User) ([]string, error)
+	HasPermission(ctx context.Context, user *model.User, permission string) (bool, error)
+	AssignRole(ctx context.Context, user *model.User, role *Role, actorID uuid.UUID) error
+	RevokeRole(ctx context.Context, user *model.User, role *Role, actorID uuid.UUID) error
+	SetRole(ctx context.Context, user *model.User, role *Role, actorID uuid.UUID) error
+	History(ctx context.Context, user *model.User) ([]*Assignment, error)
+	GetRoutes() []routes.Route
+	RegisterRoutes(router *mux.Router, prefix string)
+	Close()
+}
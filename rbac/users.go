@@ -0,0 +1,281 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+var (
+	// ErrRoleNotHeld ...
+	ErrRoleNotHeld = errors.New("User does not hold the role")
+)
+
+// UserRoleIDs returns the IDs of the roles a user holds, sorted. The role
+// stored on the user record is always held, users which were never
+// assigned roles through this service hold only that one.
+func (s *Service) UserRoleIDs(ctx context.Context, user *model.User) ([]int32, error) {
+	return userRoleIDs(ctx, s.db, user)
+}
+
+// UserRoles returns the roles a user holds along with their permissions
+func (s *Service) UserRoles(ctx context.Context, user *model.User) ([]*Role, error) {
+	roleIDs, err := s.UserRoleIDs(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	held := []*Role{}
+	for _, role := range roles {
+		if containsRole(roleIDs, role.ID) {
+			held = append(held, role)
+		}
+	}
+
+	return held, nil
+}
+
+// UserPermissions returns the effective permissions of a user, the union of
+// the permissions of every role held
+func (s *Service) UserPermissions(ctx context.Context, user *model.User) ([]string, error) {
+	roleIDs, err := s.UserRoleIDs(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	var permissions []string
+
+	err = s.db.NewSelect().
+		Model((*RolePermission)(nil)).
+		Column("permission").
+		Where("role_id IN (?)", bun.In(roleIDs)).
+		Scan(ctx, &permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	return sortedUnique(permissions), nil
+}
+
+// HasPermission returns true if one of the roles of a user grants permission
+func (s *Service) HasPermission(ctx context.Context, user *model.User, permission string) (bool, error) {
+	roleIDs, err := s.UserRoleIDs(ctx, user)
+	if err != nil {
+		return false, err
+	}
+
+	return s.db.NewSelect().
+		Model((*RolePermission)(nil)).
+		Where("role_id IN (?)", bun.In(roleIDs)).
+		Where("permission = ?", permission).
+		Exists(ctx)
+}
+
+// AssignRole grants a role to a user on behalf of actorID, uuid.Nil for
+// changes made from the command line or the server itself
+func (s *Service) AssignRole(ctx context.Context, user *model.User, role *Role, actorID uuid.UUID) error {
+	return s.changeRoles(ctx, user, actorID, func(roleIDs []int32) ([]int32, error) {
+		if containsRole(roleIDs, role.ID) {
+			return roleIDs, nil
+		}
+		return append(roleIDs, role.ID), nil
+	})
+}
+
+// RevokeRole revokes a role from a user, users left without a built-in role
+// fall back to the user role
+func (s *Service) RevokeRole(ctx context.Context, user *model.User, role *Role, actorID uuid.UUID) error {
+	return s.changeRoles(ctx, user, actorID, func(roleIDs []int32) ([]int32, error) {
+		if !containsRole(roleIDs, role.ID) {
+			return nil, ErrRoleNotHeld
+		}
+
+		remaining := []int32{}
+		for _, roleID := range roleIDs {
+			if roleID != role.ID {
+				remaining = append(remaining, roleID)
+			}
+		}
+		return remaining, nil
+	})
+}
+
+// SetRole replaces every role of a user with a single role
+func (s *Service) SetRole(ctx context.Context, user *model.User, role *Role, actorID uuid.UUID) error {
+	return s.changeRoles(ctx, user, actorID, func(roleIDs []int32) ([]int32, error) {
+		return []int32{role.ID}, nil
+	})
+}
+
+// History returns the role assignments of a user, most recent first
+func (s *Service) History(ctx context.Context, user *model.User) ([]*Assignment, error) {
+	assignments := []*Assignment{}
+
+	err := s.db.NewSelect().
+		Model(&assignments).
+		Where("user_id = ?", user.ID).
+		Order("created_at DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return assignments, nil
+}
+
+// changeRoles applies change to the roles of a user in a transaction, keeps
+// the role stored on the user record in sync and records what changed
+func (s *Service) changeRoles(ctx context.Context, user *model.User, actorID uuid.UUID, change func([]int32) ([]int32, error)) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	before, err := userRoleIDs(ctx, tx, user)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	after, err := change(append([]int32{}, before...))
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	primary := PrimaryRoleID(after)
+	if !containsRole(after, primary) {
+		after = append(after, primary)
+	}
+
+	if err = s.recordChanges(ctx, tx, user, actorID, before, after); err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	_, err = tx.NewDelete().
+		Model((*UserRole)(nil)).
+		Where("user_id = ?", user.ID).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	rows := make([]*UserRole, len(after))
+	for i, roleID := range after {
+		rows[i] = &UserRole{UserID: user.ID, RoleID: roleID}
+	}
+
+	_, err = tx.NewInsert().
+		Model(&rows).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	if primary != user.RoleID {
+		_, err = tx.NewUpdate().
+			Model(user).
+			Set("role_id = ?", primary).
+			Set("updated_at = ?", time.Now().UTC()).
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			tx.Rollback() // rollback the transaction
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	user.RoleID = primary
+
+	return nil
+}
+
+// recordChanges adds an assignment for every role granted or revoked
+func (s *Service) recordChanges(ctx context.Context, tx bun.Tx, user *model.User, actorID uuid.UUID, before, after []int32) error {
+	var assignments []*Assignment
+
+	for _, roleID := range after {
+		if !containsRole(before, roleID) {
+			assignments = append(assignments, &Assignment{UserID: user.ID, RoleID: roleID, Action: ActionGrant, ActorID: actorID})
+		}
+	}
+	for _, roleID := range before {
+		if !containsRole(after, roleID) {
+			assignments = append(assignments, &Assignment{UserID: user.ID, RoleID: roleID, Action: ActionRevoke, ActorID: actorID})
+		}
+	}
+
+	if len(assignments) == 0 {
+		return nil
+	}
+
+	roles := []*model.Role{}
+	err := tx.NewSelect().
+		Model(&roles).
+		Scan(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, assignment := range assignments {
+		for _, role := range roles {
+			if role.ID == assignment.RoleID {
+				assignment.RoleName = role.Name
+			}
+		}
+	}
+
+	_, err = tx.NewInsert().
+		Model(&assignments).
+		Exec(ctx)
+
+	return err
+}
+
+// userRoleIDs returns the roles of a user along with the one on its record
+func userRoleIDs(ctx context.Context, db bun.IDB, user *model.User) ([]int32, error) {
+	var roleIDs []int32
+
+	err := db.NewSelect().
+		Model((*UserRole)(nil)).
+		Column("role_id").
+		Where("user_id = ?", user.ID).
+		Order("role_id ASC").
+		Scan(ctx, &roleIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.RoleID != 0 && !containsRole(roleIDs, user.RoleID) {
+		roleIDs = append(roleIDs, user.RoleID)
+		sort.Slice(roleIDs, func(i, j int) bool { return roleIDs[i] < roleIDs[j] })
+	}
+
+	return roleIDs, nil
+}
+
+func containsRole(roleIDs []int32, roleID int32) bool {
+	for _, id := range roleIDs {
+		if id == roleID {
+			return true
+		}
+	}
+	return false
+}
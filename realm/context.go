@@ -0,0 +1,31 @@
+package realm
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type contextKey int
+
+const realmKey contextKey = 0
+
+// NewContext returns a copy of ctx carrying the realm
+func NewContext(ctx context.Context, realm *Realm) context.Context {
+	return context.WithValue(ctx, realmKey, realm)
+}
+
+// FromContext returns the realm a request was routed to
+func FromContext(ctx context.Context) (*Realm, bool) {
+	realm, ok := ctx.Value(realmKey).(*Realm)
+	return realm, ok && realm != nil
+}
+
+// IDFromContext returns the ID of the realm a request was routed to,
+// requests which did not go through the middleware use the default realm
+func IDFromContext(ctx context.Context) uuid.UUID {
+	if realm, ok := FromContext(ctx); ok {
+		return realm.ID
+	}
+	return uuid.Nil
+}
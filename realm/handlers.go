@@ -0,0 +1,502 @@
+package realm
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/id/util/response"
+	"github.com/resonatecoop/user-api/model"
+)
+
+var (
+	// ErrForbidden ...
+	ErrForbidden = errors.New("Not allowed to manage this realm")
+	// ErrUnauthorized ...
+	ErrUnauthorized = errors.New("Invalid or missing access token")
+	// ErrClientIDRequired ...
+	ErrClientIDRequired = errors.New("Client ID is required")
+)
+
+// tenantRealmRequest is the body of a realm update by one of its tenant
+// admins, it only holds what they may change
+type tenantRealmRequest struct {
+	Name          string     `json:"name"`
+	DefaultRoleID int32      `json:"default_role_id"`
+	Mail          MailConfig `json:"mail"`
+	Branding      Branding   `json:"branding"`
+}
+
+// realmRequest is the body of a realm update by an admin, who also decides
+// how requests are routed to the realm, what it may grant and which stripe
+// account it charges on behalf of
+type realmRequest struct {
+	tenantRealmRequest
+	Hostnames    []string      `json:"hostnames"`
+	Active       bool          `json:"active"`
+	Scopes       []string      `json:"scopes"`
+	DefaultScope string        `json:"default_scope"`
+	Payment      PaymentConfig `json:"payment"`
+}
+
+// clientRequest is the body of a client creation request
+type clientRequest struct {
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	ApplicationName     string `json:"application_name"`
+	ApplicationHostname string `json:"application_hostname"`
+	ApplicationURL      string `json:"application_url"`
+}
+
+// clientResponse describes a client without its secret hash
+type clientResponse struct {
+	ID              string `json:"id"`
+	ClientID        string `json:"client_id"`
+	Secret          string `json:"secret,omitempty"`
+	RedirectURI     string `json:"redirect_uri,omitempty"`
+	ApplicationName string `json:"application_name,omitempty"`
+	ApplicationURL  string `json:"application_url,omitempty"`
+}
+
+// userResponse describes a realm member
+type userResponse struct {
+	ID             string `json:"id"`
+	Username       string `json:"username"`
+	RoleID         int32  `json:"role_id"`
+	EmailConfirmed bool   `json:"email_confirmed"`
+}
+
+// Lists realms (GET /v1/realms)
+func (s *Service) listRealmsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.authorize(r, nil); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	realms, err := s.List(r.Context())
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, map[string]interface{}{"realms": realms}, http.StatusOK)
+}
+
+// Creates a realm (POST /v1/realms)
+func (s *Service) createRealmHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.authorize(r, nil); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	realm := &Realm{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(realm); err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	realm.ID = uuid.Nil
+
+	if err := s.Create(r.Context(), realm); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, realm, http.StatusCreated)
+}
+
+// Returns a realm (GET /v1/realms/{slug})
+func (s *Service) getRealmHandler(w http.ResponseWriter, r *http.Request) {
+	realm, _, err := s.authorizeRealm(r, true)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, realm, http.StatusOK)
+}
+
+// Updates a realm (PUT /v1/realms/{slug}), fields missing from the body are
+// left unchanged. Tenant admins cannot change how requests are routed to
+// their realm, its scopes or its payment settings, those are ignored.
+func (s *Service) updateRealmHandler(w http.ResponseWriter, r *http.Request) {
+	realm, actor, err := s.authorizeRealm(r, true)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	tenantReq := tenantRealmRequest{
+		Name:          realm.Name,
+		DefaultRoleID: realm.DefaultRoleID,
+		Mail:          realm.Mail,
+		Branding:      realm.Branding,
+	}
+
+	if !isAdmin(actor) {
+		if err := json.NewDecoder(r.Body).Decode(&tenantReq); err != nil {
+			response.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		req := realmRequest{
+			tenantRealmRequest: tenantReq,
+			Hostnames:          realm.Hostnames,
+			Active:             realm.Active,
+			Scopes:             realm.Scopes,
+			DefaultScope:       realm.DefaultScope,
+			Payment:            realm.Payment,
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tenantReq = req.tenantRealmRequest
+		realm.Hostnames, realm.Active = req.Hostnames, req.Active
+		realm.Scopes, realm.DefaultScope = req.Scopes, req.DefaultScope
+		realm.Payment = req.Payment
+	}
+
+	realm.Name, realm.DefaultRoleID = tenantReq.Name, tenantReq.DefaultRoleID
+	realm.Mail, realm.Branding = tenantReq.Mail, tenantReq.Branding
+
+	if err := s.Update(r.Context(), realm); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, realm, http.StatusOK)
+}
+
+// Deletes a realm (DELETE /v1/realms/{slug})
+func (s *Service) deleteRealmHandler(w http.ResponseWriter, r *http.Request) {
+	realm, _, err := s.authorizeRealm(r, false)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	if err := s.Delete(r.Context(), realm); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// Lists the clients of a realm (GET /v1/realms/{slug}/clients)
+func (s *Service) listClientsHandler(w http.ResponseWriter, r *http.Request) {
+	realm, _, err := s.authorizeRealm(r, true)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	clients, err := s.ListClients(r.Context(), realm)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	resp := make([]clientResponse, 0, len(clients))
+	for _, client := range clients {
+		resp = append(resp, newClientResponse(client, ""))
+	}
+
+	response.WriteJSON(w, map[string]interface{}{"clients": resp}, http.StatusOK)
+}
+
+// Creates a client in a realm (POST /v1/realms/{slug}/clients), the
+// generated secret is only returned once
+func (s *Service) createClientHandler(w http.ResponseWriter, r *http.Request) {
+	realm, _, err := s.authorizeRealm(r, true)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	req := new(clientRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.ClientID == "" {
+		response.Error(w, ErrClientIDRequired.Error(), http.StatusBadRequest)
+		return
+	}
+
+	secret, err := util.GenerateSecret()
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	client, err := s.backend.CreateClient(
+		req.ClientID,
+		secret,
+		req.RedirectURI,
+		req.ApplicationName,
+		req.ApplicationHostname,
+		req.ApplicationURL,
+	)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.AssignClient(r.Context(), realm.ID, client.ID); err != nil {
+		s.backend.DeleteClient(client) // do not leave the client in the default realm
+		s.writeError(w, err)
+		return
+	}
+
+	response.WriteJSON(w, newClientResponse(client, secret), http.StatusCreated)
+}
+
+// Moves an existing client to a realm (PUT /v1/realms/{slug}/clients/{client_id})
+func (s *Service) assignClientHandler(w http.ResponseWriter, r *http.Request) {
+	realm, _, err := s.authorizeRealm(r, false)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	client, err := s.backend.FindClientByClientID(mux.Vars(r)["client_id"])
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err := s.AssignClient(r.Context(), realm.ID, client.ID); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// Deletes a client of a realm (DELETE /v1/realms/{slug}/clients/{client_id})
+func (s *Service) deleteClientHandler(w http.ResponseWriter, r *http.Request) {
+	realm, _, err := s.authorizeRealm(r, true)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	client, err := s.backend.FindClientByClientID(mux.Vars(r)["client_id"])
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	realmID, err := s.ClientRealmID(r.Context(), client.ID)
+	if err == nil && realmID != realm.ID {
+		err = ErrForbidden
+	}
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	if err := s.backend.DeleteClient(client); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// Lists the users of a realm (GET /v1/realms/{slug}/users)
+func (s *Service) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	realm, _, err := s.authorizeRealm(r, true)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	users, err := s.ListUsers(r.Context(), realm)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	resp := make([]userResponse, 0, len(users))
+	for _, user := range users {
+		resp = append(resp, userResponse{
+			ID:             user.ID.String(),
+			Username:       user.Username,
+			RoleID:         user.RoleID,
+			EmailConfirmed: user.EmailConfirmed,
+		})
+	}
+
+	response.WriteJSON(w, map[string]interface{}{"users": resp}, http.StatusOK)
+}
+
+// Moves a user to a realm (PUT /v1/realms/{slug}/users/{username})
+func (s *Service) assignUserHandler(w http.ResponseWriter, r *http.Request) {
+	realm, _, err := s.authorizeRealm(r, false)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	user, err := s.backend.FindUserByUsername(mux.Vars(r)["username"])
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err := s.AssignUser(r.Context(), realm.ID, user.ID); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// Sets the role of a realm member (PUT /v1/realms/{slug}/users/{username}/role),
+// tenant admins can grant any role up to their own
+func (s *Service) setUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	realm, actor, err := s.authorizeRealm(r, true)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	var req struct {
+		RoleID int32 `json:"role_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.RoleID < int32(model.TenantAdminRole) || req.RoleID > int32(model.UserRole) {
+		s.writeError(w, ErrInvalidRole)
+		return
+	}
+
+	user, err := s.backend.FindUserByUsername(mux.Vars(r)["username"])
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	realmID, err := s.UserRealmID(r.Context(), user.ID)
+	if err == nil && realmID != realm.ID {
+		err = ErrForbidden
+	}
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	if err := s.backend.SetUserRole(user, req.RoleID, actor.ID); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// authorize authenticates the bearer token of the request as an admin token
+// and returns its user. Admins may manage every realm, tenant admins only
+// the realm given they belong to, a nil realm requires an admin.
+func (s *Service) authorize(r *http.Request, realm *Realm) (*model.User, error) {
+	token, err := util.ParseBearerToken(r)
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+
+	user, err := s.backend.AuthenticateAdmin(string(token))
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+
+	switch model.AccessRole(user.RoleID) {
+	case model.SuperAdminRole, model.AdminRole:
+		return user, nil
+	case model.TenantAdminRole:
+		if realm == nil || realm.IsDefault() {
+			return nil, ErrForbidden
+		}
+		realmID, err := s.UserRealmID(r.Context(), user.ID)
+		if err == nil && realmID != realm.ID {
+			err = ErrForbidden
+		}
+		if err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	return nil, ErrForbidden
+}
+
+// authorizeRealm looks up the realm in the request path and authorizes the
+// request, tenantAdmin tells whether tenant admins of the realm are allowed.
+// The authorized user is returned along with the realm.
+func (s *Service) authorizeRealm(r *http.Request, tenantAdmin bool) (*Realm, *model.User, error) {
+	realm, err := s.FindBySlug(r.Context(), mux.Vars(r)["slug"])
+	if err == ErrRealmNotFound {
+		// do not tell anonymous clients which realms exist
+		if _, authErr := s.authorize(r, nil); authErr == ErrUnauthorized {
+			return nil, nil, authErr
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	required := realm
+	if !tenantAdmin {
+		required = nil
+	}
+
+	user, err := s.authorize(r, required)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return realm, user, nil
+}
+
+// isAdmin returns true for users who may manage every realm
+func isAdmin(user *model.User) bool {
+	role := model.AccessRole(user.RoleID)
+	return role == model.SuperAdminRole || role == model.AdminRole
+}
+
+// writeError maps realm errors to status codes
+func (s *Service) writeError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrRealmNotFound:
+		response.Error(w, err.Error(), http.StatusNotFound)
+	case ErrForbidden:
+		response.Error(w, err.Error(), http.StatusForbidden)
+	case ErrDefaultRealm, ErrSlugTaken, ErrHostnameTaken:
+		response.Error(w, err.Error(), http.StatusConflict)
+	case ErrInvalidSlug, ErrNameRequired, ErrInvalidHostname, ErrInvalidRole, ErrInvalidDefaultScope:
+		response.Error(w, err.Error(), http.StatusBadRequest)
+	case ErrUnauthorized:
+		response.UnauthorizedError(w, err.Error())
+	default:
+		response.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func newClientResponse(client *model.Client, secret string) clientResponse {
+	return clientResponse{
+		ID:              client.ID.String(),
+		ClientID:        client.Key,
+		Secret:          secret,
+		RedirectURI:     client.RedirectURI.String,
+		ApplicationName: client.ApplicationName.String,
+		ApplicationURL:  client.ApplicationURL.String,
+	}
+}
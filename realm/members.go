@@ -0,0 +1,162 @@
+package realm
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/user-api/model"
+)
+
+// ClientRealmID returns the ID of the realm a client belongs to
+func (s *Service) ClientRealmID(ctx context.Context, clientID uuid.UUID) (uuid.UUID, error) {
+	var realmID uuid.UUID
+
+	err := s.db.NewSelect().
+		Model((*Client)(nil)).
+		Column("realm_id").
+		Where("client_id = ?", clientID).
+		Scan(ctx, &realmID)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, nil
+	}
+
+	return realmID, err
+}
+
+// UserRealmID returns the ID of the realm a user belongs to
+func (s *Service) UserRealmID(ctx context.Context, userID uuid.UUID) (uuid.UUID, error) {
+	var realmID uuid.UUID
+
+	err := s.db.NewSelect().
+		Model((*User)(nil)).
+		Column("realm_id").
+		Where("user_id = ?", userID).
+		Scan(ctx, &realmID)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, nil
+	}
+
+	return realmID, err
+}
+
+// ForUsername returns the realm of the user with the given username,
+// unknown users belong to the default realm
+func (s *Service) ForUsername(ctx context.Context, username string) (*Realm, error) {
+	var realmID uuid.UUID
+
+	err := s.db.NewSelect().
+		Model((*User)(nil)).
+		Column("realm_user.realm_id").
+		Join("JOIN users AS u ON u.id = realm_user.user_id").
+		Where("u.username = ?", username).
+		Scan(ctx, &realmID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return s.FindByID(ctx, realmID)
+}
+
+// AssignClient moves a client to a realm, uuid.Nil being the default realm
+func (s *Service) AssignClient(ctx context.Context, realmID, clientID uuid.UUID) error {
+	if realmID == uuid.Nil {
+		_, err := s.db.NewDelete().
+			Model((*Client)(nil)).
+			Where("client_id = ?", clientID).
+			Exec(ctx)
+		return err
+	}
+
+	_, err := s.db.NewInsert().
+		Model(&Client{ClientID: clientID, RealmID: realmID}).
+		On("CONFLICT (client_id) DO UPDATE").
+		Set("realm_id = EXCLUDED.realm_id").
+		Exec(ctx)
+
+	return err
+}
+
+// AssignUser moves a user to a realm, uuid.Nil being the default realm
+func (s *Service) AssignUser(ctx context.Context, realmID, userID uuid.UUID) error {
+	if realmID == uuid.Nil {
+		_, err := s.db.NewDelete().
+			Model((*User)(nil)).
+			Where("user_id = ?", userID).
+			Exec(ctx)
+		return err
+	}
+
+	_, err := s.db.NewInsert().
+		Model(&User{UserID: userID, RealmID: realmID}).
+		On("CONFLICT (user_id) DO UPDATE").
+		Set("realm_id = EXCLUDED.realm_id").
+		Exec(ctx)
+
+	return err
+}
+
+// ListClients returns the clients assigned to a stored realm
+func (s *Service) ListClients(ctx context.Context, realm *Realm) ([]*model.Client, error) {
+	var clients []*model.Client
+
+	err := s.db.NewSelect().
+		Model(&clients).
+		Join("JOIN realm_clients AS rc ON rc.client_id = client.id").
+		Where("rc.realm_id = ?", realm.ID).
+		Order("client.key").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+// ListUsers returns the users assigned to a stored realm
+func (s *Service) ListUsers(ctx context.Context, realm *Realm) ([]*model.User, error) {
+	var users []*model.User
+
+	err := s.db.NewSelect().
+		Model(&users).
+		Column("user.id", "user.username", "user.role_id", "user.email_confirmed", "user.created_at").
+		Join("JOIN realm_users AS ru ON ru.user_id = \"user\".id").
+		Where("ru.realm_id = ?", realm.ID).
+		Order("user.username").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// CheckClient returns ErrRealmMismatch unless the client belongs to the
+// realm the request was routed to
+func (s *Service) CheckClient(ctx context.Context, client *model.Client) error {
+	realmID, err := s.ClientRealmID(ctx, client.ID)
+	if err != nil {
+		return err
+	}
+
+	if realmID != IDFromContext(ctx) {
+		return ErrRealmMismatch
+	}
+
+	return nil
+}
+
+// CheckUser returns ErrRealmMismatch unless the user belongs to the realm
+// the request was routed to
+func (s *Service) CheckUser(ctx context.Context, user *model.User) error {
+	realmID, err := s.UserRealmID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if realmID != IDFromContext(ctx) {
+		return ErrRealmMismatch
+	}
+
+	return nil
+}
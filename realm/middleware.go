@@ -0,0 +1,117 @@
+package realm
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/resonatecoop/id/log"
+)
+
+// PathPrefix selects a realm by slug, e.g. /realms/acme/v1/oauth/tokens
+const PathPrefix = "/realms/"
+
+// Finder looks up the realm a request is routed to
+type Finder interface {
+	Default() *Realm
+	FindBySlug(ctx context.Context, slug string) (*Realm, error)
+	FindByHostname(ctx context.Context, hostname string) (*Realm, error)
+}
+
+// Middleware is a middleware handler adding the realm a request is routed
+// to to the request context. Realms are selected with the /realms/{slug}
+// path prefix, which is stripped before the request is routed, or by the
+// request hostname. Unknown hostnames use the default realm.
+type Middleware struct {
+	finder Finder
+}
+
+// NewMiddleware returns a new Middleware instance
+func NewMiddleware(finder Finder) *Middleware {
+	return &Middleware{finder: finder}
+}
+
+func (m *Middleware) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	ctx := r.Context()
+
+	if strings.HasPrefix(r.URL.Path, PathPrefix) {
+		slug, rest := splitPrefix(r.URL.Path)
+
+		realm, err := m.finder.FindBySlug(ctx, slug)
+		if err != nil || !realm.Active {
+			m.notFound(rw, r, err)
+			return
+		}
+
+		prefix := PathPrefix + slug
+		r = stripPrefix(r.WithContext(NewContext(ctx, realm)), prefix, rest)
+		next(&locationWriter{ResponseWriter: rw, prefix: prefix}, r)
+		return
+	}
+
+	realm, err := m.finder.FindByHostname(ctx, hostname(r))
+	if err == ErrRealmNotFound {
+		realm, err = m.finder.Default(), nil
+	}
+	if err != nil || !realm.Active {
+		m.notFound(rw, r, err)
+		return
+	}
+
+	next(rw, r.WithContext(NewContext(ctx, realm)))
+}
+
+func (m *Middleware) notFound(rw http.ResponseWriter, r *http.Request, err error) {
+	if err != nil && err != ErrRealmNotFound {
+		log.FromContext(r.Context()).ERROR.Printf("Realm lookup failed: %v", err)
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	http.Error(rw, ErrRealmNotFound.Error(), http.StatusNotFound)
+}
+
+// splitPrefix splits /realms/{slug}/rest into slug and /rest
+func splitPrefix(path string) (string, string) {
+	path = strings.TrimPrefix(path, PathPrefix)
+	if i := strings.Index(path, "/"); i >= 0 {
+		return path[:i], path[i:]
+	}
+	return path, "/"
+}
+
+// stripPrefix returns a copy of r with the realm prefix removed
+func stripPrefix(r *http.Request, prefix, rest string) *http.Request {
+	r2 := r.Clone(r.Context())
+	r2.URL.Path = rest
+	r2.URL.RawPath = ""
+	r2.RequestURI = strings.TrimPrefix(r.RequestURI, prefix)
+	if r2.RequestURI == "" || r2.RequestURI[0] != '/' {
+		r2.RequestURI = "/" + r2.RequestURI
+	}
+	return r2
+}
+
+// hostname returns the request host without its port
+func hostname(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		return strings.ToLower(r.Host)
+	}
+	return strings.ToLower(host)
+}
+
+// locationWriter keeps redirects to absolute paths within the realm prefix
+type locationWriter struct {
+	http.ResponseWriter
+	prefix string
+}
+
+func (w *locationWriter) WriteHeader(code int) {
+	location := w.Header().Get("Location")
+	if strings.HasPrefix(location, "/") && !strings.HasPrefix(location, "//") &&
+		!strings.HasPrefix(location, w.prefix+"/") {
+		w.Header().Set("Location", w.prefix+location)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,94 @@
+package realm_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/realm"
+	"github.com/stretchr/testify/assert"
+)
+
+type finder struct {
+	realms []*realm.Realm
+}
+
+func (f *finder) Default() *realm.Realm {
+	return realm.Default(&config.Config{Hostname: "id.resonate.coop"})
+}
+
+func (f *finder) FindBySlug(ctx context.Context, slug string) (*realm.Realm, error) {
+	for _, rlm := range f.realms {
+		if rlm.Slug == slug {
+			return rlm, nil
+		}
+	}
+	return nil, realm.ErrRealmNotFound
+}
+
+func (f *finder) FindByHostname(ctx context.Context, hostname string) (*realm.Realm, error) {
+	for _, rlm := range f.realms {
+		if rlm.HasHostname(hostname) {
+			return rlm, nil
+		}
+	}
+	return nil, realm.ErrRealmNotFound
+}
+
+func TestMiddleware(t *testing.T) {
+	acme := &realm.Realm{ID: uuid.New(), Slug: "acme", Active: true, Hostnames: []string{"id.acme.test"}}
+	closed := &realm.Realm{ID: uuid.New(), Slug: "closed"}
+
+	middleware := realm.NewMiddleware(&finder{realms: []*realm.Realm{acme, closed}})
+
+	var (
+		selected *realm.Realm
+		path     string
+	)
+	next := func(w http.ResponseWriter, r *http.Request) {
+		selected, _ = realm.FromContext(r.Context())
+		path = r.URL.Path
+		http.Redirect(w, r, "/web/login", http.StatusFound)
+	}
+
+	// Realms are selected by hostname
+	r := httptest.NewRequest("GET", "https://id.acme.test:443/web/register", nil)
+	w := httptest.NewRecorder()
+	middleware.ServeHTTP(w, r, next)
+
+	assert.Equal(t, acme, selected)
+	assert.Equal(t, "/web/register", path)
+	assert.Equal(t, "/web/login", w.Header().Get("Location"))
+
+	// Unknown hostnames use the default realm
+	r = httptest.NewRequest("GET", "https://localhost/web/register", nil)
+	w = httptest.NewRecorder()
+	middleware.ServeHTTP(w, r, next)
+
+	if assert.NotNil(t, selected) {
+		assert.True(t, selected.IsDefault())
+	}
+
+	// The path prefix is stripped and kept in redirects
+	r = httptest.NewRequest("GET", "https://id.resonate.coop/realms/acme/web/register?a=1", nil)
+	w = httptest.NewRecorder()
+	middleware.ServeHTTP(w, r, next)
+
+	assert.Equal(t, acme, selected)
+	assert.Equal(t, "/web/register", path)
+	assert.Equal(t, "/realms/acme/web/login", w.Header().Get("Location"))
+
+	// Unknown and inactive realms are not found
+	for _, target := range []string{"/realms/unknown/web/login", "/realms/closed/web/login"} {
+		selected = nil
+		r = httptest.NewRequest("GET", "https://id.resonate.coop"+target, nil)
+		w = httptest.NewRecorder()
+		middleware.ServeHTTP(w, r, next)
+
+		assert.Nil(t, selected, target)
+		assert.Equal(t, http.StatusNotFound, w.Code, target)
+	}
+}
@@ -0,0 +1,244 @@
+package realm
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+// DefaultSlug is the slug of the realm built from the global configuration
+const DefaultSlug = "default"
+
+// validSlug matches realm slugs, which are used as path prefixes
+var validSlug = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// Realm is a tenant with its own clients, users and branding
+type Realm struct {
+	bun.BaseModel `bun:"table:realms"`
+
+	ID            uuid.UUID     `bun:",pk,type:uuid,nullzero,default:uuid_generate_v4()" json:"id"`
+	Slug          string        `bun:",unique,notnull" json:"slug"`
+	Name          string        `bun:",notnull" json:"name"`
+	Hostnames     []string      `bun:",array" json:"hostnames"`
+	Active        bool          `bun:",notnull" json:"active"`
+	DefaultRoleID int32         `json:"default_role_id"`
+	Scopes        []string      `bun:",array" json:"scopes"`
+	DefaultScope  string        `json:"default_scope"`
+	Mail          MailConfig    `bun:"type:jsonb" json:"mail"`
+	Payment       PaymentConfig `bun:"type:jsonb" json:"payment"`
+	Branding      Branding      `bun:"type:jsonb" json:"branding"`
+	CreatedAt     time.Time     `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt     time.Time     `bun:",nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// MailConfig overrides the global mailgun settings for a realm
+type MailConfig struct {
+	Sender         string `json:"sender,omitempty"`
+	TemplatePrefix string `json:"template_prefix,omitempty"`
+}
+
+// PaymentConfig overrides the global stripe settings for a realm. Account is
+// the connected stripe account charges are made on behalf of, products are
+// keyed by their name in config.StripeConfig (e.g. ArtistMembership).
+type PaymentConfig struct {
+	Account  string                    `json:"account,omitempty"`
+	Domain   string                    `json:"domain,omitempty"`
+	Products map[string]config.Product `json:"products,omitempty"`
+}
+
+// Branding is what the web pages of a realm are decorated with
+type Branding struct {
+	Name         string `json:"name,omitempty"`
+	LogoURL      string `json:"logo_url,omitempty"`
+	PrimaryColor string `json:"primary_color,omitempty"`
+}
+
+// Client assigns a client to a realm
+type Client struct {
+	bun.BaseModel `bun:"table:realm_clients"`
+
+	ClientID uuid.UUID `bun:"type:uuid,pk"`
+	RealmID  uuid.UUID `bun:"type:uuid,notnull"`
+}
+
+// User assigns a user to a realm
+type User struct {
+	bun.BaseModel `bun:"table:realm_users"`
+
+	UserID  uuid.UUID `bun:"type:uuid,pk"`
+	RealmID uuid.UUID `bun:"type:uuid,notnull"`
+}
+
+// Default returns the realm every client and user without an explicit
+// assignment belongs to. It is not stored, its settings come from cnf.
+func Default(cnf *config.Config) *Realm {
+	return &Realm{
+		ID:            uuid.Nil,
+		Slug:          DefaultSlug,
+		Name:          "Resonate ID",
+		Hostnames:     []string{cnf.Hostname},
+		Active:        true,
+		DefaultRoleID: int32(model.UserRole),
+	}
+}
+
+// IsDefault returns true for the realm built from the global configuration
+func (r *Realm) IsDefault() bool {
+	return r.ID == uuid.Nil
+}
+
+// RoleID returns the role assigned to users signing up in the realm
+func (r *Realm) RoleID() int32 {
+	if r.DefaultRoleID == 0 {
+		return int32(model.UserRole)
+	}
+	return r.DefaultRoleID
+}
+
+// AllowsScope returns true if every scope in the space delimited scope
+// string may be granted in the realm. Realms without scopes allow all.
+func (r *Realm) AllowsScope(scope string) bool {
+	if len(r.Scopes) == 0 {
+		return true
+	}
+
+	for _, requested := range strings.Fields(scope) {
+		allowed := false
+		for _, s := range r.Scopes {
+			if s == requested {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Sender returns the address emails of the realm are sent from
+func (r *Realm) Sender(cnf *config.Config) string {
+	if r.Mail.Sender != "" {
+		return r.Mail.Sender
+	}
+	return cnf.Mailgun.Sender
+}
+
+// Template returns the name of the mailgun template used by the realm
+func (r *Realm) Template(name string) string {
+	return r.Mail.TemplatePrefix + name
+}
+
+// StripeConfig returns base with the payment settings of the realm applied
+func (r *Realm) StripeConfig(base config.StripeConfig) config.StripeConfig {
+	cnf := base
+
+	if r.Payment.Domain != "" {
+		cnf.Domain = r.Payment.Domain
+	}
+
+	products := map[string]*config.Product{
+		"ListenerSubscription": &cnf.ListenerSubscription,
+		"SupporterShares":      &cnf.SupporterShares,
+		"ArtistMembership":     &cnf.ArtistMembership,
+		"LabelMembership":      &cnf.LabelMembership,
+		"StreamCredit50":       &cnf.StreamCredit50,
+		"StreamCredit20":       &cnf.StreamCredit20,
+		"StreamCredit10":       &cnf.StreamCredit10,
+		"StreamCredit5":        &cnf.StreamCredit5,
+	}
+
+	for name, product := range r.Payment.Products {
+		if p, ok := products[name]; ok {
+			*p = product
+		}
+	}
+
+	return cnf
+}
+
+// StripeParams is implemented by the params of every stripe request
+type StripeParams interface {
+	SetStripeAccount(val string)
+}
+
+// UseStripeAccount makes a stripe request on behalf of the connected account
+// of the realm, realms without one use the platform account
+func (r *Realm) UseStripeAccount(params StripeParams) {
+	if r.Payment.Account != "" {
+		params.SetStripeAccount(r.Payment.Account)
+	}
+}
+
+// BrandingData returns the branding rendered in the web pages of the realm
+func (r *Realm) BrandingData() Branding {
+	branding := r.Branding
+	if branding.Name == "" {
+		branding.Name = r.Name
+	}
+	return branding
+}
+
+// HasHostname returns true if the realm is served on hostname
+func (r *Realm) HasHostname(hostname string) bool {
+	for _, h := range r.Hostnames {
+		if strings.EqualFold(h, hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the realm can be stored
+func (r *Realm) Validate() error {
+	if !validSlug.MatchString(r.Slug) || r.Slug == DefaultSlug {
+		return ErrInvalidSlug
+	}
+
+	if strings.TrimSpace(r.Name) == "" {
+		return ErrNameRequired
+	}
+
+	// arrays are not nullable
+	if r.Hostnames == nil {
+		r.Hostnames = []string{}
+	}
+	if r.Scopes == nil {
+		r.Scopes = []string{}
+	}
+
+	for i, hostname := range r.Hostnames {
+		hostname = strings.ToLower(strings.TrimSpace(hostname))
+		if hostname == "" || strings.ContainsAny(hostname, "/ ") {
+			return ErrInvalidHostname
+		}
+		r.Hostnames[i] = hostname
+	}
+
+	if r.DefaultRoleID != 0 &&
+		(r.DefaultRoleID < int32(model.LabelRole) || r.DefaultRoleID > int32(model.UserRole)) {
+		return ErrInvalidRole
+	}
+
+	if r.DefaultScope != "" && !r.AllowsScope(r.DefaultScope) {
+		return ErrInvalidDefaultScope
+	}
+
+	return nil
+}
+
+// URL returns the absolute URL of path in the realm, realms without a
+// hostname of their own are reached through their path prefix
+func (r *Realm) URL(cnf *config.Config, path string) string {
+	if len(r.Hostnames) > 0 {
+		return "https://" + r.Hostnames[0] + path
+	}
+	return "https://" + cnf.Hostname + PathPrefix + r.Slug + path
+}
@@ -0,0 +1,94 @@
+package realm_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/realm"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefault(t *testing.T) {
+	rlm := realm.Default(&config.Config{Hostname: "id.resonate.coop"})
+
+	assert.True(t, rlm.IsDefault())
+	assert.True(t, rlm.Active)
+	assert.True(t, rlm.HasHostname("ID.resonate.coop"))
+	assert.Equal(t, int32(model.UserRole), rlm.RoleID())
+	assert.Equal(t, "Resonate ID", rlm.BrandingData().Name)
+}
+
+func TestAllowsScope(t *testing.T) {
+	rlm := &realm.Realm{}
+	assert.True(t, rlm.AllowsScope("read_write"))
+
+	rlm.Scopes = []string{"read", "read_write"}
+	assert.True(t, rlm.AllowsScope("read"))
+	assert.True(t, rlm.AllowsScope("read read_write"))
+	assert.False(t, rlm.AllowsScope("read admin"))
+}
+
+func TestStripeConfig(t *testing.T) {
+	base := config.StripeConfig{
+		Domain:           "id.resonate.coop",
+		ArtistMembership: config.Product{ID: "prod_artist"},
+		LabelMembership:  config.Product{ID: "prod_label"},
+	}
+
+	rlm := &realm.Realm{
+		Payment: realm.PaymentConfig{
+			Domain: "id.acme.test",
+			Products: map[string]config.Product{
+				"ArtistMembership": {ID: "prod_acme"},
+				"Unknown":          {ID: "prod_unknown"},
+			},
+		},
+	}
+
+	cnf := rlm.StripeConfig(base)
+	assert.Equal(t, "id.acme.test", cnf.Domain)
+	assert.Equal(t, "prod_acme", cnf.ArtistMembership.ID)
+	assert.Equal(t, "prod_label", cnf.LabelMembership.ID)
+
+	// The base configuration is left untouched
+	assert.Equal(t, "prod_artist", base.ArtistMembership.ID)
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		realm realm.Realm
+		err   error
+	}{
+		{realm.Realm{Slug: "acme", Name: "Acme"}, nil},
+		{realm.Realm{Slug: "default", Name: "Acme"}, realm.ErrInvalidSlug},
+		{realm.Realm{Slug: "Acme", Name: "Acme"}, realm.ErrInvalidSlug},
+		{realm.Realm{Slug: "acme/x", Name: "Acme"}, realm.ErrInvalidSlug},
+		{realm.Realm{Slug: "acme", Name: " "}, realm.ErrNameRequired},
+		{realm.Realm{Slug: "acme", Name: "Acme", Hostnames: []string{"acme.test/x"}}, realm.ErrInvalidHostname},
+		{realm.Realm{Slug: "acme", Name: "Acme", DefaultRoleID: int32(model.AdminRole)}, realm.ErrInvalidRole},
+		{realm.Realm{Slug: "acme", Name: "Acme", DefaultRoleID: int32(model.ArtistRole)}, nil},
+		{realm.Realm{Slug: "acme", Name: "Acme", Scopes: []string{"read"}, DefaultScope: "read_write"}, realm.ErrInvalidDefaultScope},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.err, testCase.realm.Validate(), testCase.realm.Slug)
+	}
+
+	rlm := &realm.Realm{Slug: "acme", Name: "Acme", Hostnames: []string{" ID.Acme.test "}}
+	if assert.NoError(t, rlm.Validate()) {
+		assert.Equal(t, []string{"id.acme.test"}, rlm.Hostnames)
+		assert.Equal(t, []string{}, rlm.Scopes)
+	}
+}
+
+func TestURL(t *testing.T) {
+	cnf := &config.Config{Hostname: "id.resonate.coop"}
+
+	rlm := &realm.Realm{ID: uuid.New(), Slug: "acme"}
+	assert.Equal(t, "https://id.resonate.coop/realms/acme/web/login", rlm.URL(cnf, "/web/login"))
+
+	rlm.Hostnames = []string{"id.acme.test"}
+	assert.Equal(t, "https://id.acme.test/web/login", rlm.URL(cnf, "/web/login"))
+}
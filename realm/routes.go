@@ -0,0 +1,90 @@
+package realm
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/resonatecoop/id/util/routes"
+)
+
+// RegisterRoutes registers route handlers for the realm service
+func (s *Service) RegisterRoutes(router *mux.Router, prefix string) {
+	subRouter := router.PathPrefix(prefix).Subrouter()
+	routes.AddRoutes(s.GetRoutes(), subRouter)
+}
+
+// GetRoutes returns []routes.Route slice for the realm service
+func (s *Service) GetRoutes() []routes.Route {
+	return []routes.Route{
+		{
+			Name:        "realms_list",
+			Method:      "GET",
+			Pattern:     "",
+			HandlerFunc: s.listRealmsHandler,
+		},
+		{
+			Name:        "realms_create",
+			Method:      "POST",
+			Pattern:     "",
+			HandlerFunc: s.createRealmHandler,
+		},
+		{
+			Name:        "realms_get",
+			Method:      "GET",
+			Pattern:     "/{slug}",
+			HandlerFunc: s.getRealmHandler,
+		},
+		{
+			Name:        "realms_update",
+			Method:      "PUT",
+			Pattern:     "/{slug}",
+			HandlerFunc: s.updateRealmHandler,
+		},
+		{
+			Name:        "realms_delete",
+			Method:      "DELETE",
+			Pattern:     "/{slug}",
+			HandlerFunc: s.deleteRealmHandler,
+		},
+		{
+			Name:        "realms_clients_list",
+			Method:      "GET",
+			Pattern:     "/{slug}/clients",
+			HandlerFunc: s.listClientsHandler,
+		},
+		{
+			Name:        "realms_clients_create",
+			Method:      "POST",
+			Pattern:     "/{slug}/clients",
+			HandlerFunc: s.createClientHandler,
+		},
+		{
+			Name:        "realms_clients_assign",
+			Method:      "PUT",
+			Pattern:     "/{slug}/clients/{client_id}",
+			HandlerFunc: s.assignClientHandler,
+		},
+		{
+			Name:        "realms_clients_delete",
+			Method:      "DELETE",
+			Pattern:     "/{slug}/clients/{client_id}",
+			HandlerFunc: s.deleteClientHandler,
+		},
+		{
+			Name:        "realms_users_list",
+			Method:      "GET",
+			Pattern:     "/{slug}/users",
+			HandlerFunc: s.listUsersHandler,
+		},
+		{
+			Name:        "realms_users_assign",
+			Method:      "PUT",
+			Pattern:     "/{slug}/users/{username}",
+			HandlerFunc: s.assignUserHandler,
+		},
+		{
+			Name:        "realms_users_role",
+			Method:      "PUT",
+			Pattern:     "/{slug}/users/{username}/role",
+			HandlerFunc: s.setUserRoleHandler,
+		},
+	}
+}
@@ -0,0 +1,219 @@
+package realm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/config"
+	"github.com/uptrace/bun"
+)
+
+var (
+	// ErrRealmNotFound ...
+	ErrRealmNotFound = errors.New("Realm not found")
+	// ErrRealmMismatch ...
+	ErrRealmMismatch = errors.New("Resource belongs to another realm")
+	// ErrDefaultRealm ...
+	ErrDefaultRealm = errors.New("The default realm is managed through the configuration")
+	// ErrInvalidSlug ...
+	ErrInvalidSlug = errors.New("Invalid realm slug")
+	// ErrSlugTaken ...
+	ErrSlugTaken = errors.New("Realm slug taken")
+	// ErrNameRequired ...
+	ErrNameRequired = errors.New("Realm name is required")
+	// ErrInvalidHostname ...
+	ErrInvalidHostname = errors.New("Invalid realm hostname")
+	// ErrHostnameTaken ...
+	ErrHostnameTaken = errors.New("Hostname is used by another realm")
+	// ErrInvalidRole ...
+	ErrInvalidRole = errors.New("Invalid default role")
+	// ErrInvalidDefaultScope ...
+	ErrInvalidDefaultScope = errors.New("Default scope is not allowed in the realm")
+)
+
+// Service struct keeps config and db objects to avoid passing them around
+type Service struct {
+	cnf     *config.Config
+	db      *bun.DB
+	backend Backend
+}
+
+// NewService returns a new Service instance
+func NewService(cnf *config.Config, db *bun.DB) *Service {
+	return &Service{cnf: cnf, db: db}
+}
+
+// GetConfig returns config.Config instance
+func (s *Service) GetConfig() *config.Config {
+	return s.cnf
+}
+
+// UseBackend sets the oauth backend the admin API relies on
+func (s *Service) UseBackend(b Backend) {
+	s.backend = b
+}
+
+// Default returns the realm built from the global configuration
+func (s *Service) Default() *Realm {
+	return Default(s.cnf)
+}
+
+// Close stops any running services
+func (s *Service) Close() {}
+
+// FindByID looks up a realm by its ID, uuid.Nil being the default realm
+func (s *Service) FindByID(ctx context.Context, id uuid.UUID) (*Realm, error) {
+	if id == uuid.Nil {
+		return s.Default(), nil
+	}
+	return s.findBy(ctx, "id = ?", id)
+}
+
+// FindBySlug looks up a realm by its slug
+func (s *Service) FindBySlug(ctx context.Context, slug string) (*Realm, error) {
+	if slug == DefaultSlug {
+		return s.Default(), nil
+	}
+	return s.findBy(ctx, "slug = ?", slug)
+}
+
+// FindByHostname looks up the realm served on hostname
+func (s *Service) FindByHostname(ctx context.Context, hostname string) (*Realm, error) {
+	hostname = strings.ToLower(hostname)
+	if s.Default().HasHostname(hostname) {
+		return s.Default(), nil
+	}
+	return s.findBy(ctx, "hostnames @> ARRAY[?]::varchar[]", hostname)
+}
+
+// FindByStripeAccount looks up the realm using a connected stripe account,
+// events without an account belong to the default realm
+func (s *Service) FindByStripeAccount(ctx context.Context, account string) (*Realm, error) {
+	if account == "" {
+		return s.Default(), nil
+	}
+	return s.findBy(ctx, "payment->>'account' = ?", account)
+}
+
+// List returns all stored realms ordered by slug
+func (s *Service) List(ctx context.Context) ([]*Realm, error) {
+	var realms []*Realm
+
+	err := s.db.NewSelect().
+		Model(&realms).
+		Order("slug").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return realms, nil
+}
+
+// Create validates and stores a new realm
+func (s *Service) Create(ctx context.Context, realm *Realm) error {
+	if err := realm.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := s.findBy(ctx, "slug = ?", realm.Slug); err != ErrRealmNotFound {
+		if err != nil {
+			return err
+		}
+		return ErrSlugTaken
+	}
+
+	if err := s.checkHostnames(ctx, realm); err != nil {
+		return err
+	}
+
+	_, err := s.db.NewInsert().
+		Model(realm).
+		Returning("*").
+		Exec(ctx)
+
+	return err
+}
+
+// Update validates and stores the changes made to a realm
+func (s *Service) Update(ctx context.Context, realm *Realm) error {
+	if realm.IsDefault() {
+		return ErrDefaultRealm
+	}
+
+	if err := realm.Validate(); err != nil {
+		return err
+	}
+
+	if err := s.checkHostnames(ctx, realm); err != nil {
+		return err
+	}
+
+	realm.UpdatedAt = time.Now().UTC()
+
+	_, err := s.db.NewUpdate().
+		Model(realm).
+		ExcludeColumn("id", "slug", "created_at").
+		WherePK().
+		Exec(ctx)
+
+	return err
+}
+
+// Delete removes a realm, its clients and users return to the default realm
+func (s *Service) Delete(ctx context.Context, realm *Realm) error {
+	if realm.IsDefault() {
+		return ErrDefaultRealm
+	}
+
+	_, err := s.db.NewDelete().
+		Model(realm).
+		WherePK().
+		Exec(ctx)
+
+	return err
+}
+
+func (s *Service) findBy(ctx context.Context, query string, args ...interface{}) (*Realm, error) {
+	realm := new(Realm)
+
+	err := s.db.NewSelect().
+		Model(realm).
+		Where(query, args...).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, ErrRealmNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return realm, nil
+}
+
+// checkHostnames makes sure a hostname selects a single realm
+func (s *Service) checkHostnames(ctx context.Context, realm *Realm) error {
+	for _, hostname := range realm.Hostnames {
+		if s.Default().HasHostname(hostname) {
+			return ErrHostnameTaken
+		}
+
+		other, err := s.findBy(ctx, "hostnames @> ARRAY[?]::varchar[]", hostname)
+		if err == ErrRealmNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if other.ID != realm.ID {
+			return ErrHostnameTaken
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package realm
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/util/routes"
+	"github.com/resonatecoop/user-api/model"
+)
+
+// Backend is the part of the oauth service the realm admin API relies on
+type Backend interface {
+	AuthenticateAdmin(token string) (*model.User, error)
+	FindClientByClientID(clientID string) (*model.Client, error)
+	CreateClient(clientID, secret, redirectURI, applicationName, applicationHostname, applicationURL string) (*model.Client, error)
+	DeleteClient(client *model.Client) error
+	FindUserByUsername(username string) (*model.User, error)
+	SetUserRole(user *model.User, roleID int32, actorID uuid.UUID) error
+}
+
+// ServiceInterface defines exported methods
+type ServiceInterface interface {
+	// Exported methods
+	GetConfig() *config.Config
+	UseBackend(b Backend)
+	Default() *Realm
+	FindByID(ctx context.Context, id uuid.UUID) (*Realm, error)
+	FindBySlug(ctx context.Context, slug string) (*Realm, error)
+	FindByHostname(ctx context.Context, hostname string) (*Realm, error)
+	FindByStripeAccount(ctx context.Context, account string) (*Realm, error)
+	List(ctx context.Context) ([]*Realm, error)
+	Create(ctx context.Context, realm *Realm) error
+	Update(ctx context.Context, realm *Realm) error
+	Delete(ctx context.Context, realm *Realm) error
+	ClientRealmID(ctx context.Context, clientID uuid.UUID) (uuid.UUID, error)
+	UserRealmID(ctx context.Context, userID uuid.UUID) (uuid.UUID, error)
+	ForUsername(ctx context.Context, username string) (*Realm, error)
+	AssignClient(ctx context.Context, realmID, clientID uuid.UUID) error
+	AssignUser(ctx context.Context, realmID, userID uuid.UUID) error
+	ListClients(ctx context.Context, realm *Realm) ([]*model.Client, error)
+	ListUsers(ctx context.Context, realm *Realm) ([]*model.User, error)
+	CheckClient(ctx context.Context, client *model.Client) error
+	CheckUser(ctx context.Context, user *model.User) error
+	GetRoutes() []routes.Route
+	RegisterRoutes(router *mux.Router, prefix string)
+	Close()
+}
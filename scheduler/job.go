@@ -0,0 +1,17 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+)
+
+// Job is a task run periodically by the scheduler
+type Job struct {
+	// Name identifies the job, it is also used to derive the
+	// advisory lock key so it must be the same on every replica
+	Name string
+	// Interval is the delay between two runs of the job
+	Interval time.Duration
+	// Run does the actual work and returns the number of removed rows
+	Run func(ctx context.Context) (int, error)
+}
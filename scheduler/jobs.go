@@ -0,0 +1,100 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/events"
+	"github.com/resonatecoop/id/oauth"
+)
+
+// NewCleanupJobs returns the jobs removing expired tokens, authorization
+// codes, email tokens, stale sessions and accounts past their deletion
+// grace period, cancelling the subscriptions of purged accounts and ending
+// expired impersonations
+func NewCleanupJobs(cnf *config.Config, oauthService oauth.ServiceInterface) []*Job {
+	cleanupInterval := time.Duration(cnf.Scheduler.CleanupInterval) * time.Second
+	purgeInterval := time.Duration(cnf.Scheduler.PurgeInterval) * time.Second
+	batchSize := cnf.Scheduler.BatchSize
+
+	batched := func(purge func(batchSize int) (int, error)) func(ctx context.Context) (int, error) {
+		return func(ctx context.Context) (int, error) {
+			return purge(batchSize)
+		}
+	}
+
+	return []*Job{
+		{
+			Name:     "purge_expired_access_tokens",
+			Interval: cleanupInterval,
+			Run:      batched(oauthService.PurgeExpiredAccessTokens),
+		},
+		{
+			Name:     "purge_expired_refresh_tokens",
+			Interval: cleanupInterval,
+			Run:      batched(oauthService.PurgeExpiredRefreshTokens),
+		},
+		{
+			Name:     "purge_expired_authorization_codes",
+			Interval: cleanupInterval,
+			Run:      batched(oauthService.PurgeExpiredAuthorizationCodes),
+		},
+		{
+			Name:     "purge_expired_email_tokens",
+			Interval: cleanupInterval,
+			Run:      batched(oauthService.PurgeExpiredEmailTokens),
+		},
+		{
+			Name:     "purge_stale_sessions",
+			Interval: cleanupInterval,
+			Run:      batched(oauthService.PurgeStaleSessions),
+		},
+		{
+			Name:     "purge_expired_pushed_authorization_requests",
+			Interval: cleanupInterval,
+			Run:      batched(oauthService.PurgeExpiredPushedAuthorizationRequests),
+		},
+		{
+			Name:     "end_expired_impersonations",
+			Interval: cleanupInterval,
+			Run:      batched(oauthService.EndExpiredImpersonations),
+		},
+		{
+			Name:     "purge_deleted_users",
+			Interval: purgeInterval,
+			Run: func(ctx context.Context) (int, error) {
+				return oauthService.PurgeDeletedUsers()
+			},
+		},
+		{
+			Name:     "cancel_stripe_subscriptions",
+			Interval: cleanupInterval,
+			Run:      batched(oauthService.CancelStripeSubscriptions),
+		},
+	}
+}
+
+// NewUserAPISyncJob returns the job delivering the changes made to users,
+// e.g. sign ups, to the user api
+func NewUserAPISyncJob(cnf *config.Config, oauthService oauth.ServiceInterface) *Job {
+	return &Job{
+		Name:     "sync_user_api",
+		Interval: time.Duration(cnf.UserAPI.SyncInterval) * time.Second,
+		Run: func(ctx context.Context) (int, error) {
+			return oauthService.SyncUserAPI(cnf.Scheduler.BatchSize)
+		},
+	}
+}
+
+// NewWebhookDeliveryJob returns the job sending identity events to the
+// webhook subscriptions of downstream services
+func NewWebhookDeliveryJob(cnf *config.Config, eventsService events.ServiceInterface) *Job {
+	return &Job{
+		Name:     "deliver_webhooks",
+		Interval: time.Duration(cnf.Webhooks.DeliveryInterval) * time.Second,
+		Run: func(ctx context.Context) (int, error) {
+			return eventsService.DeliverPending(cnf.Scheduler.BatchSize)
+		},
+	}
+}
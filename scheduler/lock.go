@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"context"
+	"hash/fnv"
+
+	"github.com/uptrace/bun"
+)
+
+// Locker is used to elect the replica running a job
+type Locker interface {
+	// TryLock attempts to acquire the lock identified by name without
+	// blocking. When acquired, unlock must be called once the job is done.
+	TryLock(ctx context.Context, name string) (unlock func(), acquired bool, err error)
+}
+
+// advisoryLocker relies on Postgres session level advisory locks
+type advisoryLocker struct {
+	db *bun.DB
+}
+
+// NewAdvisoryLocker returns a Locker backed by Postgres advisory locks
+func NewAdvisoryLocker(db *bun.DB) Locker {
+	return &advisoryLocker{db: db}
+}
+
+// TryLock acquires a Postgres advisory lock on a dedicated connection,
+// advisory locks are bound to the session which acquired them
+func (l *advisoryLocker) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key := lockKey(name)
+
+	var acquired bool
+
+	err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(?)", key).Scan(&acquired)
+	if err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+
+	if !acquired {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	unlock := func() {
+		// use a fresh context, ctx may be cancelled by now
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(?)", key)
+		conn.Close()
+	}
+
+	return unlock, true, nil
+}
+
+// lockKey maps a job name to a 64 bit advisory lock key
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("id:scheduler:" + name))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,42 @@
+package scheduler
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	jobRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "id",
+			Subsystem: "scheduler",
+			Name:      "job_runs_total",
+			Help:      "Number of job runs by job and result (success, error, skipped).",
+		},
+		[]string{"job", "result"},
+	)
+
+	jobDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "id",
+			Subsystem: "scheduler",
+			Name:      "job_duration_seconds",
+			Help:      "Duration of job runs on the elected replica.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"job"},
+	)
+
+	removedRows = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "id",
+			Subsystem: "scheduler",
+			Name:      "removed_rows_total",
+			Help:      "Number of rows removed by cleanup jobs.",
+		},
+		[]string{"job"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(jobRuns, jobDuration, removedRows)
+}
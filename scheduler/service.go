@@ -0,0 +1,134 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/log"
+	"github.com/uptrace/bun"
+)
+
+// Service struct keeps registered jobs and the locker used to elect
+// the replica running each of them
+type Service struct {
+	cnf     *config.Config
+	locker  Locker
+	jobs    []*Job
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	started bool
+}
+
+// NewService returns a new Service instance using Postgres advisory locks
+func NewService(cnf *config.Config, db *bun.DB) *Service {
+	return NewServiceWithLocker(cnf, NewAdvisoryLocker(db))
+}
+
+// NewServiceWithLocker returns a new Service instance using the given locker
+func NewServiceWithLocker(cnf *config.Config, locker Locker) *Service {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Service{
+		cnf:    cnf,
+		locker: locker,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Register adds a job to the scheduler, jobs registered
+// after Start has been called are started right away
+func (s *Service) Register(job *Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs = append(s.jobs, job)
+
+	if s.started {
+		s.startJob(job)
+	}
+}
+
+// Start runs every registered job on its own interval
+func (s *Service) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return
+	}
+
+	s.started = true
+
+	for _, job := range s.jobs {
+		s.startJob(job)
+	}
+}
+
+// RunJob runs a job once if this replica manages to acquire its lock,
+// it returns false when another replica holds the lock
+func (s *Service) RunJob(ctx context.Context, job *Job) (bool, error) {
+	unlock, acquired, err := s.locker.TryLock(ctx, job.Name)
+	if err != nil {
+		jobRuns.WithLabelValues(job.Name, "error").Inc()
+		return false, err
+	}
+
+	if !acquired {
+		jobRuns.WithLabelValues(job.Name, "skipped").Inc()
+		return false, nil
+	}
+
+	defer unlock()
+
+	start := time.Now()
+	removed, err := job.Run(ctx)
+	jobDuration.WithLabelValues(job.Name).Observe(time.Since(start).Seconds())
+
+	removedRows.WithLabelValues(job.Name).Add(float64(removed))
+
+	if err != nil {
+		jobRuns.WithLabelValues(job.Name, "error").Inc()
+		return true, err
+	}
+
+	jobRuns.WithLabelValues(job.Name, "success").Inc()
+
+	if removed > 0 {
+		log.INFO.Printf("Job %s removed %d rows", job.Name, removed)
+	}
+
+	return true, nil
+}
+
+func (s *Service) startJob(job *Job) {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(job.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.RunJob(s.ctx, job); err != nil {
+					log.ERROR.Printf("Job %s failed: %v", job.Name, err)
+				}
+			}
+		}
+	}()
+}
+
+// Close stops the scheduler and waits for running jobs to return
+func (s *Service) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
@@ -0,0 +1,14 @@
+package scheduler
+
+import (
+	"context"
+)
+
+// ServiceInterface defines exported methods
+type ServiceInterface interface {
+	// Exported methods
+	Register(job *Job)
+	Start()
+	RunJob(ctx context.Context, job *Job) (bool, error)
+	Close()
+}
@@ -0,0 +1,133 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeLocker emulates advisory locks shared by several replicas
+type fakeLocker struct {
+	mu     sync.Mutex
+	locked map[string]bool
+}
+
+func newFakeLocker() *fakeLocker {
+	return &fakeLocker{locked: make(map[string]bool)}
+}
+
+func (l *fakeLocker) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.locked[name] {
+		return nil, false, nil
+	}
+
+	l.locked[name] = true
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.locked, name)
+	}, true, nil
+}
+
+func TestRunJob(t *testing.T) {
+	s := scheduler.NewServiceWithLocker(config.Cnf, newFakeLocker())
+
+	runs := 0
+	job := &scheduler.Job{
+		Name: "test_run_job",
+		Run: func(ctx context.Context) (int, error) {
+			runs++
+			return 3, nil
+		},
+	}
+
+	ran, err := s.RunJob(context.Background(), job)
+	assert.NoError(t, err)
+	assert.True(t, ran)
+	assert.Equal(t, 1, runs)
+
+	job.Run = func(ctx context.Context) (int, error) {
+		return 0, errors.New("boom")
+	}
+
+	ran, err = s.RunJob(context.Background(), job)
+	assert.EqualError(t, err, "boom")
+	assert.True(t, ran)
+}
+
+func TestRunJobSingleReplica(t *testing.T) {
+	locker := newFakeLocker()
+
+	// two replicas sharing the same database
+	first := scheduler.NewServiceWithLocker(config.Cnf, locker)
+	second := scheduler.NewServiceWithLocker(config.Cnf, locker)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	job := &scheduler.Job{
+		Name: "test_single_replica",
+		Run: func(ctx context.Context) (int, error) {
+			close(started)
+			<-release
+			return 0, nil
+		},
+	}
+
+	done := make(chan bool)
+	go func() {
+		ran, _ := first.RunJob(context.Background(), job)
+		done <- ran
+	}()
+
+	<-started
+
+	ran, err := second.RunJob(context.Background(), job)
+	assert.NoError(t, err)
+	assert.False(t, ran, "job should not run while another replica holds the lock")
+
+	close(release)
+	assert.True(t, <-done)
+}
+
+func TestStartAndClose(t *testing.T) {
+	s := scheduler.NewServiceWithLocker(config.Cnf, newFakeLocker())
+
+	ran := make(chan struct{})
+
+	s.Register(&scheduler.Job{
+		Name:     "test_start_and_close",
+		Interval: 10 * time.Millisecond,
+		Run: func(ctx context.Context) (int, error) {
+			select {
+			case ran <- struct{}{}:
+			case <-ctx.Done():
+			}
+			return 0, nil
+		},
+	})
+
+	s.Start()
+
+	// the job keeps running on its interval, however slow the runner is
+	for i := 0; i < 2; i++ {
+		select {
+		case <-ran:
+		case <-time.After(10 * time.Second):
+			t.Fatal("job did not run")
+		}
+	}
+
+	// runs still waiting to be received are cancelled by Close
+	s.Close()
+}
@@ -0,0 +1,213 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// BulkOperation is an operation of a bulk request
+type BulkOperation struct {
+	Method  string          `json:"method"`
+	BulkID  string          `json:"bulkId,omitempty"`
+	Version string          `json:"version,omitempty"`
+	Path    string          `json:"path"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// BulkRequest is the body of a bulk request
+type BulkRequest struct {
+	Schemas      []string        `json:"schemas"`
+	FailOnErrors int             `json:"failOnErrors,omitempty"`
+	Operations   []BulkOperation `json:"Operations"`
+}
+
+// BulkOperationResponse is the outcome of a bulk operation
+type BulkOperationResponse struct {
+	Method   string         `json:"method"`
+	BulkID   string         `json:"bulkId,omitempty"`
+	Location string         `json:"location,omitempty"`
+	Status   string         `json:"status"`
+	Response *ErrorResponse `json:"response,omitempty"`
+}
+
+// BulkResponse is the body of a bulk response
+type BulkResponse struct {
+	Schemas    []string                `json:"schemas"`
+	Operations []BulkOperationResponse `json:"Operations"`
+}
+
+// bulkIDPattern matches references to resources created earlier in the
+// same request, e.g. "bulkId:qwerty"
+var bulkIDPattern = regexp.MustCompile(`bulkId:([^"/\s]+)`)
+
+// Bulk applies the operations of a bulk request in order. Operations may
+// reference the resources created by earlier ones through their bulkId.
+// Processing stops once failOnErrors operations failed.
+func (s *Service) Bulk(ctx context.Context, req *BulkRequest) (*BulkResponse, error) {
+	if len(req.Operations) > s.cnf.SCIM.BulkMaxOperations {
+		return nil, ErrTooManyOperations
+	}
+
+	ids := map[string]string{}
+	failures := 0
+
+	res := &BulkResponse{
+		Schemas:    []string{BulkResponseSchema},
+		Operations: []BulkOperationResponse{},
+	}
+
+	for _, op := range req.Operations {
+		result := BulkOperationResponse{
+			Method: strings.ToUpper(op.Method),
+			BulkID: op.BulkID,
+		}
+
+		id, location, status, err := s.bulkOperation(ctx, op, ids)
+		if err != nil {
+			failures++
+			result.Response = newErrorResponse(err)
+			result.Status = result.Response.Status
+		} else {
+			result.Location = location
+			result.Status = strconv.Itoa(status)
+			if op.BulkID != "" && id != "" {
+				ids[op.BulkID] = id
+			}
+		}
+
+		res.Operations = append(res.Operations, result)
+
+		if req.FailOnErrors > 0 && failures >= req.FailOnErrors {
+			break
+		}
+	}
+
+	return res, nil
+}
+
+// bulkOperation applies a single operation, it returns the ID and location
+// of the resource along with the status of the operation
+func (s *Service) bulkOperation(ctx context.Context, op BulkOperation, ids map[string]string) (string, string, int, error) {
+	path, err := resolveBulkIDs(op.Path, ids)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	data, err := resolveBulkIDs(string(op.Data), ids)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) > 2 || (parts[0] != "Users" && parts[0] != "Groups") {
+		return "", "", 0, ErrInvalidPath
+	}
+
+	method := strings.ToUpper(op.Method)
+
+	// Only creations target the resource type itself
+	if (method == http.MethodPost) != (len(parts) == 1) {
+		return "", "", 0, ErrInvalidPath
+	}
+
+	id := ""
+	if len(parts) == 2 {
+		id = parts[1]
+	}
+
+	if method == http.MethodDelete {
+		if parts[0] == "Users" {
+			err = s.DeleteUser(ctx, id)
+		} else {
+			err = s.DeleteGroup(ctx, id)
+		}
+		return id, "", http.StatusNoContent, err
+	}
+
+	var meta *Meta
+
+	switch {
+	case method == http.MethodPatch:
+		req := new(PatchRequest)
+		if err = decode([]byte(data), req); err != nil {
+			return "", "", 0, err
+		}
+		if parts[0] == "Users" {
+			var user *User
+			if user, err = s.PatchUser(ctx, id, req.Operations); err == nil {
+				meta = user.Meta
+			}
+		} else {
+			var group *Group
+			if group, err = s.PatchGroup(ctx, id, req.Operations); err == nil {
+				meta = group.Meta
+			}
+		}
+	case parts[0] == "Users" && (method == http.MethodPost || method == http.MethodPut):
+		user := new(User)
+		if err = decode([]byte(data), user); err != nil {
+			return "", "", 0, err
+		}
+		if method == http.MethodPost {
+			user, err = s.CreateUser(ctx, user)
+		} else {
+			user, err = s.ReplaceUser(ctx, id, user)
+		}
+		if err == nil {
+			id, meta = user.ID, user.Meta
+		}
+	case parts[0] == "Groups" && (method == http.MethodPost || method == http.MethodPut):
+		group := new(Group)
+		if err = decode([]byte(data), group); err != nil {
+			return "", "", 0, err
+		}
+		if method == http.MethodPost {
+			group, err = s.CreateGroup(ctx, group)
+		} else {
+			group, err = s.ReplaceGroup(ctx, id, group)
+		}
+		if err == nil {
+			id, meta = group.ID, group.Meta
+		}
+	default:
+		return "", "", 0, ErrUnsupportedOperation
+	}
+
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	if method == http.MethodPost {
+		return id, meta.Location, http.StatusCreated, nil
+	}
+
+	return id, meta.Location, http.StatusOK, nil
+}
+
+// resolveBulkIDs replaces references to resources created earlier in a
+// bulk request by their ID
+func resolveBulkIDs(s string, ids map[string]string) (string, error) {
+	var err error
+
+	resolved := bulkIDPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		id, ok := ids[ref[len("bulkId:"):]]
+		if !ok {
+			err = ErrUnknownBulkID
+		}
+		return id
+	})
+
+	return resolved, err
+}
+
+// decode decodes a SCIM message
+func decode(data []byte, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrInvalidSyntax
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package scim
+
+import (
+	"context"
+)
+
+// DiscoveryMeta describes the configuration resources, which have no
+// creation or modification time
+type DiscoveryMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location"`
+}
+
+// Supported tells whether an optional feature is supported
+type Supported struct {
+	Supported bool `json:"supported"`
+}
+
+// BulkSupport describes the limits of bulk requests
+type BulkSupport struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+// FilterSupport describes the limits of queries
+type FilterSupport struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+// AuthenticationScheme describes how clients authenticate
+type AuthenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Primary     bool   `json:"primary"`
+}
+
+// ServiceProviderConfig describes the features of the API
+type ServiceProviderConfig struct {
+	Schemas               []string               `json:"schemas"`
+	Patch                 Supported              `json:"patch"`
+	Bulk                  BulkSupport            `json:"bulk"`
+	Filter                FilterSupport          `json:"filter"`
+	ChangePassword        Supported              `json:"changePassword"`
+	Sort                  Supported              `json:"sort"`
+	Etag                  Supported              `json:"etag"`
+	AuthenticationSchemes []AuthenticationScheme `json:"authenticationSchemes"`
+	Meta                  *DiscoveryMeta         `json:"meta"`
+}
+
+// SchemaExtension is an extension of the schema of a resource type
+type SchemaExtension struct {
+	Schema   string `json:"schema"`
+	Required bool   `json:"required"`
+}
+
+// ResourceType describes a type of resource
+type ResourceType struct {
+	Schemas          []string          `json:"schemas"`
+	ID               string            `json:"id"`
+	Name             string            `json:"name"`
+	Endpoint         string            `json:"endpoint"`
+	Description      string            `json:"description"`
+	Schema           string            `json:"schema"`
+	SchemaExtensions []SchemaExtension `json:"schemaExtensions,omitempty"`
+	Meta             *DiscoveryMeta    `json:"meta"`
+}
+
+// ServiceProviderConfig returns the features of the API, sorting and ETags
+// are not supported and passwords are replaced like any other attribute
+func (s *Service) ServiceProviderConfig(ctx context.Context) *ServiceProviderConfig {
+	return &ServiceProviderConfig{
+		Schemas: []string{ServiceProviderConfigSchema},
+		Patch:   Supported{Supported: true},
+		Bulk: BulkSupport{
+			Supported:      true,
+			MaxOperations:  s.cnf.SCIM.BulkMaxOperations,
+			MaxPayloadSize: s.cnf.SCIM.BulkMaxPayloadSize,
+		},
+		Filter: FilterSupport{
+			Supported:  true,
+			MaxResults: s.cnf.SCIM.MaxResults,
+		},
+		AuthenticationSchemes: []AuthenticationScheme{{
+			Type:        "oauthbearertoken",
+			Name:        "OAuth Bearer Token",
+			Description: "Client credentials access token with the " + Scope + " scope",
+			Primary:     true,
+		}},
+		Meta: &DiscoveryMeta{
+			ResourceType: "ServiceProviderConfig",
+			Location:     s.location(ctx, "/ServiceProviderConfig"),
+		},
+	}
+}
+
+// ResourceTypes returns the types of resources the API manages
+func (s *Service) ResourceTypes(ctx context.Context) []*ResourceType {
+	return []*ResourceType{
+		{
+			Schemas:     []string{ResourceTypeSchema},
+			ID:          ResourceUser,
+			Name:        ResourceUser,
+			Endpoint:    "/Users",
+			Description: "User accounts",
+			Schema:      UserSchema,
+			Meta: &DiscoveryMeta{
+				ResourceType: "ResourceType",
+				Location:     s.location(ctx, "/ResourceTypes/"+ResourceUser),
+			},
+		},
+		{
+			Schemas:     []string{ResourceTypeSchema},
+			ID:          ResourceGroup,
+			Name:        ResourceGroup,
+			Endpoint:    "/Groups",
+			Description: "User groups, e.g. artist personas, bands and labels",
+			Schema:      GroupSchema,
+			SchemaExtensions: []SchemaExtension{
+				{Schema: GroupExtensionSchema, Required: false},
+			},
+			Meta: &DiscoveryMeta{
+				ResourceType: "ResourceType",
+				Location:     s.location(ctx, "/ResourceTypes/"+ResourceGroup),
+			},
+		},
+	}
+}
@@ -0,0 +1,666 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AttributeType tells how the values of an attribute are compared
+type AttributeType int
+
+const (
+	// StringAttribute values are compared ignoring case
+	StringAttribute AttributeType = iota
+	// BooleanAttribute values are only compared for equality
+	BooleanAttribute
+	// DateTimeAttribute values are RFC 3339 timestamps
+	DateTimeAttribute
+	// ReferenceAttribute values are UUIDs, only compared for equality
+	ReferenceAttribute
+)
+
+// Attribute maps an attribute which may be filtered on to a SQL expression
+type Attribute struct {
+	Column string
+	Type   AttributeType
+}
+
+// Filter is a parsed SCIM filter, e.g. userName eq "member@example.com"
+type Filter struct {
+	expr expression
+}
+
+type expression interface{}
+
+// logicalExpression joins two expressions with "and" or "or"
+type logicalExpression struct {
+	op          string
+	left, right expression
+}
+
+// notExpression negates an expression
+type notExpression struct {
+	expr expression
+}
+
+// attributeExpression compares an attribute to a value, value is nil for
+// the "pr" (present) operator
+type attributeExpression struct {
+	attr  string
+	op    string
+	value interface{}
+}
+
+// valuePathExpression filters the values of a multi-valued attribute,
+// e.g. emails[type eq "work"]
+type valuePathExpression struct {
+	attr   string
+	filter expression
+}
+
+// comparisonOperators are the operators taking a value
+var comparisonOperators = []string{"eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le"}
+
+// ParseFilter parses a filter as described in RFC 7644 section 3.4.2.2
+func ParseFilter(filter string) (*Filter, error) {
+	tokens, err := tokenize(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos != len(p.tokens) {
+		return nil, ErrInvalidFilter
+	}
+
+	return &Filter{expr: expr}, nil
+}
+
+// Match returns true if a resource, or a value of a multi-valued attribute,
+// decoded from JSON matches the filter
+func (f *Filter) Match(value map[string]interface{}) bool {
+	return match(f.expr, value)
+}
+
+// SQL returns the WHERE clause selecting the resources matching the filter
+// along with its arguments, attributes lists the attributes which may be
+// filtered on keyed by their lowercase path
+func (f *Filter) SQL(attributes map[string]Attribute) (string, []interface{}, error) {
+	return compile(f.expr, "", attributes)
+}
+
+type tokenKind int
+
+const (
+	wordToken tokenKind = iota
+	stringToken
+	openParenToken
+	closeParenToken
+	openBracketToken
+	closeBracketToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize splits a filter into words, quoted strings, parentheses and
+// brackets, quoted strings are unescaped
+func tokenize(filter string) ([]token, error) {
+	tokens := []token{}
+
+	for i := 0; i < len(filter); {
+		switch c := filter[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: openParenToken})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: closeParenToken})
+			i++
+		case c == '[':
+			tokens = append(tokens, token{kind: openBracketToken})
+			i++
+		case c == ']':
+			tokens = append(tokens, token{kind: closeBracketToken})
+			i++
+		case c == '"':
+			end := i + 1
+			for ; end < len(filter) && filter[end] != '"'; end++ {
+				if filter[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(filter) {
+				return nil, ErrInvalidFilter
+			}
+
+			var text string
+			if err := json.Unmarshal([]byte(filter[i:end+1]), &text); err != nil {
+				return nil, ErrInvalidFilter
+			}
+
+			tokens = append(tokens, token{kind: stringToken, text: text})
+			i = end + 1
+		default:
+			end := i
+			for ; end < len(filter) && !strings.ContainsRune(" \t\n\r()[]\"", rune(filter[end])); end++ {
+			}
+			tokens = append(tokens, token{kind: wordToken, text: filter[i:end]})
+			i = end
+		}
+	}
+
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() *token {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+func (p *parser) next() *token {
+	t := p.peek()
+	if t != nil {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind) error {
+	if t := p.next(); t == nil || t.kind != kind {
+		return ErrInvalidFilter
+	}
+	return nil
+}
+
+// keyword returns true and consumes the next token if it is the keyword
+func (p *parser) keyword(keyword string) bool {
+	t := p.peek()
+	if t == nil || t.kind != wordToken || !strings.EqualFold(t.text, keyword) {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+func (p *parser) parseOr() (expression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpression{op: "or", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expression, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpression{op: "and", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (expression, error) {
+	if p.keyword("not") {
+		if err := p.expect(openParenToken); err != nil {
+			return nil, err
+		}
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(closeParenToken); err != nil {
+			return nil, err
+		}
+		return &notExpression{expr: expr}, nil
+	}
+
+	t := p.next()
+	if t == nil {
+		return nil, ErrInvalidFilter
+	}
+
+	if t.kind == openParenToken {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(closeParenToken); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+
+	if t.kind != wordToken {
+		return nil, ErrInvalidFilter
+	}
+
+	attr := t.text
+
+	if next := p.peek(); next != nil && next.kind == openBracketToken {
+		p.pos++
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(closeBracketToken); err != nil {
+			return nil, err
+		}
+		return &valuePathExpression{attr: attr, filter: filter}, nil
+	}
+
+	op := p.next()
+	if op == nil || op.kind != wordToken {
+		return nil, ErrInvalidFilter
+	}
+
+	operator := strings.ToLower(op.text)
+
+	if operator == "pr" {
+		return &attributeExpression{attr: attr, op: operator}, nil
+	}
+
+	if !containsString(comparisonOperators, operator) {
+		return nil, ErrInvalidFilter
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	return &attributeExpression{attr: attr, op: operator, value: value}, nil
+}
+
+// parseValue parses a string, boolean, null or number
+func (p *parser) parseValue() (interface{}, error) {
+	t := p.next()
+	if t == nil {
+		return nil, ErrInvalidFilter
+	}
+
+	if t.kind == stringToken {
+		return t.text, nil
+	}
+
+	if t.kind != wordToken {
+		return nil, ErrInvalidFilter
+	}
+
+	switch strings.ToLower(t.text) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+
+	number, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return nil, ErrInvalidFilter
+	}
+
+	return number, nil
+}
+
+// normalizeAttribute lowercases an attribute path and strips the URN of
+// the core schemas, extension attributes keep theirs
+func normalizeAttribute(attr string) string {
+	attr = strings.ToLower(attr)
+
+	for _, schema := range []string{UserSchema, GroupSchema} {
+		prefix := strings.ToLower(schema) + ":"
+		if strings.HasPrefix(attr, prefix) {
+			return attr[len(prefix):]
+		}
+	}
+
+	return attr
+}
+
+// match evaluates an expression against a value decoded from JSON
+func match(expr expression, value map[string]interface{}) bool {
+	switch e := expr.(type) {
+	case *logicalExpression:
+		if e.op == "and" {
+			return match(e.left, value) && match(e.right, value)
+		}
+		return match(e.left, value) || match(e.right, value)
+	case *notExpression:
+		return !match(e.expr, value)
+	case *valuePathExpression:
+		for _, candidate := range lookup(value, normalizeAttribute(e.attr)) {
+			if item, ok := candidate.(map[string]interface{}); ok && match(e.filter, item) {
+				return true
+			}
+		}
+		return false
+	case *attributeExpression:
+		candidates := lookup(value, normalizeAttribute(e.attr))
+
+		if e.op == "ne" {
+			for _, candidate := range candidates {
+				if compare(candidate, "eq", e.value) {
+					return false
+				}
+			}
+			return true
+		}
+
+		for _, candidate := range candidates {
+			if compare(candidate, e.op, e.value) {
+				return true
+			}
+		}
+		return e.op == "eq" && e.value == nil && len(candidates) == 0
+	}
+
+	return false
+}
+
+// lookup returns the values found at a dotted attribute path, keys are
+// matched ignoring case and the items of multi-valued attributes are
+// flattened
+func lookup(value interface{}, path string) []interface{} {
+	values := []interface{}{value}
+
+	for _, key := range strings.Split(path, ".") {
+		next := []interface{}{}
+
+		for _, v := range values {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			found, ok := m[findKey(m, key)]
+			if !ok || found == nil {
+				continue
+			}
+
+			if items, ok := found.([]interface{}); ok {
+				next = append(next, items...)
+			} else {
+				next = append(next, found)
+			}
+		}
+
+		values = next
+	}
+
+	return values
+}
+
+// compare compares a value decoded from JSON to the value of a filter
+func compare(candidate interface{}, op string, value interface{}) bool {
+	if op == "pr" {
+		switch c := candidate.(type) {
+		case nil:
+			return false
+		case string:
+			return c != ""
+		case []interface{}:
+			return len(c) > 0
+		case map[string]interface{}:
+			return len(c) > 0
+		}
+		return true
+	}
+
+	switch c := candidate.(type) {
+	case string:
+		v, ok := value.(string)
+		if !ok {
+			return false
+		}
+		c, v = strings.ToLower(c), strings.ToLower(v)
+
+		switch op {
+		case "eq":
+			return c == v
+		case "co":
+			return strings.Contains(c, v)
+		case "sw":
+			return strings.HasPrefix(c, v)
+		case "ew":
+			return strings.HasSuffix(c, v)
+		case "gt":
+			return c > v
+		case "ge":
+			return c >= v
+		case "lt":
+			return c < v
+		case "le":
+			return c <= v
+		}
+	case bool:
+		v, ok := value.(bool)
+		return ok && op == "eq" && c == v
+	case float64:
+		v, ok := value.(float64)
+		if !ok {
+			return false
+		}
+
+		switch op {
+		case "eq":
+			return c == v
+		case "gt":
+			return c > v
+		case "ge":
+			return c >= v
+		case "lt":
+			return c < v
+		case "le":
+			return c <= v
+		}
+	case nil:
+		return op == "eq" && value == nil
+	}
+
+	return false
+}
+
+// compile turns an expression into a WHERE clause, prefix is the path of
+// the multi-valued attribute a value path expression filters
+func compile(expr expression, prefix string, attributes map[string]Attribute) (string, []interface{}, error) {
+	switch e := expr.(type) {
+	case *logicalExpression:
+		left, leftArgs, err := compile(e.left, prefix, attributes)
+		if err != nil {
+			return "", nil, err
+		}
+		right, rightArgs, err := compile(e.right, prefix, attributes)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("(%s %s %s)", left, strings.ToUpper(e.op), right), append(leftArgs, rightArgs...), nil
+	case *notExpression:
+		clause, args, err := compile(e.expr, prefix, attributes)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("NOT (%s)", clause), args, nil
+	case *valuePathExpression:
+		if prefix != "" {
+			return "", nil, ErrInvalidFilter
+		}
+		return compile(e.filter, normalizeAttribute(e.attr)+".", attributes)
+	case *attributeExpression:
+		path := prefix + normalizeAttribute(e.attr)
+
+		attribute, ok := attributes[path]
+		if !ok {
+			// multi-valued attributes are compared by their value
+			attribute, ok = attributes[path+".value"]
+		}
+		if !ok {
+			return "", nil, ErrInvalidFilter
+		}
+
+		return compileComparison(attribute, e.op, e.value)
+	}
+
+	return "", nil, ErrInvalidFilter
+}
+
+// compileComparison compares a column to a value according to its type
+func compileComparison(attribute Attribute, op string, value interface{}) (string, []interface{}, error) {
+	column := attribute.Column
+
+	if op == "pr" {
+		if attribute.Type == StringAttribute {
+			return fmt.Sprintf("(%s IS NOT NULL AND %s <> '')", column, column), nil, nil
+		}
+		return fmt.Sprintf("%s IS NOT NULL", column), nil, nil
+	}
+
+	if value == nil {
+		switch op {
+		case "eq":
+			return fmt.Sprintf("%s IS NULL", column), nil, nil
+		case "ne":
+			return fmt.Sprintf("%s IS NOT NULL", column), nil, nil
+		}
+		return "", nil, ErrInvalidFilter
+	}
+
+	switch attribute.Type {
+	case StringAttribute:
+		v, ok := value.(string)
+		if !ok {
+			return "", nil, ErrInvalidFilter
+		}
+
+		switch op {
+		case "eq":
+			return fmt.Sprintf("lower(%s) = lower(?)", column), []interface{}{v}, nil
+		case "ne":
+			return fmt.Sprintf("(%s IS NULL OR lower(%s) <> lower(?))", column, column), []interface{}{v}, nil
+		case "co":
+			return fmt.Sprintf("lower(%s) LIKE ?", column), []interface{}{"%" + escapeLike(strings.ToLower(v)) + "%"}, nil
+		case "sw":
+			return fmt.Sprintf("lower(%s) LIKE ?", column), []interface{}{escapeLike(strings.ToLower(v)) + "%"}, nil
+		case "ew":
+			return fmt.Sprintf("lower(%s) LIKE ?", column), []interface{}{"%" + escapeLike(strings.ToLower(v))}, nil
+		}
+
+		return fmt.Sprintf("lower(%s) %s lower(?)", column, sqlOperator(op)), []interface{}{v}, nil
+	case BooleanAttribute:
+		v, ok := value.(bool)
+		if !ok || (op != "eq" && op != "ne") {
+			return "", nil, ErrInvalidFilter
+		}
+		return fmt.Sprintf("%s %s ?", column, sqlOperator(op)), []interface{}{v}, nil
+	case DateTimeAttribute:
+		s, ok := value.(string)
+		if !ok || op == "co" || op == "sw" || op == "ew" {
+			return "", nil, ErrInvalidFilter
+		}
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return "", nil, ErrInvalidFilter
+		}
+		return fmt.Sprintf("%s %s ?", column, sqlOperator(op)), []interface{}{v.UTC()}, nil
+	case ReferenceAttribute:
+		s, ok := value.(string)
+		if !ok || (op != "eq" && op != "ne") {
+			return "", nil, ErrInvalidFilter
+		}
+		v, err := uuid.Parse(s)
+		if err != nil {
+			// nothing is referenced by an invalid ID
+			if op == "eq" {
+				return "FALSE", nil, nil
+			}
+			return "TRUE", nil, nil
+		}
+		return fmt.Sprintf("%s %s ?", column, sqlOperator(op)), []interface{}{v}, nil
+	}
+
+	return "", nil, ErrInvalidFilter
+}
+
+// sqlOperator returns the SQL operator of a comparison operator
+func sqlOperator(op string) string {
+	switch op {
+	case "ne":
+		return "<>"
+	case "gt":
+		return ">"
+	case "ge":
+		return ">="
+	case "lt":
+		return "<"
+	case "le":
+		return "<="
+	}
+	return "="
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// findKey returns the key of a map matching key ignoring case, key itself
+// when there is none
+func findKey(m map[string]interface{}, key string) string {
+	if _, ok := m[key]; ok {
+		return key
+	}
+	for k := range m {
+		if strings.EqualFold(k, key) {
+			return k
+		}
+	}
+	return key
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
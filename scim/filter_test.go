@@ -0,0 +1,137 @@
+package scim_test
+
+import (
+	"testing"
+
+	"github.com/resonatecoop/id/scim"
+	"github.com/stretchr/testify/assert"
+)
+
+var testAttributes = map[string]scim.Attribute{
+	"id":           {Column: "id", Type: scim.ReferenceAttribute},
+	"username":     {Column: "username"},
+	"emails.value": {Column: "username"},
+	"active":       {Column: "active", Type: scim.BooleanAttribute},
+	"meta.created": {Column: "created_at", Type: scim.DateTimeAttribute},
+}
+
+func TestParseFilter(t *testing.T) {
+	for _, filter := range []string{
+		`userName eq "member@example.com"`,
+		`userName sw "m" and not (active eq false)`,
+		`(userName co "@example" or userName ew ".org") and active pr`,
+		`emails[type eq "work" and value co "@example.com"]`,
+		`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "a\"b"`,
+		`meta.created gt "2026-01-01T00:00:00Z"`,
+	} {
+		_, err := scim.ParseFilter(filter)
+		assert.Nil(t, err, filter)
+	}
+
+	for _, filter := range []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`userName like "x"`,
+		`userName eq "unterminated`,
+		`(userName eq "x"`,
+		`userName eq "x" and`,
+		`emails[type eq "work"`,
+	} {
+		_, err := scim.ParseFilter(filter)
+		assert.Equal(t, scim.ErrInvalidFilter, err, filter)
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	member := map[string]interface{}{
+		"userName": "Member@Example.com",
+		"active":   true,
+		"emails": []interface{}{
+			map[string]interface{}{"value": "member@example.com", "type": "work"},
+		},
+	}
+
+	testCases := []struct {
+		filter  string
+		matches bool
+	}{
+		{`userName eq "member@example.com"`, true},
+		{`USERNAME eq "member@example.com"`, true},
+		{`userName ne "member@example.com"`, false},
+		{`userName sw "member" and active eq true`, true},
+		{`not (active eq true)`, false},
+		{`displayName pr`, false},
+		{`displayName pr or userName ew ".com"`, true},
+		{`emails[type eq "work"]`, true},
+		{`emails[type eq "home"]`, false},
+		{`emails.value co "example"`, true},
+	}
+
+	for _, tc := range testCases {
+		filter, err := scim.ParseFilter(tc.filter)
+		if assert.Nil(t, err, tc.filter) {
+			assert.Equal(t, tc.matches, filter.Match(member), tc.filter)
+		}
+	}
+}
+
+func TestFilterSQL(t *testing.T) {
+	testCases := []struct {
+		filter string
+		clause string
+		args   []interface{}
+	}{
+		{
+			`userName eq "member@example.com"`,
+			"lower(username) = lower(?)",
+			[]interface{}{"member@example.com"},
+		},
+		{
+			`emails co "50%_off" and active eq false`,
+			"(lower(username) LIKE ? AND active = ?)",
+			[]interface{}{`%50\%\_off%`, false},
+		},
+		{
+			`not (userName sw "a" or userName pr)`,
+			"NOT ((lower(username) LIKE ? OR (username IS NOT NULL AND username <> '')))",
+			[]interface{}{"a%"},
+		},
+		{
+			`emails[value ew "@example.com"]`,
+			"lower(username) LIKE ?",
+			[]interface{}{"%@example.com"},
+		},
+		{
+			// nothing is referenced by an invalid ID
+			`id eq "unknown"`,
+			"FALSE",
+			nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		filter, err := scim.ParseFilter(tc.filter)
+		if !assert.Nil(t, err, tc.filter) {
+			continue
+		}
+		clause, args, err := filter.SQL(testAttributes)
+		if assert.Nil(t, err, tc.filter) {
+			assert.Equal(t, tc.clause, clause, tc.filter)
+			assert.Equal(t, tc.args, args, tc.filter)
+		}
+	}
+
+	for _, invalid := range []string{
+		`displayName eq "unknown attribute"`,
+		`active eq "yes"`,
+		`active gt true`,
+		`meta.created gt "yesterday"`,
+	} {
+		filter, err := scim.ParseFilter(invalid)
+		if assert.Nil(t, err, invalid) {
+			_, _, err = filter.SQL(testAttributes)
+			assert.Equal(t, scim.ErrInvalidFilter, err, invalid)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package scim
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/rbac"
+	"github.com/resonatecoop/user-api/model"
+)
+
+// defaultGroupType is the type of groups provisioned without one
+const defaultGroupType = "persona"
+
+// groupTypeColumn is the name of the type of a group
+const groupTypeColumn = `(SELECT gt.name FROM group_types AS gt WHERE gt.id = user_group.type_id)`
+
+// groupAttributes are the group attributes which may be filtered on
+var groupAttributes = map[string]Attribute{
+	"id":                {Column: "user_group.id", Type: ReferenceAttribute},
+	"displayname":       {Column: "user_group.display_name"},
+	"members.value":     {Column: "user_group.owner_id", Type: ReferenceAttribute},
+	"externalid":        {Column: `(SELECT e.external_id FROM scim_external_ids AS e WHERE e.resource_type = 'Group' AND e.resource_id = user_group.id)`},
+	"meta.created":      {Column: "user_group.created_at", Type: DateTimeAttribute},
+	"meta.lastmodified": {Column: "COALESCE(user_group.updated_at, user_group.created_at)", Type: DateTimeAttribute},
+	strings.ToLower(GroupExtensionSchema) + ":grouptype":   {Column: groupTypeColumn},
+	strings.ToLower(GroupExtensionSchema) + ":description": {Column: "user_group.description"},
+	strings.ToLower(GroupExtensionSchema) + ":shortbio":    {Column: "user_group.short_bio"},
+	strings.ToLower(GroupExtensionSchema) + ":groupemail":  {Column: "user_group.group_email"},
+}
+
+// ListGroups returns a page of the groups owned by users of the request
+// realm matching the filter, startIndex is 1-based and a count of 0 only
+// returns the total
+func (s *Service) ListGroups(ctx context.Context, filter *Filter, startIndex, count int) (*ListResponse, error) {
+	groups := []*UserGroup{}
+
+	q := s.inRealm(ctx, s.db.NewSelect().Model(&groups), "user_group.owner_id")
+
+	if filter != nil {
+		clause, args, err := filter.SQL(groupAttributes)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where(clause, args...)
+	}
+
+	startIndex, count = s.page(startIndex, count)
+
+	var (
+		total int
+		err   error
+	)
+
+	if count == 0 {
+		total, err = q.Count(ctx)
+	} else {
+		total, err = q.
+			Order("user_group.created_at", "user_group.id").
+			Offset(startIndex - 1).
+			Limit(count).
+			ScanAndCount(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]*Group, len(groups))
+	for i, group := range groups {
+		if resources[i], err = s.newGroup(ctx, group); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ListResponse{
+		Schemas:      []string{ListResponseSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}, nil
+}
+
+// GetGroup returns a group owned by a user of the request realm
+func (s *Service) GetGroup(ctx context.Context, id string) (*Group, error) {
+	group, err := s.findGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.newGroup(ctx, group)
+}
+
+// CreateGroup creates a group owned by its single member
+func (s *Service) CreateGroup(ctx context.Context, resource *Group) (*Group, error) {
+	group := &UserGroup{ID: uuid.New()}
+
+	if err := s.applyGroup(ctx, group, resource); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.NewInsert().
+		Model(group).
+		Column("id", "display_name", "description", "short_bio", "group_email", "address_id", "type_id", "owner_id").
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	if err = s.setExternalID(ctx, tx, ResourceGroup, resource.ExternalID, group.ID); err != nil {
+		tx.Rollback() // rollback the transaction
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.newGroup(ctx, group)
+}
+
+// ReplaceGroup replaces the attributes of a group
+func (s *Service) ReplaceGroup(ctx context.Context, id string, resource *Group) (*Group, error) {
+	group, err := s.findGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.updateGroup(ctx, group, resource); err != nil {
+		return nil, err
+	}
+
+	return s.newGroup(ctx, group)
+}
+
+// PatchGroup applies PATCH operations to a group
+func (s *Service) PatchGroup(ctx context.Context, id string, ops []PatchOperation) (*Group, error) {
+	group, err := s.findGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	resource, err := s.newGroup(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = Patch(resource, ops); err != nil {
+		return nil, err
+	}
+
+	if err = s.updateGroup(ctx, group, resource); err != nil {
+		return nil, err
+	}
+
+	return s.newGroup(ctx, group)
+}
+
+// DeleteGroup deletes a group along with its external ID
+func (s *Service) DeleteGroup(ctx context.Context, id string) error {
+	group, err := s.findGroup(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// will set deleted_at to current time using soft delete
+	if _, err = tx.NewDelete().Model(group).WherePK().Exec(ctx); err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	if err = s.setExternalID(ctx, tx, ResourceGroup, "", group.ID); err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// findGroup returns a group owned by a user of the request realm
+func (s *Service) findGroup(ctx context.Context, id string) (*UserGroup, error) {
+	groupID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrGroupNotFound
+	}
+
+	group := new(UserGroup)
+
+	err = s.inRealm(ctx, s.db.NewSelect().Model(group), "user_group.owner_id").
+		Where("user_group.id = ?", groupID).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// updateGroup stores the attributes of a resource on a group
+func (s *Service) updateGroup(ctx context.Context, group *UserGroup, resource *Group) error {
+	if err := s.applyGroup(ctx, group, resource); err != nil {
+		return err
+	}
+
+	group.UpdatedAt = time.Now().UTC()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NewUpdate().
+		Model(group).
+		Column("display_name", "description", "short_bio", "group_email", "type_id", "owner_id", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	if err = s.setExternalID(ctx, tx, ResourceGroup, resource.ExternalID, group.ID); err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// applyGroup validates the attributes of a resource and copies them to a
+// group. Display names are unique across every group, deleted ones too,
+// and users holding the user role own a single group, as in the user api.
+func (s *Service) applyGroup(ctx context.Context, group *UserGroup, resource *Group) error {
+	if strings.TrimSpace(resource.DisplayName) == "" {
+		return ErrDisplayNameRequired
+	}
+
+	if len(resource.Members) != 1 || (resource.Members[0].Type != "" && resource.Members[0].Type != ResourceUser) {
+		return ErrGroupOwner
+	}
+
+	owner, err := s.findUser(ctx, resource.Members[0].Value)
+	if err == ErrUserNotFound {
+		return ErrGroupOwner
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = s.checkExternalID(ctx, ResourceGroup, resource.ExternalID, group.ID); err != nil {
+		return err
+	}
+
+	taken, err := s.db.NewSelect().
+		Model((*UserGroup)(nil)).
+		WhereAllWithDeleted().
+		Where("lower(display_name) = lower(?)", resource.DisplayName).
+		Where("id <> ?", group.ID).
+		Exists(ctx)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrDisplayNameTaken
+	}
+
+	if owner.ID != group.OwnerID {
+		if err = s.checkGroupLimit(ctx, owner); err != nil {
+			return err
+		}
+	}
+
+	extension := resource.Extension
+	if extension == nil {
+		extension = new(GroupExtension)
+	}
+
+	groupType := extension.GroupType
+	if groupType == "" {
+		groupType = defaultGroupType
+	}
+
+	typeID, err := s.findGroupTypeID(ctx, groupType)
+	if err != nil {
+		return err
+	}
+
+	group.DisplayName = resource.DisplayName
+	group.Description = extension.Description
+	group.ShortBio = extension.ShortBio
+	group.GroupEmail = extension.GroupEmail
+	group.TypeID = typeID
+	group.OwnerID = owner.ID
+
+	return nil
+}
+
+// checkGroupLimit returns ErrGroupLimit if the owner holds the user role
+// and already owns a group
+func (s *Service) checkGroupLimit(ctx context.Context, owner *model.User) error {
+	roleIDs, err := s.oauthService.GetRBACService().UserRoleIDs(ctx, owner)
+	if err != nil {
+		return err
+	}
+
+	if rbac.PrimaryRoleID(roleIDs) != int32(model.UserRole) {
+		return nil
+	}
+
+	owns, err := s.db.NewSelect().
+		Model((*UserGroup)(nil)).
+		Where("owner_id = ?", owner.ID).
+		Exists(ctx)
+	if err != nil {
+		return err
+	}
+	if owns {
+		return ErrGroupLimit
+	}
+
+	return nil
+}
+
+// findGroupTypeID returns the ID of a group type given its name
+func (s *Service) findGroupTypeID(ctx context.Context, name string) (uuid.UUID, error) {
+	groupType := new(model.GroupType)
+
+	err := s.db.NewSelect().
+		Model(groupType).
+		Column("id").
+		Where("name = lower(?)", name).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, ErrUnknownGroupType
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return groupType.ID, nil
+}
+
+// newGroup returns the SCIM representation of a group
+func (s *Service) newGroup(ctx context.Context, group *UserGroup) (*Group, error) {
+	externalID, err := s.findExternalID(ctx, ResourceGroup, group.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	owner := new(model.User)
+	err = s.db.NewSelect().
+		Model(owner).
+		Column("id", "username").
+		WhereAllWithDeleted().
+		Where("id = ?", group.OwnerID).
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	groupType := new(model.GroupType)
+	err = s.db.NewSelect().
+		Model(groupType).
+		Column("name").
+		Where("id = ?", group.TypeID).
+		Limit(1).
+		Scan(ctx)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return &Group{
+		Schemas:     []string{GroupSchema, GroupExtensionSchema},
+		ID:          group.ID.String(),
+		ExternalID:  externalID,
+		DisplayName: group.DisplayName,
+		Members: []Value{{
+			Value:   owner.ID.String(),
+			Display: owner.Username,
+			Type:    ResourceUser,
+			Ref:     s.location(ctx, "/Users/"+owner.ID.String()),
+		}},
+		Extension: &GroupExtension{
+			GroupType:   groupType.Name,
+			Description: group.Description,
+			ShortBio:    group.ShortBio,
+			GroupEmail:  group.GroupEmail,
+		},
+		Meta: s.newMeta(ctx, ResourceGroup, "/Groups/"+group.ID.String(), group.CreatedAt, group.UpdatedAt),
+	}, nil
+}
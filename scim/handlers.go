@@ -0,0 +1,474 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/rbac"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/user-api/model"
+)
+
+// ErrorResponse is the body of error responses
+type ErrorResponse struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// newErrorResponse describes an error the way SCIM clients expect
+func newErrorResponse(err error) *ErrorResponse {
+	status, scimType := errorStatus(err)
+
+	return &ErrorResponse{
+		Schemas:  []string{ErrorSchema},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   err.Error(),
+	}
+}
+
+// errorStatus maps errors to status codes and SCIM error types
+func errorStatus(err error) (int, string) {
+	switch err {
+	case ErrUnauthorized:
+		return http.StatusUnauthorized, ""
+	case ErrForbidden, ErrPrivilegedUser, ErrNotProvisioned:
+		return http.StatusForbidden, ""
+	case ErrUserNotFound, ErrGroupNotFound:
+		return http.StatusNotFound, ""
+	case ErrInvalidFilter:
+		return http.StatusBadRequest, "invalidFilter"
+	case ErrInvalidPath:
+		return http.StatusBadRequest, "invalidPath"
+	case ErrNoTarget:
+		return http.StatusBadRequest, "noTarget"
+	case ErrInvalidSyntax, ErrUnsupportedOperation, ErrUnknownBulkID:
+		return http.StatusBadRequest, "invalidSyntax"
+	case ErrInvalidUserName, ErrDisplayNameRequired, ErrGroupOwner, ErrGroupLimit,
+		ErrUnknownGroupType, ErrUnknownRole, ErrPrivilegedRole, ErrLockedUser,
+		oauth.ErrCountryNotFound, rbac.ErrRoleNotFound:
+		return http.StatusBadRequest, "invalidValue"
+	case oauth.ErrUsernameTaken, ErrDisplayNameTaken, ErrExternalIDTaken:
+		return http.StatusConflict, "uniqueness"
+	case ErrTooManyOperations, ErrPayloadTooLarge:
+		return http.StatusRequestEntityTooLarge, ""
+	}
+	return http.StatusInternalServerError, ""
+}
+
+// writeJSON writes a SCIM response
+func writeJSON(w http.ResponseWriter, v interface{}, status int) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a SCIM error response
+func (s *Service) writeError(w http.ResponseWriter, err error) {
+	res := newErrorResponse(err)
+
+	if err == ErrUnauthorized {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", s.cnf.Hostname))
+	}
+
+	status, _ := strconv.Atoi(res.Status)
+	writeJSON(w, res, status)
+}
+
+// authorize checks the request carries a client credentials token with the
+// SCIM scope, issued to a client of the request realm which was explicitly
+// allowed the scope
+func (s *Service) authorize(r *http.Request) error {
+	token, err := util.ParseBearerToken(r)
+	if err != nil {
+		return ErrUnauthorized
+	}
+
+	accessToken, err := s.oauthService.Authenticate(string(token))
+	if err != nil {
+		return ErrUnauthorized
+	}
+
+	if accessToken.UserID != uuid.Nil || !util.StringInSlice(Scope, strings.Fields(accessToken.Scope)) {
+		return ErrForbidden
+	}
+
+	client := new(model.Client)
+	err = s.db.NewSelect().
+		Model(client).
+		Where("id = ?", accessToken.ClientID).
+		Limit(1).
+		Scan(r.Context())
+	if err != nil {
+		return ErrUnauthorized
+	}
+
+	if err = s.oauthService.GetRealmService().CheckClient(r.Context(), client); err != nil {
+		return ErrForbidden
+	}
+
+	// Provisioning is never part of the default scope, it has to be granted
+	// explicitly
+	scopes, err := s.oauthService.GetClientScopes(client)
+	if err != nil {
+		return err
+	}
+	if !util.StringInSlice(Scope, scopes) {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// listParams returns the filter and paging parameters of a query
+func (s *Service) listParams(r *http.Request) (*Filter, int, int, error) {
+	query := r.URL.Query()
+
+	var filter *Filter
+	if value := query.Get("filter"); value != "" {
+		var err error
+		if filter, err = ParseFilter(value); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+
+	startIndex, count := 1, s.cnf.SCIM.MaxResults
+
+	if value := query.Get("startIndex"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, 0, 0, ErrInvalidSyntax
+		}
+		startIndex = n
+	}
+
+	if value := query.Get("count"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, 0, 0, ErrInvalidSyntax
+		}
+		count = n
+	}
+
+	return filter, startIndex, count, nil
+}
+
+// Lists users (GET /scim/v2/Users)
+func (s *Service) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	filter, startIndex, count, err := s.listParams(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	res, err := s.ListUsers(r.Context(), filter, startIndex, count)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	writeJSON(w, res, http.StatusOK)
+}
+
+// Returns a user (GET /scim/v2/Users/{id})
+func (s *Service) getUserHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	user, err := s.GetUser(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	writeJSON(w, user, http.StatusOK)
+}
+
+// Provisions a user (POST /scim/v2/Users)
+func (s *Service) createUserHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	req := new(User)
+	if err := decodeBody(r.Body, req); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	user, err := s.CreateUser(r.Context(), req)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", user.Meta.Location)
+	writeJSON(w, user, http.StatusCreated)
+}
+
+// Replaces a user (PUT /scim/v2/Users/{id})
+func (s *Service) replaceUserHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	req := new(User)
+	if err := decodeBody(r.Body, req); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	user, err := s.ReplaceUser(r.Context(), mux.Vars(r)["id"], req)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	writeJSON(w, user, http.StatusOK)
+}
+
+// Patches a user (PATCH /scim/v2/Users/{id})
+func (s *Service) patchUserHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	req := new(PatchRequest)
+	if err := decodeBody(r.Body, req); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	user, err := s.PatchUser(r.Context(), mux.Vars(r)["id"], req.Operations)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	writeJSON(w, user, http.StatusOK)
+}
+
+// Deprovisions a user (DELETE /scim/v2/Users/{id})
+func (s *Service) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	if err := s.DeleteUser(r.Context(), mux.Vars(r)["id"]); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Lists groups (GET /scim/v2/Groups)
+func (s *Service) listGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	filter, startIndex, count, err := s.listParams(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	res, err := s.ListGroups(r.Context(), filter, startIndex, count)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	writeJSON(w, res, http.StatusOK)
+}
+
+// Returns a group (GET /scim/v2/Groups/{id})
+func (s *Service) getGroupHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	group, err := s.GetGroup(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	writeJSON(w, group, http.StatusOK)
+}
+
+// Creates a group (POST /scim/v2/Groups)
+func (s *Service) createGroupHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	req := new(Group)
+	if err := decodeBody(r.Body, req); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	group, err := s.CreateGroup(r.Context(), req)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", group.Meta.Location)
+	writeJSON(w, group, http.StatusCreated)
+}
+
+// Replaces a group (PUT /scim/v2/Groups/{id})
+func (s *Service) replaceGroupHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	req := new(Group)
+	if err := decodeBody(r.Body, req); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	group, err := s.ReplaceGroup(r.Context(), mux.Vars(r)["id"], req)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	writeJSON(w, group, http.StatusOK)
+}
+
+// Patches a group (PATCH /scim/v2/Groups/{id})
+func (s *Service) patchGroupHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	req := new(PatchRequest)
+	if err := decodeBody(r.Body, req); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	group, err := s.PatchGroup(r.Context(), mux.Vars(r)["id"], req.Operations)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	writeJSON(w, group, http.StatusOK)
+}
+
+// Deletes a group (DELETE /scim/v2/Groups/{id})
+func (s *Service) deleteGroupHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	if err := s.DeleteGroup(r.Context(), mux.Vars(r)["id"]); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Applies a bulk request (POST /scim/v2/Bulk)
+func (s *Service) bulkHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	maxSize := int64(s.cnf.SCIM.BulkMaxPayloadSize)
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxSize+1))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if int64(len(body)) > maxSize {
+		s.writeError(w, ErrPayloadTooLarge)
+		return
+	}
+
+	req := new(BulkRequest)
+	if err = decode(body, req); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	res, err := s.Bulk(r.Context(), req)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	writeJSON(w, res, http.StatusOK)
+}
+
+// Describes the features supported (GET /scim/v2/ServiceProviderConfig)
+func (s *Service) serviceProviderConfigHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	writeJSON(w, s.ServiceProviderConfig(r.Context()), http.StatusOK)
+}
+
+// Lists resource types (GET /scim/v2/ResourceTypes)
+func (s *Service) resourceTypesHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	resourceTypes := s.ResourceTypes(r.Context())
+
+	writeJSON(w, &ListResponse{
+		Schemas:      []string{ListResponseSchema},
+		TotalResults: len(resourceTypes),
+		StartIndex:   1,
+		ItemsPerPage: len(resourceTypes),
+		Resources:    resourceTypes,
+	}, http.StatusOK)
+}
+
+// decodeBody decodes the body of a request
+func decodeBody(body io.Reader, v interface{}) error {
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		return ErrInvalidSyntax
+	}
+	return nil
+}
@@ -0,0 +1,369 @@
+package scim
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PatchOperation is an operation of a PATCH request
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// PatchRequest is the body of a PATCH request
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// pathPattern matches attr, attr.sub, attr[filter] and attr[filter].sub
+var pathPattern = regexp.MustCompile(`^([A-Za-z$][\w$-]*)(?:\[(.+)\])?(?:\.([A-Za-z$][\w$-]*))?$`)
+
+// attributePath is a parsed PATCH path
+type attributePath struct {
+	// extension is true when the path targets the group extension
+	extension bool
+	attr      string
+	filter    *Filter
+	sub       string
+}
+
+// Patch applies PATCH operations to a resource, e.g. a *User or a *Group,
+// as described in RFC 7644 section 3.5.2. Attribute names are matched
+// ignoring case.
+func Patch(resource interface{}, ops []PatchOperation) error {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return err
+	}
+
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	for _, op := range ops {
+		if err := applyOperation(doc, op); err != nil {
+			return err
+		}
+	}
+
+	// Some clients send booleans as strings, e.g. "active": "False"
+	if key := findKey(doc, "active"); doc[key] != nil {
+		if s, ok := doc[key].(string); ok {
+			active, err := strconv.ParseBool(s)
+			if err != nil {
+				return ErrInvalidSyntax
+			}
+			doc[key] = active
+		}
+	}
+
+	data, err = json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	// Start from scratch so removed attributes do not linger
+	v := reflect.ValueOf(resource).Elem()
+	v.Set(reflect.Zero(v.Type()))
+
+	if err := json.Unmarshal(data, resource); err != nil {
+		return ErrInvalidSyntax
+	}
+
+	return nil
+}
+
+// applyOperation applies a single operation to a resource decoded from JSON
+func applyOperation(doc map[string]interface{}, op PatchOperation) error {
+	operation := strings.ToLower(op.Op)
+
+	var value interface{}
+	if len(op.Value) > 0 {
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return ErrInvalidSyntax
+		}
+	}
+
+	switch operation {
+	case "add", "replace":
+		if value == nil {
+			return ErrInvalidSyntax
+		}
+
+		if op.Path != "" {
+			return applyPath(doc, operation, op.Path, value)
+		}
+
+		// Without a path the value holds the attributes to change
+		attributes, ok := value.(map[string]interface{})
+		if !ok {
+			return ErrInvalidSyntax
+		}
+
+		for key, v := range attributes {
+			if err := applyPath(doc, operation, key, v); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case "remove":
+		if op.Path == "" {
+			return ErrNoTarget
+		}
+		return applyPath(doc, operation, op.Path, value)
+	}
+
+	return ErrInvalidSyntax
+}
+
+// parsePath parses a PATCH path, the URN of the core schemas is optional
+func parsePath(path string) (*attributePath, error) {
+	lower := strings.ToLower(path)
+	extension := strings.ToLower(GroupExtensionSchema)
+
+	parsed := new(attributePath)
+
+	switch {
+	case lower == extension:
+		return &attributePath{attr: GroupExtensionSchema}, nil
+	case strings.HasPrefix(lower, extension+":"):
+		parsed.extension = true
+		path = path[len(extension)+1:]
+	default:
+		path = path[len(path)-len(normalizeAttribute(path)):]
+	}
+
+	matches := pathPattern.FindStringSubmatch(path)
+	if matches == nil {
+		return nil, ErrInvalidPath
+	}
+
+	parsed.attr, parsed.sub = matches[1], matches[3]
+
+	if matches[2] != "" {
+		filter, err := ParseFilter(matches[2])
+		if err != nil {
+			return nil, ErrInvalidPath
+		}
+		parsed.filter = filter
+	}
+
+	return parsed, nil
+}
+
+// applyPath applies an operation to the attribute a path points to
+func applyPath(doc map[string]interface{}, operation, path string, value interface{}) error {
+	target, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+
+	container := doc
+
+	if target.extension {
+		key := findKey(doc, GroupExtensionSchema)
+		extension, ok := doc[key].(map[string]interface{})
+		if !ok {
+			if operation == "remove" {
+				return nil
+			}
+			extension = map[string]interface{}{}
+			doc[key] = extension
+		}
+		container = extension
+	}
+
+	key := findKey(container, target.attr)
+
+	if target.filter != nil {
+		return applyFiltered(container, key, operation, target, value)
+	}
+
+	switch operation {
+	case "add":
+		if target.sub != "" {
+			parent, ok := container[key].(map[string]interface{})
+			if !ok {
+				parent = map[string]interface{}{}
+				container[key] = parent
+			}
+			subKey := findKey(parent, target.sub)
+			parent[subKey] = addValue(parent[subKey], value)
+			return nil
+		}
+		container[key] = addValue(container[key], value)
+	case "replace":
+		if target.sub != "" {
+			parent, ok := container[key].(map[string]interface{})
+			if !ok {
+				parent = map[string]interface{}{}
+				container[key] = parent
+			}
+			parent[findKey(parent, target.sub)] = value
+			return nil
+		}
+		container[key] = replaceValue(container[key], value)
+	case "remove":
+		if target.sub != "" {
+			switch parent := container[key].(type) {
+			case map[string]interface{}:
+				delete(parent, findKey(parent, target.sub))
+			case []interface{}:
+				for _, item := range parent {
+					if m, ok := item.(map[string]interface{}); ok {
+						delete(m, findKey(m, target.sub))
+					}
+				}
+			}
+			return nil
+		}
+
+		items, isArray := container[key].([]interface{})
+		if value == nil || !isArray {
+			delete(container, key)
+			return nil
+		}
+
+		// Remove the values listed, e.g. members to drop from a group
+		container[key] = removeValues(items, value)
+	}
+
+	return nil
+}
+
+// applyFiltered applies an operation to the values of a multi-valued
+// attribute matching the filter of a path
+func applyFiltered(container map[string]interface{}, key, operation string, target *attributePath, value interface{}) error {
+	items, _ := container[key].([]interface{})
+
+	kept := []interface{}{}
+	matched := false
+
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok || !target.filter.Match(m) {
+			kept = append(kept, item)
+			continue
+		}
+
+		matched = true
+
+		switch {
+		case operation == "remove" && target.sub == "":
+			continue
+		case operation == "remove":
+			delete(m, findKey(m, target.sub))
+		case target.sub != "":
+			subKey := findKey(m, target.sub)
+			if operation == "add" {
+				m[subKey] = addValue(m[subKey], value)
+			} else {
+				m[subKey] = value
+			}
+		case operation == "add":
+			attributes, ok := value.(map[string]interface{})
+			if !ok {
+				return ErrInvalidSyntax
+			}
+			for k, v := range attributes {
+				m[findKey(m, k)] = v
+			}
+		default:
+			item = value
+		}
+
+		kept = append(kept, item)
+	}
+
+	if !matched && operation != "remove" {
+		return ErrNoTarget
+	}
+
+	container[key] = kept
+
+	return nil
+}
+
+// addValue adds a value to an attribute, values are appended to
+// multi-valued attributes and merged into complex ones
+func addValue(existing, value interface{}) interface{} {
+	if items, ok := existing.([]interface{}); ok {
+		values, isArray := value.([]interface{})
+		if !isArray {
+			values = []interface{}{value}
+		}
+		for _, v := range values {
+			if !containsValue(items, v) {
+				items = append(items, v)
+			}
+		}
+		return items
+	}
+
+	return replaceValue(existing, value)
+}
+
+// replaceValue replaces a value, the sub-attributes of complex attributes
+// are replaced one by one
+func replaceValue(existing, value interface{}) interface{} {
+	current, ok := existing.(map[string]interface{})
+	attributes, isMap := value.(map[string]interface{})
+	if !ok || !isMap {
+		return value
+	}
+
+	for k, v := range attributes {
+		current[findKey(current, k)] = v
+	}
+
+	return current
+}
+
+// removeValues removes the items of a multi-valued attribute having the
+// same value as any of the values given
+func removeValues(items []interface{}, value interface{}) []interface{} {
+	values, ok := value.([]interface{})
+	if !ok {
+		values = []interface{}{value}
+	}
+
+	kept := []interface{}{}
+	for _, item := range items {
+		if !containsValue(values, item) {
+			kept = append(kept, item)
+		}
+	}
+
+	return kept
+}
+
+// containsValue returns true if a list holds an item with the same value,
+// items of multi-valued attributes are compared by their value
+func containsValue(items []interface{}, value interface{}) bool {
+	for _, item := range items {
+		if reflect.DeepEqual(item, value) {
+			return true
+		}
+
+		a, aok := item.(map[string]interface{})
+		b, bok := value.(map[string]interface{})
+		if !aok || !bok {
+			continue
+		}
+
+		av, bv := a[findKey(a, "value")], b[findKey(b, "value")]
+		if av != nil && reflect.DeepEqual(av, bv) {
+			return true
+		}
+	}
+
+	return false
+}
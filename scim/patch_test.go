@@ -0,0 +1,96 @@
+package scim_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/resonatecoop/id/scim"
+	"github.com/stretchr/testify/assert"
+)
+
+func operation(op, path string, value interface{}) scim.PatchOperation {
+	operation := scim.PatchOperation{Op: op, Path: path}
+	if value != nil {
+		operation.Value, _ = json.Marshal(value)
+	}
+	return operation
+}
+
+func TestPatchUser(t *testing.T) {
+	active := true
+	user := &scim.User{
+		UserName: "member@example.com",
+		Active:   &active,
+		Name:     &scim.Name{GivenName: "Jane"},
+		Roles:    []scim.Value{{Value: "user"}},
+	}
+
+	err := scim.Patch(user, []scim.PatchOperation{
+		operation("Replace", "name.familyName", "Doe"),
+		operation("add", "roles", []interface{}{map[string]interface{}{"value": "artist"}}),
+		operation("remove", `roles[value eq "user"]`, nil),
+		// some clients send booleans as strings
+		operation("replace", "", map[string]interface{}{"active": "False", "displayName": "Jane Doe"}),
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, "member@example.com", user.UserName)
+		assert.Equal(t, &scim.Name{GivenName: "Jane", FamilyName: "Doe"}, user.Name)
+		assert.Equal(t, []scim.Value{{Value: "artist"}}, user.Roles)
+		assert.Equal(t, "Jane Doe", user.DisplayName)
+		if assert.NotNil(t, user.Active) {
+			assert.False(t, *user.Active)
+		}
+	}
+
+	// Removing an attribute drops it
+	err = scim.Patch(user, []scim.PatchOperation{
+		operation("remove", "urn:ietf:params:scim:schemas:core:2.0:User:name", nil),
+	})
+	if assert.Nil(t, err) {
+		assert.Nil(t, user.Name)
+		assert.Equal(t, "Jane Doe", user.DisplayName)
+	}
+}
+
+func TestPatchGroup(t *testing.T) {
+	group := &scim.Group{
+		DisplayName: "Band",
+		Members:     []scim.Value{{Value: "1"}},
+		Extension:   &scim.GroupExtension{GroupType: "band"},
+	}
+
+	err := scim.Patch(group, []scim.PatchOperation{
+		operation("add", "members", []interface{}{map[string]interface{}{"value": "2"}}),
+		operation("remove", "members", []interface{}{map[string]interface{}{"value": "1"}}),
+		operation("replace", "urn:resonate:params:scim:schemas:extension:2.0:Group:shortBio", "Loud"),
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []scim.Value{{Value: "2"}}, group.Members)
+		assert.Equal(t, &scim.GroupExtension{GroupType: "band", ShortBio: "Loud"}, group.Extension)
+	}
+}
+
+func TestPatchErrors(t *testing.T) {
+	testCases := []struct {
+		op  scim.PatchOperation
+		err error
+	}{
+		{operation("move", "displayName", "x"), scim.ErrInvalidSyntax},
+		{operation("replace", "displayName", nil), scim.ErrInvalidSyntax},
+		{operation("add", "", "not an object"), scim.ErrInvalidSyntax},
+		{operation("remove", "", nil), scim.ErrNoTarget},
+		{operation("replace", "members[", "x"), scim.ErrInvalidPath},
+		{operation("replace", `members[value eq "3"].display`, "x"), scim.ErrNoTarget},
+		{operation("replace", "displayName", 42), scim.ErrInvalidSyntax},
+	}
+
+	for _, tc := range testCases {
+		group := &scim.Group{DisplayName: "Band", Members: []scim.Value{{Value: "1"}}}
+		assert.Equal(t, tc.err, scim.Patch(group, []scim.PatchOperation{tc.op}), tc.op.Op+" "+tc.op.Path)
+	}
+
+	// Removing values which do not exist is not an error
+	group := &scim.Group{DisplayName: "Band", Members: []scim.Value{{Value: "1"}}}
+	assert.Nil(t, scim.Patch(group, []scim.PatchOperation{operation("remove", `members[value eq "3"]`, nil)}))
+	assert.Len(t, group.Members, 1)
+}
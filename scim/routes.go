@@ -0,0 +1,108 @@
+package scim
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/resonatecoop/id/util/routes"
+)
+
+// RegisterRoutes registers route handlers for the SCIM service
+func (s *Service) RegisterRoutes(router *mux.Router, prefix string) {
+	subRouter := router.PathPrefix(prefix).Subrouter()
+	routes.AddRoutes(s.GetRoutes(), subRouter)
+}
+
+// GetRoutes returns []routes.Route slice for the SCIM service
+func (s *Service) GetRoutes() []routes.Route {
+	return []routes.Route{
+		{
+			Name:        "scim_users_list",
+			Method:      "GET",
+			Pattern:     "/Users",
+			HandlerFunc: s.listUsersHandler,
+		},
+		{
+			Name:        "scim_users_create",
+			Method:      "POST",
+			Pattern:     "/Users",
+			HandlerFunc: s.createUserHandler,
+		},
+		{
+			Name:        "scim_users_get",
+			Method:      "GET",
+			Pattern:     "/Users/{id}",
+			HandlerFunc: s.getUserHandler,
+		},
+		{
+			Name:        "scim_users_replace",
+			Method:      "PUT",
+			Pattern:     "/Users/{id}",
+			HandlerFunc: s.replaceUserHandler,
+		},
+		{
+			Name:        "scim_users_patch",
+			Method:      "PATCH",
+			Pattern:     "/Users/{id}",
+			HandlerFunc: s.patchUserHandler,
+		},
+		{
+			Name:        "scim_users_delete",
+			Method:      "DELETE",
+			Pattern:     "/Users/{id}",
+			HandlerFunc: s.deleteUserHandler,
+		},
+		{
+			Name:        "scim_groups_list",
+			Method:      "GET",
+			Pattern:     "/Groups",
+			HandlerFunc: s.listGroupsHandler,
+		},
+		{
+			Name:        "scim_groups_create",
+			Method:      "POST",
+			Pattern:     "/Groups",
+			HandlerFunc: s.createGroupHandler,
+		},
+		{
+			Name:        "scim_groups_get",
+			Method:      "GET",
+			Pattern:     "/Groups/{id}",
+			HandlerFunc: s.getGroupHandler,
+		},
+		{
+			Name:        "scim_groups_replace",
+			Method:      "PUT",
+			Pattern:     "/Groups/{id}",
+			HandlerFunc: s.replaceGroupHandler,
+		},
+		{
+			Name:        "scim_groups_patch",
+			Method:      "PATCH",
+			Pattern:     "/Groups/{id}",
+			HandlerFunc: s.patchGroupHandler,
+		},
+		{
+			Name:        "scim_groups_delete",
+			Method:      "DELETE",
+			Pattern:     "/Groups/{id}",
+			HandlerFunc: s.deleteGroupHandler,
+		},
+		{
+			Name:        "scim_bulk",
+			Method:      "POST",
+			Pattern:     "/Bulk",
+			HandlerFunc: s.bulkHandler,
+		},
+		{
+			Name:        "scim_service_provider_config",
+			Method:      "GET",
+			Pattern:     "/ServiceProviderConfig",
+			HandlerFunc: s.serviceProviderConfigHandler,
+		},
+		{
+			Name:        "scim_resource_types",
+			Method:      "GET",
+			Pattern:     "/ResourceTypes",
+			HandlerFunc: s.resourceTypesHandler,
+		},
+	}
+}
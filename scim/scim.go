@@ -0,0 +1,218 @@
+package scim
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/uptrace/bun"
+)
+
+// Schemas of the resources and messages of the API
+const (
+	// UserSchema is the core schema of users
+	UserSchema = "urn:ietf:params:scim:schemas:core:2.0:User"
+	// GroupSchema is the core schema of groups
+	GroupSchema = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	// GroupExtensionSchema holds the user api attributes of groups
+	GroupExtensionSchema = "urn:resonate:params:scim:schemas:extension:2.0:Group"
+	// ListResponseSchema is the schema of query responses
+	ListResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	// PatchOpSchema is the schema of PATCH requests
+	PatchOpSchema = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	// BulkRequestSchema is the schema of bulk requests
+	BulkRequestSchema = "urn:ietf:params:scim:api:messages:2.0:BulkRequest"
+	// BulkResponseSchema is the schema of bulk responses
+	BulkResponseSchema = "urn:ietf:params:scim:api:messages:2.0:BulkResponse"
+	// ErrorSchema is the schema of error responses
+	ErrorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+	// ServiceProviderConfigSchema is the schema of the service provider configuration
+	ServiceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	// ResourceTypeSchema is the schema of resource types
+	ResourceTypeSchema = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+)
+
+// Scope is the scope client credentials tokens need to use the API
+const Scope = oauth.SCIMScope
+
+// ContentType is the media type of SCIM requests and responses
+const ContentType = "application/scim+json"
+
+// basePath is where the API is served, resource locations are built from it
+const basePath = "/scim/v2"
+
+// Resource types, also used to tell external IDs apart
+const (
+	// ResourceUser ...
+	ResourceUser = "User"
+	// ResourceGroup ...
+	ResourceGroup = "Group"
+)
+
+var (
+	// ErrUnauthorized ...
+	ErrUnauthorized = errors.New("Invalid or missing access token")
+	// ErrForbidden ...
+	ErrForbidden = errors.New("The access token does not allow provisioning")
+	// ErrUserNotFound ...
+	ErrUserNotFound = errors.New("User not found")
+	// ErrGroupNotFound ...
+	ErrGroupNotFound = errors.New("Group not found")
+	// ErrInvalidSyntax ...
+	ErrInvalidSyntax = errors.New("Request body is not a valid SCIM message")
+	// ErrInvalidFilter ...
+	ErrInvalidFilter = errors.New("Invalid or unsupported filter")
+	// ErrInvalidPath ...
+	ErrInvalidPath = errors.New("Invalid or unsupported attribute path")
+	// ErrNoTarget ...
+	ErrNoTarget = errors.New("Attribute path matches no value")
+	// ErrInvalidUserName ...
+	ErrInvalidUserName = errors.New("userName must be an email address")
+	// ErrDisplayNameRequired ...
+	ErrDisplayNameRequired = errors.New("displayName is required")
+	// ErrDisplayNameTaken ...
+	ErrDisplayNameTaken = errors.New("displayName is already taken")
+	// ErrExternalIDTaken ...
+	ErrExternalIDTaken = errors.New("externalId is already taken")
+	// ErrGroupOwner ...
+	ErrGroupOwner = errors.New("Groups have exactly one member, the user owning them")
+	// ErrGroupLimit ...
+	ErrGroupLimit = errors.New("Users with the user role own a single group")
+	// ErrUnknownGroupType ...
+	ErrUnknownGroupType = errors.New("Unknown groupType")
+	// ErrUnknownRole ...
+	ErrUnknownRole = errors.New("Unknown role")
+	// ErrPrivilegedRole ...
+	ErrPrivilegedRole = errors.New("Privileged roles cannot be provisioned")
+	// ErrPrivilegedUser ...
+	ErrPrivilegedUser = errors.New("Users holding privileged roles cannot be provisioned")
+	// ErrNotProvisioned ...
+	ErrNotProvisioned = errors.New("Only users provisioned through the API can be changed")
+	// ErrLockedUser ...
+	ErrLockedUser = errors.New("Locked users are reactivated by setting a password")
+	// ErrUnsupportedOperation ...
+	ErrUnsupportedOperation = errors.New("Unsupported bulk operation")
+	// ErrUnknownBulkID ...
+	ErrUnknownBulkID = errors.New("Unknown bulkId")
+	// ErrTooManyOperations ...
+	ErrTooManyOperations = errors.New("Too many bulk operations")
+	// ErrPayloadTooLarge ...
+	ErrPayloadTooLarge = errors.New("Bulk request is too large")
+)
+
+// Meta describes a resource
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+// Name is the name of a user
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// Value is an item of a multi-valued attribute, e.g. an email address,
+// a role or a group member
+type Value struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// Address is a postal address of a user, only its country is kept
+type Address struct {
+	Country string `json:"country,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// User is the SCIM representation of a user. userName is the email address
+// the user logs in with, roles are the names of the roles the user holds and
+// groups are the groups the user owns.
+type User struct {
+	Schemas     []string  `json:"schemas"`
+	ID          string    `json:"id,omitempty"`
+	ExternalID  string    `json:"externalId,omitempty"`
+	UserName    string    `json:"userName"`
+	Name        *Name     `json:"name,omitempty"`
+	DisplayName string    `json:"displayName,omitempty"`
+	Active      *bool     `json:"active,omitempty"`
+	Password    string    `json:"password,omitempty"`
+	Emails      []Value   `json:"emails,omitempty"`
+	Addresses   []Address `json:"addresses,omitempty"`
+	Roles       []Value   `json:"roles,omitempty"`
+	Groups      []Value   `json:"groups,omitempty"`
+	Meta        *Meta     `json:"meta,omitempty"`
+}
+
+// GroupExtension holds the user api attributes of a group
+type GroupExtension struct {
+	GroupType   string `json:"groupType,omitempty"`
+	Description string `json:"description,omitempty"`
+	ShortBio    string `json:"shortBio,omitempty"`
+	GroupEmail  string `json:"groupEmail,omitempty"`
+}
+
+// Group is the SCIM representation of a user group, e.g. an artist persona
+// or a label. Its single member is the user owning it.
+type Group struct {
+	Schemas     []string        `json:"schemas"`
+	ID          string          `json:"id,omitempty"`
+	ExternalID  string          `json:"externalId,omitempty"`
+	DisplayName string          `json:"displayName"`
+	Members     []Value         `json:"members"`
+	Extension   *GroupExtension `json:"urn:resonate:params:scim:schemas:extension:2.0:Group,omitempty"`
+	Meta        *Meta           `json:"meta,omitempty"`
+}
+
+// ListResponse is a page of query results
+type ListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// UserGroup is the part of a user api user group the SCIM API manages
+type UserGroup struct {
+	bun.BaseModel `bun:"table:user_groups,alias:user_group"`
+
+	ID          uuid.UUID `bun:"type:uuid,pk"`
+	CreatedAt   time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	UpdatedAt   time.Time `bun:",nullzero"`
+	DeletedAt   time.Time `bun:",soft_delete,nullzero"`
+	DisplayName string    `bun:",notnull"`
+	Description string
+	ShortBio    string
+	GroupEmail  string
+	AddressID   uuid.UUID `bun:"type:uuid,notnull"`
+	TypeID      uuid.UUID `bun:"type:uuid,notnull"`
+	OwnerID     uuid.UUID `bun:"type:uuid,notnull"`
+}
+
+// ExternalID is the identifier a provisioning client knows a resource by
+type ExternalID struct {
+	bun.BaseModel `bun:"table:scim_external_ids"`
+
+	ResourceType string    `bun:",pk"`
+	ResourceID   uuid.UUID `bun:"type:uuid,pk"`
+	ExternalID   string    `bun:",notnull"`
+	CreatedAt    time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// ProvisionedUser marks a user created through the API, the only users it
+// may change
+type ProvisionedUser struct {
+	bun.BaseModel `bun:"table:scim_provisioned_users"`
+
+	UserID    uuid.UUID `bun:"type:uuid,pk"`
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,162 @@
+package scim
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/realm"
+	"github.com/uptrace/bun"
+)
+
+// Service struct keeps variables for reuse
+type Service struct {
+	cnf          *config.Config
+	db           *bun.DB
+	oauthService oauth.ServiceInterface
+}
+
+// NewService returns a new Service instance
+func NewService(cnf *config.Config, db *bun.DB, oauthService oauth.ServiceInterface) *Service {
+	return &Service{
+		cnf:          cnf,
+		db:           db,
+		oauthService: oauthService,
+	}
+}
+
+// GetConfig returns config.Config instance
+func (s *Service) GetConfig() *config.Config {
+	return s.cnf
+}
+
+// GetOauthService returns oauth.Service instance
+func (s *Service) GetOauthService() oauth.ServiceInterface {
+	return s.oauthService
+}
+
+// Close stops any running services
+func (s *Service) Close() {}
+
+// realm returns the realm resources are provisioned in, the realm the
+// request was routed to
+func (s *Service) realm(ctx context.Context) *realm.Realm {
+	if rlm, ok := realm.FromContext(ctx); ok {
+		return rlm
+	}
+	return realm.Default(s.cnf)
+}
+
+// location returns the absolute URL of a resource
+func (s *Service) location(ctx context.Context, path string) string {
+	return s.realm(ctx).URL(s.cnf, basePath+path)
+}
+
+// inRealm restricts a query to the users of the request realm, column is
+// the user ID column, e.g. "user".id or user_group.owner_id. Users of the
+// default realm are the ones not assigned to any realm.
+func (s *Service) inRealm(ctx context.Context, q *bun.SelectQuery, column string) *bun.SelectQuery {
+	rlm := s.realm(ctx)
+
+	if rlm.IsDefault() {
+		return q.Where("NOT EXISTS (SELECT 1 FROM realm_users AS ru WHERE ru.user_id = " + column + ")")
+	}
+
+	return q.Where("EXISTS (SELECT 1 FROM realm_users AS ru WHERE ru.user_id = "+column+" AND ru.realm_id = ?)", rlm.ID)
+}
+
+// findExternalID returns the external ID of a resource, an empty string
+// when it has none
+func (s *Service) findExternalID(ctx context.Context, resourceType string, resourceID uuid.UUID) (string, error) {
+	externalIDs := []*ExternalID{}
+
+	err := s.db.NewSelect().
+		Model(&externalIDs).
+		Where("resource_type = ?", resourceType).
+		Where("resource_id = ?", resourceID).
+		Scan(ctx)
+	if err != nil || len(externalIDs) == 0 {
+		return "", err
+	}
+
+	return externalIDs[0].ExternalID, nil
+}
+
+// checkExternalID returns ErrExternalIDTaken if another resource of the
+// same type is known by the external ID
+func (s *Service) checkExternalID(ctx context.Context, resourceType, externalID string, resourceID uuid.UUID) error {
+	if externalID == "" {
+		return nil
+	}
+
+	taken, err := s.db.NewSelect().
+		Model((*ExternalID)(nil)).
+		Where("resource_type = ?", resourceType).
+		Where("external_id = ?", externalID).
+		Where("resource_id <> ?", resourceID).
+		Exists(ctx)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrExternalIDTaken
+	}
+
+	return nil
+}
+
+// setExternalID stores, replaces or, given an empty string, removes the
+// external ID of a resource
+func (s *Service) setExternalID(ctx context.Context, db bun.IDB, resourceType, externalID string, resourceID uuid.UUID) error {
+	_, err := db.NewDelete().
+		Model((*ExternalID)(nil)).
+		Where("resource_type = ?", resourceType).
+		Where("resource_id = ?", resourceID).
+		Exec(ctx)
+	if err != nil || externalID == "" {
+		return err
+	}
+
+	_, err = db.NewInsert().
+		Model(&ExternalID{
+			ResourceType: resourceType,
+			ResourceID:   resourceID,
+			ExternalID:   externalID,
+		}).
+		Exec(ctx)
+
+	return err
+}
+
+// page clamps the paging parameters of a query, count is capped to the
+// configured maximum
+func (s *Service) page(startIndex, count int) (int, int) {
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	if count < 0 {
+		count = 0
+	}
+	if count > s.cnf.SCIM.MaxResults {
+		count = s.cnf.SCIM.MaxResults
+	}
+	return startIndex, count
+}
+
+// newMeta describes a resource, resources never updated were last modified
+// when created
+func (s *Service) newMeta(ctx context.Context, resourceType, path string, created, lastModified time.Time) *Meta {
+	if lastModified.IsZero() {
+		lastModified = created
+	}
+
+	return &Meta{
+		ResourceType: resourceType,
+		Created:      created,
+		LastModified: lastModified,
+		Location:     s.location(ctx, path),
+	}
+}
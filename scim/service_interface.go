@@ -0,0 +1,35 @@
+package scim
+
+import (
+	"context"
+
+	"github.com/gorilla/mux"
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/util/routes"
+)
+
+// ServiceInterface defines exported methods
+type ServiceInterface interface {
+	// Exported methods
+	GetConfig() *config.Config
+	GetOauthService() oauth.ServiceInterface
+	ListUsers(ctx context.Context, filter *Filter, startIndex, count int) (*ListResponse, error)
+	GetUser(ctx context.Context, id string) (*User, error)
+	CreateUser(ctx context.Context, resource *User) (*User, error)
+	ReplaceUser(ctx context.Context, id string, resource *User) (*User, error)
+	PatchUser(ctx context.Context, id string, ops []PatchOperation) (*User, error)
+	DeleteUser(ctx context.Context, id string) error
+	ListGroups(ctx context.Context, filter *Filter, startIndex, count int) (*ListResponse, error)
+	GetGroup(ctx context.Context, id string) (*Group, error)
+	CreateGroup(ctx context.Context, resource *Group) (*Group, error)
+	ReplaceGroup(ctx context.Context, id string, resource *Group) (*Group, error)
+	PatchGroup(ctx context.Context, id string, ops []PatchOperation) (*Group, error)
+	DeleteGroup(ctx context.Context, id string) error
+	Bulk(ctx context.Context, req *BulkRequest) (*BulkResponse, error)
+	ServiceProviderConfig(ctx context.Context) *ServiceProviderConfig
+	ResourceTypes(ctx context.Context) []*ResourceType
+	GetRoutes() []routes.Route
+	RegisterRoutes(router *mux.Router, prefix string)
+	Close()
+}
@@ -0,0 +1,515 @@
+package scim
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/rbac"
+	"github.com/resonatecoop/id/util"
+	"github.com/resonatecoop/user-api/model"
+	"github.com/uptrace/bun"
+)
+
+// userAttributes are the user attributes which may be filtered on
+var userAttributes = map[string]Attribute{
+	"id":                {Column: `"user".id`, Type: ReferenceAttribute},
+	"username":          {Column: `"user".username`},
+	"emails.value":      {Column: `"user".username`},
+	"emails.type":       {Column: `'work'`},
+	"emails.primary":    {Column: "TRUE", Type: BooleanAttribute},
+	"name.formatted":    {Column: `"user".full_name`},
+	"name.givenname":    {Column: `"user".first_name`},
+	"name.familyname":   {Column: `"user".last_name`},
+	"displayname":       {Column: `"user".full_name`},
+	"active":            {Column: `("user".password IS NOT NULL)`, Type: BooleanAttribute},
+	"addresses.country": {Column: `"user".country`},
+	"externalid":        {Column: `(SELECT e.external_id FROM scim_external_ids AS e WHERE e.resource_type = 'User' AND e.resource_id = "user".id)`},
+	"meta.created":      {Column: `"user".created_at`, Type: DateTimeAttribute},
+	"meta.lastmodified": {Column: `"user".updated_at`, Type: DateTimeAttribute},
+}
+
+// ListUsers returns a page of the users of the request realm matching the
+// filter, startIndex is 1-based and a count of 0 only returns the total
+func (s *Service) ListUsers(ctx context.Context, filter *Filter, startIndex, count int) (*ListResponse, error) {
+	users := []*model.User{}
+
+	q := s.inRealm(ctx, s.db.NewSelect().Model(&users), `"user".id`)
+
+	if filter != nil {
+		clause, args, err := filter.SQL(userAttributes)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where(clause, args...)
+	}
+
+	startIndex, count = s.page(startIndex, count)
+
+	var (
+		total int
+		err   error
+	)
+
+	if count == 0 {
+		total, err = q.Count(ctx)
+	} else {
+		total, err = q.
+			Order("user.created_at", "user.id").
+			Offset(startIndex - 1).
+			Limit(count).
+			ScanAndCount(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]*User, len(users))
+	for i, user := range users {
+		if resources[i], err = s.newUser(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ListResponse{
+		Schemas:      []string{ListResponseSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}, nil
+}
+
+// GetUser returns a user of the request realm
+func (s *Service) GetUser(ctx context.Context, id string) (*User, error) {
+	user, err := s.findUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.newUser(ctx, user)
+}
+
+// CreateUser provisions a user in the request realm. Provisioned users have
+// a confirmed email address and, unless a password is given, a random one
+// so they sign in through a password reset or an upstream identity provider.
+// The user and its attributes are stored in a single transaction.
+func (s *Service) CreateUser(ctx context.Context, resource *User) (*User, error) {
+	if !util.ValidateEmail(resource.UserName) {
+		return nil, ErrInvalidUserName
+	}
+
+	if err := s.checkExternalID(ctx, ResourceUser, resource.ExternalID, uuid.Nil); err != nil {
+		return nil, err
+	}
+
+	var roleIDs []int32
+	if len(resource.Roles) > 0 {
+		roles, err := s.findRoles(ctx, resource.Roles)
+		if err != nil {
+			return nil, err
+		}
+		for _, role := range roles {
+			roleIDs = append(roleIDs, role.ID)
+		}
+	}
+
+	password := resource.Password
+	if password == "" {
+		secret, err := util.GenerateSecret()
+		if err != nil {
+			return nil, err
+		}
+		password = secret
+	}
+
+	user, err := s.oauthService.CreateConfirmedUser(ctx, s.realm(ctx), resource.UserName, password, country(resource), roleIDs, func(ctx context.Context, tx bun.Tx, user *model.User) error {
+		if err := s.updateProfile(ctx, tx, user, resource); err != nil {
+			return err
+		}
+
+		// new users have no tokens to revoke
+		if resource.Active != nil && !*resource.Active {
+			_, err := tx.NewUpdate().
+				Model(user).
+				Set("password = NULL").
+				WherePK().
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+			user.Password = sql.NullString{}
+		}
+
+		if _, err := tx.NewInsert().Model(&ProvisionedUser{UserID: user.ID}).Exec(ctx); err != nil {
+			return err
+		}
+
+		return s.setExternalID(ctx, tx, ResourceUser, resource.ExternalID, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetUser(ctx, user.ID.String())
+}
+
+// ReplaceUser replaces the attributes of a user, attributes left out are
+// kept. roles replace the roles held, an empty list leaves the user role.
+func (s *Service) ReplaceUser(ctx context.Context, id string, resource *User) (*User, error) {
+	user, err := s.findProvisionedUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.updateUser(ctx, user, resource); err != nil {
+		return nil, err
+	}
+
+	return s.GetUser(ctx, id)
+}
+
+// PatchUser applies PATCH operations to a user
+func (s *Service) PatchUser(ctx context.Context, id string, ops []PatchOperation) (*User, error) {
+	user, err := s.findProvisionedUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	resource, err := s.newUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = Patch(resource, ops); err != nil {
+		return nil, err
+	}
+
+	// Removing every role leaves the user role
+	if resource.Roles == nil {
+		resource.Roles = []Value{}
+	}
+
+	if err = s.updateUser(ctx, user, resource); err != nil {
+		return nil, err
+	}
+
+	return s.GetUser(ctx, id)
+}
+
+// DeleteUser schedules the deletion of a user, the account can be restored
+// during the grace period like any other account
+func (s *Service) DeleteUser(ctx context.Context, id string) error {
+	user, err := s.findProvisionedUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err = s.oauthService.ScheduleUserDeletion(user); err != nil {
+		return err
+	}
+
+	return s.setExternalID(ctx, s.db, ResourceUser, "", user.ID)
+}
+
+// findUser returns a user of the request realm
+func (s *Service) findUser(ctx context.Context, id string) (*model.User, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	user := new(model.User)
+
+	err = s.inRealm(ctx, s.db.NewSelect().Model(user), `"user".id`).
+		Where(`"user".id = ?`, userID).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// findProvisionedUser returns a user of the request realm which may be
+// changed through the API, only users it created and which hold no
+// privileged role may
+func (s *Service) findProvisionedUser(ctx context.Context, id string) (*model.User, error) {
+	user, err := s.findUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	provisioned, err := s.db.NewSelect().
+		Model((*ProvisionedUser)(nil)).
+		Where("user_id = ?", user.ID).
+		Exists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !provisioned {
+		return nil, ErrNotProvisioned
+	}
+
+	roleIDs, err := s.oauthService.GetRBACService().UserRoleIDs(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, roleID := range roleIDs {
+		if rbac.IsPrivileged(roleID) {
+			return nil, ErrPrivilegedUser
+		}
+	}
+
+	return user, nil
+}
+
+// findRoles returns the roles named, privileged roles are refused
+func (s *Service) findRoles(ctx context.Context, values []Value) ([]*rbac.Role, error) {
+	roles := []*rbac.Role{}
+
+	for _, value := range values {
+		role, err := s.oauthService.GetRBACService().FindRoleByName(ctx, value.Value)
+		if err == rbac.ErrRoleNotFound {
+			return nil, ErrUnknownRole
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rbac.IsPrivileged(role.ID) {
+			return nil, ErrPrivilegedRole
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
+
+// updateUser applies the attributes of a resource to a user
+func (s *Service) updateUser(ctx context.Context, user *model.User, resource *User) error {
+	if !strings.EqualFold(resource.UserName, user.Username) {
+		if !util.ValidateEmail(resource.UserName) {
+			return ErrInvalidUserName
+		}
+	}
+
+	if err := s.checkExternalID(ctx, ResourceUser, resource.ExternalID, user.ID); err != nil {
+		return err
+	}
+
+	var roles []*rbac.Role
+	if resource.Roles != nil {
+		var err error
+		if roles, err = s.findRoles(ctx, resource.Roles); err != nil {
+			return err
+		}
+	}
+
+	if resource.Active != nil && *resource.Active && !user.Password.Valid && resource.Password == "" {
+		return ErrLockedUser
+	}
+
+	if err := s.oauthService.SetUsername(user, resource.UserName); err != nil {
+		return err
+	}
+
+	if err := s.updateProfile(ctx, s.db, user, resource); err != nil {
+		return err
+	}
+
+	if c := country(resource); c != "" && c != user.Country {
+		if err := s.oauthService.SetUserCountry(user, c); err != nil {
+			return err
+		}
+	}
+
+	if resource.Password != "" {
+		if err := s.oauthService.SetPassword(user, resource.Password); err != nil {
+			return err
+		}
+		user.Password.Valid = true
+	}
+
+	if resource.Active != nil && !*resource.Active && user.Password.Valid {
+		if err := s.oauthService.LockUser(user); err != nil {
+			return err
+		}
+	}
+
+	if roles != nil {
+		if err := s.setRoles(ctx, user, roles); err != nil {
+			return err
+		}
+	}
+
+	return s.setExternalID(ctx, s.db, ResourceUser, resource.ExternalID, user.ID)
+}
+
+// updateProfile updates the names of a user, names left out are kept
+func (s *Service) updateProfile(ctx context.Context, db bun.IDB, user *model.User, resource *User) error {
+	fullName := resource.DisplayName
+	firstName, lastName := "", ""
+
+	if resource.Name != nil {
+		if fullName == "" {
+			fullName = resource.Name.Formatted
+		}
+		firstName, lastName = resource.Name.GivenName, resource.Name.FamilyName
+	}
+
+	if (fullName == "" || fullName == user.FullName) &&
+		(firstName == "" || firstName == user.FirstName) &&
+		(lastName == "" || lastName == user.LastName) {
+		return nil
+	}
+
+	update := db.NewUpdate().Model(user)
+
+	if fullName != "" {
+		update.Set("full_name = ?", fullName)
+		user.FullName = fullName
+	}
+	if firstName != "" {
+		update.Set("first_name = ?", firstName)
+		user.FirstName = firstName
+	}
+	if lastName != "" {
+		update.Set("last_name = ?", lastName)
+		user.LastName = lastName
+	}
+
+	_, err := update.WherePK().Exec(ctx)
+
+	return err
+}
+
+// setRoles grants and revokes roles so the user holds exactly the roles
+// given, or the user role when none is given
+func (s *Service) setRoles(ctx context.Context, user *model.User, roles []*rbac.Role) error {
+	rbacService := s.oauthService.GetRBACService()
+
+	if len(roles) == 0 {
+		role, err := rbacService.FindRoleByID(ctx, int32(model.UserRole))
+		if err != nil {
+			return err
+		}
+		roles = []*rbac.Role{role}
+	}
+
+	held, err := rbacService.UserRoles(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	for _, role := range roles {
+		if !containsRole(held, role.ID) {
+			if err := rbacService.AssignRole(ctx, user, role, uuid.Nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, role := range held {
+		if !containsRole(roles, role.ID) {
+			if err := rbacService.RevokeRole(ctx, user, role, uuid.Nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// newUser returns the SCIM representation of a user
+func (s *Service) newUser(ctx context.Context, user *model.User) (*User, error) {
+	externalID, err := s.findExternalID(ctx, ResourceUser, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.oauthService.GetRBACService().UserRoles(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []*UserGroup{}
+	err = s.db.NewSelect().
+		Model(&groups).
+		Where("owner_id = ?", user.ID).
+		Order("display_name").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	active := user.Password.Valid
+
+	resource := &User{
+		Schemas:     []string{UserSchema},
+		ID:          user.ID.String(),
+		ExternalID:  externalID,
+		UserName:    user.Username,
+		DisplayName: user.FullName,
+		Active:      &active,
+		Emails:      []Value{{Value: user.Username, Type: "work", Primary: true}},
+		Roles:       []Value{},
+		Groups:      []Value{},
+		Meta:        s.newMeta(ctx, ResourceUser, "/Users/"+user.ID.String(), user.CreatedAt, user.UpdatedAt),
+	}
+
+	if user.FullName != "" || user.FirstName != "" || user.LastName != "" {
+		resource.Name = &Name{
+			Formatted:  user.FullName,
+			GivenName:  user.FirstName,
+			FamilyName: user.LastName,
+		}
+	}
+
+	if user.Country != "" {
+		resource.Addresses = []Address{{Country: user.Country}}
+	}
+
+	for _, role := range roles {
+		resource.Roles = append(resource.Roles, Value{Value: role.Name})
+	}
+
+	for _, group := range groups {
+		resource.Groups = append(resource.Groups, Value{
+			Value:   group.ID.String(),
+			Display: group.DisplayName,
+			Ref:     s.location(ctx, "/Groups/"+group.ID.String()),
+		})
+	}
+
+	return resource, nil
+}
+
+// country returns the country of the primary address of a user, or of its
+// first address with a country
+func country(resource *User) string {
+	country := ""
+	for _, address := range resource.Addresses {
+		if address.Primary && address.Country != "" {
+			return address.Country
+		}
+		if country == "" {
+			country = address.Country
+		}
+	}
+	return country
+}
+
+func containsRole(roles []*rbac.Role, id int32) bool {
+	for _, role := range roles {
+		if role.ID == id {
+			return true
+		}
+	}
+	return false
+}
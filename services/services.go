@@ -5,11 +5,17 @@ import (
 
 	"github.com/gorilla/sessions"
 	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/events"
 	"github.com/resonatecoop/id/health"
 	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/rbac"
+	"github.com/resonatecoop/id/realm"
+	"github.com/resonatecoop/id/scheduler"
+	"github.com/resonatecoop/id/scim"
 	"github.com/resonatecoop/id/session"
 	"github.com/resonatecoop/id/web"
 	"github.com/resonatecoop/id/webhook"
+	"github.com/stripe/stripe-go/v72"
 	"github.com/uptrace/bun"
 )
 
@@ -24,14 +30,32 @@ var (
 	// OauthService ...
 	OauthService oauth.ServiceInterface
 
+	// RealmService ...
+	RealmService realm.ServiceInterface
+
+	// RBACService ...
+	RBACService rbac.ServiceInterface
+
+	// EventsService ...
+	EventsService events.ServiceInterface
+
 	// WebService ...
 	WebService web.ServiceInterface
 
 	// WebHookService ...
 	WebHookService webhook.ServiceInterface
 
+	// SCIMService ...
+	SCIMService scim.ServiceInterface
+
 	// SessionService ...
 	SessionService session.ServiceInterface
+
+	// SchedulerService ...
+	SchedulerService scheduler.ServiceInterface
+
+	// unsubscribers remove config change handlers on Close
+	unsubscribers []func()
 )
 
 // UseHealthService sets the health service
@@ -44,6 +68,21 @@ func UseOauthService(o oauth.ServiceInterface) {
 	OauthService = o
 }
 
+// UseRealmService sets the realm service
+func UseRealmService(r realm.ServiceInterface) {
+	RealmService = r
+}
+
+// UseRBACService sets the rbac service
+func UseRBACService(r rbac.ServiceInterface) {
+	RBACService = r
+}
+
+// UseEventsService sets the events service
+func UseEventsService(e events.ServiceInterface) {
+	EventsService = e
+}
+
 // UseWebHookService sets the web service
 func UseWebHookService(w webhook.ServiceInterface) {
 	WebHookService = w
@@ -54,33 +93,61 @@ func UseWebService(w web.ServiceInterface) {
 	WebService = w
 }
 
+// UseSCIMService sets the SCIM service
+func UseSCIMService(s scim.ServiceInterface) {
+	SCIMService = s
+}
+
 // UseSessionService sets the session service
 func UseSessionService(s session.ServiceInterface) {
 	SessionService = s
 }
 
+// UseSchedulerService sets the scheduler service
+func UseSchedulerService(s scheduler.ServiceInterface) {
+	SchedulerService = s
+}
+
 // Init starts up all services
 func Init(cnf *config.Config, db *bun.DB) error {
 	if nil == reflect.TypeOf(HealthService) {
-		HealthService = health.NewService(db)
+		HealthService = health.NewService(cnf, db)
 	}
 
 	if nil == reflect.TypeOf(OauthService) {
 		OauthService = oauth.NewService(cnf, db)
 	}
 
+	if nil == reflect.TypeOf(RealmService) {
+		RealmService = OauthService.GetRealmService()
+	}
+
+	if nil == reflect.TypeOf(RBACService) {
+		RBACService = OauthService.GetRBACService()
+	}
+
+	if nil == reflect.TypeOf(EventsService) {
+		EventsService = OauthService.GetEventsService()
+	}
+
 	if nil == reflect.TypeOf(SessionService) {
-		// note: default session store is CookieStore
-		store := sessions.NewCookieStore([]byte(cnf.Session.Secret))
-
-		store.Options = &sessions.Options{
-			Path:     cnf.Session.Path,
-			MaxAge:   cnf.Session.MaxAge,
-			Secure:   cnf.Session.Secure,
-			HttpOnly: cnf.Session.HTTPOnly,
+		SessionService = session.NewService(cnf, newCookieStore(cnf))
+
+		// rotate the cookie store when session settings change
+		if err := subscribe("Session", func(cnf *config.Config) {
+			SessionService.SetSessionStore(cnf, newCookieStore(cnf))
+		}); err != nil {
+			return err
 		}
+	}
+
+	// stripe reads its key from a package level variable
+	stripe.Key = cnf.Stripe.Secret
 
-		SessionService = session.NewService(cnf, store)
+	if err := subscribe("Stripe.Secret", func(cnf *config.Config) {
+		stripe.Key = cnf.Stripe.Secret
+	}); err != nil {
+		return err
 	}
 
 	if nil == reflect.TypeOf(WebService) {
@@ -91,14 +158,65 @@ func Init(cnf *config.Config, db *bun.DB) error {
 		WebHookService = webhook.NewService(cnf, db, OauthService)
 	}
 
+	if nil == reflect.TypeOf(SCIMService) {
+		SCIMService = scim.NewService(cnf, db, OauthService)
+	}
+
+	if nil == reflect.TypeOf(SchedulerService) {
+		SchedulerService = scheduler.NewService(cnf, db)
+
+		for _, job := range scheduler.NewCleanupJobs(cnf, OauthService) {
+			SchedulerService.Register(job)
+		}
+
+		SchedulerService.Register(scheduler.NewUserAPISyncJob(cnf, OauthService))
+		SchedulerService.Register(scheduler.NewWebhookDeliveryJob(cnf, EventsService))
+	}
+
 	return nil
 }
 
 // Close closes any open services
 func Close() {
+	for _, unsubscribe := range unsubscribers {
+		unsubscribe()
+	}
+	unsubscribers = nil
+
 	HealthService.Close()
 	OauthService.Close()
+	RealmService.Close()
+	RBACService.Close()
+	EventsService.Close()
 	WebHookService.Close()
+	SCIMService.Close()
 	WebService.Close()
 	SessionService.Close()
+	SchedulerService.Close()
+}
+
+// newCookieStore returns the default session store
+func newCookieStore(cnf *config.Config) sessions.Store {
+	store := sessions.NewCookieStore([]byte(cnf.Session.Secret))
+
+	store.Options = &sessions.Options{
+		Path:     cnf.Session.Path,
+		MaxAge:   cnf.Session.MaxAge,
+		Secure:   cnf.Session.Secure,
+		HttpOnly: cnf.Session.HTTPOnly,
+	}
+
+	return store
+}
+
+// subscribe registers a config change handler removed on Close
+func subscribe(path string, fn func(cnf *config.Config)) error {
+	unsubscribe, err := config.Subscribe(path, fn)
+	if err != nil {
+		return err
+	}
+
+	unsubscribers = append(unsubscribers, unsubscribe)
+
+	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/gob"
 	"errors"
 	"net/http"
+	"sync"
 
 	//"github.com/resonatecoop/id/config"
 	"github.com/gorilla/sessions"
@@ -27,6 +28,7 @@ type Flash struct {
 
 // Service wraps session functionality
 type Service struct {
+	storeMu        sync.RWMutex
 	sessionStore   sessions.Store
 	sessionOptions *sessions.Options
 	session        *sessions.Session
@@ -47,8 +49,11 @@ type UserSession struct {
 	Role                   string // user, artist, label, admin, tenantadmin, ...
 	AccessToken            string
 	RefreshToken           string
+	SessionID              string // ends on logout, see oauth.Session
 	CheckoutSessionID      string
 	CheckoutSessionPriceID string
+	ImpersonationID        string // set when an admin acts as the user, see oauth.Impersonation
+	Impersonator           string // username of that admin
 }
 
 var (
@@ -71,16 +76,25 @@ func init() {
 
 // NewService returns a new Service instance
 func NewService(cnf *config.Config, sessionStore sessions.Store) *Service {
-	return &Service{
-		// Session cookie storage
-		sessionStore: sessionStore,
-		// Session options
-		sessionOptions: &sessions.Options{
-			Path:     cnf.Session.Path,
-			MaxAge:   cnf.Session.MaxAge,
-			Secure:   cnf.Session.Secure,
-			HttpOnly: cnf.Session.HTTPOnly,
-		},
+	s := new(Service)
+	s.SetSessionStore(cnf, sessionStore)
+	return s
+}
+
+// SetSessionStore replaces the session store and options, e.g. after
+// session settings have changed on config reload
+func (s *Service) SetSessionStore(cnf *config.Config, sessionStore sessions.Store) {
+	s.storeMu.Lock()
+	defer s.storeMu.Unlock()
+
+	// Session cookie storage
+	s.sessionStore = sessionStore
+	// Session options
+	s.sessionOptions = &sessions.Options{
+		Path:     cnf.Session.Path,
+		MaxAge:   cnf.Session.MaxAge,
+		Secure:   cnf.Session.Secure,
+		HttpOnly: cnf.Session.HTTPOnly,
 	}
 }
 
@@ -93,7 +107,11 @@ func (s *Service) SetSessionService(r *http.Request, w http.ResponseWriter) {
 // StartSession starts a new session. This method must be called before other
 // public methods of this struct as it sets the internal session object
 func (s *Service) StartSession() error {
-	session, err := s.sessionStore.Get(s.r, StorageSessionName)
+	s.storeMu.RLock()
+	sessionStore := s.sessionStore
+	s.storeMu.RUnlock()
+
+	session, err := sessionStore.Get(s.r, StorageSessionName)
 	if err != nil {
 		return err
 	}
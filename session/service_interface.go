@@ -1,10 +1,16 @@
 package session
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/gorilla/sessions"
+	"github.com/resonatecoop/id/config"
+)
 
 // ServiceInterface defines exported methods
 type ServiceInterface interface {
 	SetSessionService(r *http.Request, w http.ResponseWriter)
+	SetSessionStore(cnf *config.Config, sessionStore sessions.Store)
 	StartSession() error
 	GetUserSession() (*UserSession, error)
 	SetUserSession(userSession *UserSession) error
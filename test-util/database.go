@@ -0,0 +1,112 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/database"
+	"github.com/resonatecoop/id/database/fixtures"
+	"github.com/resonatecoop/id/database/migrations"
+	"github.com/uptrace/bun"
+)
+
+var (
+	// ErrDatabaseUnreachable is wrapped by the errors of CreateTestSchema
+	// when Postgres cannot be reached, suites may be skipped then
+	ErrDatabaseUnreachable = errors.New("Test database is unreachable")
+
+	// invalidSchemaChars are replaced in schema names
+	invalidSchemaChars = regexp.MustCompile(`[^a-z0-9_]+`)
+)
+
+// CreateTestSchema creates an empty Postgres schema for a test suite, runs
+// the migrations and loads the default fixtures into it. The returned
+// database uses the schema as its search path, the returned function closes
+// it and drops the schema. Errors other than ErrDatabaseUnreachable, e.g. a
+// broken migration, should fail the suite.
+func CreateTestSchema(cnf *config.Config, suiteName string) (*bun.DB, func() error, error) {
+	ctx := context.Background()
+
+	schema := fmt.Sprintf(
+		"test_%s_%s",
+		invalidSchemaChars.ReplaceAllString(strings.ToLower(suiteName), "_"),
+		strings.Replace(uuid.New().String(), "-", "", -1)[:8],
+	)
+
+	adminDB, err := database.NewDatabase(cnf)
+	if err != nil {
+		if adminDB != nil {
+			adminDB.Close()
+		}
+		return nil, nil, fmt.Errorf("%w: %v", ErrDatabaseUnreachable, err)
+	}
+
+	if _, err := adminDB.ExecContext(ctx, fmt.Sprintf("CREATE SCHEMA %s", schema)); err != nil {
+		adminDB.Close()
+		return nil, nil, err
+	}
+
+	dropSchema := func() error {
+		defer adminDB.Close()
+		_, err := adminDB.ExecContext(ctx, fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", schema))
+		return err
+	}
+
+	testCnf := *cnf
+	testCnf.Database.PSN, err = withSearchPath(cnf.Database.PSN, schema)
+	if err != nil {
+		dropSchema()
+		return nil, nil, err
+	}
+
+	db, err := database.NewDatabase(&testCnf)
+	if err != nil {
+		dropSchema()
+		return nil, nil, err
+	}
+
+	cleanup := func() error {
+		db.Close()
+		return dropSchema()
+	}
+
+	if _, err := migrations.Up(ctx, db); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	if _, err := fixtures.Load(ctx, db, cnf.Clients); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	return db, cleanup, nil
+}
+
+// withSearchPath sets the search path of a connection string, extensions
+// installed in the public schema remain visible
+func withSearchPath(psn, schema string) (string, error) {
+	searchPath := schema + ",public"
+
+	if !strings.Contains(psn, "://") {
+		// key=value connection string
+		return fmt.Sprintf("%s search_path=%s", psn, searchPath), nil
+	}
+
+	u, err := url.Parse(psn)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	query.Set("search_path", searchPath)
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// QueryHook creates a span for every bun query. Statements are recorded
+// without their arguments so passwords and tokens do not end up in traces.
+type QueryHook struct{}
+
+var _ bun.QueryHook = (*QueryHook)(nil)
+
+// NewQueryHook returns a new QueryHook instance
+func NewQueryHook() *QueryHook {
+	return &QueryHook{}
+}
+
+// BeforeQuery starts the query span
+func (h *QueryHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	ctx, _ = Tracer().Start(ctx, "db.query", trace.WithSpanKind(trace.SpanKindClient))
+	return ctx
+}
+
+// AfterQuery ends the query span
+func (h *QueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	defer span.End()
+
+	operation := event.Operation()
+	span.SetName(operation)
+
+	span.SetAttributes(
+		semconv.DBSystemPostgreSQL,
+		semconv.DBOperationKey.String(operation),
+		semconv.DBStatementKey.String(unformattedQuery(event)),
+	)
+
+	if event.IQuery != nil {
+		if tableName := event.IQuery.GetTableName(); tableName != "" {
+			span.SetAttributes(semconv.DBSQLTableKey.String(tableName))
+		}
+	}
+
+	switch event.Err {
+	case nil, sql.ErrNoRows, sql.ErrTxDone:
+	default:
+		span.RecordError(event.Err)
+		span.SetStatus(codes.Error, event.Err.Error())
+	}
+}
+
+// unformattedQuery returns the query with placeholders instead of arguments
+func unformattedQuery(event *bun.QueryEvent) string {
+	if event.IQuery != nil {
+		if b, err := event.IQuery.AppendQuery(schema.NewNopFormatter(), nil); err == nil {
+			return string(b)
+		}
+	}
+	return event.QueryTemplate
+}
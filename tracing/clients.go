@@ -0,0 +1,27 @@
+package tracing
+
+import (
+	"time"
+
+	"github.com/mailgun/mailgun-go/v4"
+	"github.com/stripe/stripe-go/v72"
+)
+
+const (
+	// stripeTimeout matches the default timeout of the stripe client
+	stripeTimeout = 80 * time.Second
+	// mailgunTimeout matches the timeout used when sending emails
+	mailgunTimeout = 10 * time.Second
+)
+
+// InstrumentStripe makes the stripe package use a traced HTTP client
+func InstrumentStripe() {
+	stripe.SetHTTPClient(NewHTTPClient(stripeTimeout))
+}
+
+// NewMailgun returns a Mailgun client using a traced HTTP client
+func NewMailgun(domain, apiKey string) *mailgun.MailgunImpl {
+	mg := mailgun.NewMailgun(domain, apiKey)
+	mg.SetClient(NewHTTPClient(mailgunTimeout))
+	return mg
+}
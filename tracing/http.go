@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/urfave/negroni"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware starts a server span for every request, continuing the trace
+// found in the W3C traceparent header if any. The span is renamed after the
+// matched route by RouteHandler.
+type Middleware struct {
+	serverName string
+}
+
+// NewMiddleware returns a new tracing middleware
+func NewMiddleware(serverName string) *Middleware {
+	return &Middleware{serverName: serverName}
+}
+
+// ServeHTTP as per the negroni.Handler interface
+func (m *Middleware) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+	ctx, span := Tracer().Start(
+		ctx,
+		fmt.Sprintf("HTTP %s", r.Method),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(m.serverName, "", r)...),
+	)
+	defer span.End()
+
+	rw, ok := w.(negroni.ResponseWriter)
+	if !ok {
+		rw = negroni.NewResponseWriter(w)
+	}
+
+	next(rw, r.WithContext(ctx))
+
+	status := rw.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(status, trace.SpanKindServer))
+}
+
+// RouteHandler names the current server span after the matched route
+func RouteHandler(name string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(name)
+		span.SetAttributes(semconv.HTTPRouteKey.String(name))
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// NewTransport wraps an http.RoundTripper so outbound requests get client
+// spans and carry the W3C trace context headers
+func NewTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(base)
+}
+
+// NewHTTPClient returns an http.Client with a traced transport
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: NewTransport(http.DefaultTransport),
+		Timeout:   timeout,
+	}
+}
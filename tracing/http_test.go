@@ -0,0 +1,73 @@
+package tracing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/resonatecoop/id/config"
+	"github.com/resonatecoop/id/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/negroni"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddleware(t *testing.T) {
+	_, err := tracing.Init(config.Cnf)
+	assert.NoError(t, err)
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	var outbound http.Header
+
+	// Fake user api recording the propagated headers
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outbound = r.Header.Clone()
+	}))
+	defer upstream.Close()
+
+	app := negroni.New()
+	app.Use(tracing.NewMiddleware("id"))
+	app.UseHandler(tracing.RouteHandler("test_route", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := http.NewRequestWithContext(r.Context(), "GET", upstream.URL, nil)
+		assert.NoError(t, err)
+
+		resp, err := tracing.NewHTTPClient(0).Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		w.WriteHeader(http.StatusTeapot)
+	})))
+
+	r, err := http.NewRequest("GET", "http://1.2.3.4/foo", nil)
+	assert.NoError(t, err)
+	r.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusTeapot, w.Code)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+
+	var server sdktrace.ReadOnlySpan
+	for _, span := range spans {
+		if span.SpanKind() == trace.SpanKindServer {
+			server = span
+		}
+	}
+
+	if assert.NotNil(t, server) {
+		assert.Equal(t, "test_route", server.Name())
+		assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", server.SpanContext().TraceID().String())
+		assert.Equal(t, "b7ad6b7169203331", server.Parent().SpanID().String())
+	}
+
+	// The trace continues in the upstream service
+	assert.Contains(t, outbound.Get("traceparent"), "0af7651916cd43dd8448eb211c80319c")
+}
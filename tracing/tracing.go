@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/resonatecoop/id/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/resonatecoop/id"
+)
+
+var (
+	// ErrUnknownExporter ...
+	ErrUnknownExporter = errors.New("Unknown tracing exporter")
+)
+
+// Tracer returns the tracer used across the app
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Init sets up the global tracer provider and W3C trace context propagation.
+// Spans are exported over OTLP/HTTP or printed to stdout depending on the
+// configured exporter, no spans are exported when the exporter is "none".
+// The returned function flushes pending spans and must be called on exit.
+//
+// Spans nest under the request span only when the request context is passed
+// down, most service methods still use context.Background() so their queries
+// and outbound calls start traces of their own.
+func Init(cnf *config.Config) (func(context.Context) error, error) {
+	// Always propagate trace context so upstream traces reach the user api
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+
+	// Outbound calls to stripe get client spans
+	InstrumentStripe()
+
+	switch cnf.Tracing.Exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exporter, err = stdouttrace.New(
+			stdouttrace.WithWriter(os.Stdout),
+			stdouttrace.WithPrettyPrint(),
+		)
+	case "otlp":
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(cnf.Tracing.Endpoint),
+		}
+		if cnf.Tracing.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	default:
+		return nil, ErrUnknownExporter
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(cnf.Tracing.ServiceName),
+		),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(
+			sdktrace.TraceIDRatioBased(cnf.Tracing.SampleRatio),
+		)),
+	)
+
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
@@ -3,10 +3,10 @@ package password
 import (
 	"errors"
 	"fmt"
-	"os"
 	"sync"
 
 	"github.com/apokalyptik/phpass"
+	"github.com/resonatecoop/id/log"
 	"github.com/trustelem/zxcvbn"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -38,20 +38,19 @@ var (
 // Fallback to phpass if bcrypt fails
 func VerifyPassword(passwordHash, password string) error {
 	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) != nil {
-		fmt.Fprintln(os.Stderr, "Not Bcrypt password")
+		log.DEBUG.Print("Password hash is not bcrypt, trying phpass")
 		phpassMutex.Lock()
 		ret := phpassVar.Check([]byte(password), []byte(passwordHash))
 		phpassMutex.Unlock()
 		// If passwords don't match return mismatch error
 		// use bcrypt's error code for compat
 		if !ret {
-			fmt.Fprintln(os.Stderr, "No password match")
+			log.DEBUG.Print("Password does not match phpass hash")
 			return bcrypt.ErrMismatchedHashAndPassword
 		}
-		fmt.Fprintln(os.Stderr, "PHPassword matched")
+		log.DEBUG.Print("Password matches phpass hash")
 		return nil
 	}
-	fmt.Fprintln(os.Stderr, "Bcrypt Password matched")
 	return nil
 }
 
@@ -1,25 +1,20 @@
 package response
 
 import (
-	"fmt"
-	"log"
 	"net/http"
-	"os"
 	"time"
 
-	thelog "github.com/resonatecoop/id/log"
+	"github.com/resonatecoop/id/log"
 	"github.com/urfave/negroni"
 )
 
 // Logger is a middleware handler that logs the request as it goes in and the response as it goes out.
-type Logger struct {
-	// Logger inherits from log.Logger used to log messages with the Logger middleware
-	*log.Logger
-}
+// Lines carry the request ID set by log.RequestID.
+type Logger struct{}
 
 // NewURLLogger returns a new Logger instance
 func NewURLLogger() *Logger {
-	return &Logger{log.New(os.Stdout, "[negroni] ", 0)}
+	return &Logger{}
 }
 
 func (l *Logger) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
@@ -29,20 +24,28 @@ func (l *Logger) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.Ha
 		ip = xff
 	}
 
-	thelog.INFO.Printf("Started %s %s for %s", r.Method, r.URL.Path, ip)
+	entry := log.FromContext(r.Context()).
+		With("method", r.Method).
+		With("path", r.URL.Path).
+		With("remote_ip", ip)
+
+	entry.DEBUG.Printf("Started %s %s", r.Method, r.URL.Path)
 
 	next(rw, r)
 
 	res := rw.(negroni.ResponseWriter)
+	duration := time.Since(start)
 
-	msg := fmt.Sprintf("Finished %s %s : %v %s in %v", r.Method, r.URL.Path, res.Status(), http.StatusText(res.Status()), time.Since(start))
+	entry = entry.
+		With("status", res.Status()).
+		With("duration_ms", float64(duration.Microseconds())/1000)
 
 	switch {
 	case res.Status() < 400:
-		thelog.INFO.Print(msg)
+		entry.INFO.Printf("Finished %s %s : %v %s in %v", r.Method, r.URL.Path, res.Status(), http.StatusText(res.Status()), duration)
 	case res.Status() < 500:
-		thelog.WARNING.Print(msg)
+		entry.WARNING.Printf("Finished %s %s : %v %s in %v", r.Method, r.URL.Path, res.Status(), http.StatusText(res.Status()), duration)
 	default:
-		thelog.ERROR.Print(msg)
+		entry.ERROR.Printf("Finished %s %s : %v %s in %v", r.Method, r.URL.Path, res.Status(), http.StatusText(res.Status()), duration)
 	}
 }
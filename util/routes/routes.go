@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/resonatecoop/id/metrics"
+	"github.com/resonatecoop/id/tracing"
 	"github.com/urfave/negroni"
 )
 
@@ -17,7 +19,9 @@ type Route struct {
 }
 
 // AddRoutes adds routes to a router instance. If there are middlewares defined
-// for a route, a new negroni app is created and wrapped as a http.Handler
+// for a route, a new negroni app is created and wrapped as a http.Handler.
+// Every route is instrumented with request latency metrics and names the
+// request span after the route.
 func AddRoutes(routes []Route, router *mux.Router) {
 	var (
 		handler http.Handler
@@ -43,6 +47,6 @@ func AddRoutes(routes []Route, router *mux.Router) {
 		router.Methods(route.Method).
 			Path(route.Pattern).
 			Name(route.Name).
-			Handler(handler)
+			Handler(metrics.InstrumentHandler(route.Name, tracing.RouteHandler(route.Name, handler)))
 	}
 }
@@ -0,0 +1,35 @@
+package util
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+)
+
+// ErrInvalidRSAPrivateKey ...
+var ErrInvalidRSAPrivateKey = errors.New("Invalid RSA private key")
+
+// ParseRSAPrivateKey parses a PEM encoded PKCS #1 or PKCS #8 RSA private key
+func ParseRSAPrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, ErrInvalidRSAPrivateKey
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, ErrInvalidRSAPrivateKey
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, ErrInvalidRSAPrivateKey
+	}
+
+	return rsaKey, nil
+}
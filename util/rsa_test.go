@@ -0,0 +1,34 @@
+package util_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/resonatecoop/id/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRSAPrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	parsed, err := util.ParseRSAPrivateKey(string(pkcs1))
+	if assert.NoError(t, err) {
+		assert.True(t, key.Equal(parsed))
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	pkcs8 := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	parsed, err = util.ParseRSAPrivateKey(string(pkcs8))
+	if assert.NoError(t, err) {
+		assert.True(t, key.Equal(parsed))
+	}
+
+	_, err = util.ParseRSAPrivateKey("not a key")
+	assert.Equal(t, util.ErrInvalidRSAPrivateKey, err)
+}
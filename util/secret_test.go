@@ -0,0 +1,18 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/resonatecoop/id/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateSecret(t *testing.T) {
+	first, err := util.GenerateSecret()
+	assert.NoError(t, err)
+	assert.Len(t, first, 43)
+
+	second, err := util.GenerateSecret()
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, second)
+}
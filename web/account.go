@@ -2,6 +2,7 @@ package web
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
@@ -79,7 +80,7 @@ func (s *Service) accountForm(w http.ResponseWriter, r *http.Request) {
 
 	profile := NewProfile(user, usergroups.Usergroup, isUserAccountComplete, credits, userSession.Role)
 
-	err = renderTemplate(w, "account.html", map[string]interface{}{
+	err = renderTemplate(w, r, "account.html", map[string]interface{}{
 		"appURL":                s.cnf.AppURL,
 		"applicationName":       client.ApplicationName.String,
 		"clientID":              client.Key,
@@ -194,7 +195,7 @@ func (s *Service) account(w http.ResponseWriter, r *http.Request) {
 				_, err = s.createUserGroup(user, r.Form.Get("displayName"), userSession.AccessToken)
 
 				if err != nil {
-					log.ERROR.Print(err)
+					log.FromContext(r.Context()).ERROR.Print(err)
 				}
 			}
 		}
@@ -213,6 +214,7 @@ func (s *Service) account(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	stripeCnf := s.getRealm(r).StripeConfig(s.cnf.Stripe)
 	products := []config.Product{}
 
 	if credits > 0 {
@@ -220,13 +222,13 @@ func (s *Service) account(w http.ResponseWriter, r *http.Request) {
 
 		switch credits {
 		case 5:
-			product = s.cnf.Stripe.StreamCredit5
+			product = stripeCnf.StreamCredit5
 		case 10:
-			product = s.cnf.Stripe.StreamCredit10
+			product = stripeCnf.StreamCredit10
 		case 20:
-			product = s.cnf.Stripe.StreamCredit20
+			product = stripeCnf.StreamCredit20
 		case 50:
-			product = s.cnf.Stripe.StreamCredit50
+			product = stripeCnf.StreamCredit50
 		}
 
 		if product.ID != "" {
@@ -240,18 +242,18 @@ func (s *Service) account(w http.ResponseWriter, r *http.Request) {
 
 		switch user.RoleID {
 		case int32(model.ArtistRole):
-			product = s.cnf.Stripe.ArtistMembership
+			product = stripeCnf.ArtistMembership
 		case int32(model.LabelRole):
-			product = s.cnf.Stripe.LabelMembership
+			product = stripeCnf.LabelMembership
 		default:
-			product = s.cnf.Stripe.ListenerSubscription
+			product = stripeCnf.ListenerSubscription
 		}
 
 		products = append(products, product)
 	}
 
 	if shares > 0 && shares%5 == 0 {
-		supporterShares := s.cnf.Stripe.SupporterShares
+		supporterShares := stripeCnf.SupporterShares
 		supporterShares.Quantity = shares
 		products = append(products, supporterShares)
 	}
@@ -302,11 +304,19 @@ func (s *Service) account(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// getUserCredits returns the credits of a user from the user api. An empty
+// payload is returned along with the error so pages still render while the
+// user api is down.
 func (s *Service) getUserCredits(user *model.User, accessToken string) (
 	*models.UserUserCreditResponse,
 	error,
 ) {
-	client := config.NewAPIClient(s.cnf.UserAPIHostname, s.cnf.UserAPIPort)
+	empty := &models.UserUserCreditResponse{}
+
+	client, err := config.NewAPIClient(s.cnf)
+	if err != nil {
+		return empty, err
+	}
 
 	bearer := httptransport.BearerToken(accessToken)
 
@@ -316,24 +326,29 @@ func (s *Service) getUserCredits(user *model.User, accessToken string) (
 
 	result, err := client.Users.ResonateUserGetUserCredits(params, bearer)
 
-	if result == nil {
-		panic("User API not started")
-	}
-
 	if err != nil {
-		if casted, ok := err.(*users.ResonateUserGetUserCreditsDefault); ok {
-			return nil, casted
+		if casted, ok := err.(*users.ResonateUserGetUserCreditsDefault); ok && casted.Payload != nil {
+			return empty, errors.New(casted.Payload.Message)
 		}
+		return empty, err
 	}
 
-	return result.Payload, err
+	return result.Payload, nil
 }
 
+// getUserGroupList returns the user groups of a user from the user api. An
+// empty list is returned along with the error so pages still render while
+// the user api is down.
 func (s *Service) getUserGroupList(user *model.User, accessToken string) (
 	*models.UserUserGroupListResponse,
 	error,
 ) {
-	client := config.NewAPIClient(s.cnf.UserAPIHostname, s.cnf.UserAPIPort)
+	empty := &models.UserUserGroupListResponse{}
+
+	client, err := config.NewAPIClient(s.cnf)
+	if err != nil {
+		return empty, err
+	}
 
 	bearer := httptransport.BearerToken(accessToken)
 
@@ -343,21 +358,21 @@ func (s *Service) getUserGroupList(user *model.User, accessToken string) (
 
 	result, err := client.Usergroups.ResonateUserListUsersUserGroups(params, bearer)
 
-	if result == nil {
-		panic("User API not started")
-	}
-
 	if err != nil {
-		if casted, ok := err.(*usergroups.ResonateUserListUsersUserGroupsDefault); ok {
-			return nil, casted
+		if casted, ok := err.(*usergroups.ResonateUserListUsersUserGroupsDefault); ok && casted.Payload != nil {
+			return empty, errors.New(casted.Payload.Message)
 		}
+		return empty, err
 	}
 
-	return result.Payload, err
+	return result.Payload, nil
 }
 
 func (s *Service) createUserGroup(user *model.User, displayName, accessToken string) (*models.UserUserRequest, error) {
-	client := config.NewAPIClient(s.cnf.UserAPIHostname, s.cnf.UserAPIPort)
+	client, err := config.NewAPIClient(s.cnf)
+	if err != nil {
+		return nil, err
+	}
 
 	bearer := httptransport.BearerToken(accessToken)
 
@@ -372,11 +387,10 @@ func (s *Service) createUserGroup(user *model.User, displayName, accessToken str
 
 	result, err := client.Usergroups.ResonateUserAddUserGroup(params, bearer)
 
-	if result == nil {
-		panic("User API not started")
-	}
-
 	if err != nil {
+		if casted, ok := err.(*usergroups.ResonateUserAddUserGroupDefault); ok && casted.Payload != nil {
+			return nil, errors.New(casted.Payload.Message)
+		}
 		return nil, err
 	}
 
@@ -0,0 +1,90 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/csrf"
+	"github.com/resonatecoop/id/session"
+)
+
+var (
+	// ErrDeletionTokenMissing ...
+	ErrDeletionTokenMissing = errors.New("Account deletion token is missing")
+)
+
+// cancelAccountDeletionForm asks the user to confirm they keep their
+// account, mail scanners following the emailed link cancel nothing
+// (GET /web/account-deletion/cancel?token=...)
+func (s *Service) cancelAccountDeletionForm(w http.ResponseWriter, r *http.Request) {
+	// Get the session service from the request context
+	sessionService, err := getSessionService(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	token := r.Form.Get("token")
+
+	if token == "" {
+		s.cancelAccountDeletionDone(w, r, sessionService, ErrDeletionTokenMissing)
+		return
+	}
+
+	w.Header().Set("X-CSRF-Token", csrf.Token(r))
+
+	err = renderTemplate(w, r, "account_deletion_cancel.html", map[string]interface{}{
+		"token":          token,
+		csrf.TemplateTag: csrf.TemplateField(r),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// cancelAccountDeletion restores an account pending deletion
+// (POST /web/account-deletion/cancel)
+func (s *Service) cancelAccountDeletion(w http.ResponseWriter, r *http.Request) {
+	// Get the session service from the request context
+	sessionService, err := getSessionService(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	token := r.Form.Get("token")
+
+	if token == "" {
+		err = ErrDeletionTokenMissing
+	} else {
+		_, err = s.oauthService.CancelUserDeletion(r.Context(), token)
+	}
+
+	s.cancelAccountDeletionDone(w, r, sessionService, err)
+}
+
+// cancelAccountDeletionDone sends the user to the login page with the
+// outcome of the cancellation
+func (s *Service) cancelAccountDeletionDone(w http.ResponseWriter, r *http.Request, sessionService session.ServiceInterface, err error) {
+	query := r.URL.Query()
+	query.Del("token")
+
+	flash := &session.Flash{
+		Type:    "Info",
+		Message: "Your account deletion has been cancelled, you can log in again",
+	}
+	if err != nil {
+		flash = &session.Flash{
+			Type:    "Error",
+			Message: err.Error(),
+		}
+	}
+
+	if err = sessionService.SetFlashMessage(flash); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	redirectWithQueryString("/web/login", query, w, r)
+}
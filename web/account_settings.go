@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/gorilla/csrf"
+	"github.com/resonatecoop/id/i18n"
 	"github.com/resonatecoop/id/session"
 	"github.com/resonatecoop/id/util/response"
 )
@@ -56,13 +57,20 @@ func (s *Service) accountSettingsForm(w http.ResponseWriter, r *http.Request) {
 
 	profile := NewProfile(user, usergroups.Usergroup, isUserAccountComplete, credits, userSession.Role)
 
-	err = renderTemplate(w, "account_settings.html", map[string]interface{}{
+	// Users who never picked a language get the one of the page
+	preferredLocale, _ := s.oauthService.GetUserLocale(user)
+	if preferredLocale == "" {
+		preferredLocale = i18n.FromContext(r.Context())
+	}
+
+	err = renderTemplate(w, r, "account_settings.html", map[string]interface{}{
 		"appURL":                s.cnf.AppURL,
 		"applicationName":       client.ApplicationName.String,
 		"clientID":              client.Key,
 		"flash":                 flash,
 		"initialState":          template.HTML(fragment),
 		"isUserAccountComplete": isUserAccountComplete,
+		"preferredLocale":       preferredLocale,
 		"profile":               profile,
 		"queryString":           getQueryString(query),
 		"staticURL":             s.cnf.StaticURL,
@@ -102,7 +110,13 @@ func (s *Service) accountSettings(w http.ResponseWriter, r *http.Request) {
 	message := "Account not updated"
 
 	if method == "delete" || r.Method == http.MethodDelete {
+		// Only the user may delete their account
+		if s.forbidWhileImpersonating(w, r, sessionService, "account deletion") {
+			return
+		}
+
 		if err = s.oauthService.DeleteUser(
+			r.Context(),
 			user,
 			r.Form.Get("password"),
 		); err != nil {
@@ -137,9 +151,43 @@ func (s *Service) accountSettings(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if method == "put" || r.Method == http.MethodPut {
+		// update language, remembered on every device the user logs in to
+		if r.Form.Get("locale") != "" {
+			if err = s.oauthService.SetUserLocale(
+				user,
+				r.Form.Get("locale"),
+			); err != nil {
+				switch r.Header.Get("Accept") {
+				case "application/json":
+					response.Error(w, err.Error(), http.StatusBadRequest)
+				default:
+					err = sessionService.SetFlashMessage(&session.Flash{
+						Type:    "Error",
+						Message: err.Error(),
+					})
+					if err != nil {
+						http.Error(w, err.Error(), http.StatusInternalServerError)
+						return
+					}
+					http.Redirect(w, r, r.RequestURI, http.StatusFound)
+				}
+				return
+			}
+
+			i18n.SetCookie(w, s.cnf, r.Form.Get("locale"))
+
+			message = "Language updated"
+		}
+
 		// update email, requires password, sends notification
 		if r.Form.Get("email") != "" && r.Form.Get("email") != user.Username {
+			// Only the user may change their email
+			if s.forbidWhileImpersonating(w, r, sessionService, "email change") {
+				return
+			}
+
 			if err = s.oauthService.UpdateUsername(
+				r.Context(),
 				user,
 				r.Form.Get("email"),
 				r.Form.Get("password"),
@@ -199,6 +247,8 @@ func (s *Service) accountSettings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	query := r.URL.Query()
+	// the language picked wins over the one the page was shown in
+	query.Del(i18n.QueryParam)
 
 	redirectWithQueryString(redirectURI, query, w, r)
 	return
@@ -10,6 +10,9 @@ import (
 	"strconv"
 
 	"github.com/gorilla/csrf"
+	"github.com/resonatecoop/id/log"
+	"github.com/resonatecoop/id/metrics"
+	"github.com/resonatecoop/id/oauth"
 	"github.com/resonatecoop/id/session"
 	"github.com/resonatecoop/user-api/model"
 )
@@ -17,31 +20,71 @@ import (
 // ErrIncorrectResponseType a form value for response_type was not set to token or code
 var ErrIncorrectResponseType = errors.New("Response type not one of token or code")
 
+// authorizeErrorCodes maps the errors of authorization requests with a
+// valid redirect URI to the error codes sent to the client
+var authorizeErrorCodes = map[error]string{
+	ErrIncorrectResponseType: "unsupported_response_type",
+}
+
+// authorizeRequest is an authorization request of a logged in user, its
+// parameters come from a pushed request, a request object or the query
+// string, and its redirect URI was checked against those of the client
+type authorizeRequest struct {
+	sessionService session.ServiceInterface
+	client         *model.Client
+	user           *model.User
+	userSession    *session.UserSession
+	params         url.Values
+	responseType   string
+	redirectURI    *url.URL
+	state          string
+	credits        string
+}
+
+// consentScope is a scope listed on the consent screen, users may decline
+// optional scopes
+type consentScope struct {
+	Name        string
+	Description string
+	Optional    bool
+}
+
 func (s *Service) authorizeForm(w http.ResponseWriter, r *http.Request) {
-	sessionService, client, user, userSession, responseType, credits, _, err := s.authorizeCommon(r)
+	req, err := s.authorizeCommon(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		s.authorizeError(w, r, req, err)
+		return
+	}
+
+	// The co-op's own applications do not ask for consent
+	if s.oauthService.IsFirstPartyClient(req.client) {
+		scope, err := s.getAuthorizeScope(r, req.client, req.params.Get("scope"))
+		if err != nil {
+			errorRedirect(w, r, req.redirectURI, "invalid_scope", req.state, req.responseType)
+			return
+		}
+		s.authorizeGrant(w, r, req, scope, s.cnf.Oauth.AccessTokenLifetime)
 		return
 	}
 
 	w.Header().Set("X-CSRF-Token", csrf.Token(r))
 
-	isUserAccountComplete := s.isUserAccountComplete(userSession)
+	isUserAccountComplete := s.isUserAccountComplete(req.userSession)
 
 	// Render the template
-	flash, _ := sessionService.GetFlashMessage()
+	flash, _ := req.sessionService.GetFlashMessage()
 	query := r.URL.Query()
 	query.Set("login_redirect_uri", r.URL.Path)
 
-	usergroups, _ := s.getUserGroupList(user, userSession.AccessToken)
+	usergroups, _ := s.getUserGroupList(req.user, req.userSession.AccessToken)
 
 	initialState, err := json.Marshal(NewInitialState(
 		s.cnf,
-		client,
-		user,
-		userSession,
+		req.client,
+		req.user,
+		req.userSession,
 		isUserAccountComplete,
-		credits,
+		req.credits,
 		usergroups.Usergroup,
 		nil,
 		nil,
@@ -71,9 +114,9 @@ func (s *Service) authorizeForm(w http.ResponseWriter, r *http.Request) {
 	}
 
 	profile := &Profile{
-		Email:          user.Username,
-		EmailConfirmed: user.EmailConfirmed,
-		LegacyID:       user.LegacyID,
+		Email:          req.user.Username,
+		EmailConfirmed: req.user.EmailConfirmed,
+		LegacyID:       req.user.LegacyID,
 		Complete:       isUserAccountComplete,
 		Usergroups:     usergroupList,
 	}
@@ -82,17 +125,49 @@ func (s *Service) authorizeForm(w http.ResponseWriter, r *http.Request) {
 		profile.DisplayName = usergroups.Usergroup[0].DisplayName
 	}
 
-	err = renderTemplate(w, "authorize.html", map[string]interface{}{
+	// Describe what the client asks for, invalid scopes are rejected on submit
+	var scopes []consentScope
+	if scope, err := s.getAuthorizeScope(r, req.client, req.params.Get("scope")); err == nil {
+		found, _ := s.oauthService.FindScopes(scope)
+		for _, sc := range found {
+			scopes = append(scopes, consentScope{
+				Name:        sc.Name,
+				Description: sc.Description,
+				Optional:    !sc.IsDefault,
+			})
+		}
+	}
+
+	// Tell users who they are dealing with
+	metadata, err := s.oauthService.GetClientMetadata(req.client)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	verified, err := s.oauthService.IsClientVerified(req.client)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = renderTemplate(w, r, "authorize.html", map[string]interface{}{
 		"appURL":                s.cnf.AppURL,
-		"applicationName":       client.ApplicationName.String,
-		"clientID":              client.Key,
+		"applicationName":       req.client.ApplicationName.String,
+		"clientID":              req.client.Key,
+		"clientURI":             metadata.ClientURI,
 		"flash":                 flash,
 		"initialState":          template.HTML(fragment),
 		"isUserAccountComplete": isUserAccountComplete,
+		"logoURI":               metadata.LogoURI,
+		"policyURI":             metadata.PolicyURI,
 		"profile":               profile,
 		"queryString":           getQueryString(query),
+		"scopes":                scopes,
 		"staticURL":             s.cnf.StaticURL,
-		"token":                 responseType == "token",
+		"token":                 req.responseType == "token",
+		"tosURI":                metadata.TosURI,
+		"verified":              verified,
 		csrf.TemplateTag:        csrf.TemplateField(r),
 	})
 	if err != nil {
@@ -102,14 +177,13 @@ func (s *Service) authorizeForm(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Service) authorize(w http.ResponseWriter, r *http.Request) {
-	_, client, user, _, responseType, _, redirectURI, err := s.authorizeCommon(r)
+	req, err := s.authorizeCommon(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		s.authorizeError(w, r, req, err)
 		return
 	}
 
-	// Get the state parameter
-	state := r.Form.Get("state")
+	redirectURI, responseType, state := req.redirectURI, req.responseType, req.state
 
 	// Has the resource owner or authorization server denied the request?
 	authorized := len(r.Form.Get("allow")) > 0
@@ -119,18 +193,90 @@ func (s *Service) authorize(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check the requested scope
-	scope, err := s.oauthService.GetScope(r.Form.Get("scope"))
+	scope, err := s.getAuthorizeScope(r, req.client, req.params.Get("scope"))
 	if err != nil {
 		errorRedirect(w, r, redirectURI, "invalid_scope", state, responseType)
 		return
 	}
 
-	// Check redirect uri
-	if redirectURI.String() != client.RedirectURI.String {
-		errorRedirect(w, r, redirectURI, "invalid_redirect_uri", state, responseType)
+	// Only grant the optional scopes the user left ticked, forms without
+	// the selection grant everything
+	if r.Form.Get("scope_selection") != "" {
+		scope, err = s.oauthService.GetConsentedScope(scope, r.Form["granted_scope"])
+		if err == oauth.ErrInvalidScope {
+			errorRedirect(w, r, redirectURI, "access_denied", state, responseType)
+			return
+		}
+		if err != nil {
+			errorRedirect(w, r, redirectURI, "server_error", state, responseType)
+			return
+		}
+	}
+
+	// Get access token lifetime from user input
+	var lifetime int
+	if responseType == "token" {
+		lifetime, err = strconv.Atoi(r.Form.Get("lifetime"))
+		if err != nil {
+			errorRedirect(w, r, redirectURI, "server_error", state, responseType)
+			return
+		}
+	}
+
+	s.authorizeGrant(w, r, req, scope, lifetime)
+}
+
+// authorizeGrant issues the authorization code or the access token of an
+// authorization request the user consented to, access tokens expire after
+// lifetime seconds
+func (s *Service) authorizeGrant(w http.ResponseWriter, r *http.Request, req *authorizeRequest, scope string, lifetime int) {
+	client, user, userSession := req.client, req.user, req.userSession
+	redirectURI, responseType, state := req.redirectURI, req.responseType, req.state
+
+	// Admins acting as a user cannot grant clients access to the account,
+	// the tokens would outlive the impersonation and hide the actor
+	if impersonation, ok := getImpersonation(r); ok {
+		err := s.oauthService.RecordImpersonationEvent(
+			impersonation,
+			oauth.ImpersonationBlocked,
+			"authorization of "+client.Key,
+		)
+		if err != nil {
+			log.FromContext(r.Context()).ERROR.Print(err)
+		}
+		errorRedirect(w, r, redirectURI, "access_denied", state, responseType)
 		return
 	}
 
+	// Check the requested resources
+	resources, err := s.oauthService.GetResources(client, scope, req.params["resource"])
+	if err == oauth.ErrInvalidScope {
+		errorRedirect(w, r, redirectURI, "invalid_scope", state, responseType)
+		return
+	}
+	if err != nil {
+		errorRedirect(w, r, redirectURI, "invalid_target", state, responseType)
+		return
+	}
+
+	// Registered clients only use the grant types they registered
+	grantType := "authorization_code"
+	if responseType == "token" {
+		grantType = "implicit"
+	}
+	if err := s.oauthService.CheckGrantType(client, grantType); err != nil {
+		errorRedirect(w, r, redirectURI, "unauthorized_client", state, responseType)
+		return
+	}
+
+	// Pushed authorization requests are used once
+	if requestURI := r.Form.Get("request_uri"); requestURI != "" {
+		if err := s.oauthService.ConsumePushedAuthorizationRequest(client, requestURI); err != nil {
+			errorRedirect(w, r, redirectURI, "invalid_request_uri", state, responseType)
+			return
+		}
+	}
+
 	query := redirectURI.Query()
 
 	// When response_type == "code", we will grant an authorization code
@@ -142,12 +288,22 @@ func (s *Service) authorize(w http.ResponseWriter, r *http.Request) {
 			s.cnf.Oauth.AuthCodeLifetime, // expires in
 			redirectURI.String(),         // redirect URI
 			scope,                        // scope
+			resources...,                 // resources
 		)
 		if err != nil {
 			errorRedirect(w, r, redirectURI, "server_error", state, responseType)
 			return
 		}
 
+		// Tie the code to the session so logging out revokes its tokens
+		if userSession.SessionID != "" {
+			err = s.oauthService.AddSessionAuthorizationCode(userSession.SessionID, authorizationCode)
+			if err != nil {
+				errorRedirect(w, r, redirectURI, "server_error", state, responseType)
+				return
+			}
+		}
+
 		// Set query string params for the redirection URL
 		query.Set("code", authorizationCode.Code)
 		// Add state param if present (recommended)
@@ -161,25 +317,29 @@ func (s *Service) authorize(w http.ResponseWriter, r *http.Request) {
 
 	// When response_type == "token", we will directly grant an access token
 	if responseType == "token" {
-		// Get access token lifetime from user input
-		lifetime, err := strconv.Atoi(r.Form.Get("lifetime"))
-		if err != nil {
-			errorRedirect(w, r, redirectURI, "server_error", state, responseType)
-			return
-		}
-
 		// Grant an access token
 		accessToken, err := s.oauthService.GrantAccessToken(
-			client,   // client
-			user,     // user
-			lifetime, // expires in
-			scope,    // scope
+			client,       // client
+			user,         // user
+			lifetime,     // expires in
+			scope,        // scope
+			resources..., // resources
 		)
 		if err != nil {
 			errorRedirect(w, r, redirectURI, "server_error", state, responseType)
 			return
 		}
 
+		if userSession.SessionID != "" {
+			err = s.oauthService.AddSessionTokens(userSession.SessionID, accessToken, nil)
+			if err != nil {
+				errorRedirect(w, r, redirectURI, "server_error", state, responseType)
+				return
+			}
+		}
+
+		metrics.GrantsIssued.WithLabelValues("implicit").Inc()
+
 		// Set query string params for the redirection URL
 		query.Set("access_token", accessToken.Token)
 		query.Set("expires_in", fmt.Sprintf("%d", lifetime))
@@ -194,32 +354,66 @@ func (s *Service) authorize(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *Service) authorizeCommon(r *http.Request) (
-	session.ServiceInterface,
-	*model.Client,
-	*model.User,
-	*session.UserSession,
-	string,
-	string,
-	*url.URL,
-	error,
-) {
+// getAuthorizeScope returns the requested scope if the client and the realm
+// of the request allow it
+func (s *Service) getAuthorizeScope(r *http.Request, client *model.Client, requestedScope string) (string, error) {
+	scope, err := s.oauthService.GetClientScope(client, requestedScope)
+	if err != nil {
+		return "", err
+	}
+
+	if !s.getRealm(r).AllowsScope(scope) {
+		return "", oauth.ErrInvalidScope
+	}
+
+	return scope, nil
+}
+
+// authorizeError responds to an invalid authorization request. The client
+// is told about it once the redirect URI is known to be registered, the
+// user is shown the error otherwise (RFC 6749 section 4.1.2.1).
+func (s *Service) authorizeError(w http.ResponseWriter, r *http.Request, req *authorizeRequest, err error) {
+	if code, ok := authorizeErrorCodes[err]; ok && req != nil {
+		errorRedirect(w, r, req.redirectURI, code, req.state, "code")
+		return
+	}
+
+	status := http.StatusBadRequest
+	switch err {
+	case oauth.ErrInvalidRedirectURI,
+		oauth.ErrInvalidRequestURI,
+		oauth.ErrInvalidRequestObject,
+		oauth.ErrPushedAuthorizationRequired:
+	default:
+		status = http.StatusInternalServerError
+	}
+
+	err = renderTemplateWithStatus(w, r, "authorize_error.html", map[string]interface{}{
+		"error": err.Error(),
+	}, status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Service) authorizeCommon(r *http.Request) (*authorizeRequest, error) {
 	// Get the session service from the request context
 	sessionService, err := getSessionService(r)
 	if err != nil {
-		return nil, nil, nil, nil, "", "", nil, err
+		return nil, err
 	}
 
 	// Get the client from the request context
 	client, err := getClient(r)
 	if err != nil {
-		return nil, nil, nil, nil, "", "", nil, err
+		return nil, err
 	}
 
 	// Get the user session
 	userSession, err := sessionService.GetUserSession()
 	if err != nil {
-		return nil, nil, nil, nil, "", "", nil, err
+		return nil, err
 	}
 
 	// Fetch the user
@@ -227,47 +421,70 @@ func (s *Service) authorizeCommon(r *http.Request) (
 		userSession.Username,
 	)
 	if err != nil {
-		return nil, nil, nil, nil, "", "", nil, err
+		return nil, err
 	}
 
-	// Fetch the user
-	// user, err := s.oauthService.FindUserByEmail(
-	// 	userSession.Username,
-	// )
-	// if err != nil {
-	// 	return nil, nil, nil, "", "", nil, err
-	// }
+	// Resolve the pushed request or the request object
+	params, err := s.oauthService.GetAuthorizationRequest(
+		client,                       // client
+		r.Form,                       // form
+		s.getRealm(r).URL(s.cnf, ""), // issuer
+	)
+	if err != nil {
+		return nil, err
+	}
 
-	// nickname, err := s.oauthService.FindNicknameByWpUserID(wpuser.ID)
-	// if err != nil {
-	// 	return nil, nil, nil, "", "", nil, err
-	// }
+	// Fallback to the client redirect URI if not in query string, clients
+	// with several redirect URIs must say which one to use
+	redirectURI := params.Get("redirect_uri")
+	if redirectURI == "" {
+		redirectURIs, err := s.oauthService.GetClientRedirectURIs(client)
+		if err != nil {
+			return nil, err
+		}
+		if len(redirectURIs) != 1 {
+			return nil, oauth.ErrInvalidRedirectURI
+		}
+		redirectURI = redirectURIs[0]
+	}
 
-	// Set default response type
-	responseType := "code"
+	// Never redirect to a URI the client did not register
+	if !s.oauthService.IsValidRedirectURI(client, redirectURI) {
+		return nil, oauth.ErrInvalidRedirectURI
+	}
 
-	// Check the response_type is either "code" or "token"
-	if r.Form.Get("response_type") != "" {
-		responseType = r.Form.Get("response_type")
+	// Parse the redirect URL
+	parsedRedirectURI, err := url.ParseRequestURI(redirectURI)
+	if err != nil {
+		return nil, oauth.ErrInvalidRedirectURI
 	}
 
-	if responseType != "code" && responseType != "token" {
-		return nil, nil, nil, nil, "", "", nil, ErrIncorrectResponseType
+	req := &authorizeRequest{
+		sessionService: sessionService,
+		client:         client,
+		user:           user,
+		userSession:    userSession,
+		params:         params,
+		responseType:   "code", // default response type
+		redirectURI:    parsedRedirectURI,
+		state:          params.Get("state"),
 	}
 
-	// Fallback to the client redirect URI if not in query string
-	redirectURI := r.Form.Get("redirect_uri")
-	if redirectURI == "" {
-		redirectURI = client.RedirectURI.String
+	// Check the response_type is either "code" or "token"
+	if params.Get("response_type") != "" {
+		req.responseType = params.Get("response_type")
 	}
 
-	// // Parse the redirect URL
-	parsedRedirectURI, err := url.ParseRequestURI(redirectURI)
-	if err != nil {
-		return nil, nil, nil, nil, "", "", nil, err
+	if req.responseType != "code" && req.responseType != "token" {
+		return req, ErrIncorrectResponseType
 	}
 
+	// credits show as zero while the user api is down
 	result, err := s.getUserCredits(user, userSession.AccessToken)
+	if err != nil {
+		log.FromContext(r.Context()).ERROR.Print(err)
+	}
+	req.credits = formatCredit(result.Total)
 
-	return sessionService, client, user, userSession, responseType, formatCredit(result.Total), parsedRedirectURI, nil
+	return req, nil
 }
@@ -42,12 +42,12 @@ func (s *Service) checkoutForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	rlm := s.getRealm(r)
+
 	csrfToken := csrf.Token(r)
 
 	w.Header().Set("X-CSRF-Token", csrfToken)
 
-	stripe.Key = s.cnf.Stripe.Secret
-
 	stripe.SetAppInfo(&stripe.AppInfo{
 		Name:    "resonatecoop/id",
 		Version: "0.0.1",
@@ -91,7 +91,9 @@ func (s *Service) checkoutForm(w http.ResponseWriter, r *http.Request) {
 	products := []Product{}
 
 	for _, item := range checkoutSession.Products {
-		p, err := product.Get(item.ID, nil)
+		productParams := &stripe.ProductParams{}
+		rlm.UseStripeAccount(productParams)
+		p, err := product.Get(item.ID, productParams)
 
 		if err != nil {
 			break
@@ -170,7 +172,7 @@ func (s *Service) checkoutForm(w http.ResponseWriter, r *http.Request) {
 		profile.DisplayName = usergroups.Usergroup[0].DisplayName
 	}
 
-	err = renderTemplate(w, "checkout.html", map[string]interface{}{
+	err = renderTemplate(w, r, "checkout.html", map[string]interface{}{
 		"appURL":                s.cnf.AppURL,
 		"applicationName":       client.ApplicationName.String,
 		"clientID":              client.Key,
@@ -198,9 +200,9 @@ func (s *Service) checkoutSuccess(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("X-CSRF-Token", csrf.Token(r))
+	rlm := s.getRealm(r)
 
-	stripe.Key = s.cnf.Stripe.Secret
+	w.Header().Set("X-CSRF-Token", csrf.Token(r))
 
 	stripe.SetAppInfo(&stripe.AppInfo{
 		Name:    "resonatecoop/id",
@@ -241,7 +243,9 @@ func (s *Service) checkoutSuccess(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cs, err := stripeCheckoutSession.Get(checkoutSession.ID, nil)
+	checkoutSessionParams := &stripe.CheckoutSessionParams{}
+	rlm.UseStripeAccount(checkoutSessionParams)
+	cs, err := stripeCheckoutSession.Get(checkoutSession.ID, checkoutSessionParams)
 
 	if err != nil {
 		// checkout session not started/empty
@@ -276,7 +280,9 @@ func (s *Service) checkoutSuccess(w http.ResponseWriter, r *http.Request) {
 	products := []*stripe.Product{}
 
 	for _, item := range checkoutSession.Products {
-		p, err := product.Get(item.ID, nil)
+		productParams := &stripe.ProductParams{}
+		rlm.UseStripeAccount(productParams)
+		p, err := product.Get(item.ID, productParams)
 
 		if err != nil {
 			break
@@ -329,9 +335,9 @@ func (s *Service) checkoutCancel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("X-CSRF-Token", csrf.Token(r))
+	rlm := s.getRealm(r)
 
-	stripe.Key = s.cnf.Stripe.Secret
+	w.Header().Set("X-CSRF-Token", csrf.Token(r))
 
 	stripe.SetAppInfo(&stripe.AppInfo{
 		Name:    "resonatecoop/id",
@@ -375,7 +381,9 @@ func (s *Service) checkoutCancel(w http.ResponseWriter, r *http.Request) {
 	products := []*stripe.Product{}
 
 	for _, item := range checkoutSession.Products {
-		p, err := product.Get(item.ID, nil)
+		productParams := &stripe.ProductParams{}
+		rlm.UseStripeAccount(productParams)
+		p, err := product.Get(item.ID, productParams)
 
 		if err != nil {
 			break
@@ -399,9 +407,11 @@ func (s *Service) checkoutCancel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// expire checkout session
+	expireParams := &stripe.CheckoutSessionExpireParams{}
+	rlm.UseStripeAccount(expireParams)
 	_, err = stripeCheckoutSession.Expire(
 		checkoutSession.ID,
-		nil,
+		expireParams,
 	)
 
 	if err != nil {
@@ -447,9 +457,10 @@ func (s *Service) checkout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("X-CSRF-Token", csrf.Token(r))
+	rlm := s.getRealm(r)
+	stripeCnf := rlm.StripeConfig(s.cnf.Stripe)
 
-	stripe.Key = s.cnf.Stripe.Secret
+	w.Header().Set("X-CSRF-Token", csrf.Token(r))
 
 	stripe.SetAppInfo(&stripe.AppInfo{
 		Name:    "resonatecoop/id",
@@ -473,12 +484,13 @@ func (s *Service) checkout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	domain := s.cnf.Stripe.Domain
+	domain := stripeCnf.Domain
 
 	// retrieve stripe customer by email
 	customerListParams := &stripe.CustomerListParams{}
 	customerListParams.Filters.AddFilter("limit", "", "1")
 	customerListParams.Filters.AddFilter("email", "", user.Username)
+	rlm.UseStripeAccount(customerListParams)
 
 	i := cust.List(customerListParams)
 	err = i.Err()
@@ -509,14 +521,17 @@ func (s *Service) checkout(w http.ResponseWriter, r *http.Request) {
 		SuccessURL: stripe.String("https://" + domain + "/checkout/success"),
 		CancelURL:  stripe.String("https://" + domain + "/checkout/cancel"),
 	}
+	rlm.UseStripeAccount(params)
 
 	lineItems := []*stripe.CheckoutSessionLineItemParams{}
 	products := []*stripe.Product{}
 
 	for _, item := range checkoutSession.Products {
-		p, err := product.Get(item.ID, nil)
+		productParams := &stripe.ProductParams{}
+		rlm.UseStripeAccount(productParams)
+		p, err := product.Get(item.ID, productParams)
 
-		log.INFO.Printf("Product id: %s", p.ID)
+		log.FromContext(r.Context()).INFO.Printf("Product id: %s", p.ID)
 
 		if err != nil {
 			break
@@ -526,28 +541,28 @@ func (s *Service) checkout(w http.ResponseWriter, r *http.Request) {
 		credits := int64(0)  // 0 credits amount
 		products = append(products, p)
 
-		if item.ID == s.cnf.Stripe.ListenerSubscription.ID ||
-			item.ID == s.cnf.Stripe.ArtistMembership.ID ||
-			item.ID == s.cnf.Stripe.LabelMembership.ID {
+		if item.ID == stripeCnf.ListenerSubscription.ID ||
+			item.ID == stripeCnf.ArtistMembership.ID ||
+			item.ID == stripeCnf.LabelMembership.ID {
 			params.Mode = stripe.String(string(stripe.CheckoutSessionModeSubscription))
 			params.AddMetadata("product_id", item.ID)
 		}
 
-		if item.ID == s.cnf.Stripe.SupporterShares.ID {
+		if item.ID == stripeCnf.SupporterShares.ID {
 			s := strconv.FormatInt(item.Quantity, 10)
-			log.INFO.Printf("Number of shares: %s", s)
+			log.FromContext(r.Context()).INFO.Printf("Number of shares: %s", s)
 			params.AddMetadata("shares", s)
 			quantity = item.Quantity
 		}
 
 		switch item.ID {
-		case s.cnf.Stripe.StreamCredit5.ID:
+		case stripeCnf.StreamCredit5.ID:
 			credits = 5000
-		case s.cnf.Stripe.StreamCredit10.ID:
+		case stripeCnf.StreamCredit10.ID:
 			credits = 10000
-		case s.cnf.Stripe.StreamCredit20.ID:
+		case stripeCnf.StreamCredit20.ID:
 			credits = 20000
-		case s.cnf.Stripe.StreamCredit50.ID:
+		case stripeCnf.StreamCredit50.ID:
 			credits = 50000
 		}
 
@@ -7,12 +7,11 @@ import (
 	"net/http"
 
 	"github.com/gorilla/csrf"
+	"github.com/resonatecoop/id/oauth"
 	"github.com/resonatecoop/id/session"
 	"github.com/resonatecoop/id/util/response"
 	"github.com/resonatecoop/user-api-client/models"
 	"github.com/resonatecoop/user-api/model"
-	"github.com/rs/xid"
-	"github.com/thanhpk/randstr"
 )
 
 func (s *Service) clientForm(w http.ResponseWriter, r *http.Request) {
@@ -59,7 +58,7 @@ func (s *Service) clientForm(w http.ResponseWriter, r *http.Request) {
 		EmailConfirmed: user.EmailConfirmed,
 	}
 
-	err = renderTemplate(w, "client.html", map[string]interface{}{
+	err = renderTemplate(w, r, "client.html", map[string]interface{}{
 		"applicationName": client.ApplicationName.String,
 		"clientID":        client.Key,
 		"flash":           flash,
@@ -85,19 +84,16 @@ func (s *Service) client(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("X-CSRF-Token", csrf.Token(r))
 
-	guid := xid.New()
-
-	secret := randstr.Hex(16)
-
-	// Create a new client
-	client, err := s.oauthService.CreateClient(
-		guid.String(), // client id
-		secret,        // client secret
-		r.Form.Get("redirect_uri"),
-		r.Form.Get("application_name"), // name or short description
-		r.Form.Get("application_hostname"),
-		r.Form.Get("application_url"),
-	)
+	// Register a new client in the realm of the request
+	info, err := s.oauthService.RegisterClient(s.getRealm(r).ID, &oauth.ClientRegistration{
+		RedirectURIs: r.Form["redirect_uri"],
+		GrantTypes:   []string{"authorization_code", "refresh_token"},
+		ClientName:   r.Form.Get("application_name"), // name or short description
+		ClientURI:    r.Form.Get("application_url"),
+		LogoURI:      r.Form.Get("logo_uri"),
+		PolicyURI:    r.Form.Get("policy_uri"),
+		TosURI:       r.Form.Get("tos_uri"),
+	})
 
 	if err != nil {
 		switch r.Header.Get("Accept") {
@@ -120,11 +116,12 @@ func (s *Service) client(w http.ResponseWriter, r *http.Request) {
 	switch r.Header.Get("Accept") {
 	case "application/json":
 		data := map[string]interface{}{
-			"clientId":            client.Key,
-			"secret":              secret,
-			"applicationName":     client.ApplicationName,
-			"applicationHostname": client.ApplicationHostname,
-			"applicationURL":      client.ApplicationURL,
+			"clientId":                info.ClientID,
+			"secret":                  info.ClientSecret,
+			"registrationAccessToken": info.RegistrationAccessToken,
+			"redirectURIs":            info.RedirectURIs,
+			"applicationName":         info.ClientName,
+			"applicationURL":          info.ClientURI,
 		}
 
 		response.WriteJSON(w, map[string]interface{}{
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/gorilla/context"
+	"github.com/resonatecoop/id/oauth"
 	"github.com/resonatecoop/id/session"
 	"github.com/resonatecoop/user-api/model"
 )
@@ -14,6 +15,7 @@ type contextKey int
 const (
 	sessionServiceKey contextKey = 0
 	clientKey         contextKey = 1
+	impersonationKey  contextKey = 2
 )
 
 var (
@@ -52,3 +54,15 @@ func getClient(r *http.Request) (*model.Client, error) {
 
 	return client, nil
 }
+
+// Returns *oauth.Impersonation from the request context when an admin acts
+// as the user
+func getImpersonation(r *http.Request) (*oauth.Impersonation, bool) {
+	val, ok := context.GetOk(r, impersonationKey)
+	if !ok {
+		return nil, false
+	}
+
+	impersonation, ok := val.(*oauth.Impersonation)
+	return impersonation, ok
+}
@@ -2,9 +2,7 @@ package web
 
 import (
 	"errors"
-	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/gorilla/csrf"
 	"github.com/resonatecoop/id/session"
@@ -83,13 +81,44 @@ func (s *Service) getEmailConfirmationToken(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	role, err := userRole(s.oauthService, user)
+	if err != nil {
+		err = sessionService.SetFlashMessage(&session.Flash{
+			Type:    "Error",
+			Message: err.Error(),
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, r.RequestURI, http.StatusFound)
+		return
+	}
+
+	// Start the session logging out ends, the tokens clients get while it
+	// lasts are tied to it
+	loginSession, err := s.oauthService.StartSession(user, accessToken, refreshToken)
+	if err != nil {
+		err = sessionService.SetFlashMessage(&session.Flash{
+			Type:    "Error",
+			Message: err.Error(),
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, r.RequestURI, http.StatusFound)
+		return
+	}
+
 	// Log in the user and store the user session in a cookie
 	userSession := &session.UserSession{
 		ClientID:     client.Key,
 		Username:     user.Username,
-		Role:         strings.Split(accessToken.Scope, " ")[1],
+		Role:         role,
 		AccessToken:  accessToken.Token,
 		RefreshToken: refreshToken.Token,
+		SessionID:    loginSession.ID.String(),
 	}
 	if err := sessionService.SetUserSession(userSession); err != nil {
 		err = sessionService.SetFlashMessage(&session.Flash{
@@ -198,10 +227,7 @@ func (s *Service) resendEmailConfirmationToken(w http.ResponseWriter, r *http.Re
 	)
 	_, err = s.oauthService.SendEmailToken(
 		email,
-		fmt.Sprintf(
-			"https://%s/email-confirmation",
-			s.cnf.Hostname,
-		),
+		s.getRealm(r).URL(s.cnf, "/email-confirmation"),
 	)
 
 	if err != nil {
@@ -22,7 +22,7 @@ func (s *Service) homeForm(w http.ResponseWriter, r *http.Request) {
 		string(initialState),
 	)
 
-	err := renderTemplate(w, "home.html", map[string]interface{}{
+	err := renderTemplate(w, r, "home.html", map[string]interface{}{
 		"appURL":         s.cnf.AppURL,
 		"clients":        s.cnf.Clients,
 		"initialState":   template.HTML(fragment),
@@ -0,0 +1,298 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/context"
+	"github.com/gorilla/csrf"
+	"github.com/resonatecoop/id/log"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/rbac"
+	"github.com/resonatecoop/id/realm"
+	"github.com/resonatecoop/id/session"
+	"github.com/resonatecoop/id/util/response"
+	"github.com/resonatecoop/user-api/model"
+)
+
+// impersonateForm lets an admin pick the user to act as
+func (s *Service) impersonateForm(w http.ResponseWriter, r *http.Request) {
+	sessionService, client, actor, err := s.impersonateCommon(r)
+	if err != nil {
+		http.Error(w, err.Error(), getImpersonationStatusCode(err))
+		return
+	}
+
+	w.Header().Set("X-CSRF-Token", csrf.Token(r))
+
+	flash, _ := sessionService.GetFlashMessage()
+
+	profile := &Profile{
+		EmailConfirmed: actor.EmailConfirmed,
+	}
+
+	err = renderTemplate(w, r, "impersonate.html", map[string]interface{}{
+		"clientID":       client.Key,
+		"flash":          flash,
+		"lifetime":       s.cnf.Oauth.ImpersonationLifetime / 60,
+		"profile":        profile,
+		"queryString":    getQueryString(r.URL.Query()),
+		"staticURL":      s.cnf.StaticURL,
+		csrf.TemplateTag: csrf.TemplateField(r),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// impersonate logs the admin out of their own account and into the one of
+// the user, for cnf.Oauth.ImpersonationLifetime seconds at most
+func (s *Service) impersonate(w http.ResponseWriter, r *http.Request) {
+	sessionService, client, actor, err := s.impersonateCommon(r)
+	if err != nil {
+		http.Error(w, err.Error(), getImpersonationStatusCode(err))
+		return
+	}
+
+	fail := func(err error) {
+		err = sessionService.SetFlashMessage(&session.Flash{
+			Type:    "Error",
+			Message: err.Error(),
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, r.RequestURI, http.StatusFound)
+	}
+
+	user, err := s.oauthService.FindUserByUsername(r.Form.Get("email"))
+	if err == nil {
+		// users of other realms are unknown here
+		if err = s.oauthService.GetRealmService().CheckUser(r.Context(), user); err == realm.ErrRealmMismatch {
+			err = oauth.ErrUserNotFound
+		}
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	role, err := userRole(s.oauthService, user)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	impersonation, accessToken, err := s.oauthService.StartImpersonation(
+		client,
+		actor,
+		user,
+		r.Form.Get("reason"),
+	)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	// The admin leaves their own session
+	userSession, err := sessionService.GetUserSession()
+	if err == nil {
+		s.endSession(r, userSession)
+	}
+
+	err = sessionService.SetUserSession(&session.UserSession{
+		ClientID:        client.Key,
+		Username:        user.Username,
+		Role:            role,
+		AccessToken:     accessToken.Token,
+		SessionID:       impersonation.SessionID.String(),
+		ImpersonationID: impersonation.ID.String(),
+		Impersonator:    actor.Username,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Nothing of the admin's checkout carries over
+	if err = sessionService.ClearCheckoutSession(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	redirectWithQueryString("/web/profile", r.URL.Query(), w, r)
+}
+
+// impersonateCommon returns the admin about to act as a user, who needs the
+// users:impersonate permission and must not be impersonating already
+func (s *Service) impersonateCommon(r *http.Request) (
+	session.ServiceInterface,
+	*model.Client,
+	*model.User,
+	error,
+) {
+	if _, ok := getImpersonation(r); ok {
+		return nil, nil, nil, oauth.ErrImpersonating
+	}
+
+	sessionService, client, actor, err := s.passwordCommon(r)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	allowed, err := s.oauthService.GetRBACService().HasPermission(r.Context(), actor, rbac.PermissionUsersImpersonate)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !allowed {
+		return nil, nil, nil, oauth.ErrImpersonationNotAllowed
+	}
+
+	return sessionService, client, actor, nil
+}
+
+// endImpersonation ends the impersonation of a user session, if any, e.g. on
+// logout
+func (s *Service) endImpersonation(r *http.Request, userSession *session.UserSession) {
+	if userSession.ImpersonationID == "" {
+		return
+	}
+
+	impersonation, err := s.oauthService.FindImpersonation(userSession.ImpersonationID)
+	if err == nil {
+		err = s.oauthService.EndImpersonation(impersonation)
+	}
+	if err != nil {
+		log.FromContext(r.Context()).ERROR.Print(err)
+	}
+}
+
+// forbidWhileImpersonating stops admins acting as a user from doing what
+// only the user may do, e.g. changing their password. The access token of
+// the session is authenticated as the user's own, so the restriction holds
+// for any token obtained by an actor. The attempt is recorded and true is
+// returned when the request was answered.
+func (s *Service) forbidWhileImpersonating(w http.ResponseWriter, r *http.Request, sessionService session.ServiceInterface, action string) bool {
+	impersonation, impersonating := getImpersonation(r)
+
+	userSession, err := sessionService.GetUserSession()
+	if err == nil {
+		_, err = s.oauthService.AuthenticateOwner(userSession.AccessToken)
+	}
+	if err != oauth.ErrImpersonating && !impersonating {
+		return false
+	}
+
+	if impersonating {
+		err = s.oauthService.RecordImpersonationEvent(
+			impersonation,
+			oauth.ImpersonationBlocked,
+			action,
+		)
+		if err != nil {
+			log.FromContext(r.Context()).ERROR.Print(err)
+		}
+	}
+
+	if r.Header.Get("Accept") == "application/json" {
+		response.Error(w, oauth.ErrImpersonating.Error(), http.StatusForbidden)
+		return true
+	}
+
+	err = sessionService.SetFlashMessage(&session.Flash{
+		Type:    "Error",
+		Message: oauth.ErrImpersonating.Error(),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return true
+	}
+	redirectWithQueryString("/web/account-settings", r.URL.Query(), w, r)
+	return true
+}
+
+// getImpersonationData returns what the banner shown while impersonating
+// needs
+func getImpersonationData(r *http.Request) map[string]interface{} {
+	impersonation, ok := getImpersonation(r)
+	if !ok {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"actor":     impersonation.ActorUsername,
+		"username":  impersonation.Username,
+		"expiresAt": impersonation.ExpiresAt.Format("15:04 UTC"),
+	}
+}
+
+// auditImpersonatedRequest records changes made while impersonating
+func (m *loggedInMiddleware) auditImpersonatedRequest(r *http.Request, impersonation *oauth.Impersonation) {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return
+	}
+
+	detail := fmt.Sprintf("%s %s", r.Method, r.URL.Path)
+	if method := r.Form.Get("_method"); method != "" {
+		detail = fmt.Sprintf("%s %s", strings.ToUpper(method), r.URL.Path)
+	}
+
+	err := m.service.GetOauthService().RecordImpersonationEvent(
+		impersonation,
+		oauth.ImpersonationRequest,
+		detail,
+	)
+	if err != nil {
+		log.FromContext(r.Context()).ERROR.Print(err)
+	}
+}
+
+// checkImpersonation makes sure the impersonation a user session belongs to
+// is still active, impersonated sessions are never refreshed
+func (m *loggedInMiddleware) checkImpersonation(r *http.Request, userSession *session.UserSession) error {
+	oauthService := m.service.GetOauthService()
+
+	client, err := oauthService.FindClientByClientID(userSession.ClientID)
+	if err != nil {
+		return err
+	}
+
+	// Sessions started in another realm are not valid here
+	if err = oauthService.GetRealmService().CheckClient(r.Context(), client); err != nil {
+		return err
+	}
+
+	impersonation, err := oauthService.FindImpersonation(userSession.ImpersonationID)
+	if err != nil {
+		return err
+	}
+
+	if !impersonation.IsActive() {
+		if err := oauthService.EndImpersonation(impersonation); err != nil {
+			log.FromContext(r.Context()).ERROR.Print(err)
+		}
+		return oauth.ErrImpersonationEnded
+	}
+
+	if _, err = oauthService.Authenticate(userSession.AccessToken); err != nil {
+		return err
+	}
+
+	context.Set(r, impersonationKey, impersonation)
+
+	m.auditImpersonatedRequest(r, impersonation)
+
+	return nil
+}
+
+func getImpersonationStatusCode(err error) int {
+	switch err {
+	case oauth.ErrImpersonating, oauth.ErrImpersonationNotAllowed:
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
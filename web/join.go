@@ -7,7 +7,6 @@ import (
 	"html/template"
 	"net/http"
 
-	"github.com/resonatecoop/id/config"
 	"github.com/resonatecoop/id/log"
 	"github.com/resonatecoop/id/session"
 	"github.com/resonatecoop/id/util"
@@ -17,9 +16,6 @@ import (
 
 	"github.com/gorilla/csrf"
 	"github.com/pariz/gountries"
-
-	"github.com/resonatecoop/user-api-client/client/users"
-	"github.com/resonatecoop/user-api-client/models"
 )
 
 var (
@@ -67,7 +63,7 @@ func (s *Service) joinForm(w http.ResponseWriter, r *http.Request) {
 
 	// Render the template
 	flash, _ := sessionService.GetFlashMessage()
-	err = renderTemplate(w, "join.html", map[string]interface{}{
+	err = renderTemplate(w, r, "join.html", map[string]interface{}{
 		"appURL":         s.cnf.AppURL,
 		"countries":      countries,
 		"flash":          flash,
@@ -120,6 +116,15 @@ func (s *Service) join(w http.ResponseWriter, r *http.Request) {
 		}
 		response.WriteJSON(w, obj, http.StatusCreated)
 	} else {
+		// the address is left out so the message can be translated
+		err = sessionService.SetFlashMessage(&session.Flash{
+			Type:    "Info",
+			Message: "A confirmation email is on its way",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 		query := r.URL.Query()
 		query.Set("login_redirect_uri", "/web/profile")
 		redirectWithQueryString("/web/login", query, w, r)
@@ -131,14 +136,11 @@ func (s *Service) join(w http.ResponseWriter, r *http.Request) {
 			"Member details",    // Subject
 			"signup",            // Template (mailgun)
 		),
-		fmt.Sprintf(
-			"https://%s/email-confirmation",
-			s.cnf.Hostname,
-		),
+		s.getRealm(r).URL(s.cnf, "/email-confirmation"),
 	)
 
 	if err != nil {
-		log.ERROR.Print(err)
+		log.FromContext(r.Context()).ERROR.Print(err)
 	}
 }
 
@@ -146,7 +148,7 @@ func (s *Service) createUser(r *http.Request) (
 	*model.User,
 	error,
 ) {
-	// first validate password before calling user-api
+	// first validate password before creating the user
 	if err := password.ValidatePassword(r.Form.Get("password")); err != nil {
 		return nil, err
 	}
@@ -156,42 +158,43 @@ func (s *Service) createUser(r *http.Request) (
 		return nil, ErrEmailInvalid
 	}
 
-	client := config.NewAPIClient(s.cnf.UserAPIHostname, s.cnf.UserAPIPort)
-
-	params := users.NewResonateUserAddUserParams()
-
-	params.Body = &models.UserUserAddRequest{
-		Username: r.Form.Get("email"),
-		Country:  r.Form.Get("country"),
-	}
-
-	switch r.Form.Get("role") {
-	case "artist":
-		params.Body.RoleID = int32(model.ArtistRole)
-	}
-	// case "label":
-	//	params.Body.RoleID = int32(model.LabelRole)
-	//}
-
-	// Create a user
-	_, err := client.Users.ResonateUserAddUser(params, nil)
+	// members may sign up as artists, labels or both, new users belong to
+	// the realm they signed up in
+	return s.oauthService.CreateUser(
+		s.getRealm(r),
+		r.Form.Get("email"),
+		r.Form.Get("password"),
+		r.Form.Get("country"),
+		signupRoles(r.Form["role"]),
+	)
+}
 
-	if err != nil {
-		if casted, ok := err.(*users.ResonateUserAddUserDefault); ok {
-			err = errors.New(casted.Payload.Message)
-			return nil, err
+// signupRoles returns the IDs of the roles picked on the join form, users
+// may only pick the artist and label roles
+func signupRoles(values []string) []int32 {
+	roleIDs := []int32{}
+	for _, value := range values {
+		var roleID int32
+		switch value {
+		case "artist":
+			roleID = int32(model.ArtistRole)
+		case "label":
+			roleID = int32(model.LabelRole)
+		default:
+			continue
+		}
+		if !containsRoleID(roleIDs, roleID) {
+			roleIDs = append(roleIDs, roleID)
 		}
 	}
+	return roleIDs
+}
 
-	user, err := s.oauthService.FindUserByUsername(r.Form.Get("email"))
-
-	if err != nil {
-		return nil, err
-	}
-
-	if err = s.oauthService.SetPassword(user, r.Form.Get("password")); err != nil {
-		return nil, err
+func containsRoleID(roleIDs []int32, roleID int32) bool {
+	for _, id := range roleIDs {
+		if id == roleID {
+			return true
+		}
 	}
-
-	return user, nil
+	return false
 }
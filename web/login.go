@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
-	"strings"
 
 	"github.com/gorilla/csrf"
+	"github.com/resonatecoop/id/i18n"
+	"github.com/resonatecoop/id/metrics"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/realm"
 	"github.com/resonatecoop/id/session"
 	"github.com/resonatecoop/id/util/response"
 	"github.com/resonatecoop/user-api/model"
@@ -35,7 +38,7 @@ func (s *Service) loginForm(w http.ResponseWriter, r *http.Request) {
 
 	flash, _ := sessionService.GetFlashMessage()
 
-	err = renderTemplate(w, "login.html", map[string]interface{}{
+	err = renderTemplate(w, r, "login.html", map[string]interface{}{
 		"appURL":         s.cnf.AppURL,
 		"flash":          flash,
 		"initialState":   template.HTML(fragment),
@@ -65,11 +68,20 @@ func (s *Service) login(w http.ResponseWriter, r *http.Request) {
 
 	// Authenticate the user
 	user, err := s.oauthService.AuthUser(
+		r.Context(),
 		r.Form.Get("email"),    // email/username
 		r.Form.Get("password"), // password
 	)
+	if err == nil {
+		// users of other realms are unknown here
+		if err = s.oauthService.GetRealmService().CheckUser(r.Context(), user); err == realm.ErrRealmMismatch {
+			err = oauth.ErrUserNotFound
+		}
+	}
 
 	if err != nil {
+		metrics.FailedLogins.WithLabelValues("web").Inc()
+
 		switch r.Header.Get("Accept") {
 		case "application/json":
 			response.Error(w, err.Error(), http.StatusBadRequest)
@@ -113,10 +125,7 @@ func (s *Service) login(w http.ResponseWriter, r *http.Request) {
 		)
 		_, _ = s.oauthService.SendEmailToken(
 			email,
-			fmt.Sprintf(
-				"https://%s/email-confirmation",
-				s.cnf.Hostname,
-			),
+			s.getRealm(r).URL(s.cnf, "/email-confirmation"),
 		)
 
 		return
@@ -156,15 +165,44 @@ func (s *Service) login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	scopes := strings.Split(accessToken.Scope, " ")
+	role, err := userRole(s.oauthService, user)
+	if err != nil {
+		err = sessionService.SetFlashMessage(&session.Flash{
+			Type:    "Error",
+			Message: err.Error(),
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, r.RequestURI, http.StatusFound)
+		return
+	}
+
+	// Start the session logging out ends, the tokens clients get while it
+	// lasts are tied to it
+	loginSession, err := s.oauthService.StartSession(user, accessToken, refreshToken)
+	if err != nil {
+		err = sessionService.SetFlashMessage(&session.Flash{
+			Type:    "Error",
+			Message: err.Error(),
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, r.RequestURI, http.StatusFound)
+		return
+	}
 
 	// Log in the user and store the user session in a cookie
 	userSession := &session.UserSession{
 		ClientID:     client.Key,
 		Username:     user.Username,
-		Role:         scopes[1],
+		Role:         role,
 		AccessToken:  accessToken.Token,
 		RefreshToken: refreshToken.Token,
+		SessionID:    loginSession.ID.String(),
 	}
 	if err := sessionService.SetUserSession(userSession); err != nil {
 		err = sessionService.SetFlashMessage(&session.Flash{
@@ -179,6 +217,11 @@ func (s *Service) login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Switch to the language the user picked, maybe on another device
+	if locale, err := s.oauthService.GetUserLocale(user); err == nil && locale != "" {
+		i18n.SetCookie(w, s.cnf, locale)
+	}
+
 	// Redirect to the authorize page by default but allow redirection to other
 	// pages by specifying a path with login_redirect_uri query string param
 	loginRedirectURI := r.URL.Query().Get("login_redirect_uri")
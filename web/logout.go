@@ -2,8 +2,17 @@ package web
 
 import (
 	"net/http"
+	"net/url"
+
+	"github.com/resonatecoop/id/log"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/session"
 )
 
+// logout ends the session of the user (OpenID Connect RP-Initiated Logout).
+// Every token tied to the session is revoked, the clients it was issued to
+// are notified through the back-channel and the front-channel before the
+// user is sent to the post logout redirect URI.
 func (s *Service) logout(w http.ResponseWriter, r *http.Request) {
 	// Get the session service from the request context
 	sessionService, err := getSessionService(r)
@@ -12,30 +21,112 @@ func (s *Service) logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get the user session
-	userSession, err := sessionService.GetUserSession()
+	// Check where to send the user before logging them out
+	redirectURI, err := s.getPostLogoutRedirectURI(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Delete the access and refresh tokens
-	s.oauthService.ClearUserTokens(userSession)
+	frontchannelURIs := []string{}
 
-	// Delete the user session
-	err = sessionService.ClearUserSession()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// Clients may log out users who already are
+	userSession, err := sessionService.GetUserSession()
+	if err == nil {
+		frontchannelURIs = s.endSession(r, userSession)
+
+		// Delete the user session
+		err = sessionService.ClearUserSession()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Delete the checkout session
+		err = sessionService.ClearCheckoutSession()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if len(frontchannelURIs) == 0 {
+		http.Redirect(w, r, redirectURI, http.StatusFound)
 		return
 	}
 
-	// Delete the checkout session
-	err = sessionService.ClearCheckoutSession()
+	// The logout page loads the front-channel logout URIs in iframes
+	err = renderTemplate(w, r, "logout.html", map[string]interface{}{
+		"frontchannelURIs": frontchannelURIs,
+		"redirectURI":      redirectURI,
+	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+}
+
+// endSession revokes the tokens of a user session and notifies the clients
+// through the back-channel. It returns the front-channel logout URIs.
+func (s *Service) endSession(r *http.Request, userSession *session.UserSession) []string {
+	defer s.endImpersonation(r, userSession)
+
+	// Sessions started before logout was tracked only hold the tokens of
+	// the client they were started with
+	if userSession.SessionID == "" {
+		s.oauthService.ClearUserTokens(userSession)
+		return []string{}
+	}
+
+	logout, err := s.oauthService.EndSession(userSession.SessionID)
+	if err != nil {
+		if err != oauth.ErrSessionNotFound {
+			log.FromContext(r.Context()).ERROR.Print(err)
+		}
+		s.oauthService.ClearUserTokens(userSession)
+		return []string{}
+	}
+
+	issuer := s.getRealm(r).URL(s.cnf, "")
+
+	// Failures are logged, the user is logged out regardless
+	_ = s.oauthService.NotifyBackchannelLogout(r.Context(), issuer, logout)
+
+	return s.oauthService.FrontchannelLogoutURIs(issuer, logout)
+}
+
+// getPostLogoutRedirectURI returns where to send the user once logged out,
+// post_logout_redirect_uri must be registered for the client_id of the
+// request. The login page is used when there is none.
+func (s *Service) getPostLogoutRedirectURI(r *http.Request) (string, error) {
+	postLogoutRedirectURI := r.Form.Get("post_logout_redirect_uri")
+	if postLogoutRedirectURI == "" {
+		return "/web/login" + getQueryString(r.URL.Query()), nil
+	}
+
+	client, err := s.oauthService.FindClientByClientID(r.Form.Get("client_id"))
+	if err == nil {
+		// clients of other realms are unknown here
+		err = s.oauthService.GetRealmService().CheckClient(r.Context(), client)
+	}
+	if err != nil {
+		return "", oauth.ErrInvalidRedirectURI
+	}
+
+	if !s.oauthService.IsValidPostLogoutRedirectURI(client, postLogoutRedirectURI) {
+		return "", oauth.ErrInvalidRedirectURI
+	}
+
+	u, err := url.Parse(postLogoutRedirectURI)
+	if err != nil {
+		return "", oauth.ErrInvalidRedirectURI
+	}
+
+	if state := r.Form.Get("state"); state != "" {
+		query := u.Query()
+		query.Set("state", state)
+		u.RawQuery = query.Encode()
+	}
 
-	// Redirect back to the login page
-	redirectWithQueryString("/web/login", r.URL.Query(), w, r)
+	return u.String(), nil
 }
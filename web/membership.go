@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/gorilla/csrf"
+	"github.com/resonatecoop/id/config"
 	"github.com/resonatecoop/id/session"
 	"github.com/stripe/stripe-go/v72"
 	cust "github.com/stripe/stripe-go/v72/customer"
@@ -41,7 +42,7 @@ func (s *Service) NewShare(invoice *stripe.Invoice, invoiceLine *stripe.InvoiceL
 }
 
 // NewMembership
-func (s *Service) NewMembership(subscription *stripe.Subscription) Membership {
+func (s *Service) NewMembership(subscription *stripe.Subscription, stripeCnf config.StripeConfig) Membership {
 	var (
 		name         string = "Listener"
 		sign         string = "€"
@@ -50,11 +51,11 @@ func (s *Service) NewMembership(subscription *stripe.Subscription) Membership {
 
 	item := subscription.Items.Data[0]
 
-	if item.Price.Product.ID == s.cnf.Stripe.ArtistMembership.ID {
+	if item.Price.Product.ID == stripeCnf.ArtistMembership.ID {
 		name = "Artist"
 	}
 
-	if item.Price.Product.ID == s.cnf.Stripe.LabelMembership.ID {
+	if item.Price.Product.ID == stripeCnf.LabelMembership.ID {
 		name = "Label"
 	}
 
@@ -88,6 +89,9 @@ func (s *Service) membershipForm(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("X-CSRF-Token", csrf.Token(r))
 
+	rlm := s.getRealm(r)
+	stripeCnf := rlm.StripeConfig(s.cnf.Stripe)
+
 	if !isUserAccountComplete {
 		err = sessionService.SetFlashMessage(&session.Flash{
 			Type:    "Info",
@@ -108,8 +112,6 @@ func (s *Service) membershipForm(w http.ResponseWriter, r *http.Request) {
 
 	query.Set("login_redirect_uri", r.URL.Path)
 
-	stripe.Key = s.cnf.Stripe.Secret
-
 	stripe.SetAppInfo(&stripe.AppInfo{
 		Name:    "resonatecoop/id",
 		Version: "0.0.1",
@@ -120,6 +122,7 @@ func (s *Service) membershipForm(w http.ResponseWriter, r *http.Request) {
 	customerListParams := &stripe.CustomerListParams{}
 	customerListParams.Filters.AddFilter("limit", "", "1")
 	customerListParams.Filters.AddFilter("email", "", user.Username)
+	rlm.UseStripeAccount(customerListParams)
 
 	ci := cust.List(customerListParams)
 	err = ci.Err()
@@ -152,6 +155,7 @@ func (s *Service) membershipForm(w http.ResponseWriter, r *http.Request) {
 	}
 
 	subcriptionListParams.Filters.AddFilter("customer", "", customer.ID)
+	rlm.UseStripeAccount(subcriptionListParams)
 
 	si := sub.List(subcriptionListParams)
 	err = si.Err()
@@ -175,10 +179,12 @@ func (s *Service) membershipForm(w http.ResponseWriter, r *http.Request) {
 	for si.Next() {
 		subscription := si.Subscription()
 
-		subscription, _ = sub.Get(subscription.ID, nil)
+		subscriptionParams := &stripe.SubscriptionParams{}
+		rlm.UseStripeAccount(subscriptionParams)
+		subscription, _ = sub.Get(subscription.ID, subscriptionParams)
 		// TODO handle err
 
-		membership := s.NewMembership(subscription)
+		membership := s.NewMembership(subscription, stripeCnf)
 
 		memberships = append(memberships, membership)
 	}
@@ -189,6 +195,7 @@ func (s *Service) membershipForm(w http.ResponseWriter, r *http.Request) {
 	invoiceListParams := &stripe.InvoiceListParams{}
 	invoiceListParams.Filters.AddFilter("limit", "", "50")
 	invoiceListParams.Filters.AddFilter("customer", "", customer.ID)
+	rlm.UseStripeAccount(invoiceListParams)
 
 	inv := invoice.List(invoiceListParams)
 	err = inv.Err()
@@ -210,7 +217,7 @@ func (s *Service) membershipForm(w http.ResponseWriter, r *http.Request) {
 		in := inv.Invoice()
 
 		for _, inl := range in.Lines.Data {
-			if inl.Price.Product.ID == s.cnf.Stripe.SupporterShares.ID {
+			if inl.Price.Product.ID == stripeCnf.SupporterShares.ID {
 				share := s.NewShare(in, inl)
 				shares = append(shares, share)
 			}
@@ -260,7 +267,7 @@ func (s *Service) membershipForm(w http.ResponseWriter, r *http.Request) {
 
 	profile := NewProfile(user, usergroups.Usergroup, isUserAccountComplete, credits, userSession.Role)
 
-	err = renderTemplate(w, "membership.html", map[string]interface{}{
+	err = renderTemplate(w, r, "membership.html", map[string]interface{}{
 		"appURL":                s.cnf.AppURL,
 		"applicationName":       client.ApplicationName.String,
 		"clientID":              client.Key,
@@ -289,7 +296,11 @@ func (s *Service) membership(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = sub.Cancel(r.Form.Get("id"), nil)
+	rlm := s.getRealm(r)
+
+	cancelParams := &stripe.SubscriptionCancelParams{}
+	rlm.UseStripeAccount(cancelParams)
+	_, err = sub.Cancel(r.Form.Get("id"), cancelParams)
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -2,11 +2,13 @@ package web
 
 import (
 	"net/http"
-	"strings"
 
 	"github.com/gorilla/context"
 	"github.com/gorilla/csrf"
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/realm"
 	"github.com/resonatecoop/id/session"
+	"github.com/resonatecoop/user-api/model"
 )
 
 // parseFormMiddleware parses the form so r.Form becomes available
@@ -79,6 +81,33 @@ func (m *guestMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, next
 	next(w, r)
 }
 
+// sessionMiddleware initialises session whether the user is logged in or not
+type sessionMiddleware struct {
+	service ServiceInterface
+}
+
+// newSessionMiddleware creates a new sessionMiddleware instance
+func newSessionMiddleware(service ServiceInterface) *sessionMiddleware {
+	return &sessionMiddleware{service: service}
+}
+
+// ServeHTTP as per the negroni.Handler interface
+func (m *sessionMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	// Initialise the session service
+	m.service.setSessionService(r, w)
+	sessionService := m.service.GetSessionService()
+
+	// Attempt to start the session
+	if err := sessionService.StartSession(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	context.Set(r, sessionServiceKey, sessionService)
+
+	next(w, r)
+}
+
 // loggedInMiddleware initialises session and makes sure the user is logged in
 type loggedInMiddleware struct {
 	service ServiceInterface
@@ -112,8 +141,13 @@ func (m *loggedInMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, n
 		return
 	}
 
-	// Authenticate
-	if err := m.authenticate(userSession); err != nil {
+	// Authenticate, impersonated sessions end with the impersonation
+	if userSession.ImpersonationID != "" {
+		err = m.checkImpersonation(r, userSession)
+	} else {
+		err = m.authenticate(r, userSession)
+	}
+	if err != nil {
 		// Delete the user session
 		err = sessionService.ClearUserSession()
 		if err != nil {
@@ -144,15 +178,7 @@ func (m *loggedInMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, n
 	next(w, r)
 }
 
-func (m *loggedInMiddleware) authenticate(userSession *session.UserSession) error {
-	// Try to authenticate with the stored access token
-	_, err := m.service.GetOauthService().Authenticate(userSession.AccessToken)
-	if err == nil {
-		// Access token valid, return
-		return nil
-	}
-	// Access token might be expired, let's try refreshing...
-
+func (m *loggedInMiddleware) authenticate(r *http.Request, userSession *session.UserSession) error {
 	// Fetch the client
 	client, err := m.service.GetOauthService().FindClientByClientID(
 		userSession.ClientID, // client ID
@@ -161,6 +187,20 @@ func (m *loggedInMiddleware) authenticate(userSession *session.UserSession) erro
 		return err
 	}
 
+	// Sessions started in another realm are not valid here
+	err = m.service.GetOauthService().GetRealmService().CheckClient(r.Context(), client)
+	if err != nil {
+		return err
+	}
+
+	// Try to authenticate with the stored access token
+	_, err = m.service.GetOauthService().Authenticate(userSession.AccessToken)
+	if err == nil {
+		// Access token valid, return
+		return nil
+	}
+	// Access token might be expired, let's try refreshing...
+
 	// Validate the refresh token
 	theRefreshToken, err := m.service.GetOauthService().GetValidRefreshToken(
 		userSession.RefreshToken, // refresh token
@@ -180,9 +220,20 @@ func (m *loggedInMiddleware) authenticate(userSession *session.UserSession) erro
 		return err
 	}
 
-	scopes := strings.Split(accessToken.Scope, " ")
+	// Sessions started before logout was tracked have no ID
+	if userSession.SessionID != "" {
+		err = m.service.GetOauthService().AddSessionTokens(userSession.SessionID, accessToken, refreshToken)
+		if err != nil {
+			return err
+		}
+	}
+
+	role, err := userRole(m.service.GetOauthService(), theRefreshToken.User)
+	if err != nil {
+		return err
+	}
 
-	userSession.Role = scopes[1] // user, artist, label, admin, tenantadmin, ...
+	userSession.Role = role // user, artist, label, admin, tenantadmin, ...
 	userSession.AccessToken = accessToken.Token
 	userSession.RefreshToken = refreshToken.Token
 
@@ -214,6 +265,9 @@ func (m *clientMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, nex
 		client, err := m.service.GetOauthService().FindClientByClientID(
 			r.Form.Get("client_id"), // client ID
 		)
+		if err == nil {
+			err = m.checkRealm(r, client)
+		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -225,7 +279,9 @@ func (m *clientMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, nex
 		client, err := m.service.GetOauthService().FindClientByApplicationURL(
 			redirect,
 		)
-
+		if err == nil {
+			err = m.checkRealm(r, client)
+		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -236,3 +292,12 @@ func (m *clientMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, nex
 
 	next(w, r)
 }
+
+// checkRealm hides clients of other realms
+func (m *clientMiddleware) checkRealm(r *http.Request, client *model.Client) error {
+	err := m.service.GetOauthService().GetRealmService().CheckClient(r.Context(), client)
+	if err == realm.ErrRealmMismatch {
+		return oauth.ErrClientNotFound
+	}
+	return err
+}
@@ -7,9 +7,10 @@ import (
 	"time"
 
 	"github.com/gorilla/csrf"
-	"github.com/mailgun/mailgun-go/v4"
 	"github.com/resonatecoop/id/log"
+	"github.com/resonatecoop/id/metrics"
 	"github.com/resonatecoop/id/session"
+	"github.com/resonatecoop/id/tracing"
 	pass "github.com/resonatecoop/id/util/password"
 	"github.com/resonatecoop/id/util/response"
 	"github.com/resonatecoop/user-api/model"
@@ -32,6 +33,11 @@ func (s *Service) passwordUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Only the user may change their password
+	if s.forbidWhileImpersonating(w, r, sessionService, "password change") {
+		return
+	}
+
 	// verify current password
 	if pass.VerifyPassword(user.Password.String, r.Form.Get("password")) != nil {
 		if r.Header.Get("Accept") == "application/json" {
@@ -106,8 +112,9 @@ func (s *Service) passwordUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Inform user by email password was changed
-	mg := mailgun.NewMailgun(s.cnf.Mailgun.Domain, s.cnf.Mailgun.Key)
-	sender := s.cnf.Mailgun.Sender
+	rlm := s.getRealm(r)
+	mg := tracing.NewMailgun(s.cnf.Mailgun.Domain, s.cnf.Mailgun.Key)
+	sender := rlm.Sender(s.cnf)
 	body := ""
 	email := model.NewOauthEmail(
 		user.Username,
@@ -117,11 +124,11 @@ func (s *Service) passwordUpdate(w http.ResponseWriter, r *http.Request) {
 	subject := email.Subject
 	recipient := email.Recipient
 	message := mg.NewMessage(sender, subject, body, recipient)
-	message.SetTemplate(email.Template) // set mailgun template
+	message.SetTemplate(rlm.Template(email.Template)) // set mailgun template
 	err = message.AddTemplateVariable("email", recipient)
 
 	if err != nil {
-		log.ERROR.Print(err)
+		log.FromContext(r.Context()).ERROR.Print(err)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
@@ -129,9 +136,10 @@ func (s *Service) passwordUpdate(w http.ResponseWriter, r *http.Request) {
 
 	// Send the message with a 10 second timeout
 	_, _, err = mg.Send(ctx, message)
+	metrics.ObserveEmail(email.Template, err)
 
 	if err != nil {
-		log.ERROR.Print(err)
+		log.FromContext(r.Context()).ERROR.Print(err)
 	}
 
 	redirectWithQueryString("/web/account-settings", r.URL.Query(), w, r)
@@ -60,7 +60,7 @@ func (s *Service) passwordResetForm(w http.ResponseWriter, r *http.Request) {
 
 	flash, _ := sessionService.GetFlashMessage()
 
-	err = renderTemplate(w, layoutTemplate, map[string]interface{}{
+	err = renderTemplate(w, r, layoutTemplate, map[string]interface{}{
 		"token":          token,
 		"flash":          flash,
 		"clients":        s.cnf.Clients,
@@ -123,18 +123,18 @@ func (s *Service) passwordReset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// send password reset token
-	_, err = s.oauthService.SendEmailToken(
-		model.NewOauthEmail(
-			r.Form.Get("email"),
-			"Reset your password",
-			"password-reset",
-		),
-		fmt.Sprintf(
-			"https://%s/password-reset",
-			s.cnf.Hostname,
-		),
-	)
+	// send password reset token, users of other realms are unknown here
+	err = s.checkUserRealm(r, r.Form.Get("email"))
+	if err == nil {
+		_, err = s.oauthService.SendEmailToken(
+			model.NewOauthEmail(
+				r.Form.Get("email"),
+				"Reset your password",
+				"password-reset",
+			),
+			s.getRealm(r).URL(s.cnf, "/password-reset"),
+		)
+	}
 
 	if err != nil {
 		status := http.StatusBadRequest
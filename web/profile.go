@@ -8,6 +8,7 @@ import (
 
 	"github.com/gorilla/csrf"
 	"github.com/pariz/gountries"
+	"github.com/resonatecoop/id/log"
 	"github.com/resonatecoop/id/session"
 	"github.com/resonatecoop/user-api/model"
 	"github.com/shopspring/decimal"
@@ -74,7 +75,7 @@ func (s *Service) profileForm(w http.ResponseWriter, r *http.Request) {
 
 	profile := NewProfile(user, usergroups.Usergroup, isUserAccountComplete, credits, userSession.Role)
 
-	err = renderTemplate(w, "profile.html", map[string]interface{}{
+	err = renderTemplate(w, r, "profile.html", map[string]interface{}{
 		"appURL":                s.cnf.AppURL,
 		"applicationName":       client.ApplicationName.String,
 		"clientID":              client.Key,
@@ -128,7 +129,11 @@ func (s *Service) profileCommon(r *http.Request) (
 		return nil, nil, nil, false, "", nil, err
 	}
 
+	// credits show as zero while the user api is down
 	result, err := s.getUserCredits(user, userSession.AccessToken)
+	if err != nil {
+		log.FromContext(r.Context()).ERROR.Print(err)
+	}
 
 	// Check if user account is complete
 	isUserAccountComplete := s.isUserAccountComplete(userSession)
@@ -0,0 +1,30 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/id/realm"
+)
+
+// getRealm returns the realm the request was routed to
+func (s *Service) getRealm(r *http.Request) *realm.Realm {
+	if rlm, ok := realm.FromContext(r.Context()); ok {
+		return rlm
+	}
+	return realm.Default(s.cnf)
+}
+
+// checkUserRealm hides users of other realms behind oauth.ErrEmailNotFound
+func (s *Service) checkUserRealm(r *http.Request, username string) error {
+	rlm, err := s.oauthService.GetRealmService().ForUsername(r.Context(), username)
+	if err != nil {
+		return err
+	}
+
+	if rlm.ID != realm.IDFromContext(r.Context()) {
+		return oauth.ErrEmailNotFound
+	}
+
+	return nil
+}
@@ -6,14 +6,27 @@ import (
 	"html/template"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 
 	"github.com/oxtoacart/bpool"
+	"github.com/resonatecoop/id/i18n"
+	"github.com/resonatecoop/id/realm"
 )
 
+// language is a link switching the page to a supported language
+type language struct {
+	Code    string
+	Name    string
+	URL     string
+	Current bool
+}
+
 var (
-	templates map[string]*template.Template
+	// templates are parsed once per locale, with the t function translating
+	// to that locale
+	templates map[string]map[string]*template.Template
 	bufpool   *bpool.BufferPool
 	loaded    = false
 )
@@ -51,11 +64,21 @@ func loadData(dirname string) (map[string]interface{}, error) {
 // renderTemplate is a wrapper around template.ExecuteTemplate.
 // It writes into a bytes.Buffer before writing to the http.ResponseWriter to catch
 // any errors resulting from populating the template.
-func renderTemplate(w http.ResponseWriter, name string, data map[string]interface{}) error {
+// The branding of the realm the request was routed to is added to data,
+// and the template is rendered in the language of the request.
+func renderTemplate(w http.ResponseWriter, r *http.Request, name string, data map[string]interface{}) error {
+	return renderTemplateWithStatus(w, r, name, data, http.StatusOK)
+}
+
+// renderTemplateWithStatus works like renderTemplate and responds with the
+// given status code, e.g. for error pages
+func renderTemplateWithStatus(w http.ResponseWriter, r *http.Request, name string, data map[string]interface{}, status int) error {
 	loadTemplates()
 
+	locale := i18n.FromContext(r.Context())
+
 	// Ensure the template exists in the map.
-	tmpl, ok := templates[name]
+	tmpl, ok := templates[locale][name]
 	if !ok {
 		return fmt.Errorf("The template %s does not exist", name)
 	}
@@ -76,6 +99,18 @@ func renderTemplate(w http.ResponseWriter, name string, data map[string]interfac
 
 	data["stylesheet"] = style["index.css"]
 
+	if rlm, ok := realm.FromContext(r.Context()); ok {
+		data["realm"] = rlm.BrandingData()
+	}
+
+	// Admins acting as a user always see who they are logged in as
+	if impersonation := getImpersonationData(r); impersonation != nil {
+		data["impersonation"] = impersonation
+	}
+
+	data["locale"] = locale
+	data["languages"] = getLanguages(r.URL, locale)
+
 	// Create a buffer to temporarily write to and check if any errors were encountered.
 	buf := bufpool.Get()
 	defer bufpool.Put(buf)
@@ -92,6 +127,7 @@ func renderTemplate(w http.ResponseWriter, name string, data map[string]interfac
 	w.Header().Set("X-Frame-Options", "deny")
 	// Set the header and write the buffer to the http.ResponseWriter
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
 	_, err = buf.WriteTo(w)
 	if err != nil {
 		return err
@@ -104,7 +140,7 @@ func loadTemplates() {
 		return
 	}
 
-	templates = make(map[string]*template.Template)
+	templates = make(map[string]map[string]*template.Template)
 
 	bufpool = bpool.NewBufferPool(64)
 
@@ -115,6 +151,9 @@ func loadTemplates() {
 			"./web/includes/password_reset.html",
 			"./web/includes/password_reset_update_password.html",
 			"./web/includes/home.html",
+			"./web/includes/logout.html",
+			"./web/includes/authorize_error.html",
+			"./web/includes/account_deletion_cancel.html",
 		},
 		"web/layouts/inside.html": {
 			"./web/includes/authorize.html",
@@ -124,15 +163,47 @@ func loadTemplates() {
 			"./web/includes/membership.html",
 			"./web/includes/profile.html",
 			"./web/includes/checkout.html",
+			"./web/includes/impersonate.html",
 		},
 	}
 
-	for layout, includes := range layoutTemplates {
-		for _, include := range includes {
-			files := []string{include, layout}
-			templates[filepath.Base(include)] = template.Must(template.ParseFiles(files...))
+	for _, l := range i18n.Locales {
+		locale := l.Code
+		funcs := template.FuncMap{
+			"t": func(msgid string, args ...interface{}) string {
+				return i18n.T(locale, msgid, args...)
+			},
+		}
+
+		templates[locale] = make(map[string]*template.Template)
+
+		for layout, includes := range layoutTemplates {
+			for _, include := range includes {
+				files := []string{include, layout}
+				name := filepath.Base(include)
+				templates[locale][name] = template.Must(template.New(name).Funcs(funcs).ParseFiles(files...))
+			}
 		}
 	}
 
 	loaded = true
 }
+
+// getLanguages returns links to the current page in every supported language
+func getLanguages(u *url.URL, locale string) []language {
+	languages := []language{}
+
+	for _, l := range i18n.Locales {
+		query := u.Query()
+		query.Set(i18n.QueryParam, l.Code)
+
+		languages = append(languages, language{
+			Code:    l.Code,
+			Name:    l.Name,
+			URL:     "?" + query.Encode(),
+			Current: l.Code == locale,
+		})
+	}
+
+	return languages
+}
@@ -84,7 +84,7 @@ func (s *Service) GetRoutes() []routes.Route {
 			HandlerFunc: s.logout,
 			Middlewares: []negroni.Handler{
 				new(parseFormMiddleware),
-				newLoggedInMiddleware(s),
+				newSessionMiddleware(s),
 			},
 		},
 		{
@@ -254,6 +254,29 @@ func (s *Service) GetRoutes() []routes.Route {
 				newClientMiddleware(s),
 			},
 		},
+		{
+			Name:        "account_deletion_cancel_form",
+			Method:      "GET",
+			Pattern:     "/account-deletion/cancel",
+			HandlerFunc: s.cancelAccountDeletionForm,
+			Middlewares: []negroni.Handler{
+				new(parseFormMiddleware),
+				newGuestMiddleware(s),
+			},
+		},
+		{
+			Name:        "account_deletion_cancel",
+			Method:      "POST",
+			Pattern:     "/account-deletion/cancel",
+			HandlerFunc: s.cancelAccountDeletion,
+			Middlewares: []negroni.Handler{
+				tollbooth_negroni.LimitHandler(
+					tollbooth.NewLimiter(1, nil),
+				),
+				new(parseFormMiddleware),
+				newGuestMiddleware(s),
+			},
+		},
 		{
 			Name:        "membership_form",
 			Method:      "GET",
@@ -404,6 +427,31 @@ func (s *Service) GetRoutes() []routes.Route {
 				newClientMiddleware(s),
 			},
 		},
+		{
+			Name:        "impersonate_form",
+			Method:      "GET",
+			Pattern:     "/impersonate",
+			HandlerFunc: s.impersonateForm,
+			Middlewares: []negroni.Handler{
+				new(parseFormMiddleware),
+				newLoggedInMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
+		{
+			Name:        "impersonate",
+			Method:      "POST",
+			Pattern:     "/impersonate",
+			HandlerFunc: s.impersonate,
+			Middlewares: []negroni.Handler{
+				tollbooth_negroni.LimitHandler(
+					tollbooth.NewLimiter(1, nil),
+				),
+				new(parseFormMiddleware),
+				newLoggedInMiddleware(s),
+				newClientMiddleware(s),
+			},
+		},
 		{
 			Name:        "get_email_confirmation_token",
 			Method:      "GET",
@@ -34,6 +34,7 @@ type ServiceInterface interface {
 	account(w http.ResponseWriter, r *http.Request)
 	accountSettingsForm(w http.ResponseWriter, r *http.Request)
 	accountSettings(w http.ResponseWriter, r *http.Request)
+	cancelAccountDeletion(w http.ResponseWriter, r *http.Request)
 	membershipForm(w http.ResponseWriter, r *http.Request)
 	membership(w http.ResponseWriter, r *http.Request)
 	checkoutForm(w http.ResponseWriter, r *http.Request)
@@ -43,6 +44,8 @@ type ServiceInterface interface {
 	clientForm(w http.ResponseWriter, r *http.Request)
 	client(w http.ResponseWriter, r *http.Request)
 	clientDelete(w http.ResponseWriter, r *http.Request)
+	impersonateForm(w http.ResponseWriter, r *http.Request)
+	impersonate(w http.ResponseWriter, r *http.Request)
 	loginForm(w http.ResponseWriter, r *http.Request)
 	login(w http.ResponseWriter, r *http.Request)
 	logout(w http.ResponseWriter, r *http.Request)
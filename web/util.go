@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+
+	"github.com/resonatecoop/id/oauth"
+	"github.com/resonatecoop/user-api/model"
 )
 
 // Redirects to a new path while keeping current request's query string
@@ -39,3 +42,13 @@ func errorRedirect(w http.ResponseWriter, r *http.Request, redirectURI *url.URL,
 		redirectWithFragment(redirectURI.String(), query, w, r)
 	}
 }
+
+// userRole returns the name of the role of the user, read from the user
+// record since the position of the role in a token scope is not fixed
+func userRole(service oauth.ServiceInterface, user *model.User) (string, error) {
+	role, err := service.FindRoleByID(user.RoleID)
+	if err != nil {
+		return "", err
+	}
+	return role.Name, nil
+}
@@ -3,17 +3,19 @@ package webhook
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"io/ioutil"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
-	"github.com/mailgun/mailgun-go/v4"
+	"github.com/resonatecoop/id/events"
 	"github.com/resonatecoop/id/log"
+	"github.com/resonatecoop/id/metrics"
+	"github.com/resonatecoop/id/realm"
+	"github.com/resonatecoop/id/tracing"
 	"github.com/resonatecoop/user-api/model"
 	"github.com/uptrace/bun"
+	"github.com/urfave/negroni"
 
 	"github.com/stripe/stripe-go/v72"
 	cus "github.com/stripe/stripe-go/v72/customer"
@@ -26,10 +28,20 @@ func (s *Service) stripePayment(w http.ResponseWriter, r *http.Request) {
 	const MaxBodyBytes = int64(65536)
 	r.Body = http.MaxBytesReader(w, r.Body, MaxBodyBytes)
 
+	// Record the event type along with the status code returned to stripe
+	eventType := "unknown"
+	rw := negroni.NewResponseWriter(w)
+	w = rw
+	defer func() {
+		metrics.ObserveStripeWebhookEvent(eventType, rw.Status())
+	}()
+
+	logger := log.FromContext(r.Context())
+
 	body, err := ioutil.ReadAll(r.Body)
 
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading request body: %v\n", err)
+		logger.ERROR.Printf("Error reading request body: %v", err)
 		w.WriteHeader(http.StatusServiceUnavailable)
 		return
 	}
@@ -38,12 +50,30 @@ func (s *Service) stripePayment(w http.ResponseWriter, r *http.Request) {
 	event, err := webhook.ConstructEvent(body, r.Header.Get("Stripe-Signature"), endpointSecret)
 
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error verifying webhook signature: %v\n", err)
+		logger.ERROR.Printf("Error verifying webhook signature: %v", err)
 		w.WriteHeader(http.StatusBadRequest) // Return a 400 error on a bad signature
 		return
 	}
 
-	stripe.Key = s.cnf.Stripe.Secret
+	eventType = event.Type
+	logger = logger.With("event_id", event.ID).With("event_type", event.Type)
+
+	// Events of connected accounts belong to the realm using the account
+	rlm, err := s.oauthService.GetRealmService().FindByStripeAccount(r.Context(), event.Account)
+
+	if err == realm.ErrRealmNotFound {
+		logger.WARNING.Printf("Ignoring event of unknown account: %s", event.Account)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err != nil {
+		logger.ERROR.Printf("Error looking up realm: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	logger = logger.With("realm", rlm.Slug)
 
 	stripe.SetAppInfo(&stripe.AppInfo{
 		Name:    "resonatecoop/id",
@@ -56,56 +86,59 @@ func (s *Service) stripePayment(w http.ResponseWriter, r *http.Request) {
 		var customer stripe.Customer
 		err := json.Unmarshal(event.Data.Raw, &customer)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error parsing webhook JSON: %v\n", err)
+			logger.ERROR.Printf("Error parsing webhook JSON: %v", err)
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
-		fmt.Println("Customer was created!")
+		logger.INFO.Print("Customer was created!")
 	case "customer.subscription.created":
 		var subscription stripe.Subscription
 		err := json.Unmarshal(event.Data.Raw, &subscription)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error parsing webhook JSON: %v\n", err)
+			logger.ERROR.Printf("Error parsing webhook JSON: %v", err)
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
-		fmt.Println("Subscription was created!")
+		logger.INFO.Print("Subscription was created!")
 	case "customer.subscription.updated":
 		var subscription stripe.Subscription
 		err := json.Unmarshal(event.Data.Raw, &subscription)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error parsing webhook JSON: %v\n", err)
+			logger.ERROR.Printf("Error parsing webhook JSON: %v", err)
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
-		fmt.Println("Subscription was updated!")
+		logger.INFO.Print("Subscription was updated!")
 	case "customer.subscription.deleted":
 		var subscription stripe.Subscription
 		err := json.Unmarshal(event.Data.Raw, &subscription)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error parsing webhook JSON: %v\n", err)
+			logger.ERROR.Printf("Error parsing webhook JSON: %v", err)
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 
-		fmt.Println("Subscription was deleted!")
+		logger.INFO.Print("Subscription was deleted!")
 
-		customer, err := cus.Get(subscription.Customer.ID, nil)
+		customerParams := &stripe.CustomerParams{}
+		rlm.UseStripeAccount(customerParams)
+		customer, err := cus.Get(subscription.Customer.ID, customerParams)
 
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error getting customer data: %v\n", err)
+			logger.ERROR.Printf("Error getting customer data: %v", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
 		subcriptionListParams := &stripe.SubscriptionListParams{}
 		subcriptionListParams.Filters.AddFilter("customer", "", customer.ID)
+		rlm.UseStripeAccount(subcriptionListParams)
 
 		subscriptionList := sub.List(subcriptionListParams)
 		err = subscriptionList.Err()
 
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error getting subscription data: %v\n", err)
+			logger.ERROR.Printf("Error getting subscription data: %v", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -113,18 +146,18 @@ func (s *Service) stripePayment(w http.ResponseWriter, r *http.Request) {
 		if !subscriptionList.Next() {
 			err := s.GrantMemberStatus(customer.Email, false)
 			if err != nil {
-				log.ERROR.Print(err)
+				logger.ERROR.Print(err)
 			}
 		}
 
-		if err = s.sendEmail(customer.Email, "Sorry you are leaving!", "cancel-subscription"); err != nil {
-			log.ERROR.Print(err)
+		if err = s.sendEmail(rlm, customer.Email, "Sorry you are leaving!", "cancel-subscription"); err != nil {
+			logger.ERROR.Print(err)
 		}
 	case "checkout.session.completed":
 		var session stripe.CheckoutSession
 		err := json.Unmarshal(event.Data.Raw, &session)
 		if err != nil {
-			log.ERROR.Print(err)
+			logger.ERROR.Print(err)
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
@@ -132,19 +165,21 @@ func (s *Service) stripePayment(w http.ResponseWriter, r *http.Request) {
 		productID := session.Metadata["product_id"]
 
 		if productID != "" {
-			fmt.Printf("Product id: %s", productID)
+			logger.INFO.Printf("Product id: %s", productID)
 		}
 
-		customer, err := cus.Get(session.Customer.ID, nil)
+		customerParams := &stripe.CustomerParams{}
+		rlm.UseStripeAccount(customerParams)
+		customer, err := cus.Get(session.Customer.ID, customerParams)
 
 		if err != nil {
-			log.ERROR.Print(err)
+			logger.ERROR.Print(err)
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 
 		if session.Subscription != nil {
-			if err = s.processMembership(customer.Email, session.Subscription.ID, productID); err != nil {
+			if err = s.processMembership(rlm, customer.Email, session.Subscription.ID, productID); err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				return
 			}
@@ -152,7 +187,7 @@ func (s *Service) stripePayment(w http.ResponseWriter, r *http.Request) {
 
 		/* BROKEN!
 		if session.Metadata["shares"] != "" {
-			log.INFO.Printf("Number of shares: %s", session.Metadata["shares"])
+			logger.INFO.Printf("Number of shares: %s", session.Metadata["shares"])
 
 			shares, err := strconv.ParseInt(session.Metadata["shares"], 10, 64)
 
@@ -164,7 +199,7 @@ func (s *Service) stripePayment(w http.ResponseWriter, r *http.Request) {
 			user, err := s.oauthService.FindUserByUsername(customer.Email)
 
 			if err != nil {
-				log.ERROR.Print(err)
+				logger.ERROR.Print(err)
 				w.WriteHeader(http.StatusBadRequest)
 				return
 			}
@@ -178,7 +213,7 @@ func (s *Service) stripePayment(w http.ResponseWriter, r *http.Request) {
 			}
 
 			if err = s.AddShares(user, invoiceID, shares); err != nil {
-				log.ERROR.Print(err)
+				logger.ERROR.Print(err)
 				w.WriteHeader(http.StatusBadRequest)
 				return
 			}
@@ -186,7 +221,7 @@ func (s *Service) stripePayment(w http.ResponseWriter, r *http.Request) {
 		*/
 
 		if session.Metadata["credits"] != "" {
-			log.INFO.Printf("Number of credits: %s", session.Metadata["credits"])
+			logger.INFO.Printf("Number of credits: %s", session.Metadata["credits"])
 
 			credits, err := strconv.ParseInt(session.Metadata["credits"], 10, 64)
 
@@ -198,13 +233,13 @@ func (s *Service) stripePayment(w http.ResponseWriter, r *http.Request) {
 			user, err := s.oauthService.FindUserByUsername(customer.Email)
 
 			if err != nil {
-				log.ERROR.Print(err)
+				logger.ERROR.Print(err)
 				w.WriteHeader(http.StatusBadRequest)
 				return
 			}
 
 			if err = s.AddCredits(user, credits); err != nil {
-				log.ERROR.Print(err)
+				logger.ERROR.Print(err)
 				w.WriteHeader(http.StatusBadRequest)
 				return
 			}
@@ -213,23 +248,23 @@ func (s *Service) stripePayment(w http.ResponseWriter, r *http.Request) {
 		var paymentIntent stripe.PaymentIntent
 		err := json.Unmarshal(event.Data.Raw, &paymentIntent)
 		if err != nil {
-			log.ERROR.Print(err)
+			logger.ERROR.Print(err)
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
-		fmt.Println("PaymentIntent was successful!")
+		logger.INFO.Print("PaymentIntent was successful!")
 	case "payment_method.attached":
 		var paymentMethod stripe.PaymentMethod
 		err := json.Unmarshal(event.Data.Raw, &paymentMethod)
 		if err != nil {
-			log.ERROR.Print(err)
+			logger.ERROR.Print(err)
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
-		fmt.Println("PaymentMethod was attached to a Customer!")
+		logger.INFO.Print("PaymentMethod was attached to a Customer!")
 		// ... handle other event types
 	default:
-		fmt.Fprintf(os.Stderr, "Unhandled event type: %s\n", event.Type)
+		logger.WARNING.Printf("Unhandled event type: %s", event.Type)
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -237,7 +272,7 @@ func (s *Service) stripePayment(w http.ResponseWriter, r *http.Request) {
 }
 
 // processMembership
-func (s *Service) processMembership(customerEmail, subscriptionID, productID string) error {
+func (s *Service) processMembership(rlm *realm.Realm, customerEmail, subscriptionID, productID string) error {
 	_, err := s.oauthService.FindUserByUsername(customerEmail)
 
 	if err != nil {
@@ -246,13 +281,14 @@ func (s *Service) processMembership(customerEmail, subscriptionID, productID str
 	}
 
 	templateName := ""
+	stripeCnf := rlm.StripeConfig(s.cnf.Stripe)
 
 	switch productID {
-	case s.cnf.Stripe.ListenerSubscription.ID:
+	case stripeCnf.ListenerSubscription.ID:
 		templateName = "listener-subscription"
-	case s.cnf.Stripe.ArtistMembership.ID:
+	case stripeCnf.ArtistMembership.ID:
 		templateName = "artist-subscription"
-	case s.cnf.Stripe.LabelMembership.ID:
+	case stripeCnf.LabelMembership.ID:
 		templateName = "label-subscription"
 	}
 
@@ -269,7 +305,7 @@ func (s *Service) processMembership(customerEmail, subscriptionID, productID str
 			return err
 		}
 
-		if err = s.sendEmail(customerEmail, "Welcome to Resonate!", templateName); err != nil {
+		if err = s.sendEmail(rlm, customerEmail, "Welcome to Resonate!", templateName); err != nil {
 			log.ERROR.Print(err)
 		}
 	}
@@ -278,9 +314,9 @@ func (s *Service) processMembership(customerEmail, subscriptionID, productID str
 }
 
 // sendEmail
-func (s *Service) sendEmail(to, subject, templateName string) error {
-	mg := mailgun.NewMailgun(s.cnf.Mailgun.Domain, s.cnf.Mailgun.Key)
-	sender := s.cnf.Mailgun.Sender
+func (s *Service) sendEmail(rlm *realm.Realm, to, subject, templateName string) error {
+	mg := tracing.NewMailgun(s.cnf.Mailgun.Domain, s.cnf.Mailgun.Key)
+	sender := rlm.Sender(s.cnf)
 	body := ""
 	email := model.NewOauthEmail(
 		to,
@@ -290,13 +326,14 @@ func (s *Service) sendEmail(to, subject, templateName string) error {
 
 	recipient := email.Recipient
 	message := mg.NewMessage(sender, subject, body, recipient)
-	message.SetTemplate(email.Template) // set mailgun template
+	message.SetTemplate(rlm.Template(email.Template)) // set mailgun template
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
 
 	// Send the message with a 10 second timeout
 	_, _, err := mg.Send(ctx, message)
+	metrics.ObserveEmail(email.Template, err)
 
 	if err != nil {
 		log.ERROR.Print(err)
@@ -314,13 +351,40 @@ func (s *Service) GrantMemberStatus(email string, status bool) error {
 		return err
 	}
 
-	_, err = s.db.NewUpdate().
+	// renewals keep the status as is, subscribers only hear of changes
+	if user.Member == status {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NewUpdate().
 		Model(user).
 		Set("member = ?", status).
 		WherePK().
 		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
 
-	return err
+	eventType := events.UserMembershipGranted
+	if !status {
+		eventType = events.UserMembershipRevoked
+	}
+
+	_, err = s.oauthService.GetEventsService().Emit(ctx, tx, eventType, user.ID, map[string]interface{}{
+		"username": user.Username,
+	})
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // AddShares ...
@@ -420,11 +484,29 @@ func (s *Service) addCreditsCommon(db *bun.DB, user *model.User, amount int64) e
 
 	total := credit.Total + amount
 
-	_, err = db.NewUpdate().
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NewUpdate().
 		Model(credit).
 		Set("total = ?", total).
 		Where("user_id = ?", user.IDRecord.ID).
 		Exec(ctx)
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
 
-	return err
+	_, err = s.oauthService.GetEventsService().Emit(ctx, tx, events.UserCreditsPurchased, user.ID, map[string]interface{}{
+		"amount": amount,
+		"total":  total,
+	})
+	if err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	return tx.Commit()
 }